@@ -0,0 +1,266 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package benchmarks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/cccsp/hash"
+	"github.com/rkcloudchain/cccsp/provider"
+	"github.com/rkcloudchain/rksync"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+)
+
+// pollInterval is the frequency the state of the peers is checked at while waiting for them
+const pollInterval = 5 * time.Millisecond
+
+// Options tunes the peers of a Cluster
+type Options struct {
+	// Configure is invoked with the gossip configuration of every peer before it is started,
+	// e.g. to compare the propagation with other intervals than the ones of the cluster
+	Configure func(peer int, cfg *config.GossipConfig)
+
+	// LogLevels of the modules of the peers, the entries below WarnLevel are discarded if nil
+	LogLevels map[string]logging.Level
+}
+
+// Peer is a peer of a Cluster
+type Peer struct {
+	*rksync.Server
+	ID       string            // Node ID of the peer
+	Endpoint string            // Address the peer listens to
+	Cert     *x509.Certificate // Certificate of the identity of the peer
+	DataDir  string            // Directory the files of the channels of the peer are stored in
+}
+
+// Cluster is a set of peers run in the process, connected over the loopback interface.
+// The peers bootstrap from the first one.
+type Cluster struct {
+	Peers []*Peer
+}
+
+// NewCluster starts a cluster of n peers, whose identities and files are stored in dir.
+// The peers are issued identities by a CA generated for the cluster.
+func NewCluster(dir string, n int, opts Options) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.New("A cluster needs at least one peer")
+	}
+	levels := opts.LogLevels
+	if levels == nil {
+		levels = make(map[string]logging.Level)
+		for _, module := range []string{"rksync", "gossip", "channel", "discovery", "identity", "rpc"} {
+			levels[module] = logging.WarnLevel
+		}
+	}
+
+	ca, err := newAuthority()
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			closeListeners(listeners)
+			return nil, errors.Wrap(err, "Failed listening")
+		}
+		listeners = append(listeners, l)
+	}
+
+	c := &Cluster{}
+	for i, l := range listeners {
+		p := &Peer{
+			ID:       fmt.Sprintf("peer%d.bench", i),
+			Endpoint: l.Addr().String(),
+			DataDir:  filepath.Join(dir, fmt.Sprintf("peer%d", i), "data"),
+		}
+		home := filepath.Join(dir, fmt.Sprintf("peer%d", i), "home")
+		p.Cert, err = ca.issue(home, p.ID, int64(i+2))
+		if err == nil {
+			err = os.MkdirAll(p.DataDir, 0755)
+		}
+		if err != nil {
+			closeListeners(listeners[i:])
+			c.Stop()
+			return nil, err
+		}
+
+		cfg := &config.GossipConfig{
+			FileSystem:               mocks.NewFSMock(p.DataDir),
+			BootstrapPeers:           []string{listeners[0].Addr().String()},
+			Endpoint:                 p.Endpoint,
+			AliveTimeInterval:        time.Second,
+			PullInterval:             time.Second,
+			PublishStateInfoInterval: time.Second,
+			RequestStateInfoInterval: time.Second,
+			LogLevels:                levels,
+		}
+		if opts.Configure != nil {
+			opts.Configure(i, cfg)
+		}
+
+		p.Server, err = rksync.Serve(l, &config.Config{
+			HomeDir:  home,
+			Gossip:   cfg,
+			Identity: &config.IdentityConfig{ID: p.ID},
+		})
+		if err != nil {
+			closeListeners(listeners[i:])
+			c.Stop()
+			return nil, errors.WithMessage(err, fmt.Sprintf("Failed starting peer %d", i))
+		}
+		c.Peers = append(c.Peers, p)
+	}
+	return c, nil
+}
+
+// Stop stops the peers of the cluster
+func (c *Cluster) Stop() {
+	for _, p := range c.Peers {
+		p.Stop()
+	}
+}
+
+// CreateChannel creates a channel led by the given peer, with the other peers of the cluster as members.
+// It returns once all of them joined the channel, or the timeout expired.
+func (c *Cluster) CreateChannel(chainID string, leader int, timeout time.Duration) error {
+	l := c.Peers[leader]
+	if err := l.CreateChannel(chainID, nil); err != nil {
+		return err
+	}
+	var members []*Peer
+	for i, p := range c.Peers {
+		if i == leader {
+			continue
+		}
+		if err := l.AddMemberToChan(chainID, p.ID, p.Cert); err != nil {
+			return err
+		}
+		members = append(members, p)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, p := range members {
+		for !p.joined(chainID) {
+			if time.Now().After(deadline) {
+				return errors.Errorf("%s didn't join channel %s within %s", p.ID, chainID, timeout)
+			}
+			time.Sleep(pollInterval)
+		}
+	}
+	return nil
+}
+
+func (p *Peer) joined(chainID string) bool {
+	for _, summary := range p.Channels() {
+		if summary.ChainID == chainID {
+			return true
+		}
+	}
+	return false
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// authority issues the identities of the peers of a cluster
+type authority struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newAuthority() (*authority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed generating CA key")
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.bench"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed creating CA certificate")
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed parsing CA certificate")
+	}
+	return &authority{key: key, cert: cert}, nil
+}
+
+// issue writes the CA certificate, and a certificate and a key of the node to the csp directory of home
+func (a *authority) issue(home, nodeID string, serial int64) (*x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed generating key")
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: nodeID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, a.cert, key.Public(), a.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed creating certificate")
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed parsing certificate")
+	}
+	privRaw, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed marshaling key")
+	}
+	ski, err := provider.GetDefault().Hash(elliptic.Marshal(key.Curve, key.X, key.Y), hash.SHA3256)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed computing key identifier")
+	}
+
+	files := map[string]*pem.Block{
+		"csp/cacerts/ca.pem":                              {Type: "CERTIFICATE", Bytes: a.cert.Raw},
+		"csp/signcerts/cert.pem":                          {Type: "CERTIFICATE", Bytes: raw},
+		"csp/keystore/" + hex.EncodeToString(ski) + "_sk": {Type: "PRIVATE KEY", Bytes: privRaw},
+	}
+	for name, block := range files {
+		path := filepath.Join(home, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
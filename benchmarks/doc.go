@@ -0,0 +1,18 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package benchmarks runs clusters of rksync peers in the process, connected over the
+// loopback interface, and measures how the files added to their channels propagate.
+// A Scenario describes the load: the number of peers, of channels and the size of the
+// files, whose content is derived from a seed so that the runs are reproducible.
+//
+// The scenarios run as Go benchmarks, use `make bench` to run them. Besides the time
+// and the allocations per round, they report the time the members took to learn about
+// the files (propagation) and to receive their whole content (convergence). The CPU and
+// memory profiles of the peers are those of the test binary, e.g.
+//
+//	make bench BENCH_FLAGS="-cpuprofile cpu.out -memprofile mem.out"
+package benchmarks
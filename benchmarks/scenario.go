@@ -0,0 +1,283 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package benchmarks
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+)
+
+// Scenario describes a reproducible load: at each round, files of a given size are added to every channel of a cluster
+type Scenario struct {
+	Peers    int   // Number of peers of the cluster
+	Channels int   // Number of channels, led by the peers in turn, the other peers are members of all of them
+	Files    int   // Number of files added to every channel at each round
+	FileSize int64 // Size in bytes of the files
+	Seed     int64 // Seed the content of the files is derived from
+}
+
+// Name returns a short description of the scenario, e.g. "3peers-2channels-1x1MB"
+func (s Scenario) Name() string {
+	return fmt.Sprintf("%dpeers-%dchannels-%dx%s", s.Peers, s.Channels, s.Files, formatSize(s.FileSize))
+}
+
+// RoundBytes returns the number of bytes received by the members of the channels at each round
+func (s Scenario) RoundBytes() int64 {
+	return int64(s.Channels*s.Files*(s.Peers-1)) * s.FileSize
+}
+
+func formatSize(size int64) string {
+	for _, unit := range []struct {
+		size int64
+		name string
+	}{{1 << 30, "GB"}, {1 << 20, "MB"}, {1 << 10, "KB"}} {
+		if size >= unit.size && size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// Result holds the measures of a round of a scenario
+type Result struct {
+	Propagation []time.Duration // Time each member of each channel took to learn about the files of the round, sorted
+	Convergence time.Duration   // Time until every member of every channel received the whole content of the files
+}
+
+// Percentile returns the propagation time below which the given fraction of the members learned about the files
+func (r Result) Percentile(p float64) time.Duration {
+	if len(r.Propagation) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(r.Propagation))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.Propagation) {
+		i = len(r.Propagation) - 1
+	}
+	return r.Propagation[i]
+}
+
+// Load runs the rounds of a scenario against a cluster
+type Load struct {
+	Scenario
+	Cluster  *Cluster
+	round    int
+	prepared []string            // Files of the next round
+	files    map[string][]string // Files added to each channel
+}
+
+// NewLoad starts the cluster of the scenario in dir and creates its channels
+func NewLoad(s Scenario, dir string, opts Options) (*Load, error) {
+	if s.Peers < 2 || s.Channels < 1 || s.Files < 1 || s.FileSize < 1 {
+		return nil, errors.Errorf("Invalid scenario %s", s.Name())
+	}
+	cluster, err := NewCluster(dir, s.Peers, opts)
+	if err != nil {
+		return nil, err
+	}
+	l := &Load{Scenario: s, Cluster: cluster, files: make(map[string][]string)}
+	for c := 0; c < s.Channels; c++ {
+		if err := cluster.CreateChannel(l.chainID(c), l.leader(c), time.Minute); err != nil {
+			cluster.Stop()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Stop stops the cluster
+func (l *Load) Stop() {
+	l.Cluster.Stop()
+}
+
+func (l *Load) chainID(c int) string {
+	return fmt.Sprintf("bench%d", c)
+}
+
+func (l *Load) leader(c int) int {
+	return c % l.Peers
+}
+
+// Prepare writes the files of the next round to the data directories of the leaders of the channels,
+// so that the time spent generating them isn't measured
+func (l *Load) Prepare() error {
+	l.round++
+	l.prepared = nil
+	for c := 0; c < l.Channels; c++ {
+		leader := l.Cluster.Peers[l.leader(c)]
+		for f := 0; f < l.Files; f++ {
+			name := fmt.Sprintf("%s-r%d-f%d", l.chainID(c), l.round, f)
+			seed := l.Seed + int64(l.round*l.Channels*l.Files+c*l.Files+f)
+			if err := writeFile(filepath.Join(leader.DataDir, name), l.FileSize, seed); err != nil {
+				return err
+			}
+			l.prepared = append(l.prepared, name)
+		}
+	}
+	return nil
+}
+
+// Round adds the prepared files to the channels, and waits until all the members received them
+func (l *Load) Round(timeout time.Duration) (Result, error) {
+	if l.prepared == nil {
+		return Result{}, errors.New("Prepare must be invoked before each round")
+	}
+	names := make(map[string][]string)
+	for c := 0; c < l.Channels; c++ {
+		names[l.chainID(c)] = l.prepared[c*l.Files : (c+1)*l.Files]
+	}
+	l.prepared = nil
+
+	start := time.Now()
+	for c := 0; c < l.Channels; c++ {
+		chainID := l.chainID(c)
+		var infos []*common.FileSyncInfo
+		for _, name := range names[chainID] {
+			infos = append(infos, &common.FileSyncInfo{Path: name, Mode: "Append"})
+		}
+		if err := l.Cluster.Peers[l.leader(c)].AddFileToChan(chainID, infos); err != nil {
+			return Result{}, err
+		}
+		l.files[chainID] = append(l.files[chainID], names[chainID]...)
+	}
+
+	type member struct {
+		peer    *Peer
+		chainID string
+		known   bool
+	}
+	var pending []*member
+	for c := 0; c < l.Channels; c++ {
+		for i, p := range l.Cluster.Peers {
+			if i != l.leader(c) {
+				pending = append(pending, &member{peer: p, chainID: l.chainID(c)})
+			}
+		}
+	}
+
+	var res Result
+	deadline := start.Add(timeout)
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			return Result{}, errors.Errorf("%d members didn't receive the files within %s", len(pending), timeout)
+		}
+		now := time.Now()
+		remaining := pending[:0]
+		for _, m := range pending {
+			known, synced := l.received(m.peer, m.chainID, names[m.chainID])
+			if known && !m.known {
+				m.known = true
+				res.Propagation = append(res.Propagation, now.Sub(start))
+			}
+			if !synced {
+				remaining = append(remaining, m)
+			}
+		}
+		pending = remaining
+		if len(pending) > 0 {
+			time.Sleep(pollInterval)
+		}
+	}
+	res.Convergence = time.Since(start)
+	sort.Slice(res.Propagation, func(i, j int) bool { return res.Propagation[i] < res.Propagation[j] })
+	return res, nil
+}
+
+// received returns whether the peer knows the files of the channel, and whether it received their whole content
+func (l *Load) received(p *Peer, chainID string, names []string) (known bool, synced bool) {
+	files, err := p.ChannelFiles(chainID)
+	if err != nil {
+		return false, false
+	}
+	states := make(map[string]channel.FileState, len(files))
+	for _, f := range files {
+		states[f.Filename] = f
+	}
+	synced = true
+	for _, name := range names {
+		state, exists := states[name]
+		if !exists {
+			return false, false
+		}
+		if state.Size != l.FileSize || state.Transferring {
+			synced = false
+		}
+	}
+	return true, synced
+}
+
+// Verify checks that the copies of the files of the members match the ones of the leaders
+func (l *Load) Verify() error {
+	for c := 0; c < l.Channels; c++ {
+		chainID := l.chainID(c)
+		leader := l.Cluster.Peers[l.leader(c)]
+		for _, name := range l.files[chainID] {
+			expected, err := digest(filepath.Join(leader.DataDir, name))
+			if err != nil {
+				return err
+			}
+			for i, p := range l.Cluster.Peers {
+				if i == l.leader(c) {
+					continue
+				}
+				actual, err := digest(filepath.Join(p.DataDir, name))
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(expected, actual) {
+					return errors.Errorf("The copy of %s of %s differs from the one of the leader", name, p.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile writes size pseudo-random bytes derived from the seed to the file
+func writeFile(path string, size int64, seed int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	if _, err := io.CopyN(w, rand.New(rand.NewSource(seed)), size); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Failed writing %s", path)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Failed writing %s", path)
+	}
+	return f.Close()
+}
+
+func digest(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, errors.Wrapf(err, "Failed reading %s", path)
+	}
+	return h.Sum(nil), nil
+}
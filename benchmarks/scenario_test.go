@@ -0,0 +1,97 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package benchmarks
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	maxFileSize  = flag.Int64("benchmarks.maxsize", 100<<20, "Size in bytes above which the scenarios are skipped")
+	roundTimeout = flag.Duration("benchmarks.timeout", 30*time.Minute, "Time the members have to receive the files of a round")
+)
+
+// scenarios are the loads the benchmarks are run with, the ones with files larger
+// than -benchmarks.maxsize are skipped since they need a lot of time and disk space
+var scenarios = []Scenario{
+	// The size of the files
+	{Peers: 3, Channels: 1, Files: 1, FileSize: 1 << 10},
+	{Peers: 3, Channels: 1, Files: 1, FileSize: 1 << 20},
+	{Peers: 3, Channels: 1, Files: 1, FileSize: 100 << 20},
+	{Peers: 3, Channels: 1, Files: 1, FileSize: 1 << 30},
+	{Peers: 3, Channels: 1, Files: 1, FileSize: 10 << 30},
+	// The number of peers
+	{Peers: 8, Channels: 1, Files: 1, FileSize: 1 << 20},
+	{Peers: 16, Channels: 1, Files: 1, FileSize: 1 << 20},
+	// The number of channels and of files
+	{Peers: 4, Channels: 8, Files: 1, FileSize: 1 << 20},
+	{Peers: 4, Channels: 32, Files: 1, FileSize: 1 << 10},
+	{Peers: 4, Channels: 4, Files: 16, FileSize: 64 << 10},
+}
+
+func TestLoad(t *testing.T) {
+	s := Scenario{Peers: 3, Channels: 2, Files: 2, FileSize: 4 << 10, Seed: 1}
+	assert.Equal(t, "3peers-2channels-2x4KB", s.Name())
+	assert.Equal(t, int64(2*2*2*4<<10), s.RoundBytes())
+
+	load, err := NewLoad(s, t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer load.Stop()
+
+	_, err = load.Round(time.Minute)
+	assert.Error(t, err)
+
+	for round := 0; round < 2; round++ {
+		require.NoError(t, load.Prepare())
+		res, err := load.Round(time.Minute)
+		require.NoError(t, err)
+		require.Len(t, res.Propagation, 4)
+		assert.True(t, res.Percentile(0.5) <= res.Percentile(1))
+		assert.True(t, res.Propagation[3] <= res.Convergence)
+	}
+	assert.NoError(t, load.Verify())
+	assert.Len(t, load.files["bench0"], 4)
+}
+
+func BenchmarkPropagation(b *testing.B) {
+	for _, s := range scenarios {
+		s := s
+		b.Run(s.Name(), func(b *testing.B) {
+			if s.FileSize > *maxFileSize {
+				b.Skipf("Files larger than %d bytes", *maxFileSize)
+			}
+			load, err := NewLoad(s, b.TempDir(), Options{})
+			require.NoError(b, err)
+			defer load.Stop()
+
+			var propagation, convergence time.Duration
+			b.SetBytes(s.RoundBytes())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				require.NoError(b, load.Prepare())
+				b.StartTimer()
+
+				res, err := load.Round(*roundTimeout)
+				require.NoError(b, err)
+				propagation += res.Percentile(0.5)
+				convergence += res.Convergence
+			}
+			b.StopTimer()
+			require.NoError(b, load.Verify())
+
+			b.ReportMetric(float64(propagation.Milliseconds())/float64(b.N), "propagation-ms")
+			b.ReportMetric(float64(convergence.Milliseconds())/float64(b.N), "convergence-ms")
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// FileAck is the acknowledgement sent by a member to the leader once its copy of a file is synchronized
+type FileAck struct {
+	Member   common.PKIidType
+	Filename string
+	Size     int64  // Size of the member's copy
+	Digest   []byte // SHA-256 digest of the member's copy
+	Version  uint64 // Latest version of the file published by the leader the member knew of
+	Received time.Time
+}
+
+// fileAcks records the latest acknowledgement of each file of each member, on the leader
+type fileAcks struct {
+	sync.Mutex
+	acks      map[string]map[string]FileAck // member's PKI-ID string -> filename -> ack
+	lastSweep time.Time
+}
+
+func newFileAcks() *fileAcks {
+	return &fileAcks{acks: make(map[string]map[string]FileAck)}
+}
+
+func (fa *fileAcks) record(ack FileAck) {
+	fa.Lock()
+	defer fa.Unlock()
+
+	files, exists := fa.acks[ack.Member.String()]
+	if !exists {
+		files = make(map[string]FileAck)
+		fa.acks[ack.Member.String()] = files
+	}
+	files[ack.Filename] = ack
+}
+
+// sweep forgets the acknowledgements of the peers which left the channel and of the files removed from it,
+// at most every interval
+func (fa *fileAcks) sweep(now time.Time, interval time.Duration, members map[string]common.PKIidType, files []*protos.File) {
+	fa.Lock()
+	defer fa.Unlock()
+
+	if now.Sub(fa.lastSweep) < interval {
+		return
+	}
+	fa.lastSweep = now
+	paths := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		paths[file.Path] = struct{}{}
+	}
+	for key, acks := range fa.acks {
+		if _, exists := members[key]; !exists {
+			delete(fa.acks, key)
+			continue
+		}
+		for filename := range acks {
+			if _, exists := paths[filename]; !exists {
+				delete(acks, filename)
+			}
+		}
+	}
+}
+
+// list returns the acknowledgements of the given members for the given files, sorted by member and filename
+func (fa *fileAcks) list(members map[string]common.PKIidType, files []*protos.File) []FileAck {
+	fa.Lock()
+	defer fa.Unlock()
+
+	var acks []FileAck
+	for key := range members {
+		for _, file := range files {
+			if ack, exists := fa.acks[key][file.Path]; exists {
+				acks = append(acks, ack)
+			}
+		}
+	}
+	sort.Slice(acks, func(i, j int) bool {
+		if mi, mj := acks[i].Member.String(), acks[j].Member.String(); mi != mj {
+			return mi < mj
+		}
+		return acks[i].Filename < acks[j].Filename
+	})
+	return acks
+}
+
+func (gc *gossipChannel) FileAcks() []FileAck {
+	if !gc.leader {
+		return nil
+	}
+
+	gc.RLock()
+	defer gc.RUnlock()
+	if gc.chainStateMsg == nil {
+		return nil
+	}
+	stateInfo, err := gc.chainStateMsg.GetChainStateInfo()
+	if err != nil {
+		return nil
+	}
+	return gc.fileAcks.list(gc.members, stateInfo.Properties.Files)
+}
+
+// handleFileAck records the acknowledgement of a file sent by a member, on the leader
+func (gc *gossipChannel) handleFileAck(msg protos.ReceivedMessage) {
+	sender := msg.GetConnectionInfo().ID
+	if !gc.leader {
+		gc.logger.Debugf("Channel %s: Ignoring FileAck message sent from %s, not the leader", gc.chainMac, sender)
+		return
+	}
+	if !gc.IsMemberInChan(common.NetworkMember{PKIID: sender}) {
+		gc.logger.Warningf("Channel %s: Received FileAck message from %s, not member in channel", gc.chainMac, sender)
+		return
+	}
+
+	m := msg.GetRKSyncMessage()
+	err := m.Verify(sender, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
+	})
+	if err != nil {
+		gc.ReportMisbehavior(sender, common.InvalidSignature)
+		gc.logger.Warningf("Channel %s: Failed verifying FileAck message: %v, sent from %s", gc.chainMac, err, sender)
+		return
+	}
+
+	ack := m.GetFileAck()
+	gc.fileAcks.record(FileAck{
+		Member:   sender,
+		Filename: ack.FileName,
+		Size:     ack.Size,
+		Digest:   ack.Digest,
+		Version:  ack.Version,
+		Received: gc.clock.Now(),
+	})
+
+	gc.RLock()
+	defer gc.RUnlock()
+	if gc.chainStateMsg == nil {
+		return
+	}
+	stateInfo, err := gc.chainStateMsg.GetChainStateInfo()
+	if err != nil {
+		return
+	}
+	gc.fileAcks.sweep(gc.clock.Now(), gc.GetChannelConfig().StateInfoCacheSweepInterval, gc.members, stateInfo.Properties.Files)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/lib"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAcks(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	assert.Empty(t, leader.FileAcks())
+
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer follower.Stop()
+	outsider := NewGossipChannel(peer2.pkiID, mac, "testchannel", false, peer2.adapter, peer2.idMapper, logging.Default()).(*gossipChannel)
+	defer outsider.Stop()
+
+	createAck := func(gc *gossipChannel, filename string, size int64) *protos.SignedRKSyncMessage {
+		msg, err := (&fsyncAdapterImpl{gc}).Sign(&protos.RKSyncMessage{
+			ChainMac: mac,
+			Tag:      protos.RKSyncMessage_CHAN_ONLY,
+			Content: &protos.RKSyncMessage_FileAck{
+				FileAck: &protos.FileAck{FileName: filename, Size: size, Digest: []byte{1, 2, 3}, Version: 1},
+			},
+		})
+		require.NoError(t, err)
+		return msg
+	}
+
+	leader.HandleMessage(&receivedMessageMock{msg: createAck(follower, "config.yaml", 1024), sender: peer1.pkiID})
+	acks := leader.FileAcks()
+	require.Len(t, acks, 1)
+	assert.Equal(t, peer1.pkiID, acks[0].Member)
+	assert.Equal(t, "config.yaml", acks[0].Filename)
+	assert.Equal(t, int64(1024), acks[0].Size)
+	assert.Equal(t, []byte{1, 2, 3}, acks[0].Digest)
+	assert.Equal(t, uint64(1), acks[0].Version)
+	assert.False(t, acks[0].Received.IsZero())
+
+	// The latest acknowledgement replaces the previous one
+	leader.HandleMessage(&receivedMessageMock{msg: createAck(follower, "config.yaml", 2048), sender: peer1.pkiID})
+	acks = leader.FileAcks()
+	require.Len(t, acks, 1)
+	assert.Equal(t, int64(2048), acks[0].Size)
+
+	// Acknowledgements of files not in the channel, from non-members or not signed by the sender are discarded
+	leader.HandleMessage(&receivedMessageMock{msg: createAck(follower, "rfc2616.txt", 1024), sender: peer1.pkiID})
+	leader.HandleMessage(&receivedMessageMock{msg: createAck(outsider, "config.yaml", 1024), sender: peer2.pkiID})
+	leader.HandleMessage(&receivedMessageMock{msg: createAck(outsider, "config.yaml", 4096), sender: peer1.pkiID})
+	acks = leader.FileAcks()
+	require.Len(t, acks, 1)
+	assert.Equal(t, peer1.pkiID, acks[0].Member)
+	assert.Equal(t, int64(2048), acks[0].Size)
+
+	// The acknowledgements of removed members are no longer reported
+	_, err = leader.RemoveMember(peer1.pkiID)
+	require.NoError(t, err)
+	assert.Empty(t, leader.FileAcks())
+
+	// Only the leader collects the acknowledgements
+	follower.HandleMessage(&receivedMessageMock{msg: createAck(outsider, "config.yaml", 1024), sender: peer2.pkiID})
+	assert.Nil(t, follower.FileAcks())
+}
+
+func TestSweepFileAcks(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	clock := lib.NewFakeClock(time.Now())
+	leaderPeer.adapter.clock = clock
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default()).(*gossipChannel)
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID, peer2.pkiID}, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+
+	ack := func(peer *peer) {
+		gc := NewGossipChannel(peer.pkiID, mac, "testchannel", false, peer.adapter, peer.idMapper, logging.Default()).(*gossipChannel)
+		defer gc.Stop()
+		msg, err := (&fsyncAdapterImpl{gc}).Sign(&protos.RKSyncMessage{
+			ChainMac: mac,
+			Tag:      protos.RKSyncMessage_CHAN_ONLY,
+			Content:  &protos.RKSyncMessage_FileAck{FileAck: &protos.FileAck{FileName: "config.yaml", Size: 1024}},
+		})
+		require.NoError(t, err)
+		leader.HandleMessage(&receivedMessageMock{msg: msg, sender: peer.pkiID})
+	}
+	recorded := func(peer *peer) bool {
+		leader.fileAcks.Lock()
+		defer leader.fileAcks.Unlock()
+		_, exists := leader.fileAcks.acks[peer.pkiID.String()]
+		return exists
+	}
+
+	ack(peer1)
+	_, err = leader.RemoveMember(peer1.pkiID)
+	require.NoError(t, err)
+	clock.Advance(5 * time.Second)
+	ack(peer2)
+	assert.True(t, recorded(peer1))
+
+	// The sweep interval derived from the pull interval changed at runtime applies to the live channel
+	leaderPeer.adapter.setPullInterval(time.Second)
+	ack(peer2)
+	assert.False(t, recorded(peer1))
+	assert.True(t, recorded(peer2))
+}
@@ -0,0 +1,427 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxChangeLogRecords is the number of records sent at most in a ChangeLogResponse
+const maxChangeLogRecords = 100
+
+// Change is a record of the change log of a channel, signed by the leader
+type Change struct {
+	Index     uint64
+	Op        protos.ChangeRecord_Op
+	Members   []common.PKIidType // Members added or removed, or all of them for an INITIALIZE record
+	Files     []string           // Files added or removed, or all of them for an INITIALIZE record
+	SeqNum    uint64             // Sequence number of the chain state the change led to
+	Timestamp time.Time
+}
+
+// changeLog is the history of the membership and file changes of a channel.
+// Each record holds the hash of the previous one, and is appended to a file if a path is given.
+// The log starts with an INITIALIZE record, holding the members and files of the channel at that time.
+type changeLog struct {
+	sync.Mutex
+	path      string
+	records   []*protos.ChangeRecord
+	envelopes []*protos.Envelope
+}
+
+// newChangeLog loads the change log persisted at the given path, the records
+// following a truncated or corrupted one are discarded
+func newChangeLog(path string, logger logging.Logger) (*changeLog, error) {
+	l := &changeLog{path: path}
+	if path == "" {
+		return l, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return l, errors.Wrap(err, "Failed creating the change log directory")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, errors.Wrapf(err, "Failed reading change log %s", path)
+	}
+
+	var offset int
+	for offset < len(data) {
+		size, n := binary.Uvarint(data[offset:])
+		if n <= 0 || uint64(len(data)-offset-n) < size {
+			break
+		}
+		env := &protos.Envelope{}
+		if err := proto.Unmarshal(data[offset+n:offset+n+int(size)], env); err != nil {
+			break
+		}
+		record, err := env.ToChangeRecord()
+		if err != nil || l.check(record) != nil {
+			break
+		}
+		l.records = append(l.records, record)
+		l.envelopes = append(l.envelopes, env)
+		offset += n + int(size)
+	}
+	if offset < len(data) {
+		logger.Warningf("Discarding the %d trailing bytes of change log %s, they don't hold a valid record", len(data)-offset, path)
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return l, errors.Wrapf(err, "Failed truncating change log %s", path)
+		}
+	}
+	return l, nil
+}
+
+// check returns an error if the record doesn't follow the last one of the log
+func (l *changeLog) check(record *protos.ChangeRecord) error {
+	if len(l.records) == 0 {
+		if record.Op != protos.ChangeRecord_INITIALIZE {
+			return errors.Errorf("Change log must start with an INITIALIZE record, got %s", record.Op)
+		}
+		return nil
+	}
+	if next := l.next(); record.Index != next {
+		return errors.Errorf("Expected change record %d, got %d", next, record.Index)
+	}
+	if !bytes.Equal(record.PrevHash, l.lastHash()) {
+		return errors.Errorf("Change record %d doesn't hold the hash of the previous record", record.Index)
+	}
+	return nil
+}
+
+// next returns the index of the next record of the log
+func (l *changeLog) next() uint64 {
+	if len(l.records) == 0 {
+		return 0
+	}
+	return l.records[len(l.records)-1].Index + 1
+}
+
+// lastHash returns the hash of the last record of the log, nil if it's empty
+func (l *changeLog) lastHash() []byte {
+	if len(l.envelopes) == 0 {
+		return nil
+	}
+	return util.ComputeSHA3256(l.envelopes[len(l.envelopes)-1].Payload)
+}
+
+// last returns the last record of the log, nil if it's empty
+func (l *changeLog) last() *protos.ChangeRecord {
+	if len(l.records) == 0 {
+		return nil
+	}
+	return l.records[len(l.records)-1]
+}
+
+// append persists the record and adds it to the log, it must follow the last record
+func (l *changeLog) append(env *protos.Envelope, record *protos.ChangeRecord) error {
+	if err := l.check(record); err != nil {
+		return err
+	}
+	if err := l.write(env, os.O_APPEND); err != nil {
+		return err
+	}
+	l.records = append(l.records, record)
+	l.envelopes = append(l.envelopes, env)
+	return nil
+}
+
+// reset replaces the log with an INITIALIZE record, discarding the history preceding it
+func (l *changeLog) reset(env *protos.Envelope, record *protos.ChangeRecord) error {
+	if record.Op != protos.ChangeRecord_INITIALIZE {
+		return errors.Errorf("Change log can only be reset with an INITIALIZE record, got %s", record.Op)
+	}
+	if err := l.write(env, os.O_TRUNC); err != nil {
+		return err
+	}
+	l.records = []*protos.ChangeRecord{record}
+	l.envelopes = []*protos.Envelope{env}
+	return nil
+}
+
+func (l *changeLog) write(env *protos.Envelope, flag int) error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := proto.Marshal(env)
+	if err != nil {
+		return errors.Wrap(err, "Failed marshaling change record")
+	}
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(len(data)))], data...)
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed opening change log %s", l.path)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return errors.Wrapf(err, "Failed writing change log %s", l.path)
+	}
+	return errors.Wrapf(f.Sync(), "Failed syncing change log %s", l.path)
+}
+
+// since returns at most max records of the log, starting from the given index
+func (l *changeLog) since(from uint64, max int) []*protos.Envelope {
+	var envelopes []*protos.Envelope
+	for i, record := range l.records {
+		if record.Index < from {
+			continue
+		}
+		if max > 0 && len(envelopes) == max {
+			break
+		}
+		envelopes = append(envelopes, l.envelopes[i])
+	}
+	return envelopes
+}
+
+func (l *changeLog) changes() []Change {
+	changes := make([]Change, len(l.records))
+	for i, record := range l.records {
+		changes[i] = Change{
+			Index:     record.Index,
+			Op:        record.Op,
+			Members:   make([]common.PKIidType, len(record.Members)),
+			Files:     append([]string(nil), record.Files...),
+			SeqNum:    record.SeqNum,
+			Timestamp: time.Unix(0, record.Timestamp),
+		}
+		for j, member := range record.Members {
+			changes[i].Members[j] = common.PKIidType(member)
+		}
+	}
+	return changes
+}
+
+// recordChange signs a record of the change with the leader's identity and appends it to the change log.
+// It should be invoked by the leader with the channel's lock held, before the chain state is updated.
+func (gc *gossipChannel) recordChange(op protos.ChangeRecord_Op, members [][]byte, files []string, seqNum uint64) (*protos.Envelope, error) {
+	gc.changeLog.Lock()
+	defer gc.changeLog.Unlock()
+
+	record := &protos.ChangeRecord{
+		Index:     gc.changeLog.next(),
+		PrevHash:  gc.changeLog.lastHash(),
+		Op:        op,
+		Members:   members,
+		Files:     files,
+		SeqNum:    seqNum,
+		Timestamp: time.Now().UnixNano(),
+	}
+	payload, err := proto.Marshal(record)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed marshaling change record")
+	}
+	sig, err := gc.idMapper.Sign(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed signing change record")
+	}
+
+	env := &protos.Envelope{
+		Payload:            payload,
+		Signature:          sig,
+		SignatureAlgorithm: gc.idMapper.SignatureAlgorithm(),
+	}
+	if err := gc.changeLog.append(env, record); err != nil {
+		return nil, errors.WithMessage(err, "Failed recording change")
+	}
+	return env, nil
+}
+
+// applyChange verifies a change record signed by the leader and appends it to the change log.
+// It returns whether records preceding it are missing, in which case they should be requested.
+func (gc *gossipChannel) applyChange(env *protos.Envelope, leader common.PKIidType) (bool, error) {
+	if err := gc.idMapper.Verify(leader, env.SignatureAlgorithm, env.Signature, env.Payload); err != nil {
+		return false, errors.WithMessage(err, "Change record isn't signed by the leader")
+	}
+	record, err := env.ToChangeRecord()
+	if err != nil {
+		return false, err
+	}
+
+	gc.changeLog.Lock()
+	defer gc.changeLog.Unlock()
+
+	next := gc.changeLog.next()
+	last := gc.changeLog.last()
+	switch {
+	case record.Op == protos.ChangeRecord_INITIALIZE && (last == nil || (record.Index != next && record.Timestamp > last.Timestamp)):
+		// The leader started a new log, e.g. after losing its own
+		return false, gc.changeLog.reset(env, record)
+	case last == nil || record.Index > next:
+		return true, nil
+	case record.Index < next:
+		return false, nil
+	}
+	return false, gc.changeLog.append(env, record)
+}
+
+// requestChanges requests the records of the change log following the given index to a peer
+func (gc *gossipChannel) requestChanges(from uint64, peer common.PKIidType) {
+	member := gc.Lookup(peer)
+	if member == nil {
+		gc.logger.Debugf("Channel %s: Can't request change records to %s, it isn't alive", gc.chainMac, peer)
+		return
+	}
+
+	req, err := (&protos.RKSyncMessage{
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Nonce:    0,
+		ChainMac: gc.chainMac,
+		Content: &protos.RKSyncMessage_ChangeLogReq{
+			ChangeLogReq: &protos.ChangeLogRequest{FromIndex: from},
+		},
+	}).NoopSign()
+	if err != nil {
+		gc.logger.Warningf("Failed creating ChangeLogRequest message: %+v", err)
+		return
+	}
+	gc.Send(req, member)
+}
+
+func (gc *gossipChannel) handleChangeLogRequest(msg protos.ReceivedMessage) {
+	if !gc.IsMemberInChan(common.NetworkMember{PKIID: msg.GetConnectionInfo().ID}) {
+		gc.logger.Warningf("Received ChangeLogRequest message from %s, not member in channel %s", msg.GetConnectionInfo().ID, gc.chainMac)
+		return
+	}
+
+	gc.changeLog.Lock()
+	records := gc.changeLog.since(msg.GetRKSyncMessage().GetChangeLogReq().FromIndex, maxChangeLogRecords)
+	gc.changeLog.Unlock()
+
+	msg.Respond(&protos.RKSyncMessage{
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Nonce:    0,
+		ChainMac: gc.chainMac,
+		Content: &protos.RKSyncMessage_ChangeLogRes{
+			ChangeLogRes: &protos.ChangeLogResponse{Records: records},
+		},
+	})
+}
+
+func (gc *gossipChannel) handleChangeLogResponse(m *protos.RKSyncMessage, sender common.PKIidType) {
+	leader, _ := gc.leaderPKIid.Load().(common.PKIidType)
+	if leader == nil || gc.leader {
+		return
+	}
+
+	records := m.GetChangeLogRes().Records
+	for _, env := range records {
+		if _, err := gc.applyChange(env, leader); err != nil {
+			gc.logger.Warningf("Channel %s: Failed applying change record sent from %s: %s", gc.chainMac, sender, err)
+			return
+		}
+	}
+	if len(records) == maxChangeLogRecords {
+		gc.changeLog.Lock()
+		next := gc.changeLog.next()
+		gc.changeLog.Unlock()
+		gc.requestChanges(next, sender)
+	}
+}
+
+func (gc *gossipChannel) ChangeLog() []Change {
+	gc.changeLog.Lock()
+	defer gc.changeLog.Unlock()
+	return gc.changeLog.changes()
+}
+
+func (gc *gossipChannel) VerifyChangeLog() error {
+	gc.RLock()
+	chainState := gc.chainStateMsg
+	gc.RUnlock()
+	if chainState == nil {
+		return errors.New("Channel isn't initialized")
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return err
+	}
+
+	gc.changeLog.Lock()
+	envelopes := gc.changeLog.since(0, 0)
+	gc.changeLog.Unlock()
+	if len(envelopes) == 0 {
+		return errors.New("Change log is empty")
+	}
+
+	members := make(map[string]struct{})
+	files := make(map[string]struct{})
+	var previous *protos.ChangeRecord
+	for i, env := range envelopes {
+		if err := gc.idMapper.Verify(stateInfo.Leader, env.SignatureAlgorithm, env.Signature, env.Payload); err != nil {
+			return errors.WithMessagef(err, "Change record at position %d isn't signed by the leader", i)
+		}
+		record, err := env.ToChangeRecord()
+		if err != nil {
+			return err
+		}
+		if previous == nil && record.Op != protos.ChangeRecord_INITIALIZE {
+			return errors.Errorf("Change log starts with a %s record", record.Op)
+		}
+		if previous != nil && (record.Index != previous.Index+1 || !bytes.Equal(record.PrevHash, util.ComputeSHA3256(envelopes[i-1].Payload))) {
+			return errors.Errorf("Change record %d doesn't follow record %d", record.Index, previous.Index)
+		}
+		previous = record
+
+		switch record.Op {
+		case protos.ChangeRecord_INITIALIZE:
+			members = make(map[string]struct{})
+			files = make(map[string]struct{})
+			fallthrough
+		case protos.ChangeRecord_ADD_MEMBER, protos.ChangeRecord_ADD_FILE:
+			for _, member := range record.Members {
+				members[common.PKIidType(member).String()] = struct{}{}
+			}
+			for _, file := range record.Files {
+				files[file] = struct{}{}
+			}
+		case protos.ChangeRecord_REMOVE_MEMBER, protos.ChangeRecord_REMOVE_FILE:
+			for _, member := range record.Members {
+				delete(members, common.PKIidType(member).String())
+			}
+			for _, file := range record.Files {
+				delete(files, file)
+			}
+		}
+	}
+
+	if len(members) != len(stateInfo.Properties.Members) {
+		return errors.Errorf("Change log leads to %d members, the chain state has %d", len(members), len(stateInfo.Properties.Members))
+	}
+	for _, member := range stateInfo.Properties.Members {
+		if _, exists := members[common.PKIidType(member).String()]; !exists {
+			return errors.Errorf("Member %s of the chain state isn't in the change log", common.PKIidType(member))
+		}
+	}
+	if len(files) != len(stateInfo.Properties.Files) {
+		return errors.Errorf("Change log leads to %d files, the chain state has %d", len(files), len(stateInfo.Properties.Files))
+	}
+	for _, file := range stateInfo.Properties.Files {
+		if _, exists := files[file.Path]; !exists {
+			return errors.Errorf("File %s of the chain state isn't in the change log", file.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func changeOps(changes []Change) []protos.ChangeRecord_Op {
+	ops := make([]protos.ChangeRecord_Op, len(changes))
+	for i, change := range changes {
+		ops[i] = change.Op
+	}
+	return ops
+}
+
+func TestChangeLog(t *testing.T) {
+	dir := t.TempDir()
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	leaderPeer.adapter.logDir = filepath.Join(dir, "leader")
+	peer1.adapter.logDir = filepath.Join(dir, "peer1")
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, nil)
+	require.NoError(t, err)
+	_, err = leader.AddMember(peer2.pkiID)
+	require.NoError(t, err)
+	state, err := leader.RemoveMember(peer2.pkiID)
+	require.NoError(t, err)
+	state = proto.Clone(state).(*protos.ChainState)
+
+	changes := leader.ChangeLog()
+	require.Len(t, changes, 3)
+	assert.Equal(t, []protos.ChangeRecord_Op{protos.ChangeRecord_INITIALIZE, protos.ChangeRecord_ADD_MEMBER, protos.ChangeRecord_REMOVE_MEMBER}, changeOps(changes))
+	assert.Equal(t, []common.PKIidType{peer1.pkiID}, changes[0].Members)
+	assert.Equal(t, []common.PKIidType{peer2.pkiID}, changes[2].Members)
+	assert.Equal(t, uint64(2), changes[2].Index)
+	assert.Equal(t, state.SeqNum, changes[2].SeqNum)
+	assert.NoError(t, leader.VerifyChangeLog())
+
+	// A late joiner requests the records preceding the latest one
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer follower.Stop()
+	require.NoError(t, follower.updateChainState(proto.Clone(state).(*protos.ChainState), leaderPeer.pkiID))
+	assert.Empty(t, follower.ChangeLog())
+	assert.Error(t, follower.VerifyChangeLog())
+
+	var req *protos.SignedRKSyncMessage
+	require.Eventually(t, func() bool {
+		for _, msg := range peer1.adapter.sentMessages() {
+			if msg.IsChangeLogReq() {
+				req = msg
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), req.GetChangeLogReq().FromIndex)
+
+	received := &receivedMessageMock{msg: req, sender: peer1.pkiID}
+	leader.HandleMessage(received)
+	require.Len(t, received.responses, 1)
+	resp, err := received.responses[0].NoopSign()
+	require.NoError(t, err)
+	follower.HandleMessage(&receivedMessageMock{msg: resp, sender: leaderPeer.pkiID})
+	assert.Equal(t, changes, follower.ChangeLog())
+	assert.NoError(t, follower.VerifyChangeLog())
+
+	// Records not signed by the leader are rejected
+	forged := &protos.ChangeRecord{Index: 3, Op: protos.ChangeRecord_ADD_MEMBER, Members: [][]byte{peer2.pkiID}}
+	payload, err := proto.Marshal(forged)
+	require.NoError(t, err)
+	sig, err := peer1.idMapper.Sign(payload)
+	require.NoError(t, err)
+	_, err = follower.applyChange(&protos.Envelope{Payload: payload, Signature: sig}, leaderPeer.pkiID)
+	assert.Error(t, err)
+	assert.Len(t, follower.ChangeLog(), 3)
+
+	// The records are persisted, a partially written one is discarded
+	path := filepath.Join(peer1.adapter.logDir, mac.String()+".log")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x7f, 0x01, 0x02})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reloaded, err := newChangeLog(path, logging.Default())
+	require.NoError(t, err)
+	assert.Equal(t, changes, reloaded.changes())
+	reloaded, err = newChangeLog(path, logging.Default())
+	require.NoError(t, err)
+	assert.Equal(t, changes, reloaded.changes())
+
+	// A leader which lost its log restarts it from the current chain state, the followers follow
+	restarted := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, &adapterMock{fs: leaderPeer.adapter.fs}, leaderPeer.idMapper, logging.Default())
+	defer restarted.Stop()
+	state.Change = nil
+	require.NoError(t, restarted.InitializeWithChainState(state))
+	require.Len(t, restarted.ChangeLog(), 1)
+	assert.Equal(t, []common.PKIidType{peer1.pkiID}, restarted.ChangeLog()[0].Members)
+
+	require.NoError(t, follower.updateChainState(proto.Clone(restarted.Self()).(*protos.ChainState), leaderPeer.pkiID))
+	assert.Equal(t, restarted.ChangeLog(), follower.ChangeLog())
+	assert.NoError(t, follower.VerifyChangeLog())
+}
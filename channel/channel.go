@@ -8,22 +8,79 @@ SPDX-License-Identifier: Apache-2.0
 package channel
 
 import (
+	"os"
+	"sort"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel/fsync"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/util"
 )
 
+// Errors returned by the operations of the channel. They may be wrapped
+// with some context, compare errors.Cause(err) with them.
+var (
+	ErrNotLeader = errors.New("peer is not the leader of the channel")
+	ErrNotMember = errors.New("peer is not a member of the channel")
+)
+
 // Config is a configuration item of the channel
 type Config struct {
 	FileSystem                  config.FileSystem
+	FileSystemFactory           config.FileSystemFactory // FileSystem of specific channels, FileSystem for the channels it returns nil for
 	PublishStateInfoInterval    time.Duration
 	PullPeerNum                 int
-	PullInterval                time.Duration
+	PullInterval                time.Duration // Period the missing data of the files is requested with
 	RequestStateInfoInterval    time.Duration
-	StateInfoCacheSweepInterval time.Duration
+	LeaderTimeout               time.Duration // Time without heartbeat after which the leader is reported unreachable, 0 disables it
+	StateInfoCacheSweepInterval time.Duration // Min time between two sweeps of the acknowledgements of the removed members and files, on the leader
+	MaxMembers                  int
+	MaxFiles                    int
+	MaxStateSize                int
+	MaxMessageSize              int
+	Zone                        string
+	StorageQuota                int64
+	StorageQuotas               map[string]int64              // StorageQuota of specific channels, by channel ID
+	ChangeLogDir                string                        // Directory the change log is persisted to, kept in memory only if empty
+	JournalDir                  string                        // Directory the journal of the chain states applied by a member is persisted to, kept in memory only if empty
+	Propagations                map[string]config.Propagation // Propagation of specific channels, by channel ID
+	Scheduler                   *fsync.Scheduler              // Schedules the transfers of the files of all the channels, nil if they aren't limited
+	Clock                       lib.Clock                     // Clock of the periodical tasks and of the leader timeout, the system clock if nil
+}
+
+// DigestStatus tells whether the local copy of a file matches its published version
+type DigestStatus int
+
+const (
+	// DigestUnknown means no version of the file was published, or the local copy couldn't be read
+	DigestUnknown DigestStatus = iota
+	// DigestMatch means the local copy has the content of the published version
+	DigestMatch
+	// DigestMismatch means the local copy differs from the published version
+	DigestMismatch
+)
+
+func (s DigestStatus) String() string {
+	switch s {
+	case DigestMatch:
+		return "match"
+	case DigestMismatch:
+		return "mismatch"
+	default:
+		return "unknown"
+	}
+}
+
+// FileState describes a file of the channel and the synchronization state of its local copy
+type FileState struct {
+	fsync.Progress
+	Metadata []byte
+	Version  uint64 // Latest version published by the leader, zero if none was
+	Digest   DigestStatus
 }
 
 // Channel defines an object that deals with all channel-related message
@@ -48,21 +105,81 @@ type Channel interface {
 	// RemoveMember removes member contained in the channel
 	RemoveMember(common.PKIidType) (*protos.ChainState, error)
 
-	// AddFile adds files to the channel
+	// AddFile adds files to the channel with a single update of its state,
+	// the state is left as is if the files are all in the channel already
 	AddFile([]*common.FileSyncInfo) (*protos.ChainState, error)
 
-	// RemoveFile removes file contained in the channel
+	// RemoveFile removes files contained in the channel with a single update of its state,
+	// the state is left as is if none of the files is in the channel
 	RemoveFile([]string) (*protos.ChainState, error)
 
+	// PublishFileVersion publishes the current content of the file as a new version
+	PublishFileVersion(string) (*protos.ChainState, error)
+
+	// SetAnchorPeers replaces the endpoints of the channel's anchor peers
+	SetAnchorPeers([]string) (*protos.ChainState, error)
+
+	// RequestState pulls the chain state and the sizes of the files from the leader and some members
+	// of the channel at once, e.g. once the peer joined it, rather than waiting for the gossip rounds
+	RequestState()
+
+	// LeaderHeartbeat returns the time the last heartbeat of the leader was received,
+	// or the channel was joined if none was, the zero time on the leader
+	LeaderHeartbeat() time.Time
+
+	// Transferring returns whether a file of the channel is being transferred
+	Transferring() bool
+
+	// FileProgress returns the synchronization state of the files of the channel
+	FileProgress() ([]fsync.Progress, error)
+
+	// FileStates returns the files of the channel along with their synchronization state
+	FileStates() ([]FileState, error)
+
+	// FileVersions returns the versions of the file observed by the peer, oldest first
+	FileVersions(filename string) ([]FileVersion, error)
+
+	// Recovery returns how the local files of a member related to the chain state the channel was
+	// initialized with, according to the chain states journaled before the peer was restarted
+	Recovery() RecoveryState
+
+	// FileAcks returns the acknowledgements of the current files sent by the current members,
+	// only the leader receives them
+	FileAcks() []FileAck
+
+	// PinFileVersion keeps a version of the file in its history until it is unpinned
+	PinFileVersion(filename string, version uint64) error
+
+	// UnpinFileVersion lets a pinned version of the file be discarded from its history
+	UnpinFileVersion(filename string, version uint64) error
+
+	// ChangeLog returns the records of the channel's change log, oldest first
+	ChangeLog() []Change
+
+	// VerifyChangeLog verifies the signatures and the chaining of the change log records,
+	// and that replaying them leads to the members and files of the current chain state
+	VerifyChangeLog() error
+
+	// Propagation returns the fan-out of the channel's chain states
+	Propagation() config.Propagation
+
+	// SetPropagation overrides the fan-out of the channel's chain states, until the channel is closed
+	SetPropagation(propagation config.Propagation)
+
 	// Stop the channel's activity
 	Stop()
+
+	// Close stops the channel's activity once the peer left the channel, orphaning the local copies of its files
+	Close()
 }
 
 // Adapter enables the gossipChannel to communicate with gossipService
 type Adapter interface {
 	GetChannelConfig() Config
-	Gossip(message *protos.SignedRKSyncMessage)
-	Forward(message protos.ReceivedMessage)
+	// Gossip and Forward push the message to remote peers, the propagation
+	// settings override the gossip ones when they aren't zero
+	Gossip(message *protos.SignedRKSyncMessage, propagation config.Propagation)
+	Forward(message protos.ReceivedMessage, propagation config.Propagation)
 	Send(message *protos.SignedRKSyncMessage, peers ...*common.NetworkMember)
 	SendWithAck(message *protos.SignedRKSyncMessage, timeout time.Duration, minAck int, peers ...*common.NetworkMember) error
 	GetMembership() []common.NetworkMember
@@ -71,6 +188,12 @@ type Adapter interface {
 	Unregister([]byte)
 	Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage)
 	CreateLeaveChainMessage(chainMac common.ChainMac) (*protos.SignedRKSyncMessage, error)
+	ConnectToAnchorPeer(endpoint string)
+	EmitEvent(event common.Event)
+	// OrphanFiles hands the local copies of files no longer synchronized to the orphan collector
+	OrphanFiles(chainID string, files []config.FileMeta)
+	// ReportMisbehavior lowers the reputation of the peer
+	ReportMisbehavior(pkiID common.PKIidType, kind common.Misbehavior)
 }
 
 // GenerateMAC returns a byte slice that is derived from the peer's PKI-ID
@@ -88,3 +211,33 @@ func contains(files []*protos.File, target string) bool {
 	}
 	return false
 }
+
+// toFileAttributes converts the extended attributes of a file, sorted by key for the chain state to be deterministic
+func toFileAttributes(attrs map[string]string) []*protos.FileAttribute {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fileAttrs := make([]*protos.FileAttribute, len(keys))
+	for i, key := range keys {
+		fileAttrs[i] = &protos.FileAttribute{Key: key, Value: attrs[key]}
+	}
+	return fileAttrs
+}
+
+// configFileAttributes returns the attributes of a file applied on the members' copies, but the modification time
+func configFileAttributes(file *protos.File) config.FileAttributes {
+	attrs := config.FileAttributes{Perm: os.FileMode(file.Perm).Perm()}
+	if len(file.Attributes) > 0 {
+		attrs.Attributes = make(map[string]string, len(file.Attributes))
+		for _, attr := range file.Attributes {
+			attrs.Attributes[attr.Key] = attr.Value
+		}
+	}
+	return attrs
+}
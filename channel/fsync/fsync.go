@@ -8,6 +8,8 @@ package fsync
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"hash"
 	"io"
 	"os"
 	"sync"
@@ -18,6 +20,7 @@ import (
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/filter"
+	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/util"
@@ -25,8 +28,26 @@ import (
 
 const (
 	dataBlockSize = 512 * 1024
+	// tailHashSize is the number of trailing bytes of the follower's copy compared with the
+	// leader's one, to detect that the leader's file was truncated or rewritten
+	tailHashSize = 4 * 1024
+	// copyBufferSize is the size of the buffers the files are hashed and copied with
+	copyBufferSize = 32 * 1024
 )
 
+var (
+	// chunkBuffers holds the buffers the data sent to the peers is read into
+	chunkBuffers = lib.NewBufferPool(dataBlockSize)
+	// copyBuffers holds the buffers the files are hashed and copied with
+	copyBuffers = lib.NewBufferPool(copyBufferSize)
+)
+
+// BufferStats returns the number of buffers taken from the pools of the providers,
+// the one of the data read from the files and the one of the files hashed and copied
+func BufferStats() (chunks lib.PoolStats, copies lib.PoolStats) {
+	return chunkBuffers.Stats(), copyBuffers.Stats()
+}
+
 // Adapter enables the fsync to communicate with rksync channel
 type Adapter interface {
 	GetFileSystem() config.FileSystem
@@ -35,25 +56,43 @@ type Adapter interface {
 	Sign(*protos.RKSyncMessage) (*protos.SignedRKSyncMessage, error)
 	GetMembership() []common.NetworkMember
 	IsMemberInChan(common.NetworkMember) bool
+	Zone() string
+	MaxMessageSize() int
 	Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage)
+	EmitEvent(event common.Event)
+	// CheckStorage returns a *StorageError if a copy of the given file of the given size can't be stored
+	CheckStorage(filename string, size int64) error
+	SendToLeader(*protos.SignedRKSyncMessage)
+	// FileVersion returns the latest version of the file published by the leader the peer knows of
+	FileVersion(filename string) uint64
+	// ReportMisbehavior lowers the reputation of the peer
+	ReportMisbehavior(common.PKIidType, common.Misbehavior)
+	// TransferScheduler returns the scheduler of the transfers of the peer, nil if they aren't limited
+	TransferScheduler() *Scheduler
+	// PullInterval returns the period the missing data of the file is requested with, it may change at runtime
+	PullInterval() time.Duration
 }
 
 // NewFileSyncProvider creates FileSyncProvier instance
-func NewFileSyncProvider(chainMac common.ChainMac, chainID string, filename string, metadata []byte, mode protos.File_Mode, leader bool,
-	pkiID common.PKIidType, adapter Adapter) (*FileSyncProvier, error) {
+func NewFileSyncProvider(chainMac common.ChainMac, chainID string, filename string, metadata []byte, mode protos.File_Mode, attrs config.FileAttributes, priority int32, leader bool,
+	pkiID common.PKIidType, adapter Adapter, logger logging.Logger) (*FileSyncProvier, error) {
 
 	mac := GenerateMAC(chainMac, filename)
 	p := &FileSyncProvier{
-		Adapter:  adapter,
-		chainMac: chainMac,
-		chainID:  chainID,
-		filename: filename,
-		metadata: metadata,
-		mode:     mode,
-		leader:   leader,
-		state:    int32(0),
-		stopCh:   make(chan struct{}, 1),
-		pkiID:    pkiID,
+		Adapter:   adapter,
+		chainMac:  chainMac,
+		chainID:   chainID,
+		filename:  filename,
+		metadata:  metadata,
+		mode:      mode,
+		attrs:     attrs,
+		leader:    leader,
+		state:     int32(0),
+		stopCh:    make(chan struct{}, 1),
+		controlCh: make(chan *protos.Payload, 1),
+		syncCh:    make(chan struct{}, 1),
+		pkiID:     pkiID,
+		logger:    logger,
 	}
 
 	p.reqChan, _ = adapter.Accept(func(message interface{}) bool {
@@ -80,30 +119,63 @@ func NewFileSyncProvider(chainMac common.ChainMac, chainID string, filename stri
 		return nil, err
 	}
 	p.payloads = NewPayloadBuffer(start)
+	if sched := adapter.TransferScheduler(); sched != nil {
+		p.transfer = sched.NewTransfer(chainMac.String(), priority)
+		p.granted = p.transfer.Granted()
+	}
 
 	p.done.Add(2)
 	go p.listen()
-	go p.periodicalInvocation(4 * time.Second)
+	go p.periodicalInvocation()
 
 	return p, nil
 }
 
+// Progress describes the synchronization state of a file
+type Progress struct {
+	Filename     string
+	Mode         protos.File_Mode
+	Size         int64 // Size of the local copy of the file
+	ExpectedSize int64 // Size of the leader's copy as announced by the data it sent, the local size on the leader
+	Transferring bool
+	Queued       bool      // Whether the transfer of the file waits for a slot of the scheduler
+	LastSynced   time.Time // Time the local copy was last updated with received data, zero if it never was
+}
+
 // FileSyncProvier is the file synchronization handler
 type FileSyncProvier struct {
 	Adapter
-	chainMac common.ChainMac
-	chainID  string
-	filename string
-	metadata []byte
-	state    int32
-	mode     protos.File_Mode
-	pkiID    common.PKIidType
-	leader   bool
-	payloads PayloadBuffer
-	msgChan  <-chan *protos.RKSyncMessage
-	reqChan  <-chan *protos.RKSyncMessage
-	done     sync.WaitGroup
-	stopCh   chan struct{}
+	chainMac  common.ChainMac
+	chainID   string
+	filename  string
+	metadata  []byte
+	state     int32
+	inFlight  int32
+	mode      protos.File_Mode
+	attrs     config.FileAttributes
+	pkiID     common.PKIidType
+	leader    bool
+	payloads  PayloadBuffer
+	msgChan   <-chan *protos.RKSyncMessage
+	reqChan   <-chan *protos.RKSyncMessage
+	done      sync.WaitGroup
+	stopCh    chan struct{}
+	controlCh chan *protos.Payload
+	syncCh    chan struct{} // signals that the file should be requested without waiting for the next period
+	transfer  *Transfer     // nil if the transfers aren't scheduled
+	granted   <-chan struct{}
+	logger    logging.Logger
+
+	remoteSize    atomic.Int64
+	lastSynced    atomic.Int64 // UnixNano
+	lastReceived  atomic.Int64 // UnixNano, the time data of the file was last received
+	rejectedUntil atomic.Int64 // UnixNano, the file isn't requested until then once the transfer was rejected
+	source        atomic.Value // common.PKIidType of the peer the data was last requested from
+	working       *workingCopy // only accessed by the goroutine processing the payloads
+
+	digestLock sync.Mutex
+	digest     hash.Hash
+	digestSize int64
 }
 
 func (p *FileSyncProvier) initPayloadBufferStart() (int64, error) {
@@ -114,10 +186,10 @@ func (p *FileSyncProvier) initPayloadBufferStart() (int64, error) {
 	}
 
 	if !p.leader && os.IsNotExist(err) {
-		logging.Debugf("Channel %s file %s does not exists, create it", p.chainMac, p.filename)
+		p.logger.Debugf("Channel %s file %s does not exists, create it", p.chainMac, p.filename)
 		f, err := fs.Create(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader})
 		if err != nil {
-			logging.Errorf("Failed creating file %s (Channel %s): %s", p.filename, p.chainMac, err)
+			p.logger.Errorf("Failed creating file %s (Channel %s): %s", p.filename, p.chainMac, err)
 			return 0, err
 		}
 		f.Close()
@@ -129,11 +201,56 @@ func (p *FileSyncProvier) initPayloadBufferStart() (int64, error) {
 
 // Stop stops the FileSyncProvider
 func (p *FileSyncProvier) Stop() {
-	logging.Info("Stopping fsync provider")
-	defer logging.Info("Stopped fsync provider")
+	p.logger.Info("Stopping fsync provider")
+	defer p.logger.Info("Stopped fsync provider")
 
 	p.stopCh <- struct{}{}
 	p.done.Wait()
+	if p.working != nil {
+		p.working.f.Close()
+		p.removeWorkingCopy()
+	}
+	if p.transfer != nil {
+		p.TransferScheduler().Release(p.transfer)
+	}
+}
+
+// Transferring returns whether file data is being sent to a peer or written to the local file,
+// or whether the local copy is shorter than the leader's one while its data keeps being received
+func (p *FileSyncProvier) Transferring() bool {
+	if atomic.LoadInt32(&p.inFlight) > 0 {
+		return true
+	}
+	if p.leader || p.remoteSize.Load() <= p.payloads.Next() {
+		return false
+	}
+	return time.Since(time.Unix(0, p.lastReceived.Load())) < transferIdleTimeout
+}
+
+// Progress returns the synchronization state of the file
+func (p *FileSyncProvier) Progress() (Progress, error) {
+	fi, err := p.GetFileSystem().Stat(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader})
+	if err != nil {
+		return Progress{}, errors.Errorf("Failed stating file %s (Channel %s): %s", p.filename, p.chainMac, err)
+	}
+
+	progress := Progress{
+		Filename:     p.filename,
+		Mode:         p.mode,
+		Size:         fi.Size(),
+		ExpectedSize: p.remoteSize.Load(),
+		Transferring: p.Transferring(),
+	}
+	if p.leader || progress.ExpectedSize < progress.Size {
+		progress.ExpectedSize = progress.Size
+	}
+	if p.transfer != nil {
+		progress.Queued = p.TransferScheduler().Waiting(p.transfer)
+	}
+	if lastSynced := p.lastSynced.Load(); lastSynced > 0 {
+		progress.LastSynced = time.Unix(0, lastSynced)
+	}
+	return progress, nil
 }
 
 func (p *FileSyncProvier) listen() {
@@ -143,7 +260,7 @@ func (p *FileSyncProvier) listen() {
 		select {
 		case s := <-p.stopCh:
 			p.stopCh <- s
-			logging.Debug("Stop listening for new messages")
+			p.logger.Debug("Stop listening for new messages")
 			return
 		case msg := <-p.msgChan:
 			p.queueDataMsg(msg)
@@ -151,7 +268,7 @@ func (p *FileSyncProvier) listen() {
 	}
 }
 
-func (p *FileSyncProvier) periodicalInvocation(d time.Duration) {
+func (p *FileSyncProvier) periodicalInvocation() {
 	defer p.done.Done()
 
 	for {
@@ -159,10 +276,22 @@ func (p *FileSyncProvier) periodicalInvocation(d time.Duration) {
 		case s := <-p.stopCh:
 			p.stopCh <- s
 			return
-		case <-time.After(d):
+		case <-time.After(p.PullInterval()):
+			p.requestDataAppend()
+		case <-p.syncCh:
+			p.requestDataAppend()
+		case <-p.granted:
 			p.requestDataAppend()
 		case <-p.payloads.Ready():
 			p.processPayloads()
+			p.scheduleTransfer()
+		case payload := <-p.controlCh:
+			if payload.GetAppend().Truncated {
+				p.resync(payload)
+			} else {
+				p.verify(payload)
+			}
+			p.scheduleTransfer()
 		}
 	}
 }
@@ -190,34 +319,54 @@ func (p *FileSyncProvier) processPayloads() {
 		return
 	}
 	defer func() { atomic.StoreInt32(&p.state, int32(0)) }()
+	atomic.AddInt32(&p.inFlight, 1)
+	defer atomic.AddInt32(&p.inFlight, -1)
 
-	logging.Debugf("[%s] Ready to process payloads, next payload start number is = [%d]", p.filename, p.payloads.Next())
-	fs := p.GetFileSystem()
-	f, err := fs.OpenFile(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader}, os.O_WRONLY|os.O_APPEND, os.ModePerm)
-	if err != nil {
-		logging.Errorf("Failed opening file %s (Channel %): %s", p.filename, p.chainMac, err)
-		return
-	}
-	defer f.Close()
-
+	p.logger.Debugf("[%s] Ready to process payloads, next payload start number is = [%d]", p.filename, p.payloads.Next())
 	for payload := p.payloads.Peek(); payload != nil; payload = p.payloads.Peek() {
 		if payload.IsAppend() {
-			n, err := f.Write(payload.Data)
-			if err != nil {
-				logging.Errorf("Failed appending data to file %s: %s", p.filename, err)
-				if n > 0 {
-					p.payloads.Reset(int64(n))
+			// Whether the whole file can be stored is checked before the transfer starts
+			if size := payload.GetAppend().FileSize; p.working == nil && size > 0 {
+				if err := p.CheckStorage(p.filename, size); err != nil {
+					if storageErr, ok := err.(*StorageError); ok {
+						p.reject(storageErr)
+						return
+					}
+					p.logger.Warningf("Failed checking storage of file %s: %s", p.filename, err)
 				}
+			}
+			if err := p.openWorkingCopy(); err != nil {
+				p.logger.Errorf("Failed opening working copy of file %s (Channel %s): %s", p.filename, p.chainMac, err)
+				p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: err})
+				return
+			}
+			n, err := p.working.Write(payload.Data)
+			if err != nil {
+				p.logger.Errorf("Failed appending data to file %s: %s", p.filename, err)
+				p.discardWorkingCopy()
+				p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: err})
 				return
 			}
 			p.payloads.Expire(int64(n))
+			if p.transfer != nil {
+				p.TransferScheduler().Touch(p.transfer)
+			}
+
+			// The last payload sent for a request carries the digest of the leader's file
+			if digest := payload.GetAppend().Digest; len(digest) > 0 {
+				if err := p.commitWorkingCopy(digest, payload.GetAppend().ModTime); err != nil {
+					p.logger.Errorf("Failed synchronizing file %s (Channel %s): %s", p.filename, p.chainMac, err)
+					p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: err})
+					return
+				}
+			}
 		}
 	}
 }
 
 func (p *FileSyncProvier) queueDataMsg(msg *protos.RKSyncMessage) {
 	if !bytes.Equal(msg.ChainMac, p.chainMac) {
-		logging.Warningf("Received message for channel %s while expecting channel %s, ignoring", common.ChainMac(msg.ChainMac), p.chainMac)
+		p.logger.Warningf("Received message for channel %s while expecting channel %s, ignoring", common.ChainMac(msg.ChainMac), p.chainMac)
 		return
 	}
 
@@ -225,13 +374,116 @@ func (p *FileSyncProvier) queueDataMsg(msg *protos.RKSyncMessage) {
 	if dataMsg != nil {
 		payload := dataMsg.Payload
 		if payload == nil {
-			logging.Error("Given payload is nil")
+			p.logger.Error("Given payload is nil")
+			return
+		}
+		p.lastReceived.Store(time.Now().UnixNano())
+		// The size of a truncated file is recorded once the local data is discarded
+		if payload.IsAppend() && !payload.GetAppend().Truncated {
+			p.updateRemoteSize(payload.GetAppend().FileSize)
+		}
+		if payload.IsAppend() && (payload.GetAppend().Truncated || len(payload.Data) == 0) {
+			select {
+			case p.controlCh <- payload:
+			default:
+				p.logger.Debugf("[%s] Control payload already pending, ignoring", p.filename)
+			}
 			return
 		}
+		if payload.IsAppend() {
+			p.updateRemoteSize(payload.GetAppend().Start + payload.GetAppend().Length)
+		}
 
 		p.payloads.Push(payload)
 	} else {
-		logging.Warning("RKSync message received is not of data message type, usually this should not happen.")
+		p.logger.Warning("RKSync message received is not of data message type, usually this should not happen.")
+	}
+}
+
+// resync discards the local data of the file, whose content diverged from the leader's one,
+// and restarts the synchronization from the given payload, the first one of the leader's file
+func (p *FileSyncProvier) resync(payload *protos.Payload) {
+	if payload.GetAppend().Start != 0 {
+		p.logger.Warningf("[%s] Resynchronization payload starts at %d, ignoring", p.filename, payload.GetAppend().Start)
+		return
+	}
+	swapped := atomic.CompareAndSwapInt32(&p.state, int32(0), int32(2))
+	if !swapped {
+		// The divergence is detected again by the next request
+		p.logger.Debugf("[%s] File is being transferred, postponing resynchronization", p.filename)
+		return
+	}
+	defer func() { atomic.StoreInt32(&p.state, int32(0)) }()
+
+	p.logger.Warningf("Channel %s file %s was truncated or rewritten by the leader, synchronizing it again", p.chainMac, p.filename)
+	if p.working != nil {
+		p.working.f.Close()
+		p.working = nil
+		p.removeWorkingCopy()
+	}
+	p.payloads.Reset(-p.payloads.Next())
+	p.remoteSize.Store(0)
+	p.updateRemoteSize(payload.GetAppend().Length)
+	p.updateRemoteSize(payload.GetAppend().FileSize)
+	if len(payload.Data) > 0 {
+		p.payloads.Push(payload)
+		return
+	}
+
+	// The leader's file is empty
+	err := p.openWorkingCopy()
+	if err == nil {
+		err = p.commitWorkingCopy(payload.GetAppend().Digest, payload.GetAppend().ModTime)
+	}
+	if err != nil {
+		p.logger.Errorf("Failed truncating file %s (Channel %s): %s", p.filename, p.chainMac, err)
+		p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: err})
+	}
+}
+
+// verify replaces the file by its working copy, once the leader sent the digest of
+// its file without any data, the working copy being as long as the leader's file
+func (p *FileSyncProvier) verify(payload *protos.Payload) {
+	if p.working == nil || payload.GetAppend().Start != p.payloads.Next() || len(payload.GetAppend().Digest) == 0 {
+		return
+	}
+	swapped := atomic.CompareAndSwapInt32(&p.state, int32(0), int32(2))
+	if !swapped {
+		return
+	}
+	defer func() { atomic.StoreInt32(&p.state, int32(0)) }()
+
+	if err := p.commitWorkingCopy(payload.GetAppend().Digest, payload.GetAppend().ModTime); err != nil {
+		p.logger.Errorf("Failed synchronizing file %s (Channel %s): %s", p.filename, p.chainMac, err)
+		p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: err})
+	}
+}
+
+// updateRemoteSize records that the leader's copy of the file is at least size bytes long
+func (p *FileSyncProvier) updateRemoteSize(size int64) {
+	for {
+		current := p.remoteSize.Load()
+		if size <= current || p.remoteSize.CompareAndSwap(current, size) {
+			return
+		}
+	}
+}
+
+// Announce records the size of a peer's copy of the file. The missing data is requested
+// at once if the local copy is shorter, rather than at the next periodical request.
+func (p *FileSyncProvier) Announce(size int64) {
+	if p.leader {
+		return
+	}
+	p.updateRemoteSize(size)
+
+	fi, err := p.GetFileSystem().Stat(p.chainID, p.FileMeta())
+	if err != nil || fi.Size() >= size {
+		return
+	}
+	select {
+	case p.syncCh <- struct{}{}:
+	default:
 	}
 }
 
@@ -239,7 +491,19 @@ func (p *FileSyncProvier) handleDataReq(msg *protos.RKSyncMessage, wg *sync.Wait
 	defer wg.Done()
 
 	if !bytes.Equal(p.chainMac, msg.ChainMac) {
-		logging.Warningf("Received message for channel %s while expecting channel %s, ignoring", common.ChainMac(msg.ChainMac), p.chainMac)
+		p.logger.Warningf("Received message for channel %s while expecting channel %s, ignoring", common.ChainMac(msg.ChainMac), p.chainMac)
+		return
+	}
+
+	req := msg.GetDataReq()
+	if !bytes.Equal([]byte(req.FileName), []byte(p.filename)) {
+		p.logger.Warningf("Received message for file %s while expecting file %s, ignoring", req.FileName, p.filename)
+		return
+	}
+	if req.IsRejection() {
+		if p.leader {
+			p.handleRejection(req)
+		}
 		return
 	}
 
@@ -252,82 +516,154 @@ func (p *FileSyncProvier) handleDataReq(msg *protos.RKSyncMessage, wg *sync.Wait
 		defer func() { atomic.StoreInt32(&p.state, int32(0)) }()
 	}
 
-	req := msg.GetDataReq()
-	if !bytes.Equal([]byte(req.FileName), []byte(p.filename)) {
-		logging.Warningf("Received message for file %s while expecting file %s, ignoring", req.FileName, p.filename)
-		return
-	}
-
 	peer := p.Lookup(req.PkiId)
 	if peer == nil {
-		logging.Warningf("Can't find peer's information: %s", req.PkiId)
+		p.logger.Warningf("Can't find peer's information: %s", req.PkiId)
 		return
 	}
 
+	atomic.AddInt32(&p.inFlight, 1)
+	defer atomic.AddInt32(&p.inFlight, -1)
+
 	if req.IsAppend() {
 		if p.mode != protos.File_Append {
-			logging.Warningf("File %s's mode isn't Append", p.filename)
+			p.logger.Warningf("File %s's mode isn't Append", p.filename)
 			return
 		}
 
 		appendReq := req.GetAppend()
 		fi, err := p.GetFileSystem().Stat(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader})
 		if err != nil {
-			logging.Warningf("Failed to stat file %s: %s", p.filename, err)
+			p.logger.Warningf("Failed to stat file %s: %s", p.filename, err)
 			return
 		}
 
-		if appendReq.Length >= fi.Size() {
-			logging.Debugf("The sender's file is newer")
+		diverged, err := p.diverged(appendReq, fi.Size())
+		if err != nil {
+			p.logger.Warningf("Failed comparing file %s with the copy of %s: %s", p.filename, peer.Endpoint, err)
+			return
+		}
+		// Only the leader's copy is authoritative, other members just don't serve a diverged copy
+		truncated := diverged && p.leader
+		if diverged && !p.leader {
+			p.logger.Debugf("The sender's copy of file %s diverged from ours", p.filename)
+			return
+		}
+		// A requester with a pending working copy needs the digest even if it is up to date
+		if !truncated && appendReq.Length >= fi.Size() && !appendReq.Pending {
+			p.logger.Debugf("The sender's file is newer")
 			return
 		}
 
-		data := make([]byte, dataBlockSize)
+		buf := chunkBuffers.Get()
+		defer chunkBuffers.Put(buf)
+		data := (*buf)[:p.blockSize()]
 		start := appendReq.Length
-		var n int
+		var sent int64
+		if truncated {
+			p.logger.Infof("Peer %s's copy of file %s diverged, sending the whole file", peer.Endpoint, p.filename)
+			p.resetDigest()
+			start = 0
+		}
 
 		fs := p.GetFileSystem()
-		f, err := fs.OpenFile(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader}, os.O_RDONLY, os.ModePerm)
+		f, err := fs.OpenFile(p.chainID, p.FileMeta(), os.O_RDONLY, os.ModePerm)
 		if err != nil {
-			logging.Errorf("Failed opening file %s (Channel %s): %s", p.filename, p.chainMac, err)
+			p.logger.Errorf("Failed opening file %s (Channel %s): %s", p.filename, p.chainMac, err)
 			return
 		}
 		defer f.Close()
 
 		for {
-			n, err = f.ReadAt(data, start)
-			if err == io.EOF {
-				if n > 0 {
-					sMsg, err := p.createAppendDataMsg(data, n, start)
-					if err != nil {
-						logging.Warningf("Failed creating DataMessage: %v", err)
-						return
-					}
-					p.SendToPeer(sMsg, peer)
-				}
+			n, err := f.ReadAt(data, start)
+			if err != nil && err != io.EOF {
+				p.logger.Warningf("Read file %s failed: %s", p.filename, err)
 				return
 			}
-			if err != nil {
-				logging.Warningf("Read file %s failed: %s", p.filename, err)
-				return
+
+			// The last payload carries the digest of the file, for the requester to verify its copy
+			var digest []byte
+			end := start + int64(n)
+			if err == io.EOF || end >= fi.Size() {
+				if digest, err = p.digestAt(end); err != nil {
+					p.logger.Warningf("Failed computing digest of file %s: %s", p.filename, err)
+					return
+				}
 			}
 
-			sMsg, err := p.createAppendDataMsg(data, n, start)
+			sMsg, err := p.createAppendDataMsg(data, n, start, truncated && start == 0, digest, fi)
 			if err != nil {
-				logging.Warningf("Failed creating DataMessage: %v", err)
+				p.logger.Warningf("Failed creating DataMessage: %v", err)
 				return
 			}
-
 			p.SendToPeer(sMsg, peer)
-			start = start + int64(n)
+
+			if digest != nil {
+				return
+			}
+			// The requester asks for the rest of the file once it's granted a transfer slot
+			if sent += int64(n); appendReq.Limit > 0 && sent >= appendReq.Limit {
+				return
+			}
+			start = end
 		}
 	}
 }
 
-func (p *FileSyncProvier) createAppendDataMsg(data []byte, n int, start int64) (*protos.SignedRKSyncMessage, error) {
+// blockSize returns the size of the file data sent in a message, so that it fits in the max message size
+func (p *FileSyncProvier) blockSize() int {
+	if max := p.MaxMessageSize() - protos.MessageOverhead; max > 0 && max < dataBlockSize {
+		return max
+	}
+	return dataBlockSize
+}
+
+// diverged returns whether the requester's copy of the file isn't a prefix of the local one,
+// i.e. it is longer or its trailing bytes differ
+func (p *FileSyncProvier) diverged(req *protos.AppendRequest, size int64) (bool, error) {
+	if req.Length > size {
+		return true, nil
+	}
+	if len(req.TailHash) == 0 || req.Length == 0 {
+		return false, nil
+	}
+
+	digest, err := p.tailHash(p.FileMeta(), req.Length)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(digest, req.TailHash), nil
+}
+
+// tailHash returns the hash of the tailHashSize bytes of the given file preceding the given offset
+func (p *FileSyncProvier) tailHash(fmeta config.FileMeta, offset int64) ([]byte, error) {
+	start := offset - tailHashSize
+	if start < 0 {
+		start = 0
+	}
+
+	f, err := p.GetFileSystem().OpenFile(p.chainID, fmeta, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := copyBuffers.Copy(h, io.NewSectionReader(f, start, offset-start)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func (p *FileSyncProvier) createAppendDataMsg(data []byte, n int, start int64, truncated bool, digest []byte, fi os.FileInfo) (*protos.SignedRKSyncMessage, error) {
 	if n < len(data) {
 		data = data[:n]
 	}
+	// The modification time is applied on the requester's copy along with the digest
+	var mtime int64
+	if digest != nil {
+		mtime = fi.ModTime().UnixNano()
+	}
 
 	msg := &protos.RKSyncMessage{
 		Nonce:    uint64(0),
@@ -340,8 +676,12 @@ func (p *FileSyncProvier) createAppendDataMsg(data []byte, n int, start int64) (
 					Data: data,
 					Metadata: &protos.Payload_Append{
 						Append: &protos.AppendMetadata{
-							Start:  start,
-							Length: int64(n),
+							Start:     start,
+							Length:    int64(n),
+							Truncated: truncated,
+							Digest:    digest,
+							ModTime:   mtime,
+							FileSize:  fi.Size(),
 						},
 					},
 				},
@@ -349,39 +689,88 @@ func (p *FileSyncProvier) createAppendDataMsg(data []byte, n int, start int64) (
 		},
 	}
 
-	return p.Sign(msg)
+	sMsg, err := p.Sign(msg)
+	// The data is carried by the envelope from now on, the buffer it was read into is reused
+	msg.GetDataMsg().Payload.Data = nil
+	return sMsg, err
 }
 
 func (p *FileSyncProvier) requestDataAppend() {
+	if time.Now().UnixNano() < p.rejectedUntil.Load() {
+		p.logger.Debugf("[%s] Transfer was rejected, not requesting the file", p.filename)
+		return
+	}
 	swapped := atomic.CompareAndSwapInt32(&p.state, int32(0), int32(2))
 	if !swapped {
 		return
 	}
 	defer func() { atomic.StoreInt32(&p.state, int32(0)) }()
 
-	req, err := p.createDataAppendMsgRequest()
+	var limit int64
+	if p.transfer != nil && !p.TransferScheduler().Active(p.transfer) {
+		if p.missingData() {
+			// The missing data is requested once the transfer is granted a slot
+			p.TransferScheduler().Request(p.transfer)
+			return
+		}
+		// Only the first block of the changes is requested, to learn whether there are any
+		limit = int64(p.blockSize())
+	}
+
+	req, err := p.createDataAppendMsgRequest(limit)
 	if err != nil {
-		logging.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
+		p.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
 		return
 	}
 
-	endpoints := filter.SelectPeers(1, p.GetMembership(), p.IsMemberInChan)
+	endpoints := filter.SelectPeersInZoneByLatency(1, p.GetMembership(), p.IsMemberInChan, p.Zone())
 	if len(endpoints) == 0 {
-		logging.Warningf("Can't find any member in Chain: %s", p.chainMac)
+		p.logger.Warningf("Can't find any member in Chain: %s", p.chainMac)
 		return
 	}
 
+	p.source.Store(endpoints[0].PKIID)
 	p.SendToPeer(req, endpoints[0])
 }
 
-func (p *FileSyncProvier) createDataAppendMsgRequest() (*protos.SignedRKSyncMessage, error) {
+// missingData returns whether the local copy of the file is known to be shorter than the leader's one
+func (p *FileSyncProvier) missingData() bool {
+	return p.working != nil || p.remoteSize.Load() > p.payloads.Next()
+}
+
+// scheduleTransfer requests a transfer slot for the missing data of the file, or frees the slot once there's none
+func (p *FileSyncProvier) scheduleTransfer() {
+	if p.transfer == nil || time.Now().UnixNano() < p.rejectedUntil.Load() {
+		return
+	}
+	if p.missingData() {
+		p.TransferScheduler().Request(p.transfer)
+	} else {
+		p.TransferScheduler().Release(p.transfer)
+	}
+}
+
+func (p *FileSyncProvier) createDataAppendMsgRequest(limit int64) (*protos.SignedRKSyncMessage, error) {
 	fi, err := p.GetFileSystem().Stat(p.chainID, config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader})
 	if err != nil {
-		logging.Warningf("Failed to stat file %s: %s", p.filename, err)
+		p.logger.Warningf("Failed to stat file %s: %s", p.filename, err)
 		return nil, err
 	}
 
 	if p.mode == protos.File_Append {
+		// Data is appended to the working copy until it replaces the file
+		fmeta, size := p.FileMeta(), fi.Size()
+		if p.working != nil {
+			fmeta, size = p.tempMeta(), p.payloads.Next()
+		} else if next := p.payloads.Next(); next != size {
+			p.logger.Warningf("[%s] Local file size %d differs from the synchronized offset %d, resuming from the local size", p.filename, size, next)
+			p.payloads.Reset(size - next)
+		}
+		tailHash, err := p.tailHash(fmeta, size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed hashing file %s", p.filename)
+		}
+
 		msg := &protos.RKSyncMessage{
 			Nonce:    uint64(0),
 			ChainMac: p.chainMac,
@@ -392,7 +781,10 @@ func (p *FileSyncProvier) createDataAppendMsgRequest() (*protos.SignedRKSyncMess
 					PkiId:    p.pkiID,
 					Req: &protos.DataRequest_Append{
 						Append: &protos.AppendRequest{
-							Length: fi.Size(),
+							Length:   size,
+							TailHash: tailHash,
+							Pending:  p.working != nil,
+							Limit:    limit,
 						},
 					},
 				},
@@ -3,6 +3,7 @@ package fsync_test
 import (
 	"math/rand"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,9 +11,11 @@ import (
 	"github.com/rkcloudchain/rksync/channel/fsync"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -80,8 +83,13 @@ func (fs *dummyFileSystem) Stat(chainID string, fmeta config.FileMeta) (os.FileI
 	return &dummyFileInfo{}, nil
 }
 
+func (fs *dummyFileSystem) Rename(chainID string, from, to config.FileMeta) error {
+	return nil
+}
+
 type dummyRPCModule struct {
-	fs *dummyFileSystem
+	fs           *dummyFileSystem
+	pullInterval atomic.Int64
 	mock.Mock
 }
 
@@ -121,11 +129,44 @@ func (m *dummyRPCModule) IsMemberInChan(member common.NetworkMember) bool {
 	return true
 }
 
+func (m *dummyRPCModule) Zone() string {
+	return ""
+}
+
 func (m *dummyRPCModule) Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage) {
 	args := m.Called(acceptor, mac, passThrough)
 	return args.Get(0).(<-chan *protos.RKSyncMessage), args.Get(1).(<-chan protos.ReceivedMessage)
 }
 
+func (m *dummyRPCModule) MaxMessageSize() int {
+	return 0
+}
+
+func (m *dummyRPCModule) EmitEvent(event common.Event) {}
+
+func (m *dummyRPCModule) CheckStorage(filename string, size int64) error {
+	return nil
+}
+
+func (m *dummyRPCModule) SendToLeader(msg *protos.SignedRKSyncMessage) {}
+
+func (m *dummyRPCModule) FileVersion(filename string) uint64 {
+	return 0
+}
+
+func (m *dummyRPCModule) ReportMisbehavior(pkiID common.PKIidType, kind common.Misbehavior) {}
+
+func (m *dummyRPCModule) TransferScheduler() *fsync.Scheduler {
+	return nil
+}
+
+func (m *dummyRPCModule) PullInterval() time.Duration {
+	if d := time.Duration(m.pullInterval.Load()); d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
 func (m *dummyRPCModule) wasMocked(methodName string) bool {
 	m.On("bla", mock.Anything)
 	for _, ec := range m.ExpectedCalls {
@@ -147,6 +188,79 @@ func TestNewFileSyncProvider(t *testing.T) {
 	fs := &dummyFileSystem{t: t, leader: false}
 	adapter.fs = fs
 
-	_, err := fsync.NewFileSyncProvider(chainMac, channelA, "filename", []byte{}, protos.File_Append, false, pkiIDForPeer1, adapter)
+	_, err := fsync.NewFileSyncProvider(chainMac, channelA, "filename", []byte{}, protos.File_Append, config.FileAttributes{}, 0, false, pkiIDForPeer1, adapter, logging.Default())
 	assert.NoError(t, err)
 }
+
+// newFollower creates the provider of a follower's file, along with the channel the data messages are delivered to
+func newFollower(t *testing.T) (*fsync.FileSyncProvier, chan<- *protos.RKSyncMessage) {
+	adapter := new(dummyRPCModule)
+	msgChan := make(chan *protos.RKSyncMessage, 1)
+	adapter.On("Accept", mock.Anything, mock.Anything, mock.Anything).Return(make(<-chan *protos.RKSyncMessage), make(<-chan protos.ReceivedMessage)).Once()
+	adapter.On("Accept", mock.Anything, mock.Anything, mock.Anything).Return((<-chan *protos.RKSyncMessage)(msgChan), make(<-chan protos.ReceivedMessage)).Once()
+	adapter.fs = &dummyFileSystem{t: t, leader: false}
+
+	chainMac := channel.GenerateMAC(pkiIDForPeer1, channelA)
+	p, err := fsync.NewFileSyncProvider(chainMac, channelA, "filename", []byte{}, protos.File_Append, config.FileAttributes{}, 0, false, pkiIDForPeer1, adapter, logging.Default())
+	require.NoError(t, err)
+	return p, msgChan
+}
+
+// dataMsg returns a data message of the file, carrying the given size of the leader's file
+func dataMsg(start int64, data []byte, fileSize int64) *protos.RKSyncMessage {
+	return &protos.RKSyncMessage{
+		ChainMac: channel.GenerateMAC(pkiIDForPeer1, channelA),
+		Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{
+			FileName: "filename",
+			Payload: &protos.Payload{Data: data, Metadata: &protos.Payload_Append{Append: &protos.AppendMetadata{
+				Start: start, Length: int64(len(data)), FileSize: fileSize,
+			}}},
+		}},
+	}
+}
+
+func TestProgressExpectedSize(t *testing.T) {
+	p, msgChan := newFollower(t)
+	defer p.Stop()
+
+	// The expected size is the size of the leader's file carried by the data, not the end of the data received
+	msgChan <- dataMsg(1000, []byte("data"), 4096)
+	assert.Eventually(t, func() bool {
+		progress, err := p.Progress()
+		return err == nil && progress.ExpectedSize == 4096
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestTransferring(t *testing.T) {
+	p, msgChan := newFollower(t)
+	defer p.Stop()
+	assert.False(t, p.Transferring())
+
+	// The transfer is active while the local copy is behind the leader's one and its data is received,
+	// even though no data is being written at the moment
+	msgChan <- dataMsg(1000, []byte("data"), 4096)
+	assert.Eventually(t, p.Transferring, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestPullIntervalChange(t *testing.T) {
+	adapter := new(dummyRPCModule)
+	adapter.fs = &dummyFileSystem{t: t, leader: false}
+	adapter.pullInterval.Store(int64(20 * time.Millisecond))
+	adapter.On("Accept", mock.Anything, mock.Anything, mock.Anything).Return(make(<-chan *protos.RKSyncMessage), make(<-chan protos.ReceivedMessage))
+	adapter.On("GetMembership").Return([]common.NetworkMember{{PKIID: pkiIDForPeer2}})
+	var requests atomic.Int32
+	adapter.On("SendToPeer", mock.Anything, mock.Anything).Run(func(mock.Arguments) { requests.Add(1) })
+
+	chainMac := channel.GenerateMAC(pkiIDForPeer1, channelA)
+	p, err := fsync.NewFileSyncProvider(chainMac, channelA, "filename", []byte{}, protos.File_Append, config.FileAttributes{}, 0, false, pkiIDForPeer1, adapter, logging.Default())
+	require.NoError(t, err)
+	defer p.Stop()
+	assert.Eventually(t, func() bool { return requests.Load() >= 2 }, 3*time.Second, 10*time.Millisecond)
+
+	// The interval changed at runtime applies from the next request on
+	adapter.pullInterval.Store(int64(time.Hour))
+	time.Sleep(100 * time.Millisecond)
+	sent := requests.Load()
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, sent, requests.Load())
+}
@@ -0,0 +1,201 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsync
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// transferIdleTimeout is the time after which the slot of a transfer which didn't receive
+// any data is granted to another one, e.g. if the peers serving the file are gone
+const transferIdleTimeout = 30 * time.Second
+
+// Scheduler bounds the number of files a peer receives concurrently across its channels.
+// A free slot is granted to the channel holding the fewest, the channels in turn if several do,
+// so that a busy channel can't starve the others. The files of a channel are granted by decreasing priority.
+type Scheduler struct {
+	lock        sync.Mutex
+	limit       int
+	idleTimeout time.Duration
+	seqNum      uint64
+	active      map[*Transfer]time.Time // Transfers granted a slot, along with the time of their last activity
+	slots       map[string]int          // Number of slots held by each channel
+	waiting     map[string][]*Transfer  // Transfers waiting for a slot, by channel
+	turns       []string                // Channels with waiting transfers, in the order they are granted a slot
+}
+
+// Transfer is a file transferred under the control of a Scheduler, its fields are guarded by the lock of the Scheduler
+type Transfer struct {
+	channel  string
+	priority int32
+	seqNum   uint64
+	waiting  bool
+	granted  chan struct{}
+}
+
+// NewScheduler creates a Scheduler granting at most limit slots at a time
+func NewScheduler(limit int) *Scheduler {
+	return &Scheduler{
+		limit:       limit,
+		idleTimeout: transferIdleTimeout,
+		active:      make(map[*Transfer]time.Time),
+		slots:       make(map[string]int),
+		waiting:     make(map[string][]*Transfer),
+	}
+}
+
+// NewTransfer creates the transfer of a file of the given channel, with the given priority among the files of the channel
+func (s *Scheduler) NewTransfer(channel string, priority int32) *Transfer {
+	return &Transfer{channel: channel, priority: priority, granted: make(chan struct{}, 1)}
+}
+
+// Granted signals that the transfer was granted a slot
+func (t *Transfer) Granted() <-chan struct{} {
+	return t.granted
+}
+
+// Request queues the transfer until a slot is available, Granted is signaled then
+func (s *Scheduler) Request(t *Transfer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, active := s.active[t]; !active && !t.waiting {
+		s.seqNum++
+		t.seqNum = s.seqNum
+		t.waiting = true
+		queue := s.waiting[t.channel]
+		if len(queue) == 0 {
+			s.turns = append(s.turns, t.channel)
+		}
+		queue = append(queue, t)
+		sort.SliceStable(queue, func(i, j int) bool {
+			if queue[i].priority != queue[j].priority {
+				return queue[i].priority > queue[j].priority
+			}
+			return queue[i].seqNum < queue[j].seqNum
+		})
+		s.waiting[t.channel] = queue
+	}
+	s.dispatch(time.Now())
+}
+
+// Active returns whether the transfer holds a slot
+func (s *Scheduler) Active(t *Transfer) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, active := s.active[t]
+	return active
+}
+
+// Waiting returns whether the transfer waits for a slot
+func (s *Scheduler) Waiting(t *Transfer) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return t.waiting
+}
+
+// Touch records that the transfer received data, so that its slot isn't granted to another one
+func (s *Scheduler) Touch(t *Transfer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, active := s.active[t]; active {
+		s.active[t] = time.Now()
+	}
+}
+
+// Release frees the slot of the transfer, or removes it from the queue
+func (s *Scheduler) Release(t *Transfer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if t.waiting {
+		t.waiting = false
+		queue := s.waiting[t.channel]
+		for i := range queue {
+			if queue[i] == t {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		s.waiting[t.channel] = queue
+		if len(queue) == 0 {
+			s.removeTurn(t.channel)
+		}
+	}
+	s.free(t)
+	s.dispatch(time.Now())
+}
+
+// Stats returns the number of transfers holding a slot and of those waiting for one
+func (s *Scheduler) Stats() (active int, waiting int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, queue := range s.waiting {
+		waiting += len(queue)
+	}
+	return len(s.active), waiting
+}
+
+// dispatch reclaims the slots of the idle transfers, then grants the free slots to the waiting transfers.
+// It should be invoked with the lock held.
+func (s *Scheduler) dispatch(now time.Time) {
+	for t, last := range s.active {
+		if now.Sub(last) > s.idleTimeout {
+			s.free(t)
+		}
+	}
+
+	for len(s.active) < s.limit && len(s.turns) > 0 {
+		next := 0
+		for i, channel := range s.turns {
+			if s.slots[channel] < s.slots[s.turns[next]] {
+				next = i
+			}
+		}
+		channel := s.turns[next]
+		s.turns = append(s.turns[:next], s.turns[next+1:]...)
+		queue := s.waiting[channel]
+		t := queue[0]
+		if len(queue) > 1 {
+			s.waiting[channel] = queue[1:]
+			s.turns = append(s.turns, channel)
+		} else {
+			delete(s.waiting, channel)
+		}
+
+		t.waiting = false
+		s.active[t] = now
+		s.slots[channel]++
+		select {
+		case t.granted <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// free frees the slot of the transfer if it holds one. It should be invoked with the lock held.
+func (s *Scheduler) free(t *Transfer) {
+	if _, active := s.active[t]; !active {
+		return
+	}
+	delete(s.active, t)
+	if s.slots[t.channel]--; s.slots[t.channel] == 0 {
+		delete(s.slots, t.channel)
+	}
+}
+
+func (s *Scheduler) removeTurn(channel string) {
+	delete(s.waiting, channel)
+	for i, c := range s.turns {
+		if c == channel {
+			s.turns = append(s.turns[:i], s.turns[i+1:]...)
+			return
+		}
+	}
+}
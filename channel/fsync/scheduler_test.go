@@ -0,0 +1,75 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func granted(t *Transfer) bool {
+	select {
+	case <-t.Granted():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(2)
+	busy1, busy2 := s.NewTransfer("busy", 0), s.NewTransfer("busy", 0)
+	low, high := s.NewTransfer("busy", 1), s.NewTransfer("busy", 5)
+	quiet := s.NewTransfer("quiet", 0)
+
+	s.Request(busy1)
+	s.Request(busy2)
+	s.Request(busy2)
+	assert.True(t, granted(busy1))
+	assert.True(t, granted(busy2))
+	assert.True(t, s.Active(busy1))
+
+	// The slots are full, the files of the busy channel are queued by priority
+	s.Request(low)
+	s.Request(high)
+	s.Request(quiet)
+	active, waiting := s.Stats()
+	assert.Equal(t, 2, active)
+	assert.Equal(t, 3, waiting)
+	assert.True(t, s.Waiting(quiet))
+
+	// The channel holding no slot is granted the first free one
+	s.Release(busy1)
+	assert.True(t, granted(quiet))
+	assert.False(t, granted(high))
+	s.Release(busy2)
+	assert.True(t, granted(high))
+	assert.False(t, granted(low))
+
+	// Waiting transfers can be canceled
+	s.Release(low)
+	s.Release(quiet)
+	active, waiting = s.Stats()
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 0, waiting)
+	assert.False(t, s.Waiting(low))
+
+	// The slot of an idle transfer is granted to another one
+	s.idleTimeout = time.Millisecond
+	s.Request(busy1)
+	assert.True(t, granted(busy1))
+	s.Request(busy2)
+	assert.False(t, granted(busy2))
+	time.Sleep(2 * time.Millisecond)
+	s.Touch(busy1)
+	s.Request(busy2)
+	assert.True(t, granted(busy2))
+	assert.False(t, s.Active(high))
+	assert.True(t, s.Active(busy1))
+}
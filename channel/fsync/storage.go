@@ -0,0 +1,83 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// rejectionBackoff is the time a member doesn't request a file it rejected
+const rejectionBackoff = time.Minute
+
+// StorageError is returned when a member doesn't have the room to store a file
+type StorageError struct {
+	ChainID   string
+	Filename  string
+	Limit     protos.TransferRejection_Limit // Quota if the channel quota would be exceeded, Disk if the disk would be filled
+	Required  int64                          // Number of bytes needed to store the file
+	Available int64                          // Number of bytes left
+}
+
+func (e *StorageError) Error() string {
+	if e.Limit == protos.TransferRejection_Disk {
+		return fmt.Sprintf("Not enough disk space to store file %s of channel %s: %d bytes required, %d available", e.Filename, e.ChainID, e.Required, e.Available)
+	}
+	return fmt.Sprintf("Storing file %s exceeds the quota of channel %s: %d bytes required, %d available", e.Filename, e.ChainID, e.Required, e.Available)
+}
+
+// reject drops the data received for the file, which can't be stored, and notifies the leader
+func (p *FileSyncProvier) reject(storageErr *StorageError) {
+	p.logger.Warningf("Rejecting transfer: %s", storageErr)
+	p.payloads.Reset(0)
+	p.rejectedUntil.Store(time.Now().Add(rejectionBackoff).UnixNano())
+	if p.transfer != nil {
+		p.TransferScheduler().Release(p.transfer)
+	}
+	p.EmitEvent(common.FileSyncFailed{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Err: storageErr})
+
+	msg, err := p.Sign(&protos.RKSyncMessage{
+		Nonce:    uint64(0),
+		ChainMac: p.chainMac,
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Content: &protos.RKSyncMessage_DataReq{
+			DataReq: &protos.DataRequest{
+				FileName: p.filename,
+				PkiId:    p.pkiID,
+				Req: &protos.DataRequest_Rejection{
+					Rejection: &protos.TransferRejection{
+						Limit:     storageErr.Limit,
+						Required:  storageErr.Required,
+						Available: storageErr.Available,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		p.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
+		return
+	}
+	p.SendToLeader(msg)
+}
+
+// handleRejection reports that a member rejected the transfer of the file
+func (p *FileSyncProvier) handleRejection(req *protos.DataRequest) {
+	rejection := req.GetRejection()
+	storageErr := &StorageError{
+		ChainID:   p.chainID,
+		Filename:  p.filename,
+		Limit:     rejection.Limit,
+		Required:  rejection.Required,
+		Available: rejection.Available,
+	}
+	p.logger.Warningf("Peer %s rejected the transfer: %s", common.PKIidType(req.PkiId), storageErr)
+	p.EmitEvent(common.TransferRejected{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, PKIID: req.PkiId, Err: storageErr})
+}
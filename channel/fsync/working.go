@@ -0,0 +1,307 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// tempSuffix is appended to the name of a file to name its working copy
+const tempSuffix = ".rksync-tmp"
+
+// workingCopy is the temporary file the received data is written to. It's renamed over the file once its digest
+// has been verified, or appended to the file if it only holds the data received from base on.
+type workingCopy struct {
+	f         config.File
+	hash      hash.Hash // Hash of the file's content up to the end of the working copy
+	base      int64     // Offset in the file of the data received
+	written   int64     // Number of bytes received since the working copy was created
+	appending bool      // Whether the working copy only holds the data received, to be appended to the file
+}
+
+func (w *workingCopy) Write(data []byte) (int, error) {
+	n, err := w.f.Write(data)
+	w.hash.Write(data[:n])
+	w.written += int64(n)
+	return n, err
+}
+
+// FileMeta returns the metadata the file is accessed with on the FileSystem
+func (p *FileSyncProvier) FileMeta() config.FileMeta {
+	return config.FileMeta{Name: p.filename, Metadata: p.metadata, Leader: p.leader}
+}
+
+func (p *FileSyncProvier) tempMeta() config.FileMeta {
+	return WorkingCopyMeta(p.FileMeta())
+}
+
+// WorkingCopyMeta returns the metadata of the working copy of the given file
+func WorkingCopyMeta(fmeta config.FileMeta) config.FileMeta {
+	fmeta.Name += tempSuffix
+	return fmeta
+}
+
+// openWorkingCopy creates the working copy of the file. If the FileSystem appends files atomically, the working copy
+// only holds the received data, appended to the file once verified. Otherwise it starts with a copy of the synchronized
+// part of the file, and replaces the file. The hash of the synchronized part is carried over either way.
+func (p *FileSyncProvier) openWorkingCopy() error {
+	if p.working != nil {
+		return nil
+	}
+
+	w := &workingCopy{base: p.payloads.Next()}
+	if w.base > 0 {
+		h, err := p.hashAt(w.base)
+		if err != nil {
+			return errors.Wrap(err, "failed hashing file")
+		}
+		w.hash = h
+	} else {
+		w.hash = sha256.New()
+	}
+
+	fs := p.GetFileSystem()
+	f, err := fs.Create(p.chainID, p.tempMeta())
+	if err != nil {
+		return errors.Wrap(err, "failed creating working copy")
+	}
+	w.f = f
+	if _, ok := fs.(config.FileAppender); ok {
+		w.appending = w.base > 0
+	} else if w.base > 0 {
+		if err := p.copyFile(f, w.base); err != nil {
+			f.Close()
+			p.removeWorkingCopy()
+			return errors.Wrap(err, "failed copying file to its working copy")
+		}
+	}
+
+	p.working = w
+	p.EmitEvent(common.FileSyncStarted{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename})
+	return nil
+}
+
+// copyFile writes the first size bytes of the file to w
+func (p *FileSyncProvier) copyFile(w io.Writer, size int64) error {
+	f, err := p.GetFileSystem().OpenFile(p.chainID, p.FileMeta(), os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := copyBuffers.Copy(w, io.NewSectionReader(f, 0, size))
+	if err == nil && n != size {
+		err = errors.Errorf("file is shorter than %d bytes", size)
+	}
+	return err
+}
+
+// commitWorkingCopy replaces the file by its working copy, or appends the working copy to the file, if the content
+// of the file along with the working copy has the given digest, and applies the attributes of the leader's copy,
+// last modified at the given time
+func (p *FileSyncProvier) commitWorkingCopy(digest []byte, modTime int64) error {
+	w := p.working
+	p.working = nil
+
+	err := w.f.Close()
+	if err == nil && !bytes.Equal(w.hash.Sum(nil), digest) {
+		err = errors.New("digest mismatch")
+		if source, _ := p.source.Load().(common.PKIidType); source != nil {
+			p.ReportMisbehavior(source, common.FailedTransfer)
+		}
+	}
+	if err == nil && w.appending {
+		err = p.appendWorkingCopy(w)
+	} else if err == nil {
+		err = p.GetFileSystem().Rename(p.chainID, p.tempMeta(), p.FileMeta())
+	}
+	if err != nil {
+		p.removeWorkingCopy()
+		p.resetDigest()
+		p.restartFromFile()
+		return errors.Wrap(err, "failed replacing file by its working copy")
+	}
+	p.setDigest(w.hash, w.base+w.written)
+
+	p.logger.Debugf("[%s] Committed working copy at offset %d, %d bytes received", p.filename, w.base, w.written)
+	p.applyAttributes(modTime)
+	p.lastSynced.Store(time.Now().UnixNano())
+	p.EmitEvent(common.FileSyncCompleted{ChainMac: p.chainMac, ChainID: p.chainID, Filename: p.filename, Written: w.written})
+	p.ackFile(p.payloads.Next(), digest)
+	return nil
+}
+
+// appendWorkingCopy appends the verified content of the working copy to the file, which must end where it starts
+func (p *FileSyncProvier) appendWorkingCopy(w *workingCopy) error {
+	fs := p.GetFileSystem()
+	fi, err := fs.Stat(p.chainID, p.FileMeta())
+	if err != nil {
+		return err
+	}
+	if fi.Size() != w.base {
+		return errors.Errorf("file is %d bytes long while its working copy starts at %d", fi.Size(), w.base)
+	}
+	return errors.Wrap(fs.(config.FileAppender).Append(p.chainID, p.tempMeta(), p.FileMeta()), "failed appending working copy to file")
+}
+
+// removeWorkingCopy deletes the working copy if the FileSystem supports it, otherwise it's truncated
+// so that no received data is left behind
+func (p *FileSyncProvier) removeWorkingCopy() {
+	fs := p.GetFileSystem()
+	var err error
+	if remover, ok := fs.(config.FileRemover); ok {
+		err = remover.Remove(p.chainID, p.tempMeta())
+	} else {
+		var f config.File
+		if f, err = fs.Create(p.chainID, p.tempMeta()); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		p.logger.Warningf("Failed removing working copy of file %s (Channel %s): %s", p.filename, p.chainMac, err)
+	}
+}
+
+// ackFile tells the leader that the local copy of the file, of the given size and digest, is synchronized
+func (p *FileSyncProvier) ackFile(size int64, digest []byte) {
+	msg, err := p.Sign(&protos.RKSyncMessage{
+		Nonce:    uint64(0),
+		ChainMac: p.chainMac,
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Content: &protos.RKSyncMessage_FileAck{
+			FileAck: &protos.FileAck{
+				FileName: p.filename,
+				Size:     size,
+				Digest:   digest,
+				Version:  p.FileVersion(p.filename),
+			},
+		},
+	})
+	if err != nil {
+		p.logger.Warningf("Failed signing FileAck message of file %s: %s", p.filename, err)
+		return
+	}
+	p.SendToLeader(msg)
+}
+
+// applyAttributes applies the attributes of the leader's copy if the FileSystem supports it
+func (p *FileSyncProvier) applyAttributes(modTime int64) {
+	setter, ok := p.GetFileSystem().(config.AttributesSetter)
+	if !ok {
+		return
+	}
+
+	attrs := p.attrs
+	if modTime > 0 {
+		attrs.ModTime = time.Unix(0, modTime)
+	}
+	if err := setter.SetAttributes(p.chainID, p.FileMeta(), attrs); err != nil {
+		p.logger.Warningf("Failed setting attributes of file %s (Channel %s): %s", p.filename, p.chainMac, err)
+	}
+}
+
+// discardWorkingCopy drops the working copy, the data written to it is requested again
+func (p *FileSyncProvier) discardWorkingCopy() {
+	if p.working == nil {
+		return
+	}
+	p.working.f.Close()
+	p.working = nil
+	p.removeWorkingCopy()
+	p.restartFromFile()
+}
+
+// restartFromFile resumes the synchronization from the end of the file
+func (p *FileSyncProvier) restartFromFile() {
+	fi, err := p.GetFileSystem().Stat(p.chainID, p.FileMeta())
+	if err != nil {
+		p.logger.Warningf("Failed to stat file %s: %s", p.filename, err)
+		return
+	}
+	p.payloads.Reset(fi.Size() - p.payloads.Next())
+}
+
+// digestAt returns the digest of the first size bytes of the local file.
+// Only the bytes appended since the previous call are hashed, unless the file shrank.
+func (p *FileSyncProvier) digestAt(size int64) ([]byte, error) {
+	p.digestLock.Lock()
+	defer p.digestLock.Unlock()
+
+	if err := p.hashFile(size); err != nil {
+		return nil, err
+	}
+	return p.digest.Sum(nil), nil
+}
+
+// hashAt returns a copy of the hash of the first size bytes of the local file, to be written to
+func (p *FileSyncProvier) hashAt(size int64) (hash.Hash, error) {
+	p.digestLock.Lock()
+	defer p.digestLock.Unlock()
+
+	if err := p.hashFile(size); err != nil {
+		return nil, err
+	}
+	state, err := p.digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// hashFile brings the hash of the local file up to its first size bytes, digestLock must be held
+func (p *FileSyncProvier) hashFile(size int64) error {
+	if p.digest == nil || p.digestSize > size {
+		p.digest, p.digestSize = sha256.New(), 0
+	}
+	if size > p.digestSize {
+		f, err := p.GetFileSystem().OpenFile(p.chainID, p.FileMeta(), os.O_RDONLY, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		n, err := copyBuffers.Copy(p.digest, io.NewSectionReader(f, p.digestSize, size-p.digestSize))
+		if err == nil && n != size-p.digestSize {
+			err = errors.Errorf("file is shorter than %d bytes", size)
+		}
+		if err != nil {
+			p.digest = nil
+			return err
+		}
+		p.digestSize = size
+	}
+	return nil
+}
+
+// setDigest records the hash of the first size bytes of the local file, after they were written
+func (p *FileSyncProvier) setDigest(h hash.Hash, size int64) {
+	p.digestLock.Lock()
+	defer p.digestLock.Unlock()
+	p.digest, p.digestSize = h, size
+}
+
+// resetDigest forgets the digest of the local file, after it was rewritten
+func (p *FileSyncProvier) resetDigest() {
+	p.digestLock.Lock()
+	defer p.digestLock.Unlock()
+	p.digest = nil
+}
@@ -9,7 +9,9 @@ package channel
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel/fsync"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
@@ -26,9 +28,10 @@ func newFSyncState(gc *gossipChannel) *fsyncState {
 
 type fsyncState struct {
 	sync.RWMutex
-	files    map[string]*fsync.FileSyncProvier
-	gc       *gossipChannel
-	stopping int32
+	files     map[string]*fsync.FileSyncProvier
+	providers sync.Map // filename -> *fsync.FileSyncProvier, mirrors files for the providers which can't take the lock
+	gc        *gossipChannel
+	stopping  int32
 }
 
 func (f *fsyncState) lookupFSyncProviderByFilename(filename string) *fsync.FileSyncProvier {
@@ -50,7 +53,9 @@ func (f *fsyncState) closeFSyncProvider(filename string) {
 	defer f.Unlock()
 
 	if fp, exists := f.files[filename]; exists {
+		f.providers.Delete(filename)
 		fp.Stop()
+		delete(f.files, filename)
 	}
 }
 
@@ -70,30 +75,90 @@ func (f *fsyncState) snapshot() []string {
 	return fnames
 }
 
-func (f *fsyncState) createProvider(filename string, mode protos.File_Mode, metadata []byte, leader bool) error {
+// fileMetas returns the metadata of the synchronized files
+func (f *fsyncState) fileMetas() []config.FileMeta {
+	if f.isStopping() {
+		return nil
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	fmetas := make([]config.FileMeta, 0, len(f.files))
+	for _, fs := range f.files {
+		fmetas = append(fmetas, fs.FileMeta())
+	}
+	return fmetas
+}
+
+func (f *fsyncState) createProvider(file *protos.File, leader bool) error {
 	if f.isStopping() {
 		return nil
 	}
 	f.Lock()
 	defer f.Unlock()
 
-	_, exists := f.files[filename]
+	_, exists := f.files[file.Path]
 	if !exists {
 		pkiID := f.gc.pkiID
 		chainMac := f.gc.chainMac
 		chainID := f.gc.chainID
 		fa := &fsyncAdapterImpl{gossipChannel: f.gc}
-		fs, err := fsync.NewFileSyncProvider(chainMac, chainID, filename, metadata, mode, leader, pkiID, fa)
+		fs, err := fsync.NewFileSyncProvider(chainMac, chainID, file.Path, file.Metadata, file.Mode, configFileAttributes(file), file.Priority, leader, pkiID, fa, f.gc.logger)
 		if err != nil {
 			return err
 		}
 
-		f.files[filename] = fs
+		f.files[file.Path] = fs
+		f.providers.Store(file.Path, fs)
 	}
 
 	return nil
 }
 
+func (f *fsyncState) transferring() bool {
+	f.RLock()
+	defer f.RUnlock()
+
+	for _, fs := range f.files {
+		if fs.Transferring() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fsyncState) progress() ([]fsync.Progress, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	progress := make([]fsync.Progress, 0, len(f.files))
+	for _, fs := range f.files {
+		p, err := fs.Progress()
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+// usage returns the number of bytes stored for the files of the channel other than the given one.
+// It doesn't take the lock, which is held while the providers calling it are stopped.
+func (f *fsyncState) usage(exclude string) int64 {
+	var total int64
+	f.providers.Range(func(key, value interface{}) bool {
+		if key.(string) == exclude {
+			return true
+		}
+		if p, err := value.(*fsync.FileSyncProvier).Progress(); err == nil {
+			total += p.Size
+		}
+		return true
+	})
+	return total
+}
+
 func (f *fsyncState) stop() {
 	if f.isStopping() {
 		return
@@ -119,10 +184,82 @@ func (fa *fsyncAdapterImpl) GetFileSystem() config.FileSystem {
 	return fa.gossipChannel.fs
 }
 
+// IsMemberInChan is called by the providers, which may be stopped while the channel's lock is held
+func (fa *fsyncAdapterImpl) IsMemberInChan(member common.NetworkMember) bool {
+	_, exists := fa.memberSet.Load(member.PKIID.String())
+	return exists
+}
+
+func (fa *fsyncAdapterImpl) FileVersion(filename string) uint64 {
+	return fa.fileVersions.latestOf(filename)
+}
+
+func (fa *fsyncAdapterImpl) Zone() string {
+	return fa.GetChannelConfig().Zone
+}
+
+func (fa *fsyncAdapterImpl) MaxMessageSize() int {
+	return fa.GetChannelConfig().MaxMessageSize
+}
+
+func (fa *fsyncAdapterImpl) TransferScheduler() *fsync.Scheduler {
+	return fa.GetChannelConfig().Scheduler
+}
+
+func (fa *fsyncAdapterImpl) PullInterval() time.Duration {
+	return fa.GetChannelConfig().PullInterval
+}
+
 func (fa *fsyncAdapterImpl) SendToPeer(message *protos.SignedRKSyncMessage, peer *common.NetworkMember) {
 	fa.Send(message, peer)
 }
 
+func (fa *fsyncAdapterImpl) SendToLeader(message *protos.SignedRKSyncMessage) {
+	leader, _ := fa.leaderPKIid.Load().(common.PKIidType)
+	if leader == nil {
+		return
+	}
+	peer := fa.Lookup(leader)
+	if peer == nil {
+		fa.logger.Warningf("Can't find the leader's information: %s", leader)
+		return
+	}
+	fa.Send(message, peer)
+}
+
+// CheckStorage checks the given size against the quota of the channel and,
+// if the file system reports it, the free disk space
+func (fa *fsyncAdapterImpl) CheckStorage(filename string, size int64) error {
+	conf := fa.GetChannelConfig()
+	quota := conf.StorageQuota
+	if q, exists := conf.StorageQuotas[fa.chainID]; exists {
+		quota = q
+	}
+	if quota > 0 {
+		available := quota - fa.fileState.usage(filename)
+		if available < 0 {
+			available = 0
+		}
+		if size > available {
+			return &fsync.StorageError{ChainID: fa.chainID, Filename: filename, Limit: protos.TransferRejection_Quota, Required: size, Available: available}
+		}
+	}
+
+	reporter, ok := fa.fs.(config.SpaceReporter)
+	if !ok {
+		return nil
+	}
+	free, err := reporter.FreeSpace(fa.chainID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting free space of channel %s", fa.chainID)
+	}
+	// The data is written to a working copy, next to the file it replaces
+	if size > free {
+		return &fsync.StorageError{ChainID: fa.chainID, Filename: filename, Limit: protos.TransferRejection_Disk, Required: size, Available: free}
+	}
+	return nil
+}
+
 func (fa *fsyncAdapterImpl) Sign(message *protos.RKSyncMessage) (*protos.SignedRKSyncMessage, error) {
 	signer := func(msg []byte) ([]byte, error) {
 		return fa.idMapper.Sign(msg)
@@ -131,7 +268,7 @@ func (fa *fsyncAdapterImpl) Sign(message *protos.RKSyncMessage) (*protos.SignedR
 	signedMsg := &protos.SignedRKSyncMessage{
 		RKSyncMessage: message,
 	}
-	envp, err := signedMsg.Sign(signer)
+	envp, err := signedMsg.SignWithAlgorithm(fa.idMapper.SignatureAlgorithm(), signer)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,130 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// leaderHeartbeat tracks the heartbeats the leader stamps on the chain states it publishes
+type leaderHeartbeat struct {
+	sync.Mutex
+	seqNum      uint64 // Sequence number of the heartbeats published by the leader, incremented atomically
+	last        *protos.PeerTime
+	received    time.Time
+	unreachable bool
+	handled     uint64 // Sequence number of the last chain state the change of which was applied
+}
+
+// stampHeartbeat returns a copy of the chain state carrying a new heartbeat of the leader
+func (gc *gossipChannel) stampHeartbeat(chainState *protos.ChainState) *protos.ChainState {
+	return &protos.ChainState{
+		SeqNum:   chainState.SeqNum,
+		ChainId:  chainState.ChainId,
+		Envelope: chainState.Envelope,
+		Change:   chainState.Change,
+		Heartbeat: &protos.PeerTime{
+			IncNum: gc.incTime,
+			SeqNum: atomic.AddUint64(&gc.heartbeat.seqNum, 1),
+		},
+	}
+}
+
+// recordHeartbeat records the heartbeat of a ChainState message, the heartbeats relayed by
+// other peers are accepted as long as they're signed by the leader.
+// It returns whether the message carries a heartbeat of the leader.
+func (gc *gossipChannel) recordHeartbeat(m *protos.SignedRKSyncMessage) bool {
+	heartbeat := m.GetState().Heartbeat
+	leader, _ := gc.leaderPKIid.Load().(common.PKIidType)
+	if heartbeat == nil || gc.leader || leader == nil {
+		return false
+	}
+	err := m.Verify(leader, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
+	})
+	if err != nil {
+		gc.logger.Debugf("Channel %s: ChainState message isn't signed by the leader: %v", gc.chainMac, err)
+		return false
+	}
+
+	gc.heartbeat.Lock()
+	defer gc.heartbeat.Unlock()
+	if gc.heartbeat.last != nil && !heartbeat.After(gc.heartbeat.last) {
+		return true
+	}
+	gc.heartbeat.last = heartbeat
+	gc.heartbeat.received = gc.clock.Now()
+	if gc.heartbeat.unreachable {
+		gc.heartbeat.unreachable = false
+		gc.logger.Infof("Channel %s: Leader %s is reachable again", gc.chainMac, leader)
+	}
+	return true
+}
+
+// LeaderHeartbeat returns the time the last heartbeat of the leader was received, or the channel was joined
+func (gc *gossipChannel) LeaderHeartbeat() time.Time {
+	if gc.leader {
+		return time.Time{}
+	}
+	gc.heartbeat.Lock()
+	defer gc.heartbeat.Unlock()
+	return gc.heartbeat.received
+}
+
+func (gc *gossipChannel) periodicalCheckLeader(timeout time.Duration) {
+	for {
+		select {
+		case <-gc.clock.After(timeout / 4):
+			gc.checkLeader(timeout, gc.clock.Now())
+		case s := <-gc.stopChan:
+			gc.stopChan <- s
+			return
+		}
+	}
+}
+
+// checkLeader emits a LeaderUnreachable event once the leader hasn't published a heartbeat for the timeout
+func (gc *gossipChannel) checkLeader(timeout time.Duration, now time.Time) {
+	leader, _ := gc.leaderPKIid.Load().(common.PKIidType)
+	if leader == nil {
+		return
+	}
+
+	gc.heartbeat.Lock()
+	received := gc.heartbeat.received
+	if gc.heartbeat.unreachable || now.Sub(received) <= timeout {
+		gc.heartbeat.Unlock()
+		return
+	}
+	gc.heartbeat.unreachable = true
+	gc.heartbeat.Unlock()
+
+	gc.logger.Warningf("Channel %s: Leader %s hasn't published a heartbeat since %s", gc.chainMac, leader, received)
+	gc.EmitEvent(common.LeaderUnreachable{ChainMac: gc.chainMac, ChainID: gc.chainID, Leader: leader, LastHeartbeat: received})
+}
+
+// isHandled returns whether the chain state is the current one and its change was applied. The gossip layer
+// initializes the channel with a new chain state before handing it over, its change still has to be applied then.
+func (gc *gossipChannel) isHandled(chainState *protos.ChainState) bool {
+	gc.heartbeat.Lock()
+	handled := gc.heartbeat.handled
+	gc.heartbeat.Unlock()
+	self := gc.Self()
+	return self != nil && self.SeqNum == handled && self.SameState(chainState)
+}
+
+// stateHandled records that the change of the chain state was applied
+func (gc *gossipChannel) stateHandled(chainState *protos.ChainState) {
+	gc.heartbeat.Lock()
+	defer gc.heartbeat.Unlock()
+	gc.heartbeat.handled = chainState.SeqNum
+}
@@ -0,0 +1,93 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLeaderHeartbeat(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default()).(*gossipChannel)
+	defer leader.Stop()
+	state, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID, peer2.pkiID}, nil)
+	require.NoError(t, err)
+	state = proto.Clone(state).(*protos.ChainState)
+
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer follower.Stop()
+	require.NoError(t, follower.InitializeWithChainState(state))
+	joined := follower.LeaderHeartbeat()
+	assert.False(t, joined.IsZero())
+	assert.True(t, leader.LeaderHeartbeat().IsZero())
+
+	heartbeat := func(signer *peer) *protos.SignedRKSyncMessage {
+		msg := &protos.SignedRKSyncMessage{
+			RKSyncMessage: &protos.RKSyncMessage{
+				ChainMac: mac,
+				Tag:      protos.RKSyncMessage_CHAN_ONLY,
+				Content:  &protos.RKSyncMessage_State{State: leader.stampHeartbeat(leader.Self())},
+			},
+		}
+		_, err := msg.SignWithAlgorithm(signer.idMapper.SignatureAlgorithm(), signer.idMapper.Sign)
+		require.NoError(t, err)
+		return msg
+	}
+	unreachable := func() []common.LeaderUnreachable {
+		var events []common.LeaderUnreachable
+		for _, event := range peer1.adapter.emittedEvents() {
+			if e, ok := event.(common.LeaderUnreachable); ok {
+				events = append(events, e)
+			}
+		}
+		return events
+	}
+
+	// The leader is reported once until it publishes a heartbeat again
+	follower.checkLeader(time.Minute, joined.Add(time.Second))
+	assert.Empty(t, unreachable())
+	follower.checkLeader(time.Minute, joined.Add(2*time.Minute))
+	follower.checkLeader(time.Minute, joined.Add(3*time.Minute))
+	assert.Equal(t, []common.LeaderUnreachable{
+		{ChainMac: mac, ChainID: "testchannel", Leader: leaderPeer.pkiID, LastHeartbeat: joined},
+	}, unreachable())
+
+	// The heartbeats relayed by the members are signed by the leader
+	time.Sleep(time.Millisecond)
+	msg := heartbeat(leaderPeer)
+	follower.HandleMessage(&receivedMessageMock{msg: msg, sender: peer2.pkiID})
+	received := follower.LeaderHeartbeat()
+	assert.True(t, received.After(joined))
+	assert.Equal(t, state.SeqNum, follower.Self().SeqNum)
+	follower.checkLeader(time.Minute, received.Add(2*time.Minute))
+	assert.Len(t, unreachable(), 2)
+
+	// Replayed heartbeats and those forged by a member are ignored
+	time.Sleep(time.Millisecond)
+	follower.HandleMessage(&receivedMessageMock{msg: msg, sender: peer2.pkiID})
+	follower.HandleMessage(&receivedMessageMock{msg: heartbeat(peer2), sender: peer2.pkiID})
+	assert.Equal(t, received, follower.LeaderHeartbeat())
+
+	// The change of a new chain state the channel was initialized with by the gossip layer is applied
+	_, err = leader.RemoveMember(peer2.pkiID)
+	require.NoError(t, err)
+	require.NoError(t, follower.InitializeWithChainState(proto.Clone(leader.Self()).(*protos.ChainState)))
+	require.True(t, follower.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+	follower.HandleMessage(&receivedMessageMock{msg: heartbeat(leaderPeer), sender: leaderPeer.pkiID})
+	assert.False(t, follower.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+}
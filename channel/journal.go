@@ -0,0 +1,235 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxJournalRecords is the number of records the journal holds before it is compacted to its last record
+const maxJournalRecords = 1000
+
+// RecoveryState tells how the local files of a member relate to the chain state its channel was initialized with,
+// according to the last chain state journaled before the peer was restarted
+type RecoveryState int
+
+const (
+	// RecoveryNone means that no chain state was journaled, the channel was joined for the first time
+	RecoveryNone RecoveryState = iota
+	// RecoveryInSync means that the files were synchronized according to the chain state the channel was initialized with
+	RecoveryInSync
+	// RecoveryBehind means that the files were synchronized according to an older chain state, they are brought up to date
+	RecoveryBehind
+	// RecoveryAhead means that the files were synchronized according to a newer chain state than the one the channel
+	// was initialized with, the latest chain state is requested from the members
+	RecoveryAhead
+	// RecoveryInterrupted means that the last journaled chain state wasn't completely applied,
+	// the latest chain state is requested from the members
+	RecoveryInterrupted
+)
+
+func (s RecoveryState) String() string {
+	switch s {
+	case RecoveryNone:
+		return "none"
+	case RecoveryInSync:
+		return "in sync"
+	case RecoveryBehind:
+		return "behind"
+	case RecoveryAhead:
+		return "ahead"
+	case RecoveryInterrupted:
+		return "interrupted"
+	}
+	return "unknown"
+}
+
+// stateJournal is the write-ahead journal of the chain states accepted by a member. A record is written
+// before a chain state is applied, and another one once it was applied, they are appended to a file if a path is given.
+type stateJournal struct {
+	sync.Mutex
+	path  string
+	count int
+	last  *protos.JournalRecord
+}
+
+// newStateJournal loads the last record of the journal persisted at the given path,
+// the records following a truncated or corrupted one are discarded
+func newStateJournal(path string, logger logging.Logger) (*stateJournal, error) {
+	j := &stateJournal{path: path}
+	if path == "" {
+		return j, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return j, errors.Wrap(err, "Failed creating the journal directory")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return j, errors.Wrapf(err, "Failed reading journal %s", path)
+	}
+
+	var offset int
+	for offset < len(data) {
+		size, n := binary.Uvarint(data[offset:])
+		if n <= 0 || uint64(len(data)-offset-n) < size {
+			break
+		}
+		record := &protos.JournalRecord{}
+		if err := proto.Unmarshal(data[offset+n:offset+n+int(size)], record); err != nil {
+			break
+		}
+		j.last = record
+		j.count++
+		offset += n + int(size)
+	}
+	if offset < len(data) {
+		logger.Warningf("Discarding the %d trailing bytes of journal %s, they don't hold a valid record", len(data)-offset, path)
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return j, errors.Wrapf(err, "Failed truncating journal %s", path)
+		}
+	}
+	return j, nil
+}
+
+// newJournalRecord creates the record of a chain state signed by the leader, not applied yet
+func newJournalRecord(state *protos.ChainState, info *protos.ChainStateInfo, now time.Time) *protos.JournalRecord {
+	digest := sha256.Sum256(state.Envelope.Payload)
+	return &protos.JournalRecord{
+		SeqNum:    state.SeqNum,
+		Epoch:     info.Epoch,
+		Digest:    digest[:],
+		Signer:    info.Leader,
+		Timestamp: now.UnixNano(),
+	}
+}
+
+// recover compares the last record of the journal with the record of the chain state the channel is initialized with
+func (j *stateJournal) recover(record *protos.JournalRecord) RecoveryState {
+	j.Lock()
+	defer j.Unlock()
+
+	last := j.last
+	switch {
+	case last == nil:
+		return RecoveryNone
+	case !last.Applied:
+		return RecoveryInterrupted
+	case last.Epoch != record.Epoch:
+		if last.Epoch < record.Epoch {
+			return RecoveryBehind
+		}
+		return RecoveryAhead
+	case last.SeqNum != record.SeqNum:
+		if last.SeqNum < record.SeqNum {
+			return RecoveryBehind
+		}
+		return RecoveryAhead
+	case !bytes.Equal(last.Digest, record.Digest):
+		// The leader signed two chain states of the same epoch and sequence number
+		return RecoveryInterrupted
+	}
+	return RecoveryInSync
+}
+
+// append persists the record, the journal is compacted to the record once it holds maxJournalRecords records
+func (j *stateJournal) append(record *protos.JournalRecord) error {
+	j.Lock()
+	defer j.Unlock()
+
+	flag := os.O_APPEND
+	if j.count >= maxJournalRecords {
+		flag = os.O_TRUNC
+		j.count = 0
+	}
+	if err := j.write(record, flag); err != nil {
+		return err
+	}
+	j.last = record
+	j.count++
+	return nil
+}
+
+func (j *stateJournal) write(record *protos.JournalRecord, flag int) error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "Failed marshaling journal record")
+	}
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(len(data)))], data...)
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed opening journal %s", j.path)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return errors.Wrapf(err, "Failed writing journal %s", j.path)
+	}
+	return errors.Wrapf(f.Sync(), "Failed syncing journal %s", j.path)
+}
+
+// journalChainState journals the chain state before it is applied, unless it is the last one applied,
+// it returns the function to call once it was applied
+func (gc *gossipChannel) journalChainState(state *protos.ChainState, info *protos.ChainStateInfo) (func(), error) {
+	record := newJournalRecord(state, info, gc.clock.Now())
+	if gc.journal.recover(record) == RecoveryInSync {
+		return func() {}, nil
+	}
+	if err := gc.journal.append(record); err != nil {
+		return nil, err
+	}
+	return func() {
+		applied := proto.Clone(record).(*protos.JournalRecord)
+		applied.Applied = true
+		applied.Timestamp = gc.clock.Now().UnixNano()
+		if err := gc.journal.append(applied); err != nil {
+			gc.logger.Warningf("Channel %s: Failed journaling the chain state %d as applied: %s", gc.chainMac, state.SeqNum, err)
+		}
+	}, nil
+}
+
+// recoverFromJournal compares the chain state the channel is initialized with to the last journaled one,
+// the latest chain state is requested if the local files may be ahead of it.
+// It should be invoked with the channel's lock held.
+func (gc *gossipChannel) recoverFromJournal(state *protos.ChainState, info *protos.ChainStateInfo) {
+	gc.recovery = gc.journal.recover(newJournalRecord(state, info, gc.clock.Now()))
+	switch gc.recovery {
+	case RecoveryBehind:
+		gc.logger.Infof("Channel %s: Files were synchronized according to an older chain state than %d, bringing them up to date", gc.chainMac, state.SeqNum)
+	case RecoveryAhead:
+		gc.logger.Warningf("Channel %s: Files were synchronized according to a newer chain state than %d, requesting the latest one", gc.chainMac, state.SeqNum)
+		go gc.requestStateInfo()
+	case RecoveryInterrupted:
+		gc.logger.Warningf("Channel %s: The last journaled chain state wasn't completely applied, requesting the latest one", gc.chainMac)
+		go gc.requestStateInfo()
+	}
+}
+
+func (gc *gossipChannel) Recovery() RecoveryState {
+	gc.RLock()
+	defer gc.RUnlock()
+	return gc.recovery
+}
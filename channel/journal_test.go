@@ -0,0 +1,87 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStateJournal(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	peer1.adapter.journalDir = t.TempDir()
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	state1, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, nil)
+	require.NoError(t, err)
+	// The leader updates its chain state in place
+	state1 = proto.Clone(state1).(*protos.ChainState)
+	time.Sleep(time.Millisecond)
+	state2, err := leader.AddMember(peer2.pkiID)
+	require.NoError(t, err)
+	state2 = proto.Clone(state2).(*protos.ChainState)
+
+	restart := func(state *protos.ChainState) RecoveryState {
+		follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default())
+		defer follower.Stop()
+		require.NoError(t, follower.InitializeWithChainState(proto.Clone(state).(*protos.ChainState)))
+		return follower.Recovery()
+	}
+
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	require.NoError(t, follower.InitializeWithChainState(proto.Clone(state1).(*protos.ChainState)))
+	assert.Equal(t, RecoveryNone, follower.Recovery())
+	require.NoError(t, follower.updateChainState(proto.Clone(state2).(*protos.ChainState), leaderPeer.pkiID))
+	// The chain state applied again isn't journaled again
+	require.NoError(t, follower.updateChainState(proto.Clone(state2).(*protos.ChainState), leaderPeer.pkiID))
+	follower.Stop()
+
+	path := filepath.Join(peer1.adapter.journalDir, mac.String()+".journal")
+	journal, err := newStateJournal(path, logging.Default())
+	require.NoError(t, err)
+	assert.Equal(t, 4, journal.count)
+	assert.True(t, journal.last.Applied)
+	assert.Equal(t, state2.SeqNum, journal.last.SeqNum)
+	assert.Equal(t, []byte(leaderPeer.pkiID), journal.last.Signer)
+
+	assert.Equal(t, RecoveryInSync, restart(state2))
+	// The files were synchronized according to the newer chain state
+	assert.Equal(t, RecoveryAhead, restart(state1))
+	assert.Equal(t, RecoveryBehind, restart(state2))
+
+	// The peer crashed while applying the chain state
+	journal, err = newStateJournal(path, logging.Default())
+	require.NoError(t, err)
+	info, err := state2.GetChainStateInfo()
+	require.NoError(t, err)
+	require.NoError(t, journal.append(newJournalRecord(state2, info, time.Now())))
+	assert.Equal(t, RecoveryInterrupted, restart(state2))
+
+	// A truncated record is discarded
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{42, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	journal, err = newStateJournal(path, logging.Default())
+	require.NoError(t, err)
+	assert.True(t, journal.last.Applied)
+	assert.Equal(t, state2.SeqNum, journal.last.SeqNum)
+	assert.Equal(t, RecoveryInSync, restart(state2))
+}
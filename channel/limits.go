@@ -0,0 +1,108 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"fmt"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// Limit identifies a bound enforced on the chain state
+type Limit string
+
+// Limits enforced when the chain state is modified
+const (
+	LimitMembers     Limit = "members"
+	LimitFiles       Limit = "files"
+	LimitStateSize   Limit = "state size"
+	LimitMessageSize Limit = "message size"
+)
+
+// LimitExceededError is returned when a modification would make
+// the chain state exceed one of the configured limits
+type LimitExceededError struct {
+	ChainID string
+	Limit   Limit
+	Max     int
+	Actual  int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("Channel %s exceeds the %s limit: %d > %d", e.ChainID, e.Limit, e.Actual, e.Max)
+}
+
+// Propagation returns the fan-out of the channel's chain states, the one set at runtime
+// takes precedence over the configured one
+func (gc *gossipChannel) Propagation() config.Propagation {
+	if p, ok := gc.propagation.Load().(config.Propagation); ok {
+		return p
+	}
+	return gc.GetChannelConfig().Propagations[gc.chainID]
+}
+
+// SetPropagation overrides the fan-out of the channel's chain states
+func (gc *gossipChannel) SetPropagation(propagation config.Propagation) {
+	gc.propagation.Store(propagation)
+}
+
+// checkCountLimits verifies the number of members and files of the chain state,
+// a zero limit disables the check
+func (gc *gossipChannel) checkCountLimits(members, files int) error {
+	conf := gc.GetChannelConfig()
+	if conf.MaxMembers > 0 && members > conf.MaxMembers {
+		return &LimitExceededError{ChainID: gc.chainID, Limit: LimitMembers, Max: conf.MaxMembers, Actual: members}
+	}
+	if conf.MaxFiles > 0 && files > conf.MaxFiles {
+		return &LimitExceededError{ChainID: gc.chainID, Limit: LimitFiles, Max: conf.MaxFiles, Actual: files}
+	}
+	return nil
+}
+
+// MetadataSizeError is returned when the metadata of a file
+// doesn't fit in a message of the max message size
+type MetadataSizeError struct {
+	ChainID  string
+	Filename string
+	Max      int
+	Actual   int
+}
+
+func (e *MetadataSizeError) Error() string {
+	return fmt.Sprintf("Metadata of file %s of channel %s exceeds the max message size: %d bytes > %d bytes", e.Filename, e.ChainID, e.Actual, e.Max)
+}
+
+// checkStateSize verifies the encoded size of the chain state carrying the given envelope,
+// the chain state must also fit in a message of the max message size
+func (gc *gossipChannel) checkStateSize(envp *protos.Envelope) error {
+	conf := gc.GetChannelConfig()
+	maxSize, limit := conf.MaxStateSize, LimitStateSize
+	if maxMsg := conf.MaxMessageSize - protos.MessageOverhead; conf.MaxMessageSize > 0 && (maxSize <= 0 || maxMsg < maxSize) {
+		maxSize, limit = maxMsg, LimitMessageSize
+	}
+	if maxSize <= 0 {
+		return nil
+	}
+
+	cs := &protos.ChainState{ChainId: gc.chainID, Envelope: envp, SeqNum: ^uint64(0)}
+	if size := proto.Size(cs); size > maxSize {
+		return &LimitExceededError{ChainID: gc.chainID, Limit: limit, Max: maxSize, Actual: size}
+	}
+	return nil
+}
+
+// checkMetadataSize verifies that the metadata of a file fits in a message
+func (gc *gossipChannel) checkMetadataSize(file *common.FileSyncInfo) error {
+	maxSize := gc.GetChannelConfig().MaxMessageSize - protos.MessageOverhead
+	if maxSize <= 0 || len(file.Metadata) <= maxSize {
+		return nil
+	}
+	return &MetadataSizeError{ChainID: gc.chainID, Filename: file.Path, Max: maxSize, Actual: len(file.Metadata)}
+}
@@ -9,7 +9,13 @@ package channel
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -37,31 +43,68 @@ type gossipChannel struct {
 	idMapper      identity.Identity
 	chainMac      common.ChainMac
 	members       map[string]common.PKIidType
+	memberSet     sync.Map     // member's PKI-ID string -> struct{}, mirrors members for the fsync providers which can't take the lock
+	leaderPKIid   atomic.Value // common.PKIidType, read by the fsync providers without holding the lock
+	propagation   atomic.Value // config.Propagation set at runtime, overriding the configured one
+	anchorPeers   map[string]struct{}
 	fileState     *fsyncState
+	fileVersions  *fileVersions
+	fileAcks      *fileAcks
+	contentHashes sync.Map // file path -> contentHash of the local copy
+	changeLog     *changeLog
+	journal       *stateJournal
+	recovery      RecoveryState
+	heartbeat     leaderHeartbeat
 	stopChan      chan struct{}
+	clock         lib.Clock
+	logger        logging.Logger
 }
 
 // NewGossipChannel creates a new gossip Channel
-func NewGossipChannel(pkiID common.PKIidType, chainMac common.ChainMac, chainID string, leader bool, adapter Adapter, idMapper identity.Identity) Channel {
+func NewGossipChannel(pkiID common.PKIidType, chainMac common.ChainMac, chainID string, leader bool, adapter Adapter, idMapper identity.Identity, logger logging.Logger) Channel {
 
 	gc := &gossipChannel{
-		incTime:  uint64(time.Now().UnixNano()),
-		seqNum:   uint64(0),
-		chainID:  chainID,
-		pkiID:    pkiID,
-		Adapter:  adapter,
-		leader:   leader,
-		fs:       adapter.GetChannelConfig().FileSystem,
-		chainMac: chainMac,
-		idMapper: idMapper,
-		stopChan: make(chan struct{}, 1),
-		members:  make(map[string]common.PKIidType),
-	}
+		incTime:     uint64(time.Now().UnixNano()),
+		seqNum:      uint64(0),
+		chainID:     chainID,
+		pkiID:       pkiID,
+		Adapter:     adapter,
+		leader:      leader,
+		fs:          config.ChannelFileSystem(adapter.GetChannelConfig().FileSystemFactory, adapter.GetChannelConfig().FileSystem, chainID),
+		chainMac:    chainMac,
+		idMapper:    idMapper,
+		stopChan:    make(chan struct{}, 1),
+		members:     make(map[string]common.PKIidType),
+		anchorPeers: make(map[string]struct{}),
+		clock:       lib.ClockOrSystem(adapter.GetChannelConfig().Clock),
+		logger:      logger,
+	}
+	gc.heartbeat.received = gc.clock.Now()
 	gc.fileState = newFSyncState(gc)
+	gc.fileVersions = newFileVersions()
+	gc.fileAcks = newFileAcks()
+	var logPath string
+	if dir := adapter.GetChannelConfig().ChangeLogDir; dir != "" {
+		logPath = filepath.Join(dir, chainMac.String()+".log")
+	}
+	var err error
+	if gc.changeLog, err = newChangeLog(logPath, logger); err != nil {
+		logger.Errorf("Channel %s: Failed loading the change log, it's kept in memory only: %s", chainMac, err)
+		gc.changeLog, _ = newChangeLog("", logger)
+	}
+	var journalPath string
+	if dir := adapter.GetChannelConfig().JournalDir; dir != "" && !leader {
+		journalPath = filepath.Join(dir, chainMac.String()+".journal")
+	}
+	if gc.journal, err = newStateJournal(journalPath, logger); err != nil {
+		logger.Errorf("Channel %s: Failed loading the journal, it's kept in memory only: %s", chainMac, err)
+		gc.journal, _ = newStateJournal("", logger)
+	}
 	gc.msgStore = lib.NewMessageStoreExpirable(
 		protos.NewRKSyncMessageComparator(),
 		lib.Noop,
 		gc.GetChannelConfig().RequestStateInfoInterval*100,
+		gc.clock,
 		nil,
 		nil,
 		lib.Noop)
@@ -70,6 +113,9 @@ func NewGossipChannel(pkiID common.PKIidType, chainMac common.ChainMac, chainID
 		go gc.periodicalPublishStateInfo(adapter.GetChannelConfig().PublishStateInfoInterval)
 	} else {
 		go gc.periodicalRequestStateInfo(adapter.GetChannelConfig().RequestStateInfoInterval)
+		if timeout := adapter.GetChannelConfig().LeaderTimeout; timeout > 0 {
+			go gc.periodicalCheckLeader(timeout)
+		}
 	}
 
 	return gc
@@ -89,19 +135,52 @@ func (gc *gossipChannel) InitializeWithChainState(chainState *protos.ChainState)
 	if err != nil {
 		return err
 	}
+	if gc.isStale(chainState, stateInfo) {
+		return errors.Errorf("ChainState of epoch %d is stale, the channel is at epoch %d", stateInfo.Epoch, gc.chainStateMsg.Epoch())
+	}
 
+	applied := func() {}
+	if !gc.leader {
+		if gc.chainStateMsg == nil {
+			gc.recoverFromJournal(chainState, stateInfo)
+		}
+		if applied, err = gc.journalChainState(chainState, stateInfo); err != nil {
+			return err
+		}
+	}
+
+	gc.setLeader(stateInfo.Leader)
 	for _, member := range stateInfo.Properties.Members {
-		gc.members[common.PKIidType(member).String()] = member
+		gc.addMember(member)
 	}
 
 	for _, file := range stateInfo.Properties.Files {
-		err := gc.fileState.createProvider(file.Path, file.Mode, file.Metadata, gc.leader)
+		err := gc.fileState.createProvider(file, gc.leader)
+		if err != nil {
+			return err
+		}
+	}
+
+	gc.changeLog.Lock()
+	lost := gc.changeLog.last() == nil
+	gc.changeLog.Unlock()
+	if gc.leader && lost {
+		// The chain state predates the change log, or the log was lost, its history restarts from the current state
+		files := make([]string, len(stateInfo.Properties.Files))
+		for i, file := range stateInfo.Properties.Files {
+			files[i] = file.Path
+		}
+		change, err := gc.recordChange(protos.ChangeRecord_INITIALIZE, stateInfo.Properties.Members, files, chainState.SeqNum)
 		if err != nil {
 			return err
 		}
+		chainState.Change = change
 	}
 
 	gc.chainStateMsg = chainState
+	gc.recordFileVersions(stateInfo.Properties.Files, chainState.SeqNum, false)
+	gc.connectToAnchorPeers(stateInfo.AnchorPeers)
+	applied()
 	return nil
 }
 
@@ -109,16 +188,22 @@ func (gc *gossipChannel) Initialize(chainID string, members []common.PKIidType,
 	gc.Lock()
 	defer gc.Unlock()
 
+	if err := gc.checkCountLimits(len(members), len(files)); err != nil {
+		return nil, err
+	}
+
 	stateInfo := &protos.ChainStateInfo{
 		Leader: gc.pkiID,
+		Epoch:  1,
 		Properties: &protos.Properties{
 			Members: make([][]byte, len(members)),
 			Files:   make([]*protos.File, len(files)),
 		},
 	}
 
+	gc.setLeader(gc.pkiID)
 	for i, member := range members {
-		gc.members[member.String()] = member
+		gc.addMember(member)
 		stateInfo.Properties.Members[i] = []byte(member)
 	}
 	for i, file := range files {
@@ -126,11 +211,17 @@ func (gc *gossipChannel) Initialize(chainID string, members []common.PKIidType,
 		if !ok {
 			return nil, errors.Errorf("Unknown file mode %s", file.Mode)
 		}
+		if err := gc.checkMetadataSize(file); err != nil {
+			return nil, err
+		}
 
 		stateInfo.Properties.Files[i] = &protos.File{
-			Path:     file.Path,
-			Mode:     protos.File_Mode(mode),
-			Metadata: file.Metadata,
+			Path:       file.Path,
+			Mode:       protos.File_Mode(mode),
+			Metadata:   file.Metadata,
+			Perm:       uint32(file.Perm.Perm()),
+			Attributes: toFileAttributes(file.Attributes),
+			Priority:   file.Priority,
 		}
 	}
 
@@ -145,26 +236,41 @@ func (gc *gossipChannel) Initialize(chainID string, members []common.PKIidType,
 		},
 	}
 
-	envp, err := stateInfoMsg.Sign(func(msg []byte) ([]byte, error) {
+	envp, err := stateInfoMsg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err := gc.checkStateSize(envp); err != nil {
+		return nil, err
+	}
+
+	seqNum := uint64(time.Now().UnixNano())
+	fnames := make([]string, len(files))
+	for i, file := range files {
+		fnames[i] = file.Path
+	}
+	change, err := gc.recordChange(protos.ChangeRecord_INITIALIZE, stateInfo.Properties.Members, fnames, seqNum)
+	if err != nil {
+		return nil, err
+	}
 
 	chainState := &protos.ChainState{
-		SeqNum:   uint64(time.Now().UnixNano()),
+		SeqNum:   seqNum,
 		ChainId:  chainID,
 		Envelope: envp,
+		Change:   change,
 	}
 	gc.chainStateMsg = chainState
 
 	for _, file := range stateInfo.Properties.Files {
-		err := gc.fileState.createProvider(file.Path, file.Mode, file.Metadata, gc.leader)
+		err := gc.fileState.createProvider(file, gc.leader)
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed creating file sync provider")
 		}
 	}
+	gc.recordFileVersions(stateInfo.Properties.Files, chainState.SeqNum, false)
 
 	return chainState, nil
 }
@@ -190,20 +296,54 @@ func (gc *gossipChannel) AddMember(member common.PKIidType) (*protos.ChainState,
 	}
 
 	stateInfo.Properties.Members = append(stateInfo.Properties.Members, member)
-	envp, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	if err := gc.checkCountLimits(len(stateInfo.Properties.Members), len(stateInfo.Properties.Files)); err != nil {
+		return nil, err
+	}
+
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err := gc.checkStateSize(envp); err != nil {
+		return nil, err
+	}
+
+	seqNum := uint64(time.Now().UnixNano())
+	change, err := gc.recordChange(protos.ChangeRecord_ADD_MEMBER, [][]byte{member}, nil, seqNum)
+	if err != nil {
+		return nil, err
+	}
 
 	gc.chainStateMsg.Envelope = envp
-	gc.chainStateMsg.SeqNum = uint64(time.Now().UnixNano())
-	gc.members[member.String()] = member
+	gc.chainStateMsg.SeqNum = seqNum
+	gc.chainStateMsg.Change = change
+	gc.addMember(member)
 
 	return gc.chainStateMsg, nil
 }
 
+// addMember adds a member to the channel, and emits a MemberAdded event if it's a new one
+func (gc *gossipChannel) addMember(member common.PKIidType) {
+	if _, exists := gc.members[member.String()]; exists {
+		return
+	}
+	gc.members[member.String()] = member
+	gc.memberSet.Store(member.String(), struct{}{})
+	gc.EmitEvent(common.MemberAdded{ChainMac: gc.chainMac, ChainID: gc.chainID, PKIID: member})
+}
+
+// setLeader records the leader of the channel, and emits a LeaderChanged event if it differs from the known one
+func (gc *gossipChannel) setLeader(leader common.PKIidType) {
+	if current, _ := gc.leaderPKIid.Load().(common.PKIidType); bytes.Equal(current, leader) {
+		return
+	}
+	gc.leaderPKIid.Store(leader)
+	gc.EmitEvent(common.LeaderChanged{ChainMac: gc.chainMac, ChainID: gc.chainID, Leader: leader})
+}
+
 func (gc *gossipChannel) RemoveMember(member common.PKIidType) (*protos.ChainState, error) {
 	gc.Lock()
 	defer gc.Unlock()
@@ -228,19 +368,28 @@ func (gc *gossipChannel) RemoveMember(member common.PKIidType) (*protos.ChainSta
 	}
 
 	if !found {
-		return gc.chainStateMsg, nil
+		return nil, errors.Wrapf(ErrNotMember, "Failed removing %s", member)
 	}
 
-	envp, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	seqNum := uint64(time.Now().UnixNano())
+	change, err := gc.recordChange(protos.ChangeRecord_REMOVE_MEMBER, [][]byte{member}, nil, seqNum)
+	if err != nil {
+		return nil, err
+	}
+
 	gc.chainStateMsg.Envelope = envp
-	gc.chainStateMsg.SeqNum = uint64(time.Now().UnixNano())
+	gc.chainStateMsg.SeqNum = seqNum
+	gc.chainStateMsg.Change = change
 	delete(gc.members, member.String())
+	gc.memberSet.Delete(member.String())
 
 	filterFunc := func(nm common.NetworkMember) bool {
 		return bytes.Equal(nm.PKIID, member)
@@ -265,7 +414,7 @@ func (gc *gossipChannel) AddFile(files []*common.FileSyncInfo) (*protos.ChainSta
 	var fnames []string
 	for _, file := range files {
 		if gc.fileState.lookupFSyncProviderByFilename(file.Path) != nil {
-			logging.Warningf("File %s has already exists", file.Path)
+			gc.logger.Warningf("File %s has already exists", file.Path)
 			continue
 		}
 
@@ -274,32 +423,60 @@ func (gc *gossipChannel) AddFile(files []*common.FileSyncInfo) (*protos.ChainSta
 			err = errors.Errorf("Unknow file mode: %s", file.Mode)
 			break
 		}
+		if err = gc.checkMetadataSize(file); err != nil {
+			break
+		}
 
-		f := &protos.File{Path: file.Path, Mode: protos.File_Mode(mode), Metadata: file.Metadata}
+		f := &protos.File{
+			Path:       file.Path,
+			Mode:       protos.File_Mode(mode),
+			Metadata:   file.Metadata,
+			Perm:       uint32(file.Perm.Perm()),
+			Attributes: toFileAttributes(file.Attributes),
+			Priority:   file.Priority,
+		}
 		stateInfo.Properties.Files = append(stateInfo.Properties.Files, f)
 
-		err = gc.fileState.createProvider(file.Path, protos.File_Mode(mode), file.Metadata, gc.leader)
+		err = gc.fileState.createProvider(f, gc.leader)
 		if err != nil {
 			break
 		}
 		fnames = append(fnames, file.Path)
 	}
 
+	if err == nil {
+		err = gc.checkCountLimits(len(stateInfo.Properties.Members), len(stateInfo.Properties.Files))
+	}
 	if err != nil {
 		gc.closeFSyncer(fnames)
 		return nil, err
 	}
+	if len(fnames) == 0 {
+		// The files are all in the channel already, its state is left as is
+		return gc.chainStateMsg, nil
+	}
 
-	envp, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
+	if err == nil {
+		err = gc.checkStateSize(envp)
+	}
+	seqNum := uint64(time.Now().UnixNano())
+	var change *protos.Envelope
+	if err == nil {
+		change, err = gc.recordChange(protos.ChangeRecord_ADD_FILE, nil, fnames, seqNum)
+	}
 	if err != nil {
 		gc.closeFSyncer(fnames)
 		return nil, err
 	}
 
 	gc.chainStateMsg.Envelope = envp
-	gc.chainStateMsg.SeqNum = uint64(time.Now().UnixNano())
+	gc.chainStateMsg.SeqNum = seqNum
+	gc.chainStateMsg.Change = change
+	gc.recordFileVersions(stateInfo.Properties.Files, gc.chainStateMsg.SeqNum, false)
 	return gc.chainStateMsg, nil
 }
 
@@ -324,30 +501,175 @@ func (gc *gossipChannel) RemoveFile(filenames []string) (*protos.ChainState, err
 			}
 		}
 	}
+	if len(fnames) == 0 {
+		// None of the files is in the channel, its state is left as is
+		return gc.chainStateMsg, nil
+	}
 
-	envp, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 	if err != nil {
 		return nil, err
 	}
+	seqNum := uint64(time.Now().UnixNano())
+	change, err := gc.recordChange(protos.ChangeRecord_REMOVE_FILE, nil, fnames, seqNum)
+	if err != nil {
+		return nil, err
+	}
+	gc.chainStateMsg.Change = change
 
 	gc.closeFSyncer(fnames)
+	gc.chainStateMsg.Envelope = envp
+	gc.chainStateMsg.SeqNum = seqNum
+
+	return gc.chainStateMsg, nil
+}
+
+// maxHashAttempts is the number of times a file is hashed before giving up on publishing it,
+// when it keeps changing while being hashed
+const maxHashAttempts = 3
+
+func (gc *gossipChannel) PublishFileVersion(filename string) (*protos.ChainState, error) {
+	for attempt := 0; attempt < maxHashAttempts; attempt++ {
+		// The file is hashed without holding the lock, reading a large file would block the channel meanwhile
+		file, err := gc.channelFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		digest, err := gc.fileContentHash(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed computing content hash of file %s", filename)
+		}
+
+		state, changed, err := gc.publishFileVersion(filename, digest)
+		if !changed {
+			return state, err
+		}
+		gc.logger.Debugf("Channel %s: File %s changed while being hashed", gc.chainMac, filename)
+	}
+	return nil, errors.Errorf("File %s kept changing while its content was hashed", filename)
+}
+
+// channelFile returns the file of the channel state with the given path
+func (gc *gossipChannel) channelFile(filename string) (*protos.File, error) {
+	gc.RLock()
+	defer gc.RUnlock()
+	if gc.chainStateMsg == nil {
+		return nil, errors.Errorf("Channel %s hasn't loaded its state", gc.chainMac)
+	}
+	stateInfo, err := gc.chainStateMsg.GetChainStateInfo()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stateInfo.Properties.Files {
+		if f.Path == filename {
+			return f, nil
+		}
+	}
+	return nil, errors.Errorf("File %s does not exist in the channel", filename)
+}
+
+// publishFileVersion publishes the digest of the file as a new version, unless it's unchanged.
+// It returns whether the file changed since the digest was computed, in which case nothing is published.
+func (gc *gossipChannel) publishFileVersion(filename string, digest []byte) (*protos.ChainState, bool, error) {
+	gc.Lock()
+	defer gc.Unlock()
+
+	msg, stateInfo, err := gc.validateChainLeader()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var file *protos.File
+	for _, f := range stateInfo.Properties.Files {
+		if f.Path == filename {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return nil, false, errors.Errorf("File %s does not exist in the channel", filename)
+	}
+
+	current, err := gc.isContentHashCurrent(file, digest)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "Failed computing content hash of file %s", filename)
+	}
+	if !current {
+		return nil, true, nil
+	}
+	if bytes.Equal(digest, file.ContentHash) {
+		gc.logger.Debugf("Channel %s: Content of file %s is unchanged, version %d", gc.chainMac, filename, file.Version)
+		return gc.chainStateMsg, false, nil
+	}
+
+	file.Version++
+	file.ContentHash = digest
+
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
+		return gc.idMapper.Sign(msg)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if err := gc.checkStateSize(envp); err != nil {
+		return nil, false, err
+	}
+
 	gc.chainStateMsg.Envelope = envp
 	gc.chainStateMsg.SeqNum = uint64(time.Now().UnixNano())
+	gc.recordFileVersions([]*protos.File{file}, gc.chainStateMsg.SeqNum, true)
+	gc.logger.Infof("Channel %s: Published file %s version %d", gc.chainMac, filename, file.Version)
+
+	return gc.chainStateMsg, false, nil
+}
+
+func (gc *gossipChannel) SetAnchorPeers(endpoints []string) (*protos.ChainState, error) {
+	for _, endpoint := range endpoints {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return nil, errors.Wrapf(err, "Invalid anchor peer %s", endpoint)
+		}
+	}
+
+	gc.Lock()
+	defer gc.Unlock()
+
+	msg, stateInfo, err := gc.validateChainLeader()
+	if err != nil {
+		return nil, err
+	}
+
+	stateInfo.AnchorPeers = endpoints
+	stateInfo.Epoch++
+	envp, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
+		return gc.idMapper.Sign(msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := gc.checkStateSize(envp); err != nil {
+		return nil, err
+	}
+
+	gc.chainStateMsg.Envelope = envp
+	gc.chainStateMsg.SeqNum = uint64(time.Now().UnixNano())
+	gc.connectToAnchorPeers(endpoints)
 
 	return gc.chainStateMsg, nil
 }
 
 func (gc *gossipChannel) HandleMessage(msg protos.ReceivedMessage) {
 	if !gc.verifyMsg(msg) {
-		logging.Warning("Failed verifying message:", msg.GetRKSyncMessage().RKSyncMessage)
+		gc.logger.Warning("Failed verifying message:", msg.GetRKSyncMessage().RKSyncMessage)
 		return
 	}
 
 	m := msg.GetRKSyncMessage()
 	if !m.IsChannelRestricted() {
-		logging.Warning("Got message", msg.GetRKSyncMessage(), "but it's not a per-channel message, discarding it")
+		gc.logger.Warning("Got message", msg.GetRKSyncMessage(), "but it's not a per-channel message, discarding it")
 		return
 	}
 
@@ -356,12 +678,7 @@ func (gc *gossipChannel) HandleMessage(msg protos.ReceivedMessage) {
 		if !gc.IsMemberInChan(member) {
 			go gc.sendLeaveChainMessage(&member)
 		} else if gc.msgStore.Add(m) {
-			resp, err := gc.createChainStateResponse()
-			if err != nil {
-				logging.Errorf("Failed creating ChainStateResponse message: %v", err)
-				return
-			}
-			msg.Respond(resp)
+			gc.reconcileChainState(msg, &member)
 		}
 		return
 	}
@@ -371,49 +688,88 @@ func (gc *gossipChannel) HandleMessage(msg protos.ReceivedMessage) {
 		return
 	}
 
+	if m.IsStateReq() {
+		gc.handleStateRequest(msg)
+		return
+	}
+
+	if m.IsStateRes() {
+		gc.handleStateResponse(m.RKSyncMessage, msg.GetConnectionInfo().ID)
+		return
+	}
+
+	if m.IsChangeLogReq() {
+		gc.handleChangeLogRequest(msg)
+		return
+	}
+
+	if m.IsChangeLogRes() {
+		gc.handleChangeLogResponse(m.RKSyncMessage, msg.GetConnectionInfo().ID)
+		return
+	}
+
+	if m.IsFileAck() {
+		gc.handleFileAck(msg)
+		return
+	}
+
 	if m.IsChainStateMsg() {
-		err := m.Verify(msg.GetConnectionInfo().ID, func(peerIdentity []byte, signature, message []byte) error {
-			return gc.idMapper.Verify(peerIdentity, signature, message)
-		})
-		if err != nil {
-			logging.Warningf("Channel %s: Failed validating ChainState message: %v", gc.chainMac, err)
+		// The leader publishes the same chain state periodically along with a heartbeat,
+		// which the gossip layer relays to the other members
+		heartbeat := gc.recordHeartbeat(m)
+		if heartbeat && gc.isHandled(m.GetState()) {
 			return
 		}
 
-		err = gc.updateChainState(m.GetState(), msg.GetConnectionInfo().ID)
+		// The heartbeats were verified as signed by the leader
+		if !heartbeat {
+			err := m.Verify(msg.GetConnectionInfo().ID, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+				return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
+			})
+			if err != nil {
+				gc.ReportMisbehavior(msg.GetConnectionInfo().ID, common.InvalidSignature)
+				gc.logger.Warningf("Channel %s: Failed validating ChainState message: %v", gc.chainMac, err)
+				return
+			}
+		}
+
+		err := gc.updateChainState(m.GetState(), msg.GetConnectionInfo().ID)
 		if err == nil {
-			gc.Forward(msg)
+			gc.stateHandled(m.GetState())
+			gc.Forward(msg, gc.Propagation())
+			gc.DeMultiplex(m)
 		} else {
-			logging.Errorf("Failed updating chain state message: %s", err)
+			gc.logger.Errorf("Failed updating chain state message: %s", err)
 		}
 	}
 
 	if m.IsDataMsg() || m.IsDataReq() {
 		if m.IsDataReq() {
 			if !gc.IsMemberInChan(common.NetworkMember{PKIID: msg.GetConnectionInfo().ID}) {
-				logging.Warningf("Received Data request message from %s, not member in channel %s", msg.GetConnectionInfo().ID, gc.chainMac)
+				gc.logger.Warningf("Received Data request message from %s, not member in channel %s", msg.GetConnectionInfo().ID, gc.chainMac)
 				return
 			}
 		}
 
 		if m.IsDataMsg() {
 			if gc.leader {
-				logging.Infof("Channel %s: Leader does not need to handle data message", gc.chainMac)
+				gc.logger.Infof("Channel %s: Leader does not need to handle data message", gc.chainMac)
 				return
 			}
 
 			if m.GetDataMsg().Payload == nil {
-				logging.Warningf("Payload is empty, got it from %s", msg.GetConnectionInfo().ID)
+				gc.logger.Warningf("Payload is empty, got it from %s", msg.GetConnectionInfo().ID)
 				return
 			}
 		}
 
-		verifier := func(peerIdentity []byte, signature, message []byte) error {
-			return gc.idMapper.Verify(peerIdentity, signature, message)
+		verifier := func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+			return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
 		}
 		err := m.Verify(msg.GetConnectionInfo().ID, verifier)
 		if err != nil {
-			logging.Errorf("Failed verifying message signature: %s, got it from %s", err, msg.GetConnectionInfo().ID)
+			gc.ReportMisbehavior(msg.GetConnectionInfo().ID, common.InvalidSignature)
+			gc.logger.Errorf("Failed verifying message signature: %s, got it from %s", err, msg.GetConnectionInfo().ID)
 			return
 		}
 
@@ -433,36 +789,124 @@ func (gc *gossipChannel) IsMemberInChan(member common.NetworkMember) bool {
 	return false
 }
 
+func (gc *gossipChannel) Transferring() bool {
+	return gc.fileState.transferring()
+}
+
+func (gc *gossipChannel) FileProgress() ([]fsync.Progress, error) {
+	return gc.fileState.progress()
+}
+
+func (gc *gossipChannel) FileStates() ([]FileState, error) {
+	chainState := gc.Self()
+	if chainState == nil {
+		return nil, errors.Errorf("Channel %s hasn't loaded its state", gc.chainMac)
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := gc.fileState.progress()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]fsync.Progress, len(progress))
+	for _, p := range progress {
+		byName[p.Filename] = p
+	}
+
+	states := make([]FileState, 0, len(stateInfo.Properties.Files))
+	for _, file := range stateInfo.Properties.Files {
+		p, exists := byName[file.Path]
+		if !exists {
+			continue
+		}
+
+		state := FileState{Progress: p, Metadata: file.Metadata, Version: file.Version}
+		if len(file.ContentHash) > 0 {
+			digest, err := gc.cachedContentHash(file)
+			if err != nil {
+				gc.logger.Warningf("Channel %s: Failed computing content hash of file %s: %s", gc.chainMac, file.Path, err)
+			} else if bytes.Equal(digest, file.ContentHash) {
+				state.Digest = DigestMatch
+			} else {
+				state.Digest = DigestMismatch
+			}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (gc *gossipChannel) FileVersions(filename string) ([]FileVersion, error) {
+	return gc.fileVersions.versions(filename)
+}
+
+func (gc *gossipChannel) PinFileVersion(filename string, version uint64) error {
+	return gc.fileVersions.pin(filename, version, true)
+}
+
+func (gc *gossipChannel) UnpinFileVersion(filename string, version uint64) error {
+	return gc.fileVersions.pin(filename, version, false)
+}
+
 func (gc *gossipChannel) Stop() {
 	gc.stopChan <- struct{}{}
 	gc.msgStore.Stop()
 	gc.fileState.stop()
 }
 
+func (gc *gossipChannel) Close() {
+	// The leader's files are the source of the channel, they are never orphaned
+	var orphans []config.FileMeta
+	if !gc.leader {
+		orphans = gc.fileState.fileMetas()
+	}
+	gc.Stop()
+	if len(orphans) > 0 {
+		gc.OrphanFiles(gc.chainID, orphans)
+	}
+}
+
 func (gc *gossipChannel) handleChainStateResponse(m *protos.RKSyncMessage, sender common.PKIidType) {
-	envelope := m.GetStatePullResponse().Element
+	gc.applyChainStateElement(m.ChainMac, m.GetStatePullResponse().Element, sender)
+}
+
+// applyChainStateElement updates the chain state with the ChainState message of a response, signed by the sender
+func (gc *gossipChannel) applyChainStateElement(chainMac common.ChainMac, envelope *protos.Envelope, sender common.PKIidType) {
 	chainState, err := envelope.ToRKSyncMessage()
 	if err != nil {
-		logging.Warningf("Channel %s: ChainState contains an invalid message: %+v", gc.chainMac, err)
+		gc.logger.Warningf("Channel %s: ChainState contains an invalid message: %+v", gc.chainMac, err)
 		return
 	}
 
 	if !chainState.IsChainStateMsg() {
-		logging.Warningf("Channel %s: Element of ChainStateResponse isn't a ChainState: %s, message sent from %s", gc.chainMac, chainState, sender)
+		gc.logger.Warningf("Channel %s: Element of ChainStateResponse isn't a ChainState: %s, message sent from %s", gc.chainMac, chainState, sender)
 		return
 	}
 
 	cs := chainState.GetState()
-	if !bytes.Equal(m.ChainMac, gc.chainMac) {
-		logging.Warningf("Channel %s: ChainState message has an invalid MAC, expected %s, got %s, sent from %s", gc.chainMac, gc.chainMac, m.ChainMac, sender)
+	if !bytes.Equal(chainMac, gc.chainMac) {
+		gc.logger.Warningf("Channel %s: ChainState message has an invalid MAC, expected %s, got %s, sent from %s", gc.chainMac, gc.chainMac, chainMac, sender)
 		return
 	}
 
-	err = chainState.Verify(sender, func(peerIdentity []byte, signature, message []byte) error {
-		return gc.idMapper.Verify(peerIdentity, signature, message)
+	// The epoch isn't verified yet, a forged one can only get the state ignored. The sequence number
+	// is only signed by the sender, the states are ordered by the epoch signed by the leader.
+	if self, epoch := gc.Self(), chainState.StateEpoch(); self != nil && epoch != 0 {
+		if selfEpoch := self.Epoch(); epoch <= selfEpoch {
+			gc.logger.Debugf("Channel %s: Ignoring ChainState of epoch %d sent from %s, already at epoch %d", gc.chainMac, epoch, sender, selfEpoch)
+			return
+		}
+	}
+
+	err = chainState.Verify(sender, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
 	})
 	if err != nil {
-		logging.Warningf("Channel %s: Failed validating ChainState message: %v, sent from: %s", gc.chainMac, err, sender)
+		gc.ReportMisbehavior(sender, common.InvalidSignature)
+		gc.logger.Warningf("Channel %s: Failed validating ChainState message: %v, sent from: %s", gc.chainMac, err, sender)
 		return
 	}
 
@@ -471,57 +915,188 @@ func (gc *gossipChannel) handleChainStateResponse(m *protos.RKSyncMessage, sende
 
 func (gc *gossipChannel) updateChainState(msg *protos.ChainState, sender common.PKIidType) error {
 	if gc.leader {
-		logging.Infof("Channel %s: Leader does not need to update chain state", gc.chainMac)
+		gc.logger.Infof("Channel %s: Leader does not need to update chain state", gc.chainMac)
 		return nil
 	}
 	chainStateInfo, err := msg.Envelope.ToRKSyncMessage()
 	if err != nil {
-		logging.Warningf("Channel %s: ChainState's envelope contains an invalid message: %+v", gc.chainMac, err)
+		gc.logger.Warningf("Channel %s: ChainState's envelope contains an invalid message: %+v", gc.chainMac, err)
 		return err
 	}
 
 	if !chainStateInfo.IsStateInfoMsg() {
-		logging.Warningf("Channel %s: Element of ChainState isn't a ChainStateInfo: %s, message sent from %s", gc.chainMac, chainStateInfo, sender)
+		gc.logger.Warningf("Channel %s: Element of ChainState isn't a ChainStateInfo: %s, message sent from %s", gc.chainMac, chainStateInfo, sender)
 		return errors.New("Element of ChainState isn't a ChainStateInfo")
 	}
 
 	csi := chainStateInfo.GetStateInfo()
-	err = chainStateInfo.Verify(csi.Leader, func(peerIdentity []byte, signature, message []byte) error {
-		return gc.idMapper.Verify(peerIdentity, signature, message)
+	err = chainStateInfo.Verify(csi.Leader, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
 	})
 	if err != nil {
-		logging.Warningf("Channel %s: Failed validating ChainStateInfo message: %v, sent from: %s", gc.chainMac, err, sender)
+		gc.logger.Warningf("Channel %s: Failed validating ChainStateInfo message: %v, sent from: %s", gc.chainMac, err, sender)
 		return err
 	}
 
+	if msg.Change != nil {
+		missing, err := gc.applyChange(msg.Change, csi.Leader)
+		if err != nil {
+			gc.logger.Warningf("Channel %s: Failed applying change record sent from %s: %s", gc.chainMac, sender, err)
+		} else if missing {
+			gc.changeLog.Lock()
+			next := gc.changeLog.next()
+			gc.changeLog.Unlock()
+			go gc.requestChanges(next, sender)
+		}
+	}
+
 	gc.Lock()
 	defer gc.Unlock()
 
+	// Chain states may be handled concurrently, a newer one may have been applied meanwhile,
+	// and an old one may be replayed
+	if gc.isStale(msg, csi) {
+		gc.logger.Debugf("Channel %s: Ignoring ChainState of epoch %d sent from %s, already at epoch %d", gc.chainMac, csi.Epoch, sender, gc.chainStateMsg.Epoch())
+		return nil
+	}
+	applied, err := gc.journalChainState(msg, csi)
+	if err != nil {
+		return err
+	}
 	gc.chainStateMsg = msg
+	gc.setLeader(csi.Leader)
+	previous := gc.members
 	gc.members = make(map[string]common.PKIidType)
 	for _, member := range csi.Properties.Members {
-		gc.members[common.PKIidType(member).String()] = member
+		if _, exists := previous[common.PKIidType(member).String()]; exists {
+			gc.members[common.PKIidType(member).String()] = member
+			continue
+		}
+		gc.addMember(member)
+	}
+	for key := range previous {
+		if _, exists := gc.members[key]; !exists {
+			gc.memberSet.Delete(key)
+		}
 	}
+	gc.connectToAnchorPeers(csi.AnchorPeers)
 
+	var orphans []config.FileMeta
 	fnames := gc.fileState.snapshot()
 	for _, fname := range fnames {
 		has := contains(csi.Properties.Files, fname)
 		if !has {
+			if fp := gc.fileState.lookupFSyncProviderByFilename(fname); fp != nil {
+				orphans = append(orphans, fp.FileMeta())
+			}
 			gc.fileState.closeFSyncProvider(fname)
+			gc.fileVersions.forget(fname)
 			gc.Unregister(fsync.GenerateMAC(gc.chainMac, fname))
 		}
 	}
+	if len(orphans) > 0 {
+		gc.OrphanFiles(gc.chainID, orphans)
+	}
 	for _, file := range csi.Properties.Files {
-		err := gc.fileState.createProvider(file.Path, file.Mode, file.Metadata, gc.leader)
+		err := gc.fileState.createProvider(file, gc.leader)
 		if err != nil {
 			return errors.Wrapf(err, "Failed creating file sync provider for %s", file.Path)
 		}
 	}
+	gc.recordFileVersions(csi.Properties.Files, msg.SeqNum, true)
+	applied()
 
 	return nil
 }
 
+// isStale returns whether the chain state is older than the current one. The states are ordered
+// by the epoch signed by the leader, and the current state is the only one of its epoch applied again.
+// The leader sets the sequence number along with the epoch, a state of the current epoch carrying
+// another one was altered by a relaying peer. The unsigned sequence number orders the states predating the epochs.
+// It should be invoked with the channel's lock held.
+func (gc *gossipChannel) isStale(state *protos.ChainState, info *protos.ChainStateInfo) bool {
+	if gc.chainStateMsg == nil {
+		return false
+	}
+	epoch := gc.chainStateMsg.Epoch()
+	switch {
+	case info.Epoch != epoch:
+		return info.Epoch < epoch
+	case epoch == 0:
+		return state.SeqNum < gc.chainStateMsg.SeqNum
+	default:
+		return state.SeqNum != gc.chainStateMsg.SeqNum || !bytes.Equal(state.Envelope.Payload, gc.chainStateMsg.Envelope.Payload)
+	}
+}
+
+// compareChainState compares the chain state of the given epoch and sequence number with the local one,
+// it returns -1 if it's older, 1 if it's newer and 0 otherwise. The states are ordered by their epochs,
+// the sequence numbers are only compared if either state predates the epochs.
+func compareChainState(epoch, seqNum uint64, local *protos.ChainState) int {
+	localEpoch := local.Epoch()
+	if epoch == 0 || localEpoch == 0 {
+		epoch, localEpoch = seqNum, local.SeqNum
+	}
+	switch {
+	case epoch < localEpoch:
+		return -1
+	case epoch > localEpoch:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// reconcileChainState compares the epoch of the requester's chain state with the local one.
+// The local chain state is sent back if it's newer, and the requester's one is pulled if it's older.
+func (gc *gossipChannel) reconcileChainState(msg protos.ReceivedMessage, member *common.NetworkMember) {
+	self := gc.Self()
+	if self == nil {
+		return
+	}
+
+	req := msg.GetRKSyncMessage().GetStatePullRequest()
+	switch compareChainState(req.Epoch, req.SeqNum, self) {
+	case -1:
+		resp, err := gc.createChainStateResponse()
+		if err != nil {
+			gc.logger.Errorf("Failed creating ChainStateResponse message: %v", err)
+			return
+		}
+		msg.Respond(resp)
+	case 1:
+		if gc.leader {
+			return
+		}
+		pull, err := gc.createStateInfoRequest()
+		if err != nil {
+			gc.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
+			return
+		}
+		gc.Send(pull, member)
+	}
+}
+
 func (gc *gossipChannel) createChainStateResponse() (*protos.RKSyncMessage, error) {
+	element, err := gc.signedChainState()
+	if err != nil {
+		return nil, err
+	}
+
+	return &protos.RKSyncMessage{
+		ChainMac: gc.chainMac,
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Nonce:    0,
+		Content: &protos.RKSyncMessage_StatePullResponse{
+			StatePullResponse: &protos.ChainStatePullResponse{
+				Element: element,
+			},
+		},
+	}, nil
+}
+
+// signedChainState returns the envelope of a ChainState message holding the chain state, signed by the peer
+func (gc *gossipChannel) signedChainState() (*protos.Envelope, error) {
 	gc.RLock()
 	defer gc.RUnlock()
 	element := &protos.SignedRKSyncMessage{
@@ -535,44 +1110,34 @@ func (gc *gossipChannel) createChainStateResponse() (*protos.RKSyncMessage, erro
 		},
 	}
 
-	_, err := element.Sign(func(msg []byte) ([]byte, error) {
+	_, err := element.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	return &protos.RKSyncMessage{
-		ChainMac: gc.chainMac,
-		Tag:      protos.RKSyncMessage_CHAN_ONLY,
-		Nonce:    0,
-		Content: &protos.RKSyncMessage_StatePullResponse{
-			StatePullResponse: &protos.ChainStatePullResponse{
-				Element: element.Envelope,
-			},
-		},
-	}, nil
+	return element.Envelope, nil
 }
 
 func (gc *gossipChannel) verifyMsg(msg protos.ReceivedMessage) bool {
 	if msg == nil {
-		logging.Warning("Message is nil")
+		gc.logger.Warning("Message is nil")
 		return false
 	}
 
 	m := msg.GetRKSyncMessage()
 	if m == nil {
-		logging.Warning("Message content is empty")
+		gc.logger.Warning("Message content is empty")
 		return false
 	}
 
 	if msg.GetConnectionInfo().ID == nil {
-		logging.Warning("Message has nil PKI-ID")
+		gc.logger.Warning("Message has nil PKI-ID")
 		return false
 	}
 
 	if !bytes.Equal(gc.chainMac, m.ChainMac) {
-		logging.Warning("Message contains wrong channel MAC (", m.ChainMac, "), expected", gc.chainMac)
+		gc.logger.Warning("Message contains wrong channel MAC (", m.ChainMac, "), expected", gc.chainMac)
 		return false
 	}
 
@@ -582,7 +1147,7 @@ func (gc *gossipChannel) verifyMsg(msg protos.ReceivedMessage) bool {
 func (gc *gossipChannel) periodicalPublishStateInfo(dur time.Duration) {
 	for {
 		select {
-		case <-time.After(dur):
+		case <-gc.clock.After(dur):
 			gc.publishStateInfo()
 		case s := <-gc.stopChan:
 			gc.stopChan <- s
@@ -593,7 +1158,11 @@ func (gc *gossipChannel) periodicalPublishStateInfo(dur time.Duration) {
 
 func (gc *gossipChannel) publishStateInfo() {
 	gc.RLock()
-	chainStateMsg := gc.chainStateMsg
+	if gc.chainStateMsg == nil {
+		gc.RUnlock()
+		return
+	}
+	chainStateMsg := gc.stampHeartbeat(gc.chainStateMsg)
 	gc.RUnlock()
 
 	msg := &protos.SignedRKSyncMessage{
@@ -607,22 +1176,22 @@ func (gc *gossipChannel) publishStateInfo() {
 		},
 	}
 
-	_, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	_, err := msg.SignWithAlgorithm(gc.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return gc.idMapper.Sign(msg)
 	})
 
 	if err != nil {
-		logging.Errorf("Failed signing ChainState message: %v", err)
+		gc.logger.Errorf("Failed signing ChainState message: %v", err)
 		return
 	}
 
-	gc.Gossip(msg)
+	gc.Gossip(msg, gc.Propagation())
 }
 
 func (gc *gossipChannel) periodicalRequestStateInfo(dur time.Duration) {
 	for {
 		select {
-		case <-time.After(dur):
+		case <-gc.clock.After(dur):
 			gc.requestStateInfo()
 		case s := <-gc.stopChan:
 			gc.stopChan <- s
@@ -634,14 +1203,15 @@ func (gc *gossipChannel) periodicalRequestStateInfo(dur time.Duration) {
 func (gc *gossipChannel) requestStateInfo() {
 	req, err := gc.createStateInfoRequest()
 	if err != nil {
-		logging.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
+		gc.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", err)
 		return
 	}
 
 	filters := filter.CombineRoutingFilters(gc.IsMemberInChan, func(member common.NetworkMember) bool {
 		return gc.pkiID.IsNotSameFilter(member.PKIID)
 	})
-	endpoints := filter.SelectPeers(gc.GetChannelConfig().PullPeerNum, gc.GetMembership(), filters)
+	conf := gc.GetChannelConfig()
+	endpoints := filter.SelectPeersAcrossZones(conf.PullPeerNum, gc.GetMembership(), filters, conf.Zone)
 	gc.Send(req, endpoints...)
 }
 
@@ -651,6 +1221,12 @@ func (gc *gossipChannel) createStateInfoRequest() (*protos.SignedRKSyncMessage,
 	gc.seqNum++
 	seq := gc.seqNum
 
+	var stateSeqNum, epoch uint64
+	if gc.chainStateMsg != nil {
+		stateSeqNum = gc.chainStateMsg.SeqNum
+		epoch = gc.chainStateMsg.Epoch()
+	}
+
 	return (&protos.RKSyncMessage{
 		Tag:      protos.RKSyncMessage_CHAN_ONLY,
 		Nonce:    0,
@@ -661,6 +1237,8 @@ func (gc *gossipChannel) createStateInfoRequest() (*protos.SignedRKSyncMessage,
 					IncNum: gc.incTime,
 					SeqNum: seq,
 				},
+				SeqNum: stateSeqNum,
+				Epoch:  epoch,
 			},
 		},
 	}).NoopSign()
@@ -669,13 +1247,13 @@ func (gc *gossipChannel) createStateInfoRequest() (*protos.SignedRKSyncMessage,
 func (gc *gossipChannel) sendLeaveChainMessage(member *common.NetworkMember) {
 	msg, err := gc.CreateLeaveChainMessage(gc.chainMac)
 	if err != nil {
-		logging.Errorf("Failed creating LeaveChainMessage: %s", err)
+		gc.logger.Errorf("Failed creating LeaveChainMessage: %s", err)
 		return
 	}
 
 	err = gc.SendWithAck(msg, time.Second*5, 1, member)
 	if err != nil {
-		logging.Errorf("Failed sending LeaveChainMessage to %s: %s", member, err)
+		gc.logger.Errorf("Failed sending LeaveChainMessage to %s: %s", member, err)
 	}
 }
 
@@ -688,19 +1266,136 @@ func (gc *gossipChannel) validateChainLeader() (*protos.SignedRKSyncMessage, *pr
 	if !msg.IsStateInfoMsg() {
 		return nil, nil, errors.New("Channel state message isn't well formatted")
 	}
-	err = msg.Verify(gc.pkiID, func(peerIdentity []byte, signature, message []byte) error {
-		return gc.idMapper.Verify(peerIdentity, signature, message)
+	stateInfo := msg.GetStateInfo()
+	if !bytes.Equal(gc.pkiID, stateInfo.Leader) {
+		return nil, nil, errors.WithMessage(ErrNotLeader, "Only the channel leader can modify the channel state")
+	}
+
+	err = msg.Verify(gc.pkiID, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return gc.idMapper.Verify(peerIdentity, algorithm, signature, message)
 	})
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "Failed verifying ChainStateInfo message")
 	}
 
-	stateInfo := msg.GetStateInfo()
-	if !bytes.Equal(gc.pkiID, stateInfo.Leader) {
-		return nil, nil, errors.New("Only the channel leader can modify the channel state")
+	return msg, stateInfo, nil
+}
+
+// connectToAnchorPeers connects to the anchor peers that are neither
+// alive members of the network nor connected to already.
+// It should be invoked with the channel's lock held.
+func (gc *gossipChannel) connectToAnchorPeers(endpoints []string) {
+	anchors := make(map[string]struct{}, len(endpoints))
+	for _, endpoint := range endpoints {
+		anchors[endpoint] = struct{}{}
+	}
+	for endpoint := range gc.anchorPeers {
+		if _, exists := anchors[endpoint]; !exists {
+			delete(gc.anchorPeers, endpoint)
+		}
 	}
 
-	return msg, stateInfo, nil
+	alive := make(map[string]struct{})
+	for _, member := range gc.GetMembership() {
+		alive[member.Endpoint] = struct{}{}
+	}
+
+	for endpoint := range anchors {
+		if _, exists := gc.anchorPeers[endpoint]; exists {
+			continue
+		}
+		gc.anchorPeers[endpoint] = struct{}{}
+		if _, exists := alive[endpoint]; exists {
+			continue
+		}
+
+		gc.logger.Infof("Channel %s: Connecting to anchor peer %s", gc.chainMac, endpoint)
+		gc.ConnectToAnchorPeer(endpoint)
+	}
+}
+
+// contentHash is the digest of a local file, valid as long as its size and modification time are unchanged
+type contentHash struct {
+	size    int64
+	modTime time.Time
+	digest  []byte
+}
+
+// cachedContentHash returns the digest of the local copy of the file, which is only hashed
+// again once its size or modification time changed
+func (gc *gossipChannel) cachedContentHash(file *protos.File) ([]byte, error) {
+	fi, err := gc.fs.Stat(gc.chainID, config.FileMeta{Name: file.Path, Metadata: file.Metadata, Leader: gc.leader})
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := gc.contentHashes.Load(file.Path); ok {
+		cached := v.(contentHash)
+		if cached.size == fi.Size() && cached.modTime.Equal(fi.ModTime()) {
+			return cached.digest, nil
+		}
+	}
+	return gc.fileContentHash(file)
+}
+
+// isContentHashCurrent returns whether the digest is the one of the local copy of the file as it is now,
+// its size and modification time are compared with the ones it was hashed with
+func (gc *gossipChannel) isContentHashCurrent(file *protos.File, digest []byte) (bool, error) {
+	fi, err := gc.fs.Stat(gc.chainID, config.FileMeta{Name: file.Path, Metadata: file.Metadata, Leader: gc.leader})
+	if err != nil {
+		return false, err
+	}
+	v, ok := gc.contentHashes.Load(file.Path)
+	if !ok {
+		return false, nil
+	}
+	cached := v.(contentHash)
+	return cached.size == fi.Size() && cached.modTime.Equal(fi.ModTime()) && bytes.Equal(cached.digest, digest), nil
+}
+
+func (gc *gossipChannel) fileContentHash(file *protos.File) ([]byte, error) {
+	fmeta := config.FileMeta{Name: file.Path, Metadata: file.Metadata, Leader: gc.leader}
+	fi, err := gc.fs.Stat(gc.chainID, fmeta)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := gc.fs.OpenFile(gc.chainID, fmeta, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, fi.Size())); err != nil {
+		return nil, err
+	}
+	digest := h.Sum(nil)
+	gc.contentHashes.Store(file.Path, contentHash{size: fi.Size(), modTime: fi.ModTime(), digest: digest})
+	return digest, nil
+}
+
+// recordFileVersions records the versions of the files carried by the chain state of the given sequence number.
+// If notify is set, a FileVersionChanged event is emitted for the known files whose version increased.
+func (gc *gossipChannel) recordFileVersions(files []*protos.File, seqNum uint64, notify bool) {
+	for _, file := range files {
+		previous, known := gc.fileVersions.record(file, seqNum)
+		if !notify || !known || file.Version <= previous {
+			continue
+		}
+
+		missed := file.Version > previous+1
+		if missed {
+			gc.logger.Warningf("Channel %s: File %s jumped from version %d to %d, the versions in between were missed", gc.chainMac, file.Path, previous, file.Version)
+		}
+		gc.EmitEvent(common.FileVersionChanged{
+			ChainMac: gc.chainMac,
+			ChainID:  gc.chainID,
+			Filename: file.Path,
+			Version:  file.Version,
+			Previous: previous,
+			Missed:   missed,
+		})
+	}
 }
 
 func (gc *gossipChannel) closeFSyncer(fnames []string) {
@@ -710,6 +1405,7 @@ func (gc *gossipChannel) closeFSyncer(fnames []string) {
 
 	for _, fname := range fnames {
 		gc.fileState.closeFSyncProvider(fname)
+		gc.fileVersions.forget(fname)
 		gc.Unregister(fsync.GenerateMAC(gc.chainMac, fname))
 	}
 }
@@ -0,0 +1,500 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/lib"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/rkcloudchain/rksync/tests/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type adapterMock struct {
+	sync.Mutex
+	fs           config.FileSystem
+	logDir       string
+	journalDir   string
+	propagations map[string]config.Propagation
+	maxMsgSize   int
+	pullInterval time.Duration
+	clock        lib.Clock
+	sent         []*protos.SignedRKSyncMessage
+	events       []common.Event
+}
+
+func (a *adapterMock) GetChannelConfig() Config {
+	a.Lock()
+	defer a.Unlock()
+	pullInterval := a.pullInterval
+	if pullInterval == 0 {
+		pullInterval = time.Hour
+	}
+	return Config{
+		FileSystem:                  a.fs,
+		PublishStateInfoInterval:    time.Hour,
+		PullPeerNum:                 3,
+		PullInterval:                pullInterval,
+		RequestStateInfoInterval:    time.Hour,
+		StateInfoCacheSweepInterval: pullInterval * 5,
+		MaxMembers:                  1000,
+		MaxFiles:                    1000,
+		MaxStateSize:                4 * 1024 * 1024,
+		MaxMessageSize:              a.maxMsgSize,
+		ChangeLogDir:                a.logDir,
+		JournalDir:                  a.journalDir,
+		Propagations:                a.propagations,
+		Clock:                       a.clock,
+	}
+}
+
+func (a *adapterMock) setPullInterval(d time.Duration) {
+	a.Lock()
+	defer a.Unlock()
+	a.pullInterval = d
+}
+
+func (a *adapterMock) setMaxMessageSize(size int) {
+	a.Lock()
+	defer a.Unlock()
+	a.maxMsgSize = size
+}
+
+func (a *adapterMock) Send(message *protos.SignedRKSyncMessage, peers ...*common.NetworkMember) {
+	a.Lock()
+	defer a.Unlock()
+	a.sent = append(a.sent, message)
+}
+
+func (a *adapterMock) sentMessages() []*protos.SignedRKSyncMessage {
+	a.Lock()
+	defer a.Unlock()
+	return append([]*protos.SignedRKSyncMessage{}, a.sent...)
+}
+
+func (a *adapterMock) Gossip(message *protos.SignedRKSyncMessage, propagation config.Propagation) {}
+func (a *adapterMock) Forward(message protos.ReceivedMessage, propagation config.Propagation)     {}
+func (a *adapterMock) SendWithAck(message *protos.SignedRKSyncMessage, timeout time.Duration, minAck int, peers ...*common.NetworkMember) error {
+	return nil
+}
+func (a *adapterMock) GetMembership() []common.NetworkMember { return nil }
+func (a *adapterMock) Lookup(pkiID common.PKIidType) *common.NetworkMember {
+	return &common.NetworkMember{PKIID: pkiID}
+}
+func (a *adapterMock) DeMultiplex(interface{}) {}
+func (a *adapterMock) Unregister([]byte)       {}
+func (a *adapterMock) Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage) {
+	return nil, nil
+}
+func (a *adapterMock) CreateLeaveChainMessage(chainMac common.ChainMac) (*protos.SignedRKSyncMessage, error) {
+	return nil, nil
+}
+func (a *adapterMock) ConnectToAnchorPeer(endpoint string) {}
+
+func (a *adapterMock) OrphanFiles(chainID string, files []config.FileMeta) {}
+
+func (a *adapterMock) ReportMisbehavior(pkiID common.PKIidType, kind common.Misbehavior) {}
+
+func (a *adapterMock) EmitEvent(event common.Event) {
+	a.Lock()
+	defer a.Unlock()
+	a.events = append(a.events, event)
+}
+
+func (a *adapterMock) emittedEvents() []common.Event {
+	a.Lock()
+	defer a.Unlock()
+	return append([]common.Event{}, a.events...)
+}
+
+type receivedMessageMock struct {
+	msg       *protos.SignedRKSyncMessage
+	sender    common.PKIidType
+	responses []*protos.RKSyncMessage
+}
+
+func (m *receivedMessageMock) Respond(msg *protos.RKSyncMessage) {
+	m.responses = append(m.responses, msg)
+}
+
+func (m *receivedMessageMock) GetRKSyncMessage() *protos.SignedRKSyncMessage {
+	return m.msg
+}
+
+func (m *receivedMessageMock) GetSourceEnvelope() *protos.Envelope {
+	return m.msg.Envelope
+}
+
+func (m *receivedMessageMock) GetConnectionInfo() *protos.ConnectionInfo {
+	return &protos.ConnectionInfo{ID: m.sender}
+}
+
+func (m *receivedMessageMock) Ack(err error) {}
+
+type peer struct {
+	pkiID    common.PKIidType
+	identity common.PeerIdentityType
+	idMapper identity.Identity
+	adapter  *adapterMock
+}
+
+func createPeers(t *testing.T, num int) []*peer {
+	peers := make([]*peer, num)
+	for i := range peers {
+		home, err := filepath.Abs(fmt.Sprintf("../tests/fixtures/identity/peer%d", i))
+		require.NoError(t, err)
+		idCfg := &config.IdentityConfig{ID: fmt.Sprintf("peer%d.org%d", i, i+1)}
+		require.NoError(t, idCfg.MakeFilesAbs(home))
+
+		selfIdentity, err := util.GetIdentity(idCfg)
+		require.NoError(t, err)
+		idMapper, err := identity.NewIdentity(idCfg, selfIdentity, func(common.PKIidType) {}, logging.Default())
+		require.NoError(t, err)
+
+		// The first peer serves the files of the test data, the others store their copies in a temporary directory
+		dir := t.TempDir()
+		if i == 0 {
+			dir, err = filepath.Abs("../tests/testdata/peer0")
+			require.NoError(t, err)
+		}
+		peers[i] = &peer{
+			pkiID:    idMapper.GetPKIidOfCert(selfIdentity),
+			identity: selfIdentity,
+			idMapper: idMapper,
+			adapter:  &adapterMock{fs: mocks.NewFSMock(dir)},
+		}
+	}
+
+	for _, p := range peers {
+		for _, q := range peers {
+			require.NoError(t, p.idMapper.Put(q.pkiID, q.identity))
+		}
+	}
+	return peers
+}
+
+func TestStateReconciliation(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	state1, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, nil)
+	require.NoError(t, err)
+	// The leader updates its chain state in place
+	state1 = proto.Clone(state1).(*protos.ChainState)
+
+	follower1 := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer follower1.Stop()
+	require.NoError(t, follower1.InitializeWithChainState(proto.Clone(state1).(*protos.ChainState)))
+
+	follower2 := NewGossipChannel(peer2.pkiID, mac, "testchannel", false, peer2.adapter, peer2.idMapper, logging.Default()).(*gossipChannel)
+	defer follower2.Stop()
+	require.NoError(t, follower2.InitializeWithChainState(proto.Clone(state1).(*protos.ChainState)))
+
+	// The follower missed the ChainState message
+	time.Sleep(time.Millisecond)
+	state2, err := leader.AddMember(peer2.pkiID)
+	require.NoError(t, err)
+	require.True(t, state2.SeqNum > state1.SeqNum)
+
+	req, err := follower1.createStateInfoRequest()
+	require.NoError(t, err)
+	assert.Equal(t, state1.SeqNum, req.GetStatePullRequest().SeqNum)
+
+	received := &receivedMessageMock{msg: req, sender: peer1.pkiID}
+	leader.HandleMessage(received)
+	require.Len(t, received.responses, 1)
+
+	resp, err := received.responses[0].NoopSign()
+	require.NoError(t, err)
+	follower1.HandleMessage(&receivedMessageMock{msg: resp, sender: leaderPeer.pkiID})
+	assert.Equal(t, state2.SeqNum, follower1.Self().SeqNum)
+	assert.True(t, follower1.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+	assert.Equal(t, []common.Event{
+		common.LeaderChanged{ChainMac: mac, ChainID: "testchannel", Leader: leaderPeer.pkiID},
+		common.MemberAdded{ChainMac: mac, ChainID: "testchannel", PKIID: peer1.pkiID},
+		common.MemberAdded{ChainMac: mac, ChainID: "testchannel", PKIID: peer2.pkiID},
+	}, peer1.adapter.emittedEvents())
+
+	// The follower is up to date, the leader doesn't respond
+	req, err = follower1.createStateInfoRequest()
+	require.NoError(t, err)
+	received = &receivedMessageMock{msg: req, sender: peer1.pkiID}
+	leader.HandleMessage(received)
+	assert.Empty(t, received.responses)
+
+	// A stale peer pulls the chain state from the requester which is newer
+	received = &receivedMessageMock{msg: req, sender: peer1.pkiID}
+	follower2.HandleMessage(received)
+	assert.Empty(t, received.responses)
+	sent := peer2.adapter.sentMessages()
+	require.Len(t, sent, 1)
+	require.True(t, sent[0].IsStatePullRequestMsg())
+	assert.Equal(t, state1.SeqNum, sent[0].GetStatePullRequest().SeqNum)
+
+	received = &receivedMessageMock{msg: sent[0], sender: peer2.pkiID}
+	follower1.HandleMessage(received)
+	require.Len(t, received.responses, 1)
+
+	resp, err = received.responses[0].NoopSign()
+	require.NoError(t, err)
+	follower2.HandleMessage(&receivedMessageMock{msg: resp, sender: peer1.pkiID})
+	assert.Equal(t, state2.SeqNum, follower2.Self().SeqNum)
+
+	// Stale responses are ignored
+	follower2.HandleMessage(&receivedMessageMock{msg: resp, sender: peer1.pkiID})
+	assert.Equal(t, state2.SeqNum, follower2.Self().SeqNum)
+}
+
+func TestInflatedSeqNum(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default()).(*gossipChannel)
+	defer leader.Stop()
+	state1, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID, peer2.pkiID}, nil)
+	require.NoError(t, err)
+	state1 = proto.Clone(state1).(*protos.ChainState)
+
+	// A member responds with the leader's current state under an inflated sequence number
+	inflated := proto.Clone(state1).(*protos.ChainState)
+	inflated.SeqNum = math.MaxUint64
+	member := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer member.Stop()
+	require.NoError(t, member.InitializeWithChainState(inflated))
+	resp, err := member.createChainStateResponse()
+	require.NoError(t, err)
+	forged, err := resp.NoopSign()
+	require.NoError(t, err)
+
+	victim := NewGossipChannel(peer2.pkiID, mac, "testchannel", false, peer2.adapter, peer2.idMapper, logging.Default()).(*gossipChannel)
+	defer victim.Stop()
+	require.NoError(t, victim.InitializeWithChainState(proto.Clone(state1).(*protos.ChainState)))
+	victim.HandleMessage(&receivedMessageMock{msg: forged, sender: peer1.pkiID})
+	assert.Equal(t, state1.SeqNum, victim.Self().SeqNum)
+
+	// The leader's next state is applied whatever its sequence number
+	time.Sleep(time.Millisecond)
+	_, err = leader.RemoveMember(peer1.pkiID)
+	require.NoError(t, err)
+	leader.Lock()
+	leader.chainStateMsg.SeqNum = 1
+	leader.Unlock()
+	resp, err = leader.createChainStateResponse()
+	require.NoError(t, err)
+	genuine, err := resp.NoopSign()
+	require.NoError(t, err)
+	victim.HandleMessage(&receivedMessageMock{msg: genuine, sender: leaderPeer.pkiID})
+	assert.Equal(t, uint64(2), victim.Self().Epoch())
+	assert.False(t, victim.IsMemberInChan(common.NetworkMember{PKIID: peer1.pkiID}))
+
+	// The member's sequence number doesn't make it look newer, the victim responds with its state
+	req, err := member.createStateInfoRequest()
+	require.NoError(t, err)
+	received := &receivedMessageMock{msg: req, sender: peer1.pkiID}
+	victim.reconcileChainState(received, &common.NetworkMember{PKIID: peer1.pkiID})
+	assert.Len(t, received.responses, 1)
+	assert.Empty(t, peer2.adapter.sentMessages())
+}
+
+func TestBulkFileChanges(t *testing.T) {
+	peers := createPeers(t, 1)
+	leaderPeer := peers[0]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", nil, nil)
+	require.NoError(t, err)
+
+	// The files are added, and removed, with a single change of the chain state
+	state, err := leader.AddFile([]*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}, {Path: "rfc2616.txt", Mode: "Random"}, {Path: "101.png", Mode: "Random"}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), state.Epoch())
+	state, err = leader.RemoveFile([]string{"config.yaml", "101.png"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), state.Epoch())
+	changes := leader.ChangeLog()
+	require.Len(t, changes, 3)
+	assert.Equal(t, []string{"config.yaml", "rfc2616.txt", "101.png"}, changes[1].Files)
+	assert.Equal(t, []string{"config.yaml", "101.png"}, changes[2].Files)
+
+	// Changes that don't modify the channel leave its state as is
+	seqNum := state.SeqNum
+	state, err = leader.AddFile([]*common.FileSyncInfo{{Path: "rfc2616.txt", Mode: "Random"}})
+	require.NoError(t, err)
+	state, err = leader.RemoveFile([]string{"config.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), state.Epoch())
+	assert.Equal(t, seqNum, state.SeqNum)
+	assert.Len(t, leader.ChangeLog(), 3)
+	assert.NoError(t, leader.VerifyChangeLog())
+}
+
+func TestFileStatesDigestCache(t *testing.T) {
+	peers := createPeers(t, 1)
+	leaderPeer := peers[0]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", nil, nil)
+	require.NoError(t, err)
+	_, err = leader.AddFile([]*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	_, err = leader.PublishFileVersion("config.yaml")
+	require.NoError(t, err)
+
+	states, err := leader.FileStates()
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, DigestMatch, states[0].Digest)
+
+	// The digest of the unchanged file is taken from the cache rather than computed again
+	gc := leader.(*gossipChannel)
+	v, ok := gc.contentHashes.Load("config.yaml")
+	require.True(t, ok)
+	cached := v.(contentHash)
+	gc.contentHashes.Store("config.yaml", contentHash{size: cached.size, modTime: cached.modTime, digest: []byte("stale")})
+	states, err = leader.FileStates()
+	require.NoError(t, err)
+	assert.Equal(t, DigestMismatch, states[0].Digest)
+
+	// It's computed again once the size or the modification time of the file differ
+	gc.contentHashes.Store("config.yaml", contentHash{size: cached.size + 1, modTime: cached.modTime, digest: []byte("stale")})
+	states, err = leader.FileStates()
+	require.NoError(t, err)
+	assert.Equal(t, DigestMatch, states[0].Digest)
+}
+
+func TestPublishFileVersion(t *testing.T) {
+	peers := createPeers(t, 1)
+	leaderPeer := peers[0]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default()).(*gossipChannel)
+	defer leader.Stop()
+	_, err := leader.Initialize("testchannel", nil, nil)
+	require.NoError(t, err)
+	state, err := leader.AddFile([]*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+
+	// The re-signed state has to fit in a message like the other changes
+	unsigned := &protos.ChainState{ChainId: state.ChainId, Envelope: state.Envelope, SeqNum: math.MaxUint64}
+	leaderPeer.adapter.setMaxMessageSize(proto.Size(unsigned) + protos.MessageOverhead + 8)
+	_, err = leader.PublishFileVersion("config.yaml")
+	_, ok := err.(*LimitExceededError)
+	assert.True(t, ok, "Expected a LimitExceededError, got %v", err)
+	assert.Equal(t, uint64(2), leader.Self().Epoch())
+
+	leaderPeer.adapter.setMaxMessageSize(0)
+	state, err = leader.PublishFileVersion("config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), state.Epoch())
+	seqNum := state.SeqNum
+
+	// A digest of the file computed before it changed isn't published
+	file, err := leader.channelFile("config.yaml")
+	require.NoError(t, err)
+	_, changed, err := leader.publishFileVersion("config.yaml", []byte("outdated"))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, seqNum, leader.Self().SeqNum)
+
+	// The unchanged file isn't published again
+	state, err = leader.PublishFileVersion("config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, seqNum, state.SeqNum)
+	assert.Equal(t, uint64(1), file.Version)
+
+	_, err = leader.PublishFileVersion("missing.txt")
+	assert.Error(t, err)
+}
+
+func TestChannelPropagation(t *testing.T) {
+	peers := createPeers(t, 1)
+	p := peers[0]
+	p.adapter.propagations = map[string]config.Propagation{"control": {PeerNum: 10, Iterations: 3}}
+
+	control := NewGossipChannel(p.pkiID, GenerateMAC(p.pkiID, "control"), "control", false, p.adapter, p.idMapper, logging.Default())
+	defer control.Stop()
+	data := NewGossipChannel(p.pkiID, GenerateMAC(p.pkiID, "data"), "data", false, p.adapter, p.idMapper, logging.Default())
+	defer data.Stop()
+
+	assert.Equal(t, config.Propagation{PeerNum: 10, Iterations: 3}, control.Propagation())
+	assert.Equal(t, config.Propagation{}, data.Propagation())
+
+	// The propagation set at runtime takes precedence over the configured one
+	control.SetPropagation(config.Propagation{PeerNum: 1})
+	data.SetPropagation(config.Propagation{Iterations: 1})
+	assert.Equal(t, config.Propagation{PeerNum: 1}, control.Propagation())
+	assert.Equal(t, config.Propagation{Iterations: 1}, data.Propagation())
+}
+
+func TestStaleChainState(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	state1, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, nil)
+	require.NoError(t, err)
+	state1 = proto.Clone(state1).(*protos.ChainState)
+	state2, err := leader.AddMember(peer2.pkiID)
+	require.NoError(t, err)
+	state2 = proto.Clone(state2).(*protos.ChainState)
+	assert.Equal(t, uint64(1), state1.Epoch())
+	assert.Equal(t, uint64(2), state2.Epoch())
+
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default()).(*gossipChannel)
+	defer follower.Stop()
+	require.NoError(t, follower.InitializeWithChainState(proto.Clone(state2).(*protos.ChainState)))
+	require.NoError(t, follower.updateChainState(proto.Clone(state2).(*protos.ChainState), leaderPeer.pkiID))
+
+	// The sequence number isn't signed by the leader, a replayed state can't pass for a newer one
+	replayed := proto.Clone(state1).(*protos.ChainState)
+	replayed.SeqNum = state2.SeqNum + 1
+	assert.Error(t, follower.InitializeWithChainState(proto.Clone(replayed).(*protos.ChainState)))
+	require.NoError(t, follower.updateChainState(replayed, leaderPeer.pkiID))
+	assert.Equal(t, state2.SeqNum, follower.Self().SeqNum)
+	assert.True(t, follower.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+
+	comparator := protos.NewRKSyncMessageComparator()
+	msg := func(state *protos.ChainState) *protos.SignedRKSyncMessage {
+		return &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{
+			ChainMac: mac,
+			Tag:      protos.RKSyncMessage_CHAN_ONLY,
+			Content:  &protos.RKSyncMessage_State{State: state},
+		}}
+	}
+	assert.Equal(t, common.MessageInvalidated, comparator(msg(replayed), msg(state2)))
+	assert.Equal(t, common.MessageInvalidates, comparator(msg(state2), msg(replayed)))
+
+	// A newer state is applied
+	state3, err := leader.RemoveMember(peer2.pkiID)
+	require.NoError(t, err)
+	require.NoError(t, follower.updateChainState(proto.Clone(state3).(*protos.ChainState), leaderPeer.pkiID))
+	assert.Equal(t, uint64(3), follower.Self().Epoch())
+	assert.False(t, follower.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+}
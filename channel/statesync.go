@@ -0,0 +1,196 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/rkcloudchain/rksync/channel/fsync"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/filter"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxManifestPageSize is the max size in bytes of a page of the manifest of a StateResponse,
+// the pages are smaller if the max message size is
+const maxManifestPageSize = 64 * 1024
+
+func (gc *gossipChannel) RequestState() {
+	if gc.leader {
+		return
+	}
+
+	req, err := gc.createStateRequest("")
+	if err != nil {
+		gc.logger.Warningf("Failed creating StateRequest message: %+v", err)
+		return
+	}
+
+	peers := gc.stateSources()
+	if len(peers) == 0 {
+		gc.logger.Debugf("Channel %s: No peer to request the state from", gc.chainMac)
+		return
+	}
+	gc.Send(req, peers...)
+}
+
+// createStateRequest creates a StateRequest message for the page of the manifest starting at the given path,
+// an empty path requests the chain state along with the first page
+func (gc *gossipChannel) createStateRequest(manifestFrom string) (*protos.SignedRKSyncMessage, error) {
+	var seqNum uint64
+	if self := gc.Self(); self != nil {
+		seqNum = self.SeqNum
+	}
+	return (&protos.RKSyncMessage{
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Nonce:    0,
+		ChainMac: gc.chainMac,
+		Content: &protos.RKSyncMessage_StateReq{
+			StateReq: &protos.StateRequest{SeqNum: seqNum, ManifestFrom: manifestFrom},
+		},
+	}).NoopSign()
+}
+
+// stateSources returns some members of the channel along with its leader, if it's alive
+func (gc *gossipChannel) stateSources() []*common.NetworkMember {
+	filters := filter.CombineRoutingFilters(gc.IsMemberInChan, func(member common.NetworkMember) bool {
+		return gc.pkiID.IsNotSameFilter(member.PKIID)
+	})
+	conf := gc.GetChannelConfig()
+	peers := filter.SelectPeersAcrossZones(conf.PullPeerNum, gc.GetMembership(), filters, conf.Zone)
+
+	leader, _ := gc.leaderPKIid.Load().(common.PKIidType)
+	if leader == nil {
+		return peers
+	}
+	for _, peer := range peers {
+		if bytes.Equal(peer.PKIID, leader) {
+			return peers
+		}
+	}
+	if member := gc.Lookup(leader); member != nil {
+		peers = append(peers, member)
+	}
+	return peers
+}
+
+// handleStateRequest responds with the chain state and the sizes of the local copies of the files.
+// The sizes are sent in pages fitting in a message, the requester pulls the next ones.
+func (gc *gossipChannel) handleStateRequest(msg protos.ReceivedMessage) {
+	sender := msg.GetConnectionInfo().ID
+	if !gc.IsMemberInChan(common.NetworkMember{PKIID: sender}) {
+		gc.logger.Warningf("Received StateRequest message from %s, not member in channel %s", sender, gc.chainMac)
+		return
+	}
+	if gc.Self() == nil {
+		return
+	}
+
+	req := msg.GetRKSyncMessage().GetStateReq()
+	res := &protos.StateResponse{}
+	if req.ManifestFrom == "" {
+		element, err := gc.signedChainState()
+		if err != nil {
+			gc.logger.Errorf("Failed signing ChainState message: %v", err)
+			return
+		}
+		res.Element = element
+	}
+	// The state is sent along without the sizes if a file can't be stated
+	progress, err := gc.fileState.progress()
+	if err != nil {
+		gc.logger.Warningf("Channel %s: Failed getting the sizes of the files: %s", gc.chainMac, err)
+	}
+	res.Manifest, res.Next = manifestPage(progress, req.ManifestFrom, gc.manifestPageSize()-proto.Size(res))
+
+	msg.Respond(&protos.RKSyncMessage{
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Nonce:    0,
+		ChainMac: gc.chainMac,
+		Content: &protos.RKSyncMessage_StateRes{
+			StateRes: res,
+		},
+	})
+}
+
+// manifestPageSize returns the max size in bytes of a StateResponse message
+func (gc *gossipChannel) manifestPageSize() int {
+	if maxMsg := gc.GetChannelConfig().MaxMessageSize - protos.MessageOverhead; maxMsg > 0 && maxMsg < maxManifestPageSize {
+		return maxMsg
+	}
+	return maxManifestPageSize
+}
+
+// manifestPage returns the entries of the manifest starting at the given path which fit in the budget,
+// along with the path the next page starts at. A page starting at a path holds at least an entry,
+// so that the requester always makes progress.
+func manifestPage(progress []fsync.Progress, from string, budget int) ([]*protos.FileManifestEntry, string) {
+	sort.Slice(progress, func(i, j int) bool {
+		return progress[i].Filename < progress[j].Filename
+	})
+	start := sort.Search(len(progress), func(i int) bool {
+		return progress[i].Filename >= from
+	})
+
+	var manifest []*protos.FileManifestEntry
+	rest := progress[start:]
+	for i, p := range rest {
+		entry := &protos.FileManifestEntry{Path: p.Filename, Length: p.Size}
+		size := proto.Size(&protos.StateResponse{Manifest: []*protos.FileManifestEntry{entry}})
+		// Room is left for the path of the next page in case the page ends after the entry
+		reserved := 0
+		if i+1 < len(rest) {
+			reserved = proto.Size(&protos.StateResponse{Next: rest[i+1].Filename})
+		}
+		if size+reserved > budget && (from == "" || len(manifest) > 0) {
+			return manifest, p.Filename
+		}
+		manifest = append(manifest, entry)
+		budget -= size
+	}
+	return manifest, ""
+}
+
+// handleStateResponse applies the chain state of the response, then requests the files
+// whose local copies are shorter than the responder's ones, and the next page of the manifest
+func (gc *gossipChannel) handleStateResponse(m *protos.RKSyncMessage, sender common.PKIidType) {
+	res := m.GetStateRes()
+	if res.Element != nil {
+		gc.applyChainStateElement(m.ChainMac, res.Element, sender)
+	}
+
+	for _, entry := range res.Manifest {
+		if p := gc.fileState.lookupFSyncProviderByFilename(entry.Path); p != nil {
+			p.Announce(entry.Length)
+		}
+	}
+
+	if res.Next == "" {
+		return
+	}
+	// A continuation page without entries, or one the next page of which doesn't start past it, would loop
+	if res.Element == nil && len(res.Manifest) == 0 {
+		return
+	}
+	if n := len(res.Manifest); n > 0 && res.Next <= res.Manifest[n-1].Path {
+		gc.logger.Warningf("Channel %s: StateResponse sent from %s has an invalid next page %s", gc.chainMac, sender, res.Next)
+		return
+	}
+	member := gc.Lookup(sender)
+	if member == nil {
+		gc.logger.Debugf("Channel %s: %s isn't alive, can't pull the next page of its manifest", gc.chainMac, sender)
+		return
+	}
+	req, err := gc.createStateRequest(res.Next)
+	if err != nil {
+		gc.logger.Warningf("Failed creating StateRequest message: %+v", err)
+		return
+	}
+	gc.Send(req, member)
+}
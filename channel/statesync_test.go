@@ -0,0 +1,137 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel/fsync"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRequestState(t *testing.T) {
+	peers := createPeers(t, 3)
+	leaderPeer, peer1, peer2 := peers[0], peers[1], peers[2]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	state1, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, []*common.FileSyncInfo{
+		{Path: "config.yaml", Mode: "Append"},
+	})
+	require.NoError(t, err)
+	state1 = proto.Clone(state1).(*protos.ChainState)
+
+	// The leader doesn't request the state of its own channel
+	leader.RequestState()
+	assert.Empty(t, leaderPeer.adapter.sentMessages())
+
+	// The follower joined the channel with a stale chain state
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default())
+	defer follower.Stop()
+	require.NoError(t, follower.InitializeWithChainState(proto.Clone(state1).(*protos.ChainState)))
+	time.Sleep(time.Millisecond)
+	state2, err := leader.AddMember(peer2.pkiID)
+	require.NoError(t, err)
+
+	follower.RequestState()
+	sent := peer1.adapter.sentMessages()
+	require.Len(t, sent, 1)
+	require.True(t, sent[0].IsStateReq())
+	assert.Equal(t, state1.SeqNum, sent[0].GetStateReq().SeqNum)
+
+	received := &receivedMessageMock{msg: sent[0], sender: peer1.pkiID}
+	leader.HandleMessage(received)
+	require.Len(t, received.responses, 1)
+	res := received.responses[0].GetStateRes()
+	require.NotNil(t, res)
+	fi, err := os.Stat("../tests/testdata/peer0/config.yaml")
+	require.NoError(t, err)
+	require.Len(t, res.Manifest, 1)
+	assert.True(t, proto.Equal(&protos.FileManifestEntry{Path: "config.yaml", Length: fi.Size()}, res.Manifest[0]))
+
+	resp, err := received.responses[0].NoopSign()
+	require.NoError(t, err)
+	follower.HandleMessage(&receivedMessageMock{msg: resp, sender: leaderPeer.pkiID})
+	assert.Equal(t, state2.SeqNum, follower.Self().SeqNum)
+	assert.True(t, follower.IsMemberInChan(common.NetworkMember{PKIID: peer2.pkiID}))
+
+	// Peers which aren't members of the channel don't get its state
+	received = &receivedMessageMock{msg: sent[0], sender: common.PKIidType("outsider")}
+	leader.HandleMessage(received)
+	assert.Empty(t, received.responses)
+}
+
+func TestRequestStatePages(t *testing.T) {
+	files := []string{"101.png", "config.yaml", "https-cert.pem", "https-key.pem", "rfc2616.txt"}
+
+	peers := createPeers(t, 2)
+	leaderPeer, peer1 := peers[0], peers[1]
+	mac := GenerateMAC(leaderPeer.pkiID, "testchannel")
+
+	// The files are added in an order other than the one of their paths
+	var infos []*common.FileSyncInfo
+	for i := len(files) - 1; i >= 0; i-- {
+		infos = append(infos, &common.FileSyncInfo{Path: files[i], Mode: "Append"})
+	}
+	leader := NewGossipChannel(leaderPeer.pkiID, mac, "testchannel", true, leaderPeer.adapter, leaderPeer.idMapper, logging.Default())
+	defer leader.Stop()
+	state, err := leader.Initialize("testchannel", []common.PKIidType{peer1.pkiID}, infos)
+	require.NoError(t, err)
+	leaderPeer.adapter.setMaxMessageSize(protos.MessageOverhead + 64)
+
+	follower := NewGossipChannel(peer1.pkiID, mac, "testchannel", false, peer1.adapter, peer1.idMapper, logging.Default())
+	defer follower.Stop()
+	require.NoError(t, follower.InitializeWithChainState(proto.Clone(state).(*protos.ChainState)))
+
+	// The follower pulls the pages of the manifest one after the other
+	var manifest []*protos.FileManifestEntry
+	pages := 0
+	follower.RequestState()
+	for sent := peer1.adapter.sentMessages(); len(sent) > pages; sent = peer1.adapter.sentMessages() {
+		require.True(t, sent[pages].IsStateReq())
+		received := &receivedMessageMock{msg: sent[pages], sender: peer1.pkiID}
+		leader.HandleMessage(received)
+		require.Len(t, received.responses, 1)
+		res := received.responses[0].GetStateRes()
+		require.NotNil(t, res)
+		if pages > 0 {
+			assert.Nil(t, res.Element)
+			assert.True(t, proto.Size(res) <= 64)
+		}
+		manifest = append(manifest, res.Manifest...)
+		pages++
+
+		resp, err := received.responses[0].NoopSign()
+		require.NoError(t, err)
+		follower.HandleMessage(&receivedMessageMock{msg: resp, sender: leaderPeer.pkiID})
+	}
+	assert.True(t, pages > 2)
+	require.Len(t, manifest, len(files))
+	for i, f := range files {
+		fi, err := os.Stat(filepath.Join("../tests/testdata/peer0", f))
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(&protos.FileManifestEntry{Path: f, Length: fi.Size()}, manifest[i]))
+	}
+
+	// A page starting at a path holds at least an entry even if it exceeds the budget
+	entries, next := manifestPage([]fsync.Progress{{Filename: "b", Size: 1}, {Filename: "a", Size: 1}}, "a", 0)
+	require.Len(t, entries, 1)
+	assert.True(t, proto.Equal(&protos.FileManifestEntry{Path: "a", Length: 1}, entries[0]))
+	assert.Equal(t, "b", next)
+	entries, next = manifestPage([]fsync.Progress{{Filename: "a", Size: 1}}, "", 0)
+	assert.Empty(t, entries)
+	assert.Equal(t, "a", next)
+}
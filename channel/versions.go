@@ -0,0 +1,121 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// maxFileVersions is the number of unpinned versions kept per file
+const maxFileVersions = 16
+
+// FileVersion is a version of a file published by the leader of the channel
+type FileVersion struct {
+	Version     uint64
+	ContentHash []byte
+	SeqNum      uint64    // Sequence number of the chain state the version was observed in
+	Published   time.Time // Time the leader published the chain state
+	Pinned      bool      // Pinned versions are kept until unpinned, regardless of maxFileVersions
+}
+
+// fileVersions records the versions of the files observed by the peer
+type fileVersions struct {
+	sync.Mutex
+	latest  map[string]uint64
+	history map[string][]FileVersion
+}
+
+func newFileVersions() *fileVersions {
+	return &fileVersions{
+		latest:  make(map[string]uint64),
+		history: make(map[string][]FileVersion),
+	}
+}
+
+// record records the version of the file carried by the chain state of the given sequence number,
+// and returns the latest version previously known, or false if the file wasn't known yet
+func (fv *fileVersions) record(file *protos.File, seqNum uint64) (uint64, bool) {
+	fv.Lock()
+	defer fv.Unlock()
+
+	previous, known := fv.latest[file.Path]
+	if known && file.Version <= previous {
+		return previous, known
+	}
+	fv.latest[file.Path] = file.Version
+	if file.Version == 0 {
+		return previous, known
+	}
+
+	versions := append(fv.history[file.Path], FileVersion{
+		Version:     file.Version,
+		ContentHash: file.ContentHash,
+		SeqNum:      seqNum,
+		Published:   time.Unix(0, int64(seqNum)),
+	})
+	excess := -maxFileVersions
+	for _, v := range versions {
+		if !v.Pinned {
+			excess++
+		}
+	}
+	if excess > 0 {
+		kept := make([]FileVersion, 0, len(versions)-excess)
+		for _, v := range versions {
+			if !v.Pinned && excess > 0 {
+				excess--
+				continue
+			}
+			kept = append(kept, v)
+		}
+		versions = kept
+	}
+	fv.history[file.Path] = versions
+	return previous, known
+}
+
+func (fv *fileVersions) forget(filename string) {
+	fv.Lock()
+	defer fv.Unlock()
+	delete(fv.latest, filename)
+	delete(fv.history, filename)
+}
+
+// latestOf returns the latest version of the file, or 0 if the file isn't known
+func (fv *fileVersions) latestOf(filename string) uint64 {
+	fv.Lock()
+	defer fv.Unlock()
+	return fv.latest[filename]
+}
+
+func (fv *fileVersions) versions(filename string) ([]FileVersion, error) {
+	fv.Lock()
+	defer fv.Unlock()
+
+	if _, known := fv.latest[filename]; !known {
+		return nil, errors.Errorf("File %s does not exist in the channel", filename)
+	}
+	return append([]FileVersion(nil), fv.history[filename]...), nil
+}
+
+func (fv *fileVersions) pin(filename string, version uint64, pinned bool) error {
+	fv.Lock()
+	defer fv.Unlock()
+
+	versions := fv.history[filename]
+	for i := range versions {
+		if versions[i].Version == version {
+			versions[i].Pinned = pinned
+			return nil
+		}
+	}
+	return errors.Errorf("Version %d of file %s is unknown", version, filename)
+}
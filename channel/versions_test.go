@@ -0,0 +1,65 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package channel
+
+import (
+	"testing"
+
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileVersions(t *testing.T) {
+	fv := newFileVersions()
+
+	_, known := fv.record(&protos.File{Path: "a.txt"}, 1)
+	assert.False(t, known)
+	versions, err := fv.versions("a.txt")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	_, err = fv.versions("b.txt")
+	assert.Error(t, err)
+
+	previous, known := fv.record(&protos.File{Path: "a.txt", Version: 1, ContentHash: []byte{1}}, 2)
+	assert.True(t, known)
+	assert.Equal(t, uint64(0), previous)
+	previous, _ = fv.record(&protos.File{Path: "a.txt", Version: 1, ContentHash: []byte{1}}, 3)
+	assert.Equal(t, uint64(1), previous)
+	previous, _ = fv.record(&protos.File{Path: "a.txt", Version: 3, ContentHash: []byte{3}}, 4)
+	assert.Equal(t, uint64(1), previous)
+
+	versions, err = fv.versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, uint64(1), versions[0].Version)
+	assert.Equal(t, uint64(2), versions[0].SeqNum)
+	assert.Equal(t, []byte{3}, versions[1].ContentHash)
+
+	assert.Error(t, fv.pin("a.txt", 2, true))
+	require.NoError(t, fv.pin("a.txt", 1, true))
+	for v := uint64(4); v < 4+maxFileVersions; v++ {
+		fv.record(&protos.File{Path: "a.txt", Version: v}, v+1)
+	}
+	versions, err = fv.versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, maxFileVersions+1)
+	assert.Equal(t, uint64(1), versions[0].Version)
+	assert.True(t, versions[0].Pinned)
+	assert.Equal(t, uint64(4), versions[1].Version)
+
+	require.NoError(t, fv.pin("a.txt", 1, false))
+	fv.record(&protos.File{Path: "a.txt", Version: 4 + maxFileVersions}, 100)
+	versions, err = fv.versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, maxFileVersions)
+	assert.Equal(t, uint64(5), versions[0].Version)
+
+	fv.forget("a.txt")
+	_, err = fv.versions("a.txt")
+	assert.Error(t, err)
+}
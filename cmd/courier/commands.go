@@ -0,0 +1,218 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fileSpecs collects the files given as path[:mode], the mode defaults to Append
+type fileSpecs []*protos.FileInfo
+
+func (f *fileSpecs) String() string {
+	specs := make([]string, len(*f))
+	for i, file := range *f {
+		specs[i] = file.Path + ":" + file.Mode
+	}
+	return strings.Join(specs, ",")
+}
+
+func (f *fileSpecs) Set(spec string) error {
+	path, mode := spec, "Append"
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		if m := spec[i+1:]; m == "Append" || m == "Random" {
+			path, mode = spec[:i], m
+		}
+	}
+	if path == "" {
+		return errors.Errorf("Invalid file %q", spec)
+	}
+	*f = append(*f, &protos.FileInfo{Path: path, Mode: mode})
+	return nil
+}
+
+// stringList collects the values of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	if value == "" {
+		return errors.New("Value can't be empty")
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+func listChannels(ctx context.Context, client protos.AdminClient, args []string) error {
+	resp, err := client.ListChannels(ctx, &emptypb.Empty{})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func createChannel(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("create-channel", flag.ExitOnError)
+	chainID := flags.String("channel", "", "Channel ID")
+	var files fileSpecs
+	flags.Var(&files, "file", "File synchronized in the channel, as path[:mode], may be repeated")
+	flags.Parse(args)
+
+	if *chainID == "" {
+		return errors.New("-channel must be provided")
+	}
+	_, err := client.CreateChannel(ctx, &protos.CreateChannelRequest{ChainId: *chainID, Files: files})
+	return err
+}
+
+func addMember(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("add-member", flag.ExitOnError)
+	chainID := flags.String("channel", "", "Channel ID")
+	nodeID := flags.String("node-id", "", "Node ID of the member")
+	certFile := flags.String("cert", "", "PEM encoded certificate of the member")
+	flags.Parse(args)
+
+	if *chainID == "" || *nodeID == "" || *certFile == "" {
+		return errors.New("-channel, -node-id and -cert must be provided")
+	}
+	cert, err := ioutil.ReadFile(*certFile)
+	if err != nil {
+		return errors.Wrap(err, "Failed reading the member certificate")
+	}
+	_, err = client.AddMember(ctx, &protos.AddMemberRequest{ChainId: *chainID, NodeId: *nodeID, Certificate: cert})
+	return err
+}
+
+func addFile(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("add-file", flag.ExitOnError)
+	chainID := flags.String("channel", "", "Channel ID")
+	var files fileSpecs
+	flags.Var(&files, "file", "File added to the channel, as path[:mode], may be repeated")
+	flags.Parse(args)
+
+	if *chainID == "" || len(files) == 0 {
+		return errors.New("-channel and at least one -file must be provided")
+	}
+	_, err := client.AddFile(ctx, &protos.AddFileRequest{ChainId: *chainID, Files: files})
+	return err
+}
+
+func removeFile(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("remove-file", flag.ExitOnError)
+	chainID := flags.String("channel", "", "Channel ID")
+	var files stringList
+	flags.Var(&files, "file", "Path of a file removed from the channel, may be repeated")
+	flags.Parse(args)
+
+	if *chainID == "" || len(files) == 0 {
+		return errors.New("-channel and at least one -file must be provided")
+	}
+	_, err := client.RemoveFile(ctx, &protos.RemoveFileRequest{ChainId: *chainID, Files: files})
+	return err
+}
+
+func showProgress(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("progress", flag.ExitOnError)
+	chainID := flags.String("channel", "", "Channel ID")
+	chainMac := flags.String("mac", "", "Channel MAC, required when several channels have the same ID")
+	flags.Parse(args)
+
+	if *chainMac == "" {
+		if *chainID == "" {
+			return errors.New("Either -channel or -mac must be provided")
+		}
+		mac, err := lookupChannelMAC(ctx, client, *chainID)
+		if err != nil {
+			return err
+		}
+		*chainMac = mac
+	}
+
+	resp, err := client.TransferProgress(ctx, &protos.ChannelRequest{ChainMac: *chainMac})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func listPeers(ctx context.Context, client protos.AdminClient, args []string) error {
+	resp, err := client.ListPeers(ctx, &emptypb.Empty{})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func showConnections(ctx context.Context, client protos.AdminClient, args []string) error {
+	resp, err := client.ConnectionStats(ctx, &emptypb.Empty{})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func debugDump(ctx context.Context, client protos.AdminClient, args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	goroutines := flags.Bool("goroutines", false, "Print the stacks of the goroutines instead of the queue depths")
+	flags.Parse(args)
+
+	resp, err := client.DebugDump(ctx, &emptypb.Empty{})
+	if err != nil {
+		return err
+	}
+	if *goroutines {
+		_, err = os.Stdout.WriteString(resp.Goroutines)
+		return err
+	}
+	resp.Goroutines = ""
+	return printJSON(resp)
+}
+
+// lookupChannelMAC returns the MAC of the only channel of the peer with the given ID
+func lookupChannelMAC(ctx context.Context, client protos.AdminClient, chainID string) (string, error) {
+	resp, err := client.ListChannels(ctx, &emptypb.Empty{})
+	if err != nil {
+		return "", err
+	}
+
+	var macs []string
+	for _, summary := range resp.Channels {
+		if summary.ChainId == chainID {
+			macs = append(macs, summary.ChainMac)
+		}
+	}
+	switch len(macs) {
+	case 0:
+		return "", errors.Errorf("Channel %s not found", chainID)
+	case 1:
+		return macs[0], nil
+	default:
+		return "", errors.Errorf("Several channels named %s, use -mac with one of %s", chainID, strings.Join(macs, ", "))
+	}
+}
+
+func printJSON(msg proto.Message) error {
+	data, err := protojson.MarshalOptions{Indent: "  ", UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type adminClientMock struct {
+	protos.AdminClient
+	channels []*protos.ChannelSummary
+}
+
+func (c *adminClientMock) ListChannels(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*protos.ChannelList, error) {
+	return &protos.ChannelList{Channels: c.channels}, nil
+}
+
+func TestFileSpecs(t *testing.T) {
+	var files fileSpecs
+	require.NoError(t, files.Set("file1.txt"))
+	require.NoError(t, files.Set("file2.txt:Random"))
+	require.NoError(t, files.Set("c:/file3.txt"))
+	assert.Error(t, files.Set(":Append"))
+
+	assert.Equal(t, fileSpecs{
+		{Path: "file1.txt", Mode: "Append"},
+		{Path: "file2.txt", Mode: "Random"},
+		{Path: "c:/file3.txt", Mode: "Append"},
+	}, files)
+	assert.Equal(t, "file1.txt:Append,file2.txt:Random,c:/file3.txt:Append", files.String())
+}
+
+func TestLookupChannelMAC(t *testing.T) {
+	client := &adminClientMock{channels: []*protos.ChannelSummary{
+		{ChainMac: "0a", ChainId: "channel1"},
+		{ChainMac: "0b", ChainId: "channel2"},
+		{ChainMac: "0c", ChainId: "channel2"},
+	}}
+
+	mac, err := lookupChannelMAC(context.Background(), client, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, "0a", mac)
+
+	_, err = lookupChannelMAC(context.Background(), client, "channel2")
+	assert.Error(t, err)
+	_, err = lookupChannelMAC(context.Background(), client, "channel3")
+	assert.Error(t, err)
+}
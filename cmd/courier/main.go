@@ -0,0 +1,129 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command courier administrates a rksync peer through its admin service,
+// which is served on the peer's GossipConfig.AdminAddress when its GossipConfig.AdminService is set.
+//
+// Usage:
+//
+//	courier [flags] <command> [command flags]
+//
+// The commands are:
+//
+//	channels          list the channels of the peer
+//	create-channel    create a channel led by the peer
+//	add-member        add a member to a channel, given its node ID and certificate
+//	add-file          add files to a channel
+//	remove-file       remove files from a channel
+//	progress          show the synchronization progress of the files of a channel
+//	peers             dump the alive members known by the peer
+//	connections       show the connections established with remote peers, and the allocations of the peer
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/creds"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, client protos.AdminClient, args []string) error
+}
+
+var commands = map[string]command{
+	"channels":       {"", listChannels},
+	"create-channel": {"-channel ID [-file path[:mode]]...", createChannel},
+	"add-member":     {"-channel ID -node-id ID -cert FILE", addMember},
+	"add-file":       {"-channel ID -file path[:mode]...", addFile},
+	"remove-file":    {"-channel ID -file path...", removeFile},
+	"progress":       {"-channel ID | -mac MAC", showProgress},
+	"peers":          {"", listPeers},
+	"connections":    {"", showConnections},
+	"dump":           {"[-goroutines]", debugDump},
+}
+
+func main() {
+	address := flag.String("address", config.DefaultAdminAddress, "Address of the admin service of the peer")
+	timeout := flag.Duration("timeout", 10*time.Second, "Timeout of the command")
+	caFile := flag.String("tls-ca", "", "PEM encoded CA certificate of the peer, enables TLS")
+	certFile := flag.String("tls-cert", "", "PEM encoded client TLS certificate")
+	keyFile := flag.String("tls-key", "", "PEM encoded client TLS key")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, exists := commands[flag.Arg(0)]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "courier: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if *caFile != "" {
+		tlsConfig, err := clientTLSConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			fail(err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, *address, opts...)
+	if err != nil {
+		fail(errors.Wrapf(err, "Failed connecting to %s", *address))
+	}
+	defer conn.Close()
+
+	if err := cmd.run(ctx, protos.NewAdminClient(conn), flag.Args()[1:]); err != nil {
+		fail(err)
+	}
+}
+
+func clientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("Both -tls-cert and -tls-key must be provided with -tls-ca")
+	}
+	return creds.ClientTLSConfig(&config.TLSConfig{
+		Certificate:   certFile,
+		Key:           keyFile,
+		ServerRootCAs: []string{caFile},
+	})
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: courier [flags] <command> [command flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range []string{"channels", "create-channel", "add-member", "add-file", "remove-file", "progress", "peers", "connections", "dump"} {
+		fmt.Fprintf(os.Stderr, "  %s\n", strings.TrimSpace(name+" "+commands[name].usage))
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "courier: %s\n", err)
+	os.Exit(1)
+}
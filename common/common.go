@@ -10,6 +10,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"time"
 )
 
 // PKIidType defines the type that holds the PKI-id
@@ -35,8 +37,26 @@ type PeerIdentityType []byte
 
 // NetworkMember defines a peer's endpoint and its PKIid
 type NetworkMember struct {
-	Endpoint string
-	PKIID    PKIidType
+	Endpoint         string
+	InternalEndpoint string
+	PKIID            PKIidType
+	Tags             []string
+	Zone             string
+	Org              string            // Organization the member belongs to, as declared by its identity
+	Labels           map[string]string // Key/value metadata attached by the application, e.g: role=ingest
+	Leaving          bool              // The member is draining and about to leave the network
+	Unreachable      bool              // The member failed repeatedly, it isn't selected until it's retried
+	Distrusted       bool              // The member misbehaved, it's only selected when there aren't enough other members
+	Latency          time.Duration     // Round-trip time measured to the member, 0 if it wasn't measured yet
+}
+
+// PreferredEndpoint computes the endpoint to connect to,
+// while preferring internal endpoint over the standard endpoint
+func (p NetworkMember) PreferredEndpoint() string {
+	if p.InternalEndpoint != "" {
+		return p.InternalEndpoint
+	}
+	return p.Endpoint
 }
 
 // String converts a NetworkMember to a string
@@ -44,6 +64,16 @@ func (p *NetworkMember) String() string {
 	return fmt.Sprintf("%s, PKIid: %v", p.Endpoint, p.PKIID)
 }
 
+// HasTag returns whether the member has been assigned the given tag
+func (p NetworkMember) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // MessageAcceptor is a predicate that is used to
 // determine in which messages the subscriber that created the
 // instance of the MessageAcceptor is interested in.
@@ -73,11 +103,73 @@ const (
 	MessageInvalidated
 )
 
+// MessagePriority determines in which order messages are sent to a remote peer
+type MessagePriority int
+
+const (
+	// LowPriority is the priority of bulk file data
+	LowPriority MessagePriority = iota
+
+	// HighPriority is the priority of control plane messages, which are
+	// sent before any pending message of a lower priority
+	HighPriority
+)
+
+// OverflowPolicy determines what happens to a message sent to a peer
+// whose send buffer is full
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered message to make room for the new one
+	DropOldest OverflowPolicy = iota + 1
+
+	// DropNewest discards the new message
+	DropNewest
+
+	// Block waits until there is room in the buffer or the connection is closed, for a bounded
+	// time and by a bounded number of messages, the others are dropped
+	Block
+)
+
+// Misbehavior is a kind of behavior of a remote peer lowering its reputation
+type Misbehavior int
+
+const (
+	// InvalidSignature means the peer sent a message whose signature doesn't verify
+	InvalidSignature Misbehavior = iota + 1
+
+	// MalformedMessage means the peer sent a message which can't be parsed or is inconsistent
+	MalformedMessage
+
+	// RateLimitExceeded means the peer sent more messages than allowed per second
+	RateLimitExceeded
+
+	// FailedTransfer means the file data received from the peer doesn't match its digest
+	FailedTransfer
+)
+
+func (m Misbehavior) String() string {
+	switch m {
+	case InvalidSignature:
+		return "InvalidSignature"
+	case MalformedMessage:
+		return "MalformedMessage"
+	case RateLimitExceeded:
+		return "RateLimitExceeded"
+	case FailedTransfer:
+		return "FailedTransfer"
+	}
+	return fmt.Sprintf("Misbehavior(%d)", int(m))
+}
+
 // FileSyncInfo defines a file sync mode
 type FileSyncInfo struct {
-	Path     string
-	Mode     string
-	Metadata []byte
+	Path       string
+	Mode       string
+	Metadata   []byte
+	Perm       os.FileMode       // Permission bits of the members' copies, zero to leave them to the FileSystem
+	Attributes map[string]string // Extended attributes of the members' copies
+	Priority   int32             // Files of the channel with higher priorities are transferred first when the members' transfers are limited
 }
 
 // ChainMac defines the identity representation of a chain
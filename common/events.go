@@ -0,0 +1,132 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import "time"
+
+// Event is a notification of the rksync service to the application,
+// it is one of the event types declared below
+type Event interface {
+	isEvent()
+}
+
+// FileSyncStarted is emitted when data received from a peer starts being written to a file
+type FileSyncStarted struct {
+	ChainMac ChainMac
+	ChainID  string
+	Filename string
+}
+
+// FileSyncCompleted is emitted when all the data received from peers has been written to a file
+type FileSyncCompleted struct {
+	ChainMac ChainMac
+	ChainID  string
+	Filename string
+	Written  int64 // Number of bytes written since the FileSyncStarted event
+}
+
+// FileSyncFailed is emitted when data received from a peer couldn't be written to a file
+type FileSyncFailed struct {
+	ChainMac ChainMac
+	ChainID  string
+	Filename string
+	Err      error
+}
+
+// TransferRejected is emitted on the leader of a channel when a member rejected the transfer of a file,
+// Err is a *fsync.StorageError telling whether the channel quota or the disk space of the member was exceeded
+type TransferRejected struct {
+	ChainMac ChainMac
+	ChainID  string
+	Filename string
+	PKIID    PKIidType // Member which rejected the transfer
+	Err      error
+}
+
+// OrphanDeleted is emitted when the local copy of a file no longer synchronized,
+// since it was removed from its channel or the channel was closed, is deleted
+type OrphanDeleted struct {
+	ChainID  string
+	Filename string
+}
+
+// FileVersionChanged is emitted when the leader of a channel publishes a new version of a file
+type FileVersionChanged struct {
+	ChainMac ChainMac
+	ChainID  string
+	Filename string
+	Version  uint64
+	Previous uint64 // Latest version known before, zero if none was published
+	Missed   bool   // Some versions between Previous and Version were never observed by the peer
+}
+
+// ChannelJoined is emitted when the peer creates or joins a channel
+type ChannelJoined struct {
+	ChainMac ChainMac
+	ChainID  string
+	Leader   bool // The peer is the leader of the channel
+}
+
+// MemberAdded is emitted when a member is added to a channel of the peer
+type MemberAdded struct {
+	ChainMac ChainMac
+	ChainID  string
+	PKIID    PKIidType
+}
+
+// ChannelInvited is emitted when the leader of a channel invites the peer to become a member,
+// the invitation is answered with Gossip.AnswerInvitation
+type ChannelInvited struct {
+	ChainMac ChainMac
+	ChainID  string
+	Leader   PKIidType
+	Expires  time.Time // Time after which the invitation can't be accepted
+}
+
+// InvitationAnswered is emitted on the leader of a channel when an invited peer answers the invitation,
+// the peer is a member of the channel once it accepted
+type InvitationAnswered struct {
+	ChainMac ChainMac
+	ChainID  string
+	PKIID    PKIidType
+	Accepted bool
+}
+
+// LeaderChanged is emitted when the peer learns the leader of one of its channels
+type LeaderChanged struct {
+	ChainMac ChainMac
+	ChainID  string
+	Leader   PKIidType
+}
+
+// LeaderUnreachable is emitted on a member of a channel when its leader hasn't published
+// a heartbeat for LeaderTimeout, it's emitted again if the leader recovers and is lost again
+type LeaderUnreachable struct {
+	ChainMac      ChainMac
+	ChainID       string
+	Leader        PKIidType
+	LastHeartbeat time.Time // Time the last heartbeat of the leader was received, or the channel was joined
+}
+
+// PeerDead is emitted when a peer stopped sending alive messages and is considered dead
+type PeerDead struct {
+	Member NetworkMember
+}
+
+func (FileSyncStarted) isEvent()    {}
+func (FileSyncCompleted) isEvent()  {}
+func (FileSyncFailed) isEvent()     {}
+func (FileVersionChanged) isEvent() {}
+func (TransferRejected) isEvent()   {}
+func (OrphanDeleted) isEvent()      {}
+func (ChannelJoined) isEvent()      {}
+func (MemberAdded) isEvent()        {}
+func (ChannelInvited) isEvent()     {}
+func (InvitationAnswered) isEvent() {}
+func (LeaderChanged) isEvent()      {}
+func (LeaderUnreachable) isEvent()  {}
+func (PeerDead) isEvent()           {}
@@ -8,7 +8,9 @@ SPDX-License-Identifier: Apache-2.0
 package config
 
 import (
+	"crypto"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/pem"
 	"io"
 	"io/ioutil"
@@ -17,7 +19,10 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/util"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
@@ -46,40 +51,301 @@ var (
 	// default connection timeout
 	DefaultConnectionTimeout = 5 * time.Second
 	DefaultHomeDir           = "/var/rksync/production"
+	// default address of the admin service, only reachable from the host of the peer
+	DefaultAdminAddress = "localhost:9054"
+)
+
+// Transports used to communicate with remote peers
+const (
+	TransportTCP    = "tcp"    // gRPC over TCP
+	TransportQUIC   = "quic"   // gRPC over a QUIC stream, for high-latency or lossy links
+	TransportMemory = "memory" // gRPC over in-memory connections within the process, for tests, the listener must come from server.ListenMemory
+)
+
+// Policies applied to the local copies of the files removed from a channel, or of a closed channel
+const (
+	OrphanKeep   = "keep"   // The files are never deleted
+	OrphanDelete = "delete" // The files are deleted immediately
+	OrphanRetain = "retain" // The files are deleted once OrphanRetention elapsed
+)
+
+// Policies deciding which alive messages of the peer carry its identity
+const (
+	IdentityWindow    = "window"     // During PublishCertPeriod from startup or from the rotation of the identity
+	IdentityAlways    = "always"     // Every alive message
+	IdentityOnRequest = "on-request" // During PublishCertPeriod from the last identity request of a remote peer
 )
 
 // Config defines the parameters for rksync
 type Config struct {
-	HomeDir  string // The service's home directory
-	Gossip   *GossipConfig
-	Identity *IdentityConfig
-	Server   *ServerConfig
+	HomeDir  string          `yaml:"homeDir"` // The service's home directory
+	Gossip   *GossipConfig   `yaml:"gossip"`
+	Identity *IdentityConfig `yaml:"identity"`
+	Server   *ServerConfig   `yaml:"server"`
 }
 
 // GossipConfig is the configuration of the rksync component
 type GossipConfig struct {
-	FileSystem                 FileSystem    // File system
-	BootstrapPeers             []string      // Peers we connect to at startup
-	PropagateIterations        int           // Number of times a message is pushed to remote peer
-	PropagatePeerNum           int           // Number of peers selected to push message to
-	Endpoint                   string        // Peer endpoint
-	MaxPropagationBurstSize    int           // Max number of messages stored until it triggers a push to remote peers
-	MaxPropagationBurstLatency time.Duration // Max time between consecutive message pushes
-	PullInterval               time.Duration // Determines frequency of pull phases
-	PullPeerNum                int           // Number of peers to pull from
-	PublishCertPeriod          time.Duration // Time from startup certifiates are included in Alive messages
-	PublishStateInfoInterval   time.Duration // Determines frequency of pushing state info messages to peers
-	RequestStateInfoInterval   time.Duration // Determines frequency of pulling state info message from peers
+	FileSystem                 FileSystem               `yaml:"-"`                          // File system
+	FileSystemFactory          FileSystemFactory        `yaml:"-"`                          // File system of specific channels, FileSystem for the channels it returns nil for
+	BootstrapPeers             []string                 `yaml:"bootstrapPeers"`             // Peers we connect to at startup
+	BootstrapResolveInterval   time.Duration            `yaml:"bootstrapResolveInterval"`   // Determines frequency of re-resolving the bootstrap peers host names, 0 disables it
+	PropagateIterations        int                      `yaml:"propagateIterations"`        // Number of times a message is pushed to remote peer
+	PropagatePeerNum           int                      `yaml:"propagatePeerNum"`           // Number of peers selected to push message to
+	ChannelPropagation         map[string]Propagation   `yaml:"channelPropagation"`         // Propagation of the chain states of specific channels, by channel ID
+	Endpoint                   string                   `yaml:"endpoint"`                   // Peer endpoint
+	ExternalEndpoint           string                   `yaml:"externalEndpoint"`           // Peer endpoint advertised to peers outside of its network, e.g. when behind a NAT
+	Zone                       string                   `yaml:"zone"`                       // Zone or region of the peer, files are preferably pulled from peers of the same zone
+	MaxPropagationBurstSize    int                      `yaml:"maxPropagationBurstSize"`    // Max number of messages stored until it triggers a push to remote peers
+	MaxPropagationBurstLatency time.Duration            `yaml:"maxPropagationBurstLatency"` // Max time between consecutive message pushes
+	PullInterval               time.Duration            `yaml:"pullInterval"`               // Determines frequency of pull phases
+	PullPeerNum                int                      `yaml:"pullPeerNum"`                // Number of peers to pull from
+	PublishCertPeriod          time.Duration            `yaml:"publishCertPeriod"`          // Time from startup certifiates are included in Alive messages
+	IdentityInclusion          string                   `yaml:"identityInclusion"`          // Which alive messages carry the identity of the peer, IdentityWindow (default), IdentityAlways or IdentityOnRequest
+	PublishStateInfoInterval   time.Duration            `yaml:"publishStateInfoInterval"`   // Determines frequency of pushing state info messages to peers
+	RequestStateInfoInterval   time.Duration            `yaml:"requestStateInfoInterval"`   // Determines frequency of pulling state info message from peers
+	LeaderTimeout              time.Duration            `yaml:"leaderTimeout"`              // Time without heartbeat after which the leader of a channel is reported unreachable, defaults to 5 times PublishStateInfoInterval
+	Tags                       []string                 `yaml:"tags"`                       // Group tags assigned to this peer, e.g: "region=eu", they aren't verified by the remote peers
+	Labels                     map[string]string        `yaml:"labels"`                     // Key/value metadata attached to this peer, e.g: role: ingest, carried by its alive messages
+	StaticTopology             bool                     `yaml:"staticTopology"`             // Disables discovery, the members are the BootstrapPeers
+	AliveTimeInterval          time.Duration            `yaml:"aliveTimeInterval"`          // Determines frequency of sending alive messages, defaults to 5s
+	AliveExpirationTimeout     time.Duration            `yaml:"aliveExpirationTimeout"`     // Time a silent peer is considered alive, defaults to 5 times AliveTimeInterval
+	ReconnectInterval          time.Duration            `yaml:"reconnectInterval"`          // Delay before the first attempt to reconnect to a dead peer, doubled at each failure, defaults to AliveTimeInterval
+	MaxReconnectInterval       time.Duration            `yaml:"maxReconnectInterval"`       // Max delay between the attempts to reconnect to a dead peer, defaults to 2m
+	MaxChannelMembers          int                      `yaml:"maxChannelMembers"`          // Max number of members of a channel
+	MaxChannelFiles            int                      `yaml:"maxChannelFiles"`            // Max number of files of a channel
+	MaxChainStateSize          int                      `yaml:"maxChainStateSize"`          // Max size in bytes of an encoded chain state
+	ChannelQuota               int64                    `yaml:"channelQuota"`               // Max size in bytes of the files of a channel stored by the peer, 0 disables it
+	ChannelQuotas              map[string]int64         `yaml:"channelQuotas"`              // ChannelQuota of specific channels, by channel ID
+	MaxConcurrentTransfers     int                      `yaml:"maxConcurrentTransfers"`     // Max number of files received concurrently across the channels, the others wait their turn, 0 disables the limit
+	OrphanPolicy               string                   `yaml:"orphanPolicy"`               // What to do with the files no longer synchronized, OrphanKeep (default), OrphanDelete or OrphanRetain
+	OrphanRetention            time.Duration            `yaml:"orphanRetention"`            // Time the orphaned files are kept with OrphanRetain, e.g. "72h"
+	MaxMessageSize             int                      `yaml:"maxMessageSize"`             // Max size in bytes of a message sent or received, file data is sent in smaller chunks
+	Transport                  string                   `yaml:"transport"`                  // Transport used to dial remote peers, TransportTCP (default), TransportQUIC or TransportMemory
+	QUICHandshakeTimeout       time.Duration            `yaml:"quicHandshakeTimeout"`       // Time the QUIC handshake may take before the peer is dialed over TCP, with TransportQUIC
+	QUICFallbackPeriod         time.Duration            `yaml:"quicFallbackPeriod"`         // Time the peers failing the QUIC handshake several times in a row are dialed over TCP only, with TransportQUIC
+	WebSocketAddress           string                   `yaml:"webSocketAddress"`           // Address the peer also accepts WebSocket connections on, e.g. ":8080", disabled if empty
+	AccessControl              AccessControlPolicy      `yaml:"-"`                          // Restricts the peers allowed to join or lead channels
+	DisclosurePolicy           DisclosurePolicy         `yaml:"-"`                          // Restricts the peers disclosed to the remote peers, all of them are disclosed if nil
+	BlockedPeers               []string                 `yaml:"blockedPeers"`               // Hex encoded PKI-IDs or node IDs of the peers whose connections and messages are refused
+	AllowedPeers               []string                 `yaml:"allowedPeers"`               // If not empty, only the peers with these hex encoded PKI-IDs or node IDs are accepted
+	DeadLetterHandler          DeadLetterHandler        `yaml:"-"`                          // Receives the messages discarded by the gossip layer
+	DeadLetterRate             int                      `yaml:"deadLetterRate"`             // Max number of dead letters reported per second
+	AliveMsgTTL                int                      `yaml:"aliveMsgTTL"`                // Max number of times an alive message is forwarded
+	ChainStateMsgTTL           int                      `yaml:"chainStateMsgTTL"`           // Max number of times a chain state message is forwarded
+	DedupCacheSize             int                      `yaml:"dedupCacheSize"`             // Max number of gossiped message digests remembered to suppress duplicates
+	DedupCacheTTL              time.Duration            `yaml:"dedupCacheTTL"`              // Time a gossiped message digest is remembered
+	MaxMessageAge              time.Duration            `yaml:"maxMessageAge"`              // Signed messages stamped further than this from the local time are discarded as replays, defaults to 5m
+	PeerMessageRate            int                      `yaml:"peerMessageRate"`            // Max number of messages handled per second from a peer, the others are discarded and lower its reputation, 0 disables the limit
+	ReputationThreshold        int                      `yaml:"reputationThreshold"`        // Score out of 100 below which a misbehaving peer is only selected when there aren't enough other peers, defaults to 50
+	ReputationBanTime          time.Duration            `yaml:"reputationBanTime"`          // Time a peer whose score fell to 0 is disconnected and its messages discarded, defaults to 10m
+	IdentityCacheTTL           time.Duration            `yaml:"identityCacheTTL"`           // Time after which the identity of a peer neither used nor in the membership is purged
+	IdentityCacheSize          int                      `yaml:"identityCacheSize"`          // Max number of peer identities held, the least recently used ones not in the membership are purged first
+	MessageWorkers             int                      `yaml:"messageWorkers"`             // Number of goroutines verifying and handling the received messages, those of a peer are handled in order, defaults to the number of CPUs
+	ChainStateStoreSize        int                      `yaml:"chainStateStoreSize"`        // Max number of chain state messages kept in memory, the least recently used ones are evicted
+	ChainStateStoreBytes       int                      `yaml:"chainStateStoreBytes"`       // Max total size in bytes of the chain state messages kept in memory
+	ChainStateSpillDir         string                   `yaml:"chainStateSpillDir"`         // Directory the evicted chain state messages are written to, they are dropped if empty
+	ChangeLogDir               string                   `yaml:"changeLogDir"`               // Directory the leader-signed change logs of the channels are persisted to, kept in memory only if empty
+	JournalDir                 string                   `yaml:"journalDir"`                 // Directory the journals of the chain states applied by the members are persisted to, kept in memory only if empty
+	MembershipFile             string                   `yaml:"membershipFile"`             // File the live membership is persisted to on shutdown, its peers are connected along with the bootstrap peers on startup
+	SendBuffSize               int                      `yaml:"sendBuffSize"`               // Max number of messages of each priority buffered per peer, defaults to 20
+	MaxConnections             int                      `yaml:"maxConnections"`             // Max number of connections with remote peers, the least recently used ones are evicted, 0 disables the limit
+	IdleConnTimeout            time.Duration            `yaml:"idleConnTimeout"`            // Connections idle for this duration are closed, 0 disables it
+	MaxHandshakes              int                      `yaml:"maxHandshakes"`              // Max number of handshakes with connecting peers performed concurrently, the others are refused, 0 disables the limit
+	HandshakeRate              int                      `yaml:"handshakeRate"`              // Max number of connection attempts accepted per second from an IP address, 0 disables the limit
+	MinProtocolVersion         uint32                   `yaml:"minProtocolVersion"`         // Oldest version of the protocol the peers must speak, the peers which predate the negotiation are accepted if 0
+	CircuitBreakerThreshold    int                      `yaml:"circuitBreakerThreshold"`    // Number of failures within CircuitBreakerTimeout after which a peer isn't selected anymore, 0 disables it
+	CircuitBreakerTimeout      time.Duration            `yaml:"circuitBreakerTimeout"`      // Time a failing peer isn't selected before being retried, defaults to 30s
+	ControlOverflowPolicy      common.OverflowPolicy    `yaml:"-"`                          // Applied to control plane messages sent to a peer whose buffer is full, defaults to DropOldest
+	DataOverflowPolicy         common.OverflowPolicy    `yaml:"-"`                          // Applied to file data messages sent to a peer whose buffer is full, defaults to Block
+	SendBlockTimeout           time.Duration            `yaml:"sendBlockTimeout"`           // Max time a message sent under the Block policy waits for room in the buffer before being dropped, defaults to 10s
+	MinAlivePeers              int                      `yaml:"minAlivePeers"`              // Min number of alive members for the health service to report the peer as serving
+	HealthCheckInterval        time.Duration            `yaml:"healthCheckInterval"`        // Determines frequency of updating the health service status
+	AdminService               bool                     `yaml:"adminService"`               // Serves the admin service used to introspect the peer and administrate its channels
+	AdminAddress               string                   `yaml:"adminAddress"`               // Address the admin service listens on, apart from the gossip endpoint, defaults to DefaultAdminAddress
+	AdminDebug                 bool                     `yaml:"adminDebug"`                 // Enables the DebugDump call of the admin service, which reports the goroutine stacks and the depth of the message queues
+	ProfilingAddress           string                   `yaml:"profilingAddress"`           // Address the pprof handlers are served on over HTTP, e.g. "localhost:6060", disabled if empty
+	RequireInvitation          bool                     `yaml:"requireInvitation"`          // The peer only joins the channels whose invitation it accepted, instead of every channel listing it as a member
+	InvitationTTL              time.Duration            `yaml:"invitationTTL"`              // Time an invitation to join a channel can be accepted, defaults to 24h
+	InvitationsFile            string                   `yaml:"invitationsFile"`            // File the channels whose invitations the peer accepted are persisted to, kept in memory only if empty
+	Logger                     logging.Logger           `yaml:"-"`                          // Logger the log entries are routed to, the package-global logger if nil
+	LogLevels                  map[string]logging.Level `yaml:"logLevels"`                  // Min level of the log entries per module, e.g. "discovery", "rpc"
+	Clock                      lib.Clock                `yaml:"-"`                          // Clock of the timers and expirations, the system clock if nil, tests can fast-forward a lib.FakeClock
+}
+
+// GossipConfigUpdate holds the gossip parameters that can be changed at runtime,
+// nil fields are left unchanged
+type GossipConfigUpdate struct {
+	BootstrapPeers             []string
+	PropagatePeerNum           *int
+	MaxPropagationBurstSize    *int
+	MaxPropagationBurstLatency *time.Duration
+	PullInterval               *time.Duration
+	PullPeerNum                *int
+}
+
+// Apply returns a copy of the configuration with the update applied
+func (u *GossipConfigUpdate) Apply(conf *GossipConfig) (*GossipConfig, error) {
+	c := *conf
+	if u.BootstrapPeers != nil {
+		if len(u.BootstrapPeers) == 0 {
+			return nil, errors.New("At least one bootstrap peer needs to be provided")
+		}
+		c.BootstrapPeers = append([]string{}, u.BootstrapPeers...)
+	}
+	if u.PropagatePeerNum != nil {
+		if *u.PropagatePeerNum <= 0 {
+			return nil, errors.Errorf("Invalid PropagatePeerNum %d", *u.PropagatePeerNum)
+		}
+		c.PropagatePeerNum = *u.PropagatePeerNum
+	}
+	if u.MaxPropagationBurstSize != nil {
+		if *u.MaxPropagationBurstSize <= 0 {
+			return nil, errors.Errorf("Invalid MaxPropagationBurstSize %d", *u.MaxPropagationBurstSize)
+		}
+		c.MaxPropagationBurstSize = *u.MaxPropagationBurstSize
+	}
+	if u.MaxPropagationBurstLatency != nil {
+		if *u.MaxPropagationBurstLatency <= 0 {
+			return nil, errors.Errorf("Invalid MaxPropagationBurstLatency %s", *u.MaxPropagationBurstLatency)
+		}
+		c.MaxPropagationBurstLatency = *u.MaxPropagationBurstLatency
+	}
+	if u.PullInterval != nil {
+		if *u.PullInterval <= 0 {
+			return nil, errors.Errorf("Invalid PullInterval %s", *u.PullInterval)
+		}
+		c.PullInterval = *u.PullInterval
+	}
+	if u.PullPeerNum != nil {
+		if *u.PullPeerNum <= 0 {
+			return nil, errors.Errorf("Invalid PullPeerNum %d", *u.PullPeerNum)
+		}
+		c.PullPeerNum = *u.PullPeerNum
+	}
+	return &c, nil
+}
+
+// Propagation overrides the fan-out of the chain states of a channel, zero values keep the gossip settings.
+// Iterations only apply if the propagation isn't disabled, i.e. PropagateIterations isn't 0.
+type Propagation struct {
+	PeerNum    int `yaml:"peerNum"`    // Number of peers selected to push the chain states to
+	Iterations int `yaml:"iterations"` // Number of times the chain states are pushed
+}
+
+// PeerInfo describes a peer submitted to the access control policy
+type PeerInfo struct {
+	NodeID      string
+	Org         string
+	Certificate *x509.Certificate
+}
+
+// AccessControlPolicy decides which peers may take part in a channel,
+// a non-nil error denies the access.
+type AccessControlPolicy interface {
+	// CanJoin checks whether the peer may be a member of the channel
+	CanJoin(chainID string, peer PeerInfo) error
+
+	// CanLead checks whether the peer may be the leader of the channel
+	CanLead(chainID string, peer PeerInfo) error
 }
 
+// DisclosurePolicy decides which peers a remote peer learns about through the alive and membership messages,
+// e.g. to hide the peers internal to an organization from the external ones
+type DisclosurePolicy interface {
+	// Disclose checks whether the remote peer may know about the peer
+	Disclose(remotePeer, peer common.NetworkMember) bool
+}
+
+// DeadLetter describes a message discarded by the gossip layer
+type DeadLetter struct {
+	Sender  common.PKIidType      // Peer the message was received from, nil if produced locally
+	Message *protos.RKSyncMessage // The discarded message
+	Reason  string                // Why the message has been discarded
+}
+
+// DeadLetterHandler is invoked for every reported dead letter, it must not block.
+type DeadLetterHandler func(DeadLetter)
+
 // IdentityConfig defines the identity parameters for peer
 type IdentityConfig struct {
-	ID string // ID of this instance
+	ID     string        `yaml:"id"`     // ID of this instance
+	Org    string        `yaml:"org"`    // Organization or tenant of this instance, carried by its identity
+	PKCS11 *PKCS11Config `yaml:"pkcs11"` // PKCS#11 module holding the private key, opened when built with the pkcs11 tag or by the factory registered with identity.RegisterPKCS11SignerFactory, the keystore is used if nil
+	SPIFFE *SPIFFEConfig `yaml:"spiffe"` // SPIFFE trust domain, the identity is an X.509 SVID instead of the certificates of the home directory if set
+
+	// Provider handles the identities instead of the built-in X.509 and SPIFFE support if set,
+	// it can't be loaded from a configuration file
+	Provider IdentityProvider `yaml:"-"`
 
+	homeDir         string
 	keyStoreDir     string
 	cert            []byte
 	rootCAs         [][]byte
 	intermediateCAs [][]byte
+	crls            [][]byte
+}
+
+// PKCS11Config defines the PKCS#11 module parameters used to sign with an HSM-backed key
+type PKCS11Config struct {
+	Library string `yaml:"library"` // Path of the PKCS#11 module
+	Slot    uint   `yaml:"slot"`    // Slot containing the private key
+	Pin     string `yaml:"pin"`     // User PIN of the token
+	Label   string `yaml:"label"`   // Label of the private key
+}
+
+// IdentityProvider handles the identities of the peers. The X.509 certificates of the home directory
+// are used if none is set, deployments can plug in e.g. JWT-based or ledger-registered identities.
+type IdentityProvider interface {
+	// Serialize returns the identity of this peer, it may change over time, e.g. when a credential is renewed
+	Serialize() (common.PeerIdentityType, error)
+	// Validate checks the identity of a peer and returns the time it expires, zero if it doesn't
+	Validate(identity common.PeerIdentityType) (time.Time, error)
+	// PKIID returns the PKI-ID of the identity, a renewed identity should keep the PKI-ID
+	PKIID(identity common.PeerIdentityType) (common.PKIidType, error)
+	// Sign signs the message with the key of this peer
+	Sign(msg []byte) ([]byte, error)
+	// Verify checks the signature of the message by the peer holding the identity
+	Verify(identity common.PeerIdentityType, signature, message []byte) error
+	// SignatureAlgorithm returns the algorithm of the signatures, UNSPECIFIED for other schemes
+	SignatureAlgorithm() protos.SignatureAlgorithm
+}
+
+// IdentityReloader is implemented by the identity providers whose trust material, e.g. the CA
+// certificates and the CRLs, changes at runtime. It's reloaded before the suspected peers are revalidated.
+type IdentityReloader interface {
+	// Reload loads the current trust material
+	Reload() error
+}
+
+// SignatureAlgorithmResolver is implemented by the identity providers which tell the signature algorithm of
+// the key of an identity, the algorithm recorded along with the signatures of the peer is checked against it
+type SignatureAlgorithmResolver interface {
+	// SignatureAlgorithmOf returns the signature algorithm of the key of the identity
+	SignatureAlgorithmOf(identity common.PeerIdentityType) (protos.SignatureAlgorithm, error)
+}
+
+// SPIFFEConfig defines the SPIFFE trust domain of the peers, the X.509 SVIDs and the trust
+// bundle are delivered by the Source, typically backed by the SPIFFE Workload API
+type SPIFFEConfig struct {
+	TrustDomain string     `yaml:"trustDomain"` // Trust domain of the peers, e.g. example.org
+	Source      SVIDSource `yaml:"-"`           // Source of the X.509 SVIDs, it can't be loaded from a configuration file
+}
+
+// SVID is an X.509 SPIFFE Verifiable Identity Document
+type SVID struct {
+	ID           string              // SPIFFE ID, e.g. spiffe://example.org/peer0
+	Certificates []*x509.Certificate // Leaf certificate followed by the intermediate certificates
+	PrivateKey   crypto.Signer       // Private key of the leaf certificate
+}
+
+// SVIDSource delivers the X.509 SVID of the workload, rotated before it expires, and the trust
+// bundle. The X509Source of the go-spiffe Workload API client can be adapted to it.
+type SVIDSource interface {
+	// GetX509SVID returns the current SVID of the workload
+	GetX509SVID() (*SVID, error)
+	// GetTrustBundle returns the root certificates of the trust domain
+	GetTrustBundle() ([]*x509.Certificate, error)
 }
 
 // GetCertificate returns the certificate file associated with the configuration
@@ -102,14 +368,41 @@ func (c *IdentityConfig) GetIntermediateCAs() [][]byte {
 	return c.intermediateCAs
 }
 
+// GetCRLs returns the certificate revocation lists associated with the configuration
+func (c *IdentityConfig) GetCRLs() [][]byte {
+	return c.crls
+}
+
 // MakeFilesAbs makes files absolute relative to 'HomeDir' if not already absolute
 func (c *IdentityConfig) MakeFilesAbs(homedir string) error {
 	if homedir == "" {
 		return errors.New("HomeDir must be provided")
 	}
 
+	c.homeDir = homedir
 	c.keyStoreDir = filepath.Join(homedir, "csp", "keystore")
+	if c.SPIFFE != nil || c.Provider != nil {
+		return nil
+	}
 
+	err := c.setupTrustMaterial(homedir)
+	if err != nil {
+		return err
+	}
+
+	return c.setupCertificate(homedir)
+}
+
+// ReloadTrustMaterial reads the CA certificates and the CRLs of the home directory again,
+// e.g. after a root CA was rotated or a certificate was revoked
+func (c *IdentityConfig) ReloadTrustMaterial() error {
+	if c.homeDir == "" {
+		return errors.New("HomeDir must be provided")
+	}
+	return c.setupTrustMaterial(c.homeDir)
+}
+
+func (c *IdentityConfig) setupTrustMaterial(homedir string) error {
 	err := c.setupRootCAs(homedir)
 	if err != nil {
 		return err
@@ -120,7 +413,7 @@ func (c *IdentityConfig) MakeFilesAbs(homedir string) error {
 		return err
 	}
 
-	return c.setupCertificate(homedir)
+	return c.setupCRLs(homedir)
 }
 
 func (c *IdentityConfig) setupCertificate(homedir string) error {
@@ -171,6 +464,23 @@ func (c *IdentityConfig) setupIntermediateCAs(homedir string) error {
 	return nil
 }
 
+func (c *IdentityConfig) setupCRLs(homedir string) error {
+	crlsDir, err := util.MakeFileAbs("csp/crls", homedir)
+	if err != nil {
+		return err
+	}
+
+	crls, err := getPemMaterialFromDir(crlsDir)
+	if os.IsNotExist(err) {
+		logging.Debugf("CRLs folder not found at [%s]. Skipping. [%s]", crlsDir, err)
+	} else if err != nil {
+		return errors.WithMessagef(err, "failed loading CRLs at [%s]", crlsDir)
+	}
+
+	c.crls = crls
+	return nil
+}
+
 func getPemMaterialFromDir(dir string) ([][]byte, error) {
 	_, err := os.Stat(dir)
 	if os.IsNotExist(err) {
@@ -222,9 +532,9 @@ func readPemFile(file string) ([]byte, error) {
 
 // ServerConfig defines the parameters for configuring a GRPCServer instance
 type ServerConfig struct {
-	ConnectionTimeout time.Duration
-	SecOpts           *TLSConfig
-	KaOpts            *KeepaliveConfig
+	ConnectionTimeout time.Duration    `yaml:"connectionTimeout"`
+	SecOpts           *TLSConfig       `yaml:"tls"`
+	KaOpts            *KeepaliveConfig `yaml:"keepalive"`
 }
 
 // KeepaliveConfig is used to set the gRPC keepalive settings for both
@@ -232,30 +542,34 @@ type ServerConfig struct {
 type KeepaliveConfig struct {
 	// ClientInterval is the duration after which if the client does not see
 	// any activity from the server it pings the server to see if it is alive
-	ClientInterval time.Duration
+	ClientInterval time.Duration `yaml:"clientInterval"`
 	// ClientTimeout is the duration the client waits for a response
 	// from the server after sending a ping before closing the connection
-	ClientTimeout time.Duration
+	ClientTimeout time.Duration `yaml:"clientTimeout"`
 	// ServerInterval is the duration after which if the server does not see
 	// any activity from the client it pings the client to see if it is alive
-	ServerInterval time.Duration
+	ServerInterval time.Duration `yaml:"serverInterval"`
 	// ServerTimeout is the duration the server waits for a response
 	// from the client after sending a ping before closing the connection
-	ServerTimeout time.Duration
+	ServerTimeout time.Duration `yaml:"serverTimeout"`
 	// ServerMinInterval is the minimum permitted time between client pings.
 	// If clients send pings more frequently, the server will disconnect them
-	ServerMinInterval time.Duration
+	ServerMinInterval time.Duration `yaml:"serverMinInterval"`
 }
 
 // TLSConfig defines the TLS parameters for a gRPC server or gRPC client instance
 type TLSConfig struct {
-	Certificate       string
-	Key               string
-	ServerRootCAs     []string
-	ClientRootCAs     []string
-	UseTLS            bool
-	RequireClientCert bool
-	CipherSuites      []uint16
+	Certificate       string   `yaml:"certificate"`
+	Key               string   `yaml:"key"`
+	ServerRootCAs     []string `yaml:"serverRootCAs"`
+	ClientRootCAs     []string `yaml:"clientRootCAs"`
+	UseTLS            bool     `yaml:"useTLS"`
+	RequireClientCert bool     `yaml:"requireClientCert"`
+	CipherSuites      []uint16 `yaml:"cipherSuites"`
+	// PEM files of the certificates the remote peers must present during the TLS handshake, any certificate
+	// trusted by the root CAs is accepted if empty. The rksync identity certificates may be pinned if the peers
+	// use them for TLS as well.
+	PinnedCertificates []string `yaml:"pinnedCertificates"`
 }
 
 // ServerKeepaliveOptions returns gRPC keepalive options for server.
@@ -310,6 +624,60 @@ type FileSystem interface {
 
 	// Stat returns a FileInfo describing the named file.
 	Stat(chainID string, fmeta FileMeta) (os.FileInfo, error)
+
+	// Rename renames (moves) a file, replacing the destination if it exists.
+	// It should be atomic, so that the destination is never observed partially written.
+	Rename(chainID string, from, to FileMeta) error
+}
+
+// FileSystemFactory returns the FileSystem the files of the channel are stored in, nil to store them in
+// GossipConfig.FileSystem. It's called when the channel is joined and when its files are exported, imported
+// or deleted, so it must return the same FileSystem for a channel.
+type FileSystemFactory func(chainID string) FileSystem
+
+// ChannelFileSystem returns the FileSystem the factory returns for the channel, fs if the factory is nil or returns nil
+func ChannelFileSystem(factory FileSystemFactory, fs FileSystem, chainID string) FileSystem {
+	if factory != nil {
+		if channelFS := factory(chainID); channelFS != nil {
+			return channelFS
+		}
+	}
+	return fs
+}
+
+// FileAttributes are the attributes of the leader's copy of a file preserved on the members' copies
+type FileAttributes struct {
+	Perm       os.FileMode // Zero if unspecified
+	ModTime    time.Time
+	Attributes map[string]string
+}
+
+// AttributesSetter is implemented by the FileSystems preserving the attributes of the synchronized files.
+// SetAttributes is called once the data received for a file has been written.
+type AttributesSetter interface {
+	SetAttributes(chainID string, fmeta FileMeta, attrs FileAttributes) error
+}
+
+// SpaceReporter is implemented by the FileSystems reporting the free space of their storage,
+// files are rejected before being transferred if they would fill it
+type SpaceReporter interface {
+	// FreeSpace returns the number of bytes available to store the files of the channel
+	FreeSpace(chainID string) (int64, error)
+}
+
+// FileRemover is implemented by the FileSystems able to delete files,
+// which is required for the orphaned files to be deleted
+type FileRemover interface {
+	// Remove deletes the named file, it succeeds if the file doesn't exist
+	Remove(chainID string, fmeta FileMeta) error
+}
+
+// FileAppender is implemented by the FileSystems able to append a file to another atomically, so that the
+// destination is never observed partially appended. The data received for a synchronized file is then appended
+// to it, rather than copied along with the file to a working copy which replaces it.
+type FileAppender interface {
+	// Append appends the content of the file from to the file to, and deletes the file from
+	Append(chainID string, from, to FileMeta) error
 }
 
 // File represents a file in the filesystem
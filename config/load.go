@@ -0,0 +1,77 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Load reads the configuration from a YAML or JSON file, the keys are the
+// lower camel case names of the fields, e.g. "bootstrapPeers", and durations
+// are written like "4s". References to environment variables, ${NAME} or
+// ${NAME:-default}, are replaced by their value before the file is parsed.
+// The parameters missing from the file are set to their default value,
+// a relative home directory is relative to the directory of the file.
+//
+// The FileSystem of the gossip configuration can't be loaded from a file,
+// it must be set before the configuration is used.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read configuration file %s", path)
+	}
+	data, err = expandEnv(data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid configuration file %s", path)
+	}
+
+	cfg := &Config{
+		HomeDir:  DefaultHomeDir,
+		Gossip:   &GossipConfig{},
+		Identity: &IdentityConfig{},
+	}
+	cfg.Gossip.SetDefaults()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrapf(err, "could not parse configuration file %s", path)
+	}
+
+	if !filepath.IsAbs(cfg.HomeDir) {
+		cfg.HomeDir = filepath.Join(filepath.Dir(path), cfg.HomeDir)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.WithMessagef(err, "invalid configuration file %s", path)
+	}
+	return cfg, nil
+}
+
+// expandEnv replaces the references to environment variables,
+// a variable that isn't set and has no default value is an error
+func expandEnv(data []byte) ([]byte, error) {
+	var err error
+	res := envVarRegexp.ReplaceAllFunc(data, func(ref []byte) []byte {
+		m := envVarRegexp.FindSubmatch(ref)
+		if value, exists := os.LookupEnv(string(m[1])); exists {
+			return []byte(value)
+		}
+		if m[2] != nil {
+			return m[3]
+		}
+		if err == nil {
+			err = errors.Errorf("environment variable %s is not set", m[1])
+		}
+		return ref
+	})
+	return res, err
+}
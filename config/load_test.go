@@ -0,0 +1,108 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "rksync-config")
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	home, err := filepath.Abs("../tests/fixtures/identity/peer0")
+	require.NoError(t, err)
+	os.Setenv("RKSYNC_TEST_ENDPOINT", "peer0.org1:9053")
+	defer os.Unsetenv("RKSYNC_TEST_ENDPOINT")
+
+	path := writeConfigFile(t, "rksync.yaml", `
+homeDir: `+home+`
+gossip:
+  endpoint: ${RKSYNC_TEST_ENDPOINT}
+  bootstrapPeers: ["${RKSYNC_TEST_BOOTSTRAP:-peer1.org2:9053}"]
+  pullInterval: 10s
+  staticTopology: true
+  logLevels:
+    rpc: warning
+identity:
+  id: peer0.org1
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, home, cfg.HomeDir)
+	assert.Equal(t, "peer0.org1:9053", cfg.Gossip.Endpoint)
+	assert.Equal(t, []string{"peer1.org2:9053"}, cfg.Gossip.BootstrapPeers)
+	assert.Equal(t, 10*time.Second, cfg.Gossip.PullInterval)
+	assert.True(t, cfg.Gossip.StaticTopology)
+	assert.Equal(t, logging.WarnLevel, cfg.Gossip.LogLevels["rpc"])
+	assert.Equal(t, 3, cfg.Gossip.PullPeerNum)
+	assert.Equal(t, TransportTCP, cfg.Gossip.Transport)
+	assert.Equal(t, "peer0.org1", cfg.Identity.ID)
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeConfigFile(t, "rksync.json", `{
+	"homeDir": "home",
+	"gossip": {
+		"endpoint": "peer0.org1:9053",
+		"bootstrapPeers": ["peer1.org2:9053"],
+		"maxConnections": 10
+	},
+	"identity": {"id": "peer0.org1"}
+}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// the home directory is relative to the configuration file
+	_, err := Load(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), filepath.Join(filepath.Dir(path), "home", "csp/signcerts")+" doesn't exist")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	home, err := filepath.Abs("../tests/fixtures/identity/peer0")
+	require.NoError(t, err)
+
+	for content, expected := range map[string]string{
+		"gossip:\n  endpoint: ${RKSYNC_TEST_UNSET}\n":                                                               "environment variable RKSYNC_TEST_UNSET is not set",
+		"gossip:\n  endpoint: [\n":                                                                                  "could not parse configuration file",
+		"gossip:\n  bootstrapPeers: [p1]\n":                                                                         "Must specify the endpoint address of the peer",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  pullInterval: 0s\n":                                     "PullInterval must be positive",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  transport: udp\n":                                       "Unknown transport udp",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  quicHandshakeTimeout: -1s\n":                            "QUICHandshakeTimeout must be positive",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n":                                                         "Must specify the ID of the peer",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  channelQuotas:\n    c1: -1\n":                           "Quota of channel c1 can't be negative",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  channelPropagation:\n    c1:\n      peerNum: -1\n":      "Propagation of channel c1 can't be negative",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  orphanPolicy: retain\n":                                 "OrphanRetention must be positive",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  reputationThreshold: 101\n":                             "ReputationThreshold must be between 0 and 100",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  blockedPeers: [\"\"]\n":                                 "Blocked and allowed peers can't be empty",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  orphanPolicy: purge\n":                                  "Unknown orphan policy purge",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  identityInclusion: never\n":                             "Unknown identity inclusion policy never",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\n  minProtocolVersion: 99\n":                               "MinProtocolVersion 99 is newer than the version spoken",
+		"gossip:\n  endpoint: p0\n  bootstrapPeers: [p1]\nidentity:\n  id: p0\nserver:\n  tls:\n    useTLS: true\n": "Must specify the TLS certificate and key",
+	} {
+		path := writeConfigFile(t, "rksync.yaml", "homeDir: "+home+"\n"+content)
+		_, err := Load(path)
+		os.RemoveAll(filepath.Dir(path))
+		require.Error(t, err, content)
+		assert.Contains(t, err.Error(), expected)
+	}
+}
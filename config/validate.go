@@ -0,0 +1,348 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// minMessageSize is the smallest MaxMessageSize leaving room for file data and chain states
+const minMessageSize = 16 * 1024
+
+// maxLabelsSize is the largest total size of the labels of a peer, they're carried by each of its alive messages
+const maxLabelsSize = 1024
+
+// SetDefaults sets the unset parameters to their default value
+func (c *GossipConfig) SetDefaults() {
+	if c.PropagateIterations == 0 {
+		c.PropagateIterations = 1
+	}
+	if c.PropagatePeerNum == 0 {
+		c.PropagatePeerNum = 3
+	}
+	if c.MaxPropagationBurstSize == 0 {
+		c.MaxPropagationBurstSize = 10
+	}
+	if c.MaxPropagationBurstLatency == time.Duration(0) {
+		c.MaxPropagationBurstLatency = 10 * time.Millisecond
+	}
+	if c.PullInterval == time.Duration(0) {
+		c.PullInterval = 4 * time.Second
+	}
+	if c.PullPeerNum == 0 {
+		c.PullPeerNum = 3
+	}
+	if c.PublishCertPeriod == time.Duration(0) {
+		c.PublishCertPeriod = 20 * time.Second
+	}
+	if c.PublishStateInfoInterval == time.Duration(0) {
+		c.PublishStateInfoInterval = 4 * time.Second
+	}
+	if c.RequestStateInfoInterval == time.Duration(0) {
+		c.RequestStateInfoInterval = 4 * time.Second
+	}
+	if c.LeaderTimeout == time.Duration(0) {
+		c.LeaderTimeout = 5 * c.PublishStateInfoInterval
+	}
+	if c.AdminService && c.AdminAddress == "" {
+		c.AdminAddress = DefaultAdminAddress
+	}
+	if c.DeadLetterRate == 0 {
+		c.DeadLetterRate = 10
+	}
+	if c.MaxChannelMembers == 0 {
+		c.MaxChannelMembers = 1000
+	}
+	if c.MaxChannelFiles == 0 {
+		c.MaxChannelFiles = 10000
+	}
+	if c.MaxChainStateSize == 0 {
+		c.MaxChainStateSize = 4 * 1024 * 1024
+	}
+	if c.MaxMessageSize == 0 {
+		c.MaxMessageSize = MaxRecvMsgSize
+	}
+	if c.Transport == "" {
+		c.Transport = TransportTCP
+	}
+	if c.QUICHandshakeTimeout == time.Duration(0) {
+		c.QUICHandshakeTimeout = 2 * time.Second
+	}
+	if c.QUICFallbackPeriod == time.Duration(0) {
+		c.QUICFallbackPeriod = 10 * time.Minute
+	}
+	if c.AliveMsgTTL == 0 {
+		c.AliveMsgTTL = 8
+	}
+	if c.ChainStateMsgTTL == 0 {
+		c.ChainStateMsgTTL = 8
+	}
+	if c.DedupCacheSize == 0 {
+		c.DedupCacheSize = 10000
+	}
+	if c.IdentityCacheTTL == time.Duration(0) {
+		c.IdentityCacheTTL = time.Hour
+	}
+	if c.IdentityCacheSize == 0 {
+		c.IdentityCacheSize = 10000
+	}
+	if c.MessageWorkers == 0 {
+		c.MessageWorkers = runtime.NumCPU()
+	}
+	if c.DedupCacheTTL == time.Duration(0) {
+		c.DedupCacheTTL = time.Minute
+	}
+	if c.MaxMessageAge == time.Duration(0) {
+		c.MaxMessageAge = 5 * time.Minute
+	}
+	if c.ReputationThreshold == 0 {
+		c.ReputationThreshold = 50
+	}
+	if c.ReputationBanTime == time.Duration(0) {
+		c.ReputationBanTime = 10 * time.Minute
+	}
+	if c.ChainStateStoreSize == 0 {
+		c.ChainStateStoreSize = 10000
+	}
+	if c.ChainStateStoreBytes == 0 {
+		c.ChainStateStoreBytes = 128 * 1024 * 1024
+	}
+	if c.HealthCheckInterval == time.Duration(0) {
+		c.HealthCheckInterval = time.Second
+	}
+	if c.OrphanPolicy == "" {
+		c.OrphanPolicy = OrphanKeep
+	}
+	if c.IdentityInclusion == "" {
+		c.IdentityInclusion = IdentityWindow
+	}
+	if c.InvitationTTL == time.Duration(0) {
+		c.InvitationTTL = 24 * time.Hour
+	}
+}
+
+// Validate rejects the nonsensical parameters, the defaults are expected to be set already.
+// The FileSystem isn't checked since it can't be loaded from a configuration file.
+func (c *GossipConfig) Validate() error {
+	if len(c.BootstrapPeers) == 0 {
+		return errors.New("At least one bootstrap peer needs to be provided")
+	}
+	if c.Endpoint == "" {
+		return errors.New("Must specify the endpoint address of the peer")
+	}
+	for _, p := range []struct {
+		name  string
+		value int
+	}{
+		{"PropagateIterations", c.PropagateIterations},
+		{"PropagatePeerNum", c.PropagatePeerNum},
+		{"MaxPropagationBurstSize", c.MaxPropagationBurstSize},
+		{"PullPeerNum", c.PullPeerNum},
+		{"MaxChannelMembers", c.MaxChannelMembers},
+		{"MaxChannelFiles", c.MaxChannelFiles},
+		{"MaxChainStateSize", c.MaxChainStateSize},
+		{"AliveMsgTTL", c.AliveMsgTTL},
+		{"ChainStateMsgTTL", c.ChainStateMsgTTL},
+		{"MessageWorkers", c.MessageWorkers},
+		{"IdentityCacheSize", c.IdentityCacheSize},
+		{"ChainStateStoreSize", c.ChainStateStoreSize},
+		{"ChainStateStoreBytes", c.ChainStateStoreBytes},
+	} {
+		if p.value <= 0 {
+			return errors.Errorf("%s must be positive, got %d", p.name, p.value)
+		}
+	}
+	for _, p := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"MaxPropagationBurstLatency", c.MaxPropagationBurstLatency},
+		{"PullInterval", c.PullInterval},
+		{"PublishStateInfoInterval", c.PublishStateInfoInterval},
+		{"RequestStateInfoInterval", c.RequestStateInfoInterval},
+		{"HealthCheckInterval", c.HealthCheckInterval},
+		{"IdentityCacheTTL", c.IdentityCacheTTL},
+		{"MaxMessageAge", c.MaxMessageAge},
+		{"ReputationBanTime", c.ReputationBanTime},
+		{"InvitationTTL", c.InvitationTTL},
+		{"QUICHandshakeTimeout", c.QUICHandshakeTimeout},
+		{"QUICFallbackPeriod", c.QUICFallbackPeriod},
+	} {
+		if p.value <= 0 {
+			return errors.Errorf("%s must be positive, e.g. \"4s\", got %s", p.name, p.value)
+		}
+	}
+	if c.MaxMessageSize < minMessageSize {
+		return errors.Errorf("MaxMessageSize must be at least %d bytes", minMessageSize)
+	}
+	switch c.Transport {
+	case TransportTCP, TransportQUIC, TransportMemory:
+	default:
+		return errors.Errorf("Unknown transport %s, must be %s, %s or %s", c.Transport, TransportTCP, TransportQUIC, TransportMemory)
+	}
+	if c.AliveTimeInterval < 0 || c.AliveExpirationTimeout < 0 {
+		return errors.New("Alive intervals can't be negative")
+	}
+	if c.AliveTimeInterval > 0 && c.AliveExpirationTimeout > 0 && c.AliveExpirationTimeout <= c.AliveTimeInterval {
+		return errors.New("AliveExpirationTimeout must be greater than AliveTimeInterval")
+	}
+	if c.LeaderTimeout < 0 {
+		return errors.New("LeaderTimeout can't be negative")
+	}
+	if c.LeaderTimeout > 0 && c.LeaderTimeout <= c.PublishStateInfoInterval {
+		return errors.New("LeaderTimeout must be greater than PublishStateInfoInterval")
+	}
+	if c.ReconnectInterval < 0 || c.MaxReconnectInterval < 0 {
+		return errors.New("Reconnect intervals can't be negative")
+	}
+	if c.ReconnectInterval > 0 && c.MaxReconnectInterval > 0 && c.MaxReconnectInterval < c.ReconnectInterval {
+		return errors.New("MaxReconnectInterval can't be less than ReconnectInterval")
+	}
+	if c.MaxConnections < 0 {
+		return errors.New("MaxConnections can't be negative")
+	}
+	if c.IdleConnTimeout < 0 {
+		return errors.New("IdleConnTimeout can't be negative")
+	}
+	if c.MaxHandshakes < 0 || c.HandshakeRate < 0 {
+		return errors.New("Handshake limits can't be negative")
+	}
+	if c.MinProtocolVersion > protos.ProtocolVersion {
+		return errors.Errorf("MinProtocolVersion %d is newer than the version spoken, %d", c.MinProtocolVersion, protos.ProtocolVersion)
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		return errors.New("CircuitBreakerThreshold can't be negative")
+	}
+	if c.SendBlockTimeout < 0 {
+		return errors.New("SendBlockTimeout can't be negative")
+	}
+	if c.MinAlivePeers < 0 {
+		return errors.New("MinAlivePeers can't be negative")
+	}
+	if c.AdminDebug && !c.AdminService {
+		return errors.New("AdminDebug requires AdminService")
+	}
+	for _, id := range append(append([]string{}, c.BlockedPeers...), c.AllowedPeers...) {
+		if strings.TrimSpace(id) == "" {
+			return errors.New("Blocked and allowed peers can't be empty")
+		}
+	}
+	labelsSize := 0
+	for key, value := range c.Labels {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("Label keys can't be empty")
+		}
+		labelsSize += len(key) + len(value)
+	}
+	if labelsSize > maxLabelsSize {
+		return errors.Errorf("Labels can't exceed %d bytes, got %d", maxLabelsSize, labelsSize)
+	}
+	if c.PeerMessageRate < 0 {
+		return errors.New("PeerMessageRate can't be negative")
+	}
+	if c.ReputationThreshold < 0 || c.ReputationThreshold > 100 {
+		return errors.Errorf("ReputationThreshold must be between 0 and 100, got %d", c.ReputationThreshold)
+	}
+	if c.ChannelQuota < 0 {
+		return errors.New("ChannelQuota can't be negative")
+	}
+	if c.MaxConcurrentTransfers < 0 {
+		return errors.New("MaxConcurrentTransfers can't be negative")
+	}
+	for chainID, p := range c.ChannelPropagation {
+		if p.PeerNum < 0 || p.Iterations < 0 {
+			return errors.Errorf("Propagation of channel %s can't be negative", chainID)
+		}
+	}
+	for chainID, quota := range c.ChannelQuotas {
+		if quota < 0 {
+			return errors.Errorf("Quota of channel %s can't be negative", chainID)
+		}
+	}
+	switch c.OrphanPolicy {
+	case OrphanKeep, OrphanDelete:
+	case OrphanRetain:
+		if c.OrphanRetention <= 0 {
+			return errors.Errorf("OrphanRetention must be positive with the %s policy, e.g. \"72h\"", OrphanRetain)
+		}
+	default:
+		return errors.Errorf("Unknown orphan policy %s, must be %s, %s or %s", c.OrphanPolicy, OrphanKeep, OrphanDelete, OrphanRetain)
+	}
+	switch c.IdentityInclusion {
+	case IdentityWindow, IdentityAlways, IdentityOnRequest:
+	default:
+		return errors.Errorf("Unknown identity inclusion policy %s, must be %s, %s or %s", c.IdentityInclusion, IdentityWindow, IdentityAlways, IdentityOnRequest)
+	}
+	return nil
+}
+
+// Validate rejects the nonsensical parameters and the missing certificate files
+func (c *Config) Validate() error {
+	if c.HomeDir == "" {
+		return errors.New("Must specify the home directory")
+	}
+	if c.Gossip == nil {
+		return errors.New("Gossip configuration cannot be nil")
+	}
+	if err := c.Gossip.Validate(); err != nil {
+		return errors.WithMessage(err, "invalid gossip configuration")
+	}
+
+	if c.Identity == nil {
+		return errors.New("Identity configuration cannot be nil")
+	}
+	if c.Identity.ID == "" {
+		return errors.New("Must specify the ID of the peer in the identity configuration")
+	}
+	switch {
+	case c.Identity.Provider != nil:
+		// The identities are handled by the provider
+	case c.Identity.SPIFFE != nil:
+		if c.Identity.SPIFFE.TrustDomain == "" {
+			return errors.New("Must specify the SPIFFE trust domain in the identity configuration")
+		}
+	default:
+		for _, dir := range []string{"csp/signcerts", "csp/cacerts"} {
+			if err := checkPath(filepath.Join(c.HomeDir, dir)); err != nil {
+				return errors.WithMessage(err, "the identity certificates must be placed in the home directory")
+			}
+		}
+	}
+
+	if c.Server == nil || c.Server.SecOpts == nil || !c.Server.SecOpts.UseTLS {
+		return nil
+	}
+	tls := c.Server.SecOpts
+	if tls.Certificate == "" || tls.Key == "" {
+		return errors.New("Must specify the TLS certificate and key when TLS is enabled")
+	}
+	files := append([]string{tls.Certificate, tls.Key}, tls.ServerRootCAs...)
+	files = append(append(files, tls.ClientRootCAs...), tls.PinnedCertificates...)
+	for _, file := range files {
+		if err := checkPath(file); err != nil {
+			return errors.WithMessage(err, "invalid TLS configuration")
+		}
+	}
+	return nil
+}
+
+func checkPath(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return errors.Errorf("%s doesn't exist", path)
+		}
+		return errors.Wrapf(err, "could not stat %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package creds
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
+)
+
+// Credentials builds the gRPC dial and server options of a peer.
+// Once a certificate has been pinned, the TLS handshake only succeeds
+// if the remote peer presents one of the pinned certificates.
+type Credentials struct {
+	sync.RWMutex
+	cfg        *config.ServerConfig
+	clientTLS  *tls.Config
+	pins       map[string]struct{}
+	maxMsgSize int
+	dialer     func(context.Context, string) (net.Conn, error)
+}
+
+// New creates a Credentials instance from the server configuration
+func New(cfg *config.ServerConfig) (*Credentials, error) {
+	if cfg == nil {
+		return nil, errors.New("Server configuration cannot be nil")
+	}
+	if cfg.SecOpts == nil {
+		cfg.SecOpts = &config.TLSConfig{}
+	}
+
+	c := &Credentials{
+		cfg:  cfg,
+		pins: make(map[string]struct{}),
+	}
+	if !cfg.SecOpts.UseTLS {
+		return c, nil
+	}
+	for _, file := range cfg.SecOpts.PinnedCertificates {
+		if err := c.pinCertificateFile(file); err != nil {
+			return nil, errors.WithMessage(err, "Failed pinning certificate")
+		}
+	}
+
+	var err error
+	c.clientTLS, err = ClientTLSConfig(cfg.SecOpts)
+	if err != nil {
+		return nil, err
+	}
+	c.clientTLS.VerifyPeerCertificate = c.verifyPinned
+	return c, nil
+}
+
+// DialOptions returns the options used to dial remote peers
+func (c *Credentials) DialOptions() []grpc.DialOption {
+	var dialOpts []grpc.DialOption
+	recvSize, sendSize := c.messageSizes()
+	dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
+		grpc.MaxCallRecvMsgSize(recvSize),
+		grpc.MaxCallSendMsgSize(sendSize),
+	))
+
+	dialOpts = append(dialOpts, config.ClientKeepaliveOptions(c.cfg.KaOpts)...)
+	if dialer := c.contextDialer(); dialer != nil {
+		dialOpts = append(dialOpts, grpc.WithContextDialer(dialer))
+	}
+	if c.clientTLS != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(urlCredentials{credentials.NewTLS(c.clientTLS)}))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+
+	return dialOpts
+}
+
+// ServerOptions returns the options used to create the gRPC server
+func (c *Credentials) ServerOptions() ([]grpc.ServerOption, error) {
+	var serverOpts []grpc.ServerOption
+	if c.cfg.SecOpts.UseTLS {
+		tlsConfig, err := ServerTLSConfig(c.cfg.SecOpts)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyPeerCertificate = c.verifyPinned
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	recvSize, sendSize := c.messageSizes()
+	serverOpts = append(serverOpts, grpc.MaxSendMsgSize(sendSize))
+	serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(recvSize))
+	serverOpts = append(serverOpts, config.ServerKeepaliveOptions(c.cfg.KaOpts)...)
+
+	timeout := c.cfg.ConnectionTimeout
+	if timeout <= 0 {
+		timeout = config.DefaultConnectionTimeout
+	}
+	serverOpts = append(serverOpts, grpc.ConnectionTimeout(timeout))
+
+	return serverOpts, nil
+}
+
+// SetMaxMessageSize sets the max size of the messages sent and received,
+// config.MaxRecvMsgSize and config.MaxSendMsgSize are used if it isn't set
+func (c *Credentials) SetMaxMessageSize(size int) {
+	c.Lock()
+	defer c.Unlock()
+	c.maxMsgSize = size
+}
+
+func (c *Credentials) messageSizes() (recvSize int, sendSize int) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.maxMsgSize > 0 {
+		return c.maxMsgSize, c.maxMsgSize
+	}
+	return config.MaxRecvMsgSize, config.MaxSendMsgSize
+}
+
+// SetDialer sets the function used to dial remote peers instead of TCP
+func (c *Credentials) SetDialer(dialer func(context.Context, string) (net.Conn, error)) {
+	c.Lock()
+	defer c.Unlock()
+	c.dialer = dialer
+}
+
+func (c *Credentials) contextDialer() func(context.Context, string) (net.Conn, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.dialer
+}
+
+// PinCertificate pins the given DER encoded certificate
+func (c *Credentials) PinCertificate(raw []byte) {
+	digest := sha256.Sum256(raw)
+
+	c.Lock()
+	defer c.Unlock()
+	c.pins[hex.EncodeToString(digest[:])] = struct{}{}
+}
+
+// PinIdentity pins the certificate of a peer identity as exchanged
+// during the rksync handshake, which requires peers to use their
+// identity certificate for TLS as well.
+func (c *Credentials) PinIdentity(identity common.PeerIdentityType) error {
+	sid := &protos.SerializedIdentity{}
+	if err := proto.Unmarshal(identity, sid); err != nil {
+		return errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+	}
+
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		return err
+	}
+
+	c.PinCertificate(cert.Raw)
+	return nil
+}
+
+// pinCertificateFile pins the certificates of a PEM file
+func (c *Credentials) pinCertificateFile(file string) error {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	certs := 0
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return errors.Wrapf(err, "Invalid certificate in %s", file)
+		}
+		c.PinCertificate(block.Bytes)
+		certs++
+	}
+	if certs == 0 {
+		return errors.Errorf("No certificate found in %s", file)
+	}
+	return nil
+}
+
+func (c *Credentials) verifyPinned(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	c.RLock()
+	defer c.RUnlock()
+
+	if len(c.pins) == 0 {
+		return nil
+	}
+	if len(rawCerts) == 0 {
+		return errors.New("Remote peer didn't present a certificate")
+	}
+
+	digest := sha256.Sum256(rawCerts[0])
+	if _, pinned := c.pins[hex.EncodeToString(digest[:])]; !pinned {
+		return errors.Errorf("Certificate %s of remote peer isn't pinned", hex.EncodeToString(digest[:]))
+	}
+	return nil
+}
+
+// urlCredentials hands the host of the endpoint to the TLS handshake
+// when the remote peer is dialed with a URL, e.g. a WebSocket endpoint
+type urlCredentials struct {
+	credentials.TransportCredentials
+}
+
+func (c urlCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if u, err := url.Parse(authority); err == nil && u.Host != "" {
+		authority = u.Host
+	}
+	return c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c urlCredentials) Clone() credentials.TransportCredentials {
+	return urlCredentials{c.TransportCredentials.Clone()}
+}
+
+// ServerTLSConfig builds the TLS configuration of a gRPC server
+func ServerTLSConfig(secOpts *config.TLSConfig) (*tls.Config, error) {
+	if secOpts.Key == "" || secOpts.Certificate == "" {
+		return nil, errors.New("serverConfig.SecOpts must contain both Key and Certificate when UseTLS is true")
+	}
+
+	cert, err := loadKeyPair(secOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: cipherSuites(secOpts),
+		ClientAuth:   tls.RequestClientCert,
+	}
+	if secOpts.RequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if len(secOpts.ClientRootCAs) > 0 {
+			tlsConfig.ClientCAs, err = certPool(secOpts.ClientRootCAs)
+			if err != nil {
+				return nil, errors.WithMessage(err, "Failed to append client root certificate(s)")
+			}
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig builds the TLS configuration used to dial remote peers
+func ClientTLSConfig(secOpts *config.TLSConfig) (*tls.Config, error) {
+	cert, err := loadKeyPair(secOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCAs, err := certPool(secOpts.ServerRootCAs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed adding certificates to peer's client TLS trust pool")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: cipherSuites(secOpts),
+		RootCAs:      rootCAs,
+	}, nil
+}
+
+func loadKeyPair(secOpts *config.TLSConfig) (tls.Certificate, error) {
+	keyPEM, err := ioutil.ReadFile(secOpts.Key)
+	if err != nil {
+		return tls.Certificate{}, errors.WithMessage(err, "error loading TLS key")
+	}
+
+	certPEM, err := ioutil.ReadFile(secOpts.Certificate)
+	if err != nil {
+		return tls.Certificate{}, errors.WithMessage(err, "error loading TLS certificate")
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, errors.WithMessage(err, "error parsing TLS key pair")
+	}
+
+	return cert, nil
+}
+
+func certPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		pemCerts, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed read root ca file: %s", file)
+		}
+
+		certs, _, err := util.PEMToX509Certs(pemCerts)
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) < 1 {
+			return nil, errors.Errorf("No certificate found in %s", file)
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+	}
+
+	return pool, nil
+}
+
+func cipherSuites(secOpts *config.TLSConfig) []uint16 {
+	if len(secOpts.CipherSuites) == 0 {
+		return config.DefaultTLSCipherSuites
+	}
+	return secOpts.CipherSuites
+}
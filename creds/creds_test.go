@@ -0,0 +1,270 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package creds
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func tlsPath(filename string) string {
+	return filepath.Join("..", "tests", "fixtures", "tls", filename)
+}
+
+func TestNewCredentials(t *testing.T) {
+	_, err := New(nil)
+	assert.Error(t, err)
+
+	c, err := New(&config.ServerConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, c.clientTLS)
+	assert.NotEmpty(t, c.DialOptions())
+	serverOpts, err := c.ServerOptions()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, serverOpts)
+
+	_, err = New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: true}})
+	assert.Error(t, err)
+
+	c, err = New(&config.ServerConfig{SecOpts: &config.TLSConfig{
+		UseTLS:            true,
+		Key:               tlsPath("server.key"),
+		Certificate:       tlsPath("server.crt"),
+		ServerRootCAs:     []string{tlsPath("ca.crt")},
+		ClientRootCAs:     []string{tlsPath("ca.crt")},
+		RequireClientCert: true,
+	}})
+	require.NoError(t, err)
+	assert.NotNil(t, c.clientTLS)
+	assert.Equal(t, config.DefaultTLSCipherSuites, c.clientTLS.CipherSuites)
+	serverOpts, err = c.ServerOptions()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, serverOpts)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	_, err := ServerTLSConfig(&config.TLSConfig{UseTLS: true})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must contain both Key and Certificate")
+
+	_, err = ServerTLSConfig(&config.TLSConfig{
+		UseTLS:            true,
+		Key:               tlsPath("server.key"),
+		Certificate:       tlsPath("server.crt"),
+		ClientRootCAs:     []string{tlsPath("server.key")},
+		RequireClientCert: true,
+	})
+	assert.Error(t, err)
+
+	tlsConfig, err := ServerTLSConfig(&config.TLSConfig{
+		UseTLS:       true,
+		Key:          tlsPath("server.key"),
+		Certificate:  tlsPath("server.crt"),
+		CipherSuites: []uint16{config.DefaultTLSCipherSuites[0]},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{config.DefaultTLSCipherSuites[0]}, tlsConfig.CipherSuites)
+	assert.Nil(t, tlsConfig.ClientCAs)
+}
+
+func TestPinCertificate(t *testing.T) {
+	c, err := New(&config.ServerConfig{})
+	require.NoError(t, err)
+
+	raw, err := ioutil.ReadFile(tlsPath("server.crt"))
+	require.NoError(t, err)
+	block, _ := pem.Decode(raw)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	assert.NoError(t, c.verifyPinned(nil, nil))
+	assert.NoError(t, c.verifyPinned([][]byte{[]byte("other")}, nil))
+
+	c.PinCertificate(cert.Raw)
+	assert.NoError(t, c.verifyPinned([][]byte{cert.Raw}, nil))
+	assert.Error(t, c.verifyPinned([][]byte{[]byte("other")}, nil))
+	assert.Error(t, c.verifyPinned(nil, nil))
+
+	assert.Error(t, c.PinIdentity([]byte("not an identity")))
+}
+
+func TestPinnedHandshake(t *testing.T) {
+	clientCreds, err := New(&config.ServerConfig{SecOpts: &config.TLSConfig{
+		UseTLS:        true,
+		Key:           tlsPath("server.key"),
+		Certificate:   tlsPath("server.crt"),
+		ServerRootCAs: []string{tlsPath("ca.crt")},
+	}})
+	require.NoError(t, err)
+
+	_, err = New(&config.ServerConfig{SecOpts: &config.TLSConfig{
+		UseTLS:             true,
+		Key:                tlsPath("server.key"),
+		Certificate:        tlsPath("server.crt"),
+		PinnedCertificates: []string{tlsPath("server.key")},
+	}})
+	assert.Error(t, err)
+
+	// The server only accepts the peers presenting the pinned certificates
+	for pinned, code := range map[string]codes.Code{
+		tlsPath("ca.crt"):     codes.Unavailable,
+		tlsPath("server.crt"): codes.Unimplemented,
+	} {
+		serverCreds, err := New(&config.ServerConfig{SecOpts: &config.TLSConfig{
+			UseTLS:             true,
+			Key:                tlsPath("server.key"),
+			Certificate:        tlsPath("server.crt"),
+			PinnedCertificates: []string{pinned},
+		}})
+		require.NoError(t, err)
+		serverOpts, err := serverCreds.ServerOptions()
+		require.NoError(t, err)
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		srv := grpc.NewServer(serverOpts...)
+		go srv.Serve(lis)
+
+		clientCreds.SetDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", lis.Addr().String())
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		conn, err := grpc.DialContext(ctx, "peer0:7051", clientCreds.DialOptions()...)
+		require.NoError(t, err)
+		err = conn.Invoke(ctx, "/rksync.Test/Ping", &emptypb.Empty{}, &emptypb.Empty{})
+		assert.Equal(t, code, status.Code(err), "pinned %s: %v", pinned, err)
+		conn.Close()
+		cancel()
+		srv.Stop()
+	}
+}
+
+type authorityRecorder struct {
+	credentials.TransportCredentials
+	authority string
+}
+
+func (r *authorityRecorder) ClientHandshake(_ context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	r.authority = authority
+	return conn, nil, nil
+}
+
+func TestURLCredentials(t *testing.T) {
+	for endpoint, authority := range map[string]string{
+		"localhost:4053":                       "localhost:4053",
+		"127.0.0.1:4053":                       "127.0.0.1:4053",
+		"wss://gateway.example.com/rksync":     "gateway.example.com",
+		"ws://gateway.example.com:8080/rksync": "gateway.example.com:8080",
+	} {
+		r := &authorityRecorder{}
+		_, _, err := urlCredentials{r}.ClientHandshake(context.Background(), endpoint, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, authority, r.authority)
+	}
+}
+
+// writeTLSFiles writes a CA certificate and a certificate of peer0 issued by it for the key,
+// and returns the paths of the CA certificate, the certificate and the key
+func writeTLSFiles(t *testing.T, key crypto.Signer) (string, string, string) {
+	dir := t.TempDir()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caRaw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, key.Public(), key)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "peer0"},
+		DNSNames:     []string{"peer0"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, caTemplate, key.Public(), key)
+	require.NoError(t, err)
+	keyRaw, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	paths := []string{filepath.Join(dir, "ca.crt"), filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")}
+	for i, block := range []*pem.Block{{Type: "CERTIFICATE", Bytes: caRaw}, {Type: "CERTIFICATE", Bytes: raw}, {Type: "PRIVATE KEY", Bytes: keyRaw}} {
+		require.NoError(t, ioutil.WriteFile(paths[i], pem.EncodeToMemory(block), 0600))
+	}
+	return paths[0], paths[1], paths[2]
+}
+
+func TestTLSKeyTypes(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for name, key := range map[string]crypto.Signer{"ECDSA-P384": p384Key, "Ed25519": ed25519Key} {
+		t.Run(name, func(t *testing.T) {
+			ca, cert, keyFile := writeTLSFiles(t, key)
+			secOpts := &config.TLSConfig{
+				UseTLS:            true,
+				Key:               keyFile,
+				Certificate:       cert,
+				ServerRootCAs:     []string{ca},
+				ClientRootCAs:     []string{ca},
+				RequireClientCert: true,
+			}
+			serverCreds, err := New(&config.ServerConfig{SecOpts: secOpts})
+			require.NoError(t, err)
+			serverOpts, err := serverCreds.ServerOptions()
+			require.NoError(t, err)
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			srv := grpc.NewServer(serverOpts...)
+			go srv.Serve(lis)
+			defer srv.Stop()
+
+			clientCreds, err := New(&config.ServerConfig{SecOpts: secOpts})
+			require.NoError(t, err)
+			clientCreds.SetDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "tcp", lis.Addr().String())
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := grpc.DialContext(ctx, "peer0:7051", clientCreds.DialOptions()...)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// The TLS handshake succeeds, the method isn't served
+			err = conn.Invoke(ctx, "/rksync.Test/Ping", &emptypb.Empty{}, &emptypb.Empty{})
+			assert.Equal(t, codes.Unimplemented, status.Code(err), "%v", err)
+		})
+	}
+}
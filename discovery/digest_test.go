@@ -0,0 +1,88 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMembershipDigests(t *testing.T) {
+	disc, rpc, err := CreateDiscoveryInstance("localhost:7253", 0)
+	require.NoError(t, err)
+	defer disc.Stop()
+	defer rpc.Stop()
+	d := disc.(*gossipDiscoveryService)
+
+	aliveMsg := func(pkiID string, seqNum uint64) *protos.SignedRKSyncMessage {
+		msg, err := (&protos.RKSyncMessage{
+			Tag: protos.RKSyncMessage_EMPTY,
+			Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{
+				Membership: &protos.Member{Endpoint: pkiID, PkiId: []byte(pkiID)},
+				Timestamp:  &protos.PeerTime{IncNum: 1, SeqNum: seqNum},
+			}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return msg
+	}
+	digest := func(pkiID string, seqNum uint64) *protos.MembershipDigest {
+		return &protos.MembershipDigest{PkiId: []byte(pkiID), Timestamp: &protos.PeerTime{IncNum: 1, SeqNum: seqNum}}
+	}
+	pkiIDs := func(envelopes []*protos.Envelope) []string {
+		var res []string
+		for _, envp := range envelopes {
+			m, err := envp.ToRKSyncMessage()
+			require.NoError(t, err)
+			res = append(res, string(m.GetAliveMsg().Membership.PkiId))
+		}
+		return res
+	}
+	digestKeys := func(digests []*protos.MembershipDigest) []string {
+		var res []string
+		for _, dig := range digests {
+			res = append(res, fmt.Sprintf("%s:%d", dig.PkiId, dig.Timestamp.SeqNum))
+		}
+		return res
+	}
+
+	d.lock.Lock()
+	d.aliveMembership.Put(common.PKIidType("p1"), aliveMsg("p1", 1))
+	d.aliveMembership.Put(common.PKIidType("p2"), aliveMsg("p2", 5))
+	d.aliveMembership.Put(common.PKIidType("p5"), aliveMsg("p5", 1))
+	d.deadMembership.Put(common.PKIidType("p3"), aliveMsg("p3", 1))
+	d.lock.Unlock()
+
+	self := aliveMsg("self", 1)
+	target := &common.NetworkMember{Endpoint: "localhost:7254", PKIID: common.PKIidType("target")}
+
+	// Without digests the whole membership is returned
+	resp := d.createMembershipResponse(self, target, nil)
+	assert.ElementsMatch(t, []string{"p1", "p2", "p5", "self"}, pkiIDs(resp.Alive))
+	assert.Equal(t, []string{"p3"}, pkiIDs(resp.Dead))
+	assert.Empty(t, resp.Wanted)
+
+	// The peers known to the target member are left out unless it knows an older alive message,
+	// the peers it knows a newer alive message of or this instance doesn't know are wanted
+	resp = d.createMembershipResponse(self, target, []*protos.MembershipDigest{
+		digest("p1", 1), digest("p2", 3), digest("p4", 1), digest("p5", 2),
+	})
+	assert.ElementsMatch(t, []string{"p2", "self"}, pkiIDs(resp.Alive))
+	assert.Equal(t, []string{"p3"}, pkiIDs(resp.Dead))
+	assert.ElementsMatch(t, [][]byte{[]byte("p4"), []byte("p5")}, resp.Wanted)
+
+	// The membership requests carry the digests of the alive and dead peers instead of their alive messages
+	req, err := d.createMembershipRequest(target)
+	require.NoError(t, err)
+	memReq := req.GetMemReq()
+	assert.Empty(t, memReq.Known)
+	assert.ElementsMatch(t, []string{"p1:1", "p2:5", "p5:1", "p3:1"}, digestKeys(memReq.Digests))
+}
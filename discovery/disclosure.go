@@ -0,0 +1,89 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"net"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// EnvelopeFilter may or may not remove part of the Envelope
+// that the given SignedRKSyncMessage originates from.
+type EnvelopeFilter func(message *protos.SignedRKSyncMessage) *protos.Envelope
+
+// Sieve defines the messages that are allowed to be
+// sent to some remote peer, based on some criteria.
+// Returns whether the sieve permits sending a given message.
+type Sieve func(message *protos.SignedRKSyncMessage) bool
+
+// DisclosurePolicy defines which messages a remote peer
+// is eligible to know about, and also what it is eligible
+// to know about out of a given SignedRKSyncMessage.
+type DisclosurePolicy func(remotePeer *common.NetworkMember) (Sieve, EnvelopeFilter)
+
+// NewDisclosurePolicy returns a DisclosurePolicy that discloses internal endpoints
+// only to remote peers that are in the same network as the given peer.
+// Peers are considered to be in the same network, e.g. behind the same NAT,
+// if their advertised endpoints share the same host. A remote peer having an internal
+// endpoint is only behind some NAT, not necessarily the same one.
+func NewDisclosurePolicy(self common.NetworkMember) DisclosurePolicy {
+	return NewSievingDisclosurePolicy(self, nil)
+}
+
+// NewSievingDisclosurePolicy returns a DisclosurePolicy that discloses internal endpoints like
+// NewDisclosurePolicy, and the alive messages of a peer only if disclose permits the remote peer
+// to know about it, e.g. to hide the peers internal to an organization from the external ones.
+// All the peers are disclosed if disclose is nil.
+func NewSievingDisclosurePolicy(self common.NetworkMember, disclose func(remotePeer, peer common.NetworkMember) bool) DisclosurePolicy {
+	selfHost := endpointHost(self.Endpoint)
+	return func(remotePeer *common.NetworkMember) (Sieve, EnvelopeFilter) {
+		sameNetwork := endpointHost(remotePeer.Endpoint) == selfHost
+		sieve := func(message *protos.SignedRKSyncMessage) bool {
+			if disclose == nil || !message.IsAliveMsg() {
+				return true
+			}
+			return disclose(*remotePeer, aliveMember(message))
+		}
+		return sieve, func(message *protos.SignedRKSyncMessage) *protos.Envelope {
+			if sameNetwork || message.Envelope.SecretEnvelope == nil {
+				return message.Envelope
+			}
+			return message.Envelope.WithoutSecret()
+		}
+	}
+}
+
+// aliveMember returns the peer an alive message originates from
+func aliveMember(message *protos.SignedRKSyncMessage) common.NetworkMember {
+	var member common.NetworkMember
+	am := message.GetAliveMsg()
+	if am.Membership != nil {
+		member = common.NetworkMember{
+			PKIID:    am.Membership.PkiId,
+			Endpoint: am.Membership.Endpoint,
+			Zone:     am.Membership.Zone,
+			Org:      am.Membership.Org,
+			Labels:   am.Membership.Labels,
+			Tags:     am.Membership.Tags,
+		}
+	}
+	member.Leaving = am.Leaving
+	if message.Envelope != nil {
+		member.InternalEndpoint = message.Envelope.SecretEnvelope.InternalEndpoint()
+	}
+	return member
+}
+
+func endpointHost(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return host
+}
@@ -0,0 +1,118 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisclosurePolicy(t *testing.T) {
+	signer := func(msg []byte) ([]byte, error) {
+		return msg, nil
+	}
+	msg := &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}},
+	}}
+	envp, err := msg.Sign(signer)
+	require.NoError(t, err)
+	require.NoError(t, envp.SignSecret(signer, &protos.Secret{
+		Content: &protos.Secret_InternalEndpoint{InternalEndpoint: "10.0.0.1:9053"},
+	}))
+	assert.Equal(t, "10.0.0.1:9053", envp.SecretEnvelope.InternalEndpoint())
+
+	policy := NewDisclosurePolicy(common.NetworkMember{Endpoint: "203.0.113.1:9053", InternalEndpoint: "10.0.0.1:9053"})
+
+	filter := func(remotePeer *common.NetworkMember) EnvelopeFilter {
+		sieve, envelopeFilter := policy(remotePeer)
+		assert.True(t, sieve(msg))
+		return envelopeFilter
+	}
+
+	sameNAT := filter(&common.NetworkMember{Endpoint: "203.0.113.1:9054"})(msg)
+	assert.Equal(t, "10.0.0.1:9053", sameNAT.SecretEnvelope.InternalEndpoint())
+
+	sameNATInternal := filter(&common.NetworkMember{Endpoint: "203.0.113.1:9055", InternalEndpoint: "10.0.0.3:9053"})(msg)
+	assert.Equal(t, "10.0.0.1:9053", sameNATInternal.SecretEnvelope.InternalEndpoint())
+
+	// A peer behind another NAT has an internal endpoint too, it can't reach ours
+	otherNAT := filter(&common.NetworkMember{Endpoint: "198.51.100.2:9053", InternalEndpoint: "10.0.0.2:9053"})(msg)
+	assert.Nil(t, otherNAT.SecretEnvelope)
+	assert.Empty(t, otherNAT.SecretEnvelope.InternalEndpoint())
+
+	outside := filter(&common.NetworkMember{Endpoint: "198.51.100.1:9053"})(msg)
+	assert.Nil(t, outside.SecretEnvelope)
+	assert.Equal(t, envp.Payload, outside.Payload)
+	assert.Equal(t, envp.Signature, outside.Signature)
+	assert.Empty(t, outside.SecretEnvelope.InternalEndpoint())
+	assert.NotNil(t, msg.Envelope.SecretEnvelope)
+}
+
+func TestSievingDisclosurePolicy(t *testing.T) {
+	internal := &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{
+			Membership: &protos.Member{Endpoint: "10.0.0.2:9053", PkiId: []byte("p2"), Tags: []string{"internal"}},
+		}},
+	}}
+	external := &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{
+			Membership: &protos.Member{Endpoint: "203.0.113.3:9053", PkiId: []byte("p3")},
+		}},
+	}}
+	chainState := &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_State{State: &protos.ChainState{}},
+	}}
+
+	isInternal := func(member common.NetworkMember) bool {
+		return len(member.Tags) > 0 && member.Tags[0] == "internal"
+	}
+	policy := NewSievingDisclosurePolicy(common.NetworkMember{Endpoint: "10.0.0.1:9053"}, func(remotePeer, peer common.NetworkMember) bool {
+		return isInternal(remotePeer) || !isInternal(peer)
+	})
+
+	// The internal peers are hidden from the external ones
+	sieve, _ := policy(&common.NetworkMember{Endpoint: "198.51.100.1:9053"})
+	assert.False(t, sieve(internal))
+	assert.True(t, sieve(external))
+	assert.True(t, sieve(chainState))
+
+	sieve, _ = policy(&common.NetworkMember{Endpoint: "10.0.0.3:9053", Tags: []string{"internal"}})
+	assert.True(t, sieve(internal))
+	assert.True(t, sieve(external))
+}
+
+func TestInternalEndpoint(t *testing.T) {
+	disc1, rpc1, err := CreateDiscoveryInstance("localhost:7155", 0)
+	require.NoError(t, err)
+	defer disc1.Stop()
+	defer rpc1.Stop()
+
+	// The external endpoint isn't reachable, peers in the same network
+	// have to use the internal endpoint
+	disc2, rpc2, err := createDiscoveryInstanceWithSelf("127.0.0.1:7156",
+		common.NetworkMember{Endpoint: "localhost:7999", InternalEndpoint: "127.0.0.1:7156"}, 1, Config{})
+	require.NoError(t, err)
+	defer disc2.Stop()
+	defer rpc2.Stop()
+
+	disc2.Connect(common.NetworkMember{Endpoint: "localhost:7155"}, func() (common.PKIidType, error) {
+		return rpc1.GetPKIid(), nil
+	})
+
+	time.Sleep(8 * time.Second)
+	members := disc1.GetMembership()
+	require.Len(t, members, 1)
+	assert.Equal(t, "localhost:7999", members[0].Endpoint)
+	assert.Equal(t, "127.0.0.1:7156", members[0].InternalEndpoint)
+	assert.Equal(t, "127.0.0.1:7156", disc1.Lookup(rpc2.GetPKIid()).PreferredEndpoint())
+	assert.Len(t, disc2.GetMembership(), 1)
+}
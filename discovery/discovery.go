@@ -8,14 +8,17 @@ SPDX-License-Identifier: Apache-2.0
 package discovery
 
 import (
+	"time"
+
 	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/protos"
 )
 
 // CryptoService is an interface that discovery expects to be implemented and passed on creation
 type CryptoService interface {
 	ValidateAliveMsg(message *protos.SignedRKSyncMessage) bool
-	SignMessage(m *protos.RKSyncMessage) *protos.Envelope
+	SignMessage(m *protos.RKSyncMessage, internalEndpoint string) *protos.Envelope
 	SelfIdentity() common.PeerIdentityType
 }
 
@@ -28,9 +31,42 @@ type RPCService interface {
 	PresumedDead() <-chan common.PKIidType
 	CloseConn(peer *common.NetworkMember)
 	Forward(msg protos.ReceivedMessage)
+	MemberDead(member common.NetworkMember)
+}
+
+// Config determines how often the peers are declared alive and
+// how long the silent or unreachable peers are waited for
+type Config struct {
+	AliveTimeInterval      time.Duration    // Determines frequency of sending alive messages, defaults to 5s
+	AliveExpirationTimeout time.Duration    // Time a silent peer is considered alive, defaults to 5 times AliveTimeInterval
+	ReconnectInterval      time.Duration    // Delay before the first attempt to reconnect to a dead peer, defaults to AliveTimeInterval
+	MaxReconnectInterval   time.Duration    // Max delay between the attempts to reconnect to a dead peer, defaults to 2m
+	Clock                  lib.Clock        // Clock of the intervals and expirations, defaults to lib.SystemClock
+	DisclosurePolicy       DisclosurePolicy // Which peers and endpoints the remote peers know about, defaults to NewDisclosurePolicy
 }
 
-type identifier func() (common.PKIidType, error)
+func (c Config) withDefaults() Config {
+	if c.AliveTimeInterval <= 0 {
+		c.AliveTimeInterval = defaultHelloInterval
+	}
+	if c.AliveExpirationTimeout <= 0 {
+		c.AliveExpirationTimeout = 5 * c.AliveTimeInterval
+	}
+	if c.ReconnectInterval <= 0 {
+		c.ReconnectInterval = c.AliveTimeInterval
+	}
+	if c.MaxReconnectInterval <= 0 {
+		c.MaxReconnectInterval = defaultMaxReconnectInterval
+	}
+	if c.MaxReconnectInterval < c.ReconnectInterval {
+		c.MaxReconnectInterval = c.ReconnectInterval
+	}
+	c.Clock = lib.ClockOrSystem(c.Clock)
+	return c
+}
+
+// Identifier returns the PKI-ID of the remote peer being connected to
+type Identifier func() (common.PKIidType, error)
 
 // Discovery is the interface represents a discovery module
 type Discovery interface {
@@ -42,11 +78,15 @@ type Discovery interface {
 	InitiateSync(peerNum int)
 
 	// Connect makes this instance to connect to a remote instance
-	Connect(member common.NetworkMember, id identifier)
+	Connect(member common.NetworkMember, id Identifier)
 
 	// Lookup returns a network member, or nil if not found
 	Lookup(pkiID common.PKIidType) *common.NetworkMember
 
+	// Leave announces to the remote peers that this instance
+	// is about to leave the network
+	Leave()
+
 	// Stop this instance
 	Stop()
 }
@@ -9,6 +9,7 @@ package discovery
 import (
 	"fmt"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/filter"
 	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/rpc"
 	"github.com/rkcloudchain/rksync/server"
@@ -101,6 +103,46 @@ func TestLookup(t *testing.T) {
 	assert.Equal(t, rpc1.GetPKIid(), member.PKIID)
 }
 
+func TestStaticDiscovery(t *testing.T) {
+	rpcSvc := &staticRPCService{
+		incChan:      make(chan protos.ReceivedMessage),
+		presumedDead: make(chan common.PKIidType),
+	}
+	self := common.NetworkMember{Endpoint: "localhost:6053", PKIID: common.PKIidType("p0")}
+	disc := NewStaticDiscovery(self, rpcSvc, Config{AliveTimeInterval: 100 * time.Millisecond, AliveExpirationTimeout: 500 * time.Millisecond}, logging.Default())
+	defer disc.Stop()
+
+	disc.Connect(common.NetworkMember{Endpoint: "localhost:6053"}, func() (common.PKIidType, error) {
+		return self.PKIID, nil
+	})
+	disc.Connect(common.NetworkMember{Endpoint: "localhost:6054"}, func() (common.PKIidType, error) {
+		return common.PKIidType("p1"), nil
+	})
+
+	time.Sleep(time.Second)
+	members := disc.GetMembership()
+	require.Len(t, members, 1)
+	assert.Equal(t, "localhost:6054", members[0].Endpoint)
+	assert.Equal(t, common.PKIidType("p1"), disc.Lookup(common.PKIidType("p1")).PKIID)
+	assert.Equal(t, &self, disc.Lookup(self.PKIID))
+
+	rpcSvc.presumedDead <- common.PKIidType("p1")
+	assert.Len(t, disc.GetMembership(), 1)
+
+	// the member failing the probes expires, and is revived once it responds again
+	atomic.StoreInt32(&rpcSvc.unreachable, 1)
+	time.Sleep(300 * time.Millisecond)
+	assert.Len(t, disc.GetMembership(), 1)
+	time.Sleep(time.Second)
+	assert.Empty(t, disc.GetMembership())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rpcSvc.dead))
+	assert.NotNil(t, disc.Lookup(common.PKIidType("p1")))
+
+	atomic.StoreInt32(&rpcSvc.unreachable, 0)
+	time.Sleep(500 * time.Millisecond)
+	assert.Len(t, disc.GetMembership(), 1)
+}
+
 func TestMembership(t *testing.T) {
 	disc1, rpc1, err := CreateDiscoveryInstance("localhost:4053", 0)
 	require.NoError(t, err)
@@ -175,6 +217,95 @@ func TestMembership(t *testing.T) {
 	assert.Len(t, disc10.GetMembership(), 9)
 }
 
+func TestMembershipManyPeers(t *testing.T) {
+	const peers = 30
+	// the peers sign and verify every alive message, a loaded machine (e.g. under -race) may
+	// take seconds to handle those of so many peers, they mustn't expire meanwhile
+	conf := Config{AliveTimeInterval: time.Second, AliveExpirationTimeout: 30 * time.Second}
+	var discs []Discovery
+	var rpcs []*rpc.Server
+	for i := 0; i < peers; i++ {
+		address := fmt.Sprintf("peer%d:7051", i)
+		disc, rpcSrv, err := createDiscoveryInstanceWithSelf(address, common.NetworkMember{Endpoint: address}, i, conf)
+		require.NoError(t, err)
+		defer disc.Stop()
+		defer rpcSrv.Stop()
+		discs = append(discs, disc)
+		rpcs = append(rpcs, rpcSrv)
+	}
+
+	for _, disc := range discs[1:] {
+		go disc.Connect(common.NetworkMember{Endpoint: "peer0:7051"}, func() (common.PKIidType, error) { return rpcs[0].GetPKIid(), nil })
+	}
+
+	converged := func() bool {
+		for _, disc := range discs {
+			if len(disc.GetMembership()) != peers-1 {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < 300 && !converged(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for i, disc := range discs {
+		assert.Len(t, disc.GetMembership(), peers-1, "peer%d", i)
+	}
+}
+
+func TestPartitionHealing(t *testing.T) {
+	const peers = 4
+	conf := Config{AliveTimeInterval: 100 * time.Millisecond, MaxReconnectInterval: 500 * time.Millisecond}
+	var discs []Discovery
+	var rpcs []*rpc.Server
+	var faults []*rpc.Faults
+	for i := 0; i < peers; i++ {
+		address := fmt.Sprintf("partition%d:7051", i)
+		disc, rpcSrv, err := createDiscoveryInstanceWithSelf(address, common.NetworkMember{Endpoint: address}, i, conf)
+		require.NoError(t, err)
+		defer disc.Stop()
+		defer rpcSrv.Stop()
+		f := rpc.NewFaults(int64(i))
+		rpcSrv.SetInterceptor(f)
+		discs = append(discs, disc)
+		rpcs = append(rpcs, rpcSrv)
+		faults = append(faults, f)
+	}
+	for _, disc := range discs[1:] {
+		go disc.Connect(common.NetworkMember{Endpoint: "partition0:7051"}, func() (common.PKIidType, error) { return rpcs[0].GetPKIid(), nil })
+	}
+
+	waitForMembership := func(expected int) {
+		for i := 0; i < 100; i++ {
+			converged := true
+			for _, disc := range discs {
+				converged = converged && len(disc.GetMembership()) == expected
+			}
+			if converged {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		for i, disc := range discs {
+			assert.Len(t, disc.GetMembership(), expected, "peer%d", i)
+		}
+	}
+	waitForMembership(peers - 1)
+
+	// the peers 0 and 1 no longer hear from the peers 2 and 3
+	faults[0].Partition(rpcs[2].GetPKIid(), rpcs[3].GetPKIid())
+	faults[1].Partition(rpcs[2].GetPKIid(), rpcs[3].GetPKIid())
+	faults[2].Partition(rpcs[0].GetPKIid(), rpcs[1].GetPKIid())
+	faults[3].Partition(rpcs[0].GetPKIid(), rpcs[1].GetPKIid())
+	waitForMembership(1)
+
+	for _, f := range faults {
+		f.Heal()
+	}
+	waitForMembership(peers - 1)
+}
+
 type mockRPCService struct {
 	rpc        *rpc.Server
 	membership func() []common.NetworkMember
@@ -208,6 +339,8 @@ func (m *mockRPCService) CloseConn(peer *common.NetworkMember) {
 	m.rpc.CloseConn(peer)
 }
 
+func (m *mockRPCService) MemberDead(member common.NetworkMember) {}
+
 func (m *mockRPCService) Forward(msg protos.ReceivedMessage) {
 	peers2Send := filter.SelectPeers(3, m.membership(), func(member common.NetworkMember) bool {
 		return msg.GetConnectionInfo().ID.IsNotSameFilter(member.PKIID)
@@ -215,12 +348,38 @@ func (m *mockRPCService) Forward(msg protos.ReceivedMessage) {
 	m.rpc.Send(msg.GetRKSyncMessage(), peers2Send...)
 }
 
+type staticRPCService struct {
+	mockRPCService
+	incChan      chan protos.ReceivedMessage
+	presumedDead chan common.PKIidType
+	unreachable  int32
+	dead         int32
+}
+
+func (s *staticRPCService) Ping(peer *common.NetworkMember) bool {
+	return atomic.LoadInt32(&s.unreachable) == 0
+}
+
+func (s *staticRPCService) CloseConn(peer *common.NetworkMember) {}
+
+func (s *staticRPCService) MemberDead(member common.NetworkMember) {
+	atomic.AddInt32(&s.dead, 1)
+}
+
+func (s *staticRPCService) Accept() <-chan protos.ReceivedMessage {
+	return s.incChan
+}
+
+func (s *staticRPCService) PresumedDead() <-chan common.PKIidType {
+	return s.presumedDead
+}
+
 type mockCryptoService struct {
 	idMapper identity.Identity
 	identity common.PeerIdentityType
 }
 
-func (m *mockCryptoService) SignMessage(message *protos.RKSyncMessage) *protos.Envelope {
+func (m *mockCryptoService) SignMessage(message *protos.RKSyncMessage, internalEndpoint string) *protos.Envelope {
 	signer := func(msg []byte) ([]byte, error) {
 		return m.idMapper.Sign(msg)
 	}
@@ -232,6 +391,13 @@ func (m *mockCryptoService) SignMessage(message *protos.RKSyncMessage) *protos.E
 	if err != nil {
 		return nil
 	}
+	if internalEndpoint != "" {
+		if err := envp.SignSecret(signer, &protos.Secret{
+			Content: &protos.Secret_InternalEndpoint{InternalEndpoint: internalEndpoint},
+		}); err != nil {
+			return nil
+		}
+	}
 	return envp
 }
 
@@ -259,8 +425,8 @@ func (m *mockCryptoService) ValidateAliveMsg(message *protos.SignedRKSyncMessage
 		}
 	}
 
-	verifier := func(pkiID []byte, signature, message []byte) error {
-		return m.idMapper.Verify(common.PKIidType(pkiID), signature, message)
+	verifier := func(pkiID []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return m.idMapper.Verify(common.PKIidType(pkiID), algorithm, signature, message)
 	}
 
 	err := message.Verify(am.Membership.PkiId, verifier)
@@ -274,18 +440,23 @@ func (m *mockCryptoService) SelfIdentity() common.PeerIdentityType {
 	return m.identity
 }
 
-// CreateRPCServerWithIdentity creates rpc server
+// CreateRPCServerWithIdentity creates rpc server, the peers are connected in memory
 func CreateRPCServerWithIdentity(address string, selfIdentity common.PeerIdentityType, idMapper identity.Identity) (*rpc.Server, error) {
-	srv, err := server.NewGRPCServer(address, &config.ServerConfig{
+	lis, err := server.ListenMemory(address)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := server.NewGRPCServerFromListener(lis, &config.ServerConfig{
 		SecOpts: &config.TLSConfig{UseTLS: false},
 	})
 	if err != nil {
+		lis.Close()
 		return nil, err
 	}
 
 	rpcSrv := rpc.NewServer(srv.Server(), idMapper, selfIdentity, func() []grpc.DialOption {
-		return []grpc.DialOption{grpc.WithInsecure()}
-	})
+		return []grpc.DialOption{grpc.WithInsecure(), grpc.WithContextDialer(server.DialMemory)}
+	}, rpc.SendBufferConfig{}, rpc.ConnPoolConfig{}, rpc.BreakerConfig{}, logging.Default())
 	go srv.Start()
 
 	return rpcSrv, nil
@@ -293,6 +464,10 @@ func CreateRPCServerWithIdentity(address string, selfIdentity common.PeerIdentit
 
 // CreateDiscoveryInstance creates discovery instance
 func CreateDiscoveryInstance(address string, num int) (Discovery, *rpc.Server, error) {
+	return createDiscoveryInstanceWithSelf(address, common.NetworkMember{Endpoint: address}, num, Config{})
+}
+
+func createDiscoveryInstanceWithSelf(address string, self common.NetworkMember, num int, conf Config) (Discovery, *rpc.Server, error) {
 	home, err := filepath.Abs(fmt.Sprintf("../tests/fixtures/identity/peer%d", num%3))
 	if err != nil {
 		return nil, nil, err
@@ -308,7 +483,7 @@ func CreateDiscoveryInstance(address string, num int) (Discovery, *rpc.Server, e
 		return nil, nil, err
 	}
 
-	idMapper, err := identity.NewIdentity(idcfg, selfIdentity, func(_ common.PKIidType) {})
+	idMapper, err := identity.NewIdentity(idcfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -319,8 +494,22 @@ func CreateDiscoveryInstance(address string, num int) (Discovery, *rpc.Server, e
 	}
 
 	mockRPC := &mockRPCService{rpc: rpc}
-	disc := NewDiscoveryService(common.NetworkMember{Endpoint: address, PKIID: rpc.GetPKIid()}, mockRPC, &mockCryptoService{idMapper, selfIdentity})
+	self.PKIID = rpc.GetPKIid()
+	disc := NewDiscoveryService(self, mockRPC, &mockCryptoService{idMapper, selfIdentity}, conf, logging.Default())
 	mockRPC.membership = disc.GetMembership
 
 	return disc, rpc, nil
 }
+
+func TestConfigDefaults(t *testing.T) {
+	conf := Config{}.withDefaults()
+	assert.Equal(t, defaultHelloInterval, conf.AliveTimeInterval)
+	assert.Equal(t, 5*defaultHelloInterval, conf.AliveExpirationTimeout)
+	assert.Equal(t, defaultHelloInterval, conf.ReconnectInterval)
+	assert.Equal(t, defaultMaxReconnectInterval, conf.MaxReconnectInterval)
+
+	conf = Config{AliveTimeInterval: time.Minute, ReconnectInterval: 10 * time.Minute}.withDefaults()
+	assert.Equal(t, 5*time.Minute, conf.AliveExpirationTimeout)
+	assert.Equal(t, 10*time.Minute, conf.ReconnectInterval)
+	assert.Equal(t, 10*time.Minute, conf.MaxReconnectInterval)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+)
+
+const defaultMaxReconnectInterval = 2 * time.Minute
+
+// backoff computes the delays between the attempts to reach a peer,
+// doubling at each failure up to a cap
+type backoff struct {
+	min      time.Duration
+	max      time.Duration
+	failures uint
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max}
+}
+
+// next counts a failed attempt and returns the delay before the next one,
+// jittered between half of the delay and the delay so that the peers
+// down at the same time aren't retried all together
+func (b *backoff) next() time.Duration {
+	delay := b.max
+	if b.failures < 32 {
+		if d := b.min << b.failures; d > 0 && d < b.max {
+			delay = d
+		}
+	}
+	b.failures++
+
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// reconnectManager schedules the attempts to reconnect to the dead members,
+// the recently dead members are retried more often than the long dead ones
+type reconnectManager struct {
+	minInterval time.Duration
+	maxInterval time.Duration
+	schedules   map[string]*reconnectSchedule
+}
+
+type reconnectSchedule struct {
+	backoff *backoff
+	at      time.Time
+}
+
+func newReconnectManager(minInterval, maxInterval time.Duration) *reconnectManager {
+	return &reconnectManager{
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+		schedules:   make(map[string]*reconnectSchedule),
+	}
+}
+
+// due returns the dead members an attempt is scheduled for, and schedules
+// the next attempt. The members that aren't dead anymore are forgotten.
+func (m *reconnectManager) due(dead []common.NetworkMember, now time.Time) []common.NetworkMember {
+	deadIDs := make(map[string]struct{}, len(dead))
+	var res []common.NetworkMember
+	for _, member := range dead {
+		id := member.PKIID.String()
+		deadIDs[id] = struct{}{}
+
+		s, exists := m.schedules[id]
+		if !exists {
+			s = &reconnectSchedule{backoff: newBackoff(m.minInterval, m.maxInterval), at: now}
+			m.schedules[id] = s
+		}
+		if now.Before(s.at) {
+			continue
+		}
+		s.at = now.Add(s.backoff.next())
+		res = append(res, member)
+	}
+
+	for id := range m.schedules {
+		if _, isDead := deadIDs[id]; !isDead {
+			delete(m.schedules, id)
+		}
+	}
+	return res
+}
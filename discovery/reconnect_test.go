@@ -0,0 +1,51 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	bo := newBackoff(time.Second, 10*time.Second)
+	for _, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay := bo.next()
+		assert.True(t, delay >= max/2 && delay <= max, "delay %s should be between %s and %s", delay, max/2, max)
+	}
+
+	for i := 0; i < 100; i++ {
+		bo.next()
+	}
+	assert.True(t, bo.next() <= 10*time.Second)
+}
+
+func TestReconnectManager(t *testing.T) {
+	m := newReconnectManager(time.Second, time.Minute)
+	p1 := common.NetworkMember{Endpoint: "p1", PKIID: common.PKIidType("p1")}
+	p2 := common.NetworkMember{Endpoint: "p2", PKIID: common.PKIidType("p2")}
+
+	now := time.Now()
+	assert.Len(t, m.due([]common.NetworkMember{p1}, now), 1)
+	assert.Empty(t, m.due([]common.NetworkMember{p1}, now))
+
+	// p2 died recently and is retried right away
+	assert.Equal(t, []common.NetworkMember{p2}, m.due([]common.NetworkMember{p1, p2}, now))
+	assert.Len(t, m.due([]common.NetworkMember{p1, p2}, now.Add(time.Second)), 2)
+
+	// the delay doubled after the second attempt
+	assert.Empty(t, m.due([]common.NetworkMember{p1, p2}, now.Add(1500*time.Millisecond)))
+	assert.Len(t, m.due([]common.NetworkMember{p1, p2}, now.Add(3*time.Second)), 2)
+
+	// p1 isn't dead anymore, it's forgotten
+	m.due([]common.NetworkMember{p2}, now.Add(time.Minute))
+	assert.Len(t, m.schedules, 1)
+	assert.Equal(t, []common.NetworkMember{p1}, m.due([]common.NetworkMember{p1, p2}, now.Add(time.Minute)))
+}
@@ -9,24 +9,25 @@ package discovery
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
-	maxConnectionAttempts = 120
-	msgExpirationFactor   = 20
-	defaultHelloInterval  = time.Duration(5) * time.Second
+	msgExpirationFactor  = 20
+	defaultHelloInterval = time.Duration(5) * time.Second
 )
 
 type timestamp struct {
@@ -40,7 +41,12 @@ func (ts *timestamp) String() string {
 }
 
 // NewDiscoveryService returns a new discovery service
-func NewDiscoveryService(self common.NetworkMember, rpc RPCService, crypt CryptoService) Discovery {
+func NewDiscoveryService(self common.NetworkMember, rpc RPCService, crypt CryptoService, conf Config, logger logging.Logger) Discovery {
+	conf = conf.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	if conf.DisclosurePolicy == nil {
+		conf.DisclosurePolicy = NewDisclosurePolicy(self)
+	}
 	d := &gossipDiscoveryService{
 		self:                         self,
 		incTime:                      uint64(time.Now().UnixNano()),
@@ -52,13 +58,17 @@ func NewDiscoveryService(self common.NetworkMember, rpc RPCService, crypt Crypto
 		deadMembership:               lib.NewMembershipStore(),
 		crypt:                        crypt,
 		rpc:                          rpc,
-		toDieChan:                    make(chan struct{}, 1),
-		toDieFlag:                    int32(0),
+		ctx:                          ctx,
+		cancel:                       cancel,
 		pubsub:                       lib.NewPubSub(),
-		aliveTimeInterval:            defaultHelloInterval,
-		aliveExpirationTimeout:       5 * defaultHelloInterval,
-		aliveExpirationCheckInterval: 5 * defaultHelloInterval / 10,
-		reconnectInterval:            5 * defaultHelloInterval,
+		aliveTimeInterval:            conf.AliveTimeInterval,
+		aliveExpirationTimeout:       conf.AliveExpirationTimeout,
+		aliveExpirationCheckInterval: conf.AliveExpirationTimeout / 10,
+		minReconnectInterval:         conf.ReconnectInterval,
+		maxReconnectInterval:         conf.MaxReconnectInterval,
+		disclosurePolicy:             conf.DisclosurePolicy,
+		clock:                        conf.Clock,
+		logger:                       logger,
 	}
 
 	d.msgStore = newAliveMsgStore(d)
@@ -87,48 +97,58 @@ type gossipDiscoveryService struct {
 	deadMembership               *lib.MembershipStore
 	selfAliveMessage             *protos.SignedRKSyncMessage
 	msgStore                     *aliveMsgStore
-	toDieChan                    chan struct{}
-	toDieFlag                    int32
-	reconnectInterval            time.Duration
+	ctx                          context.Context // cancelled by Stop
+	cancel                       context.CancelFunc
+	leavingFlag                  int32
+	minReconnectInterval         time.Duration
+	maxReconnectInterval         time.Duration
 	aliveTimeInterval            time.Duration
 	aliveExpirationTimeout       time.Duration
 	aliveExpirationCheckInterval time.Duration
+	disclosurePolicy             DisclosurePolicy
+	clock                        lib.Clock
+	logger                       logging.Logger
 }
 
-func (d *gossipDiscoveryService) Connect(member common.NetworkMember, id identifier) {
+func (d *gossipDiscoveryService) Connect(member common.NetworkMember, id Identifier) {
 	if d.isMyOwnEndpoint(member.Endpoint) {
-		logging.Debug("Skipping connecting to myself")
+		d.logger.Debug("Skipping connecting to myself")
 		return
 	}
 
-	logging.Debug("Entering", member)
-	defer logging.Debug("Exiting")
+	d.logger.Debug("Entering", member)
+	defer d.logger.Debug("Exiting")
 	go func() {
-		for i := 0; i < maxConnectionAttempts && !d.toDie(); i++ {
+		// the peer is retried until it's reached, it may be temporarily down
+		bo := newBackoff(d.minReconnectInterval, d.maxReconnectInterval)
+		for !d.toDie() {
 			id, err := id()
 			if err != nil {
 				if d.toDie() {
 					return
 				}
-				logging.Warningf("Could not connect to %v: %v", member, err)
-				time.Sleep(d.reconnectInterval)
+				delay := bo.next()
+				d.logger.Warningf("Could not connect to %v, retrying in %s: %v", member, delay, err)
+				if !d.sleep(delay) {
+					return
+				}
 				continue
 			}
 			peer := &common.NetworkMember{Endpoint: member.Endpoint, PKIID: id}
-			m, err := d.createMembershipRequest()
+			m, err := d.createMembershipRequest(peer)
 			if err != nil {
-				logging.Warningf("Failed creating membership request: %+v", errors.WithStack(err))
+				d.logger.Warningf("Failed creating membership request: %+v", errors.WithStack(err))
 				continue
 			}
 			req, err := m.NoopSign()
 			if err != nil {
-				logging.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
+				d.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
 				continue
 			}
 			req.Nonce = util.RandomUInt64()
 			req, err = req.NoopSign()
 			if err != nil {
-				logging.Warningf("Failed adding NONCE to SignedRKSyncMessage: %+v", errors.WithStack(err))
+				d.logger.Warningf("Failed adding NONCE to SignedRKSyncMessage: %+v", errors.WithStack(err))
 				continue
 			}
 			go d.sendUntilAcked(peer, req)
@@ -161,16 +181,6 @@ func (d *gossipDiscoveryService) InitiateSync(peerNum int) {
 		return
 	}
 	var peers2SendTo []*common.NetworkMember
-	m, err := d.createMembershipRequest()
-	if err != nil {
-		logging.Warningf("Failed creating membership request: %+v", errors.WithStack(err))
-		return
-	}
-	memReq, err := m.NoopSign()
-	if err != nil {
-		logging.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
-		return
-	}
 	d.lock.RLock()
 
 	n := d.aliveMembership.Size()
@@ -185,6 +195,13 @@ func (d *gossipDiscoveryService) InitiateSync(peerNum int) {
 		netMember := &common.NetworkMember{
 			Endpoint: pulledPeer.Endpoint,
 			PKIID:    pulledPeer.PkiId,
+			Zone:     pulledPeer.Zone,
+			Org:      pulledPeer.Org,
+			Labels:   pulledPeer.Labels,
+			Leaving:  aliveMembersAsSlice[i].GetAliveMsg().Leaving,
+		}
+		if member, exists := d.id2Member[common.PKIidType(pulledPeer.PkiId).String()]; exists {
+			netMember.InternalEndpoint = member.InternalEndpoint
 		}
 		peers2SendTo = append(peers2SendTo, netMember)
 	}
@@ -192,7 +209,7 @@ func (d *gossipDiscoveryService) InitiateSync(peerNum int) {
 	d.lock.RUnlock()
 
 	for _, member := range peers2SendTo {
-		d.rpc.SendToPeer(member, memReq)
+		d.sendMembershipRequest(member)
 	}
 }
 
@@ -206,56 +223,130 @@ func (d *gossipDiscoveryService) GetMembership() []common.NetworkMember {
 	response := []common.NetworkMember{}
 	for _, m := range d.aliveMembership.ToSlice() {
 		member := m.GetAliveMsg()
-		response = append(response, common.NetworkMember{Endpoint: member.Membership.Endpoint, PKIID: member.Membership.PkiId})
+		netMember := common.NetworkMember{
+			Endpoint: member.Membership.Endpoint,
+			PKIID:    member.Membership.PkiId,
+			Tags:     member.Membership.Tags,
+			Zone:     member.Membership.Zone,
+			Org:      member.Membership.Org,
+			Labels:   member.Membership.Labels,
+			Leaving:  member.Leaving,
+		}
+		if known, exists := d.id2Member[common.PKIidType(member.Membership.PkiId).String()]; exists {
+			netMember.InternalEndpoint = known.InternalEndpoint
+		}
+		response = append(response, netMember)
 	}
 	return response
 }
 
+func (d *gossipDiscoveryService) Leave() {
+	if d.toDie() || !atomic.CompareAndSwapInt32(&d.leavingFlag, int32(0), int32(1)) {
+		return
+	}
+
+	msg, err := d.createSignedAliveMessage()
+	if err != nil {
+		d.logger.Warningf("Failed creating alive message: %+v", errors.WithStack(err))
+		return
+	}
+	d.lock.Lock()
+	d.selfAliveMessage = msg
+	d.lock.Unlock()
+	d.rpc.Gossip(msg)
+}
+
 func (d *gossipDiscoveryService) Stop() {
-	defer logging.Info("Stopped discovery")
-	logging.Info("Stopping discovery")
+	defer d.logger.Info("Stopped discovery")
+	d.logger.Info("Stopping discovery")
 
-	atomic.StoreInt32(&d.toDieFlag, int32(1))
+	d.cancel()
 	d.msgStore.Stop()
-	d.toDieChan <- struct{}{}
 }
 
 func (d *gossipDiscoveryService) sendUntilAcked(peer *common.NetworkMember, message *protos.SignedRKSyncMessage) {
 	nonce := message.Nonce
-	for i := 0; i < maxConnectionAttempts && !d.toDie(); i++ {
+	// the request is resent until it's acknowledged, with the same backoff as the connection attempts
+	bo := newBackoff(d.minReconnectInterval, d.maxReconnectInterval)
+	for !d.toDie() {
 		sub := d.pubsub.Subscribe(fmt.Sprintf("%d", nonce), time.Second*5)
 		d.rpc.SendToPeer(peer, message)
 		if _, timeoutErr := sub.Listen(); timeoutErr == nil {
 			return
 		}
-		time.Sleep(d.reconnectInterval)
+		delay := bo.next()
+		d.logger.Debugf("%s didn't acknowledge the membership request, resending it in %s", peer.Endpoint, delay)
+		if !d.sleep(delay) {
+			return
+		}
 	}
 }
 
-func (d *gossipDiscoveryService) createMembershipResponse(aliveMsg *protos.SignedRKSyncMessage) *protos.MembershipResponse {
+// createMembershipResponse returns the membership disclosed to the target member. If the membership request
+// carries the digests of the peers known to the target member, only the peers it doesn't know or knows
+// an older alive message of are returned, and the response tells the peers it knows better of.
+func (d *gossipDiscoveryService) createMembershipResponse(aliveMsg *protos.SignedRKSyncMessage, targetMember *common.NetworkMember, digests []*protos.MembershipDigest) *protos.MembershipResponse {
+	shouldBeDisclosed, envelopeFilter := d.disclosurePolicy(targetMember)
+
+	known := make(map[string]*protos.PeerTime, len(digests))
+	for _, digest := range digests {
+		if digest.Timestamp != nil {
+			known[common.PKIidType(digest.PkiId).String()] = digest.Timestamp
+		}
+	}
+	isKnown := func(m *protos.SignedRKSyncMessage) bool {
+		am := m.GetAliveMsg()
+		ts, exists := known[common.PKIidType(am.Membership.PkiId).String()]
+		return exists && !newer(am.Timestamp, ts)
+	}
+
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
 	deadPeers := []*protos.Envelope{}
 	for _, dm := range d.deadMembership.ToSlice() {
-		envp := proto.Clone(dm.Envelope).(*protos.Envelope)
+		if !shouldBeDisclosed(dm) || isKnown(dm) {
+			continue
+		}
+		envp := proto.Clone(envelopeFilter(dm)).(*protos.Envelope)
 		deadPeers = append(deadPeers, envp)
 	}
 
 	aliveSnapshot := []*protos.Envelope{}
 	for _, am := range d.aliveMembership.ToSlice() {
-		envp := proto.Clone(am.Envelope).(*protos.Envelope)
+		if !shouldBeDisclosed(am) || isKnown(am) {
+			continue
+		}
+		envp := proto.Clone(envelopeFilter(am)).(*protos.Envelope)
 		aliveSnapshot = append(aliveSnapshot, envp)
 	}
 
-	e := proto.Clone(aliveMsg.Envelope).(*protos.Envelope)
+	var wanted [][]byte
+	for _, digest := range digests {
+		pkiID := common.PKIidType(digest.PkiId)
+		if digest.Timestamp == nil || bytes.Equal(pkiID, d.self.PKIID) {
+			continue
+		}
+		m := d.aliveMembership.MsgByID(pkiID)
+		if m == nil {
+			m = d.deadMembership.MsgByID(pkiID)
+		}
+		if m == nil || newer(digest.Timestamp, m.GetAliveMsg().Timestamp) {
+			wanted = append(wanted, pkiID)
+		}
+	}
+
+	e := proto.Clone(envelopeFilter(aliveMsg)).(*protos.Envelope)
 	return &protos.MembershipResponse{
-		Alive: append(aliveSnapshot, e),
-		Dead:  deadPeers,
+		Alive:  append(aliveSnapshot, e),
+		Dead:   deadPeers,
+		Wanted: wanted,
 	}
 }
 
-func (d *gossipDiscoveryService) createMembershipRequest() (*protos.RKSyncMessage, error) {
+func (d *gossipDiscoveryService) createMembershipRequest(targetMember *common.NetworkMember) (*protos.RKSyncMessage, error) {
+	shouldBeDisclosed, envelopeFilter := d.disclosurePolicy(targetMember)
+
 	var am *protos.SignedRKSyncMessage
 	var err error
 
@@ -270,22 +361,27 @@ func (d *gossipDiscoveryService) createMembershipRequest() (*protos.RKSyncMessag
 		}
 	}
 
-	e := proto.Clone(am.Envelope).(*protos.Envelope)
+	e := proto.Clone(envelopeFilter(am)).(*protos.Envelope)
 	req := &protos.MembershipRequest{SelfInformation: e}
 
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
-	aliveSnapshot := []*protos.Envelope{}
-	for _, am := range d.aliveMembership.ToSlice() {
-		if !am.IsAliveMsg() {
-			logging.Fatal("createMembershipRequest: Programming error, am should be alive messages")
+	// Instead of the alive messages of the known peers, their digests are sent,
+	// the remote peer asks for the alive messages it doesn't know of in its response
+	for _, m := range append(d.aliveMembership.ToSlice(), d.deadMembership.ToSlice()...) {
+		am := m.GetAliveMsg()
+		if am == nil {
+			d.logger.Fatal("createMembershipRequest: Programming error, am should be alive messages")
 		}
-
-		envp := proto.Clone(am.Envelope).(*protos.Envelope)
-		aliveSnapshot = append(aliveSnapshot, envp)
+		if !shouldBeDisclosed(m) {
+			continue
+		}
+		req.Digests = append(req.Digests, &protos.MembershipDigest{
+			PkiId:     am.Membership.PkiId,
+			Timestamp: am.Timestamp,
+		})
 	}
-	req.Known = aliveSnapshot
 
 	return &protos.RKSyncMessage{
 		Tag:   protos.RKSyncMessage_EMPTY,
@@ -298,7 +394,7 @@ func (d *gossipDiscoveryService) createMembershipRequest() (*protos.RKSyncMessag
 
 func (d *gossipDiscoveryService) createSignedAliveMessage() (*protos.SignedRKSyncMessage, error) {
 	msg := d.aliveMsg()
-	envp := d.crypt.SignMessage(msg)
+	envp := d.crypt.SignMessage(msg, d.self.InternalEndpoint)
 	if envp == nil {
 		return nil, errors.New("Failed signing message")
 	}
@@ -324,12 +420,17 @@ func (d *gossipDiscoveryService) aliveMsg() *protos.RKSyncMessage {
 				Membership: &protos.Member{
 					Endpoint: endpoint,
 					PkiId:    pkiID,
+					Tags:     d.self.Tags,
+					Zone:     d.self.Zone,
+					Org:      d.self.Org,
+					Labels:   d.self.Labels,
 				},
 				Timestamp: &protos.PeerTime{
 					IncNum: uint64(d.incTime),
 					SeqNum: seq,
 				},
 				Identity: d.crypt.SelfIdentity(),
+				Leaving:  atomic.LoadInt32(&d.leavingFlag) == int32(1),
 			},
 		},
 	}
@@ -341,11 +442,11 @@ func (d *gossipDiscoveryService) isMyOwnEndpoint(endpoint string) bool {
 	// return strings.Contains(endpoint, "127.0.0.1") || strings.Contains(endpoint, "localhost") ||
 	// 	endpoint == d.self.Endpoint
 
-	return endpoint == d.self.Endpoint
+	return endpoint == d.self.Endpoint || (d.self.InternalEndpoint != "" && endpoint == d.self.InternalEndpoint)
 }
 
 func (d *gossipDiscoveryService) toDie() bool {
-	return atomic.LoadInt32(&d.toDieFlag) == int32(1)
+	return d.ctx.Err() != nil
 }
 
 func (d *gossipDiscoveryService) getDeadMembers() []common.PKIidType {
@@ -354,58 +455,58 @@ func (d *gossipDiscoveryService) getDeadMembers() []common.PKIidType {
 
 	dead := []common.PKIidType{}
 	for id, last := range d.aliveLastTS {
-		elapsedNonAliveTime := time.Since(last.lastSeen)
+		elapsedNonAliveTime := d.clock.Since(last.lastSeen)
 		if elapsedNonAliveTime > d.aliveExpirationTimeout {
-			logging.Warning("Haven't heard from", id, "for", elapsedNonAliveTime)
-			dead = append(dead, common.PKIidType(id))
+			d.logger.Warning("Haven't heard from", id, "for", elapsedNonAliveTime)
+			// the keys are the hex encoded PKI-IDs
+			pkiID, _ := hex.DecodeString(id)
+			dead = append(dead, common.PKIidType(pkiID))
 		}
 	}
 	return dead
 }
 
 func (d *gossipDiscoveryService) periodicalSendAlive() {
-	defer logging.Debug("Stopped")
+	defer d.logger.Debug("Stopped")
 
 	for !d.toDie() {
 		select {
-		case <-time.After(d.aliveTimeInterval):
+		case <-d.clock.After(d.aliveTimeInterval):
 			msg, err := d.createSignedAliveMessage()
 			if err != nil {
-				logging.Warningf("Failed creating alive message: %+v", errors.WithStack(err))
+				d.logger.Warningf("Failed creating alive message: %+v", errors.WithStack(err))
 				return
 			}
 			d.lock.Lock()
 			d.selfAliveMessage = msg
 			d.lock.Unlock()
 			d.rpc.Gossip(msg)
-		case s := <-d.toDieChan:
-			d.toDieChan <- s
+		case <-d.ctx.Done():
 			return
 		}
 	}
 }
 
 func (d *gossipDiscoveryService) periodicalCheckAlive() {
-	defer logging.Debug("Stopped")
+	defer d.logger.Debug("Stopped")
 
 	for !d.toDie() {
 		select {
-		case <-time.After(d.aliveExpirationCheckInterval):
+		case <-d.clock.After(d.aliveExpirationCheckInterval):
 			dead := d.getDeadMembers()
 			if len(dead) > 0 {
-				logging.Debug("Got %d dead members: %v", len(dead), dead)
+				d.logger.Debug("Got %d dead members: %v", len(dead), dead)
 				d.expireDeadMembers(dead)
 			}
-		case s := <-d.toDieChan:
-			d.toDieChan <- s
+		case <-d.ctx.Done():
 			return
 		}
 	}
 }
 
 func (d *gossipDiscoveryService) expireDeadMembers(dead []common.PKIidType) {
-	logging.Warning("Entering", dead)
-	defer logging.Warning("Exiting")
+	d.logger.Warning("Entering", dead)
+	defer d.logger.Warning("Exiting")
 
 	var deadMembers2Expire []*common.NetworkMember
 
@@ -431,19 +532,19 @@ func (d *gossipDiscoveryService) expireDeadMembers(dead []common.PKIidType) {
 	d.lock.Unlock()
 
 	for _, member2Expire := range deadMembers2Expire {
-		logging.Warning("Closing connection to", member2Expire)
+		d.logger.Warning("Closing connection to", member2Expire)
 		d.rpc.CloseConn(member2Expire)
+		d.rpc.MemberDead(*member2Expire)
 	}
 }
 
 func (d *gossipDiscoveryService) handleMessage() {
-	defer logging.Debug("Stopped")
+	defer d.logger.Debug("Stopped")
 
 	in := d.rpc.Accept()
 	for !d.toDie() {
 		select {
-		case s := <-d.toDieChan:
-			d.toDieChan <- s
+		case <-d.ctx.Done():
 			return
 		case m := <-in:
 			d.handleMsgFromRPC(m)
@@ -457,21 +558,21 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 	}
 	m := msg.GetRKSyncMessage()
 	if m.GetAliveMsg() == nil && m.GetMemRes() == nil && m.GetMemReq() == nil {
-		logging.Warning("Got message with wrong type (expected Alive or MembershipResponse or MembershipRequest message)", m.RKSyncMessage)
+		d.logger.Warning("Got message with wrong type (expected Alive or MembershipResponse or MembershipRequest message)", m.RKSyncMessage)
 		return
 	}
 
-	logging.Debug("Got message:", m)
-	defer logging.Debug("Exiting")
+	d.logger.Debug("Got message:", m)
+	defer d.logger.Debug("Exiting")
 
 	if memReq := m.GetMemReq(); memReq != nil {
 		selfInRKSyncMsg, err := memReq.SelfInformation.ToRKSyncMessage()
 		if err != nil {
-			logging.Warningf("Failed deserializing RKSyncMessage from envelope: %+v", errors.WithStack(err))
+			d.logger.Warningf("Failed deserializing RKSyncMessage from envelope: %+v", errors.WithStack(err))
 			return
 		}
 		if !d.crypt.ValidateAliveMsg(selfInRKSyncMsg) {
-			logging.Warningf("Failed validating alive message: %+v", selfInRKSyncMsg)
+			d.logger.Warningf("Failed validating alive message: %+v", selfInRKSyncMsg)
 			return
 		}
 		if d.msgStore.CheckValid(selfInRKSyncMsg) {
@@ -482,11 +583,11 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 		for _, envp := range memReq.Known {
 			msg, err := envp.ToRKSyncMessage()
 			if err != nil {
-				logging.Warningf("Failed deserializing RKSyncMessage from envelope: %+v", errors.WithStack(err))
+				d.logger.Warningf("Failed deserializing RKSyncMessage from envelope: %+v", errors.WithStack(err))
 				continue
 			}
 			if !d.crypt.ValidateAliveMsg(msg) {
-				logging.Warningf("Failed validating alive message: %+v", msg)
+				d.logger.Warningf("Failed validating alive message: %+v", msg)
 				continue
 			}
 			if !d.msgStore.Add(msg) {
@@ -499,7 +600,7 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 			d.handleAliveMessage(aliveMsg)
 		}
 
-		go d.sendMemResponse(selfInRKSyncMsg.GetAliveMsg().Membership, m.Nonce)
+		go d.sendMemResponse(selfInRKSyncMsg, m.Nonce, memReq.Digests)
 		return
 	}
 
@@ -522,11 +623,11 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 		for _, env := range memResp.Alive {
 			am, err := env.ToRKSyncMessage()
 			if err != nil {
-				logging.Warningf("Membership response contains an invalid message from an online peer: %+v", errors.WithStack(err))
+				d.logger.Warningf("Membership response contains an invalid message from an online peer: %+v", errors.WithStack(err))
 				return
 			}
 			if !am.IsAliveMsg() {
-				logging.Warning("Expected alive message, got", am, "instead")
+				d.logger.Warning("Expected alive message, got", am, "instead")
 				return
 			}
 			if d.msgStore.CheckValid(am) && d.crypt.ValidateAliveMsg(am) {
@@ -537,7 +638,7 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 		for _, env := range memResp.Dead {
 			dm, err := env.ToRKSyncMessage()
 			if err != nil {
-				logging.Warningf("Membership response contains an invalid message from an online peer %+v", errors.WithStack(err))
+				d.logger.Warningf("Membership response contains an invalid message from an online peer %+v", errors.WithStack(err))
 				return
 			}
 			if !d.msgStore.CheckValid(dm) || !d.crypt.ValidateAliveMsg(dm) {
@@ -552,12 +653,16 @@ func (d *gossipDiscoveryService) handleMsgFromRPC(msg protos.ReceivedMessage) {
 			d.lock.RUnlock()
 			d.learnNewMembers([]*protos.SignedRKSyncMessage{}, newDeadMembers)
 		}
+
+		if len(memResp.Wanted) > 0 {
+			go d.sendWanted(msg.GetConnectionInfo(), memResp.Wanted)
+		}
 	}
 }
 
 func (d *gossipDiscoveryService) handleAliveMessage(m *protos.SignedRKSyncMessage) {
-	logging.Debug("Entering", m)
-	defer logging.Debug("Exiting")
+	d.logger.Debug("Entering", m)
+	defer d.logger.Debug("Exiting")
 
 	if d.isSentByMe(m) {
 		return
@@ -581,20 +686,20 @@ func (d *gossipDiscoveryService) handleAliveMessage(m *protos.SignedRKSyncMessag
 	d.lock.RUnlock()
 
 	if !isAlive && !isDead {
-		logging.Fatalf("Member %s is known but not found neither in alive nor in dead lastTS maps, isAlive=%v, isDead=%v", m.GetAliveMsg().Membership.Endpoint, isAlive, isDead)
+		d.logger.Fatalf("Member %s is known but not found neither in alive nor in dead lastTS maps, isAlive=%v, isDead=%v", m.GetAliveMsg().Membership.Endpoint, isAlive, isDead)
 		return
 	}
 
 	if isAlive && isDead {
-		logging.Fatalf("Member %s is both alive and dead at the same time", m.GetAliveMsg().Membership.Endpoint)
+		d.logger.Fatalf("Member %s is both alive and dead at the same time", m.GetAliveMsg().Membership.Endpoint)
 		return
 	}
 
 	if isDead {
 		if before(lastDeadTS, ts) {
-			d.resurrectMember(m, *ts)
+			d.resurrectMember(m, ts)
 		} else if !same(lastDeadTS, ts) {
-			logging.Debug(m.GetAliveMsg().Membership, "lastDeadTS:", lastDeadTS, "but got ts:", ts)
+			d.logger.Debug(m.GetAliveMsg().Membership, "lastDeadTS:", lastDeadTS, "but got ts:", ts)
 		}
 		return
 	}
@@ -607,21 +712,23 @@ func (d *gossipDiscoveryService) handleAliveMessage(m *protos.SignedRKSyncMessag
 		if before(lastAliveTS, ts) {
 			d.learnExistingMembers([]*protos.SignedRKSyncMessage{m})
 		} else if !same(lastAliveTS, ts) {
-			logging.Debug(m.GetAliveMsg().Membership, "lastAliveTS:", lastAliveTS, "but got ts:", ts)
+			d.logger.Debug(m.GetAliveMsg().Membership, "lastAliveTS:", lastAliveTS, "but got ts:", ts)
 		}
 	}
 }
 
-func (d *gossipDiscoveryService) sendMemResponse(target *protos.Member, nonce uint64) {
-	logging.Debug("Entering", target)
+func (d *gossipDiscoveryService) sendMemResponse(selfInfo *protos.SignedRKSyncMessage, nonce uint64, digests []*protos.MembershipDigest) {
+	target := selfInfo.GetAliveMsg().Membership
+	d.logger.Debug("Entering", target)
 
 	targetPeer := &common.NetworkMember{
-		Endpoint: target.Endpoint,
-		PKIID:    target.PkiId,
+		Endpoint:         target.Endpoint,
+		InternalEndpoint: selfInfo.Envelope.SecretEnvelope.InternalEndpoint(),
+		PKIID:            target.PkiId,
 	}
 
 	if targetPeer.Endpoint == "" {
-		logging.Warningf("Discovery: Target endpoint is empty, this should be a programming error")
+		d.logger.Warningf("Discovery: Target endpoint is empty, this should be a programming error")
 		d.rpc.CloseConn(targetPeer)
 		return
 	}
@@ -635,13 +742,13 @@ func (d *gossipDiscoveryService) sendMemResponse(target *protos.Member, nonce ui
 	if aliveMsg == nil {
 		aliveMsg, err = d.createSignedAliveMessage()
 		if err != nil {
-			logging.Warningf("Failed creating alive message: %+v", errors.WithStack(err))
+			d.logger.Warningf("Failed creating alive message: %+v", errors.WithStack(err))
 			return
 		}
 	}
 
-	memResp := d.createMembershipResponse(aliveMsg)
-	defer logging.Debug("Exiting, replying with", memResp)
+	memResp := d.createMembershipResponse(aliveMsg, targetPeer, digests)
+	defer d.logger.Debug("Exiting, replying with", memResp)
 
 	msg, err := (&protos.RKSyncMessage{
 		Tag:   protos.RKSyncMessage_EMPTY,
@@ -652,15 +759,53 @@ func (d *gossipDiscoveryService) sendMemResponse(target *protos.Member, nonce ui
 	}).NoopSign()
 
 	if err != nil {
-		logging.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
+		d.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
+		return
+	}
+	d.rpc.SendToPeer(targetPeer, msg)
+}
+
+// sendWanted pushes the alive messages of the peers asked for in a membership response
+func (d *gossipDiscoveryService) sendWanted(info *protos.ConnectionInfo, wanted [][]byte) {
+	if info == nil {
+		return
+	}
+	targetPeer := d.Lookup(info.ID)
+	if targetPeer == nil {
+		targetPeer = &common.NetworkMember{Endpoint: info.Endpoint, PKIID: info.ID}
+	}
+	shouldBeDisclosed, envelopeFilter := d.disclosurePolicy(targetPeer)
+
+	memResp := &protos.MembershipResponse{}
+	d.lock.RLock()
+	for _, pkiID := range wanted {
+		if am := d.aliveMembership.MsgByID(common.PKIidType(pkiID)); am != nil && shouldBeDisclosed(am) {
+			memResp.Alive = append(memResp.Alive, proto.Clone(envelopeFilter(am)).(*protos.Envelope))
+		} else if dm := d.deadMembership.MsgByID(common.PKIidType(pkiID)); dm != nil && shouldBeDisclosed(dm) {
+			memResp.Dead = append(memResp.Dead, proto.Clone(envelopeFilter(dm)).(*protos.Envelope))
+		}
+	}
+	d.lock.RUnlock()
+	if len(memResp.Alive) == 0 && len(memResp.Dead) == 0 {
+		return
+	}
+
+	msg, err := (&protos.RKSyncMessage{
+		Tag: protos.RKSyncMessage_EMPTY,
+		Content: &protos.RKSyncMessage_MemRes{
+			MemRes: memResp,
+		},
+	}).NoopSign()
+	if err != nil {
+		d.logger.Warningf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
 		return
 	}
 	d.rpc.SendToPeer(targetPeer, msg)
 }
 
 func (d *gossipDiscoveryService) learnExistingMembers(aliveArr []*protos.SignedRKSyncMessage) {
-	logging.Debugf("Entering: learnedMembers={%v}", aliveArr)
-	defer logging.Debug("Exiting")
+	d.logger.Debugf("Entering: learnedMembers={%v}", aliveArr)
+	defer d.logger.Debug("Exiting")
 
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -668,35 +813,44 @@ func (d *gossipDiscoveryService) learnExistingMembers(aliveArr []*protos.SignedR
 	for _, m := range aliveArr {
 		am := m.GetAliveMsg()
 		if am == nil {
-			logging.Warning("Expecting alive message, got instead:", m)
+			d.logger.Warning("Expecting alive message, got instead:", m)
 			return
 		}
-		logging.Debug("updating", am)
+		d.logger.Debug("updating", am)
 
 		member := d.id2Member[common.PKIidType(am.Membership.PkiId).String()]
 		member.Endpoint = am.Membership.Endpoint
+		member.Tags = am.Membership.Tags
+		member.Zone = am.Membership.Zone
+		member.Org = am.Membership.Org
+		member.Labels = am.Membership.Labels
+		member.Leaving = am.Leaving
+		// Alive messages relayed by peers of another network don't carry the internal endpoint
+		if internalEndpoint := m.Envelope.SecretEnvelope.InternalEndpoint(); internalEndpoint != "" {
+			member.InternalEndpoint = internalEndpoint
+		}
 
 		if _, isKnownAsDead := d.deadLastTS[common.PKIidType(am.Membership.PkiId).String()]; isKnownAsDead {
-			logging.Warning(am.Membership, "has already expired")
+			d.logger.Warning(am.Membership, "has already expired")
 			continue
 		}
 
 		if _, isKnownAsAlive := d.aliveLastTS[common.PKIidType(am.Membership.PkiId).String()]; !isKnownAsAlive {
-			logging.Warning(am.Membership, "has already expired")
+			d.logger.Warning(am.Membership, "has already expired")
 			continue
 		} else {
-			logging.Debug("Updating aliveness data:", am)
+			d.logger.Debug("Updating aliveness data:", am)
 			alive := d.aliveLastTS[common.PKIidType(am.Membership.PkiId).String()]
 			alive.incTime = tsToTime(am.Timestamp.IncNum)
-			alive.lastSeen = time.Now()
+			alive.lastSeen = d.clock.Now()
 			alive.seqNum = am.Timestamp.SeqNum
 
 			if am := d.aliveMembership.MsgByID(m.GetAliveMsg().Membership.PkiId); am == nil {
-				logging.Debug("Adding", am, "to aliveMembership")
+				d.logger.Debug("Adding", am, "to aliveMembership")
 				msg := &protos.SignedRKSyncMessage{RKSyncMessage: m.RKSyncMessage, Envelope: m.Envelope}
 				d.aliveMembership.Put(m.GetAliveMsg().Membership.PkiId, msg)
 			} else {
-				logging.Debug("Replacing", am, "in aliveMembership")
+				d.logger.Debug("Replacing", am, "in aliveMembership")
 				am.RKSyncMessage = m.RKSyncMessage
 				am.Envelope = m.Envelope
 			}
@@ -704,23 +858,29 @@ func (d *gossipDiscoveryService) learnExistingMembers(aliveArr []*protos.SignedR
 	}
 }
 
-func (d *gossipDiscoveryService) resurrectMember(am *protos.SignedRKSyncMessage, t protos.PeerTime) {
-	logging.Debug("Entering, AliveMessage:", am, "t:", t)
-	defer logging.Debug("Exiting")
+func (d *gossipDiscoveryService) resurrectMember(am *protos.SignedRKSyncMessage, t *protos.PeerTime) {
+	d.logger.Debug("Entering, AliveMessage:", am, "t:", t)
+	defer d.logger.Debug("Exiting")
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
 	member := am.GetAliveMsg().Membership
 	pkiID := member.PkiId
 	d.aliveLastTS[common.PKIidType(pkiID).String()] = &timestamp{
-		lastSeen: time.Now(),
+		lastSeen: d.clock.Now(),
 		seqNum:   t.SeqNum,
 		incTime:  tsToTime(t.IncNum),
 	}
 
 	d.id2Member[common.PKIidType(pkiID).String()] = &common.NetworkMember{
-		Endpoint: member.Endpoint,
-		PKIID:    member.PkiId,
+		Endpoint:         member.Endpoint,
+		InternalEndpoint: am.Envelope.SecretEnvelope.InternalEndpoint(),
+		PKIID:            member.PkiId,
+		Tags:             member.Tags,
+		Zone:             member.Zone,
+		Org:              member.Org,
+		Labels:           member.Labels,
+		Leaving:          am.GetAliveMsg().Leaving,
 	}
 
 	delete(d.deadLastTS, common.PKIidType(pkiID).String())
@@ -729,8 +889,8 @@ func (d *gossipDiscoveryService) resurrectMember(am *protos.SignedRKSyncMessage,
 }
 
 func (d *gossipDiscoveryService) learnNewMembers(aliveMembers []*protos.SignedRKSyncMessage, deadMembers []*protos.SignedRKSyncMessage) {
-	logging.Debugf("Entering: learnedMembers={%v}, deadMembers={%v}", aliveMembers, deadMembers)
-	defer logging.Debug("Exiting")
+	d.logger.Debugf("Entering: learnedMembers={%v}, deadMembers={%v}", aliveMembers, deadMembers)
+	defer d.logger.Debug("Exiting")
 
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -741,12 +901,12 @@ func (d *gossipDiscoveryService) learnNewMembers(aliveMembers []*protos.SignedRK
 		}
 		d.aliveLastTS[common.PKIidType(am.GetAliveMsg().Membership.PkiId).String()] = &timestamp{
 			incTime:  tsToTime(am.GetAliveMsg().Timestamp.IncNum),
-			lastSeen: time.Now(),
+			lastSeen: d.clock.Now(),
 			seqNum:   am.GetAliveMsg().Timestamp.SeqNum,
 		}
 
 		d.aliveMembership.Put(am.GetAliveMsg().Membership.PkiId, &protos.SignedRKSyncMessage{RKSyncMessage: am.RKSyncMessage, Envelope: am.Envelope})
-		logging.Debugf("Learned about a new alive member: %v", am)
+		d.logger.Debugf("Learned about a new alive member: %v", am)
 	}
 
 	for _, dm := range deadMembers {
@@ -755,25 +915,31 @@ func (d *gossipDiscoveryService) learnNewMembers(aliveMembers []*protos.SignedRK
 		}
 		d.deadLastTS[common.PKIidType(dm.GetAliveMsg().Membership.PkiId).String()] = &timestamp{
 			incTime:  tsToTime(dm.GetAliveMsg().Timestamp.IncNum),
-			lastSeen: time.Now(),
+			lastSeen: d.clock.Now(),
 			seqNum:   dm.GetAliveMsg().Timestamp.SeqNum,
 		}
 
 		d.deadMembership.Put(dm.GetAliveMsg().Membership.PkiId, &protos.SignedRKSyncMessage{RKSyncMessage: dm.RKSyncMessage, Envelope: dm.Envelope})
-		logging.Debugf("Learned about a new dead member: %v", dm)
+		d.logger.Debugf("Learned about a new dead member: %v", dm)
 	}
 
 	for _, a := range [][]*protos.SignedRKSyncMessage{aliveMembers, deadMembers} {
 		for _, m := range a {
 			member := m.GetAliveMsg()
 			if member == nil {
-				logging.Warning("Expected alive message, got instead:", m)
+				d.logger.Warning("Expected alive message, got instead:", m)
 				return
 			}
 
 			d.id2Member[common.PKIidType(member.Membership.PkiId).String()] = &common.NetworkMember{
-				Endpoint: member.Membership.Endpoint,
-				PKIID:    member.Membership.PkiId,
+				Endpoint:         member.Membership.Endpoint,
+				InternalEndpoint: m.Envelope.SecretEnvelope.InternalEndpoint(),
+				PKIID:            member.Membership.PkiId,
+				Tags:             member.Membership.Tags,
+				Zone:             member.Membership.Zone,
+				Org:              member.Membership.Org,
+				Labels:           member.Membership.Labels,
+				Leaving:          member.Leaving,
 			}
 		}
 	}
@@ -785,50 +951,60 @@ func (d *gossipDiscoveryService) isSentByMe(m *protos.SignedRKSyncMessage) bool
 		return false
 	}
 
-	logging.Debug("Got alive message about ourselves,", m)
+	d.logger.Debug("Got alive message about ourselves,", m)
 	diffEndpoint := d.self.Endpoint != m.GetAliveMsg().Membership.Endpoint
 	if diffEndpoint {
-		logging.Error("Bad configuration detected: Received AliveMessage from a peer with the same PKI-ID as myself: ", m.RKSyncMessage)
+		d.logger.Error("Bad configuration detected: Received AliveMessage from a peer with the same PKI-ID as myself: ", m.RKSyncMessage)
 	}
 	return true
 }
 
 func (d *gossipDiscoveryService) periodicalReconnectToDead() {
-	defer logging.Debug("Stopped")
+	defer d.logger.Debug("Stopped")
 
+	reconnects := newReconnectManager(d.minReconnectInterval, d.maxReconnectInterval)
 	for !d.toDie() {
 		select {
-		case <-time.After(d.reconnectInterval):
+		case <-d.clock.After(d.minReconnectInterval):
 			wg := sync.WaitGroup{}
-			for _, member := range d.copyLastSeen(d.deadLastTS) {
+			for _, member := range reconnects.due(d.copyLastSeen(d.deadLastTS), d.clock.Now()) {
 				wg.Add(1)
 				go func(member common.NetworkMember) {
 					defer wg.Done()
 					if d.rpc.Ping(&member) {
-						logging.Debug(member, "is responding, sending membership request")
+						d.logger.Debug(member, "is responding, sending membership request")
 						d.sendMembershipRequest(&member)
 					} else {
-						logging.Debug(member, "is still dead")
+						d.logger.Debug(member, "is still dead")
 					}
 				}(member)
 			}
 			wg.Wait()
-		case s := <-d.toDieChan:
-			d.toDieChan <- s
+		case <-d.ctx.Done():
 			return
 		}
 	}
 }
 
+// sleep waits for the given duration, it returns false if the instance is stopped meanwhile
+func (d *gossipDiscoveryService) sleep(duration time.Duration) bool {
+	select {
+	case <-d.clock.After(duration):
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
 func (d *gossipDiscoveryService) sendMembershipRequest(member *common.NetworkMember) {
-	m, err := d.createMembershipRequest()
+	m, err := d.createMembershipRequest(member)
 	if err != nil {
-		logging.Warning("Failed creating membership request: %+v", errors.WithStack(err))
+		d.logger.Warning("Failed creating membership request: %+v", errors.WithStack(err))
 		return
 	}
 	req, err := m.NoopSign()
 	if err != nil {
-		logging.Errorf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
+		d.logger.Errorf("Failed creating SignedRKSyncMessage: %+v", errors.WithStack(err))
 		return
 	}
 	d.rpc.SendToPeer(member, req)
@@ -846,7 +1022,7 @@ func (d *gossipDiscoveryService) copyLastSeen(lastSeenMap map[string]*timestamp)
 }
 
 func (d *gossipDiscoveryService) handlePresumedDeadPeers() {
-	defer logging.Debug("Stopped")
+	defer d.logger.Debug("Stopped")
 
 	for !d.toDie() {
 		select {
@@ -854,8 +1030,7 @@ func (d *gossipDiscoveryService) handlePresumedDeadPeers() {
 			if d.isAlive(deadPeer) {
 				d.expireDeadMembers([]common.PKIidType{deadPeer})
 			}
-		case s := <-d.toDieChan:
-			d.toDieChan <- s
+		case <-d.ctx.Done():
 			return
 		}
 	}
@@ -892,7 +1067,7 @@ func newAliveMsgStore(d *gossipDiscoveryService) *aliveMsgStore {
 	}
 
 	s := &aliveMsgStore{
-		MessageStore: lib.NewMessageStoreExpirable(policy, trigger, aliveMsgTTL, externalLock, externalUnlock, callback),
+		MessageStore: lib.NewMessageStoreExpirable(policy, trigger, aliveMsgTTL, d.clock, externalLock, externalUnlock, callback),
 	}
 
 	return s
@@ -924,6 +1099,11 @@ func same(a *timestamp, b *protos.PeerTime) bool {
 	return uint64(a.incTime.UnixNano()) == b.IncNum && a.seqNum == b.SeqNum
 }
 
+// newer returns whether the peer time a is more recent than b
+func newer(a, b *protos.PeerTime) bool {
+	return a.IncNum > b.IncNum || (a.IncNum == b.IncNum && a.SeqNum > b.SeqNum)
+}
+
 func before(a *timestamp, b *protos.PeerTime) bool {
 	return (uint64(a.incTime.UnixNano()) == b.IncNum && a.seqNum < b.SeqNum) ||
 		uint64(a.incTime.UnixNano()) < b.IncNum
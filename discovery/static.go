@@ -0,0 +1,234 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/lib"
+	"github.com/rkcloudchain/rksync/logging"
+)
+
+// NewStaticDiscovery returns a discovery service whose membership is made of
+// the peers it is asked to connect to. No alive or membership messages are
+// exchanged, the discovery messages received from remote peers are discarded.
+// The liveness of the members is determined by probing them every AliveTimeInterval,
+// a member failing the probes for AliveExpirationTimeout is considered dead until it responds again.
+func NewStaticDiscovery(self common.NetworkMember, rpc RPCService, conf Config, logger logging.Logger) Discovery {
+	conf = conf.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &staticDiscoveryService{
+		self:                   self,
+		rpc:                    rpc,
+		members:                make(map[string]*staticMember),
+		ctx:                    ctx,
+		cancel:                 cancel,
+		aliveTimeInterval:      conf.AliveTimeInterval,
+		aliveExpirationTimeout: conf.AliveExpirationTimeout,
+		minReconnectInterval:   conf.ReconnectInterval,
+		maxReconnectInterval:   conf.MaxReconnectInterval,
+		clock:                  conf.Clock,
+		logger:                 logger,
+	}
+
+	go d.handleMessage()
+	go d.periodicalProbe()
+
+	return d
+}
+
+type staticDiscoveryService struct {
+	self                   common.NetworkMember
+	rpc                    RPCService
+	lock                   sync.RWMutex
+	members                map[string]*staticMember
+	ctx                    context.Context // cancelled by Stop
+	cancel                 context.CancelFunc
+	aliveTimeInterval      time.Duration
+	aliveExpirationTimeout time.Duration
+	minReconnectInterval   time.Duration
+	maxReconnectInterval   time.Duration
+	clock                  lib.Clock
+	logger                 logging.Logger
+}
+
+type staticMember struct {
+	member   *common.NetworkMember
+	lastSeen time.Time
+	dead     bool
+}
+
+func (d *staticDiscoveryService) Connect(member common.NetworkMember, id Identifier) {
+	if member.Endpoint == d.self.Endpoint || (d.self.InternalEndpoint != "" && member.Endpoint == d.self.InternalEndpoint) {
+		d.logger.Debug("Skipping connecting to myself")
+		return
+	}
+
+	go func() {
+		bo := newBackoff(d.minReconnectInterval, d.maxReconnectInterval)
+		for !d.toDie() {
+			pkiID, err := id()
+			if err != nil {
+				if d.toDie() {
+					return
+				}
+				delay := bo.next()
+				d.logger.Warningf("Could not connect to %v, retrying in %s: %v", member, delay, err)
+				select {
+				case <-d.clock.After(delay):
+				case <-d.ctx.Done():
+					return
+				}
+				continue
+			}
+
+			d.lock.Lock()
+			d.members[pkiID.String()] = &staticMember{
+				member:   &common.NetworkMember{Endpoint: member.Endpoint, PKIID: pkiID},
+				lastSeen: d.clock.Now(),
+			}
+			d.lock.Unlock()
+			d.logger.Infof("Added static member %s (%s)", member.Endpoint, pkiID)
+			return
+		}
+	}()
+}
+
+func (d *staticDiscoveryService) Lookup(pkiID common.PKIidType) *common.NetworkMember {
+	if bytes.Equal(pkiID, d.self.PKIID) {
+		return &d.self
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	m, exists := d.members[pkiID.String()]
+	if !exists {
+		return nil
+	}
+	return copyNetworkMember(m.member)
+}
+
+func (d *staticDiscoveryService) InitiateSync(peerNum int) {}
+
+func (d *staticDiscoveryService) GetMembership() []common.NetworkMember {
+	if d.toDie() {
+		return []common.NetworkMember{}
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	response := []common.NetworkMember{}
+	for _, m := range d.members {
+		if !m.dead {
+			response = append(response, *m.member)
+		}
+	}
+	return response
+}
+
+// Leave does nothing, the static members don't exchange alive messages
+func (d *staticDiscoveryService) Leave() {}
+
+func (d *staticDiscoveryService) Stop() {
+	defer d.logger.Info("Stopped discovery")
+	d.logger.Info("Stopping discovery")
+
+	d.cancel()
+}
+
+func (d *staticDiscoveryService) toDie() bool {
+	return d.ctx.Err() != nil
+}
+
+// handleMessage drains the messages and dead peer notifications
+// the RPC layer hands over to the discovery. A member presumed dead
+// is probed right away, it's only expired if it doesn't respond.
+func (d *staticDiscoveryService) handleMessage() {
+	defer d.logger.Debug("Stopped")
+
+	in := d.rpc.Accept()
+	dead := d.rpc.PresumedDead()
+	for {
+		select {
+		case msg := <-in:
+			d.logger.Debug("Discarding discovery message", msg.GetRKSyncMessage(), "in static topology")
+		case deadPeer := <-dead:
+			d.logger.Debugf("Static member %s is presumed dead", deadPeer)
+			d.lock.RLock()
+			m, exists := d.members[deadPeer.String()]
+			d.lock.RUnlock()
+			if exists {
+				go d.probe(m)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *staticDiscoveryService) periodicalProbe() {
+	defer d.logger.Debug("Stopped")
+
+	for !d.toDie() {
+		select {
+		case <-d.clock.After(d.aliveTimeInterval):
+		case <-d.ctx.Done():
+			return
+		}
+
+		d.lock.RLock()
+		members := make([]*staticMember, 0, len(d.members))
+		for _, m := range d.members {
+			members = append(members, m)
+		}
+		d.lock.RUnlock()
+
+		var wg sync.WaitGroup
+		for _, m := range members {
+			wg.Add(1)
+			go func(m *staticMember) {
+				defer wg.Done()
+				d.probe(m)
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// probe pings the member, which is expired once it didn't
+// respond for aliveExpirationTimeout and revived when it responds again
+func (d *staticDiscoveryService) probe(m *staticMember) {
+	if d.toDie() {
+		return
+	}
+	alive := d.rpc.Ping(m.member)
+
+	d.lock.Lock()
+	if alive {
+		m.lastSeen = d.clock.Now()
+		if m.dead {
+			m.dead = false
+			d.logger.Infof("Static member %s is alive again", m.member.Endpoint)
+		}
+		d.lock.Unlock()
+		return
+	}
+	if m.dead || d.clock.Since(m.lastSeen) < d.aliveExpirationTimeout {
+		d.lock.Unlock()
+		return
+	}
+	m.dead = true
+	member := *m.member
+	d.lock.Unlock()
+
+	d.logger.Warningf("Static member %s didn't respond for %s, considering it dead", member.Endpoint, d.aliveExpirationTimeout)
+	d.rpc.CloseConn(&member)
+	d.rpc.MemberDead(member)
+}
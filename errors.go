@@ -0,0 +1,145 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rksync
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/gossip"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCode classifies the errors returned by the rksync service, so that callers can
+// decide whether to retry the operation, to surface the error to users or to alert
+type ErrCode int
+
+// Codes of the errors returned by the rksync service
+const (
+	CodeOK           ErrCode = iota // No error
+	CodeInternal                    // Unexpected failure of the service, e.g. while writing the state of a channel
+	CodeValidation                  // Invalid arguments or configuration, e.g. a channel which doesn't exist
+	CodeUnauthorized                // Operation the peer isn't allowed to do, e.g. modifying a channel it doesn't lead
+	CodeUnavailable                 // Transient failure, e.g. of the network or while the service is stopping
+)
+
+func (c ErrCode) String() string {
+	switch c {
+	case CodeOK:
+		return "ok"
+	case CodeInternal:
+		return "internal"
+	case CodeValidation:
+		return "validation"
+	case CodeUnauthorized:
+		return "unauthorized"
+	case CodeUnavailable:
+		return "unavailable"
+	}
+	return fmt.Sprintf("ErrCode(%d)", int(c))
+}
+
+// Retryable returns whether the operation may succeed if it's retried later
+func (c ErrCode) Retryable() bool {
+	return c == CodeUnavailable
+}
+
+// Error is an error of the rksync service along with its code
+type Error struct {
+	Code ErrCode
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error, errors.Cause(err) can still be compared
+// with the errors of the gossip package, e.g. gossip.ErrChannelNotExist
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus converts the error to a gRPC status, so that the clients of the admin service receive its code
+func (e *Error) GRPCStatus() *status.Status {
+	code := codes.Internal
+	switch e.Code {
+	case CodeValidation:
+		code = codes.InvalidArgument
+	case CodeUnauthorized:
+		code = codes.PermissionDenied
+	case CodeUnavailable:
+		code = codes.Unavailable
+	}
+	return status.New(code, e.Error())
+}
+
+// Code returns the code of the error, CodeInternal if it wasn't classified and CodeOK if it's nil
+func Code(err error) ErrCode {
+	if err == nil {
+		return CodeOK
+	}
+	if coded := codedError(err); coded != nil {
+		return coded.Code
+	}
+	return CodeInternal
+}
+
+// codedError returns the first Error in the chain of causes of err
+func codedError(err error) *Error {
+	for err != nil {
+		if coded, ok := err.(*Error); ok {
+			return coded
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil
+		}
+		err = cause.Cause()
+	}
+	return nil
+}
+
+func newError(code ErrCode, err error) error {
+	return &Error{Code: code, Err: err}
+}
+
+func validationErrorf(format string, args ...interface{}) error {
+	return newError(CodeValidation, errors.Errorf(format, args...))
+}
+
+// classify sets the code of an error returned by the gossip service according to its cause
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if codedError(err) != nil {
+		return err
+	}
+
+	cause := errors.Cause(err)
+	switch cause {
+	case gossip.ErrChannelExists, gossip.ErrChannelNotExist, gossip.ErrNotMember, gossip.ErrInvalidSnapshot, gossip.ErrNoInvitation:
+		return newError(CodeValidation, err)
+	case gossip.ErrNotLeader:
+		return newError(CodeUnauthorized, err)
+	case gossip.ErrStopping, gossip.ErrDraining, gossip.ErrPeerNotAlive, context.Canceled, context.DeadlineExceeded:
+		return newError(CodeUnavailable, err)
+	}
+	if _, isNetErr := cause.(net.Error); isNetErr {
+		return newError(CodeUnavailable, err)
+	}
+	return newError(CodeInternal, err)
+}
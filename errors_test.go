@@ -0,0 +1,74 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rksync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/gossip"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCodes(t *testing.T) {
+	assert.Equal(t, CodeOK, Code(nil))
+	assert.Equal(t, CodeInternal, Code(errors.New("failure")))
+	assert.Nil(t, classify(nil))
+
+	err := classify(errors.Wrapf(gossip.ErrChannelNotExist, "Channel %s", "testchannel"))
+	assert.Equal(t, CodeValidation, Code(err))
+	assert.Equal(t, gossip.ErrChannelNotExist, errors.Cause(err))
+	assert.Equal(t, "Channel testchannel: channel does not exist", err.Error())
+	assert.Equal(t, CodeUnauthorized, Code(classify(errors.WithMessage(gossip.ErrNotLeader, "Only the channel leader can close the channel"))))
+	assert.Equal(t, CodeUnavailable, Code(classify(gossip.ErrStopping)))
+	assert.Equal(t, CodeUnavailable, Code(classify(context.DeadlineExceeded)))
+	assert.True(t, Code(classify(gossip.ErrDraining)).Retryable())
+	assert.False(t, Code(classify(gossip.ErrChannelExists)).Retryable())
+
+	// The code of an error is kept when it's wrapped or classified again
+	err = errors.WithMessage(validationErrorf("Channel ID must be provided"), "Failed creating channel")
+	assert.Equal(t, CodeValidation, Code(err))
+	assert.Equal(t, err, classify(err))
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(validationErrorf("Channel ID must be provided")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(classify(gossip.ErrNotLeader)))
+	assert.Equal(t, codes.Internal, status.Code(classify(errors.New("failure"))))
+	assert.Equal(t, "unavailable", CodeUnavailable.String())
+	assert.Equal(t, "ErrCode(42)", ErrCode(42).String())
+}
+
+func TestServerValidationErrors(t *testing.T) {
+	srv := &Server{}
+	assert.Equal(t, CodeValidation, Code(srv.CloseChannel("")))
+	assert.Equal(t, CodeValidation, Code(srv.AddMemberToChan("testchannel", "", nil)))
+	assert.Equal(t, CodeValidation, Code(srv.AddFileToChan("testchannel", nil)))
+	assert.Equal(t, CodeValidation, Code(srv.RemoveFileWithChan("testchannel", nil)))
+	assert.Equal(t, CodeValidation, Code(srv.SetChannelPropagation("testchannel", config.Propagation{PeerNum: -1})))
+
+	_, err := Serve(nil, &config.Config{})
+	assert.Equal(t, CodeValidation, Code(err))
+
+	// No PKCS#11 signer factory is registered
+	_, err = Serve(nil, &config.Config{
+		Gossip: &config.GossipConfig{
+			FileSystem:     mocks.NewFSMock("peer0"),
+			BootstrapPeers: []string{"localhost:8053"},
+			Endpoint:       "localhost:8053",
+		},
+		Identity: &config.IdentityConfig{
+			ID:     "peer0.org1",
+			PKCS11: &config.PKCS11Config{Library: "/usr/lib/softhsm/libsofthsm2.so", Label: "peer0"},
+		},
+	})
+	assert.Equal(t, CodeValidation, Code(err))
+	assert.Contains(t, err.Error(), "No PKCS#11 signer factory registered")
+}
@@ -7,7 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package filter
 
 import (
+	"math"
 	"math/rand"
+	"sort"
+	"time"
 
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/util"
@@ -23,6 +26,32 @@ var SelectAllPolicy = func(common.NetworkMember) bool {
 	return true
 }
 
+// SelectByTag returns a routing filter that selects members
+// which have been assigned the given tag, e.g: "region=eu".
+// The tags are advertised by the members themselves and aren't verified.
+func SelectByTag(tag string) RoutingFilter {
+	return func(member common.NetworkMember) bool {
+		return member.HasTag(tag)
+	}
+}
+
+// SelectByOrg returns a routing filter that selects members
+// of the given organization
+func SelectByOrg(org string) RoutingFilter {
+	return func(member common.NetworkMember) bool {
+		return member.Org == org
+	}
+}
+
+// SelectByLabel returns a routing filter that selects members
+// which have been attached the given label value, e.g: "role", "ingest"
+func SelectByLabel(key, value string) RoutingFilter {
+	return func(member common.NetworkMember) bool {
+		v, exists := member.Labels[key]
+		return exists && v == value
+	}
+}
+
 // CombineRoutingFilters returns the logical AND of given routing filters
 func CombineRoutingFilters(filters ...RoutingFilter) RoutingFilter {
 	return func(member common.NetworkMember) bool {
@@ -35,9 +64,12 @@ func CombineRoutingFilters(filters ...RoutingFilter) RoutingFilter {
 	}
 }
 
-// SelectPeers returns a slice of peers that match the routing filter
+// SelectPeers returns a slice of peers that match the routing filter.
+// Peers which announced they are leaving and distrusted peers are only selected
+// when there aren't enough other peers, unreachable peers are never selected.
 func SelectPeers(k int, peerPool []common.NetworkMember, filter RoutingFilter) []*common.NetworkMember {
 	var res []*common.NetworkMember
+	var leaving []*common.NetworkMember
 	rand.Seed(int64(util.RandomUInt64()))
 
 	for _, index := range rand.Perm(len(peerPool)) {
@@ -46,15 +78,103 @@ func SelectPeers(k int, peerPool []common.NetworkMember, filter RoutingFilter) [
 		}
 		peer := peerPool[index]
 
-		if !filter(peer) {
+		if peer.Unreachable || !filter(peer) {
 			continue
 		}
-		p := &common.NetworkMember{PKIID: peer.PKIID, Endpoint: peer.Endpoint}
+		p := peer
+		if p.Leaving || p.Distrusted {
+			leaving = append(leaving, &p)
+			continue
+		}
+		res = append(res, &p)
+	}
+
+	for _, p := range leaving {
+		if len(res) == k {
+			break
+		}
 		res = append(res, p)
 	}
 	return res
 }
 
+// SelectPeersByLatency returns a slice of peers that match the routing filter,
+// like SelectPeers, but the peers are drawn with a probability inversely
+// proportional to their latency. Fast peers are preferred while slow peers
+// are still selected from time to time, so that their latency keeps being measured.
+// Peers whose latency wasn't measured yet are weighted like an average peer.
+func SelectPeersByLatency(k int, peerPool []common.NetworkMember, filter RoutingFilter) []*common.NetworkMember {
+	var candidates []common.NetworkMember
+	var leaving []common.NetworkMember
+	for _, peer := range peerPool {
+		if peer.Unreachable || !filter(peer) {
+			continue
+		}
+		if peer.Leaving || peer.Distrusted {
+			leaving = append(leaving, peer)
+			continue
+		}
+		candidates = append(candidates, peer)
+	}
+
+	res := drawByLatency(k, candidates)
+	if len(res) < k {
+		res = append(res, drawByLatency(k-len(res), leaving)...)
+	}
+	return res
+}
+
+// drawByLatency draws at most k of the peers without replacement, the probability
+// of a peer to be drawn being proportional to the inverse of its latency
+func drawByLatency(k int, peers []common.NetworkMember) []*common.NetworkMember {
+	if k <= 0 || len(peers) == 0 {
+		return nil
+	}
+
+	var total float64
+	var measured int
+	for _, peer := range peers {
+		if peer.Latency > 0 {
+			total += latencyWeight(peer.Latency)
+			measured++
+		}
+	}
+	unmeasuredWeight := 1.0
+	if measured > 0 {
+		unmeasuredWeight = total / float64(measured)
+	}
+
+	// each peer is given the key u^(1/weight), u drawn uniformly in (0, 1),
+	// and the peers with the largest keys are selected
+	type drawnPeer struct {
+		peer common.NetworkMember
+		key  float64
+	}
+	r := rand.New(rand.NewSource(int64(util.RandomUInt64())))
+	drawn := make([]drawnPeer, len(peers))
+	for i, peer := range peers {
+		weight := unmeasuredWeight
+		if peer.Latency > 0 {
+			weight = latencyWeight(peer.Latency)
+		}
+		drawn[i] = drawnPeer{peer: peer, key: math.Pow(1-r.Float64(), 1/weight)}
+	}
+	sort.Slice(drawn, func(i, j int) bool {
+		return drawn[i].key > drawn[j].key
+	})
+
+	var res []*common.NetworkMember
+	for i := 0; i < k && i < len(drawn); i++ {
+		p := drawn[i].peer
+		res = append(res, &p)
+	}
+	return res
+}
+
+func latencyWeight(latency time.Duration) float64 {
+	return float64(time.Millisecond) / float64(latency)
+}
+
 // SelectAllPeers returns all peers match the routing filter
 func SelectAllPeers(peerPool []common.NetworkMember, filter RoutingFilter) []*common.NetworkMember {
 	var res []*common.NetworkMember
@@ -64,9 +184,67 @@ func SelectAllPeers(peerPool []common.NetworkMember, filter RoutingFilter) []*co
 			continue
 		}
 
-		p := &common.NetworkMember{PKIID: peer.PKIID, Endpoint: peer.Endpoint}
-		res = append(res, p)
+		p := peer
+		res = append(res, &p)
 	}
 
 	return res
 }
+
+// SelectPeersInZone returns at most k peers that match the routing filter,
+// preferring the peers of the given zone. Peers of other zones are
+// only selected when the zone doesn't have enough peers.
+func SelectPeersInZone(k int, peerPool []common.NetworkMember, filter RoutingFilter, zone string) []*common.NetworkMember {
+	return selectPeersInZone(k, peerPool, filter, zone, SelectPeers)
+}
+
+// SelectPeersInZoneByLatency is like SelectPeersInZone, but the peers
+// are drawn according to their latency like SelectPeersByLatency.
+// It suits the messages carrying large amounts of data.
+func SelectPeersInZoneByLatency(k int, peerPool []common.NetworkMember, filter RoutingFilter, zone string) []*common.NetworkMember {
+	return selectPeersInZone(k, peerPool, filter, zone, SelectPeersByLatency)
+}
+
+func selectPeersInZone(k int, peerPool []common.NetworkMember, filter RoutingFilter, zone string,
+	selectPeers func(int, []common.NetworkMember, RoutingFilter) []*common.NetworkMember) []*common.NetworkMember {
+	if k <= 0 {
+		return nil
+	}
+
+	res := selectPeers(k, peerPool, CombineRoutingFilters(filter, selectInZone(zone)))
+	if len(res) == k {
+		return res
+	}
+	return append(res, selectPeers(k-len(res), peerPool, CombineRoutingFilters(filter, selectOutOfZone(zone)))...)
+}
+
+// SelectPeersAcrossZones returns at most k peers that match the routing filter.
+// Unlike SelectPeers, at least one of them is outside the given zone
+// whenever there is such a peer, so that messages keep crossing zones.
+func SelectPeersAcrossZones(k int, peerPool []common.NetworkMember, filter RoutingFilter, zone string) []*common.NetworkMember {
+	if k <= 0 {
+		return nil
+	}
+
+	res := SelectPeers(1, peerPool, CombineRoutingFilters(filter, selectOutOfZone(zone)))
+	if len(res) == 0 {
+		return SelectPeers(k, peerPool, filter)
+	}
+
+	selected := res[0].PKIID
+	return append(res, SelectPeers(k-1, peerPool, CombineRoutingFilters(filter, func(member common.NetworkMember) bool {
+		return selected.IsNotSameFilter(member.PKIID)
+	}))...)
+}
+
+func selectInZone(zone string) RoutingFilter {
+	return func(member common.NetworkMember) bool {
+		return member.Zone == zone
+	}
+}
+
+func selectOutOfZone(zone string) RoutingFilter {
+	return func(member common.NetworkMember) bool {
+		return member.Zone != zone
+	}
+}
@@ -8,6 +8,7 @@ package filter
 
 import (
 	"testing"
+	"time"
 
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/stretchr/testify/assert"
@@ -71,3 +72,180 @@ func TestSelectAllPeers(t *testing.T) {
 
 	assert.Len(t, SelectAllPeers(peers, filter), 4)
 }
+
+func TestSelectByTag(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", Tags: []string{"region=eu", "role=edge"}}
+	b := common.NetworkMember{Endpoint: "b", Tags: []string{"region=us"}}
+	c := common.NetworkMember{Endpoint: "c"}
+
+	peers := []common.NetworkMember{a, b, c}
+
+	selected := SelectAllPeers(peers, SelectByTag("region=eu"))
+	assert.Len(t, selected, 1)
+	assert.Equal(t, "a", selected[0].Endpoint)
+	assert.Equal(t, a.Tags, selected[0].Tags)
+
+	assert.Len(t, SelectAllPeers(peers, SelectByTag("role=core")), 0)
+	assert.Len(t, SelectPeers(3, peers, CombineRoutingFilters(SelectByTag("role=edge"), SelectByTag("region=eu"))), 1)
+}
+
+func TestSelectByOrg(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", Org: "org1"}
+	b := common.NetworkMember{Endpoint: "b", Org: "org2"}
+	c := common.NetworkMember{Endpoint: "c"}
+
+	peers := []common.NetworkMember{a, b, c}
+
+	selected := SelectAllPeers(peers, SelectByOrg("org1"))
+	assert.Len(t, selected, 1)
+	assert.Equal(t, "a", selected[0].Endpoint)
+
+	assert.Len(t, SelectAllPeers(peers, SelectByOrg("org3")), 0)
+	assert.Len(t, SelectAllPeers(peers, SelectByOrg("")), 1)
+}
+
+func TestSelectByLabel(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", Labels: map[string]string{"role": "ingest", "version": "1.4.2"}}
+	b := common.NetworkMember{Endpoint: "b", Labels: map[string]string{"role": ""}}
+	c := common.NetworkMember{Endpoint: "c"}
+
+	peers := []common.NetworkMember{a, b, c}
+
+	selected := SelectAllPeers(peers, SelectByLabel("role", "ingest"))
+	assert.Len(t, selected, 1)
+	assert.Equal(t, "a", selected[0].Endpoint)
+
+	assert.Len(t, SelectAllPeers(peers, SelectByLabel("role", "")), 1)
+	assert.Len(t, SelectAllPeers(peers, SelectByLabel("zone", "")), 0)
+}
+
+func TestSelectPeersInZone(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", PKIID: common.PKIidType("a"), Zone: "eu"}
+	b := common.NetworkMember{Endpoint: "b", PKIID: common.PKIidType("b"), Zone: "eu"}
+	c := common.NetworkMember{Endpoint: "c", PKIID: common.PKIidType("c"), Zone: "us"}
+	d := common.NetworkMember{Endpoint: "d", PKIID: common.PKIidType("d"), InternalEndpoint: "10.0.0.1:9053"}
+	peers := []common.NetworkMember{a, b, c, d}
+
+	for i := 0; i < 10; i++ {
+		res := SelectPeersInZone(1, peers, SelectAllPolicy, "us")
+		assert.Len(t, res, 1)
+		assert.Equal(t, "c", res[0].Endpoint)
+
+		res = SelectPeersInZone(2, peers, SelectAllPolicy, "eu")
+		assert.Len(t, res, 2)
+		for _, p := range res {
+			assert.Equal(t, "eu", p.Zone)
+		}
+	}
+
+	assert.Len(t, SelectPeersInZone(3, peers, SelectAllPolicy, "us"), 3)
+	assert.Empty(t, SelectPeersInZone(0, peers, SelectAllPolicy, "us"))
+
+	res := SelectPeersInZone(1, peers, SelectAllPolicy, "")
+	assert.Equal(t, "10.0.0.1:9053", res[0].InternalEndpoint)
+}
+
+func TestSelectPeersAcrossZones(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", PKIID: common.PKIidType("a"), Zone: "eu"}
+	b := common.NetworkMember{Endpoint: "b", PKIID: common.PKIidType("b"), Zone: "eu"}
+	c := common.NetworkMember{Endpoint: "c", PKIID: common.PKIidType("c"), Zone: "eu"}
+	d := common.NetworkMember{Endpoint: "d", PKIID: common.PKIidType("d"), Zone: "us"}
+	peers := []common.NetworkMember{a, b, c, d}
+
+	for i := 0; i < 10; i++ {
+		res := SelectPeersAcrossZones(2, peers, SelectAllPolicy, "eu")
+		assert.Len(t, res, 2)
+		assert.Equal(t, "d", res[0].Endpoint)
+		assert.Equal(t, "eu", res[1].Zone)
+	}
+
+	assert.Len(t, SelectPeersAcrossZones(4, peers, SelectAllPolicy, "eu"), 4)
+	assert.Len(t, SelectPeersAcrossZones(2, []common.NetworkMember{a, b, c}, SelectAllPolicy, "eu"), 2)
+	assert.Empty(t, SelectPeersAcrossZones(0, peers, SelectAllPolicy, "eu"))
+}
+
+func TestSelectPeersSkipsLeavingPeers(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", PKIID: common.PKIidType("a"), Leaving: true}
+	b := common.NetworkMember{Endpoint: "b", PKIID: common.PKIidType("b")}
+	c := common.NetworkMember{Endpoint: "c", PKIID: common.PKIidType("c"), Leaving: true}
+	peers := []common.NetworkMember{a, b, c}
+
+	for i := 0; i < 10; i++ {
+		res := SelectPeers(1, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "b", res[0].Endpoint)
+
+		res = SelectPeers(2, peers, SelectAllPolicy)
+		assert.Len(t, res, 2)
+		assert.Equal(t, "b", res[0].Endpoint)
+		assert.True(t, res[1].Leaving)
+	}
+
+	assert.Len(t, SelectPeers(3, peers, SelectAllPolicy), 3)
+}
+
+func TestSelectPeersDeprioritizesDistrustedPeers(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", PKIID: common.PKIidType("a"), Distrusted: true, Latency: time.Microsecond}
+	b := common.NetworkMember{Endpoint: "b", PKIID: common.PKIidType("b"), Latency: time.Second}
+	peers := []common.NetworkMember{a, b}
+
+	for i := 0; i < 10; i++ {
+		res := SelectPeers(1, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "b", res[0].Endpoint)
+
+		res = SelectPeersByLatency(1, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "b", res[0].Endpoint)
+	}
+
+	assert.Len(t, SelectPeers(2, peers, SelectAllPolicy), 2)
+	assert.Len(t, SelectPeersByLatency(2, peers, SelectAllPolicy), 2)
+}
+
+func TestSelectPeersSkipsUnreachablePeers(t *testing.T) {
+	a := common.NetworkMember{Endpoint: "a", PKIID: common.PKIidType("a"), Unreachable: true}
+	b := common.NetworkMember{Endpoint: "b", PKIID: common.PKIidType("b")}
+	c := common.NetworkMember{Endpoint: "c", PKIID: common.PKIidType("c"), Leaving: true, Unreachable: true}
+	peers := []common.NetworkMember{a, b, c}
+
+	for i := 0; i < 10; i++ {
+		res := SelectPeers(3, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "b", res[0].Endpoint)
+	}
+	assert.Len(t, SelectAllPeers(peers, SelectAllPolicy), 3)
+}
+
+func TestSelectPeersByLatency(t *testing.T) {
+	fast := common.NetworkMember{Endpoint: "fast", PKIID: common.PKIidType("fast"), Latency: time.Millisecond}
+	slow := common.NetworkMember{Endpoint: "slow", PKIID: common.PKIidType("slow"), Latency: 100 * time.Millisecond}
+	unknown := common.NetworkMember{Endpoint: "unknown", PKIID: common.PKIidType("unknown")}
+	peers := []common.NetworkMember{fast, slow, unknown}
+
+	counts := make(map[string]int)
+	for i := 0; i < 5000; i++ {
+		res := SelectPeersByLatency(1, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		counts[res[0].Endpoint]++
+	}
+	assert.True(t, counts["fast"] > counts["unknown"], "fast peer should be preferred: %v", counts)
+	assert.True(t, counts["unknown"] > counts["slow"], "unmeasured peer should be weighted like an average peer: %v", counts)
+	assert.True(t, counts["slow"] > 0, "slow peer should still be sampled: %v", counts)
+
+	assert.Len(t, SelectPeersByLatency(5, peers, SelectAllPolicy), 3)
+
+	leaving := common.NetworkMember{Endpoint: "leaving", PKIID: common.PKIidType("leaving"), Leaving: true, Latency: time.Microsecond}
+	unreachable := common.NetworkMember{Endpoint: "unreachable", PKIID: common.PKIidType("unreachable"), Unreachable: true}
+	peers = []common.NetworkMember{slow, leaving, unreachable}
+	for i := 0; i < 10; i++ {
+		res := SelectPeersByLatency(1, peers, SelectAllPolicy)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "slow", res[0].Endpoint)
+		assert.Len(t, SelectPeersByLatency(3, peers, SelectAllPolicy), 2)
+	}
+
+	res := SelectPeersInZoneByLatency(1, []common.NetworkMember{fast, {Endpoint: "zoned", Zone: "z1", Latency: time.Second}}, SelectAllPolicy, "z1")
+	assert.Len(t, res, 1)
+	assert.Equal(t, "zoned", res[0].Endpoint)
+}
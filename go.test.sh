@@ -14,4 +14,6 @@ for d in $(go list ./... | grep -v vendor); do
         cat profile.out >> coverage.txt
         rm profile.out
     fi
-done
\ No newline at end of file
+done
+# The PKCS#11 signer is only built with the pkcs11 tag
+go test -v -race -tags pkcs11 ./identity
@@ -0,0 +1,158 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// ChannelMessageType identifies a kind of message of a channel
+type ChannelMessageType int
+
+// Kinds of messages of a channel which can be accepted
+const (
+	ChainStateMessage ChannelMessageType = iota + 1 // A chain state applied by the peer
+	DataMessage                                     // A chunk of a file sent by a peer
+	DataRequest                                     // A request for the data of a file
+)
+
+func (t ChannelMessageType) String() string {
+	switch t {
+	case ChainStateMessage:
+		return "chain state"
+	case DataMessage:
+		return "data message"
+	case DataRequest:
+		return "data request"
+	}
+	return fmt.Sprintf("ChannelMessageType(%d)", int(t))
+}
+
+// ChannelMessage is a message received in a channel, decoded according to its type
+type ChannelMessage struct {
+	Type     ChannelMessageType
+	ChainID  string
+	ChainMac common.ChainMac
+	State    *protos.ChainStateInfo // Set for a ChainStateMessage
+	Data     *protos.DataMessage    // Set for a DataMessage
+	Request  *protos.DataRequest    // Set for a DataRequest
+	Message  *protos.RKSyncMessage  // The message as received
+}
+
+func channelMessageType(msg *protos.RKSyncMessage) ChannelMessageType {
+	switch {
+	case msg.IsChainStateMsg():
+		return ChainStateMessage
+	case msg.IsDataMsg():
+		return DataMessage
+	case msg.IsDataReq():
+		return DataRequest
+	}
+	return 0
+}
+
+// decodeChannelMessage wraps a message of the channel with its decoded content
+func decodeChannelMessage(chainID string, msg *protos.RKSyncMessage) (*ChannelMessage, error) {
+	cm := &ChannelMessage{
+		Type:     channelMessageType(msg),
+		ChainID:  chainID,
+		ChainMac: msg.ChainMac,
+		Message:  msg,
+	}
+	switch cm.Type {
+	case ChainStateMessage:
+		info, err := msg.GetState().GetChainStateInfo()
+		if err != nil {
+			return nil, err
+		}
+		cm.State = info
+	case DataMessage:
+		cm.Data = msg.GetDataMsg()
+	case DataRequest:
+		cm.Request = msg.GetDataReq()
+	default:
+		return nil, errors.Errorf("Message of channel %s has an unknown type", chainID)
+	}
+	return cm, nil
+}
+
+// AcceptChannelMessages subscribes to the messages of the given types received in the channel,
+// all of them if no type is given. Messages are dropped while the subscriber's channel is full.
+func (g *gossipService) AcceptChannelMessages(chainID string, types ...ChannelMessageType) (<-chan *ChannelMessage, func(), error) {
+	accepted := make(map[ChannelMessageType]struct{}, len(types))
+	for _, t := range types {
+		if t < ChainStateMessage || t > DataRequest {
+			return nil, nil, errors.Errorf("Unknown message type %d", int(t))
+		}
+		accepted[t] = struct{}{}
+	}
+
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := gc.Self().GetChainStateInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	chainMac := channel.GenerateMAC(info.Leader, chainID)
+
+	acceptor := func(o interface{}) bool {
+		msg, isSignedMsg := o.(*protos.SignedRKSyncMessage)
+		if !isSignedMsg || !bytes.Equal(msg.ChainMac, chainMac) {
+			return false
+		}
+		t := channelMessageType(msg.RKSyncMessage)
+		if t == 0 {
+			return false
+		}
+		if len(accepted) == 0 {
+			return true
+		}
+		_, exists := accepted[t]
+		return exists
+	}
+
+	// The demultiplexer is shared with the file synchronization, the subscriber must never block it
+	inCh, unsubscribe := g.Subscribe(acceptor, nil, acceptChanSize, common.DropNewest)
+	if inCh == nil {
+		return nil, nil, ErrStopping
+	}
+
+	outCh := make(chan *ChannelMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(outCh)
+		for m := range inCh {
+			cm, err := decodeChannelMessage(chainID, m.(*protos.SignedRKSyncMessage).RKSyncMessage)
+			if err != nil {
+				g.logger.Warningf("Failed decoding message of channel %s: %s", chainID, err)
+				continue
+			}
+			select {
+			case outCh <- cm:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return outCh, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptChannelMessages(t *testing.T) {
+	gossipSvc, err := CreateGossipServer([]string{"localhost:12073"}, "localhost:12073", 0)
+	require.NoError(t, err)
+	defer gossipSvc.Stop()
+
+	mac := channel.GenerateMAC(gossipSvc.SelfPKIid(), "testchannel")
+	_, err = gossipSvc.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+
+	_, _, err = gossipSvc.AcceptChannelMessages("nochannel")
+	assert.Error(t, err)
+	_, _, err = gossipSvc.AcceptChannelMessages("testchannel", ChannelMessageType(42))
+	assert.Error(t, err)
+
+	requests, cancel, err := gossipSvc.AcceptChannelMessages("testchannel", DataRequest)
+	require.NoError(t, err)
+	all, cancelAll, err := gossipSvc.AcceptChannelMessages("testchannel")
+	require.NoError(t, err)
+	defer cancelAll()
+
+	signed := func(chainMac common.ChainMac, msg *protos.RKSyncMessage) *protos.SignedRKSyncMessage {
+		msg.Tag, msg.ChainMac = protos.RKSyncMessage_CHAN_ONLY, chainMac
+		sMsg, err := msg.NoopSign()
+		require.NoError(t, err)
+		return sMsg
+	}
+	demux := gossipSvc.(*gossipService).DeMultiplex
+	demux(signed(channel.GenerateMAC(gossipSvc.SelfPKIid(), "otherchannel"), &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataReq{DataReq: &protos.DataRequest{FileName: "other"}}}))
+	demux(signed(mac, &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{FileName: "data", Payload: &protos.Payload{Data: []byte("data")}}}}))
+	demux(signed(mac, &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataReq{DataReq: &protos.DataRequest{FileName: "request"}}}))
+
+	select {
+	case msg := <-requests:
+		assert.Equal(t, DataRequest, msg.Type)
+		assert.Equal(t, "testchannel", msg.ChainID)
+		assert.Equal(t, mac, msg.ChainMac)
+		assert.Equal(t, "request", msg.Request.FileName)
+		assert.Nil(t, msg.Data)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Didn't receive the data request")
+	}
+
+	for _, expected := range []ChannelMessageType{DataMessage, DataRequest} {
+		select {
+		case msg := <-all:
+			assert.Equal(t, expected, msg.Type)
+			if expected == DataMessage {
+				assert.Equal(t, []byte("data"), msg.Data.Payload.Data)
+			}
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "Didn't receive the message", "%s", expected)
+		}
+	}
+
+	cancel()
+	cancel()
+	demux(signed(mac, &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataReq{DataReq: &protos.DataRequest{FileName: "request"}}}))
+	for msg := range requests {
+		assert.Fail(t, "Message received after the subscription was cancelled", "%v", msg)
+	}
+}
+
+func TestDecodeChannelMessage(t *testing.T) {
+	_, err := decodeChannelMessage("testchannel", &protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}})
+	assert.Error(t, err)
+
+	_, err = decodeChannelMessage("testchannel", &protos.RKSyncMessage{Content: &protos.RKSyncMessage_State{State: &protos.ChainState{Envelope: &protos.Envelope{Payload: []byte("garbage")}}}})
+	assert.Error(t, err)
+
+	msg, err := decodeChannelMessage("testchannel", &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{FileName: "file"}}})
+	require.NoError(t, err)
+	assert.Equal(t, DataMessage, msg.Type)
+	assert.Equal(t, "file", msg.Data.FileName)
+	assert.Equal(t, "data message", msg.Type.String())
+}
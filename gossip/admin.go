@@ -0,0 +1,283 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ChannelAdmin performs the channel operations requested through the admin service
+type ChannelAdmin interface {
+	CreateChannel(chainID string, files []*common.FileSyncInfo) error
+	AddMemberToChan(chainID string, nodeID string, cert *x509.Certificate) error
+	AddFileToChan(chainID string, files []*common.FileSyncInfo) error
+	RemoveFileWithChan(chainID string, filenames []string) error
+}
+
+// adminServer implements the admin service on top of the gossip component
+type adminServer struct {
+	g        *gossipService
+	channels ChannelAdmin
+}
+
+func (s *adminServer) ListChannels(context.Context, *emptypb.Empty) (*protos.ChannelList, error) {
+	list := &protos.ChannelList{}
+	for mac, gc := range s.g.chanState.snapshot() {
+		// The channels still loading their state are listed without it
+		if gc.Self() == nil {
+			list.Channels = append(list.Channels, &protos.ChannelSummary{ChainMac: mac, Loading: true})
+			continue
+		}
+		summary, _, err := s.channelSummary(mac, gc)
+		if err != nil {
+			s.g.logger.Warningf("Skipping channel: %s", err)
+			continue
+		}
+		list.Channels = append(list.Channels, summary)
+	}
+	return list, nil
+}
+
+func (s *adminServer) ChannelInfo(ctx context.Context, req *protos.ChannelRequest) (*protos.ChannelInfoResponse, error) {
+	gc, err := s.lookupChannel(req.ChainMac)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, stateInfo, err := s.channelSummary(req.ChainMac, gc)
+	if err != nil {
+		return nil, err
+	}
+	resp := &protos.ChannelInfoResponse{
+		Summary:     summary,
+		Files:       stateInfo.Properties.Files,
+		AnchorPeers: stateInfo.AnchorPeers,
+	}
+	for _, member := range stateInfo.Properties.Members {
+		resp.Members = append(resp.Members, hex.EncodeToString(member))
+	}
+	return resp, nil
+}
+
+func (s *adminServer) ListPeers(context.Context, *emptypb.Empty) (*protos.PeerList, error) {
+	list := &protos.PeerList{}
+	for _, member := range s.g.Peers() {
+		list.Peers = append(list.Peers, &protos.PeerInfo{
+			Endpoint:         member.Endpoint,
+			InternalEndpoint: member.InternalEndpoint,
+			PkiId:            hex.EncodeToString(member.PKIID),
+			Tags:             member.Tags,
+			Zone:             member.Zone,
+			Leaving:          member.Leaving,
+			Org:              member.Org,
+			Labels:           member.Labels,
+		})
+	}
+	return list, nil
+}
+
+func (s *adminServer) ConnectionStats(context.Context, *emptypb.Empty) (*protos.ConnectionStatsResponse, error) {
+	dropped := s.g.DroppedMessages()
+	dedup := s.g.DedupStats()
+	identities := s.g.IdentityStats()
+	allocs := s.g.AllocStats()
+	resp := &protos.ConnectionStatsResponse{
+		DroppedControl:     dropped.Control,
+		DroppedData:        dropped.Data,
+		DroppedReceived:    dropped.Received,
+		DedupReceived:      dedup.Received,
+		DedupSuppressed:    dedup.Suppressed,
+		IdentityCacheSize:  uint64(identities.Size),
+		IdentitiesUnused:   identities.Unused,
+		IdentitiesEvicted:  identities.Evicted,
+		IdentitiesExpired:  identities.Expired,
+		IdentitiesRejected: identities.Rejected,
+		HeapAllocs:         allocs.Mallocs,
+		HeapAllocBytes:     allocs.TotalAlloc,
+		GcCycles:           allocs.NumGC,
+		ChunkBufferGets:    allocs.ChunkBuffers.Gets,
+		ChunkBufferAllocs:  allocs.ChunkBuffers.Allocs,
+		CopyBufferGets:     allocs.CopyBuffers.Gets,
+		CopyBufferAllocs:   allocs.CopyBuffers.Allocs,
+	}
+	for _, stats := range s.g.ConnectionStats() {
+		conn := &protos.ConnectionStat{
+			PkiId:           hex.EncodeToString(stats.PKIID),
+			Endpoint:        stats.Endpoint,
+			QueuedControl:   uint32(stats.QueuedControl),
+			QueuedData:      uint32(stats.QueuedData),
+			ProtocolVersion: stats.ProtocolVersion,
+			BytesIn:         stats.BytesIn,
+			BytesOut:        stats.BytesOut,
+			MessagesIn:      stats.MessagesIn,
+			MessagesOut:     stats.MessagesOut,
+			LastActivity:    stats.LastActivity.Unix(),
+			RttNanos:        int64(stats.RTT),
+		}
+		if stats.TLS != nil {
+			conn.Tls = &protos.TLSSession{
+				Version:            stats.TLS.Version,
+				CipherSuite:        stats.TLS.CipherSuite,
+				ServerName:         stats.TLS.ServerName,
+				NegotiatedProtocol: stats.TLS.NegotiatedProtocol,
+				PeerCertificate:    stats.TLS.PeerCertificate,
+			}
+		}
+		resp.Connections = append(resp.Connections, conn)
+	}
+	return resp, nil
+}
+
+func (s *adminServer) PeerScores(context.Context, *emptypb.Empty) (*protos.PeerScoreList, error) {
+	threshold := s.g.getConf().ReputationThreshold
+	list := &protos.PeerScoreList{}
+	for _, score := range s.g.PeerScores() {
+		peer := &protos.PeerScore{
+			PkiId:               hex.EncodeToString(score.PKIID),
+			Score:               int32(score.Score),
+			Distrusted:          score.Score < threshold,
+			InvalidSignatures:   score.Misbehaviors[common.InvalidSignature],
+			MalformedMessages:   score.Misbehaviors[common.MalformedMessage],
+			RateLimitViolations: score.Misbehaviors[common.RateLimitExceeded],
+			FailedTransfers:     score.Misbehaviors[common.FailedTransfer],
+		}
+		if !score.BannedUntil.IsZero() {
+			peer.BannedUntil = score.BannedUntil.Unix()
+		}
+		list.Peers = append(list.Peers, peer)
+	}
+	return list, nil
+}
+
+func (s *adminServer) TransferProgress(ctx context.Context, req *protos.ChannelRequest) (*protos.TransferProgressResponse, error) {
+	gc, err := s.lookupChannel(req.ChainMac)
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := gc.FileProgress()
+	if err != nil {
+		return nil, err
+	}
+	resp := &protos.TransferProgressResponse{}
+	for _, p := range progress {
+		resp.Files = append(resp.Files, &protos.FileProgress{
+			Path:         p.Filename,
+			Mode:         p.Mode,
+			FileSize:     p.Size,
+			ExpectedSize: p.ExpectedSize,
+			Transferring: p.Transferring,
+		})
+	}
+	return resp, nil
+}
+
+func (s *adminServer) CreateChannel(ctx context.Context, req *protos.CreateChannelRequest) (*emptypb.Empty, error) {
+	if err := s.channels.CreateChannel(req.ChainId, toFileSyncInfos(req.Files)); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *adminServer) AddMember(ctx context.Context, req *protos.AddMemberRequest) (*emptypb.Empty, error) {
+	cert, err := util.GetX509CertificateFromPEM(req.Certificate)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid member certificate")
+	}
+	if err := s.channels.AddMemberToChan(req.ChainId, req.NodeId, cert); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *adminServer) AddFile(ctx context.Context, req *protos.AddFileRequest) (*emptypb.Empty, error) {
+	if err := s.channels.AddFileToChan(req.ChainId, toFileSyncInfos(req.Files)); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *adminServer) RemoveFile(ctx context.Context, req *protos.RemoveFileRequest) (*emptypb.Empty, error) {
+	if err := s.channels.RemoveFileWithChan(req.ChainId, req.Files); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *adminServer) DebugDump(context.Context, *emptypb.Empty) (*protos.DebugDumpResponse, error) {
+	if !s.g.getConf().AdminDebug {
+		return nil, errors.New("DebugDump is disabled, enable AdminDebug in the gossip configuration")
+	}
+
+	// debug=2 prints the stacks like an unrecovered panic, with the time each goroutine has been blocked
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		return nil, errors.Wrap(err, "Failed dumping the goroutines")
+	}
+	resp := &protos.DebugDumpResponse{
+		GoroutineCount: uint32(runtime.NumGoroutine()),
+		Goroutines:     goroutines.String(),
+	}
+	for _, depth := range s.g.QueueDepths() {
+		resp.Queues = append(resp.Queues, &protos.QueueDepth{
+			Name:     depth.Name,
+			Length:   uint32(depth.Length),
+			Capacity: uint32(depth.Capacity),
+		})
+	}
+	return resp, nil
+}
+
+func toFileSyncInfos(files []*protos.FileInfo) []*common.FileSyncInfo {
+	infos := make([]*common.FileSyncInfo, len(files))
+	for i, f := range files {
+		infos[i] = &common.FileSyncInfo{Path: f.Path, Mode: f.Mode, Metadata: f.Metadata}
+	}
+	return infos
+}
+
+func (s *adminServer) lookupChannel(chainMac string) (channel.Channel, error) {
+	mac, err := hex.DecodeString(chainMac)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid channel MAC %s", chainMac)
+	}
+	gc := s.g.chanState.getChannelByMAC(mac)
+	if gc == nil {
+		return nil, ErrChannelNotExist
+	}
+	return gc, nil
+}
+
+func (s *adminServer) channelSummary(chainMac string, gc channel.Channel) (*protos.ChannelSummary, *protos.ChainStateInfo, error) {
+	summary, stateInfo, err := s.g.channelSummary(chainMac, gc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &protos.ChannelSummary{
+		ChainMac:    chainMac,
+		ChainId:     summary.ChainID,
+		Leader:      hex.EncodeToString(summary.Leader),
+		IsLeader:    summary.IsLeader,
+		SeqNum:      summary.SeqNum,
+		Epoch:       summary.Epoch,
+		MemberCount: uint32(summary.MemberCount),
+		FileCount:   uint32(summary.FileCount),
+	}, stateInfo, nil
+}
@@ -0,0 +1,258 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/filter"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type channelAdminMock struct {
+	chainID   string
+	nodeID    string
+	files     []*common.FileSyncInfo
+	filenames []string
+}
+
+func (m *channelAdminMock) CreateChannel(chainID string, files []*common.FileSyncInfo) error {
+	m.chainID, m.files = chainID, files
+	return nil
+}
+
+func (m *channelAdminMock) AddMemberToChan(chainID string, nodeID string, cert *x509.Certificate) error {
+	m.chainID, m.nodeID = chainID, nodeID
+	return nil
+}
+
+func (m *channelAdminMock) AddFileToChan(chainID string, files []*common.FileSyncInfo) error {
+	m.chainID, m.files = chainID, files
+	return nil
+}
+
+func (m *channelAdminMock) RemoveFileWithChan(chainID string, filenames []string) error {
+	m.chainID, m.filenames = chainID, filenames
+	return nil
+}
+
+func TestAdminChannelOperations(t *testing.T) {
+	channels := &channelAdminMock{}
+	admin := &adminServer{channels: channels}
+	ctx := context.Background()
+
+	_, err := admin.CreateChannel(ctx, &protos.CreateChannelRequest{
+		ChainId: "testchannel",
+		Files:   []*protos.FileInfo{{Path: "config.yaml", Mode: "Append", Metadata: []byte("metadata")}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "testchannel", channels.chainID)
+	assert.Equal(t, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append", Metadata: []byte("metadata")}}, channels.files)
+
+	_, err = admin.AddFile(ctx, &protos.AddFileRequest{ChainId: "otherchannel", Files: []*protos.FileInfo{{Path: "rfc2616.txt", Mode: "Random"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "otherchannel", channels.chainID)
+	assert.Equal(t, []*common.FileSyncInfo{{Path: "rfc2616.txt", Mode: "Random"}}, channels.files)
+
+	_, err = admin.RemoveFile(ctx, &protos.RemoveFileRequest{ChainId: "testchannel", Files: []string{"config.yaml", "rfc2616.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, "testchannel", channels.chainID)
+	assert.Equal(t, []string{"config.yaml", "rfc2616.txt"}, channels.filenames)
+
+	_, err = admin.AddMember(ctx, &protos.AddMemberRequest{ChainId: "testchannel", NodeId: "peer1.org2", Certificate: []byte("not a certificate")})
+	assert.Error(t, err)
+
+	cert, err := ioutil.ReadFile("../tests/fixtures/identity/peer1/csp/signcerts/cert.pem")
+	require.NoError(t, err)
+	_, err = admin.AddMember(ctx, &protos.AddMemberRequest{ChainId: "testchannel", NodeId: "peer1.org2", Certificate: cert})
+	require.NoError(t, err)
+	assert.Equal(t, "peer1.org2", channels.nodeID)
+}
+
+func TestAdminDebugDump(t *testing.T) {
+	gossipSvc, err := createGossipServerWithConfig([]string{"localhost:12081"}, "localhost:12081", 0, func(c *config.GossipConfig) {
+		c.AdminService = true
+		c.AdminDebug = true
+	})
+	require.NoError(t, err)
+	defer gossipSvc.Stop()
+
+	g := gossipSvc.(*gossipService)
+	admin := &adminServer{g: g}
+	ctx := context.Background()
+
+	var dump *protos.DebugDumpResponse
+	for i := 0; i < 50; i++ {
+		dump, err = admin.DebugDump(ctx, &emptypb.Empty{})
+		require.NoError(t, err)
+		if strings.Contains(dump.Goroutines, "acceptMessages") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NotZero(t, dump.GoroutineCount)
+	assert.Contains(t, dump.Goroutines, "acceptMessages")
+	names := make(map[string]*protos.QueueDepth)
+	for _, queue := range dump.Queues {
+		names[queue.Name] = queue
+	}
+	require.Contains(t, names, "emitter")
+	assert.Zero(t, names["emitter"].Capacity)
+	require.Contains(t, names, "worker/0")
+	assert.Equal(t, uint32(workerQueueSize), names["worker/0"].Capacity)
+	assert.Contains(t, names, "accept/0")
+
+	g.confLock.Lock()
+	conf := *g.conf
+	conf.AdminDebug = false
+	g.conf = &conf
+	g.confLock.Unlock()
+	_, err = admin.DebugDump(ctx, &emptypb.Empty{})
+	assert.Error(t, err)
+}
+
+func TestAdminService(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12060"}, "localhost:12060", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := createGossipServerWithConfig([]string{"localhost:12060"}, "localhost:13060", 1, func(c *config.GossipConfig) {
+		c.Labels = map[string]string{"role": "ingest", "version": "1.4.2"}
+	})
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	admin := &adminServer{g: gossipSvc1.(*gossipService)}
+	ctx := context.Background()
+
+	var peers *protos.PeerList
+	for i := 0; i < 50; i++ {
+		peers, err = admin.ListPeers(ctx, &emptypb.Empty{})
+		require.NoError(t, err)
+		if len(peers.Peers) == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Len(t, peers.Peers, 1)
+	assert.Equal(t, "localhost:13060", peers.Peers[0].Endpoint)
+	assert.Equal(t, hex.EncodeToString(gossipSvc2.SelfPKIid()), peers.Peers[0].PkiId)
+	assert.Equal(t, "org2", peers.Peers[0].Org)
+	assert.Len(t, filter.SelectAllPeers(gossipSvc1.Peers(), filter.SelectByOrg("org2")), 1)
+	assert.Equal(t, map[string]string{"role": "ingest", "version": "1.4.2"}, peers.Peers[0].Labels)
+	assert.Len(t, filter.SelectAllPeers(gossipSvc1.Peers(), filter.SelectByLabel("role", "ingest")), 1)
+	assert.Empty(t, filter.SelectAllPeers(gossipSvc1.Peers(), filter.SelectByLabel("role", "edge")))
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	channels, err := admin.ListChannels(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Len(t, channels.Channels, 1)
+	summary := channels.Channels[0]
+	assert.Equal(t, mac.String(), summary.ChainMac)
+	assert.Equal(t, "testchannel", summary.ChainId)
+	assert.True(t, summary.IsLeader)
+	assert.Equal(t, uint32(2), summary.MemberCount)
+	assert.Equal(t, uint32(1), summary.FileCount)
+
+	// A channel which hasn't loaded its state is flagged rather than failing the listing
+	loadingMac := channel.GenerateMAC(gossipSvc2.SelfPKIid(), "loadingchannel")
+	gc, _ := gossipSvc1.(*gossipService).chanState.joinChannel(loadingMac, "loadingchannel", false)
+	channels, err = admin.ListChannels(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Len(t, channels.Channels, 2)
+	for _, s := range channels.Channels {
+		assert.Equal(t, s.ChainMac == loadingMac.String(), s.Loading)
+	}
+	gossipSvc1.(*gossipService).chanState.discardChannel(loadingMac, gc)
+
+	summaries := gossipSvc1.Channels()
+	require.Len(t, summaries, 1)
+	assert.Equal(t, mac, summaries[0].ChainMac)
+	assert.Equal(t, "testchannel", summaries[0].ChainID)
+	assert.Equal(t, gossipSvc1.SelfPKIid(), summaries[0].Leader)
+	assert.True(t, summaries[0].IsLeader)
+	assert.Equal(t, 2, summaries[0].MemberCount)
+	assert.Equal(t, 1, summaries[0].FileCount)
+	assert.WithinDuration(t, time.Now(), summaries[0].LastUpdate, time.Minute)
+
+	info, err := admin.ChannelInfo(ctx, &protos.ChannelRequest{ChainMac: mac.String()})
+	require.NoError(t, err)
+	assert.Equal(t, summary, info.Summary)
+	assert.Len(t, info.Members, 2)
+	require.Len(t, info.Files, 1)
+	assert.Equal(t, "config.yaml", info.Files[0].Path)
+
+	_, err = admin.ChannelInfo(ctx, &protos.ChannelRequest{ChainMac: "not hex"})
+	assert.Error(t, err)
+	_, err = admin.ChannelInfo(ctx, &protos.ChannelRequest{ChainMac: "0a0b"})
+	assert.Equal(t, ErrChannelNotExist, err)
+
+	fi, err := os.Stat(filepath.Join(testDataDir, "peer0", "config.yaml"))
+	require.NoError(t, err)
+	progress, err := admin.TransferProgress(ctx, &protos.ChannelRequest{ChainMac: mac.String()})
+	require.NoError(t, err)
+	require.Len(t, progress.Files, 1)
+	assert.Equal(t, "config.yaml", progress.Files[0].Path)
+	assert.Equal(t, protos.File_Append, progress.Files[0].Mode)
+	assert.Equal(t, fi.Size(), progress.Files[0].FileSize)
+	assert.Equal(t, fi.Size(), progress.Files[0].ExpectedSize)
+
+	stats, err := admin.ConnectionStats(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	require.NotEmpty(t, stats.Connections)
+	assert.Equal(t, hex.EncodeToString(gossipSvc2.SelfPKIid()), stats.Connections[0].PkiId)
+	assert.NotZero(t, stats.Connections[0].BytesIn)
+	assert.NotEmpty(t, stats.Connections[0].MessagesIn)
+	assert.NotZero(t, stats.Connections[0].LastActivity)
+	assert.NotZero(t, stats.HeapAllocs)
+	assert.True(t, stats.ChunkBufferAllocs <= stats.ChunkBufferGets)
+
+	for i := 0; i < 3; i++ {
+		gossipSvc1.ReportMisbehavior(gossipSvc2.SelfPKIid(), common.InvalidSignature)
+	}
+	scores, err := admin.PeerScores(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Len(t, scores.Peers, 1)
+	assert.Equal(t, hex.EncodeToString(gossipSvc2.SelfPKIid()), scores.Peers[0].PkiId)
+	assert.Equal(t, int32(25), scores.Peers[0].Score)
+	assert.True(t, scores.Peers[0].Distrusted)
+	assert.Equal(t, uint64(3), scores.Peers[0].InvalidSignatures)
+	assert.Zero(t, scores.Peers[0].BannedUntil)
+	members := gossipSvc1.(*gossipService).membership()
+	require.Len(t, members, 1)
+	assert.True(t, members[0].Distrusted)
+	assert.False(t, members[0].Unreachable)
+
+	gossipSvc1.ReportMisbehavior(gossipSvc2.SelfPKIid(), common.InvalidSignature)
+	scores, err = admin.PeerScores(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Len(t, scores.Peers, 1)
+	assert.Zero(t, scores.Peers[0].Score)
+	assert.NotZero(t, scores.Peers[0].BannedUntil)
+	for _, member := range gossipSvc1.(*gossipService).membership() {
+		assert.True(t, member.Unreachable)
+	}
+}
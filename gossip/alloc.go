@@ -0,0 +1,37 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"runtime"
+
+	"github.com/rkcloudchain/rksync/channel/fsync"
+	"github.com/rkcloudchain/rksync/lib"
+)
+
+// AllocStats holds the heap allocations of the process, along with the use
+// of the buffer pools of the file transfers which spare some of them
+type AllocStats struct {
+	Mallocs      uint64 // Number of heap objects allocated since the process started
+	TotalAlloc   uint64 // Number of bytes allocated for the heap objects since the process started
+	NumGC        uint32 // Number of completed garbage collections
+	ChunkBuffers lib.PoolStats
+	CopyBuffers  lib.PoolStats
+}
+
+func (g *gossipService) AllocStats() AllocStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	chunks, copies := fsync.BufferStats()
+	return AllocStats{
+		Mallocs:      mem.Mallocs,
+		TotalAlloc:   mem.TotalAlloc,
+		NumGC:        mem.NumGC,
+		ChunkBuffers: chunks,
+		CopyBuffers:  copies,
+	}
+}
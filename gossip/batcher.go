@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/lib"
 )
 
 type emitBatchCallback func([]interface{})
@@ -26,14 +27,18 @@ type batchingEmitter interface {
 
 	// Size returns the amount of pending message to be emitted
 	Size() int
+
+	// SetBurst changes the burst size and latency of the emitter
+	SetBurst(burstSize int, latency time.Duration)
 }
 
 // newBatchingEmitter accepts the following parameters:
 // iterations: number of times each message is forwarded
 // burstSize: a threshold that triggers a forwarding because of message count
 // latency: the maximum delay that each message can be stored without being forwarded
+// clock: the clock the latency is measured with
 // cb: a callback that is called in order for the forwarding to take place
-func newBatchingEmitter(iterations, burstSize int, latency time.Duration, cb emitBatchCallback) batchingEmitter {
+func newBatchingEmitter(iterations, burstSize int, latency time.Duration, clock lib.Clock, cb emitBatchCallback) batchingEmitter {
 	if iterations < 0 {
 		panic(errors.New("Got a negative iterations number"))
 	}
@@ -41,6 +46,7 @@ func newBatchingEmitter(iterations, burstSize int, latency time.Duration, cb emi
 	p := &batchingEmitterImpl{
 		cb:         cb,
 		delay:      latency,
+		clock:      clock,
 		iterations: iterations,
 		burstSize:  burstSize,
 		buff:       make([]*batchedMessage, 0),
@@ -58,12 +64,18 @@ type batchingEmitterImpl struct {
 	iterations int
 	burstSize  int
 	delay      time.Duration
+	clock      lib.Clock
 	cb         emitBatchCallback
 	lock       sync.Mutex
 	buff       []*batchedMessage
 	stopFlag   int32
 }
 
+// iterable is implemented by the messages overriding the number of times they're emitted
+type iterable interface {
+	emitIterations() int
+}
+
 type batchedMessage struct {
 	data           interface{}
 	iterationsLeft int
@@ -79,7 +91,11 @@ func (p *batchingEmitterImpl) Add(message interface{}) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	p.buff = append(p.buff, &batchedMessage{data: message, iterationsLeft: p.iterations})
+	iterations := p.iterations
+	if m, ok := message.(iterable); ok && m.emitIterations() > 0 {
+		iterations = m.emitIterations()
+	}
+	p.buff = append(p.buff, &batchedMessage{data: message, iterationsLeft: iterations})
 	if len(p.buff) >= p.burstSize {
 		p.emit()
 	}
@@ -99,9 +115,23 @@ func (p *batchingEmitterImpl) Size() int {
 	return len(p.buff)
 }
 
+func (p *batchingEmitterImpl) SetBurst(burstSize int, latency time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.burstSize = burstSize
+	p.delay = latency
+	if len(p.buff) >= p.burstSize {
+		p.emit()
+	}
+}
+
 func (p *batchingEmitterImpl) periodicEmit() {
 	for !p.toDie() {
-		time.Sleep(p.delay)
+		p.lock.Lock()
+		delay := p.delay
+		p.lock.Unlock()
+
+		p.clock.Sleep(delay)
 		p.lock.Lock()
 		p.emit()
 		p.lock.Unlock()
@@ -0,0 +1,130 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// peerBlocklist refuses the peers blocked by PKI-ID or node ID, and the peers
+// which aren't allowed when the allowlist isn't empty
+type peerBlocklist struct {
+	sync.RWMutex
+	blocked   map[string]struct{}
+	allowed   map[string]struct{}
+	decisions sync.Map // hex encoded PKI-ID -> error, nil if the peer is accepted
+}
+
+func newPeerBlocklist(blocked, allowed []string) *peerBlocklist {
+	b := &peerBlocklist{
+		blocked: make(map[string]struct{}, len(blocked)),
+		allowed: make(map[string]struct{}, len(allowed)),
+	}
+	for _, id := range blocked {
+		b.blocked[id] = struct{}{}
+	}
+	for _, id := range allowed {
+		b.allowed[id] = struct{}{}
+	}
+	return b
+}
+
+// block refuses the peer with the given hex encoded PKI-ID or node ID
+func (b *peerBlocklist) block(id string) {
+	b.Lock()
+	defer b.Unlock()
+	b.blocked[id] = struct{}{}
+	b.decisions = sync.Map{}
+}
+
+// unblock accepts the peer with the given hex encoded PKI-ID or node ID again,
+// it returns false if it wasn't blocked
+func (b *peerBlocklist) unblock(id string) bool {
+	b.Lock()
+	defer b.Unlock()
+	if _, exists := b.blocked[id]; !exists {
+		return false
+	}
+	delete(b.blocked, id)
+	b.decisions = sync.Map{}
+	return true
+}
+
+// list returns the blocked PKI-IDs and node IDs, sorted
+func (b *peerBlocklist) list() []string {
+	b.RLock()
+	defer b.RUnlock()
+	ids := make([]string, 0, len(b.blocked))
+	for id := range b.blocked {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// enabled returns whether some peers are blocked, or some are allowed
+func (b *peerBlocklist) enabled() bool {
+	b.RLock()
+	defer b.RUnlock()
+	return len(b.blocked) > 0 || len(b.allowed) > 0
+}
+
+// check returns an error if the peer is refused. The node ID is read from the identity of the peer,
+// the peer is only checked by PKI-ID if the identity is nil.
+func (b *peerBlocklist) check(pkiID common.PKIidType, identity common.PeerIdentityType) error {
+	b.RLock()
+	defer b.RUnlock()
+
+	if len(b.blocked) == 0 && len(b.allowed) == 0 {
+		return nil
+	}
+
+	key := pkiID.String()
+	if identity != nil {
+		if decision, exists := b.decisions.Load(key); exists {
+			err, _ := decision.(error)
+			return err
+		}
+	}
+
+	ids := []string{key}
+	if identity != nil {
+		sid := &protos.SerializedIdentity{}
+		if err := proto.Unmarshal(identity, sid); err == nil && sid.NodeId != "" {
+			ids = append(ids, sid.NodeId)
+		}
+	}
+
+	err := b.decide(ids)
+	if identity != nil {
+		b.decisions.Store(key, err)
+	}
+	return err
+}
+
+func (b *peerBlocklist) decide(ids []string) error {
+	for _, id := range ids {
+		if _, exists := b.blocked[id]; exists {
+			return errors.Errorf("Peer %s is blocked", id)
+		}
+	}
+	if len(b.allowed) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		if _, exists := b.allowed[id]; exists {
+			return nil
+		}
+	}
+	return errors.Errorf("Peer %s isn't allowed", ids[0])
+}
@@ -0,0 +1,78 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPeerBlocklist(t *testing.T) {
+	identity, err := proto.Marshal(&protos.SerializedIdentity{NodeId: "peer1.org2", IdBytes: []byte("cert")})
+	require.NoError(t, err)
+	pkiID := common.PKIidType("peer1")
+
+	blocklist := newPeerBlocklist(nil, nil)
+	assert.False(t, blocklist.enabled())
+	assert.NoError(t, blocklist.check(pkiID, identity))
+
+	// Peers are blocked by node ID or by PKI-ID
+	blocklist.block("peer1.org2")
+	assert.True(t, blocklist.enabled())
+	assert.Error(t, blocklist.check(pkiID, identity))
+	assert.NoError(t, blocklist.check(pkiID, nil))
+	assert.True(t, blocklist.unblock("peer1.org2"))
+	assert.False(t, blocklist.unblock("peer1.org2"))
+	assert.NoError(t, blocklist.check(pkiID, identity))
+
+	blocklist.block(pkiID.String())
+	assert.Error(t, blocklist.check(pkiID, nil))
+	assert.Error(t, blocklist.check(pkiID, identity))
+	assert.Equal(t, []string{pkiID.String()}, blocklist.list())
+
+	// Only the allowed peers are accepted, unless they're blocked
+	blocklist = newPeerBlocklist([]string{"peer2.org3"}, []string{"peer1.org2", "peer2.org3"})
+	assert.NoError(t, blocklist.check(pkiID, identity))
+	assert.Error(t, blocklist.check(pkiID, nil))
+	other, err := proto.Marshal(&protos.SerializedIdentity{NodeId: "peer2.org3", IdBytes: []byte("cert")})
+	require.NoError(t, err)
+	assert.Error(t, blocklist.check(common.PKIidType("peer2"), other))
+}
+
+func TestBlockPeer(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12072"}, "localhost:12072", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12072"}, "localhost:13061", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	require.Eventually(t, func() bool {
+		return len(gossipSvc1.Peers()) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+
+	gossipSvc1.BlockPeer("peer1.org2")
+	assert.Equal(t, []string{"peer1.org2"}, gossipSvc1.BlockedPeers())
+	assert.Empty(t, gossipSvc1.Peers())
+	assert.Empty(t, gossipSvc1.(*gossipService).membership())
+
+	member := &common.NetworkMember{Endpoint: "localhost:13061"}
+	_, err = gossipSvc1.(*gossipService).srv.Handshake(member)
+	assert.Error(t, err)
+
+	gossipSvc1.UnblockPeer("peer1.org2")
+	assert.Empty(t, gossipSvc1.BlockedPeers())
+	_, err = gossipSvc1.(*gossipService).srv.Handshake(member)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"net"
+	"sort"
+
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/server"
+)
+
+// bootstrapResolver resolves the host names of the bootstrap peers,
+// e.g. a headless service returning one address per replica, and
+// keeps track of the addresses already known.
+type bootstrapResolver struct {
+	endpoints  []string
+	lookupHost func(host string) ([]string, error)
+	known      map[string]struct{}
+	logger     logging.Logger
+}
+
+func newBootstrapResolver(endpoints []string, logger logging.Logger) *bootstrapResolver {
+	return &bootstrapResolver{
+		endpoints:  endpoints,
+		lookupHost: net.LookupHost,
+		known:      make(map[string]struct{}),
+		logger:     logger,
+	}
+}
+
+// resolve returns the addresses of the bootstrap peers that
+// haven't been returned by a previous resolution
+func (r *bootstrapResolver) resolve() []string {
+	var fresh []string
+	for _, endpoint := range r.endpoints {
+		if server.IsWebSocketURL(endpoint) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			r.logger.Warningf("Invalid bootstrap peer %s: %s", endpoint, err)
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			continue
+		}
+
+		addrs, err := r.lookupHost(host)
+		if err != nil {
+			r.logger.Warningf("Failed resolving bootstrap peer %s: %s", endpoint, err)
+			continue
+		}
+		for _, addr := range addrs {
+			address := net.JoinHostPort(addr, port)
+			if _, exists := r.known[address]; exists {
+				continue
+			}
+			r.known[address] = struct{}{}
+			fresh = append(fresh, address)
+		}
+	}
+
+	sort.Strings(fresh)
+	return fresh
+}
+
+// isLocalAddress checks whether the address designates this peer,
+// i.e. it uses the port of the peer endpoint and one of the local addresses
+func isLocalAddress(address, selfEndpoint string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	_, selfPort, err := net.SplitHostPort(selfEndpoint)
+	if err != nil || port != selfPort {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
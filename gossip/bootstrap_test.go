@@ -0,0 +1,41 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapResolver(t *testing.T) {
+	addrs := map[string][]string{
+		"peers.rksync": {"10.0.0.2", "10.0.0.1"},
+	}
+	resolver := newBootstrapResolver([]string{"peers.rksync:9053", "10.0.0.9:9053", "unknown.rksync:9053", "invalid", "wss://peers.rksync/rksync"}, logging.Default())
+	resolver.lookupHost = func(host string) ([]string, error) {
+		if a, exists := addrs[host]; exists {
+			return a, nil
+		}
+		return nil, errors.Errorf("no such host %s", host)
+	}
+
+	assert.Equal(t, []string{"10.0.0.1:9053", "10.0.0.2:9053"}, resolver.resolve())
+	assert.Empty(t, resolver.resolve())
+
+	addrs["peers.rksync"] = []string{"10.0.0.1", "10.0.0.3", "10.0.0.2"}
+	assert.Equal(t, []string{"10.0.0.3:9053"}, resolver.resolve())
+}
+
+func TestIsLocalAddress(t *testing.T) {
+	assert.True(t, isLocalAddress("127.0.0.1:9053", "localhost:9053"))
+	assert.False(t, isLocalAddress("127.0.0.1:9054", "localhost:9053"))
+	assert.False(t, isLocalAddress("192.0.2.1:9053", "localhost:9053"))
+	assert.False(t, isLocalAddress("invalid", "localhost:9053"))
+}
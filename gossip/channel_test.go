@@ -8,19 +8,26 @@ package gossip
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/server"
 	"github.com/rkcloudchain/rksync/tests/mocks"
 	"github.com/rkcloudchain/rksync/tests/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestAddSelfToChainMembers(t *testing.T) {
@@ -37,6 +44,31 @@ func TestAddSelfToChainMembers(t *testing.T) {
 	assert.Contains(t, err.Error(), "Can't add self-node to the channel members")
 }
 
+func TestIdempotentChannelCreation(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12074"}, "localhost:12074", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	assert.Equal(t, ErrChannelNotExist, errors.Cause(err))
+
+	created, err := gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	assert.Equal(t, ErrChannelExists, errors.Cause(err))
+	assert.True(t, proto.Equal(created, gossipSvc1.SelfChainInfo("testchannel")))
+
+	assert.NoError(t, gossipSvc1.InitializeChain(mac, created))
+	// The state of the channel is updated in place
+	created = proto.Clone(created).(*protos.ChainState)
+	updated, err := gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	err = gossipSvc1.InitializeChain(mac, created)
+	assert.Equal(t, ErrChannelExists, errors.Cause(err))
+	assert.True(t, proto.Equal(updated, gossipSvc1.SelfChainInfo("testchannel")))
+}
+
 func TestChannelInit(t *testing.T) {
 	gossipSvc1, err := CreateGossipServer([]string{"localhost:9053"}, "localhost:9053", 0)
 	require.NoError(t, err)
@@ -149,15 +181,14 @@ func TestRemoveMemberWithChain(t *testing.T) {
 	chainState = gossipSvc3.SelfChainInfo("channel3")
 	assert.NotNil(t, chainState)
 
-	chainState, err = gossipSvc1.RemoveMemberWithChain(mac, []byte{0})
-	assert.NoError(t, err)
-	chainInfo, err := chainState.GetChainStateInfo()
+	_, err = gossipSvc1.RemoveMemberWithChain(mac, []byte{0})
+	assert.Equal(t, ErrNotMember, errors.Cause(err))
+	chainInfo, err := gossipSvc1.SelfChainInfo("channel3").GetChainStateInfo()
 	assert.NoError(t, err)
 	assert.Len(t, chainInfo.Properties.Members, 3)
 
 	_, err = gossipSvc2.RemoveMemberWithChain(mac, gossipSvc3.SelfPKIid())
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "Failed verifying ChainStateInfo message")
+	assert.Equal(t, ErrNotLeader, errors.Cause(err))
 
 	chainState, err = gossipSvc1.RemoveMemberWithChain(mac, gossipSvc3.SelfPKIid())
 	assert.NoError(t, err)
@@ -171,6 +202,50 @@ func TestRemoveMemberWithChain(t *testing.T) {
 	assert.Nil(t, chainState)
 }
 
+// testDataDir holds a copy of the test data, the peers synchronize their files there
+var testDataDir string
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "rksync-gossip")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	testDataDir = dir
+	if err = copyTestData("../tests/testdata", dir); err != nil {
+		os.RemoveAll(dir)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func copyTestData(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(dst, rel), data, info.Mode()); err != nil {
+			return err
+		}
+		return os.Chtimes(filepath.Join(dst, rel), info.ModTime(), info.ModTime())
+	})
+}
+
 func secureDialOpts() []grpc.DialOption {
 	var dialOpts []grpc.DialOption
 	dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
@@ -179,20 +254,26 @@ func secureDialOpts() []grpc.DialOption {
 	))
 
 	dialOpts = append(dialOpts, config.ClientKeepaliveOptions(nil)...)
-	dialOpts = append(dialOpts, grpc.WithInsecure())
+	dialOpts = append(dialOpts, grpc.WithInsecure(), grpc.WithContextDialer(server.DialMemory))
 
 	return dialOpts
 }
 
 // CreateGossipServer creates a gossip server
-func CreateGossipServer(bootstrap []string, address string, num int) (Gossip, error) {
+func CreateGossipServer(bootstrap []string, address string, num int, opts ...Option) (Gossip, error) {
+	return createGossipServerWithConfig(bootstrap, address, num, nil, opts...)
+}
+
+// createGossipServerWithConfig creates a gossip server whose configuration is adjusted by configure before it is started
+func createGossipServerWithConfig(bootstrap []string, address string, num int, configure func(*config.GossipConfig), opts ...Option) (Gossip, error) {
 	home, err := filepath.Abs(fmt.Sprintf("../tests/fixtures/identity/peer%d", num))
 	if err != nil {
 		return nil, err
 	}
 
 	idCfg := &config.IdentityConfig{
-		ID: fmt.Sprintf("peer%d.org%d", num, num+1),
+		ID:  fmt.Sprintf("peer%d.org%d", num, num+1),
+		Org: fmt.Sprintf("org%d", num+1),
 	}
 	err = idCfg.MakeFilesAbs(home)
 	if err != nil {
@@ -210,13 +291,12 @@ func CreateGossipServer(bootstrap []string, address string, num int) (Gossip, er
 	}
 
 	cfg := util.DefaultGossipConfig(bootstrap, address)
-	p, err := filepath.Abs("../tests/testdata")
-	if err != nil {
-		return nil, err
+	cfg.FileSystem = mocks.NewFSMock(filepath.Join(testDataDir, fmt.Sprintf("peer%d", num)))
+	if configure != nil {
+		configure(cfg)
 	}
-	cfg.FileSystem = mocks.NewFSMock(filepath.Join(p, fmt.Sprintf("peer%d", num)))
 
-	gossipSrv, err := NewGossipService(cfg, idCfg, gsrv.Server(), selfIdentity, secureDialOpts)
+	gossipSrv, err := NewGossipService(cfg, idCfg, gsrv.Server(), selfIdentity, append([]Option{WithSecureDialOpts(secureDialOpts)}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -225,9 +305,174 @@ func CreateGossipServer(bootstrap []string, address string, num int) (Gossip, er
 	return gossipSrv, nil
 }
 
-// CreateGRPCServer creates a new grpc server
+// CreateGRPCServer creates a new grpc server, the peers are connected in memory
 func CreateGRPCServer(address string) (*server.GRPCServer, error) {
-	return server.NewGRPCServer(address, &config.ServerConfig{
+	lis, err := server.ListenMemory(address)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := server.NewGRPCServerFromListener(lis, &config.ServerConfig{
 		SecOpts: &config.TLSConfig{UseTLS: false},
 	})
+	if err != nil {
+		lis.Close()
+	}
+	return srv, err
+}
+
+func TestChainStateLimits(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12054"}, "localhost:12054", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+	conf := gossipSvc1.(*gossipService).conf
+	conf.MaxChannelMembers = 2
+	conf.MaxChannelFiles = 2
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "config.yaml", Mode: "Append"},
+		&common.FileSyncInfo{Path: "rfc2616.txt", Mode: "Append"},
+	})
+	require.NoError(t, err)
+
+	_, err = gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{&common.FileSyncInfo{Path: "101.png", Mode: "Append"}})
+	require.Error(t, err)
+	limitErr, ok := err.(*channel.LimitExceededError)
+	require.True(t, ok)
+	assert.Equal(t, channel.LimitFiles, limitErr.Limit)
+	assert.Equal(t, 3, limitErr.Actual)
+
+	_, err = gossipSvc1.AddMemberToChain(mac, common.PKIidType("peer1"))
+	assert.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, common.PKIidType("peer2"))
+	limitErr, ok = err.(*channel.LimitExceededError)
+	require.True(t, ok)
+	assert.Equal(t, channel.LimitMembers, limitErr.Limit)
+
+	conf.MaxChannelMembers = 0
+	conf.MaxChainStateSize = 64
+	_, err = gossipSvc1.AddMemberToChain(mac, common.PKIidType("peer2"))
+	limitErr, ok = err.(*channel.LimitExceededError)
+	require.True(t, ok)
+	assert.Equal(t, channel.LimitStateSize, limitErr.Limit)
+
+	selfChannelInfo := gossipSvc1.SelfChainInfo("testchannel")
+	msg, err := selfChannelInfo.Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	assert.Len(t, msg.GetStateInfo().Properties.Members, 2)
+	assert.Len(t, msg.GetStateInfo().Properties.Files, 2)
+}
+
+func TestMessageSizeLimits(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12062"}, "localhost:12062", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+	conf := gossipSvc1.(*gossipService).conf
+	conf.MaxMessageSize = 16 * 1024
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "config.yaml", Mode: "Append"},
+	})
+	require.NoError(t, err)
+
+	_, err = gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "rfc2616.txt", Mode: "Append", Metadata: make([]byte, 16*1024)},
+	})
+	require.Error(t, err)
+	metadataErr, ok := err.(*channel.MetadataSizeError)
+	require.True(t, ok)
+	assert.Equal(t, "rfc2616.txt", metadataErr.Filename)
+	assert.Equal(t, 16*1024-protos.MessageOverhead, metadataErr.Max)
+
+	// The metadata fit in a message separately, but not together
+	_, err = gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "rfc2616.txt", Mode: "Append", Metadata: make([]byte, 8*1024)},
+		&common.FileSyncInfo{Path: "101.png", Mode: "Append", Metadata: make([]byte, 8*1024)},
+	})
+	require.Error(t, err)
+	limitErr, ok := err.(*channel.LimitExceededError)
+	require.True(t, ok)
+	assert.Equal(t, channel.LimitMessageSize, limitErr.Limit)
+
+	msg, err := gossipSvc1.SelfChainInfo("testchannel").Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	assert.Len(t, msg.GetStateInfo().Properties.Files, 1)
+}
+
+type denyLeaderPolicy struct {
+	nodeID string
+}
+
+func (p *denyLeaderPolicy) CanJoin(chainID string, peer config.PeerInfo) error {
+	return nil
+}
+
+func (p *denyLeaderPolicy) CanLead(chainID string, peer config.PeerInfo) error {
+	if peer.NodeID == p.nodeID {
+		return fmt.Errorf("%s can't lead %s", peer.NodeID, chainID)
+	}
+	return nil
+}
+
+func TestAccessControlPolicy(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12055"}, "localhost:12055", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12055"}, "localhost:13055", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	var letters int32
+	conf := gossipSvc2.(*gossipService).conf
+	conf.AccessControl = &denyLeaderPolicy{nodeID: "peer0.org1"}
+	gossipSvc2.(*gossipService).deadLetters = newDeadLetterBox(func(letter config.DeadLetter) {
+		atomic.AddInt32(&letters, 1)
+	}, 10, logging.Default())
+
+	time.Sleep(5 * time.Second)
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Second)
+	assert.Nil(t, gossipSvc2.SelfChainInfo("testchannel"))
+	assert.NotZero(t, atomic.LoadInt32(&letters))
+}
+
+func TestAnchorPeers(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12056"}, "localhost:12056", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:13056"}, "localhost:13056", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	time.Sleep(5 * time.Second)
+	assert.Empty(t, gossipSvc1.Peers())
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	_, err = gossipSvc1.SetAnchorPeers(mac, []string{"localhost"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid anchor peer localhost")
+
+	chainState, err := gossipSvc1.SetAnchorPeers(mac, []string{"localhost:13056"})
+	require.NoError(t, err)
+	stateInfo, err := chainState.GetChainStateInfo()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost:13056"}, stateInfo.AnchorPeers)
+
+	time.Sleep(10 * time.Second)
+	assert.Len(t, gossipSvc1.Peers(), 1)
+	assert.Len(t, gossipSvc2.Peers(), 1)
+	assert.NotNil(t, gossipSvc2.SelfChainInfo("testchannel"))
 }
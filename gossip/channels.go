@@ -0,0 +1,250 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// ChannelSummary describes a channel the peer takes part in
+type ChannelSummary struct {
+	ChainMac        common.ChainMac
+	ChainID         string
+	Leader          common.PKIidType
+	IsLeader        bool                  // Whether the peer is the leader of the channel
+	MemberCount     int                   // Number of members, the leader included
+	FileCount       int                   // Number of files synchronized in the channel
+	SeqNum          uint64                // Sequence number of the state of the channel
+	Epoch           uint64                // Epoch of the state of the channel, incremented by the leader at every change
+	LastUpdate      time.Time             // Time the leader last updated the state of the channel
+	LeaderHeartbeat time.Time             // Time the last heartbeat of the leader was received, or the channel was joined, zero on the leader
+	Recovery        channel.RecoveryState // How the local files related to the last journaled chain state when the channel was initialized
+}
+
+// FileSyncStatus is the synchronization state of a file of the channel on a member, as acknowledged to the leader
+type FileSyncStatus struct {
+	Filename   string
+	Version    uint64    // Version of the file the member acknowledged
+	VersionLag uint64    // Number of versions published by the leader since the acknowledged one
+	ByteLag    int64     // Number of bytes of the leader's copy beyond the acknowledged ones
+	Complete   bool      // Whether the member acknowledged the latest version and the whole content of the leader's copy
+	LastSeen   time.Time // Time the member last acknowledged the file, zero if it never did
+}
+
+// MemberSyncStatus is the synchronization state of the files of the channel on a member
+type MemberSyncStatus struct {
+	Member   common.PKIidType
+	Files    []FileSyncStatus // Sorted by name
+	Complete bool             // Whether all the files are complete
+	LastSeen time.Time        // Time the member last acknowledged a file, zero if it never did
+}
+
+func (g *gossipService) Channels() []ChannelSummary {
+	var summaries []ChannelSummary
+	for mac, gc := range g.chanState.snapshot() {
+		summary, _, err := g.channelSummary(mac, gc)
+		if err != nil {
+			g.logger.Debugf("Skipping channel: %s", err)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].ChainID < summaries[j].ChainID
+	})
+	return summaries
+}
+
+func (g *gossipService) ChannelFiles(chainID string) ([]channel.FileState, error) {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := gc.FileStates()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Filename < files[j].Filename
+	})
+	return files, nil
+}
+
+func (g *gossipService) ChannelSyncStatus(chainID string) ([]MemberSyncStatus, error) {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return nil, err
+	}
+	chainState := gc.Self()
+	if chainState == nil {
+		return nil, errors.Errorf("Channel %s hasn't loaded its state", chainID)
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed getting channel %s state information", chainID)
+	}
+	if !bytes.Equal(stateInfo.Leader, g.selfPKIid) {
+		return nil, errors.Wrapf(ErrNotLeader, "Channel %s is led by %s", chainID, common.PKIidType(stateInfo.Leader))
+	}
+
+	// The local copies of the leader are the reference of the members' ones
+	progress, err := gc.FileProgress()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(progress))
+	for _, p := range progress {
+		sizes[p.Filename] = p.Size
+	}
+	acks := make(map[string]channel.FileAck)
+	for _, ack := range gc.FileAcks() {
+		acks[ack.Member.String()+"/"+ack.Filename] = ack
+	}
+
+	files := append([]*protos.File(nil), stateInfo.Properties.Files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	var members []MemberSyncStatus
+	for _, m := range stateInfo.Properties.Members {
+		member := common.PKIidType(m)
+		if bytes.Equal(member, g.selfPKIid) {
+			continue
+		}
+
+		status := MemberSyncStatus{Member: member, Files: make([]FileSyncStatus, len(files)), Complete: true}
+		for i, file := range files {
+			fs := FileSyncStatus{Filename: file.Path, VersionLag: file.Version, ByteLag: sizes[file.Path]}
+			if ack, exists := acks[member.String()+"/"+file.Path]; exists {
+				fs.Version = ack.Version
+				fs.LastSeen = ack.Received
+				if ack.Version < file.Version {
+					fs.VersionLag = file.Version - ack.Version
+				} else {
+					fs.VersionLag = 0
+				}
+				if fs.ByteLag -= ack.Size; fs.ByteLag < 0 {
+					fs.ByteLag = 0
+				}
+				fs.Complete = fs.VersionLag == 0 && fs.ByteLag == 0
+				if fs.LastSeen.After(status.LastSeen) {
+					status.LastSeen = fs.LastSeen
+				}
+			}
+			status.Files[i] = fs
+			status.Complete = status.Complete && fs.Complete
+		}
+		members = append(members, status)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Member.String() < members[j].Member.String()
+	})
+	return members, nil
+}
+
+func (g *gossipService) FileVersions(chainID, filename string) ([]channel.FileVersion, error) {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return gc.FileVersions(filename)
+}
+
+func (g *gossipService) PinFileVersion(chainID, filename string, version uint64) error {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return err
+	}
+	return gc.PinFileVersion(filename, version)
+}
+
+func (g *gossipService) UnpinFileVersion(chainID, filename string, version uint64) error {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return err
+	}
+	return gc.UnpinFileVersion(filename, version)
+}
+
+func (g *gossipService) ChangeLog(chainID string) ([]channel.Change, error) {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return gc.ChangeLog(), nil
+}
+
+func (g *gossipService) VerifyChangeLog(chainID string) error {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return err
+	}
+	return gc.VerifyChangeLog()
+}
+
+func (g *gossipService) SetChannelPropagation(chainID string, propagation config.Propagation) error {
+	if propagation.PeerNum < 0 || propagation.Iterations < 0 {
+		return errors.Errorf("Propagation of channel %s can't be negative", chainID)
+	}
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return err
+	}
+	gc.SetPropagation(propagation)
+	return nil
+}
+
+func (g *gossipService) channelByID(chainID string) (channel.Channel, error) {
+	gc := g.chanState.getChannelByChainID(chainID)
+	if gc == nil {
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainID)
+	}
+	return gc, nil
+}
+
+// channelSummary summarizes the state of a channel, returned along with the summary
+func (g *gossipService) channelSummary(chainMac string, gc channel.Channel) (ChannelSummary, *protos.ChainStateInfo, error) {
+	chainState := gc.Self()
+	if chainState == nil {
+		return ChannelSummary{}, nil, errors.Errorf("Channel %s hasn't loaded its state", chainMac)
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return ChannelSummary{}, nil, errors.Wrapf(err, "Failed getting channel %s state information", chainMac)
+	}
+	mac, err := hex.DecodeString(chainMac)
+	if err != nil {
+		return ChannelSummary{}, nil, errors.Wrapf(err, "Invalid channel MAC %s", chainMac)
+	}
+
+	return ChannelSummary{
+		ChainMac:        mac,
+		ChainID:         chainState.ChainId,
+		Leader:          stateInfo.Leader,
+		IsLeader:        bytes.Equal(stateInfo.Leader, g.selfPKIid),
+		MemberCount:     len(stateInfo.Properties.Members),
+		FileCount:       len(stateInfo.Properties.Files),
+		SeqNum:          chainState.SeqNum,
+		Epoch:           stateInfo.Epoch,
+		LastUpdate:      time.Unix(0, int64(chainState.SeqNum)),
+		LeaderHeartbeat: gc.LeaderHeartbeat(),
+		Recovery:        gc.Recovery(),
+	}, stateInfo, nil
+}
@@ -17,38 +17,73 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
 	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/discovery"
-	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 )
 
+// channelStateShards is the number of shards of the channels, so that the lookups
+// of the channels of the received messages don't contend on a single lock
+const channelStateShards = 16
+
 func newChannelState(g *gossipService) *channelState {
-	return &channelState{
+	cs := &channelState{
 		stopping: int32(0),
-		channels: make(map[string]channel.Channel),
 		g:        g,
 	}
+	for i := range cs.shards {
+		cs.shards[i].channels = make(map[string]channel.Channel)
+	}
+	return cs
 }
 
 type channelState struct {
 	stopping int32
+	shards   [channelStateShards]channelShard
+	g        *gossipService
+}
+
+// channelShard holds the channels indexed by their hex encoded MAC
+type channelShard struct {
 	sync.RWMutex
 	channels map[string]channel.Channel
-	g        *gossipService
+}
+
+// shard returns the shard holding the channel with the given MAC
+func (cs *channelState) shard(chainMac common.ChainMac) *channelShard {
+	return &cs.shards[stripe(chainMac, channelStateShards)]
+}
+
+// forEach invokes f with the channels, one shard at a time, until it returns false
+func (cs *channelState) forEach(f func(key string, gc channel.Channel) bool) {
+	for i := range cs.shards {
+		shard := &cs.shards[i]
+		shard.RLock()
+		for key, gc := range shard.channels {
+			if !f(key, gc) {
+				shard.RUnlock()
+				return
+			}
+		}
+		shard.RUnlock()
+	}
 }
 
 func (cs *channelState) stop() {
-	logging.Info("Stopping channelState")
-	defer logging.Info("Stopped channelState")
+	cs.g.logger.Info("Stopping channelState")
+	defer cs.g.logger.Info("Stopped channelState")
 	if cs.isStopping() {
 		return
 	}
 
 	atomic.StoreInt32(&cs.stopping, int32(1))
-	cs.Lock()
-	defer cs.Unlock()
-	for _, gc := range cs.channels {
-		gc.Stop()
+	for i := range cs.shards {
+		shard := &cs.shards[i]
+		shard.Lock()
+		for _, gc := range shard.channels {
+			gc.Stop()
+		}
+		shard.Unlock()
 	}
 }
 
@@ -56,86 +91,140 @@ func (cs *channelState) isStopping() bool {
 	return atomic.LoadInt32(&cs.stopping) == int32(1)
 }
 
-func (cs *channelState) lookupChannelForMsg(msg protos.ReceivedMessage) channel.Channel {
+// transferring returns whether a file of any channel is being transferred
+func (cs *channelState) transferring() bool {
+	if cs.isStopping() {
+		return false
+	}
+
+	transferring := false
+	cs.forEach(func(_ string, gc channel.Channel) bool {
+		transferring = gc.Transferring()
+		return !transferring
+	})
+	return transferring
+}
+
+// snapshot returns the channels indexed by their hex encoded MAC
+func (cs *channelState) snapshot() map[string]channel.Channel {
+	if cs.isStopping() {
+		return nil
+	}
+
+	channels := make(map[string]channel.Channel)
+	cs.forEach(func(key string, gc channel.Channel) bool {
+		channels[key] = gc
+		return true
+	})
+	return channels
+}
+
+// uninitializedChannel returns the MAC of a channel without chain state, if any
+func (cs *channelState) uninitializedChannel() common.ChainMac {
 	if cs.isStopping() {
 		return nil
 	}
-	cs.RLock()
-	defer cs.RUnlock()
 
-	m := msg.GetRKSyncMessage()
-	mac := common.ChainMac(m.ChainMac)
-	return cs.channels[mac.String()]
+	var mac common.ChainMac
+	cs.forEach(func(key string, gc channel.Channel) bool {
+		if gc.Self() == nil {
+			mac, _ = hex.DecodeString(key)
+			return false
+		}
+		return true
+	})
+	return mac
+}
+
+func (cs *channelState) lookupChannelForMsg(msg protos.ReceivedMessage) channel.Channel {
+	return cs.getChannelByMAC(msg.GetRKSyncMessage().ChainMac)
 }
 
 func (cs *channelState) getChannelByMAC(chainMac common.ChainMac) channel.Channel {
 	if cs.isStopping() {
 		return nil
 	}
-	cs.RLock()
-	defer cs.RUnlock()
+	shard := cs.shard(chainMac)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	return cs.channels[chainMac.String()]
+	return shard.channels[chainMac.String()]
 }
 
 func (cs *channelState) getChannelByChainID(chainID string) channel.Channel {
 	if cs.isStopping() {
 		return nil
 	}
-	cs.RLock()
-	defer cs.RUnlock()
 
-	for key, gc := range cs.channels {
+	var found channel.Channel
+	cs.forEach(func(key string, gc channel.Channel) bool {
 		chainState := gc.Self()
 		chainInfo, err := chainState.GetChainStateInfo()
 		if err != nil {
-			logging.Warningf("Failed getting ChainStateInfo message: %s", err)
-			continue
+			cs.g.logger.Warningf("Failed getting ChainStateInfo message: %s", err)
+			return true
 		}
 
 		mac := channel.GenerateMAC(chainInfo.Leader, chainID)
 		expectedMac, _ := hex.DecodeString(key)
 
 		if bytes.Equal(mac, expectedMac) {
-			return gc
+			found = gc
+			return false
 		}
-	}
-
-	return nil
+		return true
+	})
+	return found
 }
 
 func (cs *channelState) closeChannel(chainMac common.ChainMac) bool {
 	if cs.isStopping() {
 		return false
 	}
-	cs.Lock()
-	defer cs.Unlock()
+	shard := cs.shard(chainMac)
+	shard.Lock()
+	defer shard.Unlock()
 
-	gc, exists := cs.channels[chainMac.String()]
+	gc, exists := shard.channels[chainMac.String()]
 	if exists {
-		gc.Stop()
-		delete(cs.channels, chainMac.String())
+		gc.Close()
+		delete(shard.channels, chainMac.String())
 		return true
 	}
 
 	return false
 }
 
-func (cs *channelState) joinChannel(chainMac common.ChainMac, chainID string, leader bool) channel.Channel {
+// joinChannel returns the channel with the given MAC, and whether it was joined by the call
+func (cs *channelState) joinChannel(chainMac common.ChainMac, chainID string, leader bool) (channel.Channel, bool) {
 	if cs.isStopping() {
-		return nil
+		return nil, false
 	}
-	cs.Lock()
-	defer cs.Unlock()
+	shard := cs.shard(chainMac)
+	shard.Lock()
+	defer shard.Unlock()
 
-	gc, exists := cs.channels[chainMac.String()]
+	gc, exists := shard.channels[chainMac.String()]
 	if !exists {
 		pkiID := cs.g.selfPKIid
 		ga := &gossipAdapterImpl{gossipService: cs.g, Discovery: cs.g.disc}
-		gc = channel.NewGossipChannel(pkiID, chainMac, chainID, leader, ga, cs.g.idMapper)
-		cs.channels[chainMac.String()] = gc
+		gc = channel.NewGossipChannel(pkiID, chainMac, chainID, leader, ga, cs.g.idMapper, cs.g.moduleLogger("channel"))
+		shard.channels[chainMac.String()] = gc
+		cs.g.EmitEvent(common.ChannelJoined{ChainMac: chainMac, ChainID: chainID, Leader: leader})
+	}
+	return gc, !exists
+}
+
+// discardChannel stops and forgets a channel which failed to initialize, its files aren't orphaned
+func (cs *channelState) discardChannel(chainMac common.ChainMac, gc channel.Channel) {
+	shard := cs.shard(chainMac)
+	shard.Lock()
+	defer shard.Unlock()
+
+	if shard.channels[chainMac.String()] == gc {
+		gc.Stop()
+		delete(shard.channels, chainMac.String())
 	}
-	return gc
 }
 
 type gossipAdapterImpl struct {
@@ -144,28 +233,41 @@ type gossipAdapterImpl struct {
 }
 
 func (ga *gossipAdapterImpl) GetChannelConfig() channel.Config {
+	conf := ga.getConf()
 	return channel.Config{
-		FileSystem:                  ga.conf.FileSystem,
-		PublishStateInfoInterval:    ga.conf.PublishStateInfoInterval,
-		PullPeerNum:                 ga.conf.PullPeerNum,
-		PullInterval:                ga.conf.PullInterval,
-		RequestStateInfoInterval:    ga.conf.RequestStateInfoInterval,
-		StateInfoCacheSweepInterval: ga.conf.PullInterval * 5,
+		FileSystem:                  conf.FileSystem,
+		FileSystemFactory:           conf.FileSystemFactory,
+		PublishStateInfoInterval:    conf.PublishStateInfoInterval,
+		PullPeerNum:                 conf.PullPeerNum,
+		PullInterval:                conf.PullInterval,
+		RequestStateInfoInterval:    conf.RequestStateInfoInterval,
+		LeaderTimeout:               conf.LeaderTimeout,
+		StateInfoCacheSweepInterval: conf.PullInterval * 5,
+		MaxMembers:                  conf.MaxChannelMembers,
+		MaxFiles:                    conf.MaxChannelFiles,
+		MaxStateSize:                conf.MaxChainStateSize,
+		MaxMessageSize:              conf.MaxMessageSize,
+		Zone:                        conf.Zone,
+		StorageQuota:                conf.ChannelQuota,
+		StorageQuotas:               conf.ChannelQuotas,
+		ChangeLogDir:                conf.ChangeLogDir,
+		JournalDir:                  conf.JournalDir,
+		Propagations:                conf.ChannelPropagation,
+		Scheduler:                   ga.transfers,
+		Clock:                       ga.clock,
 	}
 }
 
-func (ga *gossipAdapterImpl) Gossip(msg *protos.SignedRKSyncMessage) {
-	ga.gossipService.emitter.Add(&emittedRKSyncMessage{
-		SignedRKSyncMessage: msg,
-		filter:              func(_ common.PKIidType) bool { return true },
-	})
+func (ga *gossipAdapterImpl) GetMembership() []common.NetworkMember {
+	return ga.membership()
 }
 
-func (ga *gossipAdapterImpl) Forward(msg protos.ReceivedMessage) {
-	ga.gossipService.emitter.Add(&emittedRKSyncMessage{
-		SignedRKSyncMessage: msg.GetRKSyncMessage(),
-		filter:              msg.GetConnectionInfo().ID.IsNotSameFilter,
-	})
+func (ga *gossipAdapterImpl) Gossip(msg *protos.SignedRKSyncMessage, propagation config.Propagation) {
+	ga.gossipService.gossipWith(msg, propagation)
+}
+
+func (ga *gossipAdapterImpl) Forward(msg protos.ReceivedMessage, propagation config.Propagation) {
+	ga.gossipService.forwardWith(msg, propagation)
 }
 
 func (ga *gossipAdapterImpl) Send(msg *protos.SignedRKSyncMessage, peers ...*common.NetworkMember) {
@@ -179,3 +281,15 @@ func (ga *gossipAdapterImpl) SendWithAck(msg *protos.SignedRKSyncMessage, timeou
 	}
 	return nil
 }
+
+func (ga *gossipAdapterImpl) ConnectToAnchorPeer(endpoint string) {
+	if ga.getConf().StaticTopology {
+		ga.logger.Debugf("Ignoring anchor peer %s in a static topology", endpoint)
+		return
+	}
+	ga.gossipService.connect2Peer(endpoint)
+}
+
+func (ga *gossipAdapterImpl) OrphanFiles(chainID string, files []config.FileMeta) {
+	ga.orphans.add(chainID, files)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putChannel registers the channel under the given MAC bypassing joinChannel, or removes it if it is nil
+func (cs *channelState) putChannel(chainMac common.ChainMac, gc channel.Channel) {
+	shard := cs.shard(chainMac)
+	shard.Lock()
+	defer shard.Unlock()
+	if gc == nil {
+		delete(shard.channels, chainMac.String())
+		return
+	}
+	shard.channels[chainMac.String()] = gc
+}
+
+func chainStateMsg(t testing.TB, chainMac common.ChainMac) protos.ReceivedMessage {
+	msg, err := (&protos.RKSyncMessage{
+		ChainMac: chainMac,
+		Content:  &protos.RKSyncMessage_State{State: &protos.ChainState{}},
+	}).NoopSign()
+	require.NoError(t, err)
+	return &receivedMessageMock{msg: msg, sender: common.PKIidType("sender")}
+}
+
+func TestChannelStateShards(t *testing.T) {
+	cs := newChannelState(nil)
+	for i := 0; i < 100; i++ {
+		cs.putChannel(common.ChainMac(fmt.Sprintf("mac%d", i)), &channelMock{state: &protos.ChainState{SeqNum: uint64(i)}})
+	}
+
+	// The channels are spread over the shards
+	used := 0
+	for i := range cs.shards {
+		if len(cs.shards[i].channels) > 0 {
+			used++
+		}
+	}
+	assert.True(t, used > channelStateShards/2)
+
+	assert.Len(t, cs.snapshot(), 100)
+	gc := cs.lookupChannelForMsg(chainStateMsg(t, common.ChainMac("mac42")))
+	require.NotNil(t, gc)
+	assert.Equal(t, uint64(42), gc.Self().SeqNum)
+	assert.Nil(t, cs.lookupChannelForMsg(chainStateMsg(t, common.ChainMac("unknown"))))
+
+	cs.putChannel(common.ChainMac("mac42"), nil)
+	assert.Nil(t, cs.getChannelByMAC(common.ChainMac("mac42")))
+	assert.Len(t, cs.snapshot(), 99)
+}
+
+// singleLockChannels looks up the channels under a single lock, as a baseline of the sharded channelState
+type singleLockChannels struct {
+	sync.RWMutex
+	channels map[string]channel.Channel
+}
+
+func (s *singleLockChannels) lookupChannelForMsg(msg protos.ReceivedMessage) channel.Channel {
+	s.RLock()
+	defer s.RUnlock()
+	return s.channels[common.ChainMac(msg.GetRKSyncMessage().ChainMac).String()]
+}
+
+// BenchmarkLookupChannelForMsg measures the lookups of the channels of the received messages
+// by concurrent handlers, e.g. go test -bench LookupChannelForMsg -cpu 1,8,32 ./gossip
+func BenchmarkLookupChannelForMsg(b *testing.B) {
+	const channels = 256
+	cs := newChannelState(nil)
+	baseline := &singleLockChannels{channels: make(map[string]channel.Channel)}
+	msgs := make([]protos.ReceivedMessage, channels)
+	for i := range msgs {
+		mac := common.ChainMac(fmt.Sprintf("mac%d", i))
+		gc := &channelMock{state: &protos.ChainState{}}
+		cs.putChannel(mac, gc)
+		baseline.channels[mac.String()] = gc
+		msgs[i] = chainStateMsg(b, mac)
+	}
+
+	run := func(lookup func(protos.ReceivedMessage) channel.Channel) func(b *testing.B) {
+		return func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if lookup(msgs[i%channels]) == nil {
+						b.Fatal("channel not found")
+					}
+					i++
+				}
+			})
+		}
+	}
+	b.Run("Sharded", run(cs.lookupChannelForMsg))
+	b.Run("SingleLock", run(baseline.lookupChannelForMsg))
+}
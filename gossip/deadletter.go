@@ -0,0 +1,81 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	deadLetterWindow  = time.Second
+	defDeadLetterRate = 10
+)
+
+// deadLetterBox reports discarded messages to the application,
+// at most rate messages are reported per second, the others are counted
+// and summarized once the window elapses.
+type deadLetterBox struct {
+	sync.Mutex
+	handler     config.DeadLetterHandler
+	rate        int
+	windowStart time.Time
+	reported    int
+	suppressed  int
+	logger      logging.Logger
+}
+
+func newDeadLetterBox(handler config.DeadLetterHandler, rate int, logger logging.Logger) *deadLetterBox {
+	if rate <= 0 {
+		rate = defDeadLetterRate
+	}
+	return &deadLetterBox{
+		handler: handler,
+		rate:    rate,
+		logger:  logger,
+	}
+}
+
+// report records that the message sent by the given peer has been discarded
+func (d *deadLetterBox) report(sender common.PKIidType, msg *protos.RKSyncMessage, reason string) {
+	if !d.allow(time.Now()) {
+		return
+	}
+
+	// the message itself isn't logged, it may carry the content of files or identities
+	d.logger.Warningf("Discarded %s message of %d bytes sent from %s: %s", msg.ContentType(), proto.Size(msg), sender, reason)
+	if d.handler != nil {
+		d.handler(config.DeadLetter{Sender: sender, Message: msg, Reason: reason})
+	}
+}
+
+func (d *deadLetterBox) allow(now time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	if now.Sub(d.windowStart) >= deadLetterWindow {
+		if d.suppressed > 0 {
+			d.logger.Warningf("%d discarded messages were not reported in the last %s", d.suppressed, now.Sub(d.windowStart))
+		}
+		d.windowStart = now
+		d.reported = 0
+		d.suppressed = 0
+	}
+
+	if d.reported >= d.rate {
+		d.suppressed++
+		return false
+	}
+	d.reported++
+	return true
+}
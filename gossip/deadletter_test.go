@@ -0,0 +1,78 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDeadLetterRateLimit(t *testing.T) {
+	var letters []config.DeadLetter
+	box := newDeadLetterBox(func(letter config.DeadLetter) {
+		letters = append(letters, letter)
+	}, 2, logging.Default())
+
+	msg := &protos.RKSyncMessage{Nonce: 1}
+	for i := 0; i < 5; i++ {
+		box.report(common.PKIidType("peer1"), msg, "Unknown message type")
+	}
+	assert.Len(t, letters, 2)
+	assert.Equal(t, common.PKIidType("peer1"), letters[0].Sender)
+	assert.Equal(t, msg, letters[0].Message)
+	assert.Equal(t, "Unknown message type", letters[0].Reason)
+	assert.Equal(t, 3, box.suppressed)
+
+	assert.True(t, box.allow(box.windowStart.Add(deadLetterWindow)))
+	assert.Equal(t, 0, box.suppressed)
+}
+
+func TestDeadLetterWithoutHandler(t *testing.T) {
+	box := newDeadLetterBox(nil, 1, logging.Default())
+	box.report(nil, &protos.RKSyncMessage{}, "Unknown message type")
+	assert.False(t, box.allow(time.Now()))
+}
+
+func TestDeadLetterDefaultRate(t *testing.T) {
+	box := newDeadLetterBox(nil, 0, logging.Default())
+	now := time.Now()
+	for i := 0; i < defDeadLetterRate; i++ {
+		assert.True(t, box.allow(now))
+	}
+	assert.False(t, box.allow(now))
+}
+
+type warningRecorder struct {
+	logging.Logger
+	warnings []string
+}
+
+func (w *warningRecorder) Warningf(format string, args ...interface{}) {
+	w.warnings = append(w.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestDeadLetterLogging(t *testing.T) {
+	logger := &warningRecorder{Logger: logging.Default()}
+	box := newDeadLetterBox(nil, 1, logger)
+	msg := &protos.RKSyncMessage{
+		Tag:     protos.RKSyncMessage_EMPTY,
+		Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{Payload: &protos.Payload{Data: []byte("secret")}}},
+	}
+	box.report(common.PKIidType("p1"), msg, "Unknown message type")
+	require.Len(t, logger.warnings, 1)
+	assert.Equal(t, fmt.Sprintf("Discarded data_msg message of %d bytes sent from 7031: Unknown message type", proto.Size(msg)), logger.warnings[0])
+	assert.NotContains(t, logger.warnings[0], "secret")
+}
@@ -0,0 +1,123 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// DedupStats holds the number of gossiped messages checked against
+// the deduplication cache, and how many of them were suppressed
+type DedupStats struct {
+	Received   uint64
+	Suppressed uint64
+}
+
+// SuppressionRate returns the ratio of suppressed messages to received ones
+func (s DedupStats) SuppressionRate() float64 {
+	if s.Received == 0 {
+		return 0
+	}
+	return float64(s.Suppressed) / float64(s.Received)
+}
+
+type seenDigest struct {
+	digest string
+	seen   time.Time
+}
+
+// dedupCache remembers the digests of the recently received messages,
+// at most size digests are kept, each of them for ttl.
+type dedupCache struct {
+	sync.Mutex
+	stats   DedupStats
+	size    int
+	ttl     time.Duration
+	digests map[string]*list.Element
+	order   *list.List
+}
+
+func newDedupCache(size int, ttl time.Duration) *dedupCache {
+	return &dedupCache{
+		size:    size,
+		ttl:     ttl,
+		digests: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// seenBefore returns whether the envelope was already received and recorded
+func (c *dedupCache) seenBefore(envp *protos.Envelope, now time.Time) bool {
+	atomic.AddUint64(&c.stats.Received, 1)
+	digest := envelopeDigest(envp)
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.purge(now)
+	if _, exists := c.digests[digest]; exists {
+		atomic.AddUint64(&c.stats.Suppressed, 1)
+		return true
+	}
+	return false
+}
+
+// record remembers the envelope, only the envelopes which passed the validation are recorded
+// so that an invalid copy can't make the valid ones be suppressed
+func (c *dedupCache) record(envp *protos.Envelope, now time.Time) {
+	digest := envelopeDigest(envp)
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.purge(now)
+	if _, exists := c.digests[digest]; exists {
+		return
+	}
+	c.digests[digest] = c.order.PushBack(&seenDigest{digest: digest, seen: now})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Front())
+	}
+}
+
+func (c *dedupCache) purge(now time.Time) {
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		if now.Sub(e.Value.(*seenDigest).seen) < c.ttl {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+func (c *dedupCache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.digests, e.Value.(*seenDigest).digest)
+}
+
+func (c *dedupCache) getStats() DedupStats {
+	return DedupStats{
+		Received:   atomic.LoadUint64(&c.stats.Received),
+		Suppressed: atomic.LoadUint64(&c.stats.Suppressed),
+	}
+}
+
+func envelopeDigest(envp *protos.Envelope) string {
+	h := sha256.New()
+	h.Write(envp.Payload)
+	h.Write(envp.Signature)
+	if envp.SecretEnvelope != nil {
+		h.Write(envp.SecretEnvelope.Payload)
+		h.Write(envp.SecretEnvelope.Signature)
+	}
+	return string(h.Sum(nil))
+}
@@ -0,0 +1,71 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupCache(t *testing.T) {
+	cache := newDedupCache(2, time.Minute)
+	now := time.Now()
+	receive := func(envp *protos.Envelope, now time.Time) bool {
+		if cache.seenBefore(envp, now) {
+			return true
+		}
+		cache.record(envp, now)
+		return false
+	}
+
+	envp1 := &protos.Envelope{Payload: []byte("payload1"), Signature: []byte("signature")}
+	envp2 := &protos.Envelope{Payload: []byte("payload2"), Signature: []byte("signature")}
+	envp3 := &protos.Envelope{Payload: []byte("payload3"), Signature: []byte("signature")}
+
+	assert.False(t, receive(envp1, now))
+	assert.True(t, receive(envp1, now))
+	assert.True(t, receive(&protos.Envelope{Payload: []byte("payload1"), Signature: []byte("signature")}, now))
+
+	// A different signature or secret makes a different message
+	assert.False(t, receive(&protos.Envelope{Payload: []byte("payload1"), Signature: []byte("other")}, now))
+	withSecret := &protos.Envelope{Payload: []byte("payload1"), Signature: []byte("signature"), SecretEnvelope: &protos.SecretEnvelope{Payload: []byte("secret")}}
+	assert.False(t, receive(withSecret, now))
+
+	stats := cache.getStats()
+	assert.Equal(t, uint64(5), stats.Received)
+	assert.Equal(t, uint64(2), stats.Suppressed)
+	assert.Equal(t, 0.4, stats.SuppressionRate())
+
+	// The oldest digests are evicted once the cache is full
+	assert.False(t, receive(envp2, now))
+	assert.False(t, receive(envp3, now))
+	assert.Len(t, cache.digests, 2)
+	assert.False(t, receive(envp1, now))
+
+	// Digests expire after the TTL
+	assert.True(t, receive(envp3, now.Add(time.Second)))
+	assert.False(t, receive(envp3, now.Add(time.Minute)))
+}
+
+func TestDedupUnrecorded(t *testing.T) {
+	cache := newDedupCache(2, time.Minute)
+	now := time.Now()
+	envp := &protos.Envelope{Payload: []byte("payload"), Signature: []byte("signature")}
+
+	// A message which failed the validation isn't recorded, its valid copies aren't suppressed
+	assert.False(t, cache.seenBefore(envp, now))
+	assert.False(t, cache.seenBefore(envp, now))
+	cache.record(envp, now)
+	assert.True(t, cache.seenBefore(envp, now))
+}
+
+func TestDedupStatsWithoutMessages(t *testing.T) {
+	assert.Zero(t, DedupStats{}.SuppressionRate())
+}
@@ -0,0 +1,81 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+
+	"github.com/rkcloudchain/rksync/common"
+)
+
+const eventChanSize = 100
+
+// eventBus dispatches the events of the gossip instance to its subscribers,
+// an event is dropped for a subscriber whose channel is full
+type eventBus struct {
+	sync.RWMutex
+	subscribers map[chan common.Event]struct{}
+	closed      bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan common.Event]struct{})}
+}
+
+// subscribe returns a channel receiving the events published from now on,
+// and a function cancelling the subscription and closing the channel
+func (b *eventBus) subscribe() (<-chan common.Event, func()) {
+	ch := make(chan common.Event, eventChanSize)
+
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.Lock()
+			defer b.Unlock()
+			if _, exists := b.subscribers[ch]; exists {
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// publish sends the event to the subscribers without blocking
+func (b *eventBus) publish(event common.Event) {
+	b.RLock()
+	defer b.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// close closes the channels of the subscribers
+func (b *eventBus) close() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subscribers {
+		close(ch)
+	}
+	b.subscribers = nil
+}
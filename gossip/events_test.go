@@ -0,0 +1,76 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	events1, cancel1 := bus.subscribe()
+	events2, cancel2 := bus.subscribe()
+	defer cancel2()
+
+	bus.publish(common.PeerDead{})
+	assert.Equal(t, common.PeerDead{}, <-events1)
+	assert.Equal(t, common.PeerDead{}, <-events2)
+
+	cancel1()
+	cancel1()
+	_, open := <-events1
+	assert.False(t, open)
+
+	// The events are dropped for a subscriber that doesn't keep up
+	for i := 0; i < eventChanSize+10; i++ {
+		bus.publish(common.ChannelJoined{ChainID: "testchannel"})
+	}
+	assert.Len(t, events2, eventChanSize)
+
+	bus.close()
+	for range events2 {
+	}
+	events3, cancel3 := bus.subscribe()
+	defer cancel3()
+	_, open = <-events3
+	assert.False(t, open)
+}
+
+func TestChannelEvents(t *testing.T) {
+	gossipSvc, err := CreateGossipServer([]string{"localhost:12061"}, "localhost:12061", 0)
+	require.NoError(t, err)
+
+	events, cancel := gossipSvc.Events()
+	defer cancel()
+
+	mac := channel.GenerateMAC(gossipSvc.SelfPKIid(), "testchannel")
+	_, err = gossipSvc.CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+
+	var received []common.Event
+	timeout := time.After(5 * time.Second)
+	for len(received) < 2 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-timeout:
+			require.Fail(t, "Didn't receive the channel events", "received %v", received)
+		}
+	}
+	assert.Contains(t, received, common.ChannelJoined{ChainMac: mac, ChainID: "testchannel", Leader: true})
+	assert.Contains(t, received, common.LeaderChanged{ChainMac: mac, ChainID: "testchannel", Leader: gossipSvc.SelfPKIid()})
+
+	gossipSvc.Stop()
+	for range events {
+	}
+}
@@ -7,19 +7,34 @@ SPDX-License-Identifier: Apache-2.0
 package gossip
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/channel/fsync"
 	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/tests/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestFileSync(t *testing.T) {
+	// Start from scratch, the files may have been synchronized by other tests
+	dir := filepath.Join(testDataDir, "peer1")
+	for _, name := range []string{"101.png", "config.yaml", "rfc2616.txt", "https-cert.pem", "https-key.pem"} {
+		os.Remove(filepath.Join(dir, name))
+	}
+
 	gossipSvc1, err := CreateGossipServer([]string{"localhost:9054"}, "localhost:9054", 0)
 	require.NoError(t, err)
 	defer gossipSvc1.Stop()
@@ -55,6 +70,41 @@ func TestFileSync(t *testing.T) {
 	assert.Equal(t, 5, len(chainStateInfo.Properties.Files))
 
 	time.Sleep(10 * time.Second)
+
+	_, err = gossipSvc2.ChannelFiles("unknownchannel")
+	assert.Error(t, err)
+
+	leaderFiles, err := gossipSvc1.ChannelFiles("testchannel")
+	require.NoError(t, err)
+	files, err := gossipSvc2.ChannelFiles("testchannel")
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+	require.Len(t, leaderFiles, 5)
+	assert.Equal(t, "101.png", files[0].Filename)
+	for i, f := range files {
+		assert.Equal(t, leaderFiles[i].Filename, f.Filename)
+		assert.Equal(t, leaderFiles[i].Size, f.ExpectedSize)
+		assert.Equal(t, f.ExpectedSize, f.Size)
+		assert.False(t, f.LastSynced.IsZero())
+		assert.Equal(t, channel.DigestUnknown, f.Digest)
+	}
+
+	// The member acknowledged the files it synchronized to the leader
+	status, err := gossipSvc1.ChannelSyncStatus("testchannel")
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	assert.Equal(t, gossipSvc2.SelfPKIid(), status[0].Member)
+	assert.True(t, status[0].Complete)
+	assert.False(t, status[0].LastSeen.IsZero())
+	require.Len(t, status[0].Files, 5)
+	for i, f := range status[0].Files {
+		assert.Equal(t, leaderFiles[i].Filename, f.Filename)
+		assert.True(t, f.Complete)
+		assert.Zero(t, f.ByteLag)
+		assert.False(t, f.LastSeen.IsZero())
+	}
+	_, err = gossipSvc2.ChannelSyncStatus("testchannel")
+	assert.Equal(t, ErrNotLeader, errors.Cause(err))
 }
 
 func TestChainStateDynamicUpdate(t *testing.T) {
@@ -210,3 +260,361 @@ func TestFileMetadata(t *testing.T) {
 		assert.Equal(t, filepath.Ext(f.Path), fmt.Sprintf(".%s", m.Type))
 	}
 }
+
+func TestPublishFileVersion(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:9059"}, "localhost:9059", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "config.yaml", Mode: "Append"},
+	})
+	require.NoError(t, err)
+
+	_, err = gossipSvc1.PublishFileVersion(mac, "unknown.txt")
+	assert.Error(t, err)
+
+	chainState, err := gossipSvc1.PublishFileVersion(mac, "config.yaml")
+	require.NoError(t, err)
+	msg, err := chainState.Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	file := msg.GetStateInfo().Properties.Files[0]
+	assert.Equal(t, uint64(1), file.Version)
+	assert.Len(t, file.ContentHash, 32)
+
+	chainState, err = gossipSvc1.PublishFileVersion(mac, "config.yaml")
+	require.NoError(t, err)
+	msg, err = chainState.Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), msg.GetStateInfo().Properties.Files[0].Version)
+
+	files, err := gossipSvc1.ChannelFiles("testchannel")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, uint64(1), files[0].Version)
+	assert.Equal(t, channel.DigestMatch, files[0].Digest)
+	assert.Equal(t, files[0].Size, files[0].ExpectedSize)
+	assert.True(t, files[0].LastSynced.IsZero())
+
+	versions, err := gossipSvc1.FileVersions("testchannel", "config.yaml")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, file.ContentHash, versions[0].ContentHash)
+	assert.NoError(t, gossipSvc1.PinFileVersion("testchannel", "config.yaml", 1))
+	assert.Error(t, gossipSvc1.PinFileVersion("testchannel", "config.yaml", 2))
+	assert.Error(t, gossipSvc1.UnpinFileVersion("unknownchannel", "config.yaml", 1))
+}
+
+// appendingFS appends the files it's asked to, the test doesn't need the appends to be atomic
+type appendingFS struct {
+	*mocks.FileSystemMock
+}
+
+func (fs *appendingFS) Append(chainID string, from, to config.FileMeta) error {
+	src, err := fs.OpenFile(chainID, from, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := fs.OpenFile(chainID, to, os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, io.NewSectionReader(src, 0, math.MaxInt64))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return fs.Remove(chainID, from)
+}
+
+func TestAppendFileRewritten(t *testing.T) {
+	t.Run("Rename", func(t *testing.T) {
+		testAppendFileRewritten(t, 12066, false)
+	})
+	t.Run("Append", func(t *testing.T) {
+		testAppendFileRewritten(t, 12090, true)
+	})
+}
+
+func testAppendFileRewritten(t *testing.T, port int, appending bool) {
+	dir := testDataDir
+	leaderFile := filepath.Join(dir, "peer0", "rewritten.log")
+	followerFile := filepath.Join(dir, "peer2", "rewritten.log")
+	defer os.Remove(leaderFile)
+	defer os.Remove(followerFile)
+	require.NoError(t, os.WriteFile(leaderFile, bytes.Repeat([]byte("a"), 10000), 0644))
+	modTime := time.Date(2019, time.June, 25, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(leaderFile, modTime, modTime))
+
+	leaderAddr := fmt.Sprintf("localhost:%d", port)
+	gossipSvc1, err := CreateGossipServer([]string{leaderAddr}, leaderAddr, 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := createGossipServerWithConfig([]string{leaderAddr}, fmt.Sprintf("localhost:%d", port+1), 2, func(c *config.GossipConfig) {
+		if appending {
+			c.FileSystem = &appendingFS{c.FileSystem.(*mocks.FileSystemMock)}
+		}
+	})
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "rewritten.log", Mode: "Append", Perm: 0750, Attributes: map[string]string{"owner": "ops"}},
+	})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	synced := func() bool {
+		expected, err := os.ReadFile(leaderFile)
+		require.NoError(t, err)
+		actual, err := os.ReadFile(followerFile)
+		return err == nil && bytes.Equal(expected, actual)
+	}
+	assert.Eventually(t, synced, 20*time.Second, 500*time.Millisecond)
+
+	// The attributes of the leader's copy are preserved
+	fi, err := os.Stat(followerFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	assert.True(t, modTime.Equal(fi.ModTime()))
+	chainState := gossipSvc2.SelfChainInfo("testchannel")
+	require.NotNil(t, chainState)
+	msg, err := chainState.Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	attrs := msg.GetStateInfo().Properties.Files[0].Attributes
+	require.Len(t, attrs, 1)
+	assert.Equal(t, "owner", attrs[0].Key)
+	assert.Equal(t, "ops", attrs[0].Value)
+
+	// Truncated below the size of the follower's copy
+	require.NoError(t, os.WriteFile(leaderFile, bytes.Repeat([]byte("b"), 5000), 0644))
+	assert.Eventually(t, synced, 20*time.Second, 500*time.Millisecond)
+
+	// Rewritten beyond the size of the follower's copy
+	require.NoError(t, os.WriteFile(leaderFile, bytes.Repeat([]byte("c"), 20000), 0644))
+	assert.Eventually(t, synced, 20*time.Second, 500*time.Millisecond)
+
+	// Appended to
+	before, err := os.Stat(followerFile)
+	require.NoError(t, err)
+	f, err := os.OpenFile(leaderFile, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write(bytes.Repeat([]byte("d"), 100))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Eventually(t, synced, 20*time.Second, 500*time.Millisecond)
+
+	// The data is appended to the follower's copy if its FileSystem appends files atomically,
+	// otherwise the follower's copy is replaced by a copy of it along with the data
+	after, err := os.Stat(followerFile)
+	require.NoError(t, err)
+	assert.Equal(t, appending, os.SameFile(before, after))
+	_, err = os.Stat(followerFile + ".rksync-tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStorageQuota(t *testing.T) {
+	dir := testDataDir
+	leaderFile := filepath.Join(dir, "peer0", "quota.log")
+	followerFile := filepath.Join(dir, "peer2", "quota.log")
+	defer os.Remove(leaderFile)
+	defer os.Remove(followerFile)
+	require.NoError(t, os.WriteFile(leaderFile, bytes.Repeat([]byte("a"), 10000), 0644))
+
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12068"}, "localhost:12068", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12068"}, "localhost:12069", 2)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+	gossipSvc2.(*gossipService).conf.ChannelQuotas = map[string]int64{"testchannel": 1000}
+
+	leaderEvents, cancel := gossipSvc1.Events()
+	defer cancel()
+	followerEvents, cancel := gossipSvc2.Events()
+	defer cancel()
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "quota.log", Mode: "Append"},
+	})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	var storageErr *fsync.StorageError
+	timeout := time.After(20 * time.Second)
+	for storageErr == nil {
+		select {
+		case e := <-followerEvents:
+			if failed, ok := e.(common.FileSyncFailed); ok {
+				storageErr, _ = failed.Err.(*fsync.StorageError)
+			}
+		case <-timeout:
+			require.FailNow(t, "The follower didn't reject the transfer")
+		}
+	}
+	assert.Equal(t, protos.TransferRejection_Quota, storageErr.Limit)
+	assert.Equal(t, int64(10000), storageErr.Required)
+	assert.Equal(t, int64(1000), storageErr.Available)
+
+	var rejected *common.TransferRejected
+	for rejected == nil {
+		select {
+		case e := <-leaderEvents:
+			if r, ok := e.(common.TransferRejected); ok {
+				rejected = &r
+			}
+		case <-timeout:
+			require.FailNow(t, "The leader wasn't notified of the rejection")
+		}
+	}
+	assert.Equal(t, "quota.log", rejected.Filename)
+	assert.Equal(t, gossipSvc2.SelfPKIid(), rejected.PKIID)
+	assert.IsType(t, &fsync.StorageError{}, rejected.Err)
+
+	// Nothing was written on the follower
+	fi, err := os.Stat(followerFile)
+	if err == nil {
+		assert.Equal(t, int64(0), fi.Size())
+	}
+	_, err = os.Stat(followerFile + ".rksync-tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOrphanedFiles(t *testing.T) {
+	dir := testDataDir
+	for _, name := range []string{"orphan1.log", "orphan2.log"} {
+		leaderFile := filepath.Join(dir, "peer0", name)
+		defer os.Remove(leaderFile)
+		defer os.Remove(filepath.Join(dir, "peer2", name))
+		require.NoError(t, os.WriteFile(leaderFile, []byte(name), 0644))
+	}
+
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12070"}, "localhost:12070", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12070"}, "localhost:12071", 2)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+	conf := gossipSvc2.(*gossipService).conf
+	conf.OrphanPolicy = config.OrphanDelete
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		&common.FileSyncInfo{Path: "orphan1.log", Mode: "Append"},
+		&common.FileSyncInfo{Path: "orphan2.log", Mode: "Append"},
+	})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	exists := func(name string) func() bool {
+		return func() bool {
+			_, err := os.Stat(filepath.Join(dir, "peer2", name))
+			return err == nil
+		}
+	}
+	require.Eventually(t, exists("orphan1.log"), 20*time.Second, 500*time.Millisecond)
+	require.Eventually(t, exists("orphan2.log"), 20*time.Second, 500*time.Millisecond)
+
+	// Deleted as soon as it is removed from the channel
+	_, err = gossipSvc1.RemoveFileWithChain(mac, []string{"orphan1.log"})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return !exists("orphan1.log")() }, 20*time.Second, 500*time.Millisecond)
+	assert.True(t, exists("orphan2.log")())
+
+	// Retained once the channel is closed, until the retention elapses
+	conf.OrphanPolicy = config.OrphanRetain
+	conf.OrphanRetention = time.Hour
+	require.NoError(t, gossipSvc1.CloseChain(mac, true))
+	assert.Eventually(t, func() bool { return gossipSvc2.SelfChainInfo("testchannel") == nil }, 20*time.Second, 500*time.Millisecond)
+
+	orphans := gossipSvc2.(*gossipService).orphans
+	orphans.sweep(time.Now())
+	assert.True(t, exists("orphan2.log")())
+	orphans.sweep(time.Now().Add(2 * time.Hour))
+	assert.False(t, exists("orphan2.log")())
+
+	// The leader's copies are never deleted
+	_, err = os.Stat(filepath.Join(dir, "peer0", "orphan2.log"))
+	assert.NoError(t, err)
+}
+
+func TestTransferScheduler(t *testing.T) {
+	dir := testDataDir
+	names := []string{"sched1.log", "sched2.log", "sched3.log"}
+	for i, name := range names {
+		defer os.Remove(filepath.Join(dir, "peer0", name))
+		defer os.Remove(filepath.Join(dir, "peer2", name))
+		os.Remove(filepath.Join(dir, "peer2", name))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "peer0", name), bytes.Repeat([]byte{byte('a' + i)}, 200000), 0644))
+	}
+
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12078"}, "localhost:12078", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+	gossipSvc1.(*gossipService).conf.MaxMessageSize = 32 * 1024
+
+	// The member receives one file at a time, in blocks smaller than the files
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12078"}, "localhost:12079", 2)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+	gossipSvc2.(*gossipService).conf.MaxMessageSize = 32 * 1024
+	scheduler := fsync.NewScheduler(1)
+	gossipSvc2.(*gossipService).transfers = scheduler
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{
+		{Path: names[0], Mode: "Append"},
+		{Path: names[1], Mode: "Append", Priority: 1},
+		{Path: names[2], Mode: "Append"},
+	})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	timeout := time.After(30 * time.Second)
+	for {
+		active, _ := scheduler.Stats()
+		assert.True(t, active <= 1)
+
+		synced := 0
+		if files, err := gossipSvc2.ChannelFiles("testchannel"); err == nil {
+			for _, file := range files {
+				if file.Size == 200000 {
+					synced++
+				}
+			}
+		}
+		if synced == len(names) {
+			break
+		}
+		select {
+		case <-timeout:
+			require.FailNow(t, "The files weren't synchronized")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// The slots are freed once the files are synchronized
+	active, waiting := scheduler.Stats()
+	assert.Equal(t, 0, active)
+	assert.Equal(t, 0, waiting)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, "peer2", name))
+		require.NoError(t, err)
+		expected, err := os.ReadFile(filepath.Join(dir, "peer0", name))
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(expected, content))
+	}
+}
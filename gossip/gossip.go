@@ -7,19 +7,35 @@ SPDX-License-Identifier: Apache-2.0
 package gossip
 
 import (
+	"context"
 	"crypto/x509"
+	"io"
 
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
 	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/filter"
+	"github.com/rkcloudchain/rksync/identity"
 	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/rpc"
+	"google.golang.org/grpc"
 )
 
-// Portable analogs of some common channel call errors
+// Portable analogs of some common channel call errors. They may be wrapped
+// with some context, compare errors.Cause(err) with them.
 var (
-	ErrChannelExist    = errors.New("channel already exists")
+	ErrChannelExists   = errors.New("channel already exists")
 	ErrChannelNotExist = errors.New("channel does not exist")
+	ErrNotLeader       = channel.ErrNotLeader
+	ErrNotMember       = channel.ErrNotMember
+	ErrStopping        = errors.New("RKSync service is stopping")
+	ErrDraining        = errors.New("RKSync service is draining")
+	ErrNoInvitation    = errors.New("no pending invitation to the channel")
+	ErrPeerNotAlive    = errors.New("peer is not alive")
+
+	// Deprecated: use ErrChannelExists
+	ErrChannelExist = ErrChannelExists
 )
 
 type channelRoutingFilterFactory func(channel.Channel) filter.RoutingFilter
@@ -44,6 +60,12 @@ type Gossip interface {
 	// RemoveFileWithChain removes file contained in the channel
 	RemoveFileWithChain(chainMac common.ChainMac, filenames []string) (*protos.ChainState, error)
 
+	// PublishFileVersion publishes the current content of the file as a new version
+	PublishFileVersion(chainMac common.ChainMac, filename string) (*protos.ChainState, error)
+
+	// SetAnchorPeers replaces the anchor peers of the channel
+	SetAnchorPeers(chainMac common.ChainMac, endpoints []string) (*protos.ChainState, error)
+
 	// GetPKIidOfCert returns the PKI-ID of a certificate
 	GetPKIidOfCert(nodeID string, cert *x509.Certificate) (common.PKIidType, error)
 
@@ -53,19 +75,150 @@ type Gossip interface {
 	// CreateChain creates a channel
 	CreateChain(chainMac common.ChainMac, chainID string, files []*common.FileSyncInfo) (*protos.ChainState, error)
 
+	// InviteMember invites the peer to become a member of the channel led by this peer, it's added
+	// to the channel once it accepted the invitation. An InvitationAnswered event is emitted when it answers.
+	InviteMember(chainMac common.ChainMac, member common.PKIidType) (*Invitation, error)
+
+	// Invitations returns the invitations to join channels received by the peer and not answered yet
+	Invitations() []Invitation
+
+	// AnswerInvitation accepts or declines the invitation to join the channel. With RequireInvitation,
+	// the peer only joins the channels whose invitation it accepted.
+	AnswerInvitation(chainMac common.ChainMac, accept bool) error
+
 	// CloseChain closes a channel
 	CloseChain(chainMac common.ChainMac, notify bool) error
 
 	// CreateLeaveChainMessage creates LeaveChainMessage for channel
 	CreateLeaveChainMessage(chainMac common.ChainMac) (*protos.SignedRKSyncMessage, error)
 
+	// Channels returns the summaries of the channels the peer takes part in, sorted by channel ID
+	Channels() []ChannelSummary
+
+	// ChannelFiles returns the files of the channel sorted by name, along with the synchronization state of their local copy
+	ChannelFiles(chainID string) ([]channel.FileState, error)
+
+	// ChannelSyncStatus returns, on the leader, the synchronization state of the files of the channel on every other member,
+	// as acknowledged by the members, sorted by member
+	ChannelSyncStatus(chainID string) ([]MemberSyncStatus, error)
+
+	// FileVersions returns the versions of a file of the channel observed by the peer, oldest first
+	FileVersions(chainID, filename string) ([]channel.FileVersion, error)
+
+	// PinFileVersion keeps a version of a file of the channel in its history, e.g. for audit purposes
+	PinFileVersion(chainID, filename string, version uint64) error
+
+	// UnpinFileVersion lets a pinned version of a file of the channel be discarded from its history
+	UnpinFileVersion(chainID, filename string, version uint64) error
+
+	// ChangeLog returns the leader-signed records of the membership and file changes of the channel, oldest first
+	ChangeLog(chainID string) ([]channel.Change, error)
+
+	// VerifyChangeLog verifies that the change log of the channel is signed by the leader and leads to its current chain state
+	VerifyChangeLog(chainID string) error
+
+	// SetChannelPropagation overrides the fan-out of the chain states of the channel, zero values keep the gossip settings
+	SetChannelPropagation(chainID string, propagation config.Propagation) error
+
+	// ExportChannelSnapshot writes the signed chain state of the channel and the local copies of its files to w,
+	// it fails with ErrNotLeader unless the peer leads the channel
+	ExportChannelSnapshot(chainID string, w io.Writer) error
+
+	// ImportChannelSnapshot joins the channel of a snapshot, seeding the local copies of its files with its content
+	ImportChannelSnapshot(r io.Reader) (*protos.ChainState, error)
+
 	// GetPeers returns the NetworkMembers considered alive
 	Peers() []common.NetworkMember
 
+	// DroppedMessages returns the number of messages dropped because the send buffer of a peer,
+	// or a subscriber of the received messages, was full
+	DroppedMessages() rpc.DropCounts
+
+	// DedupStats returns how many gossiped messages were suppressed as duplicates
+	DedupStats() DedupStats
+
+	// ConnectionStats returns the connections established with the remote peers along with their traffic,
+	// last activity, round-trip time and TLS session
+	ConnectionStats() []rpc.ConnectionStats
+
+	// QueueDepths returns the number of messages waiting in the queues of the peer: the messages
+	// to forward in the emitter, the received messages waiting for a worker and for each subscriber,
+	// and the messages to send to each peer
+	QueueDepths() []rpc.QueueDepth
+
+	// RegisterMessageValidator adds a rule the messages received from the peers must satisfy, e.g.
+	// a file name policy. The messages it rejects are discarded, reported as dead letters and counted.
+	// A rule registered under the same name is replaced.
+	RegisterMessageValidator(rule string, validator MessageValidator)
+
+	// RejectedMessages returns the number of messages rejected by each registered rule, by rule name
+	RejectedMessages() map[string]uint64
+
+	// IdentityStats returns the number of peer identities held and purged
+	IdentityStats() identity.CacheStats
+
+	// AllocStats returns the heap allocations of the process and the use of the buffer pools
+	AllocStats() AllocStats
+
+	// PeerScores returns the reputation of the peers which misbehaved recently, lowest score first
+	PeerScores() []PeerScore
+
+	// ReportMisbehavior lowers the reputation of the peer, peers with a low score are
+	// deprioritized, and disconnected once their score falls to 0
+	ReportMisbehavior(pkiID common.PKIidType, kind common.Misbehavior)
+
+	// BlockPeer refuses the connections and the messages of the peer with the given hex encoded
+	// PKI-ID or node ID, closes its connections and excludes it from the membership
+	BlockPeer(id string)
+
+	// UnblockPeer accepts the peer with the given hex encoded PKI-ID or node ID again
+	UnblockPeer(id string)
+
+	// BlockedPeers returns the hex encoded PKI-IDs and node IDs of the blocked peers
+	BlockedPeers() []string
+
+	// SuspectPeers revalidates the identities of the peers the predicate selects against the
+	// current CA certificates and CRLs, e.g. after a CA compromise or a root rotation, and purges
+	// those no longer valid, closing their connections
+	SuspectPeers(isSuspected func(common.PeerIdentityType) bool)
+
+	// ApplyConfig changes the given configuration parameters at runtime
+	ApplyConfig(update *config.GossipConfigUpdate) error
+
+	// RegisterAdminServer registers the admin service on the gRPC server,
+	// the channel operations are delegated to the given ChannelAdmin
+	RegisterAdminServer(s *grpc.Server, channels ChannelAdmin)
+
+	// SendToGroup sends a message to all alive peers assigned the given tag.
+	// The tags are asserted by the peers themselves in their alive messages and
+	// aren't verified, so the group must not be trusted with confidential messages.
+	SendToGroup(tag string, msg *protos.SignedRKSyncMessage)
+
 	// Accept returns a dedicated read-only channel for messages sent by other nodes that match a certain predicate.
 	Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage)
 
-	// Stop the gossip component
+	// AcceptChannelMessages returns a channel receiving the decoded messages of the given types
+	// received in the channel, all of them if no type is given, and a function cancelling the subscription.
+	// Messages are dropped for a subscriber that doesn't keep up, the channel is closed when
+	// the subscription is cancelled or the instance stopped.
+	AcceptChannelMessages(chainID string, types ...ChannelMessageType) (<-chan *ChannelMessage, func(), error)
+
+	// Events returns a channel receiving the events of the instance, such as file
+	// synchronizations and membership changes, and a function cancelling the subscription.
+	// Events are dropped for a subscriber that doesn't keep up, the channel is closed when
+	// the subscription is cancelled or the instance stopped.
+	Events() (<-chan common.Event, func())
+
+	// Drain stops accepting new channel work, announces to the peers that
+	// this instance is leaving and waits for the file transfers in progress
+	// to finish, or the context to expire, before stopping the gossip component
+	Drain(ctx context.Context) error
+
+	// Done returns a channel closed once the gossip component starts stopping, e.g. so that the
+	// goroutines of the application consuming its channels exit along with it
+	Done() <-chan struct{}
+
+	// Stop the gossip component, it returns once the goroutines of the component have exited
 	Stop()
 }
 
@@ -73,5 +226,12 @@ type Gossip interface {
 // with routing filter to be used while message is forwarded
 type emittedRKSyncMessage struct {
 	*protos.SignedRKSyncMessage
-	filter func(id common.PKIidType) bool
+	filter      func(id common.PKIidType) bool
+	priority    common.MessagePriority
+	propagation config.Propagation
+}
+
+// emitIterations returns the number of times the message is pushed, 0 keeps the emitter's setting
+func (m *emittedRKSyncMessage) emitIterations() int {
+	return m.propagation.Iterations
 }
@@ -0,0 +1,54 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthServiceName is the service name the readiness of the gossip
+// component is reported under by the gRPC health service, the overall
+// server status ("") is set to the same status
+const HealthServiceName = "protos.RKSync"
+
+// checkReadiness returns an error if the discovery doesn't know enough
+// alive members, or a channel hasn't loaded its chain state yet
+func (g *gossipService) checkReadiness() error {
+	alive, minAlive := len(g.disc.GetMembership()), g.getConf().MinAlivePeers
+	if alive < minAlive {
+		return errors.Errorf("%d alive members, expected at least %d", alive, minAlive)
+	}
+	if chainMac := g.chanState.uninitializedChannel(); chainMac != nil {
+		return errors.Errorf("Channel %s hasn't loaded its state", chainMac)
+	}
+	return nil
+}
+
+func (g *gossipService) updateHealth() {
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	if err := g.checkReadiness(); err != nil {
+		g.logger.Debugf("RKSync gossip instance %s isn't ready: %s", g.id, err)
+		status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	g.health.SetServingStatus("", status)
+	g.health.SetServingStatus(HealthServiceName, status)
+}
+
+func (g *gossipService) periodicalCheckHealth() {
+	defer g.logger.Debug("Exiting")
+	defer g.loops.Done()
+
+	for {
+		g.updateHealth()
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-g.clock.After(g.getConf().HealthCheckInterval):
+		}
+	}
+}
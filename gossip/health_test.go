@@ -0,0 +1,72 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func waitForHealthStatus(t *testing.T, client grpc_health_v1.HealthClient, expected grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	var status grpc_health_v1.HealthCheckResponse_ServingStatus
+	for i := 0; i < 50; i++ {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: HealthServiceName})
+		if err == nil {
+			status = resp.Status
+			if status == expected {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(t, expected, status)
+}
+
+func TestHealthService(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12059"}, "localhost:12059", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	conn, err := grpc.Dial("localhost:12059", grpc.WithInsecure(), grpc.WithContextDialer(server.DialMemory))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+	waitForHealthStatus(t, client, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	// The peer isn't ready until it knows enough alive members
+	g1 := gossipSvc1.(*gossipService)
+	g1.confLock.Lock()
+	g1.conf.MinAlivePeers = 1
+	g1.confLock.Unlock()
+	waitForHealthStatus(t, client, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12059"}, "localhost:13059", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+	waitForHealthStatus(t, client, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// Nor while a channel hasn't loaded its state
+	chanMock := &channelMock{}
+	g1.chanState.putChannel(common.ChainMac{0x0a, 0x0b}, chanMock)
+	assert.Error(t, g1.checkReadiness())
+	waitForHealthStatus(t, client, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	g1.chanState.putChannel(common.ChainMac{0x0a, 0x0b}, nil)
+	waitForHealthStatus(t, client, grpc_health_v1.HealthCheckResponse_SERVING)
+}
@@ -0,0 +1,277 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/filter"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+const (
+	// maxRequestedIdentities is the max number of identities answered to a single request
+	maxRequestedIdentities = 100
+	// identityRequestInterval is the min time between two requests for the same identity
+	identityRequestInterval = 5 * time.Second
+	// pendingIdentityTTL is the time an identity is awaited after it was last requested
+	pendingIdentityTTL = time.Minute
+	// maxPendingIdentities is the max number of identities awaited at once
+	maxPendingIdentities = 1000
+	// identityRequestPeers is the number of neighbors asked for an identity the sender didn't answer
+	identityRequestPeers = 3
+)
+
+// identityRequests tracks the identities requested for the alive messages which can't be
+// verified, the last alive message of each peer is held until its identity arrives
+type identityRequests struct {
+	sync.Mutex
+	pending map[string]*pendingIdentity
+}
+
+type pendingIdentity struct {
+	requested time.Time
+	attempts  int
+	msg       protos.ReceivedMessage // nil if no alive message is held
+	asked     map[string]struct{}    // Peers asked for the identity
+}
+
+func newIdentityRequests() *identityRequests {
+	return &identityRequests{pending: make(map[string]*pendingIdentity)}
+}
+
+// await records that the identity of the peer is missing and holds its alive message if not nil.
+// It returns false if too many identities are awaited. The identity is due to be requested if it
+// wasn't for identityRequestInterval, attempts is then the number of the previous requests.
+func (r *identityRequests) await(pkiID common.PKIidType, msg protos.ReceivedMessage, now time.Time) (awaited bool, due bool, attempts int) {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, p := range r.pending {
+		if now.Sub(p.requested) > pendingIdentityTTL {
+			delete(r.pending, id)
+		}
+	}
+
+	p, exists := r.pending[string(pkiID)]
+	if !exists {
+		if len(r.pending) >= maxPendingIdentities {
+			return false, false, 0
+		}
+		p = &pendingIdentity{}
+		r.pending[string(pkiID)] = p
+	}
+	if msg != nil {
+		p.msg = msg
+	}
+	if exists && now.Sub(p.requested) < identityRequestInterval {
+		return true, false, p.attempts
+	}
+	attempts = p.attempts
+	p.requested = now
+	p.attempts++
+	return true, true, attempts
+}
+
+// asked records that the peer was asked for the identities
+func (r *identityRequests) asked(peer common.PKIidType, pkiIDs ...common.PKIidType) {
+	r.Lock()
+	defer r.Unlock()
+	for _, pkiID := range pkiIDs {
+		p, exists := r.pending[string(pkiID)]
+		if !exists {
+			continue
+		}
+		if p.asked == nil {
+			p.asked = make(map[string]struct{})
+		}
+		p.asked[string(peer)] = struct{}{}
+	}
+}
+
+// expects returns whether the identity of the peer is awaited from the sender, i.e. it was asked for it
+func (r *identityRequests) expects(pkiID, sender common.PKIidType) bool {
+	r.Lock()
+	defer r.Unlock()
+	p, exists := r.pending[string(pkiID)]
+	if !exists {
+		return false
+	}
+	_, asked := p.asked[string(sender)]
+	return asked
+}
+
+// resolve stops awaiting the identity of the peer, it returns its held alive message if any
+func (r *identityRequests) resolve(pkiID common.PKIidType) protos.ReceivedMessage {
+	r.Lock()
+	defer r.Unlock()
+	p, exists := r.pending[string(pkiID)]
+	if !exists {
+		return nil
+	}
+	delete(r.pending, string(pkiID))
+	return p.msg
+}
+
+// awaitIdentity holds the alive message if the identity of the peer which signed it is unknown,
+// and asks for it. It returns false if the alive message can be handed over to the discovery.
+func (g *gossipService) awaitIdentity(m protos.ReceivedMessage) bool {
+	am := m.GetRKSyncMessage().GetAliveMsg()
+	if g.secAdapter == nil || am.Membership == nil || len(am.Membership.PkiId) == 0 || am.Identity != nil {
+		return false
+	}
+	pkiID := common.PKIidType(am.Membership.PkiId)
+	if bytes.Equal(pkiID, g.selfPKIid) {
+		return false
+	}
+	if identity, _ := g.idMapper.Get(pkiID); identity != nil {
+		return false
+	}
+
+	awaited, due, attempts := g.identityRequests.await(pkiID, m, g.clock.Now())
+	if due {
+		// the sender verified the alive message before forwarding it, it holds the identity.
+		// If it didn't answer, some neighbors are asked as well.
+		g.requestIdentities(m, attempts > 0, pkiID)
+	}
+	return awaited
+}
+
+// requestMissingIdentities asks the peer which sent the membership response for the identities
+// of the alive peers it disclosed which are unknown, their next alive messages are verified then
+func (g *gossipService) requestMissingIdentities(m protos.ReceivedMessage) {
+	if g.secAdapter == nil {
+		return
+	}
+
+	var missing []common.PKIidType
+	now := g.clock.Now()
+	for _, envp := range m.GetRKSyncMessage().GetMemRes().Alive {
+		msg, err := envp.ToRKSyncMessage()
+		if err != nil || !msg.IsAliveMsg() {
+			continue
+		}
+		am := msg.GetAliveMsg()
+		if am.Membership == nil || len(am.Membership.PkiId) == 0 || am.Identity != nil || bytes.Equal(am.Membership.PkiId, g.selfPKIid) {
+			continue
+		}
+		pkiID := common.PKIidType(am.Membership.PkiId)
+		if identity, _ := g.idMapper.Get(pkiID); identity != nil {
+			continue
+		}
+		if _, due, _ := g.identityRequests.await(pkiID, nil, now); due {
+			missing = append(missing, pkiID)
+		}
+		if len(missing) == maxRequestedIdentities {
+			break
+		}
+	}
+	if len(missing) > 0 {
+		g.requestIdentities(m, false, missing...)
+	}
+}
+
+// requestIdentities asks the peer which sent the message for the identities, and some of the
+// neighbors too if askNeighbors is true. The peers speaking an older version of the protocol aren't asked.
+func (g *gossipService) requestIdentities(m protos.ReceivedMessage, askNeighbors bool, pkiIDs ...common.PKIidType) {
+	req := &protos.PeerIdentityRequest{PkiIds: make([][]byte, len(pkiIDs))}
+	for i, pkiID := range pkiIDs {
+		req.PkiIds[i] = pkiID
+	}
+	msg := &protos.RKSyncMessage{
+		Tag:     protos.RKSyncMessage_EMPTY,
+		Content: &protos.RKSyncMessage_IdentityReq{IdentityReq: req},
+	}
+
+	sender := m.GetConnectionInfo().ID
+	g.logger.Debugf("Requesting %d identities from %s", len(pkiIDs), sender)
+	if m.GetConnectionInfo().ProtocolVersion >= msg.ProtocolVersion() {
+		g.identityRequests.asked(sender, pkiIDs...)
+		m.Respond(msg)
+	}
+	if !askNeighbors {
+		return
+	}
+
+	neighbors := filter.SelectPeers(identityRequestPeers, g.membership(), func(member common.NetworkMember) bool {
+		return !bytes.Equal(member.PKIID, sender)
+	})
+	if len(neighbors) == 0 {
+		return
+	}
+	sMsg, err := msg.NoopSign()
+	if err != nil {
+		g.logger.Warningf("Failed creating identity request: %+v", err)
+		return
+	}
+	for _, neighbor := range neighbors {
+		g.identityRequests.asked(neighbor.PKIID, pkiIDs...)
+	}
+	g.srv.Send(sMsg, neighbors...)
+}
+
+// handleIdentityRequest answers the requested identities the peer holds, its own one included.
+// They aren't signed by the peer, the requester binds each of them to its PKI-ID.
+func (g *gossipService) handleIdentityRequest(m protos.ReceivedMessage) {
+	msg := m.GetRKSyncMessage()
+	res := &protos.PeerIdentityResponse{}
+	for _, pkiID := range msg.GetIdentityReq().PkiIds {
+		if len(res.Identities) == maxRequestedIdentities {
+			break
+		}
+
+		var identity common.PeerIdentityType
+		if bytes.Equal(pkiID, g.selfPKIid) {
+			identity = g.idMapper.SelfIdentity()
+			if g.secAdapter != nil {
+				g.secAdapter.identityRequested()
+			}
+		} else {
+			identity, _ = g.idMapper.Get(pkiID)
+		}
+		if identity == nil {
+			continue
+		}
+		res.Identities = append(res.Identities, &protos.PeerIdentity{PkiId: pkiID, Identity: identity})
+	}
+
+	g.logger.Debugf("Answering %d of the %d identities requested by %s", len(res.Identities), len(msg.GetIdentityReq().PkiIds), m.GetConnectionInfo().ID)
+	m.Respond(&protos.RKSyncMessage{
+		Tag:     protos.RKSyncMessage_EMPTY,
+		Nonce:   msg.Nonce,
+		Content: &protos.RKSyncMessage_IdentityRes{IdentityRes: res},
+	})
+}
+
+// handleIdentityResponse stores the identities received, only those which are awaited from the sender are
+// accepted and the identities already stored are kept. The identity store rejects those which aren't bound
+// to their PKI-IDs or aren't trusted. The alive messages held until they arrived are handed over to the discovery.
+func (g *gossipService) handleIdentityResponse(m protos.ReceivedMessage) {
+	msg := m.GetRKSyncMessage()
+	sender := m.GetConnectionInfo().ID
+	for _, pi := range msg.GetIdentityRes().Identities {
+		if !g.identityRequests.expects(pi.PkiId, sender) {
+			g.deadLetters.report(sender, msg.RKSyncMessage, fmt.Sprintf("Identity of %s wasn't requested", common.PKIidType(pi.PkiId)))
+			continue
+		}
+		if stored, _ := g.idMapper.Get(pi.PkiId); stored == nil {
+			if err := g.idMapper.Put(pi.PkiId, pi.Identity); err != nil {
+				g.ReportMisbehavior(sender, common.MalformedMessage)
+				g.deadLetters.report(sender, msg.RKSyncMessage, fmt.Sprintf("Identity of %s is invalid: %s", common.PKIidType(pi.PkiId), err))
+				continue
+			}
+			g.logger.Debug("Received the identity of", common.PKIidType(pi.PkiId), "from", sender)
+		}
+		if held := g.identityRequests.resolve(pi.PkiId); held != nil {
+			g.forwardDiscoveryMsg(held)
+		}
+	}
+}
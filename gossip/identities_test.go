@@ -0,0 +1,263 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/lib"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// identityStoreMock binds the identity "cert-<pki-id>" to each PKI-ID
+type identityStoreMock struct {
+	identity.Identity
+	sync.Mutex
+	self       common.PeerIdentityType
+	identities map[string]common.PeerIdentityType
+}
+
+func (s *identityStoreMock) Put(pkiID common.PKIidType, identity common.PeerIdentityType) error {
+	if string(identity) != "cert-"+string(pkiID) {
+		return errors.Errorf("identity %s doesn't match %s", identity, pkiID)
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.identities[string(pkiID)] = identity
+	return nil
+}
+
+func (s *identityStoreMock) Get(pkiID common.PKIidType) (common.PeerIdentityType, error) {
+	s.Lock()
+	defer s.Unlock()
+	if identity, exists := s.identities[string(pkiID)]; exists {
+		return identity, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (s *identityStoreMock) SelfIdentity() common.PeerIdentityType {
+	return s.self
+}
+
+type respondingMessageMock struct {
+	receivedMessageMock
+	responses []*protos.RKSyncMessage
+}
+
+func (m *respondingMessageMock) Respond(msg *protos.RKSyncMessage) {
+	m.responses = append(m.responses, msg)
+}
+
+func (m *respondingMessageMock) GetConnectionInfo() *protos.ConnectionInfo {
+	return &protos.ConnectionInfo{ID: m.sender, ProtocolVersion: protos.ProtocolVersion}
+}
+
+func TestIdentityInclusion(t *testing.T) {
+	clock := lib.NewFakeClock(time.Now())
+	idMapper := &identityStoreMock{self: common.PeerIdentityType("cert-self")}
+	adapter := func(inclusion string) *discoverySecurityAdapter {
+		g := &gossipService{
+			conf:                  &config.GossipConfig{PublishCertPeriod: time.Minute, IdentityInclusion: inclusion},
+			idMapper:              idMapper,
+			clock:                 clock,
+			selfIdentity:          idMapper.self,
+			includeIdentityPeriod: clock.Now().Add(time.Minute),
+			logger:                logging.Default(),
+		}
+		return g.newDiscoverySecurityAdapter()
+	}
+
+	window := adapter(config.IdentityWindow)
+	always := adapter(config.IdentityAlways)
+	onRequest := adapter(config.IdentityOnRequest)
+	assert.Equal(t, idMapper.self, window.currentIdentity())
+	assert.Equal(t, idMapper.self, always.currentIdentity())
+	assert.Nil(t, onRequest.currentIdentity())
+
+	clock.Advance(2 * time.Minute)
+	assert.Nil(t, window.currentIdentity())
+	assert.Equal(t, idMapper.self, always.currentIdentity())
+	assert.Nil(t, onRequest.currentIdentity())
+
+	// a request only opens the window again with the on-request policy
+	window.identityRequested()
+	onRequest.identityRequested()
+	assert.Nil(t, window.currentIdentity())
+	assert.Equal(t, idMapper.self, onRequest.currentIdentity())
+
+	clock.Advance(2 * time.Minute)
+	assert.Nil(t, onRequest.currentIdentity())
+
+	// a rotated identity is published whatever the policy
+	idMapper.self = common.PeerIdentityType("cert-self2")
+	assert.Equal(t, idMapper.self, window.currentIdentity())
+	assert.Equal(t, idMapper.self, onRequest.currentIdentity())
+}
+
+func TestIdentityRequest(t *testing.T) {
+	idMapper := &identityStoreMock{
+		self:       common.PeerIdentityType("cert-self"),
+		identities: map[string]common.PeerIdentityType{"p1": common.PeerIdentityType("cert-p1")},
+	}
+	g := &gossipService{
+		conf:             &config.GossipConfig{PublishCertPeriod: time.Minute, IdentityInclusion: config.IdentityOnRequest},
+		idMapper:         idMapper,
+		clock:            lib.SystemClock,
+		selfIdentity:     idMapper.self,
+		selfPKIid:        common.PKIidType("self"),
+		logger:           logging.Default(),
+		reputation:       newReputationTracker(),
+		deadLetters:      newDeadLetterBox(nil, 10, logging.Default()),
+		identityRequests: newIdentityRequests(),
+	}
+	g.secAdapter = g.newDiscoverySecurityAdapter()
+	assert.Nil(t, g.secAdapter.currentIdentity())
+
+	req, err := (&protos.RKSyncMessage{
+		Nonce: 7,
+		Content: &protos.RKSyncMessage_IdentityReq{IdentityReq: &protos.PeerIdentityRequest{
+			PkiIds: [][]byte{[]byte("self"), []byte("p1"), []byte("p2")},
+		}},
+	}).NoopSign()
+	require.NoError(t, err)
+	m := &respondingMessageMock{receivedMessageMock: receivedMessageMock{msg: req, sender: common.PKIidType("p3")}}
+	g.handleIdentityRequest(m)
+
+	require.Len(t, m.responses, 1)
+	assert.Equal(t, uint64(7), m.responses[0].Nonce)
+	assert.Equal(t, []*protos.PeerIdentity{
+		{PkiId: []byte("self"), Identity: []byte("cert-self")},
+		{PkiId: []byte("p1"), Identity: []byte("cert-p1")},
+	}, m.responses[0].GetIdentityRes().Identities)
+	// the peers which missed the identity learn it from the next alive messages
+	assert.Equal(t, idMapper.self, g.secAdapter.currentIdentity())
+
+	for _, pkiID := range []string{"p2", "p4"} {
+		g.identityRequests.await(common.PKIidType(pkiID), nil, time.Now())
+	}
+	g.requestIdentities(&respondingMessageMock{receivedMessageMock: receivedMessageMock{sender: common.PKIidType("p3")}}, false, common.PKIidType("p2"), common.PKIidType("p4"))
+
+	res, err := (&protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_IdentityRes{IdentityRes: &protos.PeerIdentityResponse{
+			Identities: []*protos.PeerIdentity{
+				{PkiId: []byte("p2"), Identity: []byte("cert-p2")},
+				{PkiId: []byte("p4"), Identity: []byte("cert-p2")},
+				{PkiId: []byte("p5"), Identity: []byte("cert-p5")},
+			},
+		}},
+	}).NoopSign()
+	require.NoError(t, err)
+
+	// only the peers asked for the identities are answered
+	g.handleIdentityResponse(&receivedMessageMock{msg: res, sender: common.PKIidType("p6")})
+	_, err = idMapper.Get(common.PKIidType("p2"))
+	assert.Error(t, err)
+
+	g.handleIdentityResponse(&receivedMessageMock{msg: res, sender: common.PKIidType("p3")})
+	identity, err := idMapper.Get(common.PKIidType("p2"))
+	assert.NoError(t, err)
+	assert.Equal(t, common.PeerIdentityType("cert-p2"), identity)
+	_, err = idMapper.Get(common.PKIidType("p4"))
+	assert.Error(t, err)
+	_, err = idMapper.Get(common.PKIidType("p5"))
+	assert.Error(t, err)
+	scores := g.reputation.scores(time.Now())
+	require.Len(t, scores, 1)
+	assert.Equal(t, uint64(1), scores[0].Misbehaviors[common.MalformedMessage])
+}
+
+func TestAwaitIdentity(t *testing.T) {
+	clock := lib.NewFakeClock(time.Now())
+	idMapper := &identityStoreMock{
+		self:       common.PeerIdentityType("cert-self"),
+		identities: map[string]common.PeerIdentityType{"p1": common.PeerIdentityType("cert-p1")},
+	}
+	g := &gossipService{
+		conf:             &config.GossipConfig{},
+		idMapper:         idMapper,
+		clock:            clock,
+		selfPKIid:        common.PKIidType("self"),
+		secAdapter:       &discoverySecurityAdapter{},
+		identityRequests: newIdentityRequests(),
+		discAdapter:      &discoveryAdapter{incChan: make(chan protos.ReceivedMessage, 10)},
+		ctx:              context.Background(),
+		logger:           logging.Default(),
+		reputation:       newReputationTracker(),
+		deadLetters:      newDeadLetterBox(nil, 10, logging.Default()),
+	}
+
+	alive := func(pkiID string, identity []byte) *respondingMessageMock {
+		msg, err := (&protos.RKSyncMessage{
+			Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{
+				Membership: &protos.Member{PkiId: []byte(pkiID)},
+				Identity:   identity,
+			}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return &respondingMessageMock{receivedMessageMock: receivedMessageMock{msg: msg, sender: common.PKIidType("p1")}}
+	}
+
+	// the identities of the peer itself and of p1 are known, the one of p3 is carried by its alive message
+	assert.False(t, g.awaitIdentity(alive("self", nil)))
+	assert.False(t, g.awaitIdentity(alive("p1", nil)))
+	assert.False(t, g.awaitIdentity(alive("p3", []byte("cert-p3"))))
+
+	m := alive("p2", nil)
+	assert.True(t, g.awaitIdentity(m))
+	require.Len(t, m.responses, 1)
+	assert.Equal(t, [][]byte{[]byte("p2")}, m.responses[0].GetIdentityReq().PkiIds)
+
+	// the identity isn't requested again before identityRequestInterval
+	m2 := alive("p2", nil)
+	assert.True(t, g.awaitIdentity(m2))
+	assert.Empty(t, m2.responses)
+
+	// the last alive message held is handed over to the discovery once the identity arrives
+	res, err := (&protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_IdentityRes{IdentityRes: &protos.PeerIdentityResponse{
+			Identities: []*protos.PeerIdentity{{PkiId: []byte("p2"), Identity: []byte("cert-p2")}},
+		}},
+	}).NoopSign()
+	require.NoError(t, err)
+	g.handleIdentityResponse(&receivedMessageMock{msg: res, sender: common.PKIidType("p1")})
+	require.Len(t, g.discAdapter.incChan, 1)
+	assert.Equal(t, m2, <-g.discAdapter.incChan)
+	assert.False(t, g.awaitIdentity(alive("p2", nil)))
+
+	// the unknown peers disclosed in a membership response are requested at once
+	var envelopes []*protos.Envelope
+	for _, pkiID := range []string{"p1", "p4", "p5"} {
+		envelopes = append(envelopes, alive(pkiID, nil).msg.Envelope)
+	}
+	memRes, err := (&protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_MemRes{MemRes: &protos.MembershipResponse{Alive: envelopes}},
+	}).NoopSign()
+	require.NoError(t, err)
+	m = &respondingMessageMock{receivedMessageMock: receivedMessageMock{msg: memRes, sender: common.PKIidType("p1")}}
+	g.requestMissingIdentities(m)
+	require.Len(t, m.responses, 1)
+	assert.Equal(t, [][]byte{[]byte("p4"), []byte("p5")}, m.responses[0].GetIdentityReq().PkiIds)
+
+	// the awaited identities are forgotten after pendingIdentityTTL
+	clock.Advance(2 * pendingIdentityTTL)
+	awaited, due, attempts := g.identityRequests.await(common.PKIidType("p4"), nil, clock.Now())
+	assert.True(t, awaited)
+	assert.True(t, due)
+	assert.Zero(t, attempts)
+	assert.Len(t, g.identityRequests.pending, 1)
+}
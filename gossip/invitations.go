@@ -0,0 +1,387 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// Invitation is an invitation sent by the leader of a channel to a peer to become a member
+type Invitation struct {
+	ChainMac common.ChainMac
+	ChainID  string
+	Leader   common.PKIidType
+	Member   common.PKIidType
+	Expires  time.Time // Time after which the invitation can't be accepted
+}
+
+// MemberAcceptedHandler is called on the leader of a channel once an invited peer accepted
+// the invitation and was added to the channel, with the new state of the channel
+type MemberAcceptedHandler func(chainMac common.ChainMac, chainState *protos.ChainState)
+
+// invitations tracks the invitations sent by the peer as the leader of channels,
+// the invitations it received, and the channels it accepted to join
+type invitations struct {
+	sync.Mutex
+	sent     map[string]Invitation // by channel MAC and invited member
+	received map[string]Invitation // by channel MAC
+	accepted map[string]struct{}   // channel MACs
+	path     string                // file the accepted channels are persisted to, if any
+}
+
+func newInvitations(path string) *invitations {
+	return &invitations{
+		sent:     make(map[string]Invitation),
+		received: make(map[string]Invitation),
+		accepted: make(map[string]struct{}),
+		path:     path,
+	}
+}
+
+func sentInvitationKey(chainMac common.ChainMac, member common.PKIidType) string {
+	return string(chainMac) + "/" + string(member)
+}
+
+// purge forgets the expired invitations
+func (inv *invitations) purge(now time.Time) {
+	for key, i := range inv.sent {
+		if now.After(i.Expires) {
+			delete(inv.sent, key)
+		}
+	}
+	for key, i := range inv.received {
+		if now.After(i.Expires) {
+			delete(inv.received, key)
+		}
+	}
+}
+
+// send records the invitation sent to a peer, replacing the previous one
+func (inv *invitations) send(i Invitation) {
+	inv.Lock()
+	defer inv.Unlock()
+	inv.sent[sentInvitationKey(i.ChainMac, i.Member)] = i
+}
+
+// answered returns the invitation sent to the member, and forgets it
+func (inv *invitations) answered(chainMac common.ChainMac, member common.PKIidType, now time.Time) (Invitation, bool) {
+	inv.Lock()
+	defer inv.Unlock()
+	inv.purge(now)
+	key := sentInvitationKey(chainMac, member)
+	i, exists := inv.sent[key]
+	delete(inv.sent, key)
+	return i, exists
+}
+
+// receive records the invitation received from the leader of a channel, replacing the previous one
+func (inv *invitations) receive(i Invitation) {
+	inv.Lock()
+	defer inv.Unlock()
+	inv.received[string(i.ChainMac)] = i
+}
+
+// pending returns the invitations received and not answered yet, ordered by expiration
+func (inv *invitations) pending(now time.Time) []Invitation {
+	inv.Lock()
+	defer inv.Unlock()
+	inv.purge(now)
+	res := make([]Invitation, 0, len(inv.received))
+	for _, i := range inv.received {
+		res = append(res, i)
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].Expires.Before(res[b].Expires) })
+	return res
+}
+
+// answer returns the invitation received for the channel, and forgets it.
+// The channel is recorded as accepted if accept is true.
+func (inv *invitations) answer(chainMac common.ChainMac, accept bool, now time.Time) (Invitation, error) {
+	inv.Lock()
+	defer inv.Unlock()
+	inv.purge(now)
+	i, exists := inv.received[string(chainMac)]
+	if !exists {
+		return Invitation{}, errors.Wrapf(ErrNoInvitation, "Channel %s", chainMac)
+	}
+	delete(inv.received, string(chainMac))
+	if !accept {
+		return i, nil
+	}
+
+	inv.accepted[string(chainMac)] = struct{}{}
+	if err := inv.save(); err != nil {
+		delete(inv.accepted, string(chainMac))
+		return Invitation{}, err
+	}
+	return i, nil
+}
+
+// isAccepted returns whether the peer accepted to join the channel
+func (inv *invitations) isAccepted(chainMac common.ChainMac) bool {
+	inv.Lock()
+	defer inv.Unlock()
+	_, exists := inv.accepted[string(chainMac)]
+	return exists
+}
+
+// save writes the accepted channels to the file, replacing its previous content at once
+func (inv *invitations) save() error {
+	if inv.path == "" {
+		return nil
+	}
+	macs := make([]string, 0, len(inv.accepted))
+	for mac := range inv.accepted {
+		macs = append(macs, hex.EncodeToString([]byte(mac)))
+	}
+	sort.Strings(macs)
+	data, err := json.Marshal(macs)
+	if err != nil {
+		return errors.Wrap(err, "Failed marshaling the accepted channels")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(inv.path), 0755); err != nil {
+		return errors.Wrap(err, "Failed creating the invitations directory")
+	}
+	tmp := inv.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrapf(err, "Failed writing invitations %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, inv.path), "Failed renaming invitations %s", tmp)
+}
+
+// load reads the accepted channels persisted to the file, none if it doesn't exist
+func (inv *invitations) load() error {
+	if inv.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(inv.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed reading invitations %s", inv.path)
+	}
+
+	var macs []string
+	if err := json.Unmarshal(data, &macs); err != nil {
+		return errors.Wrapf(err, "Failed unmarshaling invitations %s", inv.path)
+	}
+	inv.Lock()
+	defer inv.Unlock()
+	for _, mac := range macs {
+		chainMac, err := hex.DecodeString(mac)
+		if err != nil {
+			return errors.Errorf("Invalid channel MAC %s in invitations %s", mac, inv.path)
+		}
+		inv.accepted[string(chainMac)] = struct{}{}
+	}
+	return nil
+}
+
+// mayJoin returns whether the peer may join the channel it's a member of,
+// with RequireInvitation only the channels whose invitation it accepted are joined
+func (g *gossipService) mayJoin(chainMac common.ChainMac) bool {
+	return !g.getConf().RequireInvitation || g.invitations.isAccepted(chainMac)
+}
+
+// channelLeader returns the ID and the leader of the channel
+func channelLeader(gc channel.Channel) (string, common.PKIidType, error) {
+	chainState := gc.Self()
+	if chainState == nil {
+		return "", nil, errors.New("Channel isn't initialized")
+	}
+	chainInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return "", nil, err
+	}
+	return chainState.ChainId, chainInfo.Leader, nil
+}
+
+func (g *gossipService) InviteMember(chainMac common.ChainMac, member common.PKIidType) (*Invitation, error) {
+	gc := g.chanState.getChannelByMAC(chainMac)
+	if gc == nil {
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
+	}
+	chainID, leader, err := channelLeader(gc)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(leader, g.selfPKIid) {
+		return nil, errors.WithMessage(ErrNotLeader, "Only the channel leader can invite members")
+	}
+	if bytes.Equal(member, g.selfPKIid) {
+		return nil, errors.New("Can't invite self-node to the channel")
+	}
+	peer := g.disc.Lookup(member)
+	if peer == nil {
+		return nil, errors.Wrapf(ErrPeerNotAlive, "Peer %s", member)
+	}
+
+	i := Invitation{
+		ChainMac: chainMac,
+		ChainID:  chainID,
+		Leader:   g.selfPKIid,
+		Member:   member,
+		Expires:  g.clock.Now().Add(g.getConf().InvitationTTL),
+	}
+	msg, err := g.signChannelMessage(&protos.RKSyncMessage{
+		ChainMac: chainMac,
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Content: &protos.RKSyncMessage_ChannelInvitation{
+			ChannelInvitation: &protos.ChannelInvitation{ChainId: chainID, Member: member, Expires: i.Expires.UnixNano()},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g.invitations.send(i)
+	g.srv.Send(msg, peer)
+	return &i, nil
+}
+
+func (g *gossipService) Invitations() []Invitation {
+	return g.invitations.pending(g.clock.Now())
+}
+
+func (g *gossipService) AnswerInvitation(chainMac common.ChainMac, accept bool) error {
+	i, err := g.invitations.answer(chainMac, accept, g.clock.Now())
+	if err != nil {
+		return err
+	}
+	leader := g.disc.Lookup(i.Leader)
+	if leader == nil {
+		return errors.Wrapf(ErrPeerNotAlive, "Leader %s of channel %s", i.Leader, i.ChainID)
+	}
+
+	msg, err := g.signChannelMessage(&protos.RKSyncMessage{
+		ChainMac: chainMac,
+		Tag:      protos.RKSyncMessage_CHAN_ONLY,
+		Content: &protos.RKSyncMessage_ChannelAcceptance{
+			ChannelAcceptance: &protos.ChannelAcceptance{Member: g.selfPKIid, Accepted: accept},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	g.srv.Send(msg, leader)
+	return nil
+}
+
+// signChannelMessage signs a message of a channel with the identity of the peer
+func (g *gossipService) signChannelMessage(m *protos.RKSyncMessage) (*protos.SignedRKSyncMessage, error) {
+	msg := &protos.SignedRKSyncMessage{RKSyncMessage: m}
+	_, err := msg.SignWithAlgorithm(g.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
+		return g.idMapper.Sign(msg)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed signing message")
+	}
+	return msg, nil
+}
+
+// verifySender checks that the message is signed by the peer which sent it
+func (g *gossipService) verifySender(m protos.ReceivedMessage) error {
+	return m.GetRKSyncMessage().Verify(m.GetConnectionInfo().ID, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return g.idMapper.Verify(peerIdentity, algorithm, signature, message)
+	})
+}
+
+// handleInvitation records the invitation sent by the leader of a channel to the peer, and emits a
+// ChannelInvited event. Invitations are sent directly, so the sender must be the leader of the channel.
+func (g *gossipService) handleInvitation(m protos.ReceivedMessage) {
+	msg := m.GetRKSyncMessage()
+	inv := msg.GetChannelInvitation()
+	leader := m.GetConnectionInfo().ID
+	if !bytes.Equal(channel.GenerateMAC(leader, inv.ChainId), msg.ChainMac) || !bytes.Equal(inv.Member, g.selfPKIid) {
+		g.ReportMisbehavior(leader, common.MalformedMessage)
+		g.deadLetters.report(leader, msg.RKSyncMessage, fmt.Sprintf("Invitation to channel %s doesn't match its sender or the peer", inv.ChainId))
+		return
+	}
+	if err := g.verifySender(m); err != nil {
+		g.ReportMisbehavior(leader, common.InvalidSignature)
+		g.deadLetters.report(leader, msg.RKSyncMessage, fmt.Sprintf("Failed verifying the invitation to channel %s: %s", inv.ChainId, err))
+		return
+	}
+	if err := g.checkAccess(inv.ChainId, leader, true); err != nil {
+		g.deadLetters.report(leader, msg.RKSyncMessage, fmt.Sprintf("Leader of channel %s is not allowed: %s", inv.ChainId, err))
+		return
+	}
+	expires := time.Unix(0, inv.Expires)
+	if g.clock.Now().After(expires) {
+		g.deadLetters.report(leader, msg.RKSyncMessage, fmt.Sprintf("Invitation to channel %s expired", inv.ChainId))
+		return
+	}
+
+	g.logger.Infof("Invited to channel %s by %s", inv.ChainId, leader)
+	g.invitations.receive(Invitation{ChainMac: msg.ChainMac, ChainID: inv.ChainId, Leader: leader, Member: g.selfPKIid, Expires: expires})
+	g.EmitEvent(common.ChannelInvited{ChainMac: msg.ChainMac, ChainID: inv.ChainId, Leader: leader, Expires: expires})
+}
+
+// handleAcceptance adds the invited peer to the channel if it accepted the invitation,
+// and emits an InvitationAnswered event
+func (g *gossipService) handleAcceptance(m protos.ReceivedMessage) {
+	msg := m.GetRKSyncMessage()
+	acc := msg.GetChannelAcceptance()
+	member := m.GetConnectionInfo().ID
+	if !bytes.Equal(acc.Member, member) {
+		g.ReportMisbehavior(member, common.MalformedMessage)
+		g.deadLetters.report(member, msg.RKSyncMessage, "Acceptance doesn't match its sender")
+		return
+	}
+	if err := g.verifySender(m); err != nil {
+		g.ReportMisbehavior(member, common.InvalidSignature)
+		g.deadLetters.report(member, msg.RKSyncMessage, fmt.Sprintf("Failed verifying the acceptance: %s", err))
+		return
+	}
+	i, exists := g.invitations.answered(msg.ChainMac, member, g.clock.Now())
+	if !exists {
+		g.deadLetters.report(member, msg.RKSyncMessage, fmt.Sprintf("No pending invitation to channel %s", common.ChainMac(msg.ChainMac)))
+		return
+	}
+	if !acc.Accepted {
+		g.logger.Infof("Peer %s declined the invitation to channel %s", member, i.ChainID)
+		g.EmitEvent(common.InvitationAnswered{ChainMac: i.ChainMac, ChainID: i.ChainID, PKIID: member})
+		return
+	}
+
+	if err := g.checkAccess(i.ChainID, member, false); err != nil {
+		g.deadLetters.report(member, msg.RKSyncMessage, fmt.Sprintf("Peer is not allowed in channel %s: %s", i.ChainID, err))
+		return
+	}
+	gc := g.chanState.getChannelByMAC(i.ChainMac)
+	if gc == nil {
+		g.deadLetters.report(member, msg.RKSyncMessage, fmt.Sprintf("No channel found for MAC %s", i.ChainMac))
+		return
+	}
+	chainState, err := gc.AddMember(member)
+	if err != nil {
+		g.logger.Errorf("Failed adding %s to channel %s: %s", member, i.ChainID, err)
+		return
+	}
+
+	g.logger.Infof("Peer %s accepted the invitation to channel %s", member, i.ChainID)
+	if g.onMemberAccepted != nil {
+		g.onMemberAccepted(i.ChainMac, chainState)
+	}
+	g.EmitEvent(common.InvitationAnswered{ChainMac: i.ChainMac, ChainID: i.ChainID, PKIID: member, Accepted: true})
+}
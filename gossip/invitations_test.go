@@ -0,0 +1,157 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "invitations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "invitations.json")
+
+	now := time.Now()
+	inv := newInvitations(path)
+	_, err = inv.answer(common.ChainMac("c1"), true, now)
+	assert.Equal(t, ErrNoInvitation, errors.Cause(err))
+
+	inv.receive(Invitation{ChainMac: common.ChainMac("c1"), ChainID: "c1", Expires: now.Add(2 * time.Minute)})
+	inv.receive(Invitation{ChainMac: common.ChainMac("c2"), ChainID: "c2", Expires: now.Add(time.Minute)})
+	inv.receive(Invitation{ChainMac: common.ChainMac("c3"), ChainID: "c3", Expires: now.Add(time.Second)})
+	pending := inv.pending(now.Add(30 * time.Second))
+	require.Len(t, pending, 2)
+	assert.Equal(t, "c2", pending[0].ChainID)
+	assert.Equal(t, "c1", pending[1].ChainID)
+
+	_, err = inv.answer(common.ChainMac("c2"), false, now)
+	assert.NoError(t, err)
+	assert.False(t, inv.isAccepted(common.ChainMac("c2")))
+	i, err := inv.answer(common.ChainMac("c1"), true, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "c1", i.ChainID)
+	assert.True(t, inv.isAccepted(common.ChainMac("c1")))
+	assert.Empty(t, inv.pending(now))
+
+	// the accepted channels survive a restart
+	inv = newInvitations(path)
+	require.NoError(t, inv.load())
+	assert.True(t, inv.isAccepted(common.ChainMac("c1")))
+	assert.False(t, inv.isAccepted(common.ChainMac("c2")))
+
+	inv.send(Invitation{ChainMac: common.ChainMac("c1"), Member: common.PKIidType("p1"), Expires: now.Add(time.Minute)})
+	_, exists := inv.answered(common.ChainMac("c1"), common.PKIidType("p2"), now)
+	assert.False(t, exists)
+	_, exists = inv.answered(common.ChainMac("c1"), common.PKIidType("p1"), now.Add(2*time.Minute))
+	assert.False(t, exists, "The invitation expired")
+}
+
+func TestChannelInvitation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "invitations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	accepted := make(chan *protos.ChainState, 1)
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12083"}, "localhost:12083", 0, WithMemberAcceptedHandler(func(chainMac common.ChainMac, chainState *protos.ChainState) {
+		accepted <- chainState
+	}))
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := createGossipServerWithConfig([]string{"localhost:12083"}, "localhost:12084", 1, func(c *config.GossipConfig) {
+		c.RequireInvitation = true
+		c.InvitationTTL = time.Minute
+		c.InvitationsFile = filepath.Join(dir, "invitations.json")
+	})
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	require.Eventually(t, func() bool {
+		return len(gossipSvc1.Peers()) == 1 && len(gossipSvc2.Peers()) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+
+	leaderEvents, cancel := gossipSvc1.Events()
+	defer cancel()
+	memberEvents, cancel := gossipSvc2.Events()
+	defer cancel()
+
+	// the members added without their consent don't join the channel
+	unsolicited := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "unsolicited")
+	_, err = gossipSvc1.CreateChain(unsolicited, "unsolicited", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(unsolicited, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "invited")
+	_, err = gossipSvc1.CreateChain(mac, "invited", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	_, err = gossipSvc1.InviteMember(mac, gossipSvc1.SelfPKIid())
+	assert.Error(t, err)
+	_, err = gossipSvc1.InviteMember(mac, common.PKIidType("unknown"))
+	assert.Equal(t, ErrPeerNotAlive, errors.Cause(err))
+	_, err = gossipSvc2.InviteMember(mac, gossipSvc1.SelfPKIid())
+	assert.Equal(t, ErrChannelNotExist, errors.Cause(err))
+
+	invitation, err := gossipSvc1.InviteMember(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+	assert.Equal(t, "invited", invitation.ChainID)
+
+	timeout := time.After(10 * time.Second)
+	var invited *common.ChannelInvited
+	for invited == nil {
+		select {
+		case e := <-memberEvents:
+			if i, ok := e.(common.ChannelInvited); ok {
+				invited = &i
+			}
+		case <-timeout:
+			require.FailNow(t, "The member wasn't invited")
+		}
+	}
+	assert.Equal(t, common.ChainMac(mac), invited.ChainMac)
+	assert.Equal(t, gossipSvc1.SelfPKIid(), invited.Leader)
+	require.Len(t, gossipSvc2.Invitations(), 1)
+	assert.Nil(t, gossipSvc2.SelfChainInfo("invited"))
+
+	require.NoError(t, gossipSvc2.AnswerInvitation(mac, true))
+	assert.Empty(t, gossipSvc2.Invitations())
+	assert.Equal(t, ErrNoInvitation, errors.Cause(gossipSvc2.AnswerInvitation(mac, true)))
+
+	var answered *common.InvitationAnswered
+	for answered == nil {
+		select {
+		case e := <-leaderEvents:
+			if a, ok := e.(common.InvitationAnswered); ok {
+				answered = &a
+			}
+		case <-timeout:
+			require.FailNow(t, "The leader didn't receive the acceptance")
+		}
+	}
+	assert.True(t, answered.Accepted)
+	assert.Equal(t, gossipSvc2.SelfPKIid(), answered.PKIID)
+	chainState := <-accepted
+	assert.Equal(t, "invited", chainState.ChainId)
+
+	require.Eventually(t, func() bool {
+		return gossipSvc2.SelfChainInfo("invited") != nil
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Nil(t, gossipSvc2.SelfChainInfo("unsolicited"))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+)
+
+// persistedPeerAttempts is the number of times a peer of the persisted membership
+// is dialed on startup, it is then forgotten unless the membership learns it again.
+// The peers are dialed after a pull interval, so that the peers connecting
+// through the bootstrap peers meanwhile aren't dialed concurrently.
+const persistedPeerAttempts = 3
+
+// persistedPeer is a member of the live membership persisted on shutdown
+type persistedPeer struct {
+	Endpoint         string `json:"endpoint"`
+	InternalEndpoint string `json:"internalEndpoint,omitempty"`
+	PKIID            string `json:"pkiId"`
+}
+
+// saveMembership writes the members to the file, replacing its previous content at once
+func saveMembership(path string, members []common.NetworkMember) error {
+	peers := []persistedPeer{}
+	for _, member := range members {
+		if member.Leaving {
+			continue
+		}
+		peers = append(peers, persistedPeer{
+			Endpoint:         member.Endpoint,
+			InternalEndpoint: member.InternalEndpoint,
+			PKIID:            hex.EncodeToString(member.PKIID),
+		})
+	}
+	data, err := json.Marshal(peers)
+	if err != nil {
+		return errors.Wrap(err, "Failed marshaling the membership")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "Failed creating the membership directory")
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrapf(err, "Failed writing membership %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, path), "Failed renaming membership %s", tmp)
+}
+
+// loadMembership reads the members persisted to the file, none if it doesn't exist
+func loadMembership(path string) ([]common.NetworkMember, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading membership %s", path)
+	}
+
+	var peers []persistedPeer
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, errors.Wrapf(err, "Failed unmarshaling membership %s", path)
+	}
+	members := make([]common.NetworkMember, 0, len(peers))
+	for _, peer := range peers {
+		pkiID, err := hex.DecodeString(peer.PKIID)
+		if err != nil || peer.Endpoint == "" {
+			return nil, errors.Errorf("Invalid member %s at %s in membership %s", peer.PKIID, peer.Endpoint, path)
+		}
+		members = append(members, common.NetworkMember{
+			Endpoint:         peer.Endpoint,
+			InternalEndpoint: peer.InternalEndpoint,
+			PKIID:            pkiID,
+		})
+	}
+	return members, nil
+}
+
+// persistMembership saves the live membership to the membership file, if any
+func (g *gossipService) persistMembership() {
+	conf := g.getConf()
+	if conf.MembershipFile == "" || conf.StaticTopology {
+		return
+	}
+	if err := saveMembership(conf.MembershipFile, g.unblockedMembers()); err != nil {
+		g.logger.Warningf("Failed persisting the membership: %s", err)
+	}
+}
+
+// connect2PersistedPeers dials the peers of the membership persisted on the last shutdown,
+// so that the peer rejoins the network even if the bootstrap peers are down.
+// The peers known already, e.g. through the bootstrap peers, are skipped.
+func (g *gossipService) connect2PersistedPeers() {
+	conf := g.getConf()
+	if conf.MembershipFile == "" || conf.StaticTopology {
+		return
+	}
+	members, err := loadMembership(conf.MembershipFile)
+	if err != nil {
+		g.logger.Warningf("Failed loading the persisted membership: %s", err)
+		return
+	}
+
+	bootstrap := make(map[string]struct{}, len(conf.BootstrapPeers))
+	for _, endpoint := range conf.BootstrapPeers {
+		bootstrap[endpoint] = struct{}{}
+	}
+	for _, member := range members {
+		endpoint := member.PreferredEndpoint()
+		if _, exists := bootstrap[endpoint]; exists || isLocalAddress(endpoint, conf.Endpoint) || !g.selfPKIid.IsNotSameFilter(member.PKIID) {
+			continue
+		}
+		go g.connect2PersistedPeer(endpoint, member.PKIID)
+	}
+}
+
+func (g *gossipService) connect2PersistedPeer(endpoint string, pkiID common.PKIidType) {
+	for i := 0; i < persistedPeerAttempts; i++ {
+		if !g.sleep(g.getConf().PullInterval) || g.disc.Lookup(pkiID) != nil {
+			return
+		}
+		remotePeerIdentity, err := g.srv.Handshake(&common.NetworkMember{Endpoint: endpoint})
+		if err != nil {
+			g.logger.Debugf("Failed connecting to persisted peer %s: %s", endpoint, err)
+			continue
+		}
+		remotePKIID := g.idMapper.GetPKIidOfCert(remotePeerIdentity)
+		if len(remotePKIID) == 0 {
+			return
+		}
+		g.logger.Infof("Connecting to persisted peer %s", endpoint)
+		g.disc.Connect(common.NetworkMember{Endpoint: endpoint}, func() (common.PKIidType, error) {
+			return remotePKIID, nil
+		})
+		return
+	}
+}
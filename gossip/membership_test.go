@@ -0,0 +1,82 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveMembership(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "membership.json")
+	members, err := loadMembership(path)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	require.NoError(t, saveMembership(path, []common.NetworkMember{
+		{Endpoint: "203.0.113.1:9053", InternalEndpoint: "10.0.0.1:9053", PKIID: common.PKIidType("p1"), Zone: "eu"},
+		{Endpoint: "203.0.113.2:9053", PKIID: common.PKIidType("p2"), Leaving: true},
+		{Endpoint: "203.0.113.3:9053", PKIID: common.PKIidType("p3")},
+	}))
+	members, err = loadMembership(path)
+	require.NoError(t, err)
+	assert.Equal(t, []common.NetworkMember{
+		{Endpoint: "203.0.113.1:9053", InternalEndpoint: "10.0.0.1:9053", PKIID: common.PKIidType("p1")},
+		{Endpoint: "203.0.113.3:9053", PKIID: common.PKIidType("p3")},
+	}, members)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"endpoint": "203.0.113.1:9053", "pkiId": "zz"}]`), 0644))
+	_, err = loadMembership(path)
+	assert.Error(t, err)
+}
+
+func TestPersistedMembership(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "membership.json")
+	withMembershipFile := func(conf *config.GossipConfig) {
+		conf.MembershipFile = path
+	}
+
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12160"}, "localhost:12160", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12160"}, "localhost:13160", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	gossipSvc3, err := createGossipServerWithConfig([]string{"localhost:12160"}, "localhost:14160", 2, withMembershipFile)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(gossipSvc3.Peers()) == 2
+	}, 20*time.Second, 100*time.Millisecond)
+	gossipSvc3.Stop()
+
+	members, err := loadMembership(path)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	// The bootstrap peer is down, the peer rejoins the network through the persisted membership
+	gossipSvc1.Stop()
+	gossipSvc3, err = createGossipServerWithConfig([]string{"localhost:12160"}, "localhost:15160", 2, withMembershipFile)
+	require.NoError(t, err)
+	defer gossipSvc3.Stop()
+	require.Eventually(t, func() bool {
+		for _, peer := range gossipSvc3.Peers() {
+			if peer.Endpoint == "localhost:13160" {
+				return true
+			}
+		}
+		return false
+	}, 20*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/discovery"
+	"github.com/rkcloudchain/rksync/logging"
+	"google.golang.org/grpc"
+)
+
+// DiscoveryFactory creates the discovery module of the gossip service,
+// the rpc service lets it exchange messages with the remote peers and probe them
+type DiscoveryFactory func(self common.NetworkMember, rpc discovery.RPCService, logger logging.Logger) discovery.Discovery
+
+// Option customizes the gossip service created by NewGossipService
+type Option func(*options)
+
+type options struct {
+	secureDialOpts   func() []grpc.DialOption
+	logger           logging.Logger
+	fs               config.FileSystem
+	fsFactory        config.FileSystemFactory
+	accessControl    config.AccessControlPolicy
+	disclosure       config.DisclosurePolicy
+	discoveryFactory DiscoveryFactory
+	memberAccepted   MemberAcceptedHandler
+}
+
+// apply returns a copy of the configuration overridden by the options
+func (o *options) apply(conf *config.GossipConfig) *config.GossipConfig {
+	c := *conf
+	if o.logger != nil {
+		c.Logger = o.logger
+	}
+	if o.fs != nil {
+		c.FileSystem = o.fs
+	}
+	if o.fsFactory != nil {
+		c.FileSystemFactory = o.fsFactory
+	}
+	if o.accessControl != nil {
+		c.AccessControl = o.accessControl
+	}
+	if o.disclosure != nil {
+		c.DisclosurePolicy = o.disclosure
+	}
+	return &c
+}
+
+// WithSecureDialOpts sets the options the remote peers are dialed with, it is required
+func WithSecureDialOpts(secureDialOpts func() []grpc.DialOption) Option {
+	return func(o *options) {
+		o.secureDialOpts = secureDialOpts
+	}
+}
+
+// WithLogger routes the log entries to the logger, overriding GossipConfig.Logger
+func WithLogger(logger logging.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithFileSystem sets the file system the files are synchronized with, overriding GossipConfig.FileSystem
+func WithFileSystem(fs config.FileSystem) Option {
+	return func(o *options) {
+		o.fs = fs
+	}
+}
+
+// WithFileSystemFactory sets the file systems of specific channels, overriding GossipConfig.FileSystemFactory
+func WithFileSystemFactory(factory config.FileSystemFactory) Option {
+	return func(o *options) {
+		o.fsFactory = factory
+	}
+}
+
+// WithAccessControl restricts the peers allowed to join or lead channels, overriding GossipConfig.AccessControl
+func WithAccessControl(policy config.AccessControlPolicy) Option {
+	return func(o *options) {
+		o.accessControl = policy
+	}
+}
+
+// WithDisclosurePolicy restricts the peers disclosed to the remote peers, overriding GossipConfig.DisclosurePolicy
+func WithDisclosurePolicy(policy config.DisclosurePolicy) Option {
+	return func(o *options) {
+		o.disclosure = policy
+	}
+}
+
+// WithDiscovery replaces the built-in discovery module, e.g. by one whose
+// membership is backed by Consul, etcd or Kubernetes Endpoints.
+// The bootstrap peers are still handed over to it through Connect.
+func WithDiscovery(factory DiscoveryFactory) Option {
+	return func(o *options) {
+		o.discoveryFactory = factory
+	}
+}
+
+// WithMemberAcceptedHandler sets the function called on the leader of a channel once an invited
+// peer accepted the invitation and was added to the channel, e.g. to persist the new chain state
+func WithMemberAcceptedHandler(handler MemberAcceptedHandler) Option {
+	return func(o *options) {
+		o.memberAccepted = handler
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/discovery"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type discoveryMock struct {
+	lock      sync.Mutex
+	connected []string
+	members   []common.NetworkMember
+	stopped   bool
+}
+
+func (d *discoveryMock) GetMembership() []common.NetworkMember {
+	return d.members
+}
+
+func (d *discoveryMock) InitiateSync(peerNum int) {}
+
+func (d *discoveryMock) Connect(member common.NetworkMember, id discovery.Identifier) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.connected = append(d.connected, member.Endpoint)
+}
+
+func (d *discoveryMock) Lookup(pkiID common.PKIidType) *common.NetworkMember {
+	for _, m := range d.members {
+		if m.PKIID.String() == pkiID.String() {
+			return &m
+		}
+	}
+	return nil
+}
+
+func (d *discoveryMock) Leave() {}
+
+func (d *discoveryMock) Stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.stopped = true
+}
+
+func TestWithDiscovery(t *testing.T) {
+	disc := &discoveryMock{
+		members: []common.NetworkMember{{Endpoint: "consul.peer:9053", PKIID: common.PKIidType("consul.peer")}},
+	}
+	var self common.NetworkMember
+	g, err := CreateGossipServer([]string{"localhost:12064"}, "localhost:12063", 0, WithDiscovery(func(s common.NetworkMember, rpc discovery.RPCService, logger logging.Logger) discovery.Discovery {
+		self = s
+		return disc
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost:12063", self.Endpoint)
+	assert.Equal(t, g.SelfPKIid(), self.PKIID)
+	assert.Equal(t, disc.members, g.Peers())
+
+	time.Sleep(time.Second)
+	disc.lock.Lock()
+	assert.Equal(t, []string{"localhost:12064"}, disc.connected)
+	disc.lock.Unlock()
+
+	g.Stop()
+	assert.True(t, disc.stopped)
+}
+
+func TestOptions(t *testing.T) {
+	_, err := NewGossipService(&config.GossipConfig{}, &config.IdentityConfig{}, nil, nil)
+	assert.EqualError(t, err, "Must specify the secure dial options")
+
+	logger := logging.Default()
+	fs := mocks.NewFSMock("base")
+	conf := &config.GossipConfig{Endpoint: "localhost:12065"}
+	o := &options{}
+	channelFS := mocks.NewFSMock("channel")
+	factory := func(chainID string) config.FileSystem {
+		if chainID == "channel" {
+			return channelFS
+		}
+		return nil
+	}
+	for _, opt := range []Option{WithLogger(logger), WithFileSystem(fs), WithFileSystemFactory(factory)} {
+		opt(o)
+	}
+	c := o.apply(conf)
+	assert.Equal(t, logger, c.Logger)
+	assert.Equal(t, fs, c.FileSystem)
+	g := &gossipService{conf: c}
+	assert.Equal(t, channelFS, g.fileSystem("channel"))
+	assert.Equal(t, fs, g.fileSystem("other"), "the channels the factory returns nil for use the FileSystem")
+	assert.Nil(t, c.AccessControl)
+	assert.Equal(t, "localhost:12065", c.Endpoint)
+	assert.Nil(t, conf.FileSystem, "the configuration given shouldn't be modified")
+}
@@ -0,0 +1,148 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel/fsync"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+)
+
+// orphanSweepInterval is the interval the retained orphaned files are checked at
+const orphanSweepInterval = time.Minute
+
+type orphanFile struct {
+	chainID  string
+	fmeta    config.FileMeta
+	orphaned time.Time
+}
+
+func newOrphanCollector(g *gossipService) *orphanCollector {
+	return &orphanCollector{
+		files: make(map[string]orphanFile),
+		g:     g,
+	}
+}
+
+// orphanCollector deletes the local copies of the files no longer synchronized, according to the OrphanPolicy.
+// The retained files are only known while the service runs.
+type orphanCollector struct {
+	sync.Mutex
+	files map[string]orphanFile // by channel ID and file name
+	g     *gossipService
+}
+
+// add applies the OrphanPolicy to the given files of the channel
+func (oc *orphanCollector) add(chainID string, files []config.FileMeta) {
+	switch oc.g.getConf().OrphanPolicy {
+	case config.OrphanDelete:
+		for _, fmeta := range files {
+			oc.remove(chainID, fmeta)
+		}
+	case config.OrphanRetain:
+		oc.Lock()
+		defer oc.Unlock()
+		now := oc.g.clock.Now()
+		for _, fmeta := range files {
+			key := orphanKey(chainID, fmeta.Name)
+			if _, exists := oc.files[key]; !exists {
+				oc.files[key] = orphanFile{chainID: chainID, fmeta: fmeta, orphaned: now}
+			}
+		}
+	default:
+		oc.g.logger.Debugf("Keeping %d orphaned files of channel %s", len(files), chainID)
+	}
+}
+
+// sweep deletes the retained files whose retention elapsed at the given time,
+// unless they are synchronized again by a channel
+func (oc *orphanCollector) sweep(now time.Time) {
+	conf := oc.g.getConf()
+
+	oc.Lock()
+	var due []orphanFile
+	for key, orphan := range oc.files {
+		switch {
+		case conf.OrphanPolicy == config.OrphanKeep:
+		case conf.OrphanPolicy == config.OrphanRetain && now.Sub(orphan.orphaned) < conf.OrphanRetention:
+			continue
+		default:
+			due = append(due, orphan)
+		}
+		delete(oc.files, key)
+	}
+	oc.Unlock()
+
+	for _, orphan := range due {
+		if oc.synchronized(orphan.chainID, orphan.fmeta.Name) {
+			oc.g.logger.Debugf("File %s of channel %s is synchronized again, keeping it", orphan.fmeta.Name, orphan.chainID)
+			continue
+		}
+		oc.remove(orphan.chainID, orphan.fmeta)
+	}
+}
+
+// synchronized returns whether the file belongs to a channel the peer is a member of
+func (oc *orphanCollector) synchronized(chainID string, filename string) bool {
+	for _, gc := range oc.g.chanState.snapshot() {
+		chainState := gc.Self()
+		if chainState == nil || chainState.ChainId != chainID {
+			continue
+		}
+		chainInfo, err := chainState.GetChainStateInfo()
+		if err != nil {
+			oc.g.logger.Warningf("Failed getting ChainStateInfo message: %s", err)
+			// Better safe than sorry
+			return true
+		}
+		for _, file := range chainInfo.Properties.Files {
+			if file.Path == filename {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remove deletes the file and its working copy, if the FileSystem supports it
+func (oc *orphanCollector) remove(chainID string, fmeta config.FileMeta) {
+	remover, ok := oc.g.fileSystem(chainID).(config.FileRemover)
+	if !ok {
+		oc.g.logger.Warningf("Can't delete orphaned file %s of channel %s, the FileSystem doesn't implement config.FileRemover", fmeta.Name, chainID)
+		return
+	}
+
+	for _, m := range []config.FileMeta{fsync.WorkingCopyMeta(fmeta), fmeta} {
+		if err := remover.Remove(chainID, m); err != nil {
+			oc.g.logger.Warningf("Failed deleting orphaned file %s of channel %s: %s", m.Name, chainID, err)
+			return
+		}
+	}
+	oc.g.logger.Infof("Deleted orphaned file %s of channel %s", fmeta.Name, chainID)
+	oc.g.EmitEvent(common.OrphanDeleted{ChainID: chainID, Filename: fmeta.Name})
+}
+
+func (g *gossipService) periodicalCollectOrphans() {
+	defer g.logger.Debug("Exiting")
+	defer g.loops.Done()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-g.clock.After(orphanSweepInterval):
+			g.orphans.sweep(g.clock.Now())
+		}
+	}
+}
+
+func orphanKey(chainID string, filename string) string {
+	return chainID + "/" + filename
+}
@@ -0,0 +1,97 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainFiles returns the number of files of the channel known by the peer, -1 if it didn't join it
+func chainFiles(g Gossip, chainID string) int {
+	info := g.SelfChainInfo(chainID)
+	if info == nil {
+		return -1
+	}
+	msg, err := info.Envelope.ToRKSyncMessage()
+	if err != nil || msg.GetStateInfo() == nil {
+		return -1
+	}
+	return len(msg.GetStateInfo().Properties.Files)
+}
+
+func TestSplitBrain(t *testing.T) {
+	network := mocks.NewNetwork()
+	configure := func(cfg *config.GossipConfig) {
+		cfg.AliveTimeInterval = 200 * time.Millisecond
+		cfg.AliveExpirationTimeout = time.Second
+		cfg.ReconnectInterval = 200 * time.Millisecond
+		cfg.MaxReconnectInterval = time.Second
+		cfg.PullInterval = time.Second
+		cfg.PublishStateInfoInterval = time.Second
+		cfg.RequestStateInfoInterval = time.Second
+	}
+
+	var peers []Gossip
+	for i := 0; i < 3; i++ {
+		g, err := createGossipServerWithConfig([]string{"split0:7051"}, fmt.Sprintf("split%d:7051", i), i, configure)
+		require.NoError(t, err)
+		defer g.Stop()
+		g.(*gossipService).srv.SetInterceptor(network.Interceptor(g.SelfPKIid()))
+		peers = append(peers, g)
+	}
+	waitForPeers := func(expected ...int) {
+		require.Eventually(t, func() bool {
+			for i, g := range peers {
+				if len(g.Peers()) != expected[i] {
+					return false
+				}
+			}
+			return true
+		}, 20*time.Second, 100*time.Millisecond)
+	}
+	waitForPeers(2, 2, 2)
+
+	mac := channel.GenerateMAC(peers[0].SelfPKIid(), "testchannel")
+	_, err := peers[0].CreateChain(mac, "testchannel", []*common.FileSyncInfo{})
+	require.NoError(t, err)
+	for _, g := range peers[1:] {
+		_, err = peers[0].AddMemberToChain(mac, g.SelfPKIid())
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		return chainFiles(peers[1], "testchannel") == 0 && chainFiles(peers[2], "testchannel") == 0
+	}, 20*time.Second, 100*time.Millisecond)
+
+	// the third peer is cut from the leader and the other member
+	network.Partition([]common.PKIidType{peers[0].SelfPKIid(), peers[1].SelfPKIid()}, []common.PKIidType{peers[2].SelfPKIid()})
+	assert.False(t, network.Connected(peers[2].SelfPKIid(), peers[0].SelfPKIid()))
+	waitForPeers(1, 1, 0)
+
+	_, err = peers[0].AddFileToChain(mac, []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return chainFiles(peers[1], "testchannel") == 1
+	}, 20*time.Second, 100*time.Millisecond)
+	assert.Equal(t, 0, chainFiles(peers[2], "testchannel"))
+
+	// once healed, the third peer catches up with the changes made meanwhile, even over a slow link
+	network.SetLatency(peers[0].SelfPKIid(), peers[2].SelfPKIid(), 100*time.Millisecond)
+	network.Heal()
+	waitForPeers(2, 2, 2)
+	require.Eventually(t, func() bool {
+		return chainFiles(peers[2], "testchannel") == 1
+	}, 20*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// replayGuard discards the signed messages stamped outside of the freshness window,
+// and the copies of the messages sent directly to the peer received within it.
+// Gossiped messages reach the peer several times legitimately, their copies are
+// handled by the deduplication cache, the alive timestamps and the chain state epochs.
+type replayGuard struct {
+	sync.Mutex
+	seen      map[string]int64 // envelope digest -> time the message leaves the window, in nanoseconds
+	nextSweep int64
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{seen: make(map[string]int64)}
+}
+
+// check returns an error if the message is stale, or a copy of a message received already.
+// Unsigned messages are bound to the authenticated connection they're received from, and the
+// messages signed by peers which don't stamp them have no timestamp, they aren't checked.
+func (r *replayGuard) check(msg *protos.SignedRKSyncMessage, maxAge time.Duration, now time.Time) error {
+	if msg.Envelope == nil || len(msg.Envelope.Signature) == 0 || msg.Timestamp == 0 {
+		return nil
+	}
+
+	stamped := time.Unix(0, msg.Timestamp)
+	if age := now.Sub(stamped); age > maxAge {
+		return errors.Errorf("Message was signed %s ago, more than %s", age, maxAge)
+	} else if -age > maxAge {
+		return errors.Errorf("Message is stamped %s in the future, more than %s", -age, maxAge)
+	}
+	if msg.IsAliveMsg() || msg.IsChainStateMsg() {
+		return nil
+	}
+
+	digest := envelopeDigest(msg.Envelope)
+	r.Lock()
+	defer r.Unlock()
+
+	r.sweep(now, maxAge)
+	if _, exists := r.seen[digest]; exists {
+		return errors.New("Message was received already")
+	}
+	r.seen[digest] = stamped.Add(maxAge).UnixNano()
+	return nil
+}
+
+// sweep forgets the messages which left the freshness window, at most every quarter of it
+func (r *replayGuard) sweep(now time.Time, maxAge time.Duration) {
+	if now.UnixNano() < r.nextSweep {
+		return
+	}
+	r.nextSweep = now.Add(maxAge / 4).UnixNano()
+	for digest, expiration := range r.seen {
+		if expiration < now.UnixNano() {
+			delete(r.seen, digest)
+		}
+	}
+}
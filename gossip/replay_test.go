@@ -0,0 +1,72 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayGuard(t *testing.T) {
+	guard := newReplayGuard()
+	signer := func(msg []byte) ([]byte, error) { return append([]byte("signature"), msg...), nil }
+	sign := func(msg *protos.RKSyncMessage) *protos.SignedRKSyncMessage {
+		sMsg := &protos.SignedRKSyncMessage{RKSyncMessage: msg}
+		_, err := sMsg.Sign(signer)
+		require.NoError(t, err)
+		return sMsg
+	}
+	leave := func() *protos.RKSyncMessage {
+		return &protos.RKSyncMessage{
+			Tag:     protos.RKSyncMessage_CHAN_ONLY,
+			Content: &protos.RKSyncMessage_LeaveChain{LeaveChain: &protos.LeaveChainMessage{ChainMac: []byte("mac")}},
+		}
+	}
+
+	msg := sign(leave())
+	now := time.Unix(0, msg.Timestamp)
+	assert.NotZero(t, msg.Timestamp)
+	assert.NoError(t, guard.check(msg, time.Minute, now))
+
+	// Copies of a message sent directly are replays
+	copied, err := msg.Envelope.ToRKSyncMessage()
+	require.NoError(t, err)
+	assert.Error(t, guard.check(copied, time.Minute, now.Add(time.Second)))
+
+	// Messages stamped outside of the window are stale
+	assert.Error(t, guard.check(sign(leave()), time.Minute, now.Add(-2*time.Minute)))
+	assert.Error(t, guard.check(copied, time.Minute, now.Add(2*time.Minute)))
+
+	// Gossiped messages are received several times
+	alive := sign(&protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}})
+	now = time.Unix(0, alive.Timestamp)
+	assert.NoError(t, guard.check(alive, time.Minute, now))
+	assert.NoError(t, guard.check(alive, time.Minute, now))
+	assert.Error(t, guard.check(alive, time.Minute, now.Add(2*time.Minute)))
+
+	// Unsigned and unstamped messages aren't checked
+	unsigned, err := leave().NoopSign()
+	require.NoError(t, err)
+	assert.NoError(t, guard.check(unsigned, time.Minute, now))
+	assert.NoError(t, guard.check(unsigned, time.Minute, now))
+	unstamped := &protos.SignedRKSyncMessage{RKSyncMessage: leave(), Envelope: &protos.Envelope{Payload: []byte("payload"), Signature: []byte("signature")}}
+	assert.NoError(t, guard.check(unstamped, time.Minute, now))
+
+	// The received messages are forgotten once they leave the window
+	guard.check(sign(leave()), time.Minute, now)
+	assert.Len(t, guard.seen, 2)
+	guard.check(unsigned, time.Minute, now.Add(time.Hour))
+	assert.Len(t, guard.seen, 2)
+	later := &protos.SignedRKSyncMessage{RKSyncMessage: leave(), Envelope: &protos.Envelope{Payload: []byte("later"), Signature: []byte("signature")}}
+	later.Timestamp = now.Add(30 * time.Minute).UnixNano()
+	assert.NoError(t, guard.check(later, time.Minute, now.Add(30*time.Minute)))
+	assert.Len(t, guard.seen, 1)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+)
+
+const (
+	// maxScore is the score of a peer which didn't misbehave
+	maxScore = 100
+	// scoreRecoveryRate is the number of points a peer recovers per minute
+	scoreRecoveryRate = 10
+	// reputationSweepInterval is the interval at which the peers fully recovered are forgotten
+	reputationSweepInterval = time.Minute
+)
+
+// penalties are the points a peer loses for each kind of misbehavior
+var penalties = map[common.Misbehavior]float64{
+	common.InvalidSignature:  25,
+	common.MalformedMessage:  10,
+	common.RateLimitExceeded: 5,
+	common.FailedTransfer:    20,
+}
+
+// PeerScore is the reputation of a remote peer
+type PeerScore struct {
+	PKIID        common.PKIidType
+	Score        int                           // From 0 to 100, lowered by the misbehaviors and recovering over time
+	Misbehaviors map[common.Misbehavior]uint64 // Number of misbehaviors of the peer, by kind
+	BannedUntil  time.Time                     // Time until which the peer is disconnected, zero if it isn't
+}
+
+type peerReputation struct {
+	score        float64
+	updated      time.Time
+	misbehaviors map[common.Misbehavior]uint64
+	bannedUntil  time.Time
+	windowStart  time.Time
+	received     int
+}
+
+// recover restores the points recovered since the score was last updated
+func (p *peerReputation) recover(now time.Time) {
+	if elapsed := now.Sub(p.updated); elapsed > 0 {
+		p.score += elapsed.Minutes() * scoreRecoveryRate
+		if p.score > maxScore {
+			p.score = maxScore
+		}
+	}
+	p.updated = now
+}
+
+// reputationTracker scores the remote peers according to their behavior.
+// Peers are tracked from their first misbehavior, or their first message when
+// their rate is limited, until they fully recovered.
+type reputationTracker struct {
+	sync.Mutex
+	peers     map[string]*peerReputation
+	nextSweep time.Time
+}
+
+func newReputationTracker() *reputationTracker {
+	return &reputationTracker{peers: make(map[string]*peerReputation)}
+}
+
+func (r *reputationTracker) peer(pkiID common.PKIidType, now time.Time) *peerReputation {
+	r.sweep(now)
+	p, exists := r.peers[string(pkiID)]
+	if !exists {
+		p = &peerReputation{score: maxScore, updated: now, misbehaviors: make(map[common.Misbehavior]uint64)}
+		r.peers[string(pkiID)] = p
+	}
+	p.recover(now)
+	return p
+}
+
+// penalize lowers the score of the peer for the given misbehavior,
+// it returns true if the peer gets banned for banTime as a result
+func (r *reputationTracker) penalize(pkiID common.PKIidType, kind common.Misbehavior, banTime time.Duration, now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.penalizeLocked(pkiID, kind, banTime, now)
+}
+
+func (r *reputationTracker) penalizeLocked(pkiID common.PKIidType, kind common.Misbehavior, banTime time.Duration, now time.Time) bool {
+	p := r.peer(pkiID, now)
+	p.misbehaviors[kind]++
+	p.score -= penalties[kind]
+	// The score recovers continuously, it's rounded down when checked
+	if p.score >= 1 || now.Before(p.bannedUntil) {
+		return false
+	}
+	p.score = 0
+	p.bannedUntil = now.Add(banTime)
+	return true
+}
+
+// receive counts a message received from the peer. It returns an error if the peer is banned,
+// or if it sent more than rate messages within the last second, in which case it is
+// penalized once per second and may get banned. The limit is disabled if rate is 0.
+func (r *reputationTracker) receive(pkiID common.PKIidType, rate int, banTime time.Duration, now time.Time) (banned bool, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	p, exists := r.peers[string(pkiID)]
+	if exists && now.Before(p.bannedUntil) {
+		return false, errors.Errorf("Peer is banned until %s", p.bannedUntil.Format(time.RFC3339))
+	}
+	if rate == 0 {
+		return false, nil
+	}
+
+	if !exists {
+		p = r.peer(pkiID, now)
+	}
+	if now.Sub(p.windowStart) >= time.Second {
+		p.windowStart = now
+		p.received = 0
+	}
+	p.received++
+	if p.received <= rate {
+		return false, nil
+	}
+	if p.received == rate+1 {
+		banned = r.penalizeLocked(pkiID, common.RateLimitExceeded, banTime, now)
+	}
+	return banned, errors.Errorf("Peer sent more than %d messages per second", rate)
+}
+
+// status returns whether the score of the peer is below the threshold, and whether it's banned
+func (r *reputationTracker) status(pkiID common.PKIidType, threshold int, now time.Time) (distrusted bool, banned bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	p, exists := r.peers[string(pkiID)]
+	if !exists {
+		return false, false
+	}
+	p.recover(now)
+	return p.score < float64(threshold), now.Before(p.bannedUntil)
+}
+
+// scores returns the scores of the tracked peers, ordered by score
+func (r *reputationTracker) scores(now time.Time) []PeerScore {
+	r.Lock()
+	defer r.Unlock()
+
+	r.sweep(now)
+	scores := make([]PeerScore, 0, len(r.peers))
+	for pkiID, p := range r.peers {
+		p.recover(now)
+		score := PeerScore{
+			PKIID:        common.PKIidType(pkiID),
+			Score:        int(p.score),
+			Misbehaviors: make(map[common.Misbehavior]uint64, len(p.misbehaviors)),
+		}
+		for kind, count := range p.misbehaviors {
+			score.Misbehaviors[kind] = count
+		}
+		if now.Before(p.bannedUntil) {
+			score.BannedUntil = p.bannedUntil
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].PKIID.String() < scores[j].PKIID.String()
+	})
+	return scores
+}
+
+// sweep forgets the peers which fully recovered and didn't send messages for a while,
+// at most every reputationSweepInterval
+func (r *reputationTracker) sweep(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	r.nextSweep = now.Add(reputationSweepInterval)
+	for pkiID, p := range r.peers {
+		p.recover(now)
+		if p.score >= maxScore && !now.Before(p.bannedUntil) && now.Sub(p.windowStart) >= reputationSweepInterval {
+			delete(r.peers, pkiID)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationTracker(t *testing.T) {
+	tracker := newReputationTracker()
+	peer := common.PKIidType("peer")
+	now := time.Now()
+
+	distrusted, banned := tracker.status(peer, 50, now)
+	assert.False(t, distrusted)
+	assert.False(t, banned)
+	assert.Empty(t, tracker.scores(now))
+
+	// Misbehaving peers fall below the threshold, and recover over time
+	assert.False(t, tracker.penalize(peer, common.InvalidSignature, time.Minute, now))
+	assert.False(t, tracker.penalize(peer, common.InvalidSignature, time.Minute, now))
+	assert.False(t, tracker.penalize(peer, common.MalformedMessage, time.Minute, now))
+	distrusted, banned = tracker.status(peer, 50, now)
+	assert.True(t, distrusted)
+	assert.False(t, banned)
+	scores := tracker.scores(now)
+	require.Len(t, scores, 1)
+	assert.Equal(t, 40, scores[0].Score)
+	assert.Equal(t, map[common.Misbehavior]uint64{common.InvalidSignature: 2, common.MalformedMessage: 1}, scores[0].Misbehaviors)
+
+	now = now.Add(2 * time.Minute)
+	distrusted, _ = tracker.status(peer, 50, now)
+	assert.False(t, distrusted)
+	assert.Equal(t, 60, tracker.scores(now)[0].Score)
+
+	// Peers whose score falls to 0 are banned, their messages are refused
+	assert.False(t, tracker.penalize(peer, common.FailedTransfer, time.Minute, now))
+	assert.False(t, tracker.penalize(peer, common.FailedTransfer, time.Minute, now))
+	assert.True(t, tracker.penalize(peer, common.FailedTransfer, time.Minute, now))
+	assert.False(t, tracker.penalize(peer, common.FailedTransfer, time.Minute, now))
+	_, banned = tracker.status(peer, 50, now)
+	assert.True(t, banned)
+	assert.Equal(t, now.Add(time.Minute), tracker.scores(now)[0].BannedUntil)
+	_, err := tracker.receive(peer, 0, time.Minute, now)
+	assert.Error(t, err)
+
+	now = now.Add(time.Minute)
+	_, banned = tracker.status(peer, 50, now)
+	assert.False(t, banned)
+	_, err = tracker.receive(peer, 0, time.Minute, now)
+	assert.NoError(t, err)
+
+	// Peers sending too many messages are penalized once per second
+	flooder := common.PKIidType("flooder")
+	for i := 0; i < 3; i++ {
+		_, err = tracker.receive(flooder, 3, time.Minute, now)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 5; i++ {
+		_, err = tracker.receive(flooder, 3, time.Minute, now)
+		assert.Error(t, err)
+	}
+	_, err = tracker.receive(flooder, 3, time.Minute, now.Add(time.Second))
+	assert.NoError(t, err)
+	for _, score := range tracker.scores(now) {
+		if score.PKIID.String() == flooder.String() {
+			assert.Equal(t, 95, score.Score)
+			assert.Equal(t, uint64(1), score.Misbehaviors[common.RateLimitExceeded])
+		}
+	}
+
+	// Fully recovered peers are forgotten
+	now = now.Add(time.Hour)
+	assert.Empty(t, tracker.scores(now))
+}
@@ -9,15 +9,20 @@ package gossip
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/channel/fsync"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/discovery"
@@ -29,74 +34,242 @@ import (
 	"github.com/rkcloudchain/rksync/rpc"
 	"github.com/rkcloudchain/rksync/util"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
 	presumedDeadChanSize = 100
 	acceptChanSize       = 100
+	drainPollInterval    = 100 * time.Millisecond
 )
 
-// NewGossipService creates a gossip instance attached to a gRPC server
+// NewGossipService creates a gossip instance attached to a gRPC server,
+// the remote peers are dialed with the options given by WithSecureDialOpts
 func NewGossipService(gConf *config.GossipConfig, idConf *config.IdentityConfig, s *grpc.Server,
-	selfIdentity common.PeerIdentityType, secureDialOpts func() []grpc.DialOption) (Gossip, error) {
+	selfIdentity common.PeerIdentityType, opts ...Option) (Gossip, error) {
 
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.secureDialOpts == nil {
+		return nil, errors.New("Must specify the secure dial options")
+	}
+	gConf = o.apply(gConf)
+
+	logger := logging.ForModule(gConf.Logger, "gossip", gConf.LogLevels)
+	clock := lib.ClockOrSystem(gConf.Clock)
+	ctx, cancel := context.WithCancel(context.Background())
 	g := &gossipService{
+		ctx:                   ctx,
+		cancel:                cancel,
+		clock:                 clock,
+		logger:                logger,
 		selfIdentity:          selfIdentity,
 		conf:                  gConf,
 		id:                    idConf.ID,
 		presumedDead:          make(chan common.PKIidType, presumedDeadChanSize),
-		toDieChan:             make(chan struct{}, 1),
-		stopFlag:              int32(0),
-		includeIdentityPeriod: time.Now().Add(gConf.PublishCertPeriod),
+		includeIdentityPeriod: clock.Now().Add(gConf.PublishCertPeriod),
 		ChannelDeMultiplexer:  rpc.NewChannelDemultiplexer(),
+		deadLetters:           newDeadLetterBox(gConf.DeadLetterHandler, gConf.DeadLetterRate, logger),
+		dedup:                 newDedupCache(gConf.DedupCacheSize, gConf.DedupCacheTTL),
+		validators:            newMessageValidators(),
+		replays:               newReplayGuard(),
+		reputation:            newReputationTracker(),
+		identityRequests:      newIdentityRequests(),
+		invitations:           newInvitations(gConf.InvitationsFile),
+		onMemberAccepted:      o.memberAccepted,
+		blocklist:             newPeerBlocklist(gConf.BlockedPeers, gConf.AllowedPeers),
+		health:                health.NewServer(),
+		events:                newEventBus(),
+	}
+	if gConf.MaxConcurrentTransfers > 0 {
+		g.transfers = fsync.NewScheduler(gConf.MaxConcurrentTransfers)
+	}
+	if err := g.invitations.load(); err != nil {
+		logger.Warningf("Failed loading the accepted invitations: %s", err)
 	}
-	g.chainStateMsgStore = g.newChainStateMsgStore()
-
 	var err error
+	g.chainStateMsgStore, err = g.newChainStateMsgStore()
+	if err != nil {
+		return nil, err
+	}
+
 	g.idMapper, err = identity.NewIdentity(idConf, selfIdentity, func(pkiID common.PKIidType) {
 		g.srv.CloseConn(&common.NetworkMember{PKIID: pkiID})
-	})
+	}, g.moduleLogger("identity"), identity.WithCache(identity.CacheConfig{
+		TTL:     gConf.IdentityCacheTTL,
+		MaxSize: gConf.IdentityCacheSize,
+		Referenced: func(pkiID common.PKIidType) bool {
+			return g.disc != nil && g.disc.Lookup(pkiID) != nil
+		},
+	}))
 	if err != nil {
 		return nil, err
 	}
 
 	g.selfPKIid = g.idMapper.GetPKIidOfCert(selfIdentity)
 	g.chanState = newChannelState(g)
-	g.srv = rpc.NewServer(s, g.idMapper, selfIdentity, secureDialOpts)
+	g.orphans = newOrphanCollector(g)
+	g.srv = rpc.NewServer(s, g.idMapper, selfIdentity, o.secureDialOpts, rpc.SendBufferConfig{
+		Size:           gConf.SendBuffSize,
+		ControlPolicy:  gConf.ControlOverflowPolicy,
+		DataPolicy:     gConf.DataOverflowPolicy,
+		MaxMessageSize: gConf.MaxMessageSize,
+		BlockTimeout:   gConf.SendBlockTimeout,
+	}, rpc.ConnPoolConfig{
+		MaxConnections:     gConf.MaxConnections,
+		IdleConnTimeout:    gConf.IdleConnTimeout,
+		MaxHandshakes:      gConf.MaxHandshakes,
+		HandshakeRate:      gConf.HandshakeRate,
+		MinProtocolVersion: gConf.MinProtocolVersion,
+		Admit:              g.blocklist.check,
+	}, rpc.BreakerConfig{
+		Threshold: gConf.CircuitBreakerThreshold,
+		Timeout:   gConf.CircuitBreakerTimeout,
+	}, g.moduleLogger("rpc"))
 	g.emitter = newBatchingEmitter(gConf.PropagateIterations, gConf.MaxPropagationBurstSize,
-		gConf.MaxPropagationBurstLatency, g.sendGossipBatch)
+		gConf.MaxPropagationBurstLatency, clock, g.sendGossipBatch)
 
 	g.discAdapter = g.newDiscoveryAdapter()
-	g.disc = discovery.NewDiscoveryService(g.selfNetworkMember(), g.discAdapter, g.newDiscoverySecurityAdapter())
-	logging.Infof("Creating gossip service with self membership of %s", g.selfNetworkMember())
-
-	g.stopSignal.Add(2)
-	go g.start()
+	var disclose func(remotePeer, peer common.NetworkMember) bool
+	if policy := gConf.DisclosurePolicy; policy != nil {
+		disclose = policy.Disclose
+	}
+	g.disclosurePolicy = discovery.NewSievingDisclosurePolicy(g.selfNetworkMember(), disclose)
+	discConf := discovery.Config{
+		AliveTimeInterval:      gConf.AliveTimeInterval,
+		AliveExpirationTimeout: gConf.AliveExpirationTimeout,
+		ReconnectInterval:      gConf.ReconnectInterval,
+		MaxReconnectInterval:   gConf.MaxReconnectInterval,
+		Clock:                  clock,
+		DisclosurePolicy:       g.disclosurePolicy,
+	}
+	switch {
+	case o.discoveryFactory != nil:
+		g.disc = o.discoveryFactory(g.selfNetworkMember(), g.discAdapter, g.moduleLogger("discovery"))
+	case gConf.StaticTopology:
+		g.disc = discovery.NewStaticDiscovery(g.selfNetworkMember(), g.discAdapter, discConf, g.moduleLogger("discovery"))
+	default:
+		g.secAdapter = g.newDiscoverySecurityAdapter()
+		g.disc = discovery.NewDiscoveryService(g.selfNetworkMember(), g.discAdapter, g.secAdapter, discConf, g.moduleLogger("discovery"))
+	}
+	g.logger.Infof("Creating gossip service with self membership of %s", g.selfNetworkMember())
+
+	grpc_health_v1.RegisterHealthServer(s, g.health)
+
+	g.start()
 	go g.connect2BootstrapPeers()
+	g.loops.Add(2)
+	go g.periodicalCheckHealth()
+	go g.periodicalCollectOrphans()
 
 	return g, nil
 }
 
+// NewGossipServiceWithDialOpts creates a gossip instance attached to a gRPC server,
+// the remote peers are dialed with the given options.
+//
+// Deprecated: use NewGossipService with WithSecureDialOpts instead.
+func NewGossipServiceWithDialOpts(gConf *config.GossipConfig, idConf *config.IdentityConfig, s *grpc.Server,
+	selfIdentity common.PeerIdentityType, secureDialOpts func() []grpc.DialOption) (Gossip, error) {
+	return NewGossipService(gConf, idConf, s, selfIdentity, WithSecureDialOpts(secureDialOpts))
+}
+
 type gossipService struct {
 	id                    string
 	selfIdentity          common.PeerIdentityType
 	selfPKIid             common.PKIidType
 	includeIdentityPeriod time.Time
+	clock                 lib.Clock
 	idMapper              identity.Identity
 	srv                   *rpc.Server
 	conf                  *config.GossipConfig
+	confLock              sync.RWMutex
 	emitter               batchingEmitter
+	workers               atomic.Value // *messageWorkers handling the received messages
 	disc                  discovery.Discovery
-	stopSignal            sync.WaitGroup
-	stopFlag              int32
-	toDieChan             chan struct{}
+	disclosurePolicy      discovery.DisclosurePolicy
+	ctx                   context.Context // cancelled by Stop, the goroutines of the instance exit on ctx.Done()
+	cancel                context.CancelFunc
+	stopOnce              sync.Once
+	lifecycle             sync.RWMutex   // orders the goroutines tracked by the wait groups before the cancellation
+	loops                 sync.WaitGroup // periodic tasks: health checks, orphan sweeps, discovery sync, presumed dead peers
+	receivers             sync.WaitGroup // goroutines handing the received messages over: acceptMessages and the Accept forwarders
+	drainFlag             int32
 	presumedDead          chan common.PKIidType
 	discAdapter           *discoveryAdapter
+	secAdapter            *discoverySecurityAdapter // signs the alive messages, nil without discovery
 	chanState             *channelState
+	orphans               *orphanCollector
 	chainStateMsgStore    lib.MessageStore
+	chainStateLocks       [chainStateLockStripes]sync.Mutex
+	deadLetters           *deadLetterBox
+	dedup                 *dedupCache
+	validators            *messageValidators
+	transfers             *fsync.Scheduler // nil if the transfers aren't limited
+	replays               *replayGuard
+	reputation            *reputationTracker
+	identityRequests      *identityRequests
+	invitations           *invitations
+	onMemberAccepted      MemberAcceptedHandler
+	blocklist             *peerBlocklist
+	health                *health.Server
+	events                *eventBus
+	logger                logging.Logger
 	*rpc.ChannelDeMultiplexer
 }
 
+// moduleLogger returns the logger of a module of the gossip instance
+func (g *gossipService) moduleLogger(module string) logging.Logger {
+	conf := g.getConf()
+	return logging.ForModule(conf.Logger, module, conf.LogLevels)
+}
+
+func (g *gossipService) getConf() *config.GossipConfig {
+	g.confLock.RLock()
+	defer g.confLock.RUnlock()
+	return g.conf
+}
+
+// fileSystem returns the FileSystem the files of the channel are stored in
+func (g *gossipService) fileSystem(chainID string) config.FileSystem {
+	conf := g.getConf()
+	return config.ChannelFileSystem(conf.FileSystemFactory, conf.FileSystem, chainID)
+}
+
+func (g *gossipService) ApplyConfig(update *config.GossipConfigUpdate) error {
+	g.confLock.Lock()
+	prev := g.conf
+	if update.BootstrapPeers != nil && prev.StaticTopology {
+		g.confLock.Unlock()
+		return errors.New("Bootstrap peers of a static topology can't be changed")
+	}
+	conf, err := update.Apply(prev)
+	if err != nil {
+		g.confLock.Unlock()
+		return err
+	}
+	g.conf = conf
+	g.confLock.Unlock()
+
+	g.emitter.SetBurst(conf.MaxPropagationBurstSize, conf.MaxPropagationBurstLatency)
+
+	known := make(map[string]struct{}, len(prev.BootstrapPeers))
+	for _, endpoint := range prev.BootstrapPeers {
+		known[endpoint] = struct{}{}
+	}
+	for _, endpoint := range conf.BootstrapPeers {
+		if _, exists := known[endpoint]; !exists {
+			g.logger.Infof("Connecting to new bootstrap peer %s", endpoint)
+			g.connect2Peer(endpoint)
+		}
+	}
+	return nil
+}
+
 func (g *gossipService) SelfChainInfo(chainID string) *protos.ChainState {
 	ch := g.chanState.getChannelByChainID(chainID)
 	if ch == nil {
@@ -113,7 +286,140 @@ func (g *gossipService) Peers() []common.NetworkMember {
 	if g.toDie() {
 		return []common.NetworkMember{}
 	}
-	return g.disc.GetMembership()
+	return g.unblockedMembers()
+}
+
+// membership returns the alive members along with their measured latency,
+// the members the rpc layer stopped sending messages to and the banned members
+// are marked unreachable, the members with a low reputation are marked distrusted
+func (g *gossipService) membership() []common.NetworkMember {
+	threshold := g.getConf().ReputationThreshold
+	now := g.clock.Now()
+	members := g.unblockedMembers()
+	for i := range members {
+		distrusted, banned := g.reputation.status(members[i].PKIID, threshold, now)
+		members[i].Unreachable = banned || g.srv.CircuitOpen(members[i].PKIID)
+		members[i].Distrusted = distrusted
+		members[i].Latency = g.srv.Latency(members[i].PKIID)
+	}
+	return members
+}
+
+// unblockedMembers returns the alive members which aren't blocked, the blocked ones
+// remain in the membership of the discovery until their alive messages expire
+func (g *gossipService) unblockedMembers() []common.NetworkMember {
+	members := g.disc.GetMembership()
+	res := members[:0]
+	for _, member := range members {
+		if g.checkBlocked(member.PKIID) == nil {
+			res = append(res, member)
+		}
+	}
+	return res
+}
+
+// checkBlocked returns an error if the peer is blocked or not allowed
+func (g *gossipService) checkBlocked(pkiID common.PKIidType) error {
+	if !g.blocklist.enabled() {
+		return nil
+	}
+	identity, _ := g.idMapper.Get(pkiID)
+	return g.blocklist.check(pkiID, identity)
+}
+
+func (g *gossipService) BlockPeer(id string) {
+	g.blocklist.block(id)
+	g.logger.Infof("Blocking peer %s", id)
+	for _, stats := range g.srv.ConnectionStats() {
+		if g.checkBlocked(stats.PKIID) != nil {
+			g.srv.CloseConn(&common.NetworkMember{PKIID: stats.PKIID, Endpoint: stats.Endpoint})
+		}
+	}
+}
+
+func (g *gossipService) UnblockPeer(id string) {
+	if g.blocklist.unblock(id) {
+		g.logger.Infof("Unblocking peer %s", id)
+	}
+}
+
+func (g *gossipService) BlockedPeers() []string {
+	return g.blocklist.list()
+}
+
+func (g *gossipService) PeerScores() []PeerScore {
+	return g.reputation.scores(g.clock.Now())
+}
+
+// ReportMisbehavior lowers the reputation of the peer, which is disconnected
+// and its messages discarded for a while once its score falls to 0
+func (g *gossipService) ReportMisbehavior(pkiID common.PKIidType, kind common.Misbehavior) {
+	if len(pkiID) == 0 || bytes.Equal(pkiID, g.selfPKIid) {
+		return
+	}
+	banTime := g.getConf().ReputationBanTime
+	if g.reputation.penalize(pkiID, kind, banTime, g.clock.Now()) {
+		g.ban(pkiID, banTime)
+	}
+}
+
+func (g *gossipService) ban(pkiID common.PKIidType, banTime time.Duration) {
+	g.logger.Warningf("Disconnecting peer %s for %s, its reputation fell to 0", pkiID, banTime)
+	g.srv.CloseConn(&common.NetworkMember{PKIID: pkiID})
+}
+
+func (g *gossipService) DroppedMessages() rpc.DropCounts {
+	dropped := g.srv.DroppedMessages()
+	dropped.Received += g.ChannelDeMultiplexer.Dropped()
+	return dropped
+}
+
+func (g *gossipService) ConnectionStats() []rpc.ConnectionStats {
+	return g.srv.ConnectionStats()
+}
+
+func (g *gossipService) QueueDepths() []rpc.QueueDepth {
+	depths := []rpc.QueueDepth{{Name: "emitter", Length: g.emitter.Size()}}
+	if workers, ok := g.workers.Load().(*messageWorkers); ok {
+		depths = append(depths, workers.queueDepths()...)
+	}
+	depths = append(depths, g.ChannelDeMultiplexer.QueueDepths("channel")...)
+	return append(depths, g.srv.QueueDepths()...)
+}
+
+func (g *gossipService) DedupStats() DedupStats {
+	return g.dedup.getStats()
+}
+
+// RegisterMessageValidator adds a rule the received messages must satisfy,
+// a rule registered under the same name is replaced
+func (g *gossipService) RegisterMessageValidator(rule string, validator MessageValidator) {
+	g.validators.register(rule, validator)
+}
+
+func (g *gossipService) RejectedMessages() map[string]uint64 {
+	return g.validators.rejected()
+}
+
+func (g *gossipService) IdentityStats() identity.CacheStats {
+	return g.idMapper.Stats()
+}
+
+func (g *gossipService) SuspectPeers(isSuspected func(common.PeerIdentityType) bool) {
+	g.idMapper.SuspectPeers(isSuspected)
+}
+
+func (g *gossipService) SendToGroup(tag string, msg *protos.SignedRKSyncMessage) {
+	if g.toDie() {
+		return
+	}
+
+	peers := filter.SelectAllPeers(g.unblockedMembers(), filter.SelectByTag(tag))
+	if len(peers) == 0 {
+		g.logger.Debugf("No alive peer is assigned the tag %s", tag)
+		return
+	}
+	g.srv.Send(msg, peers...)
 }
 
 func (g *gossipService) Accept(acceptor common.MessageAcceptor, mac []byte, passThrough bool) (<-chan *protos.RKSyncMessage, <-chan protos.ReceivedMessage) {
@@ -127,38 +433,54 @@ func (g *gossipService) Accept(acceptor common.MessageAcceptor, mac []byte, pass
 		if o, isSignedMsg := o.(*protos.SignedRKSyncMessage); isSignedMsg {
 			return acceptor(o.RKSyncMessage)
 		}
-		logging.Warning("Message type: ", reflect.TypeOf(o), "cannot be evaluated")
+		g.logger.Warning("Message type: ", reflect.TypeOf(o), "cannot be evaluated")
 		return false
 	}
 
 	inCh := g.AddChannelWithMAC(acceptByType, mac)
 	outCh := make(chan *protos.RKSyncMessage, acceptChanSize)
+	if !g.track(&g.receivers) {
+		return outCh, nil
+	}
 	go func() {
+		defer g.receivers.Done()
 		for {
 			select {
-			case s := <-g.toDieChan:
-				g.toDieChan <- s
+			case <-g.ctx.Done():
 				return
 			case m := <-inCh:
 				if m == nil {
 					return
 				}
-				outCh <- m.(*protos.SignedRKSyncMessage).RKSyncMessage
+				select {
+				case outCh <- m.(*protos.SignedRKSyncMessage).RKSyncMessage:
+				case <-g.ctx.Done():
+					return
+				}
 			}
 		}
 	}()
 	return outCh, nil
 }
 
+func (g *gossipService) Events() (<-chan common.Event, func()) {
+	return g.events.subscribe()
+}
+
+// EmitEvent publishes an event to the subscribers of the instance
+func (g *gossipService) EmitEvent(event common.Event) {
+	g.events.publish(event)
+}
+
 func (g *gossipService) InitializeChain(chainMac common.ChainMac, chainState *protos.ChainState) error {
 	if len(chainMac) == 0 {
 		return errors.New("Channel mac can't be nil or empty")
 	}
 	if g.toDie() {
-		return errors.New("RKSync service is stopping")
+		return ErrStopping
 	}
-	if c := g.chanState.getChannelByMAC(chainMac); c != nil {
-		return errors.Errorf("Channel (%s) already exists", chainMac)
+	if g.draining() {
+		return ErrDraining
 	}
 
 	signedMsg, err := chainState.Envelope.ToRKSyncMessage()
@@ -166,29 +488,43 @@ func (g *gossipService) InitializeChain(chainMac common.ChainMac, chainState *pr
 		return errors.Wrapf(err, "Failed to parse channel %s state information", chainMac)
 	}
 
-	err = signedMsg.Verify(g.selfPKIid, func(peerIdentity []byte, signature, message []byte) error {
-		return g.idMapper.Verify(peerIdentity, signature, message)
-	})
-	if err != nil {
-		return errors.Wrapf(err, "Failed verifying %s chain state information signature: %s", chainMac, err)
-	}
-
 	stateInfo, err := chainState.GetChainStateInfo()
 	if err != nil {
 		return errors.Errorf("Channel %s: state information format error: %s", chainMac, err)
 	}
 	if !bytes.Equal(common.PKIidType(stateInfo.Leader), g.selfPKIid) {
-		return errors.Errorf("Channel %s: current peer's PKI-ID (%s) doesn't match the leader PKI-ID (%s)", chainMac, g.selfPKIid, common.PKIidType(stateInfo.Leader))
+		return errors.Wrapf(ErrNotLeader, "Channel %s is led by %s", chainMac, common.PKIidType(stateInfo.Leader))
+	}
+
+	err = signedMsg.Verify(g.selfPKIid, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return g.idMapper.Verify(peerIdentity, algorithm, signature, message)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed verifying %s chain state information signature: %s", chainMac, err)
 	}
 
-	gc := g.chanState.joinChannel(chainMac, chainState.ChainId, true)
-	return gc.InitializeWithChainState(chainState)
+	gc, joined := g.chanState.joinChannel(chainMac, chainState.ChainId, true)
+	if gc == nil {
+		return ErrStopping
+	}
+	if !joined {
+		// Initializing the channel again with the same state is a no-op, so that it can be retried
+		if proto.Equal(gc.Self(), chainState) {
+			return nil
+		}
+		return errors.Wrapf(ErrChannelExists, "Channel %s", chainMac)
+	}
+	if err := gc.InitializeWithChainState(chainState); err != nil {
+		g.chanState.discardChannel(chainMac, gc)
+		return err
+	}
+	return nil
 }
 
 func (g *gossipService) AddMemberToChain(chainMac common.ChainMac, member common.PKIidType) (*protos.ChainState, error) {
 	gc := g.chanState.getChannelByMAC(chainMac)
 	if gc == nil {
-		return nil, errors.Errorf("Channel %s not yet created", chainMac)
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
 	}
 
 	return gc.AddMember(member)
@@ -197,7 +533,7 @@ func (g *gossipService) AddMemberToChain(chainMac common.ChainMac, member common
 func (g *gossipService) RemoveMemberWithChain(chainMac common.ChainMac, member common.PKIidType) (*protos.ChainState, error) {
 	gc := g.chanState.getChannelByMAC(chainMac)
 	if gc == nil {
-		return nil, errors.Errorf("Channel %s not yet created", chainMac)
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
 	}
 
 	return gc.RemoveMember(member)
@@ -206,7 +542,7 @@ func (g *gossipService) RemoveMemberWithChain(chainMac common.ChainMac, member c
 func (g *gossipService) AddFileToChain(chainMac common.ChainMac, files []*common.FileSyncInfo) (*protos.ChainState, error) {
 	gc := g.chanState.getChannelByMAC(chainMac)
 	if gc == nil {
-		return nil, errors.Errorf("Channel %s not yet created", chainMac)
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
 	}
 
 	return gc.AddFile(files)
@@ -215,12 +551,30 @@ func (g *gossipService) AddFileToChain(chainMac common.ChainMac, files []*common
 func (g *gossipService) RemoveFileWithChain(chainMac common.ChainMac, filenames []string) (*protos.ChainState, error) {
 	gc := g.chanState.getChannelByMAC(chainMac)
 	if gc == nil {
-		return nil, errors.Errorf("Channel %s not yet created", chainMac)
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
 	}
 
 	return gc.RemoveFile(filenames)
 }
 
+func (g *gossipService) PublishFileVersion(chainMac common.ChainMac, filename string) (*protos.ChainState, error) {
+	gc := g.chanState.getChannelByMAC(chainMac)
+	if gc == nil {
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
+	}
+
+	return gc.PublishFileVersion(filename)
+}
+
+func (g *gossipService) SetAnchorPeers(chainMac common.ChainMac, endpoints []string) (*protos.ChainState, error) {
+	gc := g.chanState.getChannelByMAC(chainMac)
+	if gc == nil {
+		return nil, errors.Wrapf(ErrChannelNotExist, "Channel %s", chainMac)
+	}
+
+	return gc.SetAnchorPeers(endpoints)
+}
+
 func (g *gossipService) GetPKIidOfCert(nodeID string, cert *x509.Certificate) (common.PKIidType, error) {
 	nodeIDRaw := []byte(nodeID)
 	pb := &pem.Block{Bytes: cert.Raw, Type: "CERTIFICATE"}
@@ -242,15 +596,26 @@ func (g *gossipService) CreateChain(chainMac common.ChainMac, chainID string, fi
 		return nil, errors.New("Channel ID must be provided")
 	}
 	if g.toDie() {
-		return nil, errors.New("RKSync service is stopping")
+		return nil, ErrStopping
 	}
-
-	if c := g.chanState.getChannelByMAC(chainMac); c != nil {
-		return nil, ErrChannelExist
+	if g.draining() {
+		return nil, ErrDraining
 	}
 
-	gc := g.chanState.joinChannel(chainMac, chainID, true)
-	return gc.Initialize(chainID, []common.PKIidType{g.selfPKIid}, files)
+	gc, joined := g.chanState.joinChannel(chainMac, chainID, true)
+	if gc == nil {
+		return nil, ErrStopping
+	}
+	if !joined {
+		return nil, errors.Wrapf(ErrChannelExists, "Channel %s", chainID)
+	}
+	chainState, err := gc.Initialize(chainID, []common.PKIidType{g.selfPKIid}, files)
+	if err != nil {
+		// The channel is forgotten, so that its creation can be retried
+		g.chanState.discardChannel(chainMac, gc)
+		return nil, err
+	}
+	return chainState, nil
 }
 
 func (g *gossipService) CloseChain(chainMac common.ChainMac, notify bool) error {
@@ -270,22 +635,21 @@ func (g *gossipService) CloseChain(chainMac common.ChainMac, notify bool) error
 	if !msg.IsStateInfoMsg() {
 		return errors.New("Channel state message isn't well formatted")
 	}
-	err = msg.Verify(g.selfPKIid, func(peerIdentity []byte, signature, message []byte) error {
-		return g.idMapper.Verify(peerIdentity, signature, message)
+	chainInfo := msg.GetStateInfo()
+	if !bytes.Equal(g.selfPKIid, common.PKIidType(chainInfo.Leader)) {
+		return errors.WithMessage(ErrNotLeader, "Only the channel leader can close the channel")
+	}
+	err = msg.Verify(g.selfPKIid, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return g.idMapper.Verify(peerIdentity, algorithm, signature, message)
 	})
 	if err != nil {
 		return errors.Wrap(err, "Failed verifying ChainStateInfo message")
 	}
-
-	chainInfo := msg.GetStateInfo()
-	if !bytes.Equal(g.selfPKIid, common.PKIidType(chainInfo.Leader)) {
-		return errors.New("Only the channel leader can close the channel")
-	}
 	filterFunc := gc.IsMemberInChan
 
 	closed := g.chanState.closeChannel(chainMac)
 	if notify && closed {
-		peers := filter.SelectAllPeers(g.disc.GetMembership(), filterFunc)
+		peers := filter.SelectAllPeers(g.unblockedMembers(), filterFunc)
 		go g.publishLeaveChainMsg(chainMac, peers...)
 	}
 	return nil
@@ -305,47 +669,135 @@ func (g *gossipService) CreateLeaveChainMessage(chainMac common.ChainMac) (*prot
 		},
 	}
 
-	_, err := msg.Sign(func(msg []byte) ([]byte, error) {
+	_, err := msg.SignWithAlgorithm(g.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 		return g.idMapper.Sign(msg)
 	})
 
 	if err != nil {
-		logging.Errorf("Failed signing LeaveChainMessage: %v", err)
+		g.logger.Errorf("Failed signing LeaveChainMessage: %v", err)
 		return nil, err
 	}
 
 	return msg, nil
 }
 
-func (g *gossipService) Stop() {
+func (g *gossipService) RegisterAdminServer(s *grpc.Server, channels ChannelAdmin) {
+	protos.RegisterAdminServer(s, &adminServer{g: g, channels: channels})
+}
+
+func (g *gossipService) Drain(ctx context.Context) error {
 	if g.toDie() {
-		return
+		return ErrStopping
 	}
+	if !atomic.CompareAndSwapInt32(&g.drainFlag, int32(0), int32(1)) {
+		return errors.New("RKSync service is already draining")
+	}
+
+	g.logger.Infof("Draining gossip instance: %s", g.id)
+	g.health.Shutdown()
+	g.disc.Leave()
+	defer g.Stop()
+
+	// The alive message announcing we are leaving is batched by the emitter
+	wait := g.getConf().MaxPropagationBurstLatency
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "Failed waiting for the file transfers to finish")
+		case <-g.clock.After(wait):
+		}
+		if !g.chanState.transferring() {
+			return nil
+		}
+		wait = drainPollInterval
+	}
+}
 
-	atomic.StoreInt32(&g.stopFlag, int32(1))
-	logging.Infof("Stopping gossip instance: %s", g.id)
-	defer logging.Infof("Stopped gossip instance: %s", g.id)
+func (g *gossipService) Done() <-chan struct{} {
+	return g.ctx.Done()
+}
+
+func (g *gossipService) Stop() {
+	g.stopOnce.Do(g.stop)
+}
+
+// stop cancels the context of the instance, then stops its subsystems from the top down:
+// the goroutines of a subsystem are waited for before the subsystems they use are stopped
+func (g *gossipService) stop() {
+	g.lifecycle.Lock()
+	g.cancel()
+	g.lifecycle.Unlock()
+	g.logger.Infof("Stopping gossip instance: %s", g.id)
+	defer g.logger.Infof("Stopped gossip instance: %s", g.id)
+	g.health.Shutdown()
 	g.chanState.stop()
+	g.persistMembership()
 	g.disc.Stop()
 	g.discAdapter.close()
-	g.toDieChan <- struct{}{}
 	g.emitter.Stop()
 	g.ChannelDeMultiplexer.Close()
-	g.stopSignal.Wait()
+	g.loops.Wait()
+	g.receivers.Wait()
 	g.srv.Stop()
+	g.idMapper.Stop()
+	g.events.close()
 }
 
 func (g *gossipService) selfNetworkMember() common.NetworkMember {
-	return common.NetworkMember{
-		Endpoint: g.conf.Endpoint,
+	conf := g.getConf()
+	self := common.NetworkMember{
+		Endpoint: conf.Endpoint,
 		PKIID:    g.srv.GetPKIid(),
+		Tags:     g.selfTags(),
+		Zone:     conf.Zone,
+		Org:      identityOrg(g.selfIdentity),
+		Labels:   conf.Labels,
+	}
+	if conf.ExternalEndpoint != "" && conf.ExternalEndpoint != conf.Endpoint {
+		self.Endpoint = conf.ExternalEndpoint
+		self.InternalEndpoint = conf.Endpoint
+	}
+	return self
+}
+
+// selfTags returns the tags configured for this peer together with
+// the "key=value" organizational units found in its certificate
+func (g *gossipService) selfTags() []string {
+	tags := append([]string{}, g.getConf().Tags...)
+
+	sid := &protos.SerializedIdentity{}
+	if err := proto.Unmarshal(g.selfIdentity, sid); err != nil {
+		g.logger.Warningf("Failed unmarshalling self identity: %s", err)
+		return tags
 	}
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		g.logger.Warningf("Failed parsing self certificate: %s", err)
+		return tags
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if strings.Contains(ou, "=") {
+			tags = append(tags, ou)
+		}
+	}
+	return tags
+}
+
+// identityOrg returns the organization of the certificate of the identity,
+// empty if it isn't a valid SerializedIdentity
+func identityOrg(peerIdentity common.PeerIdentityType) string {
+	org, err := identity.IdentityOrg(peerIdentity)
+	if err != nil {
+		return ""
+	}
+	return org
 }
 
 func (g *gossipService) publishLeaveChainMsg(chainMac common.ChainMac, peers ...*common.NetworkMember) {
 	msg, err := g.CreateLeaveChainMessage(chainMac)
 	if err != nil {
-		logging.Errorf("Failed creating LeaveChainMessage for channel %s: %s", chainMac, err)
+		g.logger.Errorf("Failed creating LeaveChainMessage for channel %s: %s", chainMac, err)
 		return
 	}
 
@@ -354,11 +806,11 @@ func (g *gossipService) publishLeaveChainMsg(chainMac common.ChainMac, peers ...
 		if res.Error() == "" {
 			continue
 		}
-		logging.Warningf("Failed sending to %s, error: %s", res.Endpoint, res.Error())
+		g.logger.Warningf("Failed sending to %s, error: %s", res.Endpoint, res.Error())
 	}
 
 	if results.AckCount() < len(peers) {
-		logging.Errorf("Publish LeaveChainMessage occurred error(s): %s", results.String())
+		g.logger.Errorf("Publish LeaveChainMessage occurred error(s): %s", results.String())
 	}
 }
 
@@ -372,10 +824,16 @@ func (g *gossipService) sendGossipBatch(a []interface{}) {
 
 func (g *gossipService) gossipBatch(msgs []*emittedRKSyncMessage) {
 	if g.disc == nil {
-		logging.Error("Discovery has not been initialized yet, aborting")
+		g.logger.Error("Discovery has not been initialized yet, aborting")
 		return
 	}
 
+	// Messages of higher priority are sent first
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].priority > msgs[j].priority
+	})
+
+	conf := g.getConf()
 	var chainStateMsgs []*emittedRKSyncMessage
 
 	isAChainStateMsg := func(o interface{}) bool {
@@ -392,26 +850,107 @@ func (g *gossipService) gossipBatch(msgs []*emittedRKSyncMessage) {
 			peerSelector = filter.CombineRoutingFilters(peerSelector, gc.IsMemberInChan)
 		}
 
-		peers2Send := filter.SelectPeers(g.conf.PropagatePeerNum, g.disc.GetMembership(), peerSelector)
+		peerNum := conf.PropagatePeerNum
+		if chainStateMsg.propagation.PeerNum > 0 {
+			peerNum = chainStateMsg.propagation.PeerNum
+		}
+		peers2Send := filter.SelectPeersAcrossZones(peerNum, g.membership(), peerSelector, conf.Zone)
 		g.srv.Send(chainStateMsg.SignedRKSyncMessage, peers2Send...)
 	}
 
 	for _, msg := range msgs {
 		if !msg.IsAliveMsg() {
-			logging.Error("Unknow message type", msg)
+			g.deadLetters.report(nil, msg.RKSyncMessage, "Unknown message type")
 			continue
 		}
 
 		selector := filter.CombineRoutingFilters(filter.SelectAllPolicy, func(member common.NetworkMember) bool {
 			return msg.filter(member.PKIID)
+		}, func(member common.NetworkMember) bool {
+			shouldBeDisclosed, _ := g.disclosurePolicy(&member)
+			return shouldBeDisclosed(msg.SignedRKSyncMessage)
 		})
-		peers2Send := filter.SelectPeers(g.conf.PropagatePeerNum, g.disc.GetMembership(), selector)
-		g.srv.Send(msg.SignedRKSyncMessage, peers2Send...)
+		peers2Send := filter.SelectPeersAcrossZones(conf.PropagatePeerNum, g.membership(), selector, conf.Zone)
+		g.sendAndFilterSecrets(msg.SignedRKSyncMessage, peers2Send...)
+	}
+}
+
+// gossip emits a message originated by this peer, limiting
+// the number of times it is forwarded according to its type
+func (g *gossipService) gossip(msg *protos.SignedRKSyncMessage) {
+	g.gossipWith(msg, config.Propagation{})
+}
+
+// gossipWith emits a message to the peers with the given propagation settings,
+// its zero values keep the gossip ones
+func (g *gossipService) gossipWith(msg *protos.SignedRKSyncMessage, propagation config.Propagation) {
+	// The envelope may be shared, e.g. with the discovery message store
+	envp := msg.Envelope.WithHopsLeft(g.hopLimit(msg))
+	g.emitter.Add(&emittedRKSyncMessage{
+		SignedRKSyncMessage: &protos.SignedRKSyncMessage{RKSyncMessage: msg.RKSyncMessage, Envelope: envp},
+		filter:              func(_ common.PKIidType) bool { return true },
+		priority:            msg.Priority(),
+		propagation:         propagation,
+	})
+}
+
+// forward emits a message received from a remote peer to the other
+// peers, unless the message has reached its hop limit
+func (g *gossipService) forward(m protos.ReceivedMessage) {
+	g.forwardWith(m, config.Propagation{})
+}
+
+// forwardWith forwards a message received from a remote peer with the given propagation settings
+func (g *gossipService) forwardWith(m protos.ReceivedMessage, propagation config.Propagation) {
+	msg := m.GetRKSyncMessage()
+	envp, ok := msg.Envelope.NextHop(g.hopLimit(msg))
+	if !ok {
+		g.logger.Debug("Not forwarding", msg, "it has reached its hop limit")
+		return
+	}
+
+	g.emitter.Add(&emittedRKSyncMessage{
+		SignedRKSyncMessage: &protos.SignedRKSyncMessage{RKSyncMessage: msg.RKSyncMessage, Envelope: envp},
+		filter:              m.GetConnectionInfo().ID.IsNotSameFilter,
+		priority:            msg.Priority(),
+		propagation:         propagation,
+	})
+}
+
+// hopLimit returns the max number of hops of the message, 0 for the messages which aren't forwarded
+func (g *gossipService) hopLimit(msg *protos.SignedRKSyncMessage) uint32 {
+	switch {
+	case msg.IsAliveMsg():
+		return uint32(g.getConf().AliveMsgTTL)
+	case msg.IsChainStateMsg():
+		return uint32(g.getConf().ChainStateMsgTTL)
+	}
+	return 0
+}
+
+// sendAndFilterSecrets sends the message to the peers, stripping
+// the parts of its envelope the disclosure policy doesn't allow them to see
+func (g *gossipService) sendAndFilterSecrets(msg *protos.SignedRKSyncMessage, peers ...*common.NetworkMember) {
+	// The peers allowed to see the whole envelope are sent it at once, so that it's marshaled once for all
+	var unfiltered []*common.NetworkMember
+	for _, peer := range peers {
+		_, envelopeFilter := g.disclosurePolicy(peer)
+		envp := envelopeFilter(msg)
+		if envp == msg.Envelope {
+			unfiltered = append(unfiltered, peer)
+			continue
+		}
+		g.srv.Send(&protos.SignedRKSyncMessage{RKSyncMessage: msg.RKSyncMessage, Envelope: envp}, peer)
 	}
+	g.srv.Send(msg, unfiltered...)
 }
 
 func (g *gossipService) start() {
-	go g.syncDiscovery()
+	if !g.getConf().StaticTopology {
+		g.loops.Add(1)
+		go g.syncDiscovery()
+	}
+	g.loops.Add(1)
 	go g.handlePresumedDead()
 
 	msgSelector := func(msg interface{}) bool {
@@ -428,21 +967,26 @@ func (g *gossipService) start() {
 
 	incMsgs := g.srv.Accept(msgSelector)
 
+	g.receivers.Add(1)
 	go g.acceptMessages(incMsgs)
 
-	logging.Info("RKSync gossip instance", g.id, "started")
+	g.logger.Info("RKSync gossip instance", g.id, "started")
 }
 
 func (g *gossipService) acceptMessages(incMsgs <-chan protos.ReceivedMessage) {
-	defer logging.Debug("Exiting")
-	defer g.stopSignal.Done()
+	defer g.logger.Debug("Exiting")
+	defer g.receivers.Done()
+
+	// The messages are handled in parallel across the peers
+	workers := newMessageWorkers(g.getConf().MessageWorkers, g.handleMessage)
+	defer workers.stop()
+	g.workers.Store(workers)
 	for {
 		select {
-		case s := <-g.toDieChan:
-			g.toDieChan <- s
+		case <-g.ctx.Done():
 			return
 		case msg := <-incMsgs:
-			g.handleMessage(msg)
+			workers.dispatch(msg)
 		}
 	}
 }
@@ -458,59 +1002,112 @@ func (g *gossipService) handleMessage(m protos.ReceivedMessage) {
 
 	msg := m.GetRKSyncMessage()
 
-	logging.Debug("Entering,", m.GetConnectionInfo(), "sent us", msg)
-	defer logging.Debug("Exiting")
+	g.logger.Debug("Entering,", m.GetConnectionInfo(), "sent us", msg)
+	defer g.logger.Debug("Exiting")
+
+	conf := g.getConf()
+	sender := m.GetConnectionInfo().ID
+	if err := g.checkBlocked(sender); err != nil {
+		g.deadLetters.report(sender, msg.RKSyncMessage, err.Error())
+		return
+	}
+	if banned, err := g.reputation.receive(sender, conf.PeerMessageRate, conf.ReputationBanTime, g.clock.Now()); err != nil {
+		g.deadLetters.report(sender, msg.RKSyncMessage, err.Error())
+		if banned {
+			g.ban(sender, conf.ReputationBanTime)
+		}
+		return
+	}
+
+	// Gossiped messages reach the peer several times, the copies of a valid message
+	// are dropped before being verified and forwarded again
+	if (msg.IsChainStateMsg() || msg.IsAliveMsg()) && g.dedup.seenBefore(msg.Envelope, g.clock.Now()) {
+		g.logger.Debug("Suppressing duplicate message", msg, "sent from", m.GetConnectionInfo())
+		return
+	}
 
-	if !g.validateMsg(m) {
-		logging.Warning("Message", msg, "isn't valid")
+	if err := g.validateMsg(m); err != nil {
+		g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, err.Error())
 		return
 	}
+	if msg.IsChainStateMsg() || msg.IsAliveMsg() {
+		g.dedup.record(msg.Envelope, g.clock.Now())
+	}
 
 	if msg.IsChainStateMsg() {
 		chainState := msg.GetState()
 		chainInfo, err := chainState.GetChainStateInfo()
 		if err != nil {
-			logging.Warningf("Failed getting ChainStateInfo message: %s", err)
+			g.ReportMisbehavior(m.GetConnectionInfo().ID, common.MalformedMessage)
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("Failed getting ChainStateInfo message: %s", err))
 			return
 		}
 
 		mac := channel.GenerateMAC(chainInfo.Leader, chainState.ChainId)
 		if !bytes.Equal(mac, msg.ChainMac) {
-			logging.Warningf("ChainState (%s) message has an invalid MAC, expected %s, got %s, leader: %s, sent from %s",
+			g.ReportMisbehavior(m.GetConnectionInfo().ID, common.MalformedMessage)
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("ChainState (%s) message has an invalid MAC, expected %s, got %s, leader: %s",
 				chainState.ChainId,
 				mac,
 				common.ChainMac(msg.ChainMac),
-				common.PKIidType(chainInfo.Leader),
-				m.GetConnectionInfo().ID)
+				common.PKIidType(chainInfo.Leader)))
 			return
 		}
 
-		g.emitter.Add(&emittedRKSyncMessage{
-			SignedRKSyncMessage: msg,
-			filter:              m.GetConnectionInfo().ID.IsNotSameFilter,
-		})
+		if err := g.checkAccess(chainState.ChainId, chainInfo.Leader, true); err != nil {
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("Leader of channel %s is not allowed: %s", chainState.ChainId, err))
+			return
+		}
+
+		var propagation config.Propagation
+		if gc := g.chanState.getChannelByMAC(msg.ChainMac); gc != nil {
+			propagation = gc.Propagation()
+		}
+		g.forwardWith(m, propagation)
 
+		// The chain states of a channel sent by different peers are applied one at a time
+		lock := &g.chainStateLocks[stripe(msg.ChainMac, chainStateLockStripes)]
+		lock.Lock()
+		defer lock.Unlock()
 		added := g.chainStateMsgStore.Add(msg)
 		if added {
 			gc := g.chanState.lookupChannelForMsg(m)
-			if gc == nil && g.isInChannel(m) {
-				gc = g.chanState.joinChannel(msg.ChainMac, chainState.ChainId, false)
+			joined := false
+			if gc == nil && !g.draining() && g.isInChannel(m) && g.mayJoin(msg.ChainMac) {
+				gc, joined = g.chanState.joinChannel(msg.ChainMac, chainState.ChainId, false)
 			}
 
 			if gc != nil {
-				gc.InitializeWithChainState(chainState)
+				// The heartbeats of the leader carry the same chain state again
+				if self := gc.Self(); self == nil || !self.SameState(chainState) {
+					gc.InitializeWithChainState(chainState)
+				}
 				gc.HandleMessage(m)
 			}
+			if joined {
+				// The gossiped chain state may be stale, the current one is pulled at once
+				gc.RequestState()
+			}
 		}
 		return
 	}
 
+	if msg.IsChannelInvitation() {
+		g.handleInvitation(m)
+		return
+	}
+
+	if msg.IsChannelAcceptance() {
+		g.handleAcceptance(m)
+		return
+	}
+
 	if msg.IsLeaveChain() {
 		chainMac := msg.GetLeaveChain().ChainMac
 		gc := g.chanState.getChannelByMAC(chainMac)
 		if gc == nil {
 			m.Ack(errors.Errorf("Failed getting channel %s based on leave message", chainMac))
-			logging.Warningf("Failed getting channel %s based on leave message: %+v", chainMac, msg)
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("Failed getting channel %s based on leave message", chainMac))
 			return
 		}
 
@@ -518,16 +1115,16 @@ func (g *gossipService) handleMessage(m protos.ReceivedMessage) {
 		chainInfo, err := chainState.GetChainStateInfo()
 		if err != nil {
 			m.Ack(errors.Errorf("Failed getting channel (%s) state information", chainMac))
-			logging.Errorf("Failed getting channel (%s) state information: %s", chainMac, err)
+			g.logger.Errorf("Failed getting channel (%s) state information: %s", chainMac, err)
 			return
 		}
 
-		err = msg.Verify(chainInfo.Leader, func(peerIdentity []byte, signature, message []byte) error {
-			return g.idMapper.Verify(peerIdentity, signature, message)
+		err = msg.Verify(chainInfo.Leader, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+			return g.idMapper.Verify(peerIdentity, algorithm, signature, message)
 		})
 		if err != nil {
 			m.Ack(errors.New("Failed verifying the signature of the leave message"))
-			logging.Errorf("Failed verifying the signature of the leave message: %s", err)
+			g.logger.Errorf("Failed verifying the signature of the leave message: %s", err)
 			return
 		}
 
@@ -536,11 +1133,33 @@ func (g *gossipService) handleMessage(m protos.ReceivedMessage) {
 		return
 	}
 
+	if msg.IsIdentityReq() {
+		g.handleIdentityRequest(m)
+		return
+	}
+
+	if msg.IsIdentityRes() {
+		g.handleIdentityResponse(m)
+		return
+	}
+
 	if msg.IsChannelRestricted() {
+		if msg.IsDataReq() && g.draining() {
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, "Service is draining")
+			return
+		}
 		gc := g.chanState.lookupChannelForMsg(m)
-		if gc != nil {
-			gc.HandleMessage(m)
+		if gc == nil {
+			g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("No channel found for MAC %s", common.ChainMac(msg.ChainMac)))
+			return
 		}
+		if chainState := gc.Self(); chainState != nil {
+			if err := g.checkAccess(chainState.ChainId, m.GetConnectionInfo().ID, false); err != nil {
+				g.deadLetters.report(m.GetConnectionInfo().ID, msg.RKSyncMessage, fmt.Sprintf("Sender is not allowed in channel %s: %s", chainState.ChainId, err))
+				return
+			}
+		}
+		gc.HandleMessage(m)
 		return
 	}
 
@@ -548,27 +1167,64 @@ func (g *gossipService) handleMessage(m protos.ReceivedMessage) {
 		if m.GetRKSyncMessage().GetMemReq() != nil {
 			sMsg, err := m.GetRKSyncMessage().GetMemReq().SelfInformation.ToRKSyncMessage()
 			if err != nil {
-				logging.Warningf("Got membership request with invalid selfInfo: %+v", errors.WithStack(err))
+				g.ReportMisbehavior(m.GetConnectionInfo().ID, common.MalformedMessage)
+				g.logger.Warningf("Got membership request with invalid selfInfo: %+v", errors.WithStack(err))
 				return
 			}
 			if !sMsg.IsAliveMsg() {
-				logging.Warning("Got membership request with selfInfo that isn't an AliveMessage")
+				g.ReportMisbehavior(m.GetConnectionInfo().ID, common.MalformedMessage)
+				g.logger.Warning("Got membership request with selfInfo that isn't an AliveMessage")
 				return
 			}
 			if !bytes.Equal(sMsg.GetAliveMsg().Membership.PkiId, m.GetConnectionInfo().ID) {
-				logging.Warning("Got membership request with selfInfo that doesn't match the handshake")
+				g.ReportMisbehavior(m.GetConnectionInfo().ID, common.MalformedMessage)
+				g.logger.Warning("Got membership request with selfInfo that doesn't match the handshake")
 				return
 			}
 		}
+		if msg.IsAliveMsg() && g.awaitIdentity(m) {
+			return
+		}
+		if msg.GetMemRes() != nil {
+			g.requestMissingIdentities(m)
+		}
 		g.forwardDiscoveryMsg(m)
 	}
 }
 
+// checkAccess consults the access control policy about the peer joining
+// or leading the channel
+func (g *gossipService) checkAccess(chainID string, pkiID common.PKIidType, leader bool) error {
+	policy := g.getConf().AccessControl
+	if policy == nil {
+		return nil
+	}
+
+	peerIdentity, err := g.idMapper.Get(pkiID)
+	if err != nil {
+		return err
+	}
+	sid := &protos.SerializedIdentity{}
+	if err := proto.Unmarshal(peerIdentity, sid); err != nil {
+		return errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+	}
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		return err
+	}
+
+	peer := config.PeerInfo{NodeID: sid.NodeId, Org: identity.CertificateOrg(cert), Certificate: cert}
+	if leader {
+		return policy.CanLead(chainID, peer)
+	}
+	return policy.CanJoin(chainID, peer)
+}
+
 func (g *gossipService) isInChannel(m protos.ReceivedMessage) bool {
 	msg := m.GetRKSyncMessage()
 	chainStateInfo, err := msg.GetState().GetChainStateInfo()
 	if err != nil {
-		logging.Errorf("Failed unmarshalling ChainStateInfo message: %v", err)
+		g.logger.Errorf("Failed unmarshalling ChainStateInfo message: %v", err)
 		return false
 	}
 
@@ -586,72 +1242,163 @@ func (g *gossipService) forwardDiscoveryMsg(msg protos.ReceivedMessage) {
 		return
 	}
 
-	g.discAdapter.incChan <- msg
+	select {
+	case g.discAdapter.incChan <- msg:
+	case <-g.ctx.Done():
+	}
 }
 
 func (g *gossipService) handlePresumedDead() {
-	defer logging.Debug("Exiting")
-	defer g.stopSignal.Done()
+	defer g.logger.Debug("Exiting")
+	defer g.loops.Done()
 	for {
 		select {
-		case s := <-g.toDieChan:
-			g.toDieChan <- s
+		case <-g.ctx.Done():
 			return
 		case deadEndpoint := <-g.srv.PresumedDead():
-			g.presumedDead <- deadEndpoint
+			select {
+			case g.presumedDead <- deadEndpoint:
+			case <-g.ctx.Done():
+				return
+			}
 		}
 	}
 }
 
-// validateMsg checks the signature of the message if exists.
-func (g *gossipService) validateMsg(msg protos.ReceivedMessage) bool {
+// validateMsg checks the tag and the freshness of the message, and the rules registered by the application
+func (g *gossipService) validateMsg(msg protos.ReceivedMessage) error {
 	if err := msg.GetRKSyncMessage().IsTagLegal(); err != nil {
-		logging.Warningf("Tag of %v isn't legal: %v", msg.GetRKSyncMessage(), errors.WithStack(err))
-		return false
+		g.ReportMisbehavior(msg.GetConnectionInfo().ID, common.MalformedMessage)
+		return errors.WithMessage(err, "tag isn't legal")
 	}
-
-	return true
+	if err := g.replays.check(msg.GetRKSyncMessage(), g.getConf().MaxMessageAge, g.clock.Now()); err != nil {
+		return err
+	}
+	return g.validators.validate(msg)
 }
 
 func (g *gossipService) syncDiscovery() {
-	logging.Debug("Entering discovery sync with interval", g.conf.PullInterval)
-	defer logging.Debug("Exiting discovery sync loop")
+	g.logger.Debug("Entering discovery sync with interval", g.getConf().PullInterval)
+	defer g.logger.Debug("Exiting discovery sync loop")
+	defer g.loops.Done()
 
 	for !g.toDie() {
-		g.disc.InitiateSync(g.conf.PullPeerNum)
-		time.Sleep(g.conf.PullInterval)
+		// The configuration may be changed at runtime
+		conf := g.getConf()
+		g.disc.InitiateSync(conf.PullPeerNum)
+		if !g.sleep(conf.PullInterval) {
+			return
+		}
 	}
 }
 
 func (g *gossipService) connect2BootstrapPeers() {
-	for _, endpoint := range g.conf.BootstrapPeers {
-		identifier := func() (common.PKIidType, error) {
-			remotePeerIdentity, err := g.srv.Handshake(&common.NetworkMember{Endpoint: endpoint})
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-			pkiID := g.idMapper.GetPKIidOfCert(remotePeerIdentity)
-			if len(pkiID) == 0 {
-				return nil, errors.Errorf("Wasn't able to extract PKI-ID of remote peer with identity of %v", remotePeerIdentity)
+	conf := g.getConf()
+	for _, endpoint := range conf.BootstrapPeers {
+		g.connect2Peer(endpoint)
+	}
+	g.connect2PersistedPeers()
+
+	if conf.BootstrapResolveInterval > 0 && !conf.StaticTopology {
+		go g.periodicalResolveBootstrapPeers(conf.BootstrapResolveInterval)
+	}
+}
+
+func (g *gossipService) connect2Peer(endpoint string) {
+	identifier := func() (common.PKIidType, error) {
+		remotePeerIdentity, err := g.srv.Handshake(&common.NetworkMember{Endpoint: endpoint})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		pkiID := g.idMapper.GetPKIidOfCert(remotePeerIdentity)
+		if len(pkiID) == 0 {
+			return nil, errors.Errorf("Wasn't able to extract PKI-ID of remote peer with identity of %v", remotePeerIdentity)
+		}
+		return pkiID, nil
+	}
+	g.disc.Connect(common.NetworkMember{Endpoint: endpoint}, identifier)
+}
+
+// periodicalResolveBootstrapPeers re-resolves the host names of the bootstrap
+// peers and connects to the addresses that weren't returned previously
+func (g *gossipService) periodicalResolveBootstrapPeers(interval time.Duration) {
+	resolver := newBootstrapResolver(g.getConf().BootstrapPeers, g.logger)
+	// The configured endpoints have been connected already
+	resolver.resolve()
+
+	for g.sleep(interval) {
+		resolver.endpoints = g.getConf().BootstrapPeers
+		for _, address := range resolver.resolve() {
+			if isLocalAddress(address, g.getConf().Endpoint) {
+				continue
 			}
-			return pkiID, nil
+			g.logger.Infof("Connecting to newly resolved bootstrap peer %s", address)
+			g.connect2Peer(address)
 		}
-		g.disc.Connect(common.NetworkMember{Endpoint: endpoint}, identifier)
 	}
 }
 
 func (g *gossipService) toDie() bool {
-	return atomic.LoadInt32(&g.stopFlag) == int32(1)
+	return g.ctx.Err() != nil
+}
+
+// track adds a goroutine to the wait group of its subsystem, unless the instance is stopping.
+// The goroutines spawned after the constructor returns must be tracked with it, so that
+// Stop doesn't miss them.
+func (g *gossipService) track(wg *sync.WaitGroup) bool {
+	g.lifecycle.RLock()
+	defer g.lifecycle.RUnlock()
+	if g.toDie() {
+		return false
+	}
+	wg.Add(1)
+	return true
 }
 
-func (g *gossipService) newChainStateMsgStore() lib.MessageStore {
+// sleep waits for the duration on the clock of the instance, it returns false if the instance stopped meanwhile
+func (g *gossipService) sleep(d time.Duration) bool {
+	select {
+	case <-g.clock.After(d):
+		return true
+	case <-g.ctx.Done():
+		return false
+	}
+}
+
+func (g *gossipService) draining() bool {
+	return atomic.LoadInt32(&g.drainFlag) == int32(1)
+}
+
+func (g *gossipService) newChainStateMsgStore() (lib.MessageStore, error) {
+	conf := g.getConf()
+	bounds := lib.MessageStoreBounds{
+		MaxMessages: conf.ChainStateStoreSize,
+		MaxBytes:    conf.ChainStateStoreBytes,
+		SizeOf: func(msg interface{}) int {
+			return proto.Size(msg.(*protos.SignedRKSyncMessage).Envelope)
+		},
+		KeyOf: func(msg interface{}) string {
+			return common.ChainMac(msg.(*protos.SignedRKSyncMessage).ChainMac).String()
+		},
+	}
+	if conf.ChainStateSpillDir != "" {
+		spill, err := lib.NewFileSpill(conf.ChainStateSpillDir, func(msg interface{}) ([]byte, error) {
+			return proto.Marshal(msg.(*protos.SignedRKSyncMessage).Envelope)
+		}, func(data []byte) (interface{}, error) {
+			envelope := &protos.Envelope{}
+			if err := proto.Unmarshal(data, envelope); err != nil {
+				return nil, err
+			}
+			return envelope.ToRKSyncMessage()
+		})
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed creating the chain state spill")
+		}
+		bounds.Spill = spill
+	}
+
 	pol := protos.NewRKSyncMessageComparator()
-	return lib.NewMessageStoreExpirable(pol,
-		lib.Noop,
-		g.conf.PublishStateInfoInterval*100,
-		nil,
-		nil,
-		lib.Noop)
+	return lib.NewMessageStoreBounded(pol, lib.Noop, conf.PublishStateInfoInterval*100, g.clock, bounds), nil
 }
 
 func selectOnlyDiscoveryMessages(m interface{}) bool {
@@ -672,25 +1419,20 @@ func (g *gossipService) newDiscoveryAdapter() *discoveryAdapter {
 		srv:      g.srv,
 		stopping: int32(0),
 		gossipFunc: func(msg *protos.SignedRKSyncMessage) {
-			if g.conf.PropagateIterations == 0 {
+			if g.getConf().PropagateIterations == 0 {
 				return
 			}
-			g.emitter.Add(&emittedRKSyncMessage{
-				SignedRKSyncMessage: msg,
-				filter:              func(_ common.PKIidType) bool { return true },
-			})
+			g.gossip(msg)
 		},
 		forwardFunc: func(msg protos.ReceivedMessage) {
-			if g.conf.PropagateIterations == 0 {
+			if g.getConf().PropagateIterations == 0 {
 				return
 			}
-			g.emitter.Add(&emittedRKSyncMessage{
-				SignedRKSyncMessage: msg.GetRKSyncMessage(),
-				filter:              msg.GetConnectionInfo().ID.IsNotSameFilter,
-			})
+			g.forward(msg)
 		},
 		incChan:      make(chan protos.ReceivedMessage),
 		presumedDead: g.presumedDead,
+		events:       g.events,
 	}
 }
 
@@ -702,6 +1444,7 @@ type discoveryAdapter struct {
 	incChan      chan protos.ReceivedMessage
 	gossipFunc   func(message *protos.SignedRKSyncMessage)
 	forwardFunc  func(message protos.ReceivedMessage)
+	events       *eventBus
 }
 
 func (da *discoveryAdapter) close() {
@@ -753,78 +1496,160 @@ func (da *discoveryAdapter) CloseConn(peer *common.NetworkMember) {
 	da.srv.CloseConn(peer)
 }
 
+func (da *discoveryAdapter) MemberDead(member common.NetworkMember) {
+	da.events.publish(common.PeerDead{Member: member})
+}
+
 func (g *gossipService) newDiscoverySecurityAdapter() *discoverySecurityAdapter {
-	return &discoverySecurityAdapter{
+	conf := g.getConf()
+	sa := &discoverySecurityAdapter{
 		idMapper:              g.idMapper,
+		checkBlocked:          g.checkBlocked,
 		includeIdentityPeriod: g.includeIdentityPeriod,
+		publishCertPeriod:     conf.PublishCertPeriod,
+		inclusion:             conf.IdentityInclusion,
+		clock:                 g.clock,
 		identity:              g.selfIdentity,
+		logger:                g.moduleLogger("discovery"),
+	}
+	if sa.inclusion == config.IdentityOnRequest {
+		// the identity is only published once a remote peer asks for it
+		sa.includeIdentityPeriod = time.Time{}
 	}
+	return sa
 }
 
 type discoverySecurityAdapter struct {
 	identity              common.PeerIdentityType
 	includeIdentityPeriod time.Time
+	publishCertPeriod     time.Duration
+	inclusion             string // config.IdentityWindow, config.IdentityAlways or config.IdentityOnRequest
+	clock                 lib.Clock
 	idMapper              identity.Identity
+	checkBlocked          func(common.PKIidType) error
+	logger                logging.Logger
+	sync.Mutex
 }
 
 func (sa *discoverySecurityAdapter) ValidateAliveMsg(m *protos.SignedRKSyncMessage) bool {
 	am := m.GetAliveMsg()
 	if am == nil || am.Membership == nil || am.Membership.PkiId == nil || !m.IsSigned() {
-		logging.Warning("Invalid alive message:", m)
+		sa.logger.Warning("Invalid alive message:", m)
 		return false
 	}
 
-	if am.Identity != nil {
-		identity := common.PeerIdentityType(am.Identity)
+	identity := common.PeerIdentityType(am.Identity)
+	if identity != nil {
 		claimedPKIID := am.Membership.PkiId
 		err := sa.idMapper.Put(claimedPKIID, identity)
 		if err != nil {
-			logging.Debug("Falied validating identity of %v reason %+v", am, errors.WithStack(err))
+			sa.logger.Debug("Falied validating identity of %v reason %+v", am, errors.WithStack(err))
 			return false
 		}
+		if stored, err := sa.idMapper.Get(claimedPKIID); err == nil && !bytes.Equal(stored, identity) {
+			// The peer advertises a renewed identity, e.g. a rotated X.509 SVID, it must have signed the alive message
+			err = sa.idMapper.Renew(claimedPKIID, identity, m.Envelope.SignatureAlgorithm, m.Envelope.Signature, m.Envelope.Payload)
+			if err != nil {
+				sa.logger.Debugf("Kept the stored identity of %s: %v", common.PKIidType(claimedPKIID), err)
+				identity = stored
+			}
+		}
 	} else {
-		cert, _ := sa.idMapper.Get(am.Membership.PkiId)
-		if cert == nil {
-			logging.Debug("Don't have certificate for", am)
+		identity, _ = sa.idMapper.Get(am.Membership.PkiId)
+		if identity == nil {
+			sa.logger.Debug("Don't have certificate for", am)
 			return false
 		}
 	}
 
-	logging.Debug("Fetched identity of", am.Membership.PkiId, "from identity store")
+	sa.logger.Debug("Fetched identity of", am.Membership.PkiId, "from identity store")
+	// The organization is declared by the identity, peers can't claim another one
+	if org := identityOrg(identity); am.Membership.Org != org {
+		sa.logger.Warningf("Alive message of %s claims organization %q, its identity declares %q", common.PKIidType(am.Membership.PkiId), am.Membership.Org, org)
+		return false
+	}
+	if err := sa.checkBlocked(am.Membership.PkiId); err != nil {
+		sa.logger.Debugf("Ignoring alive message of %s: %s", common.PKIidType(am.Membership.PkiId), err)
+		return false
+	}
 	return sa.validateAliveMsgSignature(m, am.Membership.PkiId)
 }
 
-func (sa *discoverySecurityAdapter) SignMessage(m *protos.RKSyncMessage) *protos.Envelope {
+func (sa *discoverySecurityAdapter) SignMessage(m *protos.RKSyncMessage, internalEndpoint string) *protos.Envelope {
 	signer := func(msg []byte) ([]byte, error) {
 		return sa.idMapper.Sign(msg)
 	}
-	if m.IsAliveMsg() && time.Now().Before(sa.includeIdentityPeriod) {
-		m.GetAliveMsg().Identity = sa.identity
+	if m.IsAliveMsg() {
+		if identity := sa.currentIdentity(); identity != nil {
+			m.GetAliveMsg().Identity = identity
+		}
 	}
 
 	signedMsg := &protos.SignedRKSyncMessage{RKSyncMessage: m}
-	e, err := signedMsg.Sign(signer)
+	e, err := signedMsg.SignWithAlgorithm(sa.idMapper.SignatureAlgorithm(), signer)
 	if err != nil {
-		logging.Warningf("Failed signing message: %+v", errors.WithStack(err))
+		sa.logger.Warningf("Failed signing message: %+v", errors.WithStack(err))
 		return nil
 	}
 
+	if internalEndpoint != "" {
+		secret := &protos.Secret{
+			Content: &protos.Secret_InternalEndpoint{InternalEndpoint: internalEndpoint},
+		}
+		if err := e.SignSecret(signer, secret); err != nil {
+			sa.logger.Warningf("Failed signing secret: %+v", errors.WithStack(err))
+			return nil
+		}
+	}
+
 	return e
 }
 
 func (sa *discoverySecurityAdapter) SelfIdentity() common.PeerIdentityType {
+	sa.currentIdentity()
+	sa.Lock()
+	defer sa.Unlock()
 	return sa.identity
 }
 
+// currentIdentity returns the identity to include in the alive messages, nil once it was
+// published long enough. A rotated identity is published again for the same period,
+// whatever the inclusion policy.
+func (sa *discoverySecurityAdapter) currentIdentity() common.PeerIdentityType {
+	identity := sa.idMapper.SelfIdentity()
+	sa.Lock()
+	defer sa.Unlock()
+	if !bytes.Equal(identity, sa.identity) {
+		sa.identity = identity
+		sa.includeIdentityPeriod = sa.clock.Now().Add(sa.publishCertPeriod)
+	}
+	if sa.inclusion == config.IdentityAlways || sa.clock.Now().Before(sa.includeIdentityPeriod) {
+		return sa.identity
+	}
+	return nil
+}
+
+// identityRequested publishes the identity again for PublishCertPeriod with the
+// config.IdentityOnRequest policy, the other peers which don't know it yet
+// likely missed it as well as the requester.
+func (sa *discoverySecurityAdapter) identityRequested() {
+	if sa.inclusion != config.IdentityOnRequest {
+		return
+	}
+	sa.Lock()
+	defer sa.Unlock()
+	sa.includeIdentityPeriod = sa.clock.Now().Add(sa.publishCertPeriod)
+}
+
 func (sa *discoverySecurityAdapter) validateAliveMsgSignature(m *protos.SignedRKSyncMessage, id common.PKIidType) bool {
 	am := m.GetAliveMsg()
-	verifier := func(pkiID []byte, signature, message []byte) error {
-		return sa.idMapper.Verify(common.PKIidType(pkiID), signature, message)
+	verifier := func(pkiID []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return sa.idMapper.Verify(common.PKIidType(pkiID), algorithm, signature, message)
 	}
 
 	err := m.Verify(id, verifier)
 	if err != nil {
-		logging.Warningf("Failed verifying: %v: %+v", am, errors.WithStack(err))
+		sa.logger.Warningf("Failed verifying: %v: %+v", am, errors.WithStack(err))
 		return false
 	}
 	return true
@@ -0,0 +1,556 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/cccsp/hash"
+	"github.com/rkcloudchain/cccsp/provider"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/lib"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/rkcloudchain/rksync/tests/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type receivedMessageMock struct {
+	msg    *protos.SignedRKSyncMessage
+	sender common.PKIidType
+}
+
+func (m *receivedMessageMock) Respond(msg *protos.RKSyncMessage)             {}
+func (m *receivedMessageMock) GetRKSyncMessage() *protos.SignedRKSyncMessage { return m.msg }
+func (m *receivedMessageMock) GetSourceEnvelope() *protos.Envelope           { return m.msg.Envelope }
+func (m *receivedMessageMock) Ack(err error)                                 {}
+func (m *receivedMessageMock) GetConnectionInfo() *protos.ConnectionInfo {
+	return &protos.ConnectionInfo{ID: m.sender}
+}
+
+func TestHopLimit(t *testing.T) {
+	emitted := make(chan *emittedRKSyncMessage, 10)
+	g := &gossipService{
+		conf:   &config.GossipConfig{AliveMsgTTL: 2, ChainStateMsgTTL: 3},
+		logger: logging.Default(),
+		emitter: newBatchingEmitter(1, 1, time.Hour, lib.SystemClock, func(msgs []interface{}) {
+			for _, msg := range msgs {
+				emitted <- msg.(*emittedRKSyncMessage)
+			}
+		}),
+	}
+	defer g.emitter.Stop()
+
+	alive, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}).NoopSign()
+	require.NoError(t, err)
+	g.gossip(alive)
+	msg := <-emitted
+	assert.Equal(t, uint32(2), msg.Envelope.HopsLeft)
+	assert.Zero(t, alive.Envelope.HopsLeft)
+	alive = msg.SignedRKSyncMessage
+
+	state, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_State{State: &protos.ChainState{}}}).NoopSign()
+	require.NoError(t, err)
+	g.gossip(state)
+	msg = <-emitted
+	assert.Equal(t, uint32(3), msg.Envelope.HopsLeft)
+
+	// The first peer receiving the alive message forwards it once more
+	g.forward(&receivedMessageMock{msg: alive, sender: common.PKIidType("peer1")})
+	msg = <-emitted
+	assert.Equal(t, uint32(1), msg.Envelope.HopsLeft)
+	assert.Equal(t, uint32(2), alive.Envelope.HopsLeft)
+	assert.Equal(t, alive.Envelope.Payload, msg.Envelope.Payload)
+	assert.False(t, msg.filter(common.PKIidType("peer1")))
+
+	// The message has reached its hop limit
+	g.forward(&receivedMessageMock{msg: msg.SignedRKSyncMessage, sender: common.PKIidType("peer2")})
+	select {
+	case <-emitted:
+		assert.Fail(t, "Message should not be forwarded")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A relaying peer can neither remove nor raise the hop limit
+	alive.Envelope.HopsLeft = 0
+	g.forward(&receivedMessageMock{msg: alive, sender: common.PKIidType("peer1")})
+	select {
+	case <-emitted:
+		assert.Fail(t, "Message should not be forwarded")
+	case <-time.After(100 * time.Millisecond):
+	}
+	alive.Envelope.HopsLeft = 100
+	g.forward(&receivedMessageMock{msg: alive, sender: common.PKIidType("peer1")})
+	msg = <-emitted
+	assert.Equal(t, uint32(1), msg.Envelope.HopsLeft)
+}
+
+func TestGossipWithPropagation(t *testing.T) {
+	var lock sync.Mutex
+	emitted := make(map[bool]int)
+	clock := lib.NewFakeClock(time.Now())
+	g := &gossipService{
+		conf:   &config.GossipConfig{AliveMsgTTL: 2, ChainStateMsgTTL: 3},
+		logger: logging.Default(),
+		emitter: newBatchingEmitter(1, 10, 10*time.Millisecond, clock, func(msgs []interface{}) {
+			lock.Lock()
+			defer lock.Unlock()
+			for _, msg := range msgs {
+				emitted[msg.(*emittedRKSyncMessage).IsChainStateMsg()]++
+			}
+		}),
+	}
+	defer g.emitter.Stop()
+
+	alive, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}).NoopSign()
+	require.NoError(t, err)
+	state, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_State{State: &protos.ChainState{}}}).NoopSign()
+	require.NoError(t, err)
+
+	// The chain state is pushed as many times as its channel requires, other messages once
+	g.gossip(alive)
+	g.gossipWith(state, config.Propagation{PeerNum: 5, Iterations: 3})
+	// the emitter emits the pending messages each time the latency elapses
+	for i := 0; i < 4; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Millisecond)
+	}
+	clock.BlockUntil(1)
+	lock.Lock()
+	assert.Equal(t, 1, emitted[false])
+	assert.Equal(t, 3, emitted[true])
+	lock.Unlock()
+}
+
+func TestApplyConfig(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12057"}, "localhost:12057", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:13057"}, "localhost:13057", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	time.Sleep(2 * time.Second)
+	assert.Empty(t, gossipSvc1.Peers())
+
+	invalid := 0
+	err = gossipSvc1.ApplyConfig(&config.GossipConfigUpdate{PropagatePeerNum: &invalid})
+	assert.Error(t, err)
+	err = gossipSvc1.ApplyConfig(&config.GossipConfigUpdate{BootstrapPeers: []string{}})
+	assert.Error(t, err)
+
+	peerNum := 5
+	burstSize := 20
+	latency := 50 * time.Millisecond
+	pullInterval := time.Second
+	err = gossipSvc1.ApplyConfig(&config.GossipConfigUpdate{
+		BootstrapPeers:             []string{"localhost:12057", "localhost:13057"},
+		PropagatePeerNum:           &peerNum,
+		MaxPropagationBurstSize:    &burstSize,
+		MaxPropagationBurstLatency: &latency,
+		PullInterval:               &pullInterval,
+	})
+	require.NoError(t, err)
+
+	conf := gossipSvc1.(*gossipService).getConf()
+	assert.Equal(t, []string{"localhost:12057", "localhost:13057"}, conf.BootstrapPeers)
+	assert.Equal(t, 5, conf.PropagatePeerNum)
+	assert.Equal(t, 3, conf.PullPeerNum)
+	assert.Equal(t, time.Second, conf.PullInterval)
+
+	// The channels read the intervals through the adapter, the existing ones included
+	chanConf := (&gossipAdapterImpl{gossipService: gossipSvc1.(*gossipService)}).GetChannelConfig()
+	assert.Equal(t, time.Second, chanConf.PullInterval)
+	assert.Equal(t, 5*time.Second, chanConf.StateInfoCacheSweepInterval)
+
+	emitter := gossipSvc1.(*gossipService).emitter.(*batchingEmitterImpl)
+	emitter.lock.Lock()
+	assert.Equal(t, 20, emitter.burstSize)
+	assert.Equal(t, 50*time.Millisecond, emitter.delay)
+	emitter.lock.Unlock()
+
+	// The peers discover each other through the new bootstrap peer
+	time.Sleep(5 * time.Second)
+	assert.Len(t, gossipSvc1.Peers(), 1)
+	assert.Len(t, gossipSvc2.Peers(), 1)
+}
+
+type channelMock struct {
+	channel.Channel
+	state        *protos.ChainState
+	transferring int32
+}
+
+func (c *channelMock) Self() *protos.ChainState { return c.state }
+func (c *channelMock) Transferring() bool       { return atomic.LoadInt32(&c.transferring) == 1 }
+func (c *channelMock) Stop()                    {}
+func (c *channelMock) Close()                   {}
+
+func TestDrain(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12058"}, "localhost:12058", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12058"}, "localhost:13058", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	for i := 0; i < 50 && len(gossipSvc2.Peers()) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Len(t, gossipSvc2.Peers(), 1)
+	assert.False(t, gossipSvc2.Peers()[0].Leaving)
+
+	g1 := gossipSvc1.(*gossipService)
+	transfer := &channelMock{state: &protos.ChainState{}, transferring: 1}
+	g1.chanState.putChannel(common.ChainMac("transfer"), transfer)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- gossipSvc1.Drain(context.Background())
+	}()
+
+	// The remote peer learns we are leaving while the transfer is in progress
+	for i := 0; i < 50 && !gossipSvc2.Peers()[0].Leaving; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(t, gossipSvc2.Peers()[0].Leaving)
+
+	_, err = gossipSvc1.CreateChain(common.ChainMac("mac"), "testchannel", nil)
+	assert.Error(t, err)
+	assert.Error(t, gossipSvc1.Drain(context.Background()))
+	assert.False(t, g1.toDie())
+
+	atomic.StoreInt32(&transfer.transferring, 0)
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Drain should return once the transfer is finished")
+	}
+	assert.True(t, g1.toDie())
+
+	// The context expires before the transfer is finished
+	g2 := gossipSvc2.(*gossipService)
+	g2.chanState.putChannel(common.ChainMac("transfer"), &channelMock{state: &protos.ChainState{}, transferring: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	assert.Error(t, gossipSvc2.Drain(ctx))
+	assert.True(t, g2.toDie())
+}
+
+func TestStopWithBlockedAcceptor(t *testing.T) {
+	gossipSvc, err := CreateGossipServer([]string{"localhost:12082"}, "localhost:12082", 0)
+	require.NoError(t, err)
+	g := gossipSvc.(*gossipService)
+
+	mac := []byte("mac")
+	msgs, _ := gossipSvc.Accept(func(o interface{}) bool { return true }, mac, false)
+	msg, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataReq{DataReq: &protos.DataRequest{FileName: "request"}}}).NoopSign()
+	require.NoError(t, err)
+	// Nobody receives from the channel, the forwarder blocks once it's full
+	for i := 0; i < acceptChanSize+5; i++ {
+		g.DeMultiplex(msg)
+	}
+	require.Eventually(t, func() bool { return len(msgs) == acceptChanSize }, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case <-gossipSvc.Done():
+		require.Fail(t, "Done should be open until the instance is stopped")
+	default:
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		gossipSvc.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Stop should return once the blocked forwarder exited")
+	}
+	select {
+	case <-gossipSvc.Done():
+	default:
+		assert.Fail(t, "Done should be closed once the instance is stopped")
+	}
+
+	// An acceptor registered after the instance stopped isn't left running
+	msgs, _ = gossipSvc.Accept(func(o interface{}) bool { return true }, mac, false)
+	assert.NotNil(t, msgs)
+	g.receivers.Wait()
+	gossipSvc.Stop()
+}
+
+func TestChainStateMsgStoreSpill(t *testing.T) {
+	dir, err := os.MkdirTemp("", "spill")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g := &gossipService{conf: &config.GossipConfig{
+		ChainStateStoreSize:      1,
+		ChainStateStoreBytes:     1024 * 1024,
+		ChainStateSpillDir:       dir,
+		PublishStateInfoInterval: time.Second,
+	}}
+	store, err := g.newChainStateMsgStore()
+	require.NoError(t, err)
+	defer store.Stop()
+
+	state := func(chainMac string, seqNum uint64) *protos.SignedRKSyncMessage {
+		msg, err := (&protos.RKSyncMessage{
+			ChainMac: []byte(chainMac),
+			Content:  &protos.RKSyncMessage_State{State: &protos.ChainState{SeqNum: seqNum, ChainId: chainMac}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return msg
+	}
+
+	assert.True(t, store.Add(state("chain1", 2)))
+	assert.True(t, store.Add(state("chain2", 1)))
+	assert.Equal(t, 1, store.Size())
+
+	// The state of chain1 was spilled, it still invalidates the older states
+	assert.False(t, store.Add(state("chain1", 1)))
+	assert.True(t, store.Add(state("chain1", 3)))
+	assert.Equal(t, uint64(3), store.Get()[0].(*protos.SignedRKSyncMessage).GetState().SeqNum)
+}
+
+func TestSendToGroup(t *testing.T) {
+	var peers []Gossip
+	for i, tags := range [][]string{nil, {"region=eu"}, {"region=us"}} {
+		tags := tags
+		g, err := createGossipServerWithConfig([]string{"localhost:12085"}, fmt.Sprintf("localhost:%d", 12085+i), i, func(c *config.GossipConfig) {
+			c.Tags = tags
+		})
+		require.NoError(t, err)
+		defer g.Stop()
+		peers = append(peers, g)
+	}
+	require.Eventually(t, func() bool {
+		for _, p := range peers {
+			if len(p.Peers()) != 2 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+
+	isGroupMsg := func(o interface{}) bool {
+		return o.(protos.ReceivedMessage).GetRKSyncMessage().Nonce == 42
+	}
+	_, eu := peers[1].Accept(isGroupMsg, nil, true)
+	_, us := peers[2].Accept(isGroupMsg, nil, true)
+
+	msg, err := (&protos.RKSyncMessage{Nonce: 42, Tag: protos.RKSyncMessage_EMPTY, Content: &protos.RKSyncMessage_Empty{Empty: &emptypb.Empty{}}}).NoopSign()
+	require.NoError(t, err)
+	peers[0].SendToGroup("region=eu", msg)
+
+	select {
+	case m := <-eu:
+		assert.Equal(t, peers[0].SelfPKIid(), m.GetConnectionInfo().ID)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "The peer of the group didn't receive the message")
+	}
+	select {
+	case <-us:
+		assert.Fail(t, "The peer outside of the group shouldn't receive the message")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// No peer is assigned the tag
+	peers[0].SendToGroup("region=asia", msg)
+	select {
+	case <-eu:
+		assert.Fail(t, "The message shouldn't be sent to peers without the tag")
+	case <-us:
+		assert.Fail(t, "The message shouldn't be sent to peers without the tag")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// handlingChannelMock counts the messages handed over to the channel
+type handlingChannelMock struct {
+	channelMock
+	handled int64
+}
+
+func (c *handlingChannelMock) HandleMessage(msg protos.ReceivedMessage) {
+	atomic.AddInt64(&c.handled, 1)
+}
+
+// singleLockIdentity looks up the identities under a single lock, as a baseline of the sharded identity cache
+type singleLockIdentity struct {
+	identity.Identity
+	sync.Mutex
+}
+
+func (s *singleLockIdentity) Get(pkiID common.PKIidType) (common.PeerIdentityType, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.Identity.Get(pkiID)
+}
+
+// BenchmarkHandleMessage measures the handling of the channel messages received from many senders by
+// concurrent handlers, the identity of the sender is looked up for the access control of the channel,
+// e.g. go test -run ^$ -bench HandleMessage -cpu 1,8,32 ./gossip
+func BenchmarkHandleMessage(b *testing.B) {
+	const senders, channels = 512, 64
+	home := b.TempDir()
+	caCert, caKey := writeIdentityHome(b, home)
+	idCfg := &config.IdentityConfig{ID: "peer0.org1", Org: "org1"}
+	require.NoError(b, idCfg.MakeFilesAbs(home))
+	selfIdentity, err := util.GetIdentity(idCfg)
+	require.NoError(b, err)
+
+	gsrv, err := CreateGRPCServer("localhost:12099")
+	require.NoError(b, err)
+	cfg := util.DefaultGossipConfig(nil, "localhost:12099")
+	cfg.FileSystem = mocks.NewFSMock(b.TempDir())
+	svc, err := NewGossipService(cfg, idCfg, gsrv.Server(), selfIdentity, WithSecureDialOpts(secureDialOpts), WithAccessControl(&denyLeaderPolicy{}))
+	require.NoError(b, err)
+	go gsrv.Start()
+	defer svc.Stop()
+	g := svc.(*gossipService)
+
+	var handlers []*handlingChannelMock
+	for i := 0; i < channels; i++ {
+		gc := &handlingChannelMock{channelMock: channelMock{state: &protos.ChainState{ChainId: fmt.Sprintf("channel%d", i)}}}
+		g.chanState.putChannel(common.ChainMac(fmt.Sprintf("mac%d", i)), gc)
+		handlers = append(handlers, gc)
+	}
+	msgs := make([]protos.ReceivedMessage, senders)
+	for i := range msgs {
+		peerIdentity := issuePeerIdentity(b, caCert, caKey, fmt.Sprintf("peer%d.org1", i+1), int64(10+i))
+		pkiID := g.idMapper.GetPKIidOfCert(peerIdentity)
+		require.NoError(b, g.idMapper.Put(pkiID, peerIdentity))
+		msg, err := (&protos.RKSyncMessage{
+			ChainMac: common.ChainMac(fmt.Sprintf("mac%d", i%channels)),
+			Tag:      protos.RKSyncMessage_CHAN_ONLY,
+			Content:  &protos.RKSyncMessage_StatePullRequest{StatePullRequest: &protos.ChainStatePullRequest{}},
+		}).NoopSign()
+		require.NoError(b, err)
+		msgs[i] = &receivedMessageMock{msg: msg, sender: pkiID}
+	}
+
+	run := func(idMapper identity.Identity) func(b *testing.B) {
+		return func(b *testing.B) {
+			g.idMapper = idMapper
+			for _, gc := range handlers {
+				atomic.StoreInt64(&gc.handled, 0)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					g.handleMessage(msgs[i%senders])
+					i++
+				}
+			})
+			b.StopTimer()
+
+			var handled int64
+			for _, gc := range handlers {
+				handled += atomic.LoadInt64(&gc.handled)
+			}
+			require.Equal(b, int64(b.N), handled)
+		}
+	}
+	idMapper := g.idMapper
+	b.Run("Sharded", run(idMapper))
+	b.Run("SingleLock", run(&singleLockIdentity{Identity: idMapper}))
+}
+
+// writeIdentityHome writes the identity of peer0.org1 issued by a new CA to the home directory
+func writeIdentityHome(t testing.TB, home string) (*x509.Certificate, crypto.Signer) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.org1", Organization: []string{"org1"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caRaw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caRaw)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	raw := issueCertificate(t, caCert, caKey, key, "peer0.org1", 2)
+	privRaw, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	ski, err := provider.GetDefault().Hash(elliptic.Marshal(key.Curve, key.X, key.Y), hash.SHA3256)
+	require.NoError(t, err)
+
+	files := map[string]*pem.Block{
+		"csp/cacerts/ca.pem":                              {Type: "CERTIFICATE", Bytes: caRaw},
+		"csp/signcerts/cert.pem":                          {Type: "CERTIFICATE", Bytes: raw},
+		"csp/keystore/" + hex.EncodeToString(ski) + "_sk": {Type: "PRIVATE KEY", Bytes: privRaw},
+	}
+	for name, block := range files {
+		path := filepath.Join(home, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	}
+	return caCert, caKey
+}
+
+// issuePeerIdentity returns the identity of a peer issued by the CA
+func issuePeerIdentity(t testing.TB, caCert *x509.Certificate, caKey crypto.Signer, name string, serial int64) common.PeerIdentityType {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sid := &protos.SerializedIdentity{
+		NodeId:  name,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issueCertificate(t, caCert, caKey, key, name, serial)}),
+	}
+	peerIdentity, err := proto.Marshal(sid)
+	require.NoError(t, err)
+	return peerIdentity
+}
+
+func issueCertificate(t testing.TB, caCert *x509.Certificate, caKey crypto.Signer, key *ecdsa.PrivateKey, name string, serial int64) []byte {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"org1"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	require.NoError(t, err)
+	return raw
+}
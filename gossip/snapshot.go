@@ -0,0 +1,446 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/channel/fsync"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// A snapshot is a tar archive holding the signed chain state of the channel, the identity of its leader,
+// the content of the files, and finally the manifest of the files, written once their content was hashed,
+// followed by the leader's signature of the manifest
+const (
+	snapshotVersion        = 2
+	snapshotStateEntry     = "chainstate.pb"
+	snapshotIdentityEntry  = "identity.pb"
+	snapshotFilesDir       = "files/"
+	snapshotManifestEntry  = "manifest.json"
+	snapshotSignatureEntry = "manifest.sig"
+	// snapshotMaxEntrySize bounds the entries of a snapshot held in memory, all of them but the files
+	snapshotMaxEntrySize = 16 * 1024 * 1024
+)
+
+// ErrInvalidSnapshot is returned when a channel snapshot is malformed or its content doesn't match its manifest
+var ErrInvalidSnapshot = errors.New("invalid channel snapshot")
+
+type snapshotManifest struct {
+	Version int            `json:"version"`
+	ChainID string         `json:"chainId"`
+	Files   []snapshotFile `json:"files"`
+}
+
+type snapshotFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// importedFile is a file of a snapshot written to its working copy
+type importedFile struct {
+	snapshotFile
+	fmeta config.FileMeta
+	attrs config.FileAttributes
+}
+
+// ExportChannelSnapshot writes the chain state of the channel and the local copies of its files to w.
+// Only the leader exports the snapshots of a channel, as it signs their manifest. The files are exported
+// as they are at the time, the missing ones are skipped.
+func (g *gossipService) ExportChannelSnapshot(chainID string, w io.Writer) error {
+	gc, err := g.channelByID(chainID)
+	if err != nil {
+		return err
+	}
+	chainState := gc.Self()
+	if chainState == nil {
+		return errors.Errorf("Channel %s hasn't loaded its state", chainID)
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting channel %s state information", chainID)
+	}
+
+	if !bytes.Equal(stateInfo.Leader, g.selfPKIid) {
+		return errors.Wrapf(ErrNotLeader, "Channel %s is led by %s", chainID, common.PKIidType(stateInfo.Leader))
+	}
+	stateBytes, err := proto.Marshal(chainState)
+	if err != nil {
+		return errors.Wrapf(err, "Failed marshalling channel %s state", chainID)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeSnapshotEntry(tw, snapshotStateEntry, stateBytes); err != nil {
+		return err
+	}
+	if err := writeSnapshotEntry(tw, snapshotIdentityEntry, g.idMapper.SelfIdentity()); err != nil {
+		return err
+	}
+
+	manifest := snapshotManifest{Version: snapshotVersion, ChainID: chainID}
+	for _, file := range stateInfo.Properties.Files {
+		fmeta := config.FileMeta{Name: file.Path, Metadata: file.Metadata, Leader: true}
+		exported, err := g.exportSnapshotFile(tw, chainID, fmeta)
+		if err != nil {
+			return errors.Wrapf(err, "Failed exporting file %s of channel %s", file.Path, chainID)
+		}
+		if exported == nil {
+			g.logger.Infof("File %s of channel %s doesn't exist, it isn't exported", file.Path, chainID)
+			continue
+		}
+		manifest.Files = append(manifest.Files, *exported)
+	}
+
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotEntry(tw, snapshotManifestEntry, manifestBytes); err != nil {
+		return err
+	}
+	signature, err := g.signSnapshotManifest(manifestBytes)
+	if err != nil {
+		return errors.Wrapf(err, "Failed signing channel %s snapshot", chainID)
+	}
+	if err := writeSnapshotEntry(tw, snapshotSignatureEntry, signature); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// signSnapshotManifest returns the marshalled envelope of the digest of the manifest, signed by the peer
+func (g *gossipService) signSnapshotManifest(manifest []byte) ([]byte, error) {
+	digest := sha256.Sum256(manifest)
+	signature, err := g.idMapper.Sign(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&protos.Envelope{
+		Payload:            digest[:],
+		Signature:          signature,
+		SignatureAlgorithm: g.idMapper.SignatureAlgorithm(),
+	})
+}
+
+// exportSnapshotFile writes the local copy of the file up to its current size, it returns nil if it doesn't exist
+func (g *gossipService) exportSnapshotFile(tw *tar.Writer, chainID string, fmeta config.FileMeta) (*snapshotFile, error) {
+	fs := g.fileSystem(chainID)
+	fi, err := fs.Stat(chainID, fmeta)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.OpenFile(chainID, fmeta, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     snapshotFilesDir + fmeta.Name,
+		Mode:     0644,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Data appended while the file is exported is left to the incremental synchronization
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), io.NewSectionReader(f, 0, fi.Size())); err != nil {
+		return nil, err
+	}
+	return &snapshotFile{Name: fmeta.Name, Size: fi.Size(), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ImportChannelSnapshot joins the channel of a snapshot the peer is a member of, seeding the local
+// copies of its files with the content of the snapshot. The files are then synchronized incrementally.
+func (g *gossipService) ImportChannelSnapshot(r io.Reader) (*protos.ChainState, error) {
+	if g.toDie() {
+		return nil, ErrStopping
+	}
+	if g.draining() {
+		return nil, ErrDraining
+	}
+
+	tr := tar.NewReader(r)
+	stateBytes, err := readSnapshotEntry(tr, snapshotStateEntry)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := readSnapshotEntry(tr, snapshotIdentityEntry)
+	if err != nil {
+		return nil, err
+	}
+	chainState := &protos.ChainState{}
+	if err := proto.Unmarshal(stateBytes, chainState); err != nil {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Failed unmarshalling chain state: %s", err)
+	}
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Failed getting chain state information: %s", err)
+	}
+
+	chainID := chainState.ChainId
+	leader := common.PKIidType(stateInfo.Leader)
+	chainMac := channel.GenerateMAC(leader, chainID)
+	if g.chanState.getChannelByMAC(chainMac) != nil {
+		return nil, errors.Wrapf(ErrChannelExists, "Channel %s", chainID)
+	}
+	isLeader := bytes.Equal(leader, g.selfPKIid)
+	if !isLeader && !containsPKIid(stateInfo.Properties.Members, g.selfPKIid) {
+		return nil, errors.Wrapf(ErrNotMember, "Channel %s", chainID)
+	}
+	if err := g.verifySnapshotState(chainState, leader, identity); err != nil {
+		return nil, err
+	}
+	if err := g.checkAccess(chainID, leader, true); err != nil {
+		return nil, errors.WithMessage(err, "Access denied")
+	}
+
+	if err := g.importSnapshotFiles(tr, chainID, stateInfo, leader); err != nil {
+		return nil, err
+	}
+
+	gc, joined := g.chanState.joinChannel(chainMac, chainID, isLeader)
+	if gc == nil {
+		return nil, ErrStopping
+	}
+	if !joined {
+		return nil, errors.Wrapf(ErrChannelExists, "Channel %s", chainID)
+	}
+	if err := gc.InitializeWithChainState(chainState); err != nil {
+		g.chanState.discardChannel(chainMac, gc)
+		return nil, err
+	}
+	g.logger.Infof("Channel %s imported from a snapshot at epoch %d", chainID, stateInfo.Epoch)
+	// The data changed since the snapshot was taken is pulled at once
+	gc.RequestState()
+	return chainState, nil
+}
+
+// verifySnapshotState verifies that the chain state is signed by its leader, whose identity is learned from the snapshot.
+// The identity is then known to verify the signature of the manifest.
+func (g *gossipService) verifySnapshotState(chainState *protos.ChainState, leader common.PKIidType, identity common.PeerIdentityType) error {
+	if !bytes.Equal(g.idMapper.GetPKIidOfCert(identity), leader) {
+		return errors.Wrapf(ErrInvalidSnapshot, "Identity doesn't match the leader %s of channel %s", leader, chainState.ChainId)
+	}
+	if !bytes.Equal(leader, g.selfPKIid) {
+		if err := g.idMapper.Put(leader, identity); err != nil {
+			return errors.Wrapf(err, "Failed validating the identity of the leader of channel %s", chainState.ChainId)
+		}
+	}
+
+	signedMsg, err := chainState.Envelope.ToRKSyncMessage()
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSnapshot, "Failed parsing channel %s state: %s", chainState.ChainId, err)
+	}
+	if !signedMsg.IsStateInfoMsg() {
+		return errors.Wrapf(ErrInvalidSnapshot, "Channel %s state message isn't well formatted", chainState.ChainId)
+	}
+	err = signedMsg.Verify(leader, func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+		return g.idMapper.Verify(peerIdentity, algorithm, signature, message)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed verifying channel %s state signature", chainState.ChainId)
+	}
+	return nil
+}
+
+// verifySnapshotManifest verifies that the envelope carries the digest of the manifest, signed by the leader
+func (g *gossipService) verifySnapshotManifest(manifest []byte, signature []byte, leader common.PKIidType, chainID string) error {
+	envp := &protos.Envelope{}
+	if err := proto.Unmarshal(signature, envp); err != nil {
+		return errors.Wrapf(ErrInvalidSnapshot, "Failed unmarshalling manifest signature: %s", err)
+	}
+	digest := sha256.Sum256(manifest)
+	if !bytes.Equal(envp.Payload, digest[:]) {
+		return errors.Wrap(ErrInvalidSnapshot, "Manifest signature doesn't match the manifest")
+	}
+	if err := g.idMapper.Verify(leader, envp.SignatureAlgorithm, envp.Signature, envp.Payload); err != nil {
+		return errors.Wrapf(ErrInvalidSnapshot, "Manifest isn't signed by the leader %s of channel %s: %s", leader, chainID, err)
+	}
+	return nil
+}
+
+// importSnapshotFiles writes the files of the snapshot to their working copies, which replace
+// the local copies once they have been verified against the manifest signed by the leader
+func (g *gossipService) importSnapshotFiles(tr *tar.Reader, chainID string, stateInfo *protos.ChainStateInfo, leader common.PKIidType) (err error) {
+	fs := g.fileSystem(chainID)
+	isLeader := bytes.Equal(leader, g.selfPKIid)
+	imported := make(map[string]*importedFile)
+	defer func() {
+		if err != nil {
+			g.removeWorkingCopies(chainID, imported)
+		}
+	}()
+
+	var manifest *snapshotManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(ErrInvalidSnapshot, "Failed reading snapshot: %s", err)
+		}
+
+		switch {
+		case hdr.Name == snapshotManifestEntry:
+			b, err := readSnapshotContent(tr, hdr)
+			if err != nil {
+				return err
+			}
+			signature, err := readSnapshotEntry(tr, snapshotSignatureEntry)
+			if err != nil {
+				return err
+			}
+			if err := g.verifySnapshotManifest(b, signature, leader, chainID); err != nil {
+				return err
+			}
+			manifest = &snapshotManifest{}
+			if err := json.Unmarshal(b, manifest); err != nil {
+				return errors.Wrapf(ErrInvalidSnapshot, "Failed unmarshalling manifest: %s", err)
+			}
+		case strings.HasPrefix(hdr.Name, snapshotFilesDir):
+			name := strings.TrimPrefix(hdr.Name, snapshotFilesDir)
+			file := lookupFile(stateInfo.Properties.Files, name)
+			if file == nil {
+				return errors.Wrapf(ErrInvalidSnapshot, "File %s isn't in channel %s", name, chainID)
+			}
+			if _, exists := imported[name]; exists {
+				return errors.Wrapf(ErrInvalidSnapshot, "File %s is duplicated", name)
+			}
+			fmeta := config.FileMeta{Name: name, Metadata: file.Metadata, Leader: isLeader}
+			f, err := fs.Create(chainID, fsync.WorkingCopyMeta(fmeta))
+			if err != nil {
+				return errors.Wrapf(err, "Failed creating working copy of file %s", name)
+			}
+			imported[name] = &importedFile{fmeta: fmeta, attrs: config.FileAttributes{Perm: os.FileMode(file.Perm).Perm()}}
+			h := sha256.New()
+			n, err := io.Copy(io.MultiWriter(f, h), tr)
+			f.Close()
+			if err != nil {
+				return errors.Wrapf(err, "Failed writing working copy of file %s", name)
+			}
+			imported[name].snapshotFile = snapshotFile{Name: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+		default:
+			return errors.Wrapf(ErrInvalidSnapshot, "Unexpected entry %s", hdr.Name)
+		}
+	}
+
+	if manifest == nil {
+		return errors.Wrap(ErrInvalidSnapshot, "Manifest is missing")
+	}
+	if manifest.Version != snapshotVersion || manifest.ChainID != chainID {
+		return errors.Wrapf(ErrInvalidSnapshot, "Manifest of version %d, channel %s, doesn't match", manifest.Version, manifest.ChainID)
+	}
+	if len(manifest.Files) != len(imported) {
+		return errors.Wrapf(ErrInvalidSnapshot, "Manifest lists %d files, the snapshot holds %d", len(manifest.Files), len(imported))
+	}
+	for _, expected := range manifest.Files {
+		file, exists := imported[expected.Name]
+		if !exists || file.snapshotFile != expected {
+			return errors.Wrapf(ErrInvalidSnapshot, "File %s doesn't match the manifest", expected.Name)
+		}
+	}
+
+	setter, _ := fs.(config.AttributesSetter)
+	for name, file := range imported {
+		if err := fs.Rename(chainID, fsync.WorkingCopyMeta(file.fmeta), file.fmeta); err != nil {
+			return errors.Wrapf(err, "Failed replacing file %s with its working copy", name)
+		}
+		delete(imported, name)
+		if setter != nil && file.attrs.Perm != 0 {
+			if err := setter.SetAttributes(chainID, file.fmeta, file.attrs); err != nil {
+				g.logger.Warningf("Failed setting the attributes of file %s of channel %s: %s", name, chainID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// removeWorkingCopies deletes the working copies of the files of a snapshot which failed to be imported
+func (g *gossipService) removeWorkingCopies(chainID string, imported map[string]*importedFile) {
+	remover, ok := g.fileSystem(chainID).(config.FileRemover)
+	if !ok {
+		return
+	}
+	for name, file := range imported {
+		if err := remover.Remove(chainID, fsync.WorkingCopyMeta(file.fmeta)); err != nil {
+			g.logger.Warningf("Failed deleting working copy of file %s of channel %s: %s", name, chainID, err)
+		}
+	}
+}
+
+func writeSnapshotEntry(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	return err
+}
+
+// readSnapshotEntry reads the next entry of the snapshot, which must have the given name
+func readSnapshotEntry(tr *tar.Reader, name string) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Failed reading %s: %s", name, err)
+	}
+	if hdr.Name != name {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Expected %s, got %s", name, hdr.Name)
+	}
+	return readSnapshotContent(tr, hdr)
+}
+
+func readSnapshotContent(tr *tar.Reader, hdr *tar.Header) ([]byte, error) {
+	if hdr.Size > snapshotMaxEntrySize {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Entry %s is larger than %d bytes", hdr.Name, snapshotMaxEntrySize)
+	}
+	b, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSnapshot, "Failed reading %s: %s", hdr.Name, err)
+	}
+	return b, nil
+}
+
+func lookupFile(files []*protos.File, name string) *protos.File {
+	for _, file := range files {
+		if file.Path == name {
+			return file
+		}
+	}
+	return nil
+}
+
+func containsPKIid(members [][]byte, pkiID common.PKIidType) bool {
+	for _, member := range members {
+		if bytes.Equal(member, pkiID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSnapshot(t *testing.T) {
+	dir := filepath.Join(testDataDir, "peer2")
+	for _, name := range []string{"config.yaml", "rfc2616.txt"} {
+		os.Remove(filepath.Join(dir, name))
+		defer os.Remove(filepath.Join(dir, name))
+	}
+
+	// The peers don't know each other, the member is seeded from the snapshot only
+	leaderSvc, err := CreateGossipServer([]string{"localhost:12075"}, "localhost:12075", 0)
+	require.NoError(t, err)
+	defer leaderSvc.Stop()
+	memberSvc, err := CreateGossipServer([]string{"localhost:12076"}, "localhost:12076", 2)
+	require.NoError(t, err)
+	defer memberSvc.Stop()
+	outsiderSvc, err := CreateGossipServer([]string{"localhost:12077"}, "localhost:12077", 1)
+	require.NoError(t, err)
+	defer outsiderSvc.Stop()
+
+	mac := channel.GenerateMAC(leaderSvc.SelfPKIid(), "snapchannel")
+	_, err = leaderSvc.CreateChain(mac, "snapchannel", []*common.FileSyncInfo{
+		{Path: "config.yaml", Mode: "Append"},
+		{Path: "rfc2616.txt", Mode: "Append"},
+	})
+	require.NoError(t, err)
+	_, err = leaderSvc.AddMemberToChain(mac, memberSvc.SelfPKIid())
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrChannelNotExist, errors.Cause(leaderSvc.ExportChannelSnapshot("nochannel", &bytes.Buffer{})))
+	snapshot := &bytes.Buffer{}
+	require.NoError(t, leaderSvc.ExportChannelSnapshot("snapchannel", snapshot))
+
+	_, err = outsiderSvc.ImportChannelSnapshot(bytes.NewReader(snapshot.Bytes()))
+	assert.Equal(t, ErrNotMember, errors.Cause(err))
+	_, err = leaderSvc.ImportChannelSnapshot(bytes.NewReader(snapshot.Bytes()))
+	assert.Equal(t, ErrChannelExists, errors.Cause(err))
+	_, err = memberSvc.ImportChannelSnapshot(bytes.NewReader([]byte("garbage")))
+	assert.Equal(t, ErrInvalidSnapshot, errors.Cause(err))
+
+	expected, err := ioutil.ReadFile(filepath.Join(testDataDir, "peer0", "config.yaml"))
+	require.NoError(t, err)
+	tampered := append([]byte(nil), snapshot.Bytes()...)
+	i := bytes.Index(tampered, expected[:32])
+	require.True(t, i > 0)
+	tampered[i] ^= 0xff
+	_, err = memberSvc.ImportChannelSnapshot(bytes.NewReader(tampered))
+	assert.Equal(t, ErrInvalidSnapshot, errors.Cause(err))
+	assert.Nil(t, memberSvc.SelfChainInfo("snapchannel"))
+	_, err = os.Stat(filepath.Join(dir, "config.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	// A file replaced along with its entry of the manifest is detected by the signature of the manifest
+	forged := rewriteSnapshot(t, snapshot.Bytes(), func(name string, content []byte) []byte {
+		switch name {
+		case snapshotFilesDir + "config.yaml":
+			return []byte("forged")
+		case snapshotManifestEntry:
+			manifest := &snapshotManifest{}
+			require.NoError(t, json.Unmarshal(content, manifest))
+			sum := sha256.Sum256([]byte("forged"))
+			for i := range manifest.Files {
+				if manifest.Files[i].Name == "config.yaml" {
+					manifest.Files[i].Size = int64(len("forged"))
+					manifest.Files[i].SHA256 = hex.EncodeToString(sum[:])
+				}
+			}
+			b, err := json.Marshal(manifest)
+			require.NoError(t, err)
+			return b
+		}
+		return content
+	})
+	_, err = memberSvc.ImportChannelSnapshot(bytes.NewReader(forged))
+	assert.Equal(t, ErrInvalidSnapshot, errors.Cause(err))
+	assert.Nil(t, memberSvc.SelfChainInfo("snapchannel"))
+
+	chainState, err := memberSvc.ImportChannelSnapshot(bytes.NewReader(snapshot.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "snapchannel", chainState.ChainId)
+	assert.NotNil(t, memberSvc.SelfChainInfo("snapchannel"))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, content)
+	files, err := memberSvc.ChannelFiles("snapchannel")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, int64(len(expected)), files[0].Size)
+
+	_, err = memberSvc.ImportChannelSnapshot(bytes.NewReader(snapshot.Bytes()))
+	assert.Equal(t, ErrChannelExists, errors.Cause(err))
+
+	// The members can't sign the manifest on behalf of the leader
+	err = memberSvc.ExportChannelSnapshot("snapchannel", &bytes.Buffer{})
+	assert.Equal(t, ErrNotLeader, errors.Cause(err))
+}
+
+// rewriteSnapshot returns a copy of the snapshot whose entries are replaced by the given function
+func rewriteSnapshot(t *testing.T, snapshot []byte, rewrite func(name string, content []byte) []byte) []byte {
+	tr := tar.NewReader(bytes.NewReader(snapshot))
+	res := &bytes.Buffer{}
+	tw := tar.NewWriter(res)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		require.NoError(t, writeSnapshotEntry(tw, hdr.Name, rewrite(hdr.Name, content)))
+	}
+	require.NoError(t, tw.Close())
+	return res.Bytes()
+}
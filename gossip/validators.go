@@ -0,0 +1,124 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// MessageValidator enforces an application rule on the messages received from
+// the peers, such as a file name policy or a max number of channel members.
+// It returns why the message breaks the rule, nil if it's accepted.
+// It's called concurrently and must not block.
+type MessageValidator func(msg protos.ReceivedMessage) error
+
+type validationRule struct {
+	name     string
+	validate MessageValidator
+	rejected uint64
+}
+
+// messageValidators holds the rules registered by the application,
+// along with the number of messages each of them rejected
+type messageValidators struct {
+	sync.RWMutex
+	rules []*validationRule
+}
+
+func newMessageValidators() *messageValidators {
+	return &messageValidators{}
+}
+
+// register adds the rule, replacing the rule of the same name if any. The rules
+// are copied on write, so that the messages are validated without holding the lock.
+func (v *messageValidators) register(name string, validate MessageValidator) {
+	v.Lock()
+	defer v.Unlock()
+
+	rule := &validationRule{name: name, validate: validate}
+	rules := append([]*validationRule(nil), v.rules...)
+	for i, r := range rules {
+		if r.name == name {
+			rules[i] = rule
+			v.rules = rules
+			return
+		}
+	}
+	v.rules = append(rules, rule)
+}
+
+// validate checks the message against the rules in their registration order,
+// the first rule it breaks is counted and returned in the error. The ChainState
+// carried by a response is checked too, as if it was received on its own, so
+// that the rules on the ChainState messages can't be bypassed by pulling them.
+func (v *messageValidators) validate(msg protos.ReceivedMessage) error {
+	v.RLock()
+	rules := v.rules
+	v.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	msgs := []protos.ReceivedMessage{msg}
+	if element := embeddedChainState(msg.GetRKSyncMessage()); element != nil {
+		// The elements which can't be decoded are rejected by the channel
+		if m, err := element.ToRKSyncMessage(); err == nil {
+			msgs = append(msgs, &embeddedMessage{ReceivedMessage: msg, msg: m})
+		}
+	}
+
+	for _, rule := range rules {
+		for _, m := range msgs {
+			if err := rule.validate(m); err != nil {
+				atomic.AddUint64(&rule.rejected, 1)
+				return errors.WithMessagef(err, "message rejected by rule %s", rule.name)
+			}
+		}
+	}
+	return nil
+}
+
+// rejected returns the number of messages rejected by each rule, by rule name
+func (v *messageValidators) rejected() map[string]uint64 {
+	v.RLock()
+	defer v.RUnlock()
+
+	counts := make(map[string]uint64, len(v.rules))
+	for _, rule := range v.rules {
+		counts[rule.name] = atomic.LoadUint64(&rule.rejected)
+	}
+	return counts
+}
+
+// embeddedChainState returns the ChainState message carried by a response, nil if none
+func embeddedChainState(msg *protos.SignedRKSyncMessage) *protos.Envelope {
+	switch {
+	case msg.IsStatePullResponseMsg():
+		return msg.GetStatePullResponse().Element
+	case msg.IsStateRes():
+		return msg.GetStateRes().Element
+	}
+	return nil
+}
+
+// embeddedMessage is a message carried by a response received from a peer
+type embeddedMessage struct {
+	protos.ReceivedMessage
+	msg *protos.SignedRKSyncMessage
+}
+
+func (m *embeddedMessage) GetRKSyncMessage() *protos.SignedRKSyncMessage {
+	return m.msg
+}
+
+func (m *embeddedMessage) GetSourceEnvelope() *protos.Envelope {
+	return m.msg.Envelope
+}
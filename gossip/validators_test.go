@@ -0,0 +1,117 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/channel"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageValidators(t *testing.T) {
+	v := newMessageValidators()
+	msg := chainStateMsg(t, common.ChainMac("mac"))
+	assert.NoError(t, v.validate(msg))
+	assert.Empty(t, v.rejected())
+
+	called := 0
+	v.register("first", func(protos.ReceivedMessage) error {
+		called++
+		return errors.New("first error")
+	})
+	v.register("second", func(protos.ReceivedMessage) error {
+		return errors.New("second error")
+	})
+
+	// the rules are checked in order, the first one broken is counted
+	err := v.validate(msg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule first")
+	assert.Contains(t, err.Error(), "first error")
+	assert.Equal(t, map[string]uint64{"first": 1, "second": 0}, v.rejected())
+
+	// a rule registered again is replaced in place
+	v.register("first", func(protos.ReceivedMessage) error { return nil })
+	err = v.validate(msg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule second")
+	assert.Equal(t, 1, called)
+	assert.Equal(t, map[string]uint64{"first": 0, "second": 1}, v.rejected())
+
+	// the ChainState carried by a pull response is validated too
+	v = newMessageValidators()
+	v.register("no-state", func(m protos.ReceivedMessage) error {
+		if m.GetRKSyncMessage().IsChainStateMsg() {
+			return errors.New("chain state")
+		}
+		return nil
+	})
+	res, err := (&protos.RKSyncMessage{
+		Content: &protos.RKSyncMessage_StatePullResponse{StatePullResponse: &protos.ChainStatePullResponse{
+			Element: msg.GetRKSyncMessage().Envelope,
+		}},
+	}).NoopSign()
+	require.NoError(t, err)
+	assert.Error(t, v.validate(&receivedMessageMock{msg: res, sender: common.PKIidType("sender")}))
+	assert.Equal(t, map[string]uint64{"no-state": 1}, v.rejected())
+}
+
+func TestRegisterMessageValidator(t *testing.T) {
+	gossipSvc1, err := CreateGossipServer([]string{"localhost:12080"}, "localhost:12080", 0)
+	require.NoError(t, err)
+	defer gossipSvc1.Stop()
+
+	gossipSvc2, err := CreateGossipServer([]string{"localhost:12080"}, "localhost:13080", 1)
+	require.NoError(t, err)
+	defer gossipSvc2.Stop()
+
+	// the second peer refuses the channels sharing executables
+	gossipSvc2.RegisterMessageValidator("no-executables", func(m protos.ReceivedMessage) error {
+		msg := m.GetRKSyncMessage()
+		if !msg.IsChainStateMsg() {
+			return nil
+		}
+		info, err := msg.GetState().GetChainStateInfo()
+		if err != nil {
+			return err
+		}
+		for _, f := range info.Properties.Files {
+			if strings.HasSuffix(f.Path, ".exe") {
+				return errors.Errorf("file %s is an executable", f.Path)
+			}
+		}
+		return nil
+	})
+
+	require.Eventually(t, func() bool {
+		return len(gossipSvc2.Peers()) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	mac := channel.GenerateMAC(gossipSvc1.SelfPKIid(), "testchannel")
+	_, err = gossipSvc1.CreateChain(mac, "testchannel", []*common.FileSyncInfo{{Path: "config.yaml", Mode: "Append"}})
+	require.NoError(t, err)
+	_, err = gossipSvc1.AddMemberToChain(mac, gossipSvc2.SelfPKIid())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return chainFiles(gossipSvc2, "testchannel") == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, uint64(0), gossipSvc2.RejectedMessages()["no-executables"])
+
+	_, err = gossipSvc1.AddFileToChain(mac, []*common.FileSyncInfo{{Path: "setup.exe", Mode: "Append"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return gossipSvc2.RejectedMessages()["no-executables"] > 0
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, 1, chainFiles(gossipSvc2, "testchannel"))
+}
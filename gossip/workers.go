@@ -0,0 +1,82 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/rpc"
+)
+
+const (
+	// workerQueueSize is the number of received messages buffered per worker
+	workerQueueSize = 100
+	// chainStateLockStripes is the number of locks serializing the chain state messages, by channel
+	chainStateLockStripes = 64
+)
+
+// messageWorkers verifies and handles the received messages on several goroutines.
+// The messages of a peer are always handled by the same goroutine, so they are handled in order.
+type messageWorkers struct {
+	queues []chan protos.ReceivedMessage
+	done   sync.WaitGroup
+}
+
+func newMessageWorkers(n int, handle func(protos.ReceivedMessage)) *messageWorkers {
+	if n < 1 {
+		n = 1
+	}
+	w := &messageWorkers{queues: make([]chan protos.ReceivedMessage, n)}
+	w.done.Add(n)
+	for i := range w.queues {
+		queue := make(chan protos.ReceivedMessage, workerQueueSize)
+		w.queues[i] = queue
+		go func() {
+			defer w.done.Done()
+			for m := range queue {
+				handle(m)
+			}
+		}()
+	}
+	return w
+}
+
+// dispatch queues the message to the goroutine handling the messages of its sender,
+// it blocks while the queue is full
+func (w *messageWorkers) dispatch(m protos.ReceivedMessage) {
+	var sender []byte
+	if m != nil && m.GetConnectionInfo() != nil {
+		sender = m.GetConnectionInfo().ID
+	}
+	w.queues[stripe(sender, len(w.queues))] <- m
+}
+
+// stop waits for the queued messages to be handled, dispatch must not be called anymore
+func (w *messageWorkers) stop() {
+	for _, queue := range w.queues {
+		close(queue)
+	}
+	w.done.Wait()
+}
+
+// queueDepths returns the number of received messages waiting in the queue of each goroutine
+func (w *messageWorkers) queueDepths() []rpc.QueueDepth {
+	depths := make([]rpc.QueueDepth, len(w.queues))
+	for i, queue := range w.queues {
+		depths[i] = rpc.QueueDepth{Name: fmt.Sprintf("worker/%d", i), Length: len(queue), Capacity: cap(queue)}
+	}
+	return depths
+}
+
+func stripe(key []byte, n int) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,77 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageWorkers(t *testing.T) {
+	var lock sync.Mutex
+	handled := make(map[string][]uint64)
+	blocked := make(chan struct{})
+	workers := newMessageWorkers(4, func(m protos.ReceivedMessage) {
+		sender := m.GetConnectionInfo().ID.String()
+		if sender == common.PKIidType("slow").String() {
+			<-blocked
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		handled[sender] = append(handled[sender], m.GetRKSyncMessage().Nonce)
+	})
+
+	message := func(sender string, nonce uint64) protos.ReceivedMessage {
+		msg, err := (&protos.RKSyncMessage{Nonce: nonce, Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}).NoopSign()
+		require.NoError(t, err)
+		return &receivedMessageMock{msg: msg, sender: common.PKIidType(sender)}
+	}
+
+	// A slow peer doesn't hold back the peers handled by other workers
+	var peers []string
+	for i := 0; len(peers) < 3; i++ {
+		peer := fmt.Sprintf("peer%d", i)
+		if stripe([]byte(peer), 4) != stripe([]byte("slow"), 4) {
+			peers = append(peers, peer)
+		}
+	}
+	workers.dispatch(message("slow", 0))
+	for nonce := uint64(0); nonce < 50; nonce++ {
+		for _, peer := range peers {
+			workers.dispatch(message(peer, nonce))
+		}
+	}
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, peer := range peers {
+			if len(handled[common.PKIidType(peer).String()]) != 50 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(blocked)
+	workers.stop()
+
+	// The messages of a peer are handled in order
+	for _, peer := range peers {
+		nonces := handled[common.PKIidType(peer).String()]
+		for i, nonce := range nonces {
+			assert.Equal(t, uint64(i), nonce)
+		}
+	}
+	assert.Len(t, handled[common.PKIidType("slow").String()], 1)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+)
+
+// CacheConfig bounds the identities of the remote peers held by the Identity
+type CacheConfig struct {
+	TTL        time.Duration               // Identities neither used nor referenced for this duration are purged, 0 disables it
+	MaxSize    int                         // Max number of identities, the least recently used unreferenced ones are purged first, 0 disables it
+	Referenced func(common.PKIidType) bool // Tells whether a peer is referenced by the membership, its identity is kept then
+}
+
+// CacheStats holds the number of identities held by the Identity and the number of identities purged, by reason
+type CacheStats struct {
+	Size     int
+	Unused   uint64 // Neither used nor referenced during the TTL
+	Evicted  uint64 // Purged to meet the max size
+	Expired  uint64 // The certificate expired
+	Rejected uint64 // Failed revalidation after being suspected
+}
+
+// Option configures the Identity
+type Option func(*identityMapper)
+
+// WithCache sets the expiration of the identities of the remote peers, the own identity is never purged
+func WithCache(cache CacheConfig) Option {
+	return func(is *identityMapper) {
+		is.cache = cache
+	}
+}
+
+func (is *identityMapper) Stats() CacheStats {
+	return CacheStats{
+		Size:     int(atomic.LoadInt64(&is.size)),
+		Unused:   atomic.LoadUint64(&is.stats.Unused),
+		Evicted:  atomic.LoadUint64(&is.stats.Evicted),
+		Expired:  atomic.LoadUint64(&is.stats.Expired),
+		Rejected: atomic.LoadUint64(&is.stats.Rejected),
+	}
+}
+
+func (is *identityMapper) Stop() {
+	is.stopOnce.Do(func() {
+		close(is.stopCh)
+	})
+}
+
+func (is *identityMapper) SuspectPeers(isSuspected func(common.PeerIdentityType) bool) {
+	if reloader, ok := is.provider.(config.IdentityReloader); ok {
+		if err := reloader.Reload(); err != nil {
+			is.logger.Warningf("Failed reloading the trust material, revalidating against the current one: %s", err)
+		}
+	}
+	for _, stored := range is.snapshot() {
+		if !isSuspected(stored.identity) {
+			continue
+		}
+		if _, err := is.validateIdentity(stored.identity); err != nil {
+			is.logger.Warningf("Purging identity of peer %s: %s", stored.pkiID, err)
+			is.delete(stored.pkiID, &is.stats.Rejected)
+		}
+	}
+}
+
+// snapshot returns the identities of the remote peers
+func (is *identityMapper) snapshot() []*storedIdentity {
+	identities := make([]*storedIdentity, 0, atomic.LoadInt64(&is.size))
+	for i := range is.shards {
+		shard := &is.shards[i]
+		shard.RLock()
+		for _, stored := range shard.certs {
+			if !stored.pkiID.IsNotSameFilter(is.selfPKIID) {
+				continue
+			}
+			identities = append(identities, stored)
+		}
+		shard.RUnlock()
+	}
+	return identities
+}
+
+// unreferenced returns the identities of the peers the membership doesn't reference, the referenced ones being used
+func (is *identityMapper) unreferenced() []*storedIdentity {
+	identities := is.snapshot()
+	if is.cache.Referenced == nil {
+		return identities
+	}
+
+	unreferenced := identities[:0]
+	for _, stored := range identities {
+		if is.cache.Referenced(stored.pkiID) {
+			stored.touch()
+			continue
+		}
+		unreferenced = append(unreferenced, stored)
+	}
+	return unreferenced
+}
+
+// sweep purges the identities unused since the TTL at the given time
+func (is *identityMapper) sweep(now time.Time) {
+	for _, stored := range is.unreferenced() {
+		if now.Sub(stored.unusedSince()) > is.cache.TTL {
+			is.logger.Debugf("Purging unused identity of peer %s", stored.pkiID)
+			is.delete(stored.pkiID, &is.stats.Unused)
+		}
+	}
+	is.evict()
+}
+
+// evict purges the least recently used identities beyond the max size
+func (is *identityMapper) evict() {
+	if is.cache.MaxSize <= 0 {
+		return
+	}
+	excess := is.Stats().Size - is.cache.MaxSize
+	if excess <= 0 {
+		return
+	}
+
+	identities := is.unreferenced()
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].unusedSince().Before(identities[j].unusedSince())
+	})
+	for i := 0; i < excess && i < len(identities); i++ {
+		is.logger.Debugf("Evicting identity of peer %s", identities[i].pkiID)
+		is.delete(identities[i].pkiID, &is.stats.Evicted)
+	}
+}
+
+func (is *identityMapper) periodicalSweep() {
+	interval := is.cache.TTL / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	for {
+		select {
+		case <-is.stopCh:
+			return
+		case now := <-time.After(interval):
+			is.sweep(now)
+		}
+	}
+}
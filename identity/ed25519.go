@@ -0,0 +1,62 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loadEd25519Signer looks up the keystore for the PKCS#8 encoded
+// Ed25519 private key matching the given public key.
+// The cccsp keystore only understands ECDSA and RSA keys, so Ed25519
+// keys are read from the keystore directory directly.
+func loadEd25519Signer(keyStoreDir string, pubKey ed25519.PublicKey) (ed25519.PrivateKey, error) {
+	files, err := ioutil.ReadDir(keyStoreDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading keystore directory %s", keyStoreDir)
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), "_sk") {
+			continue
+		}
+
+		raw, err := ioutil.ReadFile(filepath.Join(keyStoreDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			continue
+		}
+
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		privKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			continue
+		}
+
+		if bytes.Equal(privKey.Public().(ed25519.PublicKey), pubKey) {
+			return privKey, nil
+		}
+	}
+
+	return nil, errors.New("Could not find matching Ed25519 private key in keystore")
+}
@@ -9,365 +9,338 @@ package identity
 
 import (
 	"bytes"
-	"crypto"
-	"crypto/rand"
-	"crypto/x509"
-	"encoding/hex"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
-	"github.com/rkcloudchain/cccsp"
-	"github.com/rkcloudchain/cccsp/hash"
-	"github.com/rkcloudchain/cccsp/importer"
-	"github.com/rkcloudchain/cccsp/provider"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
-	"github.com/rkcloudchain/rksync/util"
 )
 
 // Identity holds identities of peer
 type Identity interface {
+	// Put stores the identity of the peer, the identity stored first for a PKI-ID is kept
 	Put(pkiID common.PKIidType, identity common.PeerIdentityType) error
+	// Renew replaces the stored identity of the peer by a renewed one keeping the PKI-ID, i.e. a rotated
+	// X.509 SVID. Only the SPIFFE identities rotate, the renewed identity must have signed the message.
+	Renew(pkiID common.PKIidType, identity common.PeerIdentityType, algorithm protos.SignatureAlgorithm, signature, message []byte) error
 	Get(pkiID common.PKIidType) (common.PeerIdentityType, error)
 	Sign(msg []byte) ([]byte, error)
-	Verify(vkID common.PKIidType, signature, message []byte) error
+	// Verify checks the signature of the message by the peer, the algorithm recorded along with the signature
+	// must match the key of its identity unless it's unspecified
+	Verify(vkID common.PKIidType, algorithm protos.SignatureAlgorithm, signature, message []byte) error
 	GetPKIidOfCert(common.PeerIdentityType) common.PKIidType
+	SignatureAlgorithm() protos.SignatureAlgorithm
+	// SelfIdentity returns the identity of the peer, it changes when its X.509 SVID is rotated
+	SelfIdentity() common.PeerIdentityType
+	// SuspectPeers revalidates the identities the given predicate selects against the reloaded
+	// trust material of the provider, the invalid ones are purged
+	SuspectPeers(isSuspected func(common.PeerIdentityType) bool)
+	// Stats returns the number of identities held and purged
+	Stats() CacheStats
+	// Stop stops the expiration of the unused identities
+	Stop()
 }
 
 type purgeTrigger func(pkiID common.PKIidType)
 
-type identityMapper struct {
-	onPurge           purgeTrigger
-	certs             map[string]*storedIdentity
-	opts              *x509.VerifyOptions
-	rootCerts         []*x509.Certificate
-	intermediateCerts []*x509.Certificate
-	csp               cccsp.CCCSP
-	signer            crypto.Signer
+// certShards is the number of shards of the identities, so that the lookups
+// of the identities of the peers verifying messages don't contend on a single lock
+const certShards = 32
+
+type certShard struct {
 	sync.RWMutex
+	certs map[string]*storedIdentity
+}
+
+type identityMapper struct {
+	onPurge      purgeTrigger
+	shards       [certShards]certShard
+	size         int64 // Number of identities held, updated atomically
+	provider     config.IdentityProvider
+	logger       logging.Logger
+	selfPKIID    common.PKIidType
+	selfIdentity atomic.Value // common.PeerIdentityType
+	selfLock     sync.Mutex
+	cache        CacheConfig
+	stats        CacheStats
+	stopCh       chan struct{}
+	stopOnce     sync.Once
 }
 
-// NewIdentity returns a new Identity instance
-func NewIdentity(cfg *config.IdentityConfig, selfIdentity common.PeerIdentityType, onPurge purgeTrigger) (Identity, error) {
+// NewIdentity returns a new Identity instance. The identities are handled by the provider
+// of the configuration if set, otherwise by the SPIFFE or the X.509 support, selfIdentity
+// being the X.509 identity of the peer in the latter case.
+func NewIdentity(cfg *config.IdentityConfig, selfIdentity common.PeerIdentityType, onPurge purgeTrigger, logger logging.Logger, opts ...Option) (Identity, error) {
 	if cfg == nil {
 		return nil, errors.New("NewIdentity error: nil cfg reference")
 	}
 
-	logging.Debug("Creating Identity instance")
+	logger.Debug("Creating Identity instance")
 	identity := &identityMapper{
-		certs: make(map[string]*storedIdentity),
+		onPurge: onPurge,
+		logger:  logger,
+		stopCh:  make(chan struct{}),
 	}
-
-	keyStoreDir := cfg.GetKeyStoreDir()
-	fks, err := provider.NewFileKeyStore(keyStoreDir)
-	if err != nil {
-		return nil, err
+	for i := range identity.shards {
+		identity.shards[i].certs = make(map[string]*storedIdentity)
+	}
+	for _, opt := range opts {
+		opt(identity)
 	}
-	identity.csp = provider.New(fks)
-
-	selfPKIID := identity.GetPKIidOfCert(selfIdentity)
 
-	if err := identity.setupCAs(cfg); err != nil {
+	var err error
+	switch {
+	case cfg.Provider != nil:
+		identity.provider = cfg.Provider
+	case cfg.SPIFFE != nil:
+		identity.provider, err = newSPIFFEProvider(cfg, logger)
+	default:
+		identity.provider, err = newX509Provider(cfg, selfIdentity, logger)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	if err := identity.setupCSP(cfg); err != nil {
-		return nil, err
+	selfIdentity, err = identity.provider.Serialize()
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed serializing own identity")
 	}
+	selfPKIID := identity.GetPKIidOfCert(selfIdentity)
+	identity.selfPKIID = selfPKIID
+	identity.selfIdentity.Store(selfIdentity)
 
 	if err := identity.Put(selfPKIID, selfIdentity); err != nil {
 		return nil, errors.Wrap(err, "Failed putting out own identity into the identity mapper")
 	}
+	if identity.cache.TTL > 0 {
+		go identity.periodicalSweep()
+	}
 	return identity, nil
 }
 
 func (is *identityMapper) Put(pkiID common.PKIidType, identity common.PeerIdentityType) error {
-	if pkiID == nil {
-		return errors.New("PKIID is nil")
-	}
-	if identity == nil {
-		return errors.New("identity is nil")
+	expirationDate, err := is.checkIdentity(pkiID, identity)
+	if err != nil {
+		return err
 	}
+	is.store(pkiID, identity, expirationDate, false)
+	return nil
+}
 
-	id := is.GetPKIidOfCert(identity)
-	if !bytes.Equal(pkiID, id) {
-		return errors.New("Identity doesn't match the computed PKIID")
+func (is *identityMapper) Renew(pkiID common.PKIidType, identity common.PeerIdentityType, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+	if _, rotates := is.provider.(*spiffeProvider); !rotates {
+		return errors.New("The identities of the provider aren't renewed")
 	}
-
-	sid := &protos.SerializedIdentity{}
-	err := proto.Unmarshal(identity, sid)
+	expirationDate, err := is.checkIdentity(pkiID, identity)
 	if err != nil {
-		return errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+		return err
 	}
-
-	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
-	if err != nil {
+	if err := checkSignatureAlgorithm(is.provider, identity, algorithm); err != nil {
 		return err
 	}
+	if err := is.provider.Verify(identity, signature, message); err != nil {
+		return errors.WithMessage(err, "The message wasn't signed by the renewed identity")
+	}
+	is.store(pkiID, identity, expirationDate, true)
+	return nil
+}
+
+// checkIdentity checks that the identity is valid and derives the PKI-ID,
+// and returns the time it expires
+func (is *identityMapper) checkIdentity(pkiID common.PKIidType, identity common.PeerIdentityType) (time.Time, error) {
+	if pkiID == nil {
+		return time.Time{}, errors.New("PKIID is nil")
+	}
+	if identity == nil {
+		return time.Time{}, errors.New("identity is nil")
+	}
 
-	if _, err := cert.Verify(*is.opts); err != nil {
-		return errors.New("could not validate identity against certification chain")
+	id := is.GetPKIidOfCert(identity)
+	if !bytes.Equal(pkiID, id) {
+		return time.Time{}, errors.New("Identity doesn't match the computed PKIID")
 	}
 
-	is.Lock()
-	defer is.Unlock()
+	return is.validateIdentity(identity)
+}
 
-	if _, exists := is.certs[pkiID.String()]; exists {
-		return nil
+// store stores the identity of the peer, the identity already stored for the PKI-ID
+// is kept unless replace is set
+func (is *identityMapper) store(pkiID common.PKIidType, identity common.PeerIdentityType, expirationDate time.Time, replace bool) {
+	shard := is.shard(pkiID)
+	shard.Lock()
+	defer shard.Unlock()
+
+	stored, exists := shard.certs[pkiID.String()]
+	if exists {
+		if !replace || bytes.Equal(stored.identity, identity) {
+			stored.touch()
+			return
+		}
+		is.logger.Debugf("Replacing identity of peer %s by its renewed identity", pkiID)
+		if stored.expirationTimer != nil {
+			stored.expirationTimer.Stop()
+		}
 	}
 
 	var expirationTimer *time.Timer
-	expirationDate := cert.NotAfter
 	if !expirationDate.IsZero() {
-		if time.Now().After(expirationDate) {
-			return errors.New("Identity expired")
-		}
-
 		timeToLive := expirationDate.Add(time.Millisecond).Sub(time.Now())
 		expirationTimer = time.AfterFunc(timeToLive, func() {
-			is.delete(pkiID)
+			is.delete(pkiID, &is.stats.Expired)
 		})
 	}
 
-	is.certs[pkiID.String()] = newStoredIdentity(pkiID, identity, expirationTimer)
-	return nil
+	shard.certs[pkiID.String()] = newStoredIdentity(pkiID, identity, expirationTimer)
+	if exists {
+		return
+	}
+	if size := atomic.AddInt64(&is.size, 1); is.cache.MaxSize > 0 && size > int64(is.cache.MaxSize) {
+		go is.evict()
+	}
+}
+
+// shard returns the shard holding the identity of the peer
+func (is *identityMapper) shard(pkiID common.PKIidType) *certShard {
+	// FNV-1a, inlined to spare the allocation of a hash
+	h := uint32(2166136261)
+	for _, b := range pkiID {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	return &is.shards[h%certShards]
+}
+
+// validateIdentity validates the identity with the provider, and returns the time it expires
+func (is *identityMapper) validateIdentity(identity common.PeerIdentityType) (time.Time, error) {
+	expirationDate, err := is.provider.Validate(identity)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !expirationDate.IsZero() && time.Now().After(expirationDate) {
+		return time.Time{}, errors.New("Identity expired")
+	}
+	return expirationDate, nil
 }
 
 func (is *identityMapper) Get(pkiID common.PKIidType) (common.PeerIdentityType, error) {
-	is.RLock()
-	defer is.RUnlock()
+	shard := is.shard(pkiID)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	id, exists := is.certs[pkiID.String()]
+	id, exists := shard.certs[pkiID.String()]
 	if !exists {
 		return nil, errors.New("PKIID wasn't found")
 	}
+	id.touch()
 	return id.identity, nil
 }
 
 func (is *identityMapper) Sign(msg []byte) ([]byte, error) {
-	if is.signer == nil {
-		return nil, errors.New("The signer must not be nil")
-	}
-
-	digest, err := is.csp.Hash(msg, hash.SHA3256)
-	if err != nil {
-		return nil, err
-	}
-
-	return is.signer.Sign(rand.Reader, digest, nil)
+	return is.provider.Sign(msg)
 }
 
-func (is *identityMapper) Verify(vkID common.PKIidType, signature, message []byte) error {
+func (is *identityMapper) Verify(vkID common.PKIidType, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
 	identity, err := is.Get(vkID)
 	if err != nil {
 		return err
 	}
-
-	var sid protos.SerializedIdentity
-	err = proto.Unmarshal(identity, &sid)
-	if err != nil {
-		return err
-	}
-
-	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
-	if err != nil {
-		return err
-	}
-
-	k, err := is.csp.KeyImport(cert, importer.X509CERT, true)
-	if err != nil {
+	if err := checkSignatureAlgorithm(is.provider, identity, algorithm); err != nil {
 		return err
 	}
-
-	digest, err := is.csp.Hash(message, hash.SHA3256)
-	if err != nil {
-		return err
-	}
-
-	valid, err := is.csp.Verify(k, signature, digest, nil)
-	if err != nil {
-		return errors.Wrap(err, "Could not determine the validity of the signature")
-	}
-	if !valid {
-		return errors.New("The signature is invalid")
-	}
-
-	return nil
+	return is.provider.Verify(identity, signature, message)
 }
 
-func (is *identityMapper) GetPKIidOfCert(peerIdentity common.PeerIdentityType) common.PKIidType {
-	if len(peerIdentity) == 0 {
-		logging.Error("Invalid Peer Identity. It must be different from nil")
+// checkSignatureAlgorithm checks that the algorithm recorded along with a signature is the one of the key of
+// the identity. The algorithms unknown to the peer, e.g. introduced by later versions, are treated as unspecified.
+func checkSignatureAlgorithm(provider config.IdentityProvider, identity common.PeerIdentityType, algorithm protos.SignatureAlgorithm) error {
+	if _, known := protos.SignatureAlgorithm_name[int32(algorithm)]; !known || algorithm == protos.SignatureAlgorithm_UNSPECIFIED {
 		return nil
 	}
-
-	sid := &protos.SerializedIdentity{}
-	err := proto.Unmarshal(peerIdentity, sid)
-	if err != nil {
-		logging.Errorf("could not unmarshalling a SerializedIdentity: %v", err)
+	resolver, ok := provider.(config.SignatureAlgorithmResolver)
+	if !ok {
 		return nil
 	}
-
-	nodeIDRaw := []byte(sid.NodeId)
-	raw := append(nodeIDRaw, sid.IdBytes...)
-
-	digest, err := is.csp.Hash(raw, hash.SHA3256)
+	expected, err := resolver.SignatureAlgorithmOf(identity)
 	if err != nil {
-		logging.Errorf("Failed computing digest of serialized identity [% x]: [%s]", peerIdentity, err)
-		return nil
-	}
-
-	return digest
-}
-
-func (is *identityMapper) setupCAs(conf *config.IdentityConfig) error {
-	cacerts := conf.GetRootCAs()
-	intermediatecerts := conf.GetIntermediateCAs()
-
-	is.opts = &x509.VerifyOptions{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool()}
-	rootCAs := make([]*x509.Certificate, len(cacerts))
-	intermediateCAs := make([]*x509.Certificate, len(intermediatecerts))
-	for i, v := range cacerts {
-		cert, err := util.GetX509CertificateFromPEM(v)
-		if err != nil {
-			return err
-		}
-		rootCAs[i] = cert
-		is.opts.Roots.AddCert(cert)
-	}
-	for i, v := range intermediatecerts {
-		cert, err := util.GetX509CertificateFromPEM(v)
-		if err != nil {
-			return err
-		}
-		intermediateCAs[i] = cert
-		is.opts.Intermediates.AddCert(cert)
-	}
-
-	is.rootCerts = make([]*x509.Certificate, len(cacerts))
-	for i, trustedCert := range rootCAs {
-		cert, err := is.sanitizeCert(trustedCert)
-		if err != nil {
-			return err
-		}
-		is.rootCerts[i] = cert
-	}
-	is.intermediateCerts = make([]*x509.Certificate, len(intermediatecerts))
-	for i, trustedCert := range intermediateCAs {
-		cert, err := is.sanitizeCert(trustedCert)
-		if err != nil {
-			return err
-		}
-		is.intermediateCerts[i] = cert
-	}
-
-	is.opts = &x509.VerifyOptions{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool()}
-	for _, cert := range is.rootCerts {
-		is.opts.Roots.AddCert(cert)
+		return err
 	}
-	for _, cert := range is.intermediateCerts {
-		is.opts.Intermediates.AddCert(cert)
+	if algorithm != expected {
+		return errors.Errorf("Signature algorithm %s doesn't match the %s key of the identity", algorithm, expected)
 	}
-
 	return nil
 }
 
-func (is *identityMapper) setupCSP(conf *config.IdentityConfig) error {
-	cert, err := util.GetX509CertificateFromPEM(conf.GetCertificate())
-	if err != nil {
-		return err
-	}
-
-	cert, err = is.sanitizeCert(cert)
-	if err != nil {
-		return err
-	}
-
-	certPubK, err := is.csp.KeyImport(cert, importer.X509CERT, true)
-	if err != nil {
-		return errors.Wrap(err, "Failed to import certificate's public key")
-	}
+func (is *identityMapper) SignatureAlgorithm() protos.SignatureAlgorithm {
+	return is.provider.SignatureAlgorithm()
+}
 
-	id := certPubK.Identifier()
-	privateKey, err := is.csp.GetKey(id)
+func (is *identityMapper) SelfIdentity() common.PeerIdentityType {
+	current := is.selfIdentity.Load().(common.PeerIdentityType)
+	identity, err := is.provider.Serialize()
 	if err != nil {
-		id = certPubK.SKI()
-		privateKey, err = is.csp.GetKey(id)
-		if err != nil {
-			return errors.Wrap(err, "Could not find matching private key for SKI")
-		}
+		is.logger.Warningf("Failed serializing own identity: %s", err)
+		return current
 	}
-	if !privateKey.Private() {
-		return errors.Errorf("The private key associated with the certificate with SKI '%s' was not found", hex.EncodeToString(id))
+	if bytes.Equal(identity, current) {
+		return current
 	}
 
-	signer, err := provider.NewSigner(is.csp, privateKey)
+	is.selfLock.Lock()
+	defer is.selfLock.Unlock()
+	expirationDate, err := is.checkIdentity(is.selfPKIID, identity)
 	if err != nil {
-		return errors.Wrap(err, "Failed to create signer from cccsp")
+		is.logger.Warningf("Renewed own identity is invalid: %s", err)
+		return current
 	}
-	is.signer = signer
-
-	return nil
-}
-
-func (is *identityMapper) delete(pkiID common.PKIidType) {
-	is.Lock()
-	defer is.Unlock()
-	is.onPurge(pkiID)
-	delete(is.certs, pkiID.String())
+	is.store(is.selfPKIID, identity, expirationDate, true)
+	is.logger.Info("Own identity was renewed")
+	is.selfIdentity.Store(identity)
+	return identity
 }
 
-// sanitizeCert ensures that x509 certificates signed using ECDSA
-// do have signatures in Low-S. If this is not the case, the certificate
-// is regenerated to have a Low-S signature.
-func (is *identityMapper) sanitizeCert(cert *x509.Certificate) (*x509.Certificate, error) {
-	if isECDSASignedCert(cert) {
-		var parentCert *x509.Certificate
-		chain, err := is.getUniqueValidationChain(cert)
-		if err != nil {
-			return nil, err
-		}
-
-		if cert.IsCA && len(chain) == 1 {
-			parentCert = cert
-		} else if len(chain) > 1 {
-			parentCert = chain[1]
-		} else {
-			return nil, errors.New("Invalid x.509 certificate")
-		}
+func (is *identityMapper) GetPKIidOfCert(peerIdentity common.PeerIdentityType) common.PKIidType {
+	if len(peerIdentity) == 0 {
+		is.logger.Error("Invalid Peer Identity. It must be different from nil")
+		return nil
+	}
 
-		cert, err = sanitizeECDSASignedCert(cert, parentCert)
-		if err != nil {
-			return nil, err
-		}
+	pkiID, err := is.provider.PKIID(peerIdentity)
+	if err != nil {
+		is.logger.Errorf("Failed computing PKI-ID of peer identity [% x]: [%s]", peerIdentity, err)
+		return nil
 	}
-	return cert, nil
+	return pkiID
 }
 
-func (is *identityMapper) getUniqueValidationChain(cert *x509.Certificate) ([]*x509.Certificate, error) {
-	if is.opts == nil {
-		return nil, errors.New("The supplied identity has no verify options")
+// delete purges the identity and increments the given purge counter
+func (is *identityMapper) delete(pkiID common.PKIidType, counter *uint64) {
+	shard := is.shard(pkiID)
+	shard.Lock()
+	defer shard.Unlock()
+	stored, exists := shard.certs[pkiID.String()]
+	if !exists {
+		return
 	}
-	validationChains, err := cert.Verify(*is.opts)
-	if err != nil {
-		return nil, errors.WithMessage(err, "The supplied identity is not valid")
+	if stored.expirationTimer != nil {
+		stored.expirationTimer.Stop()
 	}
-
-	if len(validationChains) != 1 {
-		return nil, errors.Errorf("This Identity only supports a single validation chain, got %d", len(validationChains))
+	delete(shard.certs, pkiID.String())
+	atomic.AddInt64(&is.size, -1)
+	atomic.AddUint64(counter, 1)
+	if is.onPurge != nil {
+		is.onPurge(pkiID)
 	}
-
-	return validationChains[0], nil
 }
 
 type storedIdentity struct {
 	pkiID           common.PKIidType
 	identity        common.PeerIdentityType
 	expirationTimer *time.Timer
+	lastAccess      int64 // UnixNano
 }
 
 func newStoredIdentity(pkiID common.PKIidType, identity common.PeerIdentityType, expirationTimer *time.Timer) *storedIdentity {
@@ -375,5 +348,15 @@ func newStoredIdentity(pkiID common.PKIidType, identity common.PeerIdentityType,
 		pkiID:           pkiID,
 		identity:        identity,
 		expirationTimer: expirationTimer,
+		lastAccess:      time.Now().UnixNano(),
 	}
 }
+
+// touch records that the identity is used
+func (si *storedIdentity) touch() {
+	atomic.StoreInt64(&si.lastAccess, time.Now().UnixNano())
+}
+
+func (si *storedIdentity) unusedSince() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&si.lastAccess))
+}
@@ -7,17 +7,39 @@ SPDX-License-Identifier: Apache-2.0
 package identity
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/cccsp/hash"
 	"github.com/rkcloudchain/cccsp/provider"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/tests/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestVerify(t *testing.T) {
@@ -33,7 +55,7 @@ func TestVerify(t *testing.T) {
 	selfIdentity, err := util.GetIdentity(cfg)
 	require.NoError(t, err)
 
-	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	assert.NoError(t, err)
 
 	vid := idMapper.GetPKIidOfCert(selfIdentity)
@@ -41,7 +63,7 @@ func TestVerify(t *testing.T) {
 
 	signed, err := idMapper.Sign([]byte("bla bla"))
 	assert.NoError(t, err)
-	assert.NoError(t, idMapper.Verify(vid, signed, []byte("bla bla")))
+	assert.NoError(t, idMapper.Verify(vid, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
 }
 
 func TestGet(t *testing.T) {
@@ -57,7 +79,7 @@ func TestGet(t *testing.T) {
 	selfIdentity, err := util.GetIdentity(cfg)
 	require.NoError(t, err)
 
-	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	assert.NoError(t, err)
 
 	vid := idMapper.GetPKIidOfCert(selfIdentity)
@@ -69,7 +91,7 @@ func TestGet(t *testing.T) {
 }
 
 func TestGetPKIidOfCert(t *testing.T) {
-	idMapper := &identityMapper{csp: provider.GetDefault()}
+	idMapper := &identityMapper{provider: &x509Provider{csp: provider.GetDefault()}, logger: logging.Default()}
 	id := idMapper.GetPKIidOfCert(nil)
 	assert.Nil(t, id)
 
@@ -96,7 +118,7 @@ func TestNewIdentity(t *testing.T) {
 	selfIdentity, err := util.GetIdentity(cfg)
 	require.NoError(t, err)
 
-	_, err = NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	_, err = NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Invalid x.509 certificate")
 
@@ -109,7 +131,627 @@ func TestNewIdentity(t *testing.T) {
 	selfIdentity, err = util.GetIdentity(cfg)
 	require.NoError(t, err)
 
-	_, err = NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	_, err = NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "The supplied identity is not valid")
 }
+
+func TestSignatureAlgorithms(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm protos.SignatureAlgorithm
+	}{
+		{"ECDSA-P384", p384Key, protos.SignatureAlgorithm_ECDSA_SHA3_256},
+		{"Ed25519", ed25519Key, protos.SignatureAlgorithm_ED25519},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			home, err := ioutil.TempDir("", "identity")
+			require.NoError(t, err)
+			defer os.RemoveAll(home)
+			writeIdentityHome(t, home, test.key)
+
+			cfg := &config.IdentityConfig{ID: "peer0.org1"}
+			require.NoError(t, cfg.MakeFilesAbs(home))
+			selfIdentity, err := util.GetIdentity(cfg)
+			require.NoError(t, err)
+
+			idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
+			require.NoError(t, err)
+			assert.Equal(t, test.algorithm, idMapper.SignatureAlgorithm())
+
+			vid := idMapper.GetPKIidOfCert(selfIdentity)
+			signed, err := idMapper.Sign([]byte("bla bla"))
+			assert.NoError(t, err)
+			assert.NoError(t, idMapper.Verify(vid, test.algorithm, signed, []byte("bla bla")))
+			assert.Error(t, idMapper.Verify(vid, test.algorithm, signed, []byte("bla bla bla")))
+			// the algorithm recorded along with the signature must match the key, unless it's unspecified or unknown
+			assert.Error(t, idMapper.Verify(vid, protos.SignatureAlgorithm_RSA_SHA3_256, signed, []byte("bla bla")))
+			assert.NoError(t, idMapper.Verify(vid, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+			assert.NoError(t, idMapper.Verify(vid, protos.SignatureAlgorithm(99), signed, []byte("bla bla")))
+
+			msg := &protos.SignedRKSyncMessage{RKSyncMessage: &protos.RKSyncMessage{Nonce: 1}}
+			envp, err := msg.SignWithAlgorithm(idMapper.SignatureAlgorithm(), idMapper.Sign)
+			require.NoError(t, err)
+			assert.Equal(t, test.algorithm, envp.SignatureAlgorithm)
+			verifier := func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
+				return idMapper.Verify(peerIdentity, algorithm, signature, message)
+			}
+			assert.NoError(t, msg.Verify(vid, verifier))
+			envp.SignatureAlgorithm = protos.SignatureAlgorithm_RSA_SHA3_256
+			assert.Error(t, msg.Verify(vid, verifier))
+			envp.SignatureAlgorithm = protos.SignatureAlgorithm(99)
+			assert.NoError(t, msg.Verify(vid, verifier))
+		})
+	}
+}
+
+func TestPKCS11Signer(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	home, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	writeIdentityHome(t, home, key)
+
+	cfg := &config.IdentityConfig{ID: "peer0.org1"}
+	require.NoError(t, cfg.MakeFilesAbs(home))
+	keyFiles, err := filepath.Glob(filepath.Join(cfg.GetKeyStoreDir(), "*_sk"))
+	require.NoError(t, err)
+	for _, f := range keyFiles {
+		require.NoError(t, os.Remove(f))
+	}
+	selfIdentity, err := util.GetIdentity(cfg)
+	require.NoError(t, err)
+
+	registered, _ := getPKCS11Factory()
+	defer RegisterPKCS11SignerFactory(registered)
+	RegisterPKCS11SignerFactory(nil)
+
+	cfg.PKCS11 = &config.PKCS11Config{Library: "/usr/lib/softhsm/libsofthsm2.so", Slot: 1, Pin: "98765432", Label: "peer0"}
+	assert.EqualError(t, ValidatePKCS11Config(cfg.PKCS11), "No PKCS#11 signer factory registered, build with the pkcs11 tag or see identity.RegisterPKCS11SignerFactory")
+	_, err = NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
+	assert.Error(t, err)
+	assert.EqualError(t, ValidatePKCS11Config(&config.PKCS11Config{Label: "peer0"}), "PKCS#11 library must be provided")
+
+	var requested *config.PKCS11Config
+	RegisterPKCS11SignerFactory(func(conf *config.PKCS11Config, pubKey crypto.PublicKey) (crypto.Signer, error) {
+		requested = conf
+		return key, nil
+	})
+	assert.NoError(t, ValidatePKCS11Config(cfg.PKCS11))
+
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
+	require.NoError(t, err)
+	assert.Equal(t, cfg.PKCS11, requested)
+	assert.Equal(t, protos.SignatureAlgorithm_ECDSA_SHA3_256, idMapper.SignatureAlgorithm())
+
+	vid := idMapper.GetPKIidOfCert(selfIdentity)
+	signed, err := idMapper.Sign([]byte("bla bla"))
+	assert.NoError(t, err)
+	assert.NoError(t, idMapper.Verify(vid, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+}
+
+func TestPresentedIntermediateCerts(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	home, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	caCert, caKey := writeIdentityHome(t, home, key)
+
+	cfg := &config.IdentityConfig{ID: "peer0.org1"}
+	require.NoError(t, cfg.MakeFilesAbs(home))
+	selfIdentity, err := util.GetIdentity(cfg)
+	require.NoError(t, err)
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
+	require.NoError(t, err)
+
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	interTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "ica.org2"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * time.Minute),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	interRaw, err := x509.CreateCertificate(rand.Reader, interTemplate, caCert, interKey.Public(), caKey)
+	require.NoError(t, err)
+	interCert, err := x509.ParseCertificate(interRaw)
+	require.NoError(t, err)
+
+	peerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	peerTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "peer0.org2", Organization: []string{"org2"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	peerRaw, err := x509.CreateCertificate(rand.Reader, peerTemplate, interCert, peerKey.Public(), interKey)
+	require.NoError(t, err)
+
+	sid := &protos.SerializedIdentity{
+		NodeId:  "peer0.org2",
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peerRaw}),
+	}
+	peerIdentity, err := proto.Marshal(sid)
+	require.NoError(t, err)
+	pkiID := idMapper.GetPKIidOfCert(peerIdentity)
+	assert.Error(t, idMapper.Put(pkiID, peerIdentity))
+
+	sid.IntermediateCerts = [][]byte{pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: interRaw})}
+	peerIdentity, err = proto.Marshal(sid)
+	require.NoError(t, err)
+	assert.Equal(t, pkiID, idMapper.GetPKIidOfCert(peerIdentity))
+	assert.NoError(t, idMapper.Put(pkiID, peerIdentity))
+
+	// The organization of the identity is the one of its certificate
+	org, err := IdentityOrg(peerIdentity)
+	require.NoError(t, err)
+	assert.Equal(t, "org2", org)
+	is := idMapper.(*identityMapper)
+	sid.Org = "org2"
+	peerIdentity, err = proto.Marshal(sid)
+	require.NoError(t, err)
+	_, err = is.provider.Validate(peerIdentity)
+	assert.NoError(t, err)
+
+	// The X.509 identities aren't replaced once stored
+	stored, err := idMapper.Get(pkiID)
+	require.NoError(t, err)
+	assert.NoError(t, idMapper.Put(pkiID, peerIdentity))
+	assert.Error(t, idMapper.Renew(pkiID, peerIdentity, protos.SignatureAlgorithm_UNSPECIFIED, nil, nil))
+	current, err := idMapper.Get(pkiID)
+	require.NoError(t, err)
+	assert.Equal(t, stored, current)
+
+	sid.Org = "org1"
+	peerIdentity, err = proto.Marshal(sid)
+	require.NoError(t, err)
+	_, err = is.provider.Validate(peerIdentity)
+	assert.EqualError(t, err, `Organization "org1" of the identity doesn't match the organization "org2" of its certificate`)
+
+	expirationDate := chainExpiration([]*x509.Certificate{interCert, caCert})
+	assert.Equal(t, interCert.NotAfter, expirationDate)
+}
+
+func writeIdentityHome(t testing.TB, home string, key crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.org1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caRaw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caRaw)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "peer0.org1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	require.NoError(t, err)
+
+	privRaw, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var pubRaw []byte
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		pubRaw = elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+	case ed25519.PublicKey:
+		pubRaw = pub
+	}
+	ski, err := provider.GetDefault().Hash(pubRaw, hash.SHA3256)
+	require.NoError(t, err)
+
+	files := map[string]*pem.Block{
+		"csp/cacerts/ca.pem":                              {Type: "CERTIFICATE", Bytes: caRaw},
+		"csp/signcerts/cert.pem":                          {Type: "CERTIFICATE", Bytes: raw},
+		"csp/keystore/" + hex.EncodeToString(ski) + "_sk": {Type: "PRIVATE KEY", Bytes: privRaw},
+	}
+	for name, block := range files {
+		path := filepath.Join(home, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	}
+
+	return caCert, caKey
+}
+
+func TestIdentityCache(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	home, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	caCert, caKey := writeIdentityHome(t, home, key)
+
+	cfg := &config.IdentityConfig{ID: "peer0.org1"}
+	require.NoError(t, cfg.MakeFilesAbs(home))
+	selfIdentity, err := util.GetIdentity(cfg)
+	require.NoError(t, err)
+
+	referenced := make(map[string]bool)
+	var purged []common.PKIidType
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(pkiID common.PKIidType) {
+		purged = append(purged, pkiID)
+	}, logging.Default(), WithCache(CacheConfig{
+		TTL: time.Hour,
+		Referenced: func(pkiID common.PKIidType) bool {
+			return referenced[pkiID.String()]
+		},
+	}))
+	require.NoError(t, err)
+	defer idMapper.Stop()
+	is := idMapper.(*identityMapper)
+
+	var pkiIDs []common.PKIidType
+	for i := 1; i <= 3; i++ {
+		peerIdentity := issuePeerIdentity(t, caCert, caKey, fmt.Sprintf("peer%d.org1", i), int64(10+i))
+		pkiID := idMapper.GetPKIidOfCert(peerIdentity)
+		require.NoError(t, idMapper.Put(pkiID, peerIdentity))
+		pkiIDs = append(pkiIDs, pkiID)
+		time.Sleep(time.Millisecond)
+	}
+	referenced[pkiIDs[0].String()] = true
+
+	// The least recently used unreferenced identity is evicted
+	is.cache.MaxSize = 3
+	is.evict()
+	assert.Equal(t, []common.PKIidType{pkiIDs[1]}, purged)
+	stats := idMapper.Stats()
+	assert.Equal(t, 3, stats.Size)
+	assert.Equal(t, uint64(1), stats.Evicted)
+
+	// The unused identities are purged after the TTL, the referenced ones and the own one are kept
+	is.sweep(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, []common.PKIidType{pkiIDs[1], pkiIDs[2]}, purged)
+	_, err = idMapper.Get(pkiIDs[0])
+	assert.NoError(t, err)
+	_, err = idMapper.Get(idMapper.GetPKIidOfCert(selfIdentity))
+	assert.NoError(t, err)
+	stats = idMapper.Stats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(1), stats.Unused)
+
+	// The suspected identities failing revalidation are purged
+	sid := &protos.SerializedIdentity{NodeId: "peer4.org1", IdBytes: selfSignedCertPEM("peer4.org1")}
+	invalid, err := proto.Marshal(sid)
+	require.NoError(t, err)
+	invalidID := idMapper.GetPKIidOfCert(invalid)
+	shard := is.shard(invalidID)
+	shard.Lock()
+	shard.certs[invalidID.String()] = newStoredIdentity(invalidID, invalid, nil)
+	shard.Unlock()
+	atomic.AddInt64(&is.size, 1)
+
+	idMapper.SuspectPeers(func(common.PeerIdentityType) bool { return true })
+	assert.Equal(t, []common.PKIidType{pkiIDs[1], pkiIDs[2], invalidID}, purged)
+	stats = idMapper.Stats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(1), stats.Rejected)
+}
+
+func TestSuspectRevokedPeers(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	home := t.TempDir()
+	caCert, caKey := writeIdentityHome(t, home, key)
+
+	cfg := &config.IdentityConfig{ID: "peer0.org1"}
+	require.NoError(t, cfg.MakeFilesAbs(home))
+	selfIdentity, err := util.GetIdentity(cfg)
+	require.NoError(t, err)
+
+	var purged []common.PKIidType
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(pkiID common.PKIidType) {
+		purged = append(purged, pkiID)
+	}, logging.Default())
+	require.NoError(t, err)
+	defer idMapper.Stop()
+
+	peer1 := issuePeerIdentity(t, caCert, caKey, "peer1.org1", 11)
+	peer2 := issuePeerIdentity(t, caCert, caKey, "peer2.org1", 12)
+	for _, peerIdentity := range []common.PeerIdentityType{peer1, peer2} {
+		require.NoError(t, idMapper.Put(idMapper.GetPKIidOfCert(peerIdentity), peerIdentity))
+	}
+
+	// The CA revokes the certificate of peer1 once the identities are stored
+	crlRaw, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(11), RevocationTime: time.Now()}},
+	}, caCert, caKey)
+	require.NoError(t, err)
+	path := filepath.Join(home, "csp", "crls", "crl.pem")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlRaw}), 0600))
+
+	// Only the suspected identities are revalidated
+	idMapper.SuspectPeers(func(identity common.PeerIdentityType) bool { return false })
+	assert.Empty(t, purged)
+
+	idMapper.SuspectPeers(func(common.PeerIdentityType) bool { return true })
+	assert.Equal(t, []common.PKIidType{idMapper.GetPKIidOfCert(peer1)}, purged)
+	_, err = idMapper.Get(idMapper.GetPKIidOfCert(peer2))
+	assert.NoError(t, err)
+	assert.Error(t, idMapper.Put(idMapper.GetPKIidOfCert(peer1), peer1), "the revoked identity is rejected")
+	assert.Equal(t, uint64(1), idMapper.Stats().Rejected)
+}
+
+// BenchmarkGet measures the lookups of the identities by concurrent message handlers,
+// e.g. go test -bench Get -cpu 1,8,32 ./identity
+func BenchmarkGet(b *testing.B) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(b, err)
+	home, err := ioutil.TempDir("", "identity")
+	require.NoError(b, err)
+	defer os.RemoveAll(home)
+	caCert, caKey := writeIdentityHome(b, home, key)
+
+	cfg := &config.IdentityConfig{ID: "peer0.org1"}
+	require.NoError(b, cfg.MakeFilesAbs(home))
+	selfIdentity, err := util.GetIdentity(cfg)
+	require.NoError(b, err)
+	idMapper, err := NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
+	require.NoError(b, err)
+	defer idMapper.Stop()
+
+	var pkiIDs []common.PKIidType
+	for i := 1; i <= 64; i++ {
+		peerIdentity := issuePeerIdentity(b, caCert, caKey, fmt.Sprintf("peer%d.org1", i), int64(10+i))
+		pkiID := idMapper.GetPKIidOfCert(peerIdentity)
+		require.NoError(b, idMapper.Put(pkiID, peerIdentity))
+		pkiIDs = append(pkiIDs, pkiID)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := idMapper.Get(pkiIDs[i%len(pkiIDs)]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func issuePeerIdentity(t testing.TB, caCert *x509.Certificate, caKey crypto.Signer, name string, serial int64) common.PeerIdentityType {
+	peerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, caCert, peerKey.Public(), caKey)
+	require.NoError(t, err)
+
+	sid := &protos.SerializedIdentity{
+		NodeId:  name,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}),
+	}
+	identity, err := proto.Marshal(sid)
+	require.NoError(t, err)
+	return identity
+}
+
+// selfSignedCertPEM returns a certificate which doesn't chain to the CA of the identity mapper
+func selfSignedCertPEM(name string) []byte {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(99),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, _ := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})
+}
+
+type svidSource struct {
+	sync.Mutex
+	svid   *config.SVID
+	bundle []*x509.Certificate
+}
+
+func (s *svidSource) GetX509SVID() (*config.SVID, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.svid, nil
+}
+
+func (s *svidSource) GetTrustBundle() ([]*x509.Certificate, error) {
+	return s.bundle, nil
+}
+
+func (s *svidSource) rotate(svid *config.SVID) {
+	s.Lock()
+	defer s.Unlock()
+	s.svid = svid
+}
+
+func TestSPIFFE(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.example.org"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caRaw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caRaw)
+	require.NoError(t, err)
+
+	issue := func(id string, serial int64) *config.SVID {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		uri, err := url.Parse(id)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			URIs:         []*url.URL{uri},
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(raw)
+		require.NoError(t, err)
+		return &config.SVID{ID: id, Certificates: []*x509.Certificate{cert}, PrivateKey: key}
+	}
+	newMapper := func(source *svidSource) Identity {
+		cfg := &config.IdentityConfig{
+			ID:     "peer",
+			SPIFFE: &config.SPIFFEConfig{TrustDomain: "example.org", Source: source},
+		}
+		idMapper, err := NewIdentity(cfg, nil, nil, logging.Default())
+		require.NoError(t, err)
+		return idMapper
+	}
+
+	source0 := &svidSource{svid: issue("spiffe://example.org/peer0", 2), bundle: []*x509.Certificate{caCert}}
+	source1 := &svidSource{svid: issue("spiffe://example.org/peer1", 3), bundle: []*x509.Certificate{caCert}}
+	idMapper0 := newMapper(source0)
+	defer idMapper0.Stop()
+	idMapper1 := newMapper(source1)
+	defer idMapper1.Stop()
+
+	identity1 := idMapper1.SelfIdentity()
+	pkiID1 := idMapper0.GetPKIidOfCert(identity1)
+	require.NotNil(t, pkiID1)
+	assert.Equal(t, idMapper1.GetPKIidOfCert(identity1), pkiID1)
+	require.NoError(t, idMapper0.Put(pkiID1, identity1))
+
+	signed, err := idMapper1.Sign([]byte("bla bla"))
+	require.NoError(t, err)
+	assert.NoError(t, idMapper0.Verify(pkiID1, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+
+	// The rotation of the SVID changes the identity but not the PKI-ID
+	source1.rotate(issue("spiffe://example.org/peer1", 4))
+	rotated := idMapper1.SelfIdentity()
+	assert.NotEqual(t, identity1, rotated)
+	assert.Equal(t, pkiID1, idMapper0.GetPKIidOfCert(rotated))
+
+	signed, err = idMapper1.Sign([]byte("bla bla"))
+	require.NoError(t, err)
+	assert.Error(t, idMapper0.Verify(pkiID1, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+
+	// The identity stored first is kept, the rotated one replaces it once it proved to sign
+	require.NoError(t, idMapper0.Put(pkiID1, rotated))
+	stored, err := idMapper0.Get(pkiID1)
+	require.NoError(t, err)
+	assert.Equal(t, identity1, stored)
+	assert.Error(t, idMapper0.Renew(pkiID1, rotated, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("other")))
+	require.NoError(t, idMapper0.Renew(pkiID1, rotated, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+	assert.NoError(t, idMapper0.Verify(pkiID1, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+
+	// SVIDs of foreign trust domains are rejected
+	foreign, err := SerializeSVID("peer2", "", issue("spiffe://other.org/peer2", 5))
+	require.NoError(t, err)
+	assert.Error(t, idMapper0.Put(idMapper0.GetPKIidOfCert(foreign), foreign))
+}
+
+// tokenProvider signs by appending the name of the peer, its identities are "name:expiration"
+type tokenProvider struct {
+	name string
+}
+
+func (p *tokenProvider) Serialize() (common.PeerIdentityType, error) {
+	return common.PeerIdentityType(fmt.Sprintf("%s:%d", p.name, time.Now().Add(time.Hour).Unix())), nil
+}
+
+func (p *tokenProvider) Validate(identity common.PeerIdentityType) (time.Time, error) {
+	parts := strings.Split(string(identity), ":")
+	if len(parts) != 2 {
+		return time.Time{}, errors.New("malformed token")
+	}
+	expiration, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(expiration, 0), nil
+}
+
+func (p *tokenProvider) PKIID(identity common.PeerIdentityType) (common.PKIidType, error) {
+	return common.PKIidType(strings.Split(string(identity), ":")[0]), nil
+}
+
+func (p *tokenProvider) Sign(msg []byte) ([]byte, error) {
+	return append(append([]byte{}, msg...), p.name...), nil
+}
+
+func (p *tokenProvider) Verify(identity common.PeerIdentityType, signature, message []byte) error {
+	name := strings.Split(string(identity), ":")[0]
+	if string(signature) != string(message)+name {
+		return errors.New("The signature is invalid")
+	}
+	return nil
+}
+
+func (p *tokenProvider) SignatureAlgorithm() protos.SignatureAlgorithm {
+	return protos.SignatureAlgorithm_UNSPECIFIED
+}
+
+func TestIdentityProvider(t *testing.T) {
+	cfg := &config.IdentityConfig{ID: "peer0", Provider: &tokenProvider{name: "peer0"}}
+	idMapper, err := NewIdentity(cfg, nil, nil, logging.Default())
+	require.NoError(t, err)
+	defer idMapper.Stop()
+
+	peer1 := &tokenProvider{name: "peer1"}
+	identity1, err := peer1.Serialize()
+	require.NoError(t, err)
+	pkiID1 := idMapper.GetPKIidOfCert(identity1)
+	assert.Equal(t, common.PKIidType("peer1"), pkiID1)
+	require.NoError(t, idMapper.Put(pkiID1, identity1))
+
+	signed, err := peer1.Sign([]byte("bla bla"))
+	require.NoError(t, err)
+	assert.NoError(t, idMapper.Verify(pkiID1, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla")))
+	assert.Error(t, idMapper.Verify(pkiID1, protos.SignatureAlgorithm_UNSPECIFIED, signed, []byte("bla bla bla")))
+
+	signed, err = idMapper.Sign([]byte("bla bla"))
+	require.NoError(t, err)
+	assert.Equal(t, "bla blapeer0", string(signed))
+
+	expired := common.PeerIdentityType(fmt.Sprintf("peer2:%d", time.Now().Add(-time.Minute).Unix()))
+	assert.Error(t, idMapper.Put(common.PKIidType("peer2"), expired))
+}
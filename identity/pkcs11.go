@@ -0,0 +1,114 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/config"
+)
+
+// PKCS11SignerFactory opens the private key referenced by the PKCS#11 configuration
+// and returns a signer bound to it. The public key is taken from the peer's certificate
+// and can be used to locate or double-check the key inside the token.
+// ECDSA and RSA signers receive the SHA3-256 digest of the message and must return
+// an ASN.1 DER encoded signature, Ed25519 signers receive the message itself.
+type PKCS11SignerFactory func(cfg *config.PKCS11Config, pubKey crypto.PublicKey) (crypto.Signer, error)
+
+var (
+	pkcs11Lock    sync.RWMutex
+	pkcs11Factory PKCS11SignerFactory
+)
+
+// RegisterPKCS11SignerFactory sets the factory used to create signers for
+// identities configured with a PKCS#11 module.
+func RegisterPKCS11SignerFactory(factory PKCS11SignerFactory) {
+	pkcs11Lock.Lock()
+	defer pkcs11Lock.Unlock()
+	pkcs11Factory = factory
+}
+
+// ValidatePKCS11Config checks the PKCS#11 configuration of an identity. The PKCS#11 module
+// is opened by the registered signer factory, the configuration is rejected if none is registered.
+func ValidatePKCS11Config(cfg *config.PKCS11Config) error {
+	if cfg.Library == "" {
+		return errors.New("PKCS#11 library must be provided")
+	}
+	if cfg.Label == "" {
+		return errors.New("PKCS#11 key label must be provided")
+	}
+	_, err := getPKCS11Factory()
+	return err
+}
+
+func getPKCS11Factory() (PKCS11SignerFactory, error) {
+	pkcs11Lock.RLock()
+	defer pkcs11Lock.RUnlock()
+	if pkcs11Factory == nil {
+		return nil, errors.New("No PKCS#11 signer factory registered, build with the pkcs11 tag or see identity.RegisterPKCS11SignerFactory")
+	}
+	return pkcs11Factory, nil
+}
+
+func loadPKCS11Signer(cfg *config.PKCS11Config, pubKey crypto.PublicKey) (crypto.Signer, error) {
+	if err := ValidatePKCS11Config(cfg); err != nil {
+		return nil, err
+	}
+	factory, err := getPKCS11Factory()
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := factory(cfg, pubKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed opening key %s in slot %d of PKCS#11 module %s", cfg.Label, cfg.Slot, cfg.Library)
+	}
+	if signer == nil {
+		return nil, errors.New("PKCS#11 signer factory returned a nil signer")
+	}
+
+	if ecdsaPubKey, ok := pubKey.(*ecdsa.PublicKey); ok {
+		return &lowSSigner{Signer: signer, pubKey: ecdsaPubKey}, nil
+	}
+	return signer, nil
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// lowSSigner wraps an ECDSA signer, tokens are free to return either S or N-S
+// whereas the cccsp verifier only accepts signatures in the low-S form.
+type lowSSigner struct {
+	crypto.Signer
+	pubKey *ecdsa.PublicKey
+}
+
+func (s *lowSSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	raw, err := s.Signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &ecdsaSignature{}
+	if _, err := asn1.Unmarshal(raw, sig); err != nil {
+		return nil, errors.Wrap(err, "Failed unmarshalling ECDSA signature")
+	}
+
+	n := s.pubKey.Curve.Params().N
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sig.S.Sub(n, sig.S)
+		return asn1.Marshal(*sig)
+	}
+	return raw, nil
+}
@@ -0,0 +1,207 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/config"
+)
+
+// ckmEDDSA and ckkECEdwards are defined by PKCS#11 v3.0, the bindings only know v2.40
+const (
+	ckmEDDSA     = 0x00001057
+	ckkECEdwards = 0x00000040
+)
+
+// sha3256DigestInfo is the DER encoded DigestInfo prefix of a SHA3-256 digest, see RFC 8017 section 9.2
+var sha3256DigestInfo = []byte{0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x08, 0x05, 0x00, 0x04, 0x20}
+
+func init() {
+	RegisterPKCS11SignerFactory(NewPKCS11Signer)
+}
+
+type slotKey struct {
+	library string
+	slot    uint
+}
+
+// slotSession is a logged in session of a token, PKCS#11 sessions
+// can't be used concurrently so the signers of a slot share its lock.
+type slotSession struct {
+	lock    sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+var (
+	modulesLock sync.Mutex
+	modules     = make(map[string]*pkcs11.Ctx)
+	sessions    = make(map[slotKey]*slotSession)
+)
+
+// NewPKCS11Signer is the PKCS#11 signer factory registered when building with the pkcs11 tag.
+// It loads the module, logs in the slot with the user PIN and looks up the private key by
+// its label. The module and the session of a slot are kept open for the life of the process
+// and shared by the signers, e.g. the ones created when the identity is reloaded.
+func NewPKCS11Signer(cfg *config.PKCS11Config, pubKey crypto.PublicKey) (crypto.Signer, error) {
+	var keyType uint
+	switch pubKey.(type) {
+	case ed25519.PublicKey:
+		keyType = ckkECEdwards
+	case *ecdsa.PublicKey:
+		keyType = pkcs11.CKK_EC
+	case *rsa.PublicKey:
+		keyType = pkcs11.CKK_RSA
+	default:
+		return nil, errors.Errorf("Unsupported public key type %T", pubKey)
+	}
+
+	s, err := openSlotSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.Label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return nil, errors.Wrap(err, "Failed looking up the private key")
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	if finalErr := s.ctx.FindObjectsFinal(s.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed looking up the private key")
+	}
+	if len(objects) != 1 {
+		return nil, errors.Errorf("Expected one private key labeled %s of the certificate's key type, found %d", cfg.Label, len(objects))
+	}
+
+	return &pkcs11Signer{slot: s, key: objects[0], pubKey: pubKey}, nil
+}
+
+func openSlotSession(cfg *config.PKCS11Config) (*slotSession, error) {
+	modulesLock.Lock()
+	defer modulesLock.Unlock()
+
+	k := slotKey{library: cfg.Library, slot: cfg.Slot}
+	if s, ok := sessions[k]; ok {
+		return s, nil
+	}
+
+	ctx, ok := modules[cfg.Library]
+	if !ok {
+		ctx = pkcs11.New(cfg.Library)
+		if ctx == nil {
+			return nil, errors.Errorf("Failed loading PKCS#11 module %s", cfg.Library)
+		}
+		if err := ctx.Initialize(); err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+			ctx.Destroy()
+			return nil, errors.Wrap(err, "Failed initializing the PKCS#11 module")
+		}
+		modules[cfg.Library] = ctx
+	}
+
+	session, err := ctx.OpenSession(cfg.Slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed opening a session")
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.Pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		ctx.CloseSession(session)
+		return nil, errors.Wrap(err, "Failed logging in the token")
+	}
+
+	s := &slotSession{ctx: ctx, session: session}
+	sessions[k] = s
+	return s, nil
+}
+
+// pkcs11Signer signs with a private key held by a PKCS#11 token
+type pkcs11Signer struct {
+	slot   *slotSession
+	key    pkcs11.ObjectHandle
+	pubKey crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pubKey
+}
+
+// Sign signs the message with EdDSA, the SHA3-256 digest with ECDSA or
+// with RSASSA-PKCS1-v1_5, see PKCS11SignerFactory.
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var hashFunc crypto.Hash
+	if opts != nil {
+		hashFunc = opts.HashFunc()
+	}
+
+	switch pubKey := s.pubKey.(type) {
+	case ed25519.PublicKey:
+		if hashFunc != 0 {
+			return nil, errors.New("Ed25519 signs the message itself, a hash function can't be used")
+		}
+		return s.sign(ckmEDDSA, digest)
+
+	case *ecdsa.PublicKey:
+		raw, err := s.sign(pkcs11.CKM_ECDSA, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		if len(raw) != 2*size {
+			return nil, errors.Errorf("Invalid ECDSA signature length %d", len(raw))
+		}
+		return asn1.Marshal(ecdsaSignature{
+			R: new(big.Int).SetBytes(raw[:size]),
+			S: new(big.Int).SetBytes(raw[size:]),
+		})
+
+	case *rsa.PublicKey:
+		if hashFunc != 0 && hashFunc != crypto.SHA3_256 {
+			return nil, errors.Errorf("Unsupported hash function %s", hashFunc)
+		}
+		if len(digest) != crypto.SHA3_256.Size() {
+			return nil, errors.Errorf("Invalid SHA3-256 digest length %d", len(digest))
+		}
+		return s.sign(pkcs11.CKM_RSA_PKCS, append(append([]byte{}, sha3256DigestInfo...), digest...))
+	}
+
+	return nil, errors.Errorf("Unsupported public key type %T", s.pubKey)
+}
+
+func (s *pkcs11Signer) sign(mechanism uint, data []byte) ([]byte, error) {
+	s.slot.lock.Lock()
+	defer s.slot.lock.Unlock()
+
+	if err := s.slot.ctx.SignInit(s.slot.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, s.key); err != nil {
+		return nil, errors.Wrap(err, "Failed initializing the signature")
+	}
+	sig, err := s.slot.ctx.Sign(s.slot.session, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed signing")
+	}
+	return sig, nil
+}
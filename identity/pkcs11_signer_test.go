@@ -0,0 +1,38 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCS11SignerFactory(t *testing.T) {
+	factory, err := getPKCS11Factory()
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	cfg := &config.PKCS11Config{Library: filepath.Join(t.TempDir(), "libmissing.so"), Slot: 1, Pin: "98765432", Label: "peer0"}
+	assert.NoError(t, ValidatePKCS11Config(cfg))
+	_, err = factory(cfg, &key.PublicKey)
+	assert.EqualError(t, err, "Failed loading PKCS#11 module "+cfg.Library)
+
+	_, err = factory(cfg, "not a key")
+	assert.EqualError(t, err, "Unsupported public key type string")
+}
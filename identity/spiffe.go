@@ -0,0 +1,252 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/cccsp"
+	"github.com/rkcloudchain/cccsp/hash"
+	"github.com/rkcloudchain/cccsp/provider"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// spiffeProvider handles the X.509 SVIDs of a SPIFFE trust domain,
+// the PKI-ID being derived from the SPIFFE ID it survives the rotations
+type spiffeProvider struct {
+	conf   *config.SPIFFEConfig
+	nodeID string
+	org    string
+	csp    cccsp.CCCSP
+	svid   atomic.Value // *svidState
+	lock   sync.Mutex
+	logger logging.Logger
+}
+
+// svidState is the current X.509 SVID of the peer
+type svidState struct {
+	leaf      []byte
+	identity  common.PeerIdentityType
+	signer    crypto.Signer
+	algorithm protos.SignatureAlgorithm
+}
+
+// SPIFFEID returns the SPIFFE ID held by the URI SAN of the certificate
+func SPIFFEID(cert *x509.Certificate) (*url.URL, error) {
+	var id *url.URL
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		if id != nil {
+			return nil, errors.New("The certificate has more than one SPIFFE ID")
+		}
+		id = uri
+	}
+	if id == nil {
+		return nil, errors.New("The certificate has no SPIFFE ID")
+	}
+	if id.Host == "" || id.User != nil || id.Port() != "" || id.RawQuery != "" || id.Fragment != "" {
+		return nil, errors.Errorf("Invalid SPIFFE ID %s", id)
+	}
+	return id, nil
+}
+
+// SerializeSVID returns the identity of the peer holding the X.509 SVID
+func SerializeSVID(nodeID, org string, svid *config.SVID) (common.PeerIdentityType, error) {
+	if svid == nil || len(svid.Certificates) == 0 {
+		return nil, errors.New("The X.509 SVID has no certificate")
+	}
+
+	sid := &protos.SerializedIdentity{
+		NodeId:  nodeID,
+		Org:     org,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svid.Certificates[0].Raw}),
+	}
+	for _, cert := range svid.Certificates[1:] {
+		sid.IntermediateCerts = append(sid.IntermediateCerts, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+
+	identity, err := proto.Marshal(sid)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal a SerializedIdentity")
+	}
+	return identity, nil
+}
+
+func newSPIFFEProvider(cfg *config.IdentityConfig, logger logging.Logger) (*spiffeProvider, error) {
+	if cfg.SPIFFE.TrustDomain == "" {
+		return nil, errors.New("Must specify the SPIFFE trust domain")
+	}
+	if cfg.SPIFFE.Source == nil {
+		return nil, errors.New("Must specify the source of the X.509 SVIDs")
+	}
+
+	p := &spiffeProvider{
+		conf:   cfg.SPIFFE,
+		nodeID: cfg.ID,
+		org:    cfg.Org,
+		csp:    provider.New(provider.NewMemoryKeyStore()),
+		logger: logger,
+	}
+	if _, err := p.refresh(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Serialize returns the identity of the current X.509 SVID, rotated by the source
+func (p *spiffeProvider) Serialize() (common.PeerIdentityType, error) {
+	svid, err := p.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return svid.identity, nil
+}
+
+// Validate validates the X.509 SVID against the trust bundle of the trust domain,
+// and returns the earliest expiration date of the certificates of the chain
+func (p *spiffeProvider) Validate(identity common.PeerIdentityType) (time.Time, error) {
+	cert, presented, err := parseIdentity(identity)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if cert.IsCA {
+		return time.Time{}, errors.New("The X.509 SVID must not be a CA certificate")
+	}
+	id, err := SPIFFEID(cert)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if id.Host != strings.ToLower(p.conf.TrustDomain) {
+		return time.Time{}, errors.Errorf("SPIFFE ID %s doesn't belong to the trust domain %s", id, p.conf.TrustDomain)
+	}
+
+	bundle, err := p.conf.Source.GetTrustBundle()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Failed fetching the trust bundle")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, root := range bundle {
+		opts.Roots.AddCert(root)
+	}
+	addPresentedIntermediates(opts.Intermediates, presented, p.logger)
+
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		return time.Time{}, errors.Errorf("could not validate SPIFFE ID %s against the trust bundle", id)
+	}
+	if _, err := IdentityOrg(identity); err != nil {
+		return time.Time{}, err
+	}
+	return chainExpiration(chains[0]), nil
+}
+
+// PKIID derives the PKI-ID from the SPIFFE ID of the certificate
+func (p *spiffeProvider) PKIID(identity common.PeerIdentityType) (common.PKIidType, error) {
+	cert, _, err := parseIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	id, err := SPIFFEID(cert)
+	if err != nil {
+		return nil, err
+	}
+	return p.csp.Hash([]byte(id.String()), hash.SHA3256)
+}
+
+func (p *spiffeProvider) Sign(msg []byte) ([]byte, error) {
+	svid := p.current()
+	return signWith(p.csp, svid.signer, svid.algorithm, msg)
+}
+
+func (p *spiffeProvider) Verify(identity common.PeerIdentityType, signature, message []byte) error {
+	return verifyCertSignature(p.csp, identity, signature, message)
+}
+
+func (p *spiffeProvider) SignatureAlgorithm() protos.SignatureAlgorithm {
+	return p.current().algorithm
+}
+
+func (p *spiffeProvider) SignatureAlgorithmOf(identity common.PeerIdentityType) (protos.SignatureAlgorithm, error) {
+	return certSignatureAlgorithm(identity)
+}
+
+func (p *spiffeProvider) current() *svidState {
+	return p.svid.Load().(*svidState)
+}
+
+// refresh fetches the current X.509 SVID from the source,
+// the identity and the signer are replaced if it was rotated
+func (p *spiffeProvider) refresh() (*svidState, error) {
+	svid, err := p.conf.Source.GetX509SVID()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed fetching the X.509 SVID")
+	}
+	if svid == nil || len(svid.Certificates) == 0 || svid.PrivateKey == nil {
+		return nil, errors.New("The X.509 SVID has no certificate or private key")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	current, _ := p.svid.Load().(*svidState)
+	if current != nil && bytes.Equal(current.leaf, svid.Certificates[0].Raw) {
+		return current, nil
+	}
+
+	identity, err := SerializeSVID(p.nodeID, p.org, svid)
+	if err != nil {
+		return nil, err
+	}
+	signer, algorithm, err := svidSigner(svid)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &svidState{
+		leaf:      svid.Certificates[0].Raw,
+		identity:  identity,
+		signer:    signer,
+		algorithm: algorithm,
+	}
+	if current != nil {
+		p.logger.Infof("X.509 SVID %s was rotated", svid.ID)
+	}
+	p.svid.Store(state)
+	return state, nil
+}
+
+func svidSigner(svid *config.SVID) (crypto.Signer, protos.SignatureAlgorithm, error) {
+	switch pubKey := svid.Certificates[0].PublicKey.(type) {
+	case ed25519.PublicKey:
+		return svid.PrivateKey, protos.SignatureAlgorithm_ED25519, nil
+	case *ecdsa.PublicKey:
+		return &lowSSigner{Signer: svid.PrivateKey, pubKey: pubKey}, protos.SignatureAlgorithm_ECDSA_SHA3_256, nil
+	default:
+		return nil, 0, errors.Errorf("Unsupported public key type %T of the X.509 SVID", pubKey)
+	}
+}
@@ -0,0 +1,532 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/cccsp"
+	"github.com/rkcloudchain/cccsp/hash"
+	"github.com/rkcloudchain/cccsp/importer"
+	"github.com/rkcloudchain/cccsp/provider"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/protobuf/proto"
+)
+
+// x509Provider handles the X.509 certificates issued by the CAs of the home directory
+type x509Provider struct {
+	sync.RWMutex      // Guards the trust material, reloaded when peers are suspected
+	conf              *config.IdentityConfig
+	selfIdentity      common.PeerIdentityType
+	opts              *x509.VerifyOptions
+	rootCerts         []*x509.Certificate
+	intermediateCerts []*x509.Certificate
+	crls              []*x509.RevocationList
+	csp               cccsp.CCCSP
+	signer            crypto.Signer
+	algorithm         protos.SignatureAlgorithm
+	logger            logging.Logger
+}
+
+func newX509Provider(cfg *config.IdentityConfig, selfIdentity common.PeerIdentityType, logger logging.Logger) (*x509Provider, error) {
+	fks, err := provider.NewFileKeyStore(cfg.GetKeyStoreDir())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &x509Provider{
+		conf:         cfg,
+		selfIdentity: selfIdentity,
+		csp:          provider.New(fks),
+		logger:       logger,
+	}
+	if err := p.setupCAs(cfg); err != nil {
+		return nil, err
+	}
+	if err := p.setupCRLs(cfg); err != nil {
+		return nil, err
+	}
+	if err := p.setupCSP(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *x509Provider) Serialize() (common.PeerIdentityType, error) {
+	return p.selfIdentity, nil
+}
+
+func (p *x509Provider) PKIID(identity common.PeerIdentityType) (common.PKIidType, error) {
+	sid := &protos.SerializedIdentity{}
+	err := proto.Unmarshal(identity, sid)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+	}
+
+	nodeIDRaw := []byte(sid.NodeId)
+	raw := append(nodeIDRaw, sid.IdBytes...)
+
+	digest, err := p.csp.Hash(raw, hash.SHA3256)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed computing digest of serialized identity")
+	}
+	return digest, nil
+}
+
+func (p *x509Provider) Sign(msg []byte) ([]byte, error) {
+	return signWith(p.csp, p.signer, p.algorithm, msg)
+}
+
+func (p *x509Provider) Verify(identity common.PeerIdentityType, signature, message []byte) error {
+	return verifyCertSignature(p.csp, identity, signature, message)
+}
+
+func (p *x509Provider) SignatureAlgorithm() protos.SignatureAlgorithm {
+	return p.algorithm
+}
+
+func (p *x509Provider) SignatureAlgorithmOf(identity common.PeerIdentityType) (protos.SignatureAlgorithm, error) {
+	return certSignatureAlgorithm(identity)
+}
+
+// Validate validates the identity against the certification chain and the CRLs,
+// and returns the earliest expiration date of the certificates of the chain
+func (p *x509Provider) Validate(identity common.PeerIdentityType) (time.Time, error) {
+	cert, presented, err := parseIdentity(identity)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	p.RLock()
+	opts := p.verifyOptions(presented)
+	crls := p.crls
+	p.RUnlock()
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		return time.Time{}, errors.New("could not validate identity against certification chain")
+	}
+	if err := checkRevoked(chains[0], crls); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := IdentityOrg(identity); err != nil {
+		return time.Time{}, err
+	}
+
+	return chainExpiration(chains[0]), nil
+}
+
+// Reload reads the CA certificates and the CRLs of the home directory again,
+// the identities are then validated against them
+func (p *x509Provider) Reload() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.conf.ReloadTrustMaterial(); err != nil {
+		return err
+	}
+	reloaded := &x509Provider{csp: p.csp, logger: p.logger}
+	if err := reloaded.setupCAs(p.conf); err != nil {
+		return err
+	}
+	if err := reloaded.setupCRLs(p.conf); err != nil {
+		return err
+	}
+	p.opts = reloaded.opts
+	p.rootCerts = reloaded.rootCerts
+	p.intermediateCerts = reloaded.intermediateCerts
+	p.crls = reloaded.crls
+	return nil
+}
+
+func (p *x509Provider) setupCAs(conf *config.IdentityConfig) error {
+	cacerts := conf.GetRootCAs()
+	intermediatecerts := conf.GetIntermediateCAs()
+
+	p.opts = &x509.VerifyOptions{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool()}
+	rootCAs := make([]*x509.Certificate, len(cacerts))
+	intermediateCAs := make([]*x509.Certificate, len(intermediatecerts))
+	for i, v := range cacerts {
+		cert, err := util.GetX509CertificateFromPEM(v)
+		if err != nil {
+			return err
+		}
+		rootCAs[i] = cert
+		p.opts.Roots.AddCert(cert)
+	}
+	for i, v := range intermediatecerts {
+		cert, err := util.GetX509CertificateFromPEM(v)
+		if err != nil {
+			return err
+		}
+		intermediateCAs[i] = cert
+		p.opts.Intermediates.AddCert(cert)
+	}
+
+	p.rootCerts = make([]*x509.Certificate, len(cacerts))
+	for i, trustedCert := range rootCAs {
+		cert, err := p.sanitizeCert(trustedCert)
+		if err != nil {
+			return err
+		}
+		p.rootCerts[i] = cert
+	}
+	p.intermediateCerts = make([]*x509.Certificate, len(intermediatecerts))
+	for i, trustedCert := range intermediateCAs {
+		cert, err := p.sanitizeCert(trustedCert)
+		if err != nil {
+			return err
+		}
+		p.intermediateCerts[i] = cert
+	}
+
+	p.opts = &x509.VerifyOptions{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool()}
+	for _, cert := range p.rootCerts {
+		p.opts.Roots.AddCert(cert)
+	}
+	for _, cert := range p.intermediateCerts {
+		p.opts.Intermediates.AddCert(cert)
+	}
+
+	return nil
+}
+
+func (p *x509Provider) setupCRLs(conf *config.IdentityConfig) error {
+	p.crls = nil
+	for _, v := range conf.GetCRLs() {
+		block, _ := pem.Decode(v)
+		if block == nil {
+			return errors.New("Failed decoding the PEM of a CRL")
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return errors.Wrap(err, "Failed parsing CRL")
+		}
+		p.crls = append(p.crls, crl)
+	}
+	return nil
+}
+
+// checkRevoked returns an error if a certificate of the chain is revoked by a CRL of its issuer
+func checkRevoked(chain []*x509.Certificate, crls []*x509.RevocationList) error {
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, crl := range crls {
+			if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) || crl.CheckSignatureFrom(issuer) != nil {
+				continue
+			}
+			for _, revoked := range crl.RevokedCertificateEntries {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return errors.Errorf("certificate %s was revoked", cert.Subject)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verifyOptions returns the options used to validate a peer certificate,
+// the intermediate certificates presented by the peer are added to the
+// configured ones. They are not trusted by themselves, a valid chain still
+// has to end at one of the configured root certificates.
+func (p *x509Provider) verifyOptions(presented [][]byte) x509.VerifyOptions {
+	opts := *p.opts
+	if len(presented) == 0 {
+		return opts
+	}
+
+	opts.Intermediates = x509.NewCertPool()
+	for _, cert := range p.intermediateCerts {
+		opts.Intermediates.AddCert(cert)
+	}
+	addPresentedIntermediates(opts.Intermediates, presented, p.logger)
+	return opts
+}
+
+// addPresentedIntermediates adds the CA certificates presented by a peer to the pool
+func addPresentedIntermediates(pool *x509.CertPool, presented [][]byte, logger logging.Logger) {
+	for _, raw := range presented {
+		cert, err := util.GetX509CertificateFromPEM(raw)
+		if err != nil {
+			logger.Warningf("Failed parsing presented intermediate certificate: %s", err)
+			continue
+		}
+		if !cert.IsCA {
+			logger.Warningf("Presented intermediate certificate %s is not a CA certificate", cert.Subject)
+			continue
+		}
+		pool.AddCert(cert)
+	}
+}
+
+// chainExpiration returns the earliest expiration date of the certificates in the chain
+func chainExpiration(chain []*x509.Certificate) time.Time {
+	var expiration time.Time
+	for _, cert := range chain {
+		if cert.NotAfter.IsZero() {
+			continue
+		}
+		if expiration.IsZero() || cert.NotAfter.Before(expiration) {
+			expiration = cert.NotAfter
+		}
+	}
+	return expiration
+}
+
+func (p *x509Provider) setupCSP(conf *config.IdentityConfig) error {
+	cert, err := util.GetX509CertificateFromPEM(conf.GetCertificate())
+	if err != nil {
+		return err
+	}
+
+	cert, err = p.sanitizeCert(cert)
+	if err != nil {
+		return err
+	}
+
+	if conf.PKCS11 != nil {
+		return p.setupPKCS11Signer(conf.PKCS11, cert.PublicKey)
+	}
+
+	switch pubKey := cert.PublicKey.(type) {
+	case ed25519.PublicKey:
+		signer, err := loadEd25519Signer(conf.GetKeyStoreDir(), pubKey)
+		if err != nil {
+			return err
+		}
+		p.signer = signer
+		p.algorithm = protos.SignatureAlgorithm_ED25519
+		return nil
+	case *ecdsa.PublicKey:
+		p.algorithm = protos.SignatureAlgorithm_ECDSA_SHA3_256
+	case *rsa.PublicKey:
+		p.algorithm = protos.SignatureAlgorithm_RSA_SHA3_256
+	default:
+		return errors.Errorf("Unsupported public key type %T", cert.PublicKey)
+	}
+
+	certPubK, err := p.csp.KeyImport(cert, importer.X509CERT, true)
+	if err != nil {
+		return errors.Wrap(err, "Failed to import certificate's public key")
+	}
+
+	id := certPubK.Identifier()
+	privateKey, err := p.csp.GetKey(id)
+	if err != nil {
+		id = certPubK.SKI()
+		privateKey, err = p.csp.GetKey(id)
+		if err != nil {
+			return errors.Wrap(err, "Could not find matching private key for SKI")
+		}
+	}
+	if !privateKey.Private() {
+		return errors.Errorf("The private key associated with the certificate with SKI '%s' was not found", hex.EncodeToString(id))
+	}
+
+	signer, err := provider.NewSigner(p.csp, privateKey)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create signer from cccsp")
+	}
+	p.signer = signer
+
+	return nil
+}
+
+func (p *x509Provider) setupPKCS11Signer(conf *config.PKCS11Config, pubKey crypto.PublicKey) error {
+	algorithm, err := keySignatureAlgorithm(pubKey)
+	if err != nil {
+		return err
+	}
+	p.algorithm = algorithm
+
+	signer, err := loadPKCS11Signer(conf, pubKey)
+	if err != nil {
+		return err
+	}
+	p.signer = signer
+	return nil
+}
+
+// sanitizeCert ensures that x509 certificates signed using ECDSA
+// do have signatures in Low-S. If this is not the case, the certificate
+// is regenerated to have a Low-S signature.
+func (p *x509Provider) sanitizeCert(cert *x509.Certificate) (*x509.Certificate, error) {
+	if isECDSASignedCert(cert) {
+		var parentCert *x509.Certificate
+		chain, err := p.getUniqueValidationChain(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		if cert.IsCA && len(chain) == 1 {
+			parentCert = cert
+		} else if len(chain) > 1 {
+			parentCert = chain[1]
+		} else {
+			return nil, errors.New("Invalid x.509 certificate")
+		}
+
+		cert, err = sanitizeECDSASignedCert(cert, parentCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
+
+func (p *x509Provider) getUniqueValidationChain(cert *x509.Certificate) ([]*x509.Certificate, error) {
+	if p.opts == nil {
+		return nil, errors.New("The supplied identity has no verify options")
+	}
+	validationChains, err := cert.Verify(*p.opts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "The supplied identity is not valid")
+	}
+
+	if len(validationChains) != 1 {
+		return nil, errors.Errorf("This Identity only supports a single validation chain, got %d", len(validationChains))
+	}
+
+	return validationChains[0], nil
+}
+
+// parseIdentity returns the certificate of the identity and the intermediate certificates it presents
+func parseIdentity(identity common.PeerIdentityType) (*x509.Certificate, [][]byte, error) {
+	sid := &protos.SerializedIdentity{}
+	if err := proto.Unmarshal(identity, sid); err != nil {
+		return nil, nil, errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+	}
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, sid.IntermediateCerts, nil
+}
+
+// CertificateOrg returns the organization of the peer holding the certificate,
+// the first organization of its subject
+func CertificateOrg(cert *x509.Certificate) string {
+	if len(cert.Subject.Organization) == 0 {
+		return ""
+	}
+	return cert.Subject.Organization[0]
+}
+
+// IdentityOrg returns the organization of the peer holding the identity, it derives from its certificate.
+// The organization the identity declares must be the one of its certificate if set.
+func IdentityOrg(identity common.PeerIdentityType) (string, error) {
+	sid := &protos.SerializedIdentity{}
+	if err := proto.Unmarshal(identity, sid); err != nil {
+		return "", errors.Wrap(err, "could not unmarshalling a SerializedIdentity")
+	}
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		return "", err
+	}
+	org := CertificateOrg(cert)
+	if sid.Org != "" && sid.Org != org {
+		return "", errors.Errorf("Organization %q of the identity doesn't match the organization %q of its certificate", sid.Org, org)
+	}
+	return org, nil
+}
+
+// certSignatureAlgorithm returns the signature algorithm of the key of the certificate of the identity
+func certSignatureAlgorithm(identity common.PeerIdentityType) (protos.SignatureAlgorithm, error) {
+	cert, _, err := parseIdentity(identity)
+	if err != nil {
+		return protos.SignatureAlgorithm_UNSPECIFIED, err
+	}
+	return keySignatureAlgorithm(cert.PublicKey)
+}
+
+// keySignatureAlgorithm returns the algorithm of the signatures made with the key
+func keySignatureAlgorithm(pubKey crypto.PublicKey) (protos.SignatureAlgorithm, error) {
+	switch pubKey.(type) {
+	case ed25519.PublicKey:
+		return protos.SignatureAlgorithm_ED25519, nil
+	case *ecdsa.PublicKey:
+		return protos.SignatureAlgorithm_ECDSA_SHA3_256, nil
+	case *rsa.PublicKey:
+		return protos.SignatureAlgorithm_RSA_SHA3_256, nil
+	default:
+		return protos.SignatureAlgorithm_UNSPECIFIED, errors.Errorf("Unsupported public key type %T", pubKey)
+	}
+}
+
+// signWith signs the message with the signer of a certificate
+func signWith(csp cccsp.CCCSP, signer crypto.Signer, algorithm protos.SignatureAlgorithm, msg []byte) ([]byte, error) {
+	if signer == nil {
+		return nil, errors.New("The signer must not be nil")
+	}
+
+	if algorithm == protos.SignatureAlgorithm_ED25519 {
+		// Ed25519 signs the message itself, hashing is part of the scheme
+		return signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	}
+
+	digest, err := csp.Hash(msg, hash.SHA3256)
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Sign(rand.Reader, digest, nil)
+}
+
+// verifyCertSignature checks the signature against the public key of the certificate of the identity
+func verifyCertSignature(csp cccsp.CCCSP, identity common.PeerIdentityType, signature, message []byte) error {
+	var sid protos.SerializedIdentity
+	err := proto.Unmarshal(identity, &sid)
+	if err != nil {
+		return err
+	}
+
+	cert, err := util.GetX509CertificateFromPEM(sid.IdBytes)
+	if err != nil {
+		return err
+	}
+
+	if pubKey, ok := cert.PublicKey.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(pubKey, message, signature) {
+			return errors.New("The signature is invalid")
+		}
+		return nil
+	}
+
+	k, err := csp.KeyImport(cert, importer.X509CERT, true)
+	if err != nil {
+		return err
+	}
+
+	digest, err := csp.Hash(message, hash.SHA3256)
+	if err != nil {
+		return err
+	}
+
+	valid, err := csp.Verify(k, signature, digest, nil)
+	if err != nil {
+		return errors.Wrap(err, "Could not determine the validity of the signature")
+	}
+	if !valid {
+		return errors.New("The signature is invalid")
+	}
+	return nil
+}
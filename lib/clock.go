@@ -0,0 +1,120 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it to pass. The components depending on the time
+// use it rather than the time package, so that tests can fast-forward it with a FakeClock.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the Clock of the system
+var SystemClock Clock = systemClock{}
+
+// ClockOrSystem returns the clock, or SystemClock if it is nil
+func ClockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+// FakeClock is a Clock whose time only passes when it is advanced. The goroutines
+// waiting on it are woken up once the time they wait for is reached.
+type FakeClock struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock creates a FakeClock set to the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.lock)
+	return c
+}
+
+// Now returns the time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Since returns the time elapsed since t according to the clock
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// After returns a channel receiving the time of the clock once it is advanced by d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, &fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Sleep blocks until the clock is advanced by d
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the time of the clock forward, waking up the goroutines whose wait is over
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// BlockUntil blocks until at least n goroutines wait on the clock, so that a test knows
+// the components are idle before advancing it. The channels returned by After which
+// are no longer received from are counted too.
+func (c *FakeClock) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockOrSystem(t *testing.T) {
+	assert.Equal(t, SystemClock, ClockOrSystem(nil))
+	clock := NewFakeClock(time.Now())
+	assert.Equal(t, clock, ClockOrSystem(clock))
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	short, long := clock.After(time.Second), clock.After(time.Minute)
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("A wait of 0 should be over immediately")
+	}
+
+	clock.Advance(time.Second)
+	assert.Equal(t, time.Second, clock.Since(start))
+	assert.Equal(t, start.Add(time.Second), <-short)
+	select {
+	case <-long:
+		t.Fatal("The wait shouldn't be over yet")
+	default:
+	}
+
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Second)
+		close(slept)
+	}()
+	// the sleeping goroutine and the long wait
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	<-slept
+	assert.Equal(t, start.Add(time.Minute+time.Second), <-long)
+}
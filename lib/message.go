@@ -28,6 +28,10 @@ type MessageStore interface {
 	Get() []interface{}
 	Stop()
 	Purge(func(interface{}) bool)
+	// OnRemoval registers a callback notified of the messages leaving the store, along with the reason.
+	// It runs on the given number of worker goroutines, so that a slow callback doesn't stall the store,
+	// hence the removals may be notified out of order. The removals are notified until the store is stopped.
+	OnRemoval(callback RemovalCallback, workers int)
 }
 
 // NewMessageStore returns a new MessageStore with the message replacing
@@ -36,12 +40,13 @@ func NewMessageStore(pol common.MessageReplcaingPolicy, trigger invalidationTrig
 }
 
 // NewMessageStoreExpirable returns a new MessageStore with the message replacing
-// It supports old message expiration after msgTTL
+// It supports old message expiration after msgTTL, measured by the clock (the system clock if nil)
 func NewMessageStoreExpirable(pol common.MessageReplcaingPolicy, trigger invalidationTrigger,
-	msgTTL time.Duration, externalLock func(), externalUnlock func(), externalExpire func(interface{})) MessageStore {
+	msgTTL time.Duration, clock Clock, externalLock func(), externalUnlock func(), externalExpire func(interface{})) MessageStore {
 
 	store := newMsgStore(pol, trigger)
 	store.msgTTL = msgTTL
+	store.clock = ClockOrSystem(clock)
 
 	if externalLock != nil {
 		store.externalLock = externalLock
@@ -57,6 +62,32 @@ func NewMessageStoreExpirable(pol common.MessageReplcaingPolicy, trigger invalid
 	return store
 }
 
+// MessageStoreBounds limits the messages a MessageStore holds in memory,
+// the least recently used messages are evicted first
+type MessageStoreBounds struct {
+	MaxMessages int                          // Max number of messages, 0 for no limit
+	MaxBytes    int                          // Max total size in bytes of the messages, 0 for no limit
+	SizeOf      func(msg interface{}) int    // Returns the size in bytes of a message, required by MaxBytes
+	Spill       MessageSpill                 // Stores the evicted messages, which are dropped if nil
+	KeyOf       func(msg interface{}) string // Returns the key of a message in the Spill, required by Spill
+}
+
+// NewMessageStoreBounded returns a new MessageStore with the message replacing, holding in memory
+// the messages within the given bounds. Messages expire after msgTTL measured by the clock, unless it's zero.
+// The evicted messages are kept in the Spill of the bounds if any, a spilled message is loaded back
+// when a message with the same key is added or checked, so messages can only invalidate the spilled
+// messages with the same key. Purge doesn't apply to the spilled messages.
+func NewMessageStoreBounded(pol common.MessageReplcaingPolicy, trigger invalidationTrigger, msgTTL time.Duration, clock Clock, bounds MessageStoreBounds) MessageStore {
+	store := newMsgStore(pol, trigger)
+	store.bounds = bounds
+	store.clock = ClockOrSystem(clock)
+	if msgTTL > 0 {
+		store.msgTTL = msgTTL
+		go store.expirationRoutine()
+	}
+	return store
+}
+
 func newMsgStore(pol common.MessageReplcaingPolicy, trigger invalidationTrigger) *messageStoreImpl {
 	return &messageStoreImpl{
 		pol:               pol,
@@ -67,6 +98,7 @@ func newMsgStore(pol common.MessageReplcaingPolicy, trigger invalidationTrigger)
 		expireMsgCallback: func(m interface{}) {},
 		expiredCount:      0,
 		doneCh:            make(chan struct{}),
+		clock:             SystemClock,
 	}
 }
 
@@ -76,42 +108,135 @@ type messageStoreImpl struct {
 	messages          []*msg
 	invTrigger        invalidationTrigger
 	msgTTL            time.Duration
+	clock             Clock
 	expiredCount      int
 	externalLock      func()
 	externalUnlock    func()
 	expireMsgCallback func(msg interface{})
 	doneCh            chan struct{}
 	stopOnce          sync.Once
+	bounds            MessageStoreBounds
+	bytes             int // Total size of the messages in memory, only computed with MaxBytes
+	removalPools      []*removalPool
 }
 
 type msg struct {
 	data    interface{}
 	created time.Time
 	expired bool
+	size    int
 }
 
 func (s *messageStoreImpl) Add(message interface{}) bool {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	s.unspill(message)
 	n := len(s.messages)
 	for i := 0; i < n; i++ {
 		m := s.messages[i]
 		switch s.pol(message, m.data) {
 		case common.MessageInvalidated:
+			s.touch(i)
 			return false
 		case common.MessageInvalidates:
 			s.invTrigger(m.data)
-			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			s.notifyRemoval(m, RemovalReplaced)
+			s.remove(i)
 			n--
 			i--
 		}
 	}
 
-	s.messages = append(s.messages, &msg{data: message, created: time.Now()})
+	s.messages = append(s.messages, &msg{data: message, created: s.clock.Now(), size: s.sizeOf(message)})
+	s.bytes += s.messages[len(s.messages)-1].size
+	s.evict()
 	return true
 }
 
+// remove removes the i-th message from memory
+func (s *messageStoreImpl) remove(i int) {
+	m := s.messages[i]
+	s.bytes -= m.size
+	if m.expired {
+		s.expiredCount--
+	}
+	s.messages = append(s.messages[:i], s.messages[i+1:]...)
+}
+
+// touch marks the i-th message as the most recently used one
+func (s *messageStoreImpl) touch(i int) {
+	if s.bounds.MaxMessages == 0 && s.bounds.MaxBytes == 0 {
+		return
+	}
+	m := s.messages[i]
+	s.messages = append(append(s.messages[:i], s.messages[i+1:]...), m)
+}
+
+func (s *messageStoreImpl) sizeOf(message interface{}) int {
+	if s.bounds.MaxBytes == 0 || s.bounds.SizeOf == nil {
+		return 0
+	}
+	return s.bounds.SizeOf(message)
+}
+
+func (s *messageStoreImpl) exceedsBounds() bool {
+	return (s.bounds.MaxMessages > 0 && len(s.messages) > s.bounds.MaxMessages) ||
+		(s.bounds.MaxBytes > 0 && s.bytes > s.bounds.MaxBytes)
+}
+
+// evict removes the least recently used messages until the bounds are met, the most recent message is always kept.
+// The evicted messages are spilled, unless they already expired.
+func (s *messageStoreImpl) evict() {
+	for len(s.messages) > 1 && s.exceedsBounds() {
+		m := s.messages[0]
+		s.remove(0)
+		if m.expired {
+			continue
+		}
+		// The message is dropped if it can't be spilled
+		if s.bounds.Spill == nil || s.bounds.Spill.Put(s.bounds.KeyOf(m.data), m.data, m.created) != nil {
+			s.notifyRemoval(m, RemovalEvicted)
+		}
+	}
+}
+
+func (s *messageStoreImpl) OnRemoval(callback RemovalCallback, workers int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-s.doneCh:
+		return
+	default:
+	}
+	s.removalPools = append(s.removalPools, newRemovalPool(callback, workers))
+}
+
+// notifyRemoval queues the removal of the message for the registered callbacks, the lock must be held
+func (s *messageStoreImpl) notifyRemoval(m *msg, reason RemovalReason) {
+	for _, p := range s.removalPools {
+		p.submit(Removal{Msg: m.data, Reason: reason, Created: m.created})
+	}
+}
+
+// unspill loads back the spilled message having the same key as the given one, if any
+func (s *messageStoreImpl) unspill(message interface{}) {
+	if s.bounds.Spill == nil {
+		return
+	}
+	data, created, err := s.bounds.Spill.Take(s.bounds.KeyOf(message))
+	if err != nil || data == nil {
+		return
+	}
+	if s.msgTTL > 0 && s.clock.Since(created) > s.msgTTL*2 {
+		return
+	}
+
+	s.messages = append(s.messages, &msg{data: data, created: created, size: s.sizeOf(data)})
+	s.bytes += s.messages[len(s.messages)-1].size
+	s.evict()
+}
+
 func (s *messageStoreImpl) Purge(should func(interface{}) bool) {
 	shouldBePurged := func(m *msg) bool {
 		return should(m.data)
@@ -128,15 +253,23 @@ func (s *messageStoreImpl) Purge(should func(interface{}) bool) {
 			continue
 		}
 		s.invTrigger(s.messages[i].data)
-		s.messages = append(s.messages[:i], s.messages[i+1:]...)
+		s.notifyRemoval(s.messages[i], RemovalPurged)
+		s.remove(i)
 		n--
 		i--
 	}
 }
 
 func (s *messageStoreImpl) CheckValid(message interface{}) bool {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	if s.bounds.Spill != nil {
+		// The spilled message is loaded back
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.unspill(message)
+	} else {
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+	}
 
 	for _, m := range s.messages {
 		if s.pol(message, m.data) == common.MessageInvalidated {
@@ -168,7 +301,12 @@ func (s *messageStoreImpl) Get() []interface{} {
 
 func (s *messageStoreImpl) Stop() {
 	stopFunc := func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
 		close(s.doneCh)
+		for _, p := range s.removalPools {
+			p.stop()
+		}
 	}
 	s.stopOnce.Do(stopFunc)
 }
@@ -183,17 +321,17 @@ func (s *messageStoreImpl) expireMessages() {
 	for i := 0; i < n; i++ {
 		m := s.messages[i]
 		if !m.expired {
-			if time.Since(m.created) > s.msgTTL {
+			if s.clock.Since(m.created) > s.msgTTL {
 				m.expired = true
 				s.expireMsgCallback(m.data)
+				s.notifyRemoval(m, RemovalExpired)
 				s.expiredCount++
 			}
 		} else {
-			if time.Since(m.created) > (s.msgTTL * 2) {
-				s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			if s.clock.Since(m.created) > (s.msgTTL * 2) {
+				s.remove(i)
 				n--
 				i--
-				s.expiredCount--
 			}
 		}
 	}
@@ -215,11 +353,11 @@ func (s *messageStoreImpl) expirationRoutine() {
 		select {
 		case <-s.doneCh:
 			return
-		case <-time.After(s.expirationCheckInterval()):
+		case <-s.clock.After(s.expirationCheckInterval()):
 			hasMessageExpired := func(m *msg) bool {
-				if !m.expired && time.Since(m.created) > s.msgTTL {
+				if !m.expired && s.clock.Since(m.created) > s.msgTTL {
 					return true
-				} else if time.Since(m.created) > (s.msgTTL * 2) {
+				} else if s.clock.Since(m.created) > (s.msgTTL * 2) {
 					return true
 				}
 				return false
@@ -228,6 +366,9 @@ func (s *messageStoreImpl) expirationRoutine() {
 			if s.isPurgeNeeded(hasMessageExpired) {
 				s.expireMessages()
 			}
+			if s.bounds.Spill != nil {
+				s.bounds.Spill.Prune(s.clock.Now().Add(-s.msgTTL * 2))
+			}
 		}
 	}
 }
@@ -8,7 +8,9 @@ SPDX-License-Identifier: Apache-2.0
 package lib
 
 import (
+	"fmt"
 	"math/rand"
+	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func alwaysNoAction(_ interface{}, _ interface{}) common.InvalidationResult {
@@ -128,10 +131,18 @@ func TestMessageAdd(t *testing.T) {
 func TestExpiration(t *testing.T) {
 	expired := make([]int, 0)
 	lock := sync.Mutex{}
+	clock := NewFakeClock(time.Now())
+	// advance moves the clock forward and waits for the store to check the expirations
+	advance := func(d time.Duration) {
+		clock.BlockUntil(1)
+		clock.Advance(d)
+		clock.BlockUntil(1)
+	}
 
-	msgStore := NewMessageStoreExpirable(nonReplaceInts, Noop, time.Second*3, lock.Lock, lock.Unlock, func(m interface{}) {
+	msgStore := NewMessageStoreExpirable(nonReplaceInts, Noop, time.Second*3, clock, lock.Lock, lock.Unlock, func(m interface{}) {
 		expired = append(expired, m.(int))
 	})
+	defer msgStore.Stop()
 
 	for i := 0; i < 10; i++ {
 		assert.True(t, msgStore.Add(i))
@@ -139,7 +150,7 @@ func TestExpiration(t *testing.T) {
 
 	assert.Equal(t, 10, msgStore.Size())
 
-	time.Sleep(2 * time.Second)
+	advance(2 * time.Second)
 
 	for i := 0; i < 10; i++ {
 		assert.False(t, msgStore.Add(i))
@@ -154,7 +165,7 @@ func TestExpiration(t *testing.T) {
 
 	assert.Equal(t, 20, msgStore.Size())
 
-	time.Sleep(2 * time.Second)
+	advance(2 * time.Second)
 
 	for i := 0; i < 20; i++ {
 		assert.False(t, msgStore.Add(i))
@@ -166,7 +177,7 @@ func TestExpiration(t *testing.T) {
 	assert.Equal(t, 10, len(expired))
 	lock.Unlock()
 
-	time.Sleep(4 * time.Second)
+	advance(4 * time.Second)
 
 	assert.Equal(t, 0, msgStore.Size())
 
@@ -219,7 +230,7 @@ func TestPurge(t *testing.T) {
 
 func TestStop(t *testing.T) {
 	expired := make([]int, 0)
-	msgStore := NewMessageStoreExpirable(nonReplaceInts, Noop, 3*time.Second, nil, nil, func(m interface{}) {
+	msgStore := NewMessageStoreExpirable(nonReplaceInts, Noop, 3*time.Second, nil, nil, nil, func(m interface{}) {
 		expired = append(expired, m.(int))
 	})
 
@@ -238,3 +249,117 @@ func TestStop(t *testing.T) {
 
 	msgStore.Stop()
 }
+
+type keyedMsg struct {
+	key string
+	seq int
+}
+
+func compareKeyed(this interface{}, that interface{}) common.InvalidationResult {
+	a, b := this.(keyedMsg), that.(keyedMsg)
+	if a.key != b.key {
+		return common.MessageNoAction
+	}
+	if a.seq > b.seq {
+		return common.MessageInvalidates
+	}
+	return common.MessageInvalidated
+}
+
+func TestBoundedStore(t *testing.T) {
+	msgStore := NewMessageStoreBounded(compareKeyed, Noop, 0, nil, MessageStoreBounds{MaxMessages: 2})
+	assert.True(t, msgStore.Add(keyedMsg{"a", 1}))
+	assert.True(t, msgStore.Add(keyedMsg{"b", 1}))
+	// a is used, so b is the least recently used message
+	assert.False(t, msgStore.Add(keyedMsg{"a", 1}))
+	assert.True(t, msgStore.Add(keyedMsg{"c", 1}))
+
+	assert.Equal(t, 2, msgStore.Size())
+	assert.ElementsMatch(t, []interface{}{keyedMsg{"a", 1}, keyedMsg{"c", 1}}, msgStore.Get())
+	// b was dropped, so it's accepted again
+	assert.True(t, msgStore.Add(keyedMsg{"b", 1}))
+
+	sizeOf := func(msg interface{}) int { return msg.(keyedMsg).seq }
+	msgStore = NewMessageStoreBounded(compareKeyed, Noop, 0, nil, MessageStoreBounds{MaxBytes: 10, SizeOf: sizeOf})
+	assert.True(t, msgStore.Add(keyedMsg{"a", 4}))
+	assert.True(t, msgStore.Add(keyedMsg{"b", 4}))
+	assert.True(t, msgStore.Add(keyedMsg{"c", 4}))
+	assert.Equal(t, []interface{}{keyedMsg{"b", 4}, keyedMsg{"c", 4}}, msgStore.Get())
+	// The most recent message is kept even if it exceeds the bounds
+	assert.True(t, msgStore.Add(keyedMsg{"d", 20}))
+	assert.Equal(t, []interface{}{keyedMsg{"d", 20}}, msgStore.Get())
+}
+
+func TestBoundedStoreSpill(t *testing.T) {
+	dir, err := os.MkdirTemp("", "spill")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spill, err := NewFileSpill(dir, func(msg interface{}) ([]byte, error) {
+		m := msg.(keyedMsg)
+		return []byte(fmt.Sprintf("%s %d", m.key, m.seq)), nil
+	}, func(data []byte) (interface{}, error) {
+		var m keyedMsg
+		_, err := fmt.Sscanf(string(data), "%s %d", &m.key, &m.seq)
+		return m, err
+	})
+	require.NoError(t, err)
+
+	keyOf := func(msg interface{}) string { return msg.(keyedMsg).key }
+	msgStore := NewMessageStoreBounded(compareKeyed, Noop, time.Hour, nil, MessageStoreBounds{MaxMessages: 1, Spill: spill, KeyOf: keyOf})
+	defer msgStore.Stop()
+	assert.True(t, msgStore.Add(keyedMsg{"a", 2}))
+	assert.True(t, msgStore.Add(keyedMsg{"b", 1}))
+	assert.Equal(t, []interface{}{keyedMsg{"b", 1}}, msgStore.Get())
+
+	// The spilled message still invalidates the older messages
+	assert.False(t, msgStore.CheckValid(keyedMsg{"a", 1}))
+	assert.False(t, msgStore.Add(keyedMsg{"a", 1}))
+	assert.Equal(t, []interface{}{keyedMsg{"a", 2}}, msgStore.Get())
+	assert.True(t, msgStore.Add(keyedMsg{"b", 2}))
+	assert.True(t, msgStore.Add(keyedMsg{"a", 3}))
+
+	// The expired spilled messages are pruned
+	require.NoError(t, spill.Put("c", keyedMsg{"c", 1}, time.Now().Add(-time.Hour)))
+	require.NoError(t, spill.Prune(time.Now().Add(-time.Minute)))
+	msg, _, err := spill.Take("c")
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestRemovalCallback(t *testing.T) {
+	msgStore := NewMessageStoreBounded(compareKeyed, Noop, 500*time.Millisecond, nil, MessageStoreBounds{MaxMessages: 2})
+	defer msgStore.Stop()
+
+	removals := make(chan Removal, 10)
+	release := make(chan struct{})
+	msgStore.OnRemoval(func(r Removal) {
+		<-release
+		removals <- r
+	}, 1)
+
+	// The blocked callback doesn't stall the store
+	assert.True(t, msgStore.Add(keyedMsg{"a", 1}))
+	assert.True(t, msgStore.Add(keyedMsg{"a", 2}))
+	assert.True(t, msgStore.Add(keyedMsg{"b", 1}))
+	assert.True(t, msgStore.Add(keyedMsg{"c", 1}))
+	msgStore.Purge(func(msg interface{}) bool { return msg.(keyedMsg).key == "b" })
+	close(release)
+
+	expected := map[RemovalReason]keyedMsg{
+		RemovalReplaced: {"a", 1},
+		RemovalEvicted:  {"a", 2},
+		RemovalPurged:   {"b", 1},
+		RemovalExpired:  {"c", 1},
+	}
+	for len(expected) > 0 {
+		select {
+		case r := <-removals:
+			assert.Equal(t, expected[r.Reason], r.Msg, r.Reason.String())
+			assert.False(t, r.Created.IsZero())
+			delete(expected, r.Reason)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Missing removals", "%v", expected)
+		}
+	}
+}
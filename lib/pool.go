@@ -0,0 +1,70 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// PoolStats holds the number of buffers taken from a BufferPool,
+// and how many of them had to be allocated
+type PoolStats struct {
+	Gets   uint64
+	Allocs uint64
+}
+
+// Reused returns the number of buffers taken from the pool which were recycled
+func (s PoolStats) Reused() uint64 {
+	return s.Gets - s.Allocs
+}
+
+// BufferPool recycles byte buffers of a fixed size, to reduce the pressure on the garbage collector
+type BufferPool struct {
+	size   int
+	pool   sync.Pool
+	gets   uint64
+	allocs uint64
+}
+
+// NewBufferPool creates a BufferPool of buffers of the given size
+func NewBufferPool(size int) *BufferPool {
+	p := &BufferPool{size: size}
+	p.pool.New = func() interface{} {
+		atomic.AddUint64(&p.allocs, 1)
+		buf := make([]byte, p.size)
+		return &buf
+	}
+	return p
+}
+
+// Get returns a buffer of the size of the pool, it should be put back once it is no longer referenced
+func (p *BufferPool) Get() *[]byte {
+	atomic.AddUint64(&p.gets, 1)
+	return p.pool.Get().(*[]byte)
+}
+
+// Put puts the buffer back to the pool, buffers of another size are dropped
+func (p *BufferPool) Put(buf *[]byte) {
+	if len(*buf) != p.size {
+		return
+	}
+	p.pool.Put(buf)
+}
+
+// Copy copies from src to dst like io.Copy, with a buffer of the pool
+func (p *BufferPool) Copy(dst io.Writer, src io.Reader) (int64, error) {
+	buf := p.Get()
+	defer p.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// Stats returns the number of buffers taken from the pool so far, and how many of them were allocated
+func (p *BufferPool) Stats() PoolStats {
+	return PoolStats{Gets: atomic.LoadUint64(&p.gets), Allocs: atomic.LoadUint64(&p.allocs)}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	p := NewBufferPool(16)
+
+	buf := p.Get()
+	assert.Len(t, *buf, 16)
+	p.Put(buf)
+	assert.Len(t, *p.Get(), 16)
+
+	// Buffers of another size aren't pooled
+	short := (*buf)[:8]
+	p.Put(&short)
+	assert.Len(t, *p.Get(), 16)
+
+	// The buffers are reused to copy more data than they hold
+	var dst bytes.Buffer
+	n, err := p.Copy(&dst, strings.NewReader(strings.Repeat("rksync", 10)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), n)
+	assert.Equal(t, strings.Repeat("rksync", 10), dst.String())
+
+	// The pool may drop its buffers at any time, they're allocated again then
+	stats := p.Stats()
+	assert.Equal(t, uint64(4), stats.Gets)
+	assert.True(t, stats.Allocs >= 2 && stats.Allocs <= stats.Gets)
+	assert.Equal(t, stats.Gets-stats.Allocs, stats.Reused())
+}
@@ -0,0 +1,110 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// RemovalReason tells why a message left a MessageStore
+type RemovalReason int
+
+const (
+	// RemovalExpired means the message outlived the TTL of the store
+	RemovalExpired RemovalReason = iota
+	// RemovalEvicted means the message was evicted to meet the bounds of the store, and wasn't spilled
+	RemovalEvicted
+	// RemovalReplaced means the message was invalidated by a message added to the store
+	RemovalReplaced
+	// RemovalPurged means the message was explicitly purged from the store
+	RemovalPurged
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalExpired:
+		return "expired"
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalPurged:
+		return "purged"
+	default:
+		return "unknown"
+	}
+}
+
+// Removal describes a message which left a MessageStore
+type Removal struct {
+	Msg     interface{}
+	Reason  RemovalReason
+	Created time.Time // Time the message was added to the store
+}
+
+// RemovalCallback is notified of the messages leaving a MessageStore
+type RemovalCallback func(Removal)
+
+// removalPool runs a RemovalCallback on worker goroutines, the removals are queued
+// without limit so that the store never waits for the callback
+type removalPool struct {
+	callback RemovalCallback
+	lock     sync.Mutex
+	cond     *sync.Cond
+	queue    []Removal
+	stopped  bool
+}
+
+func newRemovalPool(callback RemovalCallback, workers int) *removalPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &removalPool{callback: callback}
+	p.cond = sync.NewCond(&p.lock)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *removalPool) submit(removal Removal) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return
+	}
+	p.queue = append(p.queue, removal)
+	p.cond.Signal()
+}
+
+// stop lets the workers exit once the queued removals are processed
+func (p *removalPool) stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stopped = true
+	p.cond.Broadcast()
+}
+
+func (p *removalPool) work() {
+	for {
+		p.lock.Lock()
+		for len(p.queue) == 0 && !p.stopped {
+			p.cond.Wait()
+		}
+		if len(p.queue) == 0 {
+			p.lock.Unlock()
+			return
+		}
+		removal := p.queue[0]
+		p.queue[0] = Removal{}
+		p.queue = p.queue[1:]
+		p.lock.Unlock()
+
+		p.callback(removal)
+	}
+}
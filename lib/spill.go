@@ -0,0 +1,106 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lib
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MessageSpill stores the messages evicted from a bounded MessageStore
+type MessageSpill interface {
+	// Put stores the message, created at the given time, under the given key, replacing the message stored under it
+	Put(key string, msg interface{}, created time.Time) error
+
+	// Take removes and returns the message stored under the given key, the message is nil if there isn't any
+	Take(key string) (msg interface{}, created time.Time, err error)
+
+	// Prune removes the messages created before the given time
+	Prune(before time.Time) error
+}
+
+// NewFileSpill returns a MessageSpill storing each message in a file of the given directory
+func NewFileSpill(dir string, marshal func(msg interface{}) ([]byte, error), unmarshal func(data []byte) (interface{}, error)) (MessageSpill, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "could not create spill directory %s", dir)
+	}
+	return &fileSpill{dir: dir, marshal: marshal, unmarshal: unmarshal}, nil
+}
+
+// fileSpill names the files after the hex encoded keys, their modification time is the creation time of the messages
+type fileSpill struct {
+	dir       string
+	marshal   func(msg interface{}) ([]byte, error)
+	unmarshal func(data []byte) (interface{}, error)
+}
+
+func (f *fileSpill) path(key string) string {
+	return filepath.Join(f.dir, hex.EncodeToString([]byte(key)))
+}
+
+func (f *fileSpill) Put(key string, msg interface{}, created time.Time) error {
+	data, err := f.marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal message")
+	}
+
+	p := f.path(key)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "could not write message")
+	}
+	if err := os.Chtimes(tmp, created, created); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "could not set message creation time")
+	}
+	return errors.Wrap(os.Rename(tmp, p), "could not write message")
+}
+
+func (f *fileSpill) Take(key string) (interface{}, time.Time, error) {
+	p := f.path(key)
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "could not stat message")
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "could not read message")
+	}
+	if err := os.Remove(p); err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "could not remove message")
+	}
+
+	msg, err := f.unmarshal(data)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "could not unmarshal message")
+	}
+	return msg, fi.ModTime(), nil
+}
+
+func (f *fileSpill) Prune(before time.Time) error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not read spill directory %s", f.dir)
+	}
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil || !fi.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "could not remove message")
+		}
+	}
+	return nil
+}
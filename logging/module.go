@@ -0,0 +1,182 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// Log levels, from the most verbose to the least verbose
+const (
+	DebugLevel Level = Level(debugLog)
+	InfoLevel  Level = Level(infoLog)
+	WarnLevel  Level = Level(warningLog)
+	ErrorLevel Level = Level(errorLog)
+	FatalLevel Level = Level(fatalLog)
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	if l < DebugLevel || l > FatalLevel {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return severityName[l]
+}
+
+// ParseLevel parses a level name, case insensitively
+func ParseLevel(name string) (Level, error) {
+	for l, n := range severityName {
+		if strings.EqualFold(name, n) {
+			return Level(l), nil
+		}
+	}
+	if strings.EqualFold(name, "warn") {
+		return WarnLevel, nil
+	}
+	return DebugLevel, errors.Errorf("Unknown log level %s", name)
+}
+
+// UnmarshalText parses a level name, so that levels can be read from configuration files
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// FieldLogger is a Logger able to attach structured fields to its entries.
+// Backends implementing it receive the module of the entries as the "module" field,
+// other backends get the module and fields as a prefix of the message.
+type FieldLogger interface {
+	Logger
+	WithField(key string, value interface{}) Logger
+}
+
+// Default returns a Logger writing to the package-global logger set by SetLogger
+func Default() Logger {
+	return globalLogger{}
+}
+
+// ForModule returns the Logger of a module writing to backend, or to the package-global
+// logger if backend is nil. Entries with a lower level than the one of the module in levels
+// are discarded, modules missing from levels leave the filtering to the backend.
+func ForModule(backend Logger, module string, levels map[string]Level) Logger {
+	if backend == nil {
+		backend = Default()
+	}
+	level, exists := levels[module]
+	if !exists {
+		level = DebugLevel
+	}
+	return (&moduleLogger{backend: backend, level: level}).WithField("module", module)
+}
+
+type moduleLogger struct {
+	backend Logger
+	level   Level
+	prefix  string
+}
+
+func (m *moduleLogger) WithField(key string, value interface{}) Logger {
+	if fl, ok := m.backend.(FieldLogger); ok {
+		return &moduleLogger{backend: fl.WithField(key, value), level: m.level, prefix: m.prefix}
+	}
+	if key == "module" {
+		return &moduleLogger{backend: m.backend, level: m.level, prefix: fmt.Sprintf("%s[%v] ", m.prefix, value)}
+	}
+	return &moduleLogger{backend: m.backend, level: m.level, prefix: fmt.Sprintf("%s%s=%v ", m.prefix, key, value)}
+}
+
+func (m *moduleLogger) args(args []interface{}) []interface{} {
+	if m.prefix == "" {
+		return args
+	}
+	return append([]interface{}{strings.TrimSuffix(m.prefix, " ")}, args...)
+}
+
+func (m *moduleLogger) format(format string) string {
+	return strings.Replace(m.prefix, "%", "%%", -1) + format
+}
+
+func (m *moduleLogger) Debug(args ...interface{}) {
+	if m.level <= DebugLevel {
+		m.backend.Debug(m.args(args)...)
+	}
+}
+
+func (m *moduleLogger) Debugf(format string, args ...interface{}) {
+	if m.level <= DebugLevel {
+		m.backend.Debugf(m.format(format), args...)
+	}
+}
+
+func (m *moduleLogger) Info(args ...interface{}) {
+	if m.level <= InfoLevel {
+		m.backend.Info(m.args(args)...)
+	}
+}
+
+func (m *moduleLogger) Infof(format string, args ...interface{}) {
+	if m.level <= InfoLevel {
+		m.backend.Infof(m.format(format), args...)
+	}
+}
+
+func (m *moduleLogger) Warning(args ...interface{}) {
+	if m.level <= WarnLevel {
+		m.backend.Warning(m.args(args)...)
+	}
+}
+
+func (m *moduleLogger) Warningf(format string, args ...interface{}) {
+	if m.level <= WarnLevel {
+		m.backend.Warningf(m.format(format), args...)
+	}
+}
+
+func (m *moduleLogger) Error(args ...interface{}) {
+	if m.level <= ErrorLevel {
+		m.backend.Error(m.args(args)...)
+	}
+}
+
+func (m *moduleLogger) Errorf(format string, args ...interface{}) {
+	if m.level <= ErrorLevel {
+		m.backend.Errorf(m.format(format), args...)
+	}
+}
+
+func (m *moduleLogger) Fatal(args ...interface{}) {
+	m.backend.Fatal(m.args(args)...)
+}
+
+func (m *moduleLogger) Fatalf(format string, args ...interface{}) {
+	m.backend.Fatalf(m.format(format), args...)
+}
+
+// globalLogger forwards to the package-global logger, so that SetLogger
+// also applies to the loggers created before it is called
+type globalLogger struct{}
+
+func (globalLogger) Debug(args ...interface{})                   { logger.Debug(args...) }
+func (globalLogger) Debugf(format string, args ...interface{})   { logger.Debugf(format, args...) }
+func (globalLogger) Info(args ...interface{})                    { logger.Info(args...) }
+func (globalLogger) Infof(format string, args ...interface{})    { logger.Infof(format, args...) }
+func (globalLogger) Warning(args ...interface{})                 { logger.Warning(args...) }
+func (globalLogger) Warningf(format string, args ...interface{}) { logger.Warningf(format, args...) }
+func (globalLogger) Error(args ...interface{})                   { logger.Error(args...) }
+func (globalLogger) Errorf(format string, args ...interface{})   { logger.Errorf(format, args...) }
+func (globalLogger) Fatal(args ...interface{})                   { Fatal(args...) }
+func (globalLogger) Fatalf(format string, args ...interface{})   { Fatalf(format, args...) }
@@ -0,0 +1,111 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	fields  map[string]interface{}
+	entries *[]string
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{fields: map[string]interface{}{}, entries: &[]string{}}
+}
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func (r *recordingLogger) record(level string, msg string) {
+	*r.entries = append(*r.entries, fmt.Sprintf("%s %v %s", level, r.fields, msg))
+}
+
+func (r *recordingLogger) Debug(args ...interface{})   { r.record("DEBUG", sprintln(args...)) }
+func (r *recordingLogger) Info(args ...interface{})    { r.record("INFO", sprintln(args...)) }
+func (r *recordingLogger) Warning(args ...interface{}) { r.record("WARNING", sprintln(args...)) }
+func (r *recordingLogger) Error(args ...interface{})   { r.record("ERROR", sprintln(args...)) }
+func (r *recordingLogger) Fatal(args ...interface{})   { r.record("FATAL", sprintln(args...)) }
+func (r *recordingLogger) Debugf(format string, args ...interface{}) {
+	r.record("DEBUG", fmt.Sprintf(format, args...))
+}
+func (r *recordingLogger) Infof(format string, args ...interface{}) {
+	r.record("INFO", fmt.Sprintf(format, args...))
+}
+func (r *recordingLogger) Warningf(format string, args ...interface{}) {
+	r.record("WARNING", fmt.Sprintf(format, args...))
+}
+func (r *recordingLogger) Errorf(format string, args ...interface{}) {
+	r.record("ERROR", fmt.Sprintf(format, args...))
+}
+func (r *recordingLogger) Fatalf(format string, args ...interface{}) {
+	r.record("FATAL", fmt.Sprintf(format, args...))
+}
+
+type recordingFieldLogger struct {
+	*recordingLogger
+}
+
+func (r *recordingFieldLogger) WithField(key string, value interface{}) Logger {
+	fields := map[string]interface{}{key: value}
+	for k, v := range r.fields {
+		fields[k] = v
+	}
+	return &recordingFieldLogger{&recordingLogger{fields: fields, entries: r.entries}}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]Level{"debug": DebugLevel, "INFO": InfoLevel, "warn": WarnLevel, "Warning": WarnLevel, "error": ErrorLevel} {
+		level, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, level)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+	assert.Equal(t, "WARNING", WarnLevel.String())
+}
+
+func TestForModule(t *testing.T) {
+	backend := newRecordingLogger()
+	levels := map[string]Level{"rpc": WarnLevel}
+
+	rpc := ForModule(backend, "rpc", levels)
+	rpc.Debugf("dropped %d", 1)
+	rpc.Info("dropped")
+	rpc.Warningf("100%% of %s", "messages")
+	rpc.Error("closing", "connection")
+
+	discovery := ForModule(backend, "discovery", levels)
+	discovery.Debug("alive")
+
+	assert.Equal(t, []string{
+		"WARNING map[] [rpc] 100% of messages",
+		"ERROR map[] [rpc] closing connection",
+		"DEBUG map[] [discovery] alive",
+	}, *backend.entries)
+}
+
+func TestForModuleWithFieldLogger(t *testing.T) {
+	backend := &recordingFieldLogger{newRecordingLogger()}
+
+	l := ForModule(backend, "gossip", map[string]Level{"gossip": InfoLevel})
+	l.Debug("hidden")
+	l.Infof("Started %s", "peer0")
+	l.(FieldLogger).WithField("channel", "testchannel").Warning("Stopping")
+
+	assert.Equal(t, []string{
+		"INFO map[module:gossip] Started peer0",
+		"WARNING map[channel:testchannel module:gossip] Stopping",
+	}, *backend.entries)
+}
@@ -0,0 +1,432 @@
+// Code generated by protoc-gen-grpc. DO NOT EDIT.
+// source: github.com/rkcloudchain/rksync/protos/admin.proto
+
+package protos
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AdminClient interface {
+	// ListChannels returns the channels the peer is a member of
+	ListChannels(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ChannelList, error)
+	// ChannelInfo returns the chain state of a channel
+	ChannelInfo(ctx context.Context, in *ChannelRequest, opts ...grpc.CallOption) (*ChannelInfoResponse, error)
+	// ListPeers returns the alive members known by the peer
+	ListPeers(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerList, error)
+	// ConnectionStats returns the connections established with remote peers
+	ConnectionStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ConnectionStatsResponse, error)
+	// PeerScores returns the reputation of the peers which misbehaved recently
+	PeerScores(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerScoreList, error)
+	// TransferProgress returns the synchronization progress of the files of a channel
+	TransferProgress(ctx context.Context, in *ChannelRequest, opts ...grpc.CallOption) (*TransferProgressResponse, error)
+	// CreateChannel creates a channel led by the peer
+	CreateChannel(ctx context.Context, in *CreateChannelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// AddMember adds a member to a channel led by the peer
+	AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// AddFile adds files to a channel led by the peer
+	AddFile(ctx context.Context, in *AddFileRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// RemoveFile removes files from a channel led by the peer
+	RemoveFile(ctx context.Context, in *RemoveFileRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// DebugDump returns the goroutine stacks of the peer and the depth of its message queues,
+	// it fails unless AdminDebug is enabled
+	DebugDump(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DebugDumpResponse, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListChannels(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ChannelList, error) {
+	out := new(ChannelList)
+	err := c.cc.Invoke(ctx, "/protos.Admin/ListChannels", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ChannelInfo(ctx context.Context, in *ChannelRequest, opts ...grpc.CallOption) (*ChannelInfoResponse, error) {
+	out := new(ChannelInfoResponse)
+	err := c.cc.Invoke(ctx, "/protos.Admin/ChannelInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListPeers(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerList, error) {
+	out := new(PeerList)
+	err := c.cc.Invoke(ctx, "/protos.Admin/ListPeers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ConnectionStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ConnectionStatsResponse, error) {
+	out := new(ConnectionStatsResponse)
+	err := c.cc.Invoke(ctx, "/protos.Admin/ConnectionStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) PeerScores(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerScoreList, error) {
+	out := new(PeerScoreList)
+	err := c.cc.Invoke(ctx, "/protos.Admin/PeerScores", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) TransferProgress(ctx context.Context, in *ChannelRequest, opts ...grpc.CallOption) (*TransferProgressResponse, error) {
+	out := new(TransferProgressResponse)
+	err := c.cc.Invoke(ctx, "/protos.Admin/TransferProgress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateChannel(ctx context.Context, in *CreateChannelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Admin/CreateChannel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Admin/AddMember", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AddFile(ctx context.Context, in *AddFileRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Admin/AddFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveFile(ctx context.Context, in *RemoveFileRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Admin/RemoveFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DebugDump(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DebugDumpResponse, error) {
+	out := new(DebugDumpResponse)
+	err := c.cc.Invoke(ctx, "/protos.Admin/DebugDump", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+type AdminServer interface {
+	// ListChannels returns the channels the peer is a member of
+	ListChannels(context.Context, *emptypb.Empty) (*ChannelList, error)
+	// ChannelInfo returns the chain state of a channel
+	ChannelInfo(context.Context, *ChannelRequest) (*ChannelInfoResponse, error)
+	// ListPeers returns the alive members known by the peer
+	ListPeers(context.Context, *emptypb.Empty) (*PeerList, error)
+	// ConnectionStats returns the connections established with remote peers
+	ConnectionStats(context.Context, *emptypb.Empty) (*ConnectionStatsResponse, error)
+	// PeerScores returns the reputation of the peers which misbehaved recently
+	PeerScores(context.Context, *emptypb.Empty) (*PeerScoreList, error)
+	// TransferProgress returns the synchronization progress of the files of a channel
+	TransferProgress(context.Context, *ChannelRequest) (*TransferProgressResponse, error)
+	// CreateChannel creates a channel led by the peer
+	CreateChannel(context.Context, *CreateChannelRequest) (*emptypb.Empty, error)
+	// AddMember adds a member to a channel led by the peer
+	AddMember(context.Context, *AddMemberRequest) (*emptypb.Empty, error)
+	// AddFile adds files to a channel led by the peer
+	AddFile(context.Context, *AddFileRequest) (*emptypb.Empty, error)
+	// RemoveFile removes files from a channel led by the peer
+	RemoveFile(context.Context, *RemoveFileRequest) (*emptypb.Empty, error)
+	// DebugDump returns the goroutine stacks of the peer and the depth of its message queues,
+	// it fails unless AdminDebug is enabled
+	DebugDump(context.Context, *emptypb.Empty) (*DebugDumpResponse, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_ListChannels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListChannels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/ListChannels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListChannels(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ChannelInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ChannelInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/ChannelInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ChannelInfo(ctx, req.(*ChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/ListPeers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListPeers(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ConnectionStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ConnectionStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/ConnectionStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ConnectionStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_PeerScores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).PeerScores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/PeerScores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).PeerScores(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_TransferProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).TransferProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/TransferProgress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).TransferProgress(ctx, req.(*ChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/CreateChannel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateChannel(ctx, req.(*CreateChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AddMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AddMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/AddMember",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AddMember(ctx, req.(*AddMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AddFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AddFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/AddFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AddFile(ctx, req.(*AddFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/RemoveFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveFile(ctx, req.(*RemoveFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DebugDump_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DebugDump(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Admin/DebugDump",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DebugDump(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListChannels",
+			Handler:    _Admin_ListChannels_Handler,
+		},
+		{
+			MethodName: "ChannelInfo",
+			Handler:    _Admin_ChannelInfo_Handler,
+		},
+		{
+			MethodName: "ListPeers",
+			Handler:    _Admin_ListPeers_Handler,
+		},
+		{
+			MethodName: "ConnectionStats",
+			Handler:    _Admin_ConnectionStats_Handler,
+		},
+		{
+			MethodName: "PeerScores",
+			Handler:    _Admin_PeerScores_Handler,
+		},
+		{
+			MethodName: "TransferProgress",
+			Handler:    _Admin_TransferProgress_Handler,
+		},
+		{
+			MethodName: "CreateChannel",
+			Handler:    _Admin_CreateChannel_Handler,
+		},
+		{
+			MethodName: "AddMember",
+			Handler:    _Admin_AddMember_Handler,
+		},
+		{
+			MethodName: "AddFile",
+			Handler:    _Admin_AddFile_Handler,
+		},
+		{
+			MethodName: "RemoveFile",
+			Handler:    _Admin_RemoveFile_Handler,
+		},
+		{
+			MethodName: "DebugDump",
+			Handler:    _Admin_DebugDump_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "github.com/rkcloudchain/rksync/protos/admin.proto",
+}
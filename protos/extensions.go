@@ -9,14 +9,20 @@ package protos
 
 import (
 	"bytes"
-	"encoding/hex"
 	"fmt"
+	"io"
+	"sync"
+	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/common"
+	"google.golang.org/protobuf/proto"
 )
 
+// MessageOverhead is the number of bytes reserved for the envelope, signature and
+// headers of a message, its content must fit in the max message size minus the overhead
+const MessageOverhead = 1024
+
 // NewRKSyncMessageComparator creates a MessageReplcaingPolicy
 func NewRKSyncMessageComparator() common.MessageReplcaingPolicy {
 	return func(this interface{}, that interface{}) common.InvalidationResult {
@@ -35,7 +41,7 @@ func invalidationPolicy(this interface{}, that interface{}) common.InvalidationR
 		if !bytes.Equal(thisMsg.RKSyncMessage.ChainMac, thatMsg.RKSyncMessage.ChainMac) {
 			return common.MessageNoAction
 		}
-		return stateInvalidationPolicy(thisMsg.GetState(), thatMsg.GetState())
+		return stateInvalidationPolicy(thisMsg, thatMsg)
 	}
 	if thisMsg.IsChainPullRequestMsg() && thatMsg.IsChainPullRequestMsg() {
 		if !bytes.Equal(thisMsg.RKSyncMessage.ChainMac, thatMsg.RKSyncMessage.ChainMac) {
@@ -43,6 +49,18 @@ func invalidationPolicy(this interface{}, that interface{}) common.InvalidationR
 		}
 		return compareTimestamps(thisMsg.GetStatePullRequest().Timestamp, thatMsg.GetStatePullRequest().Timestamp)
 	}
+	if thisMsg.IsLeadershipDeclaration() && thatMsg.IsLeadershipDeclaration() {
+		if !bytes.Equal(thisMsg.RKSyncMessage.ChainMac, thatMsg.RKSyncMessage.ChainMac) {
+			return common.MessageNoAction
+		}
+		return leadershipInvalidationPolicy(thisMsg.GetLeadershipDeclaration(), thatMsg.GetLeadershipDeclaration())
+	}
+	if thisMsg.IsLeadershipVote() && thatMsg.IsLeadershipVote() {
+		if !bytes.Equal(thisMsg.RKSyncMessage.ChainMac, thatMsg.RKSyncMessage.ChainMac) {
+			return common.MessageNoAction
+		}
+		return voteInvalidationPolicy(thisMsg.GetLeadershipVote(), thatMsg.GetLeadershipVote())
+	}
 
 	return common.MessageNoAction
 }
@@ -55,8 +73,56 @@ func aliveInvalidationPolicy(this *AliveMessage, that *AliveMessage) common.Inva
 	return compareTimestamps(this.Timestamp, that.Timestamp)
 }
 
-func stateInvalidationPolicy(this *ChainState, that *ChainState) common.InvalidationResult {
-	if this.SeqNum > that.SeqNum {
+// stateInvalidationPolicy orders the chain states by the epoch signed by the leader,
+// the sequence number isn't signed and only orders the states of the same epoch
+func stateInvalidationPolicy(thisMsg *SignedRKSyncMessage, thatMsg *SignedRKSyncMessage) common.InvalidationResult {
+	if thisEpoch, thatEpoch := thisMsg.StateEpoch(), thatMsg.StateEpoch(); thisEpoch != thatEpoch {
+		if thisEpoch > thatEpoch {
+			return common.MessageInvalidates
+		}
+		return common.MessageInvalidated
+	}
+	this, that := thisMsg.GetState(), thatMsg.GetState()
+	if this.SeqNum != that.SeqNum {
+		if this.SeqNum > that.SeqNum {
+			return common.MessageInvalidates
+		}
+		return common.MessageInvalidated
+	}
+	// The leader publishes the same state again along with a newer heartbeat
+	if this.Heartbeat != nil && that.Heartbeat != nil {
+		return compareTimestamps(this.Heartbeat, that.Heartbeat)
+	}
+	return common.MessageInvalidated
+}
+
+// leadershipInvalidationPolicy keeps the declaration of the highest term only, the candidates
+// declaring the same term are ordered by their PKI-IDs so that all the peers keep the same one
+func leadershipInvalidationPolicy(this *LeadershipDeclaration, that *LeadershipDeclaration) common.InvalidationResult {
+	if this.Term != that.Term {
+		if this.Term > that.Term {
+			return common.MessageInvalidates
+		}
+		return common.MessageInvalidated
+	}
+	if cmp := bytes.Compare(this.PkiId, that.PkiId); cmp != 0 {
+		if cmp < 0 {
+			return common.MessageInvalidates
+		}
+		return common.MessageInvalidated
+	}
+	if this.Timestamp != nil && that.Timestamp != nil {
+		return compareTimestamps(this.Timestamp, that.Timestamp)
+	}
+	return common.MessageInvalidated
+}
+
+// voteInvalidationPolicy keeps the vote of the highest term of each voter
+func voteInvalidationPolicy(this *LeadershipVote, that *LeadershipVote) common.InvalidationResult {
+	if !bytes.Equal(this.Voter, that.Voter) {
+		return common.MessageNoAction
+	}
+	if this.Term > that.Term {
 		return common.MessageInvalidates
 	}
 	return common.MessageInvalidated
@@ -107,79 +173,48 @@ type ReceivedMessage interface {
 type SignedRKSyncMessage struct {
 	*Envelope
 	*RKSyncMessage
+	epochOnce sync.Once
+	epoch     uint64 // epoch of the chain state of the message, unmarshaled once
+}
+
+// StateEpoch returns the epoch of the chain state of the message, 0 if it isn't a ChainState message.
+// The ChainStateInfo signed by the leader is only unmarshaled the first time.
+func (m *SignedRKSyncMessage) StateEpoch() uint64 {
+	m.epochOnce.Do(func() {
+		if m.IsChainStateMsg() {
+			m.epoch = m.GetState().Epoch()
+		}
+	})
+	return m.epoch
 }
 
 // String returns a string representation of a SignedRKSyncMessage
 func (m *SignedRKSyncMessage) String() string {
 	env := "No envelope"
 	if m.Envelope != nil {
-		env = fmt.Sprintf("%d bytes, Signature: %d bytes %s", len(m.Envelope.Payload), len(m.Envelope.Signature), hex.EncodeToString(m.Envelope.Signature))
+		env = envelopeToString(m.Envelope)
 	}
 
 	msg := "No rksync message"
 	if m.RKSyncMessage != nil {
-		if m.IsAliveMsg() {
-			msg = aliveMessageToString(m.GetAliveMsg())
-		} else if m.IsChainStateMsg() {
-			msg = chainStatMessageToString(m.RKSyncMessage.ChainMac, m.GetState())
-		} else {
-			msg = m.RKSyncMessage.String()
-		}
+		msg = messageToString(m.RKSyncMessage)
 	}
 
 	return fmt.Sprintf("RKSyncMessage: %s, Envelope: %s", msg, env)
 }
 
-func chainStatMessageToString(chainMac []byte, cs *ChainState) string {
-	str := fmt.Sprintf("chain_state_message: Channel MAC: %s, Sequence: %d", hex.EncodeToString(chainMac), cs.SeqNum)
-	msg, err := cs.Envelope.ToRKSyncMessage()
-	if err == nil {
-		if msg.IsStateInfoMsg() {
-			str = fmt.Sprintf("%s, StateInfo: %s", str, chainStateInfoToString(msg.GetStateInfo()))
-		}
-	}
-	return str
-}
-
-func chainStateInfoToString(csi *ChainStateInfo) string {
-	return fmt.Sprintf("Leader: %s, Properties: %s", common.PKIidType(csi.Leader).String(), chainStateInfoPropertyToString(csi.Properties))
-}
-
-func chainStateInfoPropertyToString(p *Properties) string {
-	buf := bytes.NewBufferString("Members: ")
-	for _, member := range p.Members {
-		buf.WriteString(common.PKIidType(member).String() + ",")
-	}
-
-	buf.WriteString("Files: ")
-	for _, file := range p.Files {
-		buf.WriteString(fmt.Sprintf("Path-%s, Mode: %s;", file.Path, File_Mode_name[int32(file.Mode)]))
-	}
-
-	return buf.String()
-}
-
-func aliveMessageToString(am *AliveMessage) string {
-	if am.Membership == nil {
-		return "nil membership"
-	}
-	var si string
-	serializeIdentity := &SerializedIdentity{}
-	if err := proto.Unmarshal(am.Identity, serializeIdentity); err == nil {
-		si = serializeIdentity.NodeId + string(serializeIdentity.IdBytes)
-	}
-	return fmt.Sprintf("Alive message: %s, Identity: %s, Timestamp: %s", membershipToString(am.Membership), si, am.Timestamp)
-}
-
-func membershipToString(m *Member) string {
-	return fmt.Sprintf("Membership: Endpoint: %s, PKI-id: %s", m.Endpoint, m.PkiId)
+// Format prints the string representation of the SignedRKSyncMessage whatever the verb,
+// rather than the one of the RKSyncMessage it embeds
+func (m *SignedRKSyncMessage) Format(s fmt.State, verb rune) {
+	io.WriteString(s, m.String())
 }
 
 // ConnectionInfo represents information about the remote peer
 type ConnectionInfo struct {
-	ID       common.PKIidType
-	Identity common.PeerIdentityType
-	Endpoint string
+	ID              common.PKIidType
+	Identity        common.PeerIdentityType
+	Endpoint        string
+	ProtocolVersion uint32 // Version of the protocol agreed on with the peer during the handshake
 }
 
 // String returns a string representation of this ConnectionInfo
@@ -187,10 +222,10 @@ func (c *ConnectionInfo) String() string {
 	return fmt.Sprintf("%s %v", c.Endpoint, c.ID)
 }
 
-// Verifier receives a peer identity, a signature and a message
-// and returns nil if the signature on the message could be verified
-// using the given identity
-type Verifier func(peerIdentity []byte, signature, message []byte) error
+// Verifier receives a peer identity, the algorithm recorded along with the signature,
+// a signature and a message and returns nil if the signature on the message could be
+// verified using the given identity
+type Verifier func(peerIdentity []byte, algorithm SignatureAlgorithm, signature, message []byte) error
 
 // Signer signs a message, and returns (signature, nil)
 // on success, and nil and an error on failure
@@ -204,7 +239,7 @@ func (e *Envelope) ToRKSyncMessage() (*SignedRKSyncMessage, error) {
 	}
 
 	msg := &RKSyncMessage{}
-	err := proto.Unmarshal(e.Payload, msg)
+	err := unmarshalMessage(e.Payload, msg)
 	if err != nil {
 		return nil, errors.Errorf("Failed unmarshaling GossipMessage from envelope: %v", err)
 	}
@@ -215,10 +250,124 @@ func (e *Envelope) ToRKSyncMessage() (*SignedRKSyncMessage, error) {
 	}, nil
 }
 
+// SignSecret signs the secret and attaches it to the envelope
+func (e *Envelope) SignSecret(signer Signer, secret *Secret) error {
+	payload, err := proto.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	sig, err := signer(payload)
+	if err != nil {
+		return err
+	}
+
+	e.SecretEnvelope = &SecretEnvelope{
+		Payload:   payload,
+		Signature: sig,
+	}
+	return nil
+}
+
+// WithoutSecret returns a copy of the envelope without its secret envelope
+func (e *Envelope) WithoutSecret() *Envelope {
+	return &Envelope{
+		Payload:            e.Payload,
+		Signature:          e.Signature,
+		SignatureAlgorithm: e.SignatureAlgorithm,
+		HopsLeft:           e.HopsLeft,
+	}
+}
+
+// NextHop returns the envelope to be forwarded to the next peers,
+// or false if the envelope has reached its hop limit.
+// The hop count isn't signed, it's clamped to the max number of hops of the
+// message type first so that a relaying peer can't raise or remove the limit.
+func (e *Envelope) NextHop(maxHops uint32) (*Envelope, bool) {
+	hops := e.HopsLeft
+	if hops > maxHops {
+		hops = maxHops
+	}
+	if hops <= 1 {
+		return nil, false
+	}
+
+	return e.WithHopsLeft(hops - 1), true
+}
+
+// WithHopsLeft returns a copy of the envelope which may be forwarded the given number of times,
+// the copy shares the payload and the signatures of the envelope
+func (e *Envelope) WithHopsLeft(hops uint32) *Envelope {
+	return &Envelope{
+		Payload:            e.Payload,
+		Signature:          e.Signature,
+		SignatureAlgorithm: e.SignatureAlgorithm,
+		SecretEnvelope:     e.SecretEnvelope,
+		HopsLeft:           hops,
+	}
+}
+
+// MarshaledEnvelope is an envelope marshaled once, which is sent to several peers
+// without being marshaled again by the stream of each of them
+type MarshaledEnvelope struct {
+	raw []byte
+}
+
+// Marshaled returns the envelope marshaled once for all
+func (e *Envelope) Marshaled() (*MarshaledEnvelope, error) {
+	raw, err := e.Marshal()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &MarshaledEnvelope{raw: raw}, nil
+}
+
+// Marshal returns the bytes of the envelope, the gRPC codec sends them as is
+func (m *MarshaledEnvelope) Marshal() ([]byte, error) {
+	return m.raw, nil
+}
+
+// Size returns the number of bytes of the envelope
+func (m *MarshaledEnvelope) Size() int {
+	return len(m.raw)
+}
+
+// Reset implements proto.Message, the envelope is immutable
+func (m *MarshaledEnvelope) Reset() {}
+
+// String returns a string representation of a MarshaledEnvelope
+func (m *MarshaledEnvelope) String() string {
+	return fmt.Sprintf("MarshaledEnvelope{%d bytes}", len(m.raw))
+}
+
+// ProtoMessage implements proto.Message
+func (m *MarshaledEnvelope) ProtoMessage() {}
+
+// InternalEndpoint returns the internal endpoint carried
+// in the secret envelope, or an empty string if there is none
+func (s *SecretEnvelope) InternalEndpoint() string {
+	if s == nil {
+		return ""
+	}
+
+	secret := &Secret{}
+	if err := proto.Unmarshal(s.Payload, secret); err != nil {
+		return ""
+	}
+	return secret.GetInternalEndpoint()
+}
+
 // Sign signs a RKSyncMessage with given Signer.
 func (m *SignedRKSyncMessage) Sign(signer Signer) (*Envelope, error) {
+	return m.SignWithAlgorithm(SignatureAlgorithm_UNSPECIFIED, signer)
+}
+
+// SignWithAlgorithm stamps a RKSyncMessage with the current time, signs it
+// with given Signer and records the signature algorithm in the envelope.
+func (m *SignedRKSyncMessage) SignWithAlgorithm(algo SignatureAlgorithm, signer Signer) (*Envelope, error) {
 	m.Envelope = nil
-	payload, err := proto.Marshal(m.RKSyncMessage)
+	m.Timestamp = time.Now().UnixNano()
+	payload, err := marshalMessage(m.RKSyncMessage)
 	if err != nil {
 		return nil, err
 	}
@@ -229,14 +378,15 @@ func (m *SignedRKSyncMessage) Sign(signer Signer) (*Envelope, error) {
 	}
 
 	e := &Envelope{
-		Payload:   payload,
-		Signature: sig,
+		Payload:            payload,
+		Signature:          sig,
+		SignatureAlgorithm: algo,
 	}
 	m.Envelope = e
 	return e, nil
 }
 
-// Verify verifies a signed RKSyncMessage with a given Verifier.
+// Verify verifies a signed RKSyncMessage with a given Verifier, along with the algorithm recorded in the envelope.
 func (m *SignedRKSyncMessage) Verify(peerIdentity []byte, verify Verifier) error {
 	if m.Envelope == nil {
 		return errors.New("Missing envelope")
@@ -248,10 +398,19 @@ func (m *SignedRKSyncMessage) Verify(peerIdentity []byte, verify Verifier) error
 		return errors.New("Empty signature")
 	}
 
-	payloadSigVerificationErr := verify(peerIdentity, m.Envelope.Signature, m.Envelope.Payload)
+	payloadSigVerificationErr := verify(peerIdentity, m.Envelope.SignatureAlgorithm, m.Envelope.Signature, m.Envelope.Payload)
 	if payloadSigVerificationErr != nil {
 		return payloadSigVerificationErr
 	}
+
+	if se := m.Envelope.SecretEnvelope; se != nil {
+		if len(se.Payload) == 0 || len(se.Signature) == 0 {
+			return errors.New("Secret envelope is missing payload or signature")
+		}
+		if err := verify(peerIdentity, SignatureAlgorithm_UNSPECIFIED, se.Signature, se.Payload); err != nil {
+			return errors.WithMessage(err, "Failed verifying secret envelope")
+		}
+	}
 	return nil
 }
 
@@ -320,15 +479,190 @@ func (m *RKSyncMessage) IsLeaveChain() bool {
 	return m.GetLeaveChain() != nil
 }
 
+// ToChangeRecord unmarshals the ChangeRecord signed in a given envelope
+func (e *Envelope) ToChangeRecord() (*ChangeRecord, error) {
+	if e == nil {
+		return nil, errors.New("nil envelope")
+	}
+
+	record := &ChangeRecord{}
+	if err := proto.Unmarshal(e.Payload, record); err != nil {
+		return nil, errors.Errorf("Failed unmarshaling ChangeRecord from envelope: %v", err)
+	}
+	return record, nil
+}
+
+// IsChangeLogReq returns whether RKSyncMessage is a request for the records of a change log
+func (m *RKSyncMessage) IsChangeLogReq() bool {
+	return m.GetChangeLogReq() != nil
+}
+
+// IsChangeLogRes returns whether RKSyncMessage carries records of a change log
+func (m *RKSyncMessage) IsChangeLogRes() bool {
+	return m.GetChangeLogRes() != nil
+}
+
+// IsStateReq returns whether RKSyncMessage is a request for the chain state and the files of a channel
+func (m *RKSyncMessage) IsStateReq() bool {
+	return m.GetStateReq() != nil
+}
+
+// IsStateRes returns whether RKSyncMessage carries the chain state and the files of a channel
+func (m *RKSyncMessage) IsStateRes() bool {
+	return m.GetStateRes() != nil
+}
+
+// IsLeadershipDeclaration returns whether RKSyncMessage declares a member the leader of a channel
+func (m *RKSyncMessage) IsLeadershipDeclaration() bool {
+	return m.GetLeadershipDeclaration() != nil
+}
+
+// IsLeadershipVote returns whether RKSyncMessage is a vote for the candidate to the leadership of a channel
+func (m *RKSyncMessage) IsLeadershipVote() bool {
+	return m.GetLeadershipVote() != nil
+}
+
+// IsFileAck returns whether RKSyncMessage acknowledges that the copy of a file of a member is synchronized
+func (m *RKSyncMessage) IsFileAck() bool {
+	return m.GetFileAck() != nil
+}
+
+// IsIdentityReq returns whether RKSyncMessage asks for the identities of peers
+func (m *RKSyncMessage) IsIdentityReq() bool {
+	return m.GetIdentityReq() != nil
+}
+
+// IsIdentityRes returns whether RKSyncMessage carries the identities of peers
+func (m *RKSyncMessage) IsIdentityRes() bool {
+	return m.GetIdentityRes() != nil
+}
+
+// IsChannelInvitation returns whether RKSyncMessage invites a peer to become a member of a channel
+func (m *RKSyncMessage) IsChannelInvitation() bool {
+	return m.GetChannelInvitation() != nil
+}
+
+// IsChannelAcceptance returns whether RKSyncMessage answers the invitation to become a member of a channel
+func (m *RKSyncMessage) IsChannelAcceptance() bool {
+	return m.GetChannelAcceptance() != nil
+}
+
+// ContentType returns the name of the content of the RKSyncMessage, e.g. "alive_msg", as declared in rksync.proto
+func (m *RKSyncMessage) ContentType() string {
+	switch m.Content.(type) {
+	case *RKSyncMessage_AliveMsg:
+		return "alive_msg"
+	case *RKSyncMessage_Empty:
+		return "empty"
+	case *RKSyncMessage_Conn:
+		return "conn"
+	case *RKSyncMessage_Ack:
+		return "ack"
+	case *RKSyncMessage_MemReq:
+		return "mem_req"
+	case *RKSyncMessage_MemRes:
+		return "mem_res"
+	case *RKSyncMessage_State:
+		return "state"
+	case *RKSyncMessage_StatePullRequest:
+		return "state_pull_request"
+	case *RKSyncMessage_StatePullResponse:
+		return "state_pull_response"
+	case *RKSyncMessage_StateInfo:
+		return "state_info"
+	case *RKSyncMessage_DataMsg:
+		return "data_msg"
+	case *RKSyncMessage_DataReq:
+		return "data_req"
+	case *RKSyncMessage_LeaveChain:
+		return "leave_chain"
+	case *RKSyncMessage_ChangeLogReq:
+		return "change_log_req"
+	case *RKSyncMessage_ChangeLogRes:
+		return "change_log_res"
+	case *RKSyncMessage_StateReq:
+		return "state_req"
+	case *RKSyncMessage_StateRes:
+		return "state_res"
+	case *RKSyncMessage_LeadershipDeclaration:
+		return "leadership_declaration"
+	case *RKSyncMessage_LeadershipVote:
+		return "leadership_vote"
+	case *RKSyncMessage_FileAck:
+		return "file_ack"
+	case *RKSyncMessage_IdentityReq:
+		return "identity_req"
+	case *RKSyncMessage_IdentityRes:
+		return "identity_res"
+	case *RKSyncMessage_ChannelInvitation:
+		return "channel_invitation"
+	case *RKSyncMessage_ChannelAcceptance:
+		return "channel_acceptance"
+	}
+	return "unknown"
+}
+
+// Versions of the gossip protocol. The peers agree on the highest version both of them speak
+// during the handshake, the messages introduced by a later version aren't sent to the peer.
+const (
+	// ProtocolVersionLegacy is spoken by the peers which predate the negotiation
+	ProtocolVersionLegacy uint32 = 0
+	// ProtocolVersionState introduces the state and change log requests
+	ProtocolVersionState uint32 = 1
+	// ProtocolVersionLeadership introduces the leadership declarations and votes
+	ProtocolVersionLeadership uint32 = 2
+	// ProtocolVersionFileAck introduces the acknowledgements of the synchronized files
+	ProtocolVersionFileAck uint32 = 3
+	// ProtocolVersionIdentity introduces the requests for the identities of the peers
+	ProtocolVersionIdentity uint32 = 4
+	// ProtocolVersionInvitation introduces the invitations to join the channels
+	ProtocolVersionInvitation uint32 = 5
+	// ProtocolVersion is spoken by this release
+	ProtocolVersion = ProtocolVersionInvitation
+)
+
+// ProtocolVersion returns the version of the protocol which introduced the RKSyncMessage,
+// the peers speaking an older version don't understand it
+func (m *RKSyncMessage) ProtocolVersion() uint32 {
+	if m.IsChannelInvitation() || m.IsChannelAcceptance() {
+		return ProtocolVersionInvitation
+	}
+	if m.IsIdentityReq() || m.IsIdentityRes() {
+		return ProtocolVersionIdentity
+	}
+	if m.IsFileAck() {
+		return ProtocolVersionFileAck
+	}
+	if m.IsLeadershipDeclaration() || m.IsLeadershipVote() {
+		return ProtocolVersionLeadership
+	}
+	if m.IsChangeLogReq() || m.IsChangeLogRes() || m.IsStateReq() || m.IsStateRes() {
+		return ProtocolVersionState
+	}
+	return ProtocolVersionLegacy
+}
+
+// Priority returns the priority the RKSyncMessage is sent with.
+// File data and requests for it are of low priority, so they
+// never delay the messages that keep the membership and the chain states up to date.
+func (m *RKSyncMessage) Priority() common.MessagePriority {
+	if m.IsDataMsg() || m.IsDataReq() {
+		return common.LowPriority
+	}
+	return common.HighPriority
+}
+
 // IsTagLegal checks the RKSyncMessage tags and inner type
 func (m *RKSyncMessage) IsTagLegal() error {
-	if m.IsAliveMsg() || m.GetMemReq() != nil || m.GetMemRes() != nil {
+	if m.IsAliveMsg() || m.GetMemReq() != nil || m.GetMemRes() != nil || m.IsIdentityReq() || m.IsIdentityRes() {
 		if m.Tag != RKSyncMessage_EMPTY {
 			return fmt.Errorf("Tag should be %s", RKSyncMessage_Tag_name[int32(RKSyncMessage_EMPTY)])
 		}
 		return nil
 	}
-	if m.IsDataMsg() || m.IsDataReq() || m.IsChainStateMsg() || m.IsStatePullRequestMsg() || m.IsStatePullResponseMsg() || m.IsLeaveChain() {
+	if m.IsDataMsg() || m.IsDataReq() || m.IsChainStateMsg() || m.IsStatePullRequestMsg() || m.IsStatePullResponseMsg() || m.IsLeaveChain() ||
+		m.IsChangeLogReq() || m.IsChangeLogRes() || m.IsStateReq() || m.IsStateRes() || m.IsLeadershipDeclaration() || m.IsLeadershipVote() ||
+		m.IsFileAck() || m.IsChannelInvitation() || m.IsChannelAcceptance() {
 		if m.Tag != RKSyncMessage_CHAN_ONLY {
 			return fmt.Errorf("Tag should be %s", RKSyncMessage_Tag_name[int32(RKSyncMessage_CHAN_ONLY)])
 		}
@@ -356,8 +690,40 @@ func (m *ChainState) GetChainStateInfo() (*ChainStateInfo, error) {
 	return msg.GetStateInfo(), nil
 }
 
+// Epoch returns the epoch of the ChainStateInfo signed by the leader, 0 if it can't be unmarshaled
+func (m *ChainState) Epoch() uint64 {
+	info, err := m.GetChainStateInfo()
+	if err != nil {
+		return 0
+	}
+	return info.Epoch
+}
+
+// SameState returns whether both chain states are the same, regardless of the heartbeats of the leader
+func (m *ChainState) SameState(other *ChainState) bool {
+	if m.SeqNum != other.SeqNum || m.ChainId != other.ChainId || (m.Envelope == nil) != (other.Envelope == nil) {
+		return false
+	}
+	return m.Envelope == nil || bytes.Equal(m.Envelope.Payload, other.Envelope.Payload)
+}
+
+// After returns whether the time is later than the other one, the times
+// of a later incarnation of the peer are later whatever their sequence number
+func (t *PeerTime) After(other *PeerTime) bool {
+	if t.IncNum != other.IncNum {
+		return t.IncNum > other.IncNum
+	}
+	return t.SeqNum > other.SeqNum
+}
+
 // Sign signs a ChainStateInfo with given Signer.
 func (si *ChainStateInfo) Sign(signer Signer) (*Envelope, error) {
+	return si.SignWithAlgorithm(SignatureAlgorithm_UNSPECIFIED, signer)
+}
+
+// SignWithAlgorithm signs a ChainStateInfo with given Signer and records
+// the signature algorithm in the envelope.
+func (si *ChainStateInfo) SignWithAlgorithm(algo SignatureAlgorithm, signer Signer) (*Envelope, error) {
 	payload, err := proto.Marshal(si)
 	if err != nil {
 		return nil, err
@@ -369,8 +735,9 @@ func (si *ChainStateInfo) Sign(signer Signer) (*Envelope, error) {
 	}
 
 	e := &Envelope{
-		Payload:   payload,
-		Signature: sig,
+		Payload:            payload,
+		Signature:          sig,
+		SignatureAlgorithm: algo,
 	}
 
 	return e, nil
@@ -385,3 +752,8 @@ func (p *Payload) IsAppend() bool {
 func (r *DataRequest) IsAppend() bool {
 	return r.GetAppend() != nil
 }
+
+// IsRejection returns whether this DataRequest rejects the transfer of the file
+func (r *DataRequest) IsRejection() bool {
+	return r.GetRejection() != nil
+}
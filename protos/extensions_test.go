@@ -0,0 +1,136 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package protos
+
+import (
+	"testing"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeadershipInvalidationPolicy(t *testing.T) {
+	declaration := func(mac string, pkiID string, term uint64, seq uint64) *SignedRKSyncMessage {
+		msg, err := (&RKSyncMessage{
+			ChainMac: []byte(mac),
+			Tag:      RKSyncMessage_CHAN_ONLY,
+			Content: &RKSyncMessage_LeadershipDeclaration{LeadershipDeclaration: &LeadershipDeclaration{
+				PkiId:     []byte(pkiID),
+				Term:      term,
+				Timestamp: &PeerTime{IncNum: 1, SeqNum: seq},
+			}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return msg
+	}
+	comparator := NewRKSyncMessageComparator()
+
+	// the highest term survives whoever declares it
+	assert.Equal(t, common.MessageInvalidates, comparator(declaration("c1", "p2", 3, 1), declaration("c1", "p1", 2, 5)))
+	assert.Equal(t, common.MessageInvalidated, comparator(declaration("c1", "p1", 2, 5), declaration("c1", "p2", 3, 1)))
+	// the candidates of the same term are ordered by their PKI-IDs
+	assert.Equal(t, common.MessageInvalidates, comparator(declaration("c1", "p1", 3, 1), declaration("c1", "p2", 3, 1)))
+	assert.Equal(t, common.MessageInvalidated, comparator(declaration("c1", "p2", 3, 1), declaration("c1", "p1", 3, 1)))
+	// the same declaration published again
+	assert.Equal(t, common.MessageInvalidates, comparator(declaration("c1", "p1", 3, 2), declaration("c1", "p1", 3, 1)))
+	// the declarations of other channels are unrelated
+	assert.Equal(t, common.MessageNoAction, comparator(declaration("c1", "p1", 3, 1), declaration("c2", "p1", 2, 1)))
+
+	vote := func(voter string, term uint64) *SignedRKSyncMessage {
+		msg, err := (&RKSyncMessage{
+			ChainMac: []byte("c1"),
+			Tag:      RKSyncMessage_CHAN_ONLY,
+			Content:  &RKSyncMessage_LeadershipVote{LeadershipVote: &LeadershipVote{Candidate: []byte("p1"), Voter: []byte(voter), Term: term, Granted: true}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return msg
+	}
+	assert.Equal(t, common.MessageInvalidates, comparator(vote("p2", 4), vote("p2", 3)))
+	assert.Equal(t, common.MessageInvalidated, comparator(vote("p2", 3), vote("p2", 4)))
+	assert.Equal(t, common.MessageNoAction, comparator(vote("p2", 3), vote("p3", 4)))
+
+	decl := declaration("c1", "p1", 3, 1)
+	assert.NoError(t, decl.IsTagLegal())
+	assert.Equal(t, ProtocolVersionLeadership, decl.ProtocolVersion())
+	assert.Equal(t, "leadership_declaration", decl.ContentType())
+	assert.Equal(t, "leadership_vote", vote("p2", 3).ContentType())
+	assert.Equal(t, "Leadership declaration: PKI-ID: 7031, Term: 3, Epoch: 0", contentToString(decl.RKSyncMessage))
+}
+
+func TestStateInvalidationPolicy(t *testing.T) {
+	state := func(epoch uint64, seq uint64) *SignedRKSyncMessage {
+		info, err := (&RKSyncMessage{
+			ChainMac: []byte("c1"),
+			Tag:      RKSyncMessage_CHAN_ONLY,
+			Content:  &RKSyncMessage_StateInfo{StateInfo: &ChainStateInfo{Leader: []byte("p1"), Epoch: epoch}},
+		}).NoopSign()
+		require.NoError(t, err)
+		msg, err := (&RKSyncMessage{
+			ChainMac: []byte("c1"),
+			Tag:      RKSyncMessage_CHAN_ONLY,
+			Content:  &RKSyncMessage_State{State: &ChainState{ChainId: "c1", SeqNum: seq, Envelope: info.Envelope}},
+		}).NoopSign()
+		require.NoError(t, err)
+		return msg
+	}
+	comparator := NewRKSyncMessageComparator()
+
+	// the epoch signed by the leader prevails over the sequence number
+	newer, older := state(3, 1), state(2, 5)
+	assert.Equal(t, common.MessageInvalidates, comparator(newer, older))
+	assert.Equal(t, common.MessageInvalidated, comparator(older, newer))
+	assert.Equal(t, common.MessageInvalidates, comparator(state(3, 2), state(3, 1)))
+	assert.Equal(t, uint64(0), (&SignedRKSyncMessage{RKSyncMessage: &RKSyncMessage{}}).StateEpoch())
+
+	// the epoch is only unmarshaled once
+	newer.GetState().Envelope = &Envelope{Payload: []byte("garbage")}
+	assert.Equal(t, uint64(3), newer.StateEpoch())
+	assert.Equal(t, common.MessageInvalidates, comparator(newer, older))
+}
+
+func TestIdentityMessages(t *testing.T) {
+	req := &RKSyncMessage{
+		Tag:     RKSyncMessage_EMPTY,
+		Content: &RKSyncMessage_IdentityReq{IdentityReq: &PeerIdentityRequest{PkiIds: [][]byte{[]byte("p1"), []byte("p2")}}},
+	}
+	assert.NoError(t, req.IsTagLegal())
+	assert.Equal(t, ProtocolVersionIdentity, req.ProtocolVersion())
+	assert.Equal(t, "identity_req", req.ContentType())
+	assert.Equal(t, "Identity request: 2 PKI-IDs", contentToString(req))
+
+	res := &RKSyncMessage{
+		Tag:     RKSyncMessage_CHAN_ONLY,
+		Content: &RKSyncMessage_IdentityRes{IdentityRes: &PeerIdentityResponse{Identities: []*PeerIdentity{{PkiId: []byte("p1"), Identity: []byte("cert")}}}},
+	}
+	assert.Error(t, res.IsTagLegal())
+	assert.Equal(t, ProtocolVersionIdentity, res.ProtocolVersion())
+	assert.Equal(t, "identity_res", res.ContentType())
+	assert.Equal(t, "Identity response: 1 identities", contentToString(res))
+}
+
+func TestChannelInvitationMessages(t *testing.T) {
+	inv := &RKSyncMessage{
+		ChainMac: []byte("c1"),
+		Tag:      RKSyncMessage_CHAN_ONLY,
+		Content:  &RKSyncMessage_ChannelInvitation{ChannelInvitation: &ChannelInvitation{ChainId: "c1", Member: []byte("p1"), Expires: 0}},
+	}
+	assert.NoError(t, inv.IsTagLegal())
+	assert.Equal(t, ProtocolVersionInvitation, inv.ProtocolVersion())
+	assert.Equal(t, "channel_invitation", inv.ContentType())
+	assert.Equal(t, "Channel invitation: Channel: c1, Member: 7031, Expires: 1970-01-01T00:00:00Z", contentToString(inv))
+
+	acc := &RKSyncMessage{
+		ChainMac: []byte("c1"),
+		Tag:      RKSyncMessage_CHAN_ONLY,
+		Content:  &RKSyncMessage_ChannelAcceptance{ChannelAcceptance: &ChannelAcceptance{Member: []byte("p1"), Accepted: true}},
+	}
+	assert.NoError(t, acc.IsTagLegal())
+	assert.Equal(t, ProtocolVersionInvitation, acc.ProtocolVersion())
+	assert.Equal(t, "channel_acceptance", acc.ContentType())
+	assert.Equal(t, "Channel acceptance: Member: 7031, Accepted: true", contentToString(acc))
+}
@@ -0,0 +1,285 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package protos
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxPrintedBytes is the number of bytes of a hash or a signature printed in hex, the others are elided
+const maxPrintedBytes = 8
+
+// Format prints a human-readable representation of the RKSyncMessage whatever the verb,
+// the PKI-IDs and the MACs are printed in hex and the payloads are elided.
+// String returns the protobuf text format of the message.
+func (m *RKSyncMessage) Format(s fmt.State, verb rune) {
+	io.WriteString(s, messageToString(m))
+}
+
+// Format prints a human-readable representation of the ChainState whatever the verb,
+// along with the ChainStateInfo it carries
+func (m *ChainState) Format(s fmt.State, verb rune) {
+	io.WriteString(s, chainStateToString(m))
+}
+
+// Format prints a human-readable representation of the Member whatever the verb, its PKI-ID in hex
+func (m *Member) Format(s fmt.State, verb rune) {
+	io.WriteString(s, memberToString(m))
+}
+
+func messageToString(m *RKSyncMessage) string {
+	if m == nil {
+		return "<nil>"
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf("Nonce: %d, Tag: %s", m.Nonce, m.Tag))
+	if len(m.ChainMac) > 0 {
+		buf.WriteString(", Channel MAC: " + common.ChainMac(m.ChainMac).String())
+	}
+	if m.Timestamp != 0 {
+		buf.WriteString(", Timestamp: " + time.Unix(0, m.Timestamp).UTC().Format(time.RFC3339Nano))
+	}
+	buf.WriteString(", " + contentToString(m))
+	return buf.String()
+}
+
+func contentToString(m *RKSyncMessage) string {
+	switch {
+	case m.IsAliveMsg():
+		return aliveMessageToString(m.GetAliveMsg())
+	case m.GetEmpty() != nil:
+		return "Empty"
+	case m.GetConn() != nil:
+		conn := m.GetConn()
+		return fmt.Sprintf("Connection: PKI-ID: %s, Identity: %s, Protocol versions: %d to %d",
+			common.PKIidType(conn.PkiId), identityToString(conn.Identity), conn.MinProtocolVersion, conn.ProtocolVersion)
+	case m.IsAck():
+		if m.GetAck().Error != "" {
+			return "Ack: " + m.GetAck().Error
+		}
+		return "Ack"
+	case m.GetMemReq() != nil:
+		return fmt.Sprintf("Membership request: %d known peers, %d digests", len(m.GetMemReq().Known), len(m.GetMemReq().Digests))
+	case m.GetMemRes() != nil:
+		return fmt.Sprintf("Membership response: %d alive, %d dead, %d wanted", len(m.GetMemRes().Alive), len(m.GetMemRes().Dead), len(m.GetMemRes().Wanted))
+	case m.IsChainStateMsg():
+		return "ChainState: " + chainStateToString(m.GetState())
+	case m.IsStatePullRequestMsg():
+		return fmt.Sprintf("ChainState pull request: Sequence: %d, Epoch: %d", m.GetStatePullRequest().SeqNum, m.GetStatePullRequest().Epoch)
+	case m.IsStatePullResponseMsg():
+		return "ChainState pull response: " + envelopeToString(m.GetStatePullResponse().Element)
+	case m.IsStateInfoMsg():
+		return "ChainStateInfo: " + chainStateInfoToString(m.GetStateInfo())
+	case m.IsDataMsg():
+		return "Data: " + dataMessageToString(m.GetDataMsg())
+	case m.IsDataReq():
+		return "Data request: " + dataRequestToString(m.GetDataReq())
+	case m.IsLeaveChain():
+		return "Leave chain: Channel MAC: " + common.ChainMac(m.GetLeaveChain().ChainMac).String()
+	case m.IsChangeLogReq():
+		return fmt.Sprintf("Change log request: From: %d", m.GetChangeLogReq().FromIndex)
+	case m.IsChangeLogRes():
+		return fmt.Sprintf("Change log response: %d records", len(m.GetChangeLogRes().Records))
+	case m.IsStateReq():
+		return fmt.Sprintf("State request: Sequence: %d, Manifest from: %q", m.GetStateReq().SeqNum, m.GetStateReq().ManifestFrom)
+	case m.IsStateRes():
+		res := m.GetStateRes()
+		return fmt.Sprintf("State response: Element: %s, %d manifest entries, Next: %q", envelopeToString(res.Element), len(res.Manifest), res.Next)
+	case m.IsLeadershipDeclaration():
+		decl := m.GetLeadershipDeclaration()
+		return fmt.Sprintf("Leadership declaration: PKI-ID: %s, Term: %d, Epoch: %d", common.PKIidType(decl.PkiId), decl.Term, decl.Epoch)
+	case m.IsLeadershipVote():
+		vote := m.GetLeadershipVote()
+		return fmt.Sprintf("Leadership vote: Candidate: %s, Voter: %s, Term: %d, Granted: %t",
+			common.PKIidType(vote.Candidate), common.PKIidType(vote.Voter), vote.Term, vote.Granted)
+	case m.IsFileAck():
+		ack := m.GetFileAck()
+		return fmt.Sprintf("File ack: File: %s, %d bytes, Version: %d, Digest: %s", ack.FileName, ack.Size, ack.Version, truncatedHex(ack.Digest))
+	case m.IsIdentityReq():
+		return fmt.Sprintf("Identity request: %d PKI-IDs", len(m.GetIdentityReq().PkiIds))
+	case m.IsIdentityRes():
+		return fmt.Sprintf("Identity response: %d identities", len(m.GetIdentityRes().Identities))
+	case m.IsChannelInvitation():
+		inv := m.GetChannelInvitation()
+		return fmt.Sprintf("Channel invitation: Channel: %s, Member: %s, Expires: %s", inv.ChainId, common.PKIidType(inv.Member), time.Unix(0, inv.Expires).UTC().Format(time.RFC3339))
+	case m.IsChannelAcceptance():
+		acc := m.GetChannelAcceptance()
+		return fmt.Sprintf("Channel acceptance: Member: %s, Accepted: %t", common.PKIidType(acc.Member), acc.Accepted)
+	}
+	return "No content"
+}
+
+func chainStateToString(m *ChainState) string {
+	if m == nil {
+		return "<nil>"
+	}
+
+	str := fmt.Sprintf("Channel: %s, Sequence: %d", m.ChainId, m.SeqNum)
+	if m.Heartbeat != nil {
+		str = fmt.Sprintf("%s, Heartbeat: %d/%d", str, m.Heartbeat.IncNum, m.Heartbeat.SeqNum)
+	}
+	if info, err := m.GetChainStateInfo(); err == nil {
+		str = fmt.Sprintf("%s, %s", str, chainStateInfoToString(info))
+	} else {
+		str = fmt.Sprintf("%s, Envelope: %s", str, envelopeToString(m.Envelope))
+	}
+	if m.Change != nil {
+		str = fmt.Sprintf("%s, Change: %s", str, envelopeToString(m.Change))
+	}
+	return str
+}
+
+func memberToString(m *Member) string {
+	if m == nil {
+		return "<nil>"
+	}
+
+	str := fmt.Sprintf("Endpoint: %s, PKI-ID: %s", m.Endpoint, common.PKIidType(m.PkiId))
+	if len(m.Tags) > 0 {
+		str = fmt.Sprintf("%s, Tags: %s", str, strings.Join(m.Tags, ","))
+	}
+	if m.Zone != "" {
+		str = fmt.Sprintf("%s, Zone: %s", str, m.Zone)
+	}
+	if m.Org != "" {
+		str = fmt.Sprintf("%s, Org: %s", str, m.Org)
+	}
+	if len(m.Labels) > 0 {
+		labels := make([]string, 0, len(m.Labels))
+		for key, value := range m.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		str = fmt.Sprintf("%s, Labels: %s", str, strings.Join(labels, ","))
+	}
+	return str
+}
+
+func chainStateInfoToString(csi *ChainStateInfo) string {
+	str := fmt.Sprintf("Leader: %s, Epoch: %d", common.PKIidType(csi.Leader), csi.Epoch)
+	if csi.Properties == nil {
+		return str
+	}
+
+	members := make([]string, len(csi.Properties.Members))
+	for i, member := range csi.Properties.Members {
+		members[i] = common.PKIidType(member).String()
+	}
+	files := make([]string, len(csi.Properties.Files))
+	for i, file := range csi.Properties.Files {
+		files[i] = fmt.Sprintf("%s (%s, version %d)", file.Path, file.Mode, file.Version)
+	}
+	return fmt.Sprintf("%s, Members: [%s], Files: [%s]", str, strings.Join(members, " "), strings.Join(files, " "))
+}
+
+func aliveMessageToString(am *AliveMessage) string {
+	if am.Membership == nil {
+		return "Alive message: nil membership"
+	}
+	str := fmt.Sprintf("Alive message: %s, Identity: %s", memberToString(am.Membership), identityToString(am.Identity))
+	if am.Timestamp != nil {
+		str = fmt.Sprintf("%s, Timestamp: %d/%d", str, am.Timestamp.IncNum, am.Timestamp.SeqNum)
+	}
+	if am.Leaving {
+		str += ", Leaving"
+	}
+	return str
+}
+
+func dataMessageToString(dm *DataMessage) string {
+	if dm.Payload == nil {
+		return fmt.Sprintf("File: %s, No payload", dm.FileName)
+	}
+	str := fmt.Sprintf("File: %s, %d bytes", dm.FileName, len(dm.Payload.Data))
+	if meta := dm.Payload.GetAppend(); meta != nil {
+		str = fmt.Sprintf("%s, Start: %d, Length: %d, File size: %d", str, meta.Start, meta.Length, meta.FileSize)
+	}
+	return str
+}
+
+func dataRequestToString(req *DataRequest) string {
+	str := fmt.Sprintf("File: %s, PKI-ID: %s", req.FileName, common.PKIidType(req.PkiId))
+	if r := req.GetAppend(); r != nil {
+		str = fmt.Sprintf("%s, Length: %d, Limit: %d", str, r.Length, r.Limit)
+	}
+	if r := req.GetRejection(); r != nil {
+		str = fmt.Sprintf("%s, Rejected: %s, Required: %d, Available: %d", str, r.Limit, r.Required, r.Available)
+	}
+	return str
+}
+
+// identityToString returns the node ID of the serialized identity, the certificate is elided
+func identityToString(identity []byte) string {
+	si := &SerializedIdentity{}
+	if err := proto.Unmarshal(identity, si); err != nil || si.NodeId == "" {
+		return fmt.Sprintf("%d bytes", len(identity))
+	}
+	return si.NodeId
+}
+
+func envelopeToString(e *Envelope) string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%d bytes, Signature: %s", len(e.Payload), truncatedHex(e.Signature))
+}
+
+// truncatedHex returns the first bytes of b in hex, followed by its length if some are elided
+func truncatedHex(b []byte) string {
+	if len(b) <= maxPrintedBytes {
+		return hex.EncodeToString(b)
+	}
+	return fmt.Sprintf("%s... (%d bytes)", hex.EncodeToString(b[:maxPrintedBytes]), len(b))
+}
+
+// MarshalJSON renders the RKSyncMessage in the canonical JSON mapping of protobuf
+func (m *RKSyncMessage) MarshalJSON() ([]byte, error) {
+	return marshalJSON(m)
+}
+
+// UnmarshalJSON parses the canonical JSON mapping of a RKSyncMessage
+func (m *RKSyncMessage) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, m)
+}
+
+// MarshalJSON renders the ChainState in the canonical JSON mapping of protobuf
+func (m *ChainState) MarshalJSON() ([]byte, error) {
+	return marshalJSON(m)
+}
+
+// UnmarshalJSON parses the canonical JSON mapping of a ChainState
+func (m *ChainState) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, m)
+}
+
+// MarshalJSON renders the Member in the canonical JSON mapping of protobuf
+func (m *Member) MarshalJSON() ([]byte, error) {
+	return marshalJSON(m)
+}
+
+// UnmarshalJSON parses the canonical JSON mapping of a Member
+func (m *Member) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, m)
+}
+
+func marshalJSON(msg proto.Message) ([]byte, error) {
+	return protojson.Marshal(msg)
+}
+
+func unmarshalJSON(data []byte, msg proto.Message) error {
+	return protojson.Unmarshal(data, msg)
+}
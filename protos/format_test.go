@@ -0,0 +1,99 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package protos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func chainStateMessage(t testing.TB) *RKSyncMessage {
+	info, err := (&RKSyncMessage{
+		Tag: RKSyncMessage_CHAN_ONLY,
+		Content: &RKSyncMessage_StateInfo{StateInfo: &ChainStateInfo{
+			Leader: []byte{0xab, 0xcd},
+			Epoch:  2,
+			Properties: &Properties{
+				Members: [][]byte{{0xab, 0xcd}, {0x01, 0x02}},
+				Files:   []*File{{Path: "config.yaml", Mode: File_Random, Version: 3}},
+			},
+		}},
+	}).NoopSign()
+	require.NoError(t, err)
+
+	return &RKSyncMessage{
+		Nonce:    7,
+		ChainMac: []byte{0x12, 0x34},
+		Tag:      RKSyncMessage_CHAN_ONLY,
+		Content: &RKSyncMessage_State{State: &ChainState{
+			SeqNum:   5,
+			ChainId:  "testchannel",
+			Envelope: info.Envelope,
+			Change:   &Envelope{Payload: bytes.Repeat([]byte{1}, 100), Signature: bytes.Repeat([]byte{0xff}, 64)},
+		}},
+	}
+}
+
+func TestString(t *testing.T) {
+	str := fmt.Sprint(chainStateMessage(t))
+	assert.Equal(t, "Nonce: 7, Tag: CHAN_ONLY, Channel MAC: 1234, ChainState: Channel: testchannel, Sequence: 5, "+
+		"Leader: abcd, Epoch: 2, Members: [abcd 0102], Files: [config.yaml (Random, version 3)], "+
+		"Change: 100 bytes, Signature: ffffffffffffffff... (64 bytes)", str)
+
+	member := &Member{Endpoint: "peer0:7051", PkiId: []byte{0xab, 0xcd}, Tags: []string{"edge"}}
+	assert.Equal(t, "Endpoint: peer0:7051, PKI-ID: abcd, Tags: edge", fmt.Sprint(member))
+	member.Labels = map[string]string{"version": "1.4.2", "role": "ingest"}
+	assert.Equal(t, "Endpoint: peer0:7051, PKI-ID: abcd, Tags: edge, Labels: role=ingest,version=1.4.2", fmt.Sprint(member))
+
+	data := &RKSyncMessage{Content: &RKSyncMessage_DataMsg{DataMsg: &DataMessage{
+		FileName: "config.yaml",
+		Payload:  &Payload{Data: bytes.Repeat([]byte("x"), 1<<20)},
+	}}}
+	assert.Equal(t, "Nonce: 0, Tag: EMPTY, Data: File: config.yaml, 1048576 bytes", fmt.Sprintf("%v", data))
+
+	var nilMsg *RKSyncMessage
+	assert.Equal(t, "<nil>", fmt.Sprintf("%s", nilMsg))
+
+	// the embedded RKSyncMessage doesn't hide the representation of the SignedRKSyncMessage
+	signed, err := data.NoopSign()
+	require.NoError(t, err)
+	assert.Equal(t, signed.String(), fmt.Sprint(signed))
+	assert.Contains(t, signed.String(), "Data: File: config.yaml, 1048576 bytes, Envelope: ")
+}
+
+func TestJSON(t *testing.T) {
+	for _, msg := range []*RKSyncMessage{
+		chainStateMessage(t),
+		{Nonce: 1, Content: &RKSyncMessage_Empty{Empty: &emptypb.Empty{}}},
+		{Content: &RKSyncMessage_AliveMsg{AliveMsg: &AliveMessage{
+			Membership: &Member{Endpoint: "peer0:7051", PkiId: []byte{0xab}},
+			Timestamp:  &PeerTime{IncNum: 1, SeqNum: 2},
+		}}},
+	} {
+		data, err := json.Marshal(msg)
+		require.NoError(t, err)
+
+		parsed := &RKSyncMessage{}
+		require.NoError(t, json.Unmarshal(data, parsed))
+		assert.Equal(t, fmt.Sprint(msg), fmt.Sprint(parsed))
+		assert.True(t, proto.Equal(msg, parsed), string(data))
+	}
+
+	// the field names follow the canonical JSON mapping
+	data, err := json.Marshal(&Member{Endpoint: "peer0:7051", PkiId: []byte{0xab}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"endpoint": "peer0:7051", "pkiId": "qw=="}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"unknown": 1}`), &ChainState{}))
+}
@@ -0,0 +1,333 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// protoc-gen-grpc generates the gRPC services of the protos into _grpc.pb.go files, along with
+// the messages protoc-gen-go generates. The services are generated for the google.golang.org/grpc
+// release the module requires, which predates grpc.ClientConnInterface, the same way as the
+// plugins=grpc option of github.com/golang/protobuf v1.3 did.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	grpcPackage    = protogen.GoImportPath("google.golang.org/grpc")
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if f.Generate && len(f.Services) > 0 {
+				generateFile(gen, f)
+			}
+		}
+		return nil
+	})
+}
+
+func generateFile(gen *protogen.Plugin, file *protogen.File) {
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_grpc.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-grpc. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	g.P("// This is a compile-time assertion to ensure that this generated file")
+	g.P("// is compatible with the grpc package it is being compiled against.")
+	g.P("const _ = ", grpcPackage.Ident("SupportPackageIsVersion4"))
+	g.P()
+	for _, service := range file.Services {
+		genService(g, file, service)
+	}
+}
+
+func genService(g *protogen.GeneratedFile, file *protogen.File, service *protogen.Service) {
+	clientName := service.GoName + "Client"
+	serviceDescVar := "_" + service.GoName + "_serviceDesc"
+
+	g.P("// ", clientName, " is the client API for ", service.GoName, " service.")
+	g.P("//")
+	g.P("// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.")
+	g.P("type ", clientName, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, clientSignature(g, method))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("type ", unexport(clientName), " struct {")
+	g.P("cc *", grpcPackage.Ident("ClientConn"))
+	g.P("}")
+	g.P()
+	g.P("func New", clientName, "(cc *", grpcPackage.Ident("ClientConn"), ") ", clientName, " {")
+	g.P("return &", unexport(clientName), "{cc}")
+	g.P("}")
+	g.P()
+
+	var methodIndex, streamIndex int
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			genClientMethod(g, method, streamIndex)
+			streamIndex++
+		} else {
+			genClientMethod(g, method, methodIndex)
+			methodIndex++
+		}
+	}
+
+	serverType := service.GoName + "Server"
+	g.P("// ", serverType, " is the server API for ", service.GoName, " service.")
+	g.P("type ", serverType, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, serverSignature(g, method))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("func Register", service.GoName, "Server(s *", grpcPackage.Ident("Server"), ", srv ", serverType, ") {")
+	g.P("s.RegisterService(&", serviceDescVar, ", srv)")
+	g.P("}")
+	g.P()
+
+	handlerNames := make([]string, len(service.Methods))
+	for i, method := range service.Methods {
+		handlerNames[i] = genServerMethod(g, method)
+	}
+
+	g.P("var ", serviceDescVar, " = ", grpcPackage.Ident("ServiceDesc"), "{")
+	g.P("ServiceName: ", strconv.Quote(string(service.Desc.FullName())), ",")
+	g.P("HandlerType: (*", serverType, ")(nil),")
+	g.P("Methods: []", grpcPackage.Ident("MethodDesc"), "{")
+	for i, method := range service.Methods {
+		if isStreaming(method) {
+			continue
+		}
+		g.P("{")
+		g.P("MethodName: ", strconv.Quote(string(method.Desc.Name())), ",")
+		g.P("Handler: ", handlerNames[i], ",")
+		g.P("},")
+	}
+	g.P("},")
+	g.P("Streams: []", grpcPackage.Ident("StreamDesc"), "{")
+	for i, method := range service.Methods {
+		if !isStreaming(method) {
+			continue
+		}
+		g.P("{")
+		g.P("StreamName: ", strconv.Quote(string(method.Desc.Name())), ",")
+		g.P("Handler: ", handlerNames[i], ",")
+		if method.Desc.IsStreamingServer() {
+			g.P("ServerStreams: true,")
+		}
+		if method.Desc.IsStreamingClient() {
+			g.P("ClientStreams: true,")
+		}
+		g.P("},")
+	}
+	g.P("},")
+	g.P("Metadata: ", strconv.Quote(file.Desc.Path()), ",")
+	g.P("}")
+	g.P()
+}
+
+func clientSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	s := method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context"))
+	if !method.Desc.IsStreamingClient() {
+		s += ", in *" + g.QualifiedGoIdent(method.Input.GoIdent)
+	}
+	s += ", opts ..." + g.QualifiedGoIdent(grpcPackage.Ident("CallOption")) + ") ("
+	if isStreaming(method) {
+		s += method.Parent.GoName + "_" + method.GoName + "Client"
+	} else {
+		s += "*" + g.QualifiedGoIdent(method.Output.GoIdent)
+	}
+	return s + ", error)"
+}
+
+func genClientMethod(g *protogen.GeneratedFile, method *protogen.Method, index int) {
+	service := method.Parent
+	sname := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.Desc.Name())
+
+	g.P("func (c *", unexport(service.GoName), "Client) ", clientSignature(g, method), " {")
+	if !isStreaming(method) {
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("err := c.cc.Invoke(ctx, ", strconv.Quote(sname), ", in, out, opts...)")
+		g.P("if err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+		return
+	}
+
+	streamType := unexport(service.GoName) + method.GoName + "Client"
+	g.P("stream, err := c.cc.NewStream(ctx, &_", service.GoName, "_serviceDesc.Streams[", index, "], ", strconv.Quote(sname), ", opts...)")
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("x := &", streamType, "{stream}")
+	if !method.Desc.IsStreamingClient() {
+		g.P("if err := x.ClientStream.SendMsg(in); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("if err := x.ClientStream.CloseSend(); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+	}
+	g.P("return x, nil")
+	g.P("}")
+	g.P()
+
+	g.P("type ", service.GoName, "_", method.GoName, "Client interface {")
+	if method.Desc.IsStreamingClient() {
+		g.P("Send(*", method.Input.GoIdent, ") error")
+	}
+	if method.Desc.IsStreamingServer() {
+		g.P("Recv() (*", method.Output.GoIdent, ", error)")
+	} else {
+		g.P("CloseAndRecv() (*", method.Output.GoIdent, ", error)")
+	}
+	g.P(grpcPackage.Ident("ClientStream"))
+	g.P("}")
+	g.P()
+
+	g.P("type ", streamType, " struct {")
+	g.P(grpcPackage.Ident("ClientStream"))
+	g.P("}")
+	g.P()
+
+	if method.Desc.IsStreamingClient() {
+		g.P("func (x *", streamType, ") Send(m *", method.Input.GoIdent, ") error {")
+		g.P("return x.ClientStream.SendMsg(m)")
+		g.P("}")
+		g.P()
+	}
+	if method.Desc.IsStreamingServer() {
+		g.P("func (x *", streamType, ") Recv() (*", method.Output.GoIdent, ", error) {")
+	} else {
+		g.P("func (x *", streamType, ") CloseAndRecv() (*", method.Output.GoIdent, ", error) {")
+		g.P("if err := x.ClientStream.CloseSend(); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+	}
+	g.P("m := new(", method.Output.GoIdent, ")")
+	g.P("if err := x.ClientStream.RecvMsg(m); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return m, nil")
+	g.P("}")
+	g.P()
+}
+
+func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	var args []string
+	ret := "error"
+	if !isStreaming(method) {
+		args = append(args, g.QualifiedGoIdent(contextPackage.Ident("Context")))
+		ret = "(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	}
+	if !method.Desc.IsStreamingClient() {
+		args = append(args, "*"+g.QualifiedGoIdent(method.Input.GoIdent))
+	}
+	if isStreaming(method) {
+		args = append(args, method.Parent.GoName+"_"+method.GoName+"Server")
+	}
+	return method.GoName + "(" + strings.Join(args, ", ") + ") " + ret
+}
+
+func genServerMethod(g *protogen.GeneratedFile, method *protogen.Method) string {
+	service := method.Parent
+	hname := fmt.Sprintf("_%s_%s_Handler", service.GoName, method.GoName)
+
+	if !isStreaming(method) {
+		g.P("func ", hname, "(srv interface{}, ctx ", contextPackage.Ident("Context"), ", dec func(interface{}) error, interceptor ", grpcPackage.Ident("UnaryServerInterceptor"), ") (interface{}, error) {")
+		g.P("in := new(", method.Input.GoIdent, ")")
+		g.P("if err := dec(in); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("if interceptor == nil {")
+		g.P("return srv.(", service.GoName, "Server).", method.GoName, "(ctx, in)")
+		g.P("}")
+		g.P("info := &", grpcPackage.Ident("UnaryServerInfo"), "{")
+		g.P("Server: srv,")
+		g.P("FullMethod: ", strconv.Quote(fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.Desc.Name())), ",")
+		g.P("}")
+		g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req interface{}) (interface{}, error) {")
+		g.P("return srv.(", service.GoName, "Server).", method.GoName, "(ctx, req.(*", method.Input.GoIdent, "))")
+		g.P("}")
+		g.P("return interceptor(ctx, in, info, handler)")
+		g.P("}")
+		g.P()
+		return hname
+	}
+
+	streamType := unexport(service.GoName) + method.GoName + "Server"
+	g.P("func ", hname, "(srv interface{}, stream ", grpcPackage.Ident("ServerStream"), ") error {")
+	if !method.Desc.IsStreamingClient() {
+		g.P("m := new(", method.Input.GoIdent, ")")
+		g.P("if err := stream.RecvMsg(m); err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("return srv.(", service.GoName, "Server).", method.GoName, "(m, &", streamType, "{stream})")
+	} else {
+		g.P("return srv.(", service.GoName, "Server).", method.GoName, "(&", streamType, "{stream})")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("type ", service.GoName, "_", method.GoName, "Server interface {")
+	if method.Desc.IsStreamingServer() {
+		g.P("Send(*", method.Output.GoIdent, ") error")
+	} else {
+		g.P("SendAndClose(*", method.Output.GoIdent, ") error")
+	}
+	if method.Desc.IsStreamingClient() {
+		g.P("Recv() (*", method.Input.GoIdent, ", error)")
+	}
+	g.P(grpcPackage.Ident("ServerStream"))
+	g.P("}")
+	g.P()
+
+	g.P("type ", streamType, " struct {")
+	g.P(grpcPackage.Ident("ServerStream"))
+	g.P("}")
+	g.P()
+
+	if method.Desc.IsStreamingServer() {
+		g.P("func (x *", streamType, ") Send(m *", method.Output.GoIdent, ") error {")
+	} else {
+		g.P("func (x *", streamType, ") SendAndClose(m *", method.Output.GoIdent, ") error {")
+	}
+	g.P("return x.ServerStream.SendMsg(m)")
+	g.P("}")
+	g.P()
+	if method.Desc.IsStreamingClient() {
+		g.P("func (x *", streamType, ") Recv() (*", method.Input.GoIdent, ", error) {")
+		g.P("m := new(", method.Input.GoIdent, ")")
+		g.P("if err := x.ServerStream.RecvMsg(m); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return m, nil")
+		g.P("}")
+		g.P()
+	}
+	return hname
+}
+
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+func unexport(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,364 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package protos
+
+import (
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// The envelopes and the chain states are marshaled and unmarshaled for every message sent and received.
+// They are encoded by hand the way the gogo-generated marshalers of the previous releases did, which is
+// about twice as fast as the generated code. The messages carrying unknown fields, or fields the fast path
+// doesn't handle, are left to the generated code.
+
+// Marshal returns the wire encoding of the envelope, the gRPC codec marshals the envelopes with it
+func (e *Envelope) Marshal() ([]byte, error) {
+	if !e.fastPath() {
+		return proto.Marshal(e)
+	}
+	return e.appendTo(make([]byte, 0, e.fastSize())), nil
+}
+
+// Unmarshal parses the wire encoding of the envelope, the gRPC codec unmarshals the envelopes with it
+func (e *Envelope) Unmarshal(b []byte) error {
+	e.Reset()
+	if e.parse(b) {
+		return nil
+	}
+	e.Reset()
+	return proto.Unmarshal(b, e)
+}
+
+// marshalMessage returns the wire encoding of the message
+func marshalMessage(m *RKSyncMessage) ([]byte, error) {
+	state, ok := m.Content.(*RKSyncMessage_State)
+	if !ok || len(m.unknownFields) > 0 || !state.State.fastPath() {
+		return proto.Marshal(m)
+	}
+
+	size := sizeVarint(1, m.Nonce) + sizeBytes(2, m.ChainMac) + sizeVarint(3, uint64(m.Tag)) +
+		sizeVarint(4, uint64(m.Timestamp)) + sizeMessage(11, state.State.fastSize())
+	b := make([]byte, 0, size)
+	b = appendVarint(b, 1, m.Nonce)
+	b = appendBytes(b, 2, m.ChainMac)
+	b = appendVarint(b, 3, uint64(m.Tag))
+	b = appendVarint(b, 4, uint64(m.Timestamp))
+	b = protowire.AppendTag(b, 11, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(state.State.fastSize()))
+	return state.State.appendTo(b), nil
+}
+
+// unmarshalMessage parses the wire encoding of the message into m
+func unmarshalMessage(b []byte, m *RKSyncMessage) error {
+	if parseMessage(b, m) {
+		return nil
+	}
+	m.Reset()
+	return proto.Unmarshal(b, m)
+}
+
+func parseMessage(b []byte, m *RKSyncMessage) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			m.Nonce, n = protowire.ConsumeVarint(b)
+		case num == 2 && typ == protowire.BytesType:
+			m.ChainMac, n = consumeBytes(b)
+		case num == 3 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			m.Tag = RKSyncMessage_Tag(v)
+		case num == 4 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			m.Timestamp = int64(v)
+		case num == 11 && typ == protowire.BytesType && m.Content == nil:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			state := &ChainState{}
+			if n < 0 || !state.parse(v) {
+				return false
+			}
+			m.Content = &RKSyncMessage_State{State: state}
+		default:
+			return false
+		}
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	// Only the chain states take the fast path, the other contents are parsed by the generated code
+	return m.Content != nil
+}
+
+func (e *Envelope) fastPath() bool {
+	return e != nil && len(e.unknownFields) == 0 && (e.SecretEnvelope == nil || len(e.SecretEnvelope.unknownFields) == 0)
+}
+
+func (e *Envelope) fastSize() int {
+	size := sizeBytes(1, e.Payload) + sizeBytes(2, e.Signature) + sizeVarint(3, uint64(e.SignatureAlgorithm)) + sizeVarint(5, uint64(e.HopsLeft))
+	if s := e.SecretEnvelope; s != nil {
+		size += sizeMessage(4, sizeBytes(1, s.Payload)+sizeBytes(2, s.Signature))
+	}
+	return size
+}
+
+func (e *Envelope) appendTo(b []byte) []byte {
+	b = appendBytes(b, 1, e.Payload)
+	b = appendBytes(b, 2, e.Signature)
+	b = appendVarint(b, 3, uint64(e.SignatureAlgorithm))
+	if s := e.SecretEnvelope; s != nil {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(sizeBytes(1, s.Payload)+sizeBytes(2, s.Signature)))
+		b = appendBytes(b, 1, s.Payload)
+		b = appendBytes(b, 2, s.Signature)
+	}
+	return appendVarint(b, 5, uint64(e.HopsLeft))
+}
+
+func (e *Envelope) parse(b []byte) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			e.Payload, n = consumeBytes(b)
+		case num == 2 && typ == protowire.BytesType:
+			e.Signature, n = consumeBytes(b)
+		case num == 3 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			e.SignatureAlgorithm = SignatureAlgorithm(v)
+		case num == 4 && typ == protowire.BytesType && e.SecretEnvelope == nil:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			e.SecretEnvelope = &SecretEnvelope{}
+			if n < 0 || !e.SecretEnvelope.parse(v) {
+				return false
+			}
+		case num == 5 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			e.HopsLeft = uint32(v)
+		default:
+			return false
+		}
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+func (s *SecretEnvelope) parse(b []byte) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			s.Payload, n = consumeBytes(b)
+		case num == 2 && typ == protowire.BytesType:
+			s.Signature, n = consumeBytes(b)
+		default:
+			return false
+		}
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+func (s *ChainState) fastPath() bool {
+	return s != nil && len(s.unknownFields) == 0 && utf8.ValidString(s.ChainId) &&
+		(s.Envelope == nil || s.Envelope.fastPath()) && (s.Change == nil || s.Change.fastPath()) &&
+		(s.Heartbeat == nil || len(s.Heartbeat.unknownFields) == 0)
+}
+
+func (s *ChainState) fastSize() int {
+	size := sizeVarint(1, s.SeqNum) + sizeString(2, s.ChainId)
+	if s.Envelope != nil {
+		size += sizeMessage(3, s.Envelope.fastSize())
+	}
+	if s.Change != nil {
+		size += sizeMessage(4, s.Change.fastSize())
+	}
+	if h := s.Heartbeat; h != nil {
+		size += sizeMessage(5, sizeVarint(1, h.IncNum)+sizeVarint(2, h.SeqNum))
+	}
+	return size
+}
+
+func (s *ChainState) appendTo(b []byte) []byte {
+	b = appendVarint(b, 1, s.SeqNum)
+	if s.ChainId != "" {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, s.ChainId)
+	}
+	if s.Envelope != nil {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(s.Envelope.fastSize()))
+		b = s.Envelope.appendTo(b)
+	}
+	if s.Change != nil {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(s.Change.fastSize()))
+		b = s.Change.appendTo(b)
+	}
+	if h := s.Heartbeat; h != nil {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(sizeVarint(1, h.IncNum)+sizeVarint(2, h.SeqNum)))
+		b = appendVarint(b, 1, h.IncNum)
+		b = appendVarint(b, 2, h.SeqNum)
+	}
+	return b
+}
+
+func (s *ChainState) parse(b []byte) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			s.SeqNum, n = protowire.ConsumeVarint(b)
+		case num == 2 && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			if !utf8.Valid(v) {
+				return false
+			}
+			s.ChainId = string(v)
+		case num == 3 && typ == protowire.BytesType && s.Envelope == nil:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			s.Envelope = &Envelope{}
+			if n < 0 || !s.Envelope.parse(v) {
+				return false
+			}
+		case num == 4 && typ == protowire.BytesType && s.Change == nil:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			s.Change = &Envelope{}
+			if n < 0 || !s.Change.parse(v) {
+				return false
+			}
+		case num == 5 && typ == protowire.BytesType && s.Heartbeat == nil:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			s.Heartbeat = &PeerTime{}
+			if n < 0 || !s.Heartbeat.parse(v) {
+				return false
+			}
+		default:
+			return false
+		}
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+func (t *PeerTime) parse(b []byte) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || typ != protowire.VarintType {
+			return false
+		}
+		b = b[n:]
+		var v uint64
+		v, n = protowire.ConsumeVarint(b)
+		if n < 0 {
+			return false
+		}
+		switch num {
+		case 1:
+			t.IncNum = v
+		case 2:
+			t.SeqNum = v
+		default:
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+// sizeVarint returns the size of the varint field, proto3 omits the zero values
+func sizeVarint(num protowire.Number, v uint64) int {
+	if v == 0 {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeVarint(v)
+}
+
+// sizeBytes returns the size of the bytes field, proto3 omits the empty values
+func sizeBytes(num protowire.Number, v []byte) int {
+	if len(v) == 0 {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeBytes(len(v))
+}
+
+// sizeString returns the size of the string field, proto3 omits the empty values
+func sizeString(num protowire.Number, v string) int {
+	if len(v) == 0 {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeBytes(len(v))
+}
+
+// sizeMessage returns the size of the embedded message field of the given size
+func sizeMessage(num protowire.Number, size int) int {
+	return protowire.SizeTag(num) + protowire.SizeBytes(size)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// consumeBytes parses a bytes field, the value is copied out of the buffer
+func consumeBytes(b []byte) ([]byte, int) {
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return nil, n
+	}
+	return append([]byte(nil), v...), n
+}
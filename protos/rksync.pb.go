@@ -1,29 +1,84 @@
-// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// Copyright Rockontrol Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        (unknown)
 // source: github.com/rkcloudchain/rksync/protos/rksync.proto
 
 package protos
 
 import (
-	context "context"
-	fmt "fmt"
-	_ "github.com/gogo/protobuf/gogoproto"
-	types "github.com/gogo/protobuf/types"
-	proto "github.com/golang/protobuf/proto"
-	grpc "google.golang.org/grpc"
-	io "io"
-	math "math"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SignatureAlgorithm identifies the scheme the envelope signature
+// was produced with
+type SignatureAlgorithm int32
+
+const (
+	SignatureAlgorithm_UNSPECIFIED    SignatureAlgorithm = 0
+	SignatureAlgorithm_ECDSA_SHA3_256 SignatureAlgorithm = 1
+	SignatureAlgorithm_RSA_SHA3_256   SignatureAlgorithm = 2
+	SignatureAlgorithm_ED25519        SignatureAlgorithm = 3
+)
+
+// Enum value maps for SignatureAlgorithm.
+var (
+	SignatureAlgorithm_name = map[int32]string{
+		0: "UNSPECIFIED",
+		1: "ECDSA_SHA3_256",
+		2: "RSA_SHA3_256",
+		3: "ED25519",
+	}
+	SignatureAlgorithm_value = map[string]int32{
+		"UNSPECIFIED":    0,
+		"ECDSA_SHA3_256": 1,
+		"RSA_SHA3_256":   2,
+		"ED25519":        3,
+	}
 )
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ = proto.Marshal
-var _ = fmt.Errorf
-var _ = math.Inf
+func (x SignatureAlgorithm) Enum() *SignatureAlgorithm {
+	p := new(SignatureAlgorithm)
+	*p = x
+	return p
+}
+
+func (x SignatureAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SignatureAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[0].Descriptor()
+}
+
+func (SignatureAlgorithm) Type() protoreflect.EnumType {
+	return &file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[0]
+}
+
+func (x SignatureAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the proto package it is being compiled against.
-// A compilation error at this line likely means your copy of the
-// proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+// Deprecated: Use SignatureAlgorithm.Descriptor instead.
+func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{0}
+}
 
 type RKSyncMessage_Tag int32
 
@@ -32,22 +87,98 @@ const (
 	RKSyncMessage_CHAN_ONLY RKSyncMessage_Tag = 1
 )
 
-var RKSyncMessage_Tag_name = map[int32]string{
-	0: "EMPTY",
-	1: "CHAN_ONLY",
-}
+// Enum value maps for RKSyncMessage_Tag.
+var (
+	RKSyncMessage_Tag_name = map[int32]string{
+		0: "EMPTY",
+		1: "CHAN_ONLY",
+	}
+	RKSyncMessage_Tag_value = map[string]int32{
+		"EMPTY":     0,
+		"CHAN_ONLY": 1,
+	}
+)
 
-var RKSyncMessage_Tag_value = map[string]int32{
-	"EMPTY":     0,
-	"CHAN_ONLY": 1,
+func (x RKSyncMessage_Tag) Enum() *RKSyncMessage_Tag {
+	p := new(RKSyncMessage_Tag)
+	*p = x
+	return p
 }
 
 func (x RKSyncMessage_Tag) String() string {
-	return proto.EnumName(RKSyncMessage_Tag_name, int32(x))
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RKSyncMessage_Tag) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[1].Descriptor()
+}
+
+func (RKSyncMessage_Tag) Type() protoreflect.EnumType {
+	return &file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[1]
+}
+
+func (x RKSyncMessage_Tag) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
+// Deprecated: Use RKSyncMessage_Tag.Descriptor instead.
 func (RKSyncMessage_Tag) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{1, 0}
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{3, 0}
+}
+
+type ChangeRecord_Op int32
+
+const (
+	ChangeRecord_INITIALIZE    ChangeRecord_Op = 0
+	ChangeRecord_ADD_MEMBER    ChangeRecord_Op = 1
+	ChangeRecord_REMOVE_MEMBER ChangeRecord_Op = 2
+	ChangeRecord_ADD_FILE      ChangeRecord_Op = 3
+	ChangeRecord_REMOVE_FILE   ChangeRecord_Op = 4
+)
+
+// Enum value maps for ChangeRecord_Op.
+var (
+	ChangeRecord_Op_name = map[int32]string{
+		0: "INITIALIZE",
+		1: "ADD_MEMBER",
+		2: "REMOVE_MEMBER",
+		3: "ADD_FILE",
+		4: "REMOVE_FILE",
+	}
+	ChangeRecord_Op_value = map[string]int32{
+		"INITIALIZE":    0,
+		"ADD_MEMBER":    1,
+		"REMOVE_MEMBER": 2,
+		"ADD_FILE":      3,
+		"REMOVE_FILE":   4,
+	}
+)
+
+func (x ChangeRecord_Op) Enum() *ChangeRecord_Op {
+	p := new(ChangeRecord_Op)
+	*p = x
+	return p
+}
+
+func (x ChangeRecord_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChangeRecord_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[2].Descriptor()
+}
+
+func (ChangeRecord_Op) Type() protoreflect.EnumType {
+	return &file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[2]
+}
+
+func (x ChangeRecord_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChangeRecord_Op.Descriptor instead.
+func (ChangeRecord_Op) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{13, 0}
 }
 
 type File_Mode int32
@@ -57,70 +188,310 @@ const (
 	File_Random File_Mode = 1
 )
 
-var File_Mode_name = map[int32]string{
-	0: "Append",
-	1: "Random",
-}
+// Enum value maps for File_Mode.
+var (
+	File_Mode_name = map[int32]string{
+		0: "Append",
+		1: "Random",
+	}
+	File_Mode_value = map[string]int32{
+		"Append": 0,
+		"Random": 1,
+	}
+)
 
-var File_Mode_value = map[string]int32{
-	"Append": 0,
-	"Random": 1,
+func (x File_Mode) Enum() *File_Mode {
+	p := new(File_Mode)
+	*p = x
+	return p
 }
 
 func (x File_Mode) String() string {
-	return proto.EnumName(File_Mode_name, int32(x))
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (File_Mode) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[3].Descriptor()
+}
+
+func (File_Mode) Type() protoreflect.EnumType {
+	return &file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[3]
 }
 
+func (x File_Mode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use File_Mode.Descriptor instead.
 func (File_Mode) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{12, 0}
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{19, 0}
+}
+
+type TransferRejection_Limit int32
+
+const (
+	TransferRejection_Quota TransferRejection_Limit = 0
+	TransferRejection_Disk  TransferRejection_Limit = 1
+)
+
+// Enum value maps for TransferRejection_Limit.
+var (
+	TransferRejection_Limit_name = map[int32]string{
+		0: "Quota",
+		1: "Disk",
+	}
+	TransferRejection_Limit_value = map[string]int32{
+		"Quota": 0,
+		"Disk":  1,
+	}
+)
+
+func (x TransferRejection_Limit) Enum() *TransferRejection_Limit {
+	p := new(TransferRejection_Limit)
+	*p = x
+	return p
+}
+
+func (x TransferRejection_Limit) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TransferRejection_Limit) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[4].Descriptor()
+}
+
+func (TransferRejection_Limit) Type() protoreflect.EnumType {
+	return &file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes[4]
+}
+
+func (x TransferRejection_Limit) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TransferRejection_Limit.Descriptor instead.
+func (TransferRejection_Limit) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{31, 0}
 }
 
 type Envelope struct {
-	Payload              []byte   `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
-	Signature            []byte   `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Payload            []byte             `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	Signature          []byte             `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	SignatureAlgorithm SignatureAlgorithm `protobuf:"varint,3,opt,name=signature_algorithm,json=signatureAlgorithm,proto3,enum=protos.SignatureAlgorithm" json:"signature_algorithm,omitempty"`
+	SecretEnvelope     *SecretEnvelope    `protobuf:"bytes,4,opt,name=secret_envelope,json=secretEnvelope,proto3" json:"secret_envelope,omitempty"`
+	// Number of times the envelope may still be forwarded, it isn't signed
+	// since every forwarding peer decrements it. Zero means it isn't forwarded.
+	HopsLeft uint32 `protobuf:"varint,5,opt,name=hops_left,json=hopsLeft,proto3" json:"hops_left,omitempty"`
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (m *Envelope) Reset()         { *m = Envelope{} }
-func (m *Envelope) String() string { return proto.CompactTextString(m) }
-func (*Envelope) ProtoMessage()    {}
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
 func (*Envelope) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{0}
-}
-func (m *Envelope) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *Envelope) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Envelope.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Envelope) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Envelope) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *Envelope) GetSignatureAlgorithm() SignatureAlgorithm {
+	if x != nil {
+		return x.SignatureAlgorithm
+	}
+	return SignatureAlgorithm_UNSPECIFIED
+}
+
+func (x *Envelope) GetSecretEnvelope() *SecretEnvelope {
+	if x != nil {
+		return x.SecretEnvelope
+	}
+	return nil
+}
+
+func (x *Envelope) GetHopsLeft() uint32 {
+	if x != nil {
+		return x.HopsLeft
+	}
+	return 0
+}
+
+// SecretEnvelope is a marshalled Secret and a signature over it.
+// It is signed separately from the envelope's payload, so it can be
+// removed from the envelope without invalidating the payload's signature.
+type SecretEnvelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Payload   []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *SecretEnvelope) Reset() {
+	*x = SecretEnvelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SecretEnvelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecretEnvelope) ProtoMessage() {}
+
+func (x *SecretEnvelope) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecretEnvelope.ProtoReflect.Descriptor instead.
+func (*SecretEnvelope) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SecretEnvelope) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *SecretEnvelope) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// Secret is an entity that might be omitted from an Envelope
+// when the remote peer isn't supposed to know about it.
+type Secret struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Content:
+	//	*Secret_InternalEndpoint
+	Content isSecret_Content `protobuf_oneof:"content"`
+}
+
+func (x *Secret) Reset() {
+	*x = Secret{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Secret) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Secret) ProtoMessage() {}
+
+func (x *Secret) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Secret.ProtoReflect.Descriptor instead.
+func (*Secret) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{2}
+}
+
+func (m *Secret) GetContent() isSecret_Content {
+	if m != nil {
+		return m.Content
 	}
+	return nil
 }
-func (m *Envelope) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Envelope.Merge(m, src)
+
+func (x *Secret) GetInternalEndpoint() string {
+	if x, ok := x.GetContent().(*Secret_InternalEndpoint); ok {
+		return x.InternalEndpoint
+	}
+	return ""
 }
-func (m *Envelope) XXX_Size() int {
-	return m.Size()
+
+type isSecret_Content interface {
+	isSecret_Content()
 }
-func (m *Envelope) XXX_DiscardUnknown() {
-	xxx_messageInfo_Envelope.DiscardUnknown(m)
+
+type Secret_InternalEndpoint struct {
+	InternalEndpoint string `protobuf:"bytes,1,opt,name=internal_endpoint,json=internalEndpoint,proto3,oneof"`
 }
 
-var xxx_messageInfo_Envelope proto.InternalMessageInfo
+func (*Secret_InternalEndpoint) isSecret_Content() {}
 
 type RKSyncMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
 	Nonce    uint64            `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
 	ChainMac []byte            `protobuf:"bytes,2,opt,name=chain_mac,json=chainMac,proto3" json:"chain_mac,omitempty"`
 	Tag      RKSyncMessage_Tag `protobuf:"varint,3,opt,name=tag,proto3,enum=protos.RKSyncMessage_Tag" json:"tag,omitempty"`
-	// Types that are valid to be assigned to Content:
+	// Time the message was signed at in nanoseconds, signed messages
+	// stamped outside of the freshness window are discarded as replays
+	Timestamp int64 `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Types that are assignable to Content:
 	//	*RKSyncMessage_AliveMsg
 	//	*RKSyncMessage_Empty
 	//	*RKSyncMessage_Conn
@@ -134,104 +505,79 @@ type RKSyncMessage struct {
 	//	*RKSyncMessage_DataMsg
 	//	*RKSyncMessage_DataReq
 	//	*RKSyncMessage_LeaveChain
-	Content              isRKSyncMessage_Content `protobuf_oneof:"content"`
-	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
-	XXX_unrecognized     []byte                  `json:"-"`
-	XXX_sizecache        int32                   `json:"-"`
+	//	*RKSyncMessage_ChangeLogReq
+	//	*RKSyncMessage_ChangeLogRes
+	//	*RKSyncMessage_StateReq
+	//	*RKSyncMessage_StateRes
+	//	*RKSyncMessage_LeadershipDeclaration
+	//	*RKSyncMessage_LeadershipVote
+	//	*RKSyncMessage_FileAck
+	//	*RKSyncMessage_IdentityReq
+	//	*RKSyncMessage_IdentityRes
+	//	*RKSyncMessage_ChannelInvitation
+	//	*RKSyncMessage_ChannelAcceptance
+	Content isRKSyncMessage_Content `protobuf_oneof:"content"`
 }
 
-func (m *RKSyncMessage) Reset()         { *m = RKSyncMessage{} }
-func (m *RKSyncMessage) String() string { return proto.CompactTextString(m) }
-func (*RKSyncMessage) ProtoMessage()    {}
-func (*RKSyncMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{1}
-}
-func (m *RKSyncMessage) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *RKSyncMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_RKSyncMessage.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *RKSyncMessage) Reset() {
+	*x = RKSyncMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *RKSyncMessage) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RKSyncMessage.Merge(m, src)
-}
-func (m *RKSyncMessage) XXX_Size() int {
-	return m.Size()
-}
-func (m *RKSyncMessage) XXX_DiscardUnknown() {
-	xxx_messageInfo_RKSyncMessage.DiscardUnknown(m)
+
+func (x *RKSyncMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var xxx_messageInfo_RKSyncMessage proto.InternalMessageInfo
+func (*RKSyncMessage) ProtoMessage() {}
 
-type isRKSyncMessage_Content interface {
-	isRKSyncMessage_Content()
-	MarshalTo([]byte) (int, error)
-	Size() int
+func (x *RKSyncMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-type RKSyncMessage_AliveMsg struct {
-	AliveMsg *AliveMessage `protobuf:"bytes,5,opt,name=alive_msg,json=aliveMsg,proto3,oneof"`
-}
-type RKSyncMessage_Empty struct {
-	Empty *types.Empty `protobuf:"bytes,6,opt,name=empty,proto3,oneof"`
-}
-type RKSyncMessage_Conn struct {
-	Conn *ConnEstablish `protobuf:"bytes,7,opt,name=conn,proto3,oneof"`
-}
-type RKSyncMessage_Ack struct {
-	Ack *Acknowledgement `protobuf:"bytes,8,opt,name=ack,proto3,oneof"`
-}
-type RKSyncMessage_MemReq struct {
-	MemReq *MembershipRequest `protobuf:"bytes,9,opt,name=mem_req,json=memReq,proto3,oneof"`
-}
-type RKSyncMessage_MemRes struct {
-	MemRes *MembershipResponse `protobuf:"bytes,10,opt,name=mem_res,json=memRes,proto3,oneof"`
-}
-type RKSyncMessage_State struct {
-	State *ChainState `protobuf:"bytes,11,opt,name=state,proto3,oneof"`
-}
-type RKSyncMessage_StatePullRequest struct {
-	StatePullRequest *ChainStatePullRequest `protobuf:"bytes,12,opt,name=state_pull_request,json=statePullRequest,proto3,oneof"`
-}
-type RKSyncMessage_StatePullResponse struct {
-	StatePullResponse *ChainStatePullResponse `protobuf:"bytes,13,opt,name=state_pull_response,json=statePullResponse,proto3,oneof"`
-}
-type RKSyncMessage_StateInfo struct {
-	StateInfo *ChainStateInfo `protobuf:"bytes,14,opt,name=state_info,json=stateInfo,proto3,oneof"`
+// Deprecated: Use RKSyncMessage.ProtoReflect.Descriptor instead.
+func (*RKSyncMessage) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{3}
 }
-type RKSyncMessage_DataMsg struct {
-	DataMsg *DataMessage `protobuf:"bytes,15,opt,name=data_msg,json=dataMsg,proto3,oneof"`
+
+func (x *RKSyncMessage) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
 }
-type RKSyncMessage_DataReq struct {
-	DataReq *DataRequest `protobuf:"bytes,16,opt,name=data_req,json=dataReq,proto3,oneof"`
+
+func (x *RKSyncMessage) GetChainMac() []byte {
+	if x != nil {
+		return x.ChainMac
+	}
+	return nil
 }
-type RKSyncMessage_LeaveChain struct {
-	LeaveChain *LeaveChainMessage `protobuf:"bytes,17,opt,name=leave_chain,json=leaveChain,proto3,oneof"`
+
+func (x *RKSyncMessage) GetTag() RKSyncMessage_Tag {
+	if x != nil {
+		return x.Tag
+	}
+	return RKSyncMessage_EMPTY
 }
 
-func (*RKSyncMessage_AliveMsg) isRKSyncMessage_Content()          {}
-func (*RKSyncMessage_Empty) isRKSyncMessage_Content()             {}
-func (*RKSyncMessage_Conn) isRKSyncMessage_Content()              {}
-func (*RKSyncMessage_Ack) isRKSyncMessage_Content()               {}
-func (*RKSyncMessage_MemReq) isRKSyncMessage_Content()            {}
-func (*RKSyncMessage_MemRes) isRKSyncMessage_Content()            {}
-func (*RKSyncMessage_State) isRKSyncMessage_Content()             {}
-func (*RKSyncMessage_StatePullRequest) isRKSyncMessage_Content()  {}
-func (*RKSyncMessage_StatePullResponse) isRKSyncMessage_Content() {}
-func (*RKSyncMessage_StateInfo) isRKSyncMessage_Content()         {}
-func (*RKSyncMessage_DataMsg) isRKSyncMessage_Content()           {}
-func (*RKSyncMessage_DataReq) isRKSyncMessage_Content()           {}
-func (*RKSyncMessage_LeaveChain) isRKSyncMessage_Content()        {}
+func (x *RKSyncMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
 
 func (m *RKSyncMessage) GetContent() isRKSyncMessage_Content {
 	if m != nil {
@@ -240,6161 +586,3895 @@ func (m *RKSyncMessage) GetContent() isRKSyncMessage_Content {
 	return nil
 }
 
-func (m *RKSyncMessage) GetAliveMsg() *AliveMessage {
-	if x, ok := m.GetContent().(*RKSyncMessage_AliveMsg); ok {
+func (x *RKSyncMessage) GetAliveMsg() *AliveMessage {
+	if x, ok := x.GetContent().(*RKSyncMessage_AliveMsg); ok {
 		return x.AliveMsg
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetEmpty() *types.Empty {
-	if x, ok := m.GetContent().(*RKSyncMessage_Empty); ok {
+func (x *RKSyncMessage) GetEmpty() *emptypb.Empty {
+	if x, ok := x.GetContent().(*RKSyncMessage_Empty); ok {
 		return x.Empty
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetConn() *ConnEstablish {
-	if x, ok := m.GetContent().(*RKSyncMessage_Conn); ok {
+func (x *RKSyncMessage) GetConn() *ConnEstablish {
+	if x, ok := x.GetContent().(*RKSyncMessage_Conn); ok {
 		return x.Conn
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetAck() *Acknowledgement {
-	if x, ok := m.GetContent().(*RKSyncMessage_Ack); ok {
+func (x *RKSyncMessage) GetAck() *Acknowledgement {
+	if x, ok := x.GetContent().(*RKSyncMessage_Ack); ok {
 		return x.Ack
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetMemReq() *MembershipRequest {
-	if x, ok := m.GetContent().(*RKSyncMessage_MemReq); ok {
+func (x *RKSyncMessage) GetMemReq() *MembershipRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_MemReq); ok {
 		return x.MemReq
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetMemRes() *MembershipResponse {
-	if x, ok := m.GetContent().(*RKSyncMessage_MemRes); ok {
+func (x *RKSyncMessage) GetMemRes() *MembershipResponse {
+	if x, ok := x.GetContent().(*RKSyncMessage_MemRes); ok {
 		return x.MemRes
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetState() *ChainState {
-	if x, ok := m.GetContent().(*RKSyncMessage_State); ok {
+func (x *RKSyncMessage) GetState() *ChainState {
+	if x, ok := x.GetContent().(*RKSyncMessage_State); ok {
 		return x.State
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetStatePullRequest() *ChainStatePullRequest {
-	if x, ok := m.GetContent().(*RKSyncMessage_StatePullRequest); ok {
+func (x *RKSyncMessage) GetStatePullRequest() *ChainStatePullRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_StatePullRequest); ok {
 		return x.StatePullRequest
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetStatePullResponse() *ChainStatePullResponse {
-	if x, ok := m.GetContent().(*RKSyncMessage_StatePullResponse); ok {
+func (x *RKSyncMessage) GetStatePullResponse() *ChainStatePullResponse {
+	if x, ok := x.GetContent().(*RKSyncMessage_StatePullResponse); ok {
 		return x.StatePullResponse
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetStateInfo() *ChainStateInfo {
-	if x, ok := m.GetContent().(*RKSyncMessage_StateInfo); ok {
+func (x *RKSyncMessage) GetStateInfo() *ChainStateInfo {
+	if x, ok := x.GetContent().(*RKSyncMessage_StateInfo); ok {
 		return x.StateInfo
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetDataMsg() *DataMessage {
-	if x, ok := m.GetContent().(*RKSyncMessage_DataMsg); ok {
+func (x *RKSyncMessage) GetDataMsg() *DataMessage {
+	if x, ok := x.GetContent().(*RKSyncMessage_DataMsg); ok {
 		return x.DataMsg
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetDataReq() *DataRequest {
-	if x, ok := m.GetContent().(*RKSyncMessage_DataReq); ok {
+func (x *RKSyncMessage) GetDataReq() *DataRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_DataReq); ok {
 		return x.DataReq
 	}
 	return nil
 }
 
-func (m *RKSyncMessage) GetLeaveChain() *LeaveChainMessage {
-	if x, ok := m.GetContent().(*RKSyncMessage_LeaveChain); ok {
+func (x *RKSyncMessage) GetLeaveChain() *LeaveChainMessage {
+	if x, ok := x.GetContent().(*RKSyncMessage_LeaveChain); ok {
 		return x.LeaveChain
 	}
 	return nil
 }
 
-// XXX_OneofFuncs is for the internal use of the proto package.
-func (*RKSyncMessage) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
-	return _RKSyncMessage_OneofMarshaler, _RKSyncMessage_OneofUnmarshaler, _RKSyncMessage_OneofSizer, []interface{}{
-		(*RKSyncMessage_AliveMsg)(nil),
-		(*RKSyncMessage_Empty)(nil),
-		(*RKSyncMessage_Conn)(nil),
-		(*RKSyncMessage_Ack)(nil),
-		(*RKSyncMessage_MemReq)(nil),
-		(*RKSyncMessage_MemRes)(nil),
-		(*RKSyncMessage_State)(nil),
-		(*RKSyncMessage_StatePullRequest)(nil),
-		(*RKSyncMessage_StatePullResponse)(nil),
-		(*RKSyncMessage_StateInfo)(nil),
-		(*RKSyncMessage_DataMsg)(nil),
-		(*RKSyncMessage_DataReq)(nil),
-		(*RKSyncMessage_LeaveChain)(nil),
+func (x *RKSyncMessage) GetChangeLogReq() *ChangeLogRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_ChangeLogReq); ok {
+		return x.ChangeLogReq
 	}
+	return nil
 }
 
-func _RKSyncMessage_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
-	m := msg.(*RKSyncMessage)
-	// content
-	switch x := m.Content.(type) {
-	case *RKSyncMessage_AliveMsg:
-		_ = b.EncodeVarint(5<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.AliveMsg); err != nil {
-			return err
-		}
-	case *RKSyncMessage_Empty:
-		_ = b.EncodeVarint(6<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Empty); err != nil {
-			return err
-		}
-	case *RKSyncMessage_Conn:
-		_ = b.EncodeVarint(7<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Conn); err != nil {
-			return err
-		}
-	case *RKSyncMessage_Ack:
-		_ = b.EncodeVarint(8<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Ack); err != nil {
-			return err
-		}
-	case *RKSyncMessage_MemReq:
-		_ = b.EncodeVarint(9<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.MemReq); err != nil {
-			return err
-		}
-	case *RKSyncMessage_MemRes:
-		_ = b.EncodeVarint(10<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.MemRes); err != nil {
-			return err
-		}
-	case *RKSyncMessage_State:
-		_ = b.EncodeVarint(11<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.State); err != nil {
-			return err
-		}
-	case *RKSyncMessage_StatePullRequest:
-		_ = b.EncodeVarint(12<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.StatePullRequest); err != nil {
-			return err
-		}
-	case *RKSyncMessage_StatePullResponse:
-		_ = b.EncodeVarint(13<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.StatePullResponse); err != nil {
-			return err
-		}
-	case *RKSyncMessage_StateInfo:
-		_ = b.EncodeVarint(14<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.StateInfo); err != nil {
-			return err
-		}
-	case *RKSyncMessage_DataMsg:
-		_ = b.EncodeVarint(15<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.DataMsg); err != nil {
-			return err
-		}
-	case *RKSyncMessage_DataReq:
-		_ = b.EncodeVarint(16<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.DataReq); err != nil {
-			return err
-		}
-	case *RKSyncMessage_LeaveChain:
-		_ = b.EncodeVarint(17<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.LeaveChain); err != nil {
-			return err
-		}
-	case nil:
-	default:
-		return fmt.Errorf("RKSyncMessage.Content has unexpected type %T", x)
+func (x *RKSyncMessage) GetChangeLogRes() *ChangeLogResponse {
+	if x, ok := x.GetContent().(*RKSyncMessage_ChangeLogRes); ok {
+		return x.ChangeLogRes
 	}
 	return nil
 }
 
-func _RKSyncMessage_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
-	m := msg.(*RKSyncMessage)
-	switch tag {
-	case 5: // content.alive_msg
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(AliveMessage)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_AliveMsg{msg}
-		return true, err
-	case 6: // content.empty
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(types.Empty)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_Empty{msg}
-		return true, err
-	case 7: // content.conn
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(ConnEstablish)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_Conn{msg}
-		return true, err
-	case 8: // content.ack
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(Acknowledgement)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_Ack{msg}
-		return true, err
-	case 9: // content.mem_req
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(MembershipRequest)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_MemReq{msg}
-		return true, err
-	case 10: // content.mem_res
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(MembershipResponse)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_MemRes{msg}
-		return true, err
-	case 11: // content.state
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(ChainState)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_State{msg}
-		return true, err
-	case 12: // content.state_pull_request
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(ChainStatePullRequest)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_StatePullRequest{msg}
-		return true, err
-	case 13: // content.state_pull_response
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(ChainStatePullResponse)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_StatePullResponse{msg}
-		return true, err
-	case 14: // content.state_info
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(ChainStateInfo)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_StateInfo{msg}
-		return true, err
-	case 15: // content.data_msg
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(DataMessage)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_DataMsg{msg}
-		return true, err
-	case 16: // content.data_req
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(DataRequest)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_DataReq{msg}
-		return true, err
-	case 17: // content.leave_chain
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(LeaveChainMessage)
-		err := b.DecodeMessage(msg)
-		m.Content = &RKSyncMessage_LeaveChain{msg}
-		return true, err
-	default:
-		return false, nil
-	}
-}
-
-func _RKSyncMessage_OneofSizer(msg proto.Message) (n int) {
-	m := msg.(*RKSyncMessage)
-	// content
-	switch x := m.Content.(type) {
-	case *RKSyncMessage_AliveMsg:
-		s := proto.Size(x.AliveMsg)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_Empty:
-		s := proto.Size(x.Empty)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_Conn:
-		s := proto.Size(x.Conn)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_Ack:
-		s := proto.Size(x.Ack)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_MemReq:
-		s := proto.Size(x.MemReq)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_MemRes:
-		s := proto.Size(x.MemRes)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_State:
-		s := proto.Size(x.State)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_StatePullRequest:
-		s := proto.Size(x.StatePullRequest)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_StatePullResponse:
-		s := proto.Size(x.StatePullResponse)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_StateInfo:
-		s := proto.Size(x.StateInfo)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_DataMsg:
-		s := proto.Size(x.DataMsg)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_DataReq:
-		s := proto.Size(x.DataReq)
-		n += 2 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *RKSyncMessage_LeaveChain:
-		s := proto.Size(x.LeaveChain)
-		n += 2 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case nil:
-	default:
-		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
-	}
-	return n
+func (x *RKSyncMessage) GetStateReq() *StateRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_StateReq); ok {
+		return x.StateReq
+	}
+	return nil
 }
 
-type ConnEstablish struct {
-	PkiId                []byte   `protobuf:"bytes,1,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
-	Identity             []byte   `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+func (x *RKSyncMessage) GetStateRes() *StateResponse {
+	if x, ok := x.GetContent().(*RKSyncMessage_StateRes); ok {
+		return x.StateRes
+	}
+	return nil
 }
 
-func (m *ConnEstablish) Reset()         { *m = ConnEstablish{} }
-func (m *ConnEstablish) String() string { return proto.CompactTextString(m) }
-func (*ConnEstablish) ProtoMessage()    {}
-func (*ConnEstablish) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{2}
-}
-func (m *ConnEstablish) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *ConnEstablish) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ConnEstablish.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *RKSyncMessage) GetLeadershipDeclaration() *LeadershipDeclaration {
+	if x, ok := x.GetContent().(*RKSyncMessage_LeadershipDeclaration); ok {
+		return x.LeadershipDeclaration
 	}
+	return nil
 }
-func (m *ConnEstablish) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ConnEstablish.Merge(m, src)
-}
-func (m *ConnEstablish) XXX_Size() int {
-	return m.Size()
-}
-func (m *ConnEstablish) XXX_DiscardUnknown() {
-	xxx_messageInfo_ConnEstablish.DiscardUnknown(m)
+
+func (x *RKSyncMessage) GetLeadershipVote() *LeadershipVote {
+	if x, ok := x.GetContent().(*RKSyncMessage_LeadershipVote); ok {
+		return x.LeadershipVote
+	}
+	return nil
 }
 
-var xxx_messageInfo_ConnEstablish proto.InternalMessageInfo
+func (x *RKSyncMessage) GetFileAck() *FileAck {
+	if x, ok := x.GetContent().(*RKSyncMessage_FileAck); ok {
+		return x.FileAck
+	}
+	return nil
+}
 
-type AliveMessage struct {
-	Membership           *Member   `protobuf:"bytes,1,opt,name=membership,proto3" json:"membership,omitempty"`
-	Timestamp            *PeerTime `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Identity             []byte    `protobuf:"bytes,3,opt,name=identity,proto3" json:"identity,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+func (x *RKSyncMessage) GetIdentityReq() *PeerIdentityRequest {
+	if x, ok := x.GetContent().(*RKSyncMessage_IdentityReq); ok {
+		return x.IdentityReq
+	}
+	return nil
 }
 
-func (m *AliveMessage) Reset()         { *m = AliveMessage{} }
-func (m *AliveMessage) String() string { return proto.CompactTextString(m) }
-func (*AliveMessage) ProtoMessage()    {}
-func (*AliveMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{3}
-}
-func (m *AliveMessage) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *AliveMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_AliveMessage.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *RKSyncMessage) GetIdentityRes() *PeerIdentityResponse {
+	if x, ok := x.GetContent().(*RKSyncMessage_IdentityRes); ok {
+		return x.IdentityRes
 	}
+	return nil
 }
-func (m *AliveMessage) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_AliveMessage.Merge(m, src)
+
+func (x *RKSyncMessage) GetChannelInvitation() *ChannelInvitation {
+	if x, ok := x.GetContent().(*RKSyncMessage_ChannelInvitation); ok {
+		return x.ChannelInvitation
+	}
+	return nil
 }
-func (m *AliveMessage) XXX_Size() int {
-	return m.Size()
+
+func (x *RKSyncMessage) GetChannelAcceptance() *ChannelAcceptance {
+	if x, ok := x.GetContent().(*RKSyncMessage_ChannelAcceptance); ok {
+		return x.ChannelAcceptance
+	}
+	return nil
 }
-func (m *AliveMessage) XXX_DiscardUnknown() {
-	xxx_messageInfo_AliveMessage.DiscardUnknown(m)
+
+type isRKSyncMessage_Content interface {
+	isRKSyncMessage_Content()
 }
 
-var xxx_messageInfo_AliveMessage proto.InternalMessageInfo
+type RKSyncMessage_AliveMsg struct {
+	AliveMsg *AliveMessage `protobuf:"bytes,5,opt,name=alive_msg,json=aliveMsg,proto3,oneof"`
+}
 
-type PeerTime struct {
-	IncNum               uint64   `protobuf:"varint,1,opt,name=inc_num,json=incNum,proto3" json:"inc_num,omitempty"`
-	SeqNum               uint64   `protobuf:"varint,2,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type RKSyncMessage_Empty struct {
+	Empty *emptypb.Empty `protobuf:"bytes,6,opt,name=empty,proto3,oneof"`
 }
 
-func (m *PeerTime) Reset()         { *m = PeerTime{} }
-func (m *PeerTime) String() string { return proto.CompactTextString(m) }
-func (*PeerTime) ProtoMessage()    {}
-func (*PeerTime) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{4}
-}
-func (m *PeerTime) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *PeerTime) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_PeerTime.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
-	}
+type RKSyncMessage_Conn struct {
+	Conn *ConnEstablish `protobuf:"bytes,7,opt,name=conn,proto3,oneof"`
 }
-func (m *PeerTime) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_PeerTime.Merge(m, src)
+
+type RKSyncMessage_Ack struct {
+	Ack *Acknowledgement `protobuf:"bytes,8,opt,name=ack,proto3,oneof"`
 }
-func (m *PeerTime) XXX_Size() int {
-	return m.Size()
+
+type RKSyncMessage_MemReq struct {
+	MemReq *MembershipRequest `protobuf:"bytes,9,opt,name=mem_req,json=memReq,proto3,oneof"`
 }
-func (m *PeerTime) XXX_DiscardUnknown() {
-	xxx_messageInfo_PeerTime.DiscardUnknown(m)
+
+type RKSyncMessage_MemRes struct {
+	MemRes *MembershipResponse `protobuf:"bytes,10,opt,name=mem_res,json=memRes,proto3,oneof"`
 }
 
-var xxx_messageInfo_PeerTime proto.InternalMessageInfo
+type RKSyncMessage_State struct {
+	State *ChainState `protobuf:"bytes,11,opt,name=state,proto3,oneof"`
+}
 
-type Member struct {
-	Endpoint             string   `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
-	PkiId                []byte   `protobuf:"bytes,2,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type RKSyncMessage_StatePullRequest struct {
+	StatePullRequest *ChainStatePullRequest `protobuf:"bytes,12,opt,name=state_pull_request,json=statePullRequest,proto3,oneof"`
 }
 
-func (m *Member) Reset()         { *m = Member{} }
-func (m *Member) String() string { return proto.CompactTextString(m) }
-func (*Member) ProtoMessage()    {}
-func (*Member) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{5}
-}
-func (m *Member) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *Member) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Member.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
-	}
+type RKSyncMessage_StatePullResponse struct {
+	StatePullResponse *ChainStatePullResponse `protobuf:"bytes,13,opt,name=state_pull_response,json=statePullResponse,proto3,oneof"`
 }
-func (m *Member) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Member.Merge(m, src)
+
+type RKSyncMessage_StateInfo struct {
+	StateInfo *ChainStateInfo `protobuf:"bytes,14,opt,name=state_info,json=stateInfo,proto3,oneof"`
 }
-func (m *Member) XXX_Size() int {
-	return m.Size()
+
+type RKSyncMessage_DataMsg struct {
+	DataMsg *DataMessage `protobuf:"bytes,15,opt,name=data_msg,json=dataMsg,proto3,oneof"`
 }
-func (m *Member) XXX_DiscardUnknown() {
-	xxx_messageInfo_Member.DiscardUnknown(m)
+
+type RKSyncMessage_DataReq struct {
+	DataReq *DataRequest `protobuf:"bytes,16,opt,name=data_req,json=dataReq,proto3,oneof"`
 }
 
-var xxx_messageInfo_Member proto.InternalMessageInfo
+type RKSyncMessage_LeaveChain struct {
+	LeaveChain *LeaveChainMessage `protobuf:"bytes,17,opt,name=leave_chain,json=leaveChain,proto3,oneof"`
+}
 
-type Acknowledgement struct {
-	Error                string   `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type RKSyncMessage_ChangeLogReq struct {
+	ChangeLogReq *ChangeLogRequest `protobuf:"bytes,18,opt,name=change_log_req,json=changeLogReq,proto3,oneof"`
 }
 
-func (m *Acknowledgement) Reset()         { *m = Acknowledgement{} }
-func (m *Acknowledgement) String() string { return proto.CompactTextString(m) }
-func (*Acknowledgement) ProtoMessage()    {}
-func (*Acknowledgement) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{6}
-}
-func (m *Acknowledgement) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *Acknowledgement) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Acknowledgement.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
-	}
+type RKSyncMessage_ChangeLogRes struct {
+	ChangeLogRes *ChangeLogResponse `protobuf:"bytes,19,opt,name=change_log_res,json=changeLogRes,proto3,oneof"`
 }
-func (m *Acknowledgement) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Acknowledgement.Merge(m, src)
+
+type RKSyncMessage_StateReq struct {
+	StateReq *StateRequest `protobuf:"bytes,20,opt,name=state_req,json=stateReq,proto3,oneof"`
 }
-func (m *Acknowledgement) XXX_Size() int {
-	return m.Size()
+
+type RKSyncMessage_StateRes struct {
+	StateRes *StateResponse `protobuf:"bytes,21,opt,name=state_res,json=stateRes,proto3,oneof"`
 }
-func (m *Acknowledgement) XXX_DiscardUnknown() {
-	xxx_messageInfo_Acknowledgement.DiscardUnknown(m)
+
+type RKSyncMessage_LeadershipDeclaration struct {
+	LeadershipDeclaration *LeadershipDeclaration `protobuf:"bytes,22,opt,name=leadership_declaration,json=leadershipDeclaration,proto3,oneof"`
 }
 
-var xxx_messageInfo_Acknowledgement proto.InternalMessageInfo
+type RKSyncMessage_LeadershipVote struct {
+	LeadershipVote *LeadershipVote `protobuf:"bytes,23,opt,name=leadership_vote,json=leadershipVote,proto3,oneof"`
+}
 
-type MembershipRequest struct {
-	SelfInformation      *Envelope   `protobuf:"bytes,1,opt,name=self_information,json=selfInformation,proto3" json:"self_information,omitempty"`
-	Known                []*Envelope `protobuf:"bytes,2,rep,name=known,proto3" json:"known,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
-	XXX_unrecognized     []byte      `json:"-"`
-	XXX_sizecache        int32       `json:"-"`
+type RKSyncMessage_FileAck struct {
+	FileAck *FileAck `protobuf:"bytes,24,opt,name=file_ack,json=fileAck,proto3,oneof"`
 }
 
-func (m *MembershipRequest) Reset()         { *m = MembershipRequest{} }
-func (m *MembershipRequest) String() string { return proto.CompactTextString(m) }
-func (*MembershipRequest) ProtoMessage()    {}
-func (*MembershipRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{7}
-}
-func (m *MembershipRequest) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *MembershipRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_MembershipRequest.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
-	}
+type RKSyncMessage_IdentityReq struct {
+	IdentityReq *PeerIdentityRequest `protobuf:"bytes,25,opt,name=identity_req,json=identityReq,proto3,oneof"`
 }
-func (m *MembershipRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_MembershipRequest.Merge(m, src)
+
+type RKSyncMessage_IdentityRes struct {
+	IdentityRes *PeerIdentityResponse `protobuf:"bytes,26,opt,name=identity_res,json=identityRes,proto3,oneof"`
 }
-func (m *MembershipRequest) XXX_Size() int {
-	return m.Size()
+
+type RKSyncMessage_ChannelInvitation struct {
+	ChannelInvitation *ChannelInvitation `protobuf:"bytes,27,opt,name=channel_invitation,json=channelInvitation,proto3,oneof"`
 }
-func (m *MembershipRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_MembershipRequest.DiscardUnknown(m)
+
+type RKSyncMessage_ChannelAcceptance struct {
+	ChannelAcceptance *ChannelAcceptance `protobuf:"bytes,28,opt,name=channel_acceptance,json=channelAcceptance,proto3,oneof"`
 }
 
-var xxx_messageInfo_MembershipRequest proto.InternalMessageInfo
+func (*RKSyncMessage_AliveMsg) isRKSyncMessage_Content() {}
 
-type MembershipResponse struct {
-	Alive                []*Envelope `protobuf:"bytes,1,rep,name=alive,proto3" json:"alive,omitempty"`
-	Dead                 []*Envelope `protobuf:"bytes,2,rep,name=dead,proto3" json:"dead,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
-	XXX_unrecognized     []byte      `json:"-"`
-	XXX_sizecache        int32       `json:"-"`
+func (*RKSyncMessage_Empty) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_Conn) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_Ack) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_MemReq) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_MemRes) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_State) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_StatePullRequest) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_StatePullResponse) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_StateInfo) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_DataMsg) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_DataReq) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_LeaveChain) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_ChangeLogReq) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_ChangeLogRes) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_StateReq) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_StateRes) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_LeadershipDeclaration) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_LeadershipVote) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_FileAck) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_IdentityReq) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_IdentityRes) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_ChannelInvitation) isRKSyncMessage_Content() {}
+
+func (*RKSyncMessage_ChannelAcceptance) isRKSyncMessage_Content() {}
+
+type ConnEstablish struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PkiId    []byte `protobuf:"bytes,1,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	Identity []byte `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
+	// Highest version of the protocol the peer speaks, 0 for the peers which predate the negotiation
+	ProtocolVersion uint32 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// Oldest version of the protocol the peer accepts to speak
+	MinProtocolVersion uint32 `protobuf:"varint,4,opt,name=min_protocol_version,json=minProtocolVersion,proto3" json:"min_protocol_version,omitempty"`
 }
 
-func (m *MembershipResponse) Reset()         { *m = MembershipResponse{} }
-func (m *MembershipResponse) String() string { return proto.CompactTextString(m) }
-func (*MembershipResponse) ProtoMessage()    {}
-func (*MembershipResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{8}
-}
-func (m *MembershipResponse) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *MembershipResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_MembershipResponse.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *ConnEstablish) Reset() {
+	*x = ConnEstablish{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *MembershipResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_MembershipResponse.Merge(m, src)
-}
-func (m *MembershipResponse) XXX_Size() int {
-	return m.Size()
-}
-func (m *MembershipResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_MembershipResponse.DiscardUnknown(m)
-}
-
-var xxx_messageInfo_MembershipResponse proto.InternalMessageInfo
 
-type ChainState struct {
-	SeqNum               uint64    `protobuf:"varint,1,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
-	ChainId              string    `protobuf:"bytes,2,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
-	Envelope             *Envelope `protobuf:"bytes,3,opt,name=envelope,proto3" json:"envelope,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+func (x *ConnEstablish) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (m *ChainState) Reset()         { *m = ChainState{} }
-func (m *ChainState) String() string { return proto.CompactTextString(m) }
-func (*ChainState) ProtoMessage()    {}
-func (*ChainState) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{9}
-}
-func (m *ChainState) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *ChainState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ChainState.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+func (*ConnEstablish) ProtoMessage() {}
+
+func (x *ConnEstablish) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
 	}
-}
-func (m *ChainState) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ChainState.Merge(m, src)
-}
-func (m *ChainState) XXX_Size() int {
-	return m.Size()
-}
-func (m *ChainState) XXX_DiscardUnknown() {
-	xxx_messageInfo_ChainState.DiscardUnknown(m)
+	return mi.MessageOf(x)
 }
 
-var xxx_messageInfo_ChainState proto.InternalMessageInfo
-
-type ChainStateInfo struct {
-	Leader               []byte      `protobuf:"bytes,1,opt,name=leader,proto3" json:"leader,omitempty"`
-	Properties           *Properties `protobuf:"bytes,2,opt,name=properties,proto3" json:"properties,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
-	XXX_unrecognized     []byte      `json:"-"`
-	XXX_sizecache        int32       `json:"-"`
+// Deprecated: Use ConnEstablish.ProtoReflect.Descriptor instead.
+func (*ConnEstablish) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{4}
 }
 
-func (m *ChainStateInfo) Reset()         { *m = ChainStateInfo{} }
-func (m *ChainStateInfo) String() string { return proto.CompactTextString(m) }
-func (*ChainStateInfo) ProtoMessage()    {}
-func (*ChainStateInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{10}
-}
-func (m *ChainStateInfo) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *ChainStateInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ChainStateInfo.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *ConnEstablish) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
 	}
+	return nil
 }
-func (m *ChainStateInfo) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ChainStateInfo.Merge(m, src)
+
+func (x *ConnEstablish) GetIdentity() []byte {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
 }
-func (m *ChainStateInfo) XXX_Size() int {
-	return m.Size()
+
+func (x *ConnEstablish) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
 }
-func (m *ChainStateInfo) XXX_DiscardUnknown() {
-	xxx_messageInfo_ChainStateInfo.DiscardUnknown(m)
+
+func (x *ConnEstablish) GetMinProtocolVersion() uint32 {
+	if x != nil {
+		return x.MinProtocolVersion
+	}
+	return 0
 }
 
-var xxx_messageInfo_ChainStateInfo proto.InternalMessageInfo
+type AliveMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-type Properties struct {
-	Members              [][]byte `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
-	Files                []*File  `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Membership *Member   `protobuf:"bytes,1,opt,name=membership,proto3" json:"membership,omitempty"`
+	Timestamp  *PeerTime `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Identity   []byte    `protobuf:"bytes,3,opt,name=identity,proto3" json:"identity,omitempty"`
+	Leaving    bool      `protobuf:"varint,4,opt,name=leaving,proto3" json:"leaving,omitempty"`
 }
 
-func (m *Properties) Reset()         { *m = Properties{} }
-func (m *Properties) String() string { return proto.CompactTextString(m) }
-func (*Properties) ProtoMessage()    {}
-func (*Properties) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{11}
-}
-func (m *Properties) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *Properties) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Properties.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *AliveMessage) Reset() {
+	*x = AliveMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *Properties) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Properties.Merge(m, src)
-}
-func (m *Properties) XXX_Size() int {
-	return m.Size()
-}
-func (m *Properties) XXX_DiscardUnknown() {
-	xxx_messageInfo_Properties.DiscardUnknown(m)
-}
 
-var xxx_messageInfo_Properties proto.InternalMessageInfo
-
-type File struct {
-	Path                 string    `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
-	Mode                 File_Mode `protobuf:"varint,2,opt,name=mode,proto3,enum=protos.File_Mode" json:"mode,omitempty"`
-	Metadata             []byte    `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+func (x *AliveMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (m *File) Reset()         { *m = File{} }
-func (m *File) String() string { return proto.CompactTextString(m) }
-func (*File) ProtoMessage()    {}
-func (*File) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{12}
-}
-func (m *File) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *File) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_File.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+func (*AliveMessage) ProtoMessage() {}
+
+func (x *AliveMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
 	}
+	return mi.MessageOf(x)
 }
-func (m *File) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_File.Merge(m, src)
-}
-func (m *File) XXX_Size() int {
-	return m.Size()
-}
-func (m *File) XXX_DiscardUnknown() {
-	xxx_messageInfo_File.DiscardUnknown(m)
-}
-
-var xxx_messageInfo_File proto.InternalMessageInfo
 
-type ChainStatePullResponse struct {
-	Element              *Envelope `protobuf:"bytes,1,opt,name=element,proto3" json:"element,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+// Deprecated: Use AliveMessage.ProtoReflect.Descriptor instead.
+func (*AliveMessage) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{5}
 }
 
-func (m *ChainStatePullResponse) Reset()         { *m = ChainStatePullResponse{} }
-func (m *ChainStatePullResponse) String() string { return proto.CompactTextString(m) }
-func (*ChainStatePullResponse) ProtoMessage()    {}
-func (*ChainStatePullResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{13}
-}
-func (m *ChainStatePullResponse) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *ChainStatePullResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ChainStatePullResponse.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *AliveMessage) GetMembership() *Member {
+	if x != nil {
+		return x.Membership
 	}
+	return nil
 }
-func (m *ChainStatePullResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ChainStatePullResponse.Merge(m, src)
+
+func (x *AliveMessage) GetTimestamp() *PeerTime {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
 }
-func (m *ChainStatePullResponse) XXX_Size() int {
-	return m.Size()
+
+func (x *AliveMessage) GetIdentity() []byte {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
 }
-func (m *ChainStatePullResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_ChainStatePullResponse.DiscardUnknown(m)
+
+func (x *AliveMessage) GetLeaving() bool {
+	if x != nil {
+		return x.Leaving
+	}
+	return false
 }
 
-var xxx_messageInfo_ChainStatePullResponse proto.InternalMessageInfo
+type PeerTime struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-type ChainStatePullRequest struct {
-	Timestamp            *PeerTime `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	IncNum uint64 `protobuf:"varint,1,opt,name=inc_num,json=incNum,proto3" json:"inc_num,omitempty"`
+	SeqNum uint64 `protobuf:"varint,2,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
 }
 
-func (m *ChainStatePullRequest) Reset()         { *m = ChainStatePullRequest{} }
-func (m *ChainStatePullRequest) String() string { return proto.CompactTextString(m) }
-func (*ChainStatePullRequest) ProtoMessage()    {}
-func (*ChainStatePullRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{14}
-}
-func (m *ChainStatePullRequest) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *ChainStatePullRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ChainStatePullRequest.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *PeerTime) Reset() {
+	*x = PeerTime{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *ChainStatePullRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ChainStatePullRequest.Merge(m, src)
-}
-func (m *ChainStatePullRequest) XXX_Size() int {
-	return m.Size()
-}
-func (m *ChainStatePullRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_ChainStatePullRequest.DiscardUnknown(m)
-}
 
-var xxx_messageInfo_ChainStatePullRequest proto.InternalMessageInfo
-
-type DataMessage struct {
-	FileName             string   `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
-	Payload              *Payload `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+func (x *PeerTime) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (m *DataMessage) Reset()         { *m = DataMessage{} }
-func (m *DataMessage) String() string { return proto.CompactTextString(m) }
-func (*DataMessage) ProtoMessage()    {}
-func (*DataMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{15}
-}
-func (m *DataMessage) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *DataMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_DataMessage.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+func (*PeerTime) ProtoMessage() {}
+
+func (x *PeerTime) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
 	}
+	return mi.MessageOf(x)
 }
-func (m *DataMessage) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_DataMessage.Merge(m, src)
+
+// Deprecated: Use PeerTime.ProtoReflect.Descriptor instead.
+func (*PeerTime) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{6}
 }
-func (m *DataMessage) XXX_Size() int {
-	return m.Size()
+
+func (x *PeerTime) GetIncNum() uint64 {
+	if x != nil {
+		return x.IncNum
+	}
+	return 0
 }
-func (m *DataMessage) XXX_DiscardUnknown() {
-	xxx_messageInfo_DataMessage.DiscardUnknown(m)
+
+func (x *PeerTime) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
+	}
+	return 0
 }
 
-var xxx_messageInfo_DataMessage proto.InternalMessageInfo
+type Member struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-type Payload struct {
-	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	// Types that are valid to be assigned to Metadata:
-	//	*Payload_Append
-	Metadata             isPayload_Metadata `protobuf_oneof:"metadata"`
-	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
-	XXX_unrecognized     []byte             `json:"-"`
-	XXX_sizecache        int32              `json:"-"`
+	Endpoint string            `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	PkiId    []byte            `protobuf:"bytes,2,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	Tags     []string          `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	Zone     string            `protobuf:"bytes,4,opt,name=zone,proto3" json:"zone,omitempty"`
+	Org      string            `protobuf:"bytes,5,opt,name=org,proto3" json:"org,omitempty"`
+	Labels   map[string]string `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
-func (m *Payload) Reset()         { *m = Payload{} }
-func (m *Payload) String() string { return proto.CompactTextString(m) }
-func (*Payload) ProtoMessage()    {}
-func (*Payload) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{16}
-}
-func (m *Payload) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *Payload) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Payload.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *Member) Reset() {
+	*x = Member{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *Payload) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Payload.Merge(m, src)
-}
-func (m *Payload) XXX_Size() int {
-	return m.Size()
-}
-func (m *Payload) XXX_DiscardUnknown() {
-	xxx_messageInfo_Payload.DiscardUnknown(m)
+
+func (x *Member) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var xxx_messageInfo_Payload proto.InternalMessageInfo
+func (*Member) ProtoMessage() {}
 
-type isPayload_Metadata interface {
-	isPayload_Metadata()
-	MarshalTo([]byte) (int, error)
-	Size() int
+func (x *Member) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-type Payload_Append struct {
-	Append *AppendMetadata `protobuf:"bytes,3,opt,name=append,proto3,oneof"`
+// Deprecated: Use Member.ProtoReflect.Descriptor instead.
+func (*Member) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{7}
 }
 
-func (*Payload_Append) isPayload_Metadata() {}
+func (x *Member) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
 
-func (m *Payload) GetMetadata() isPayload_Metadata {
-	if m != nil {
-		return m.Metadata
+func (x *Member) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
 	}
 	return nil
 }
 
-func (m *Payload) GetAppend() *AppendMetadata {
-	if x, ok := m.GetMetadata().(*Payload_Append); ok {
-		return x.Append
+func (x *Member) GetTags() []string {
+	if x != nil {
+		return x.Tags
 	}
 	return nil
 }
 
-// XXX_OneofFuncs is for the internal use of the proto package.
-func (*Payload) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
-	return _Payload_OneofMarshaler, _Payload_OneofUnmarshaler, _Payload_OneofSizer, []interface{}{
-		(*Payload_Append)(nil),
+func (x *Member) GetZone() string {
+	if x != nil {
+		return x.Zone
 	}
+	return ""
 }
 
-func _Payload_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
-	m := msg.(*Payload)
-	// metadata
-	switch x := m.Metadata.(type) {
-	case *Payload_Append:
-		_ = b.EncodeVarint(3<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Append); err != nil {
-			return err
-		}
-	case nil:
-	default:
-		return fmt.Errorf("Payload.Metadata has unexpected type %T", x)
+func (x *Member) GetOrg() string {
+	if x != nil {
+		return x.Org
 	}
-	return nil
+	return ""
 }
 
-func _Payload_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
-	m := msg.(*Payload)
-	switch tag {
-	case 3: // metadata.append
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(AppendMetadata)
-		err := b.DecodeMessage(msg)
-		m.Metadata = &Payload_Append{msg}
-		return true, err
-	default:
-		return false, nil
+func (x *Member) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
 	}
+	return nil
+}
+
+type Acknowledgement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Error string `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
 }
 
-func _Payload_OneofSizer(msg proto.Message) (n int) {
-	m := msg.(*Payload)
-	// metadata
-	switch x := m.Metadata.(type) {
-	case *Payload_Append:
-		s := proto.Size(x.Append)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case nil:
-	default:
-		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+func (x *Acknowledgement) Reset() {
+	*x = Acknowledgement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return n
 }
 
-type AppendMetadata struct {
-	Start                int64    `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
-	Length               int64    `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+func (x *Acknowledgement) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (m *AppendMetadata) Reset()         { *m = AppendMetadata{} }
-func (m *AppendMetadata) String() string { return proto.CompactTextString(m) }
-func (*AppendMetadata) ProtoMessage()    {}
-func (*AppendMetadata) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{17}
-}
-func (m *AppendMetadata) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *AppendMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_AppendMetadata.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+func (*Acknowledgement) ProtoMessage() {}
+
+func (x *Acknowledgement) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
 	}
+	return mi.MessageOf(x)
 }
-func (m *AppendMetadata) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_AppendMetadata.Merge(m, src)
-}
-func (m *AppendMetadata) XXX_Size() int {
-	return m.Size()
+
+// Deprecated: Use Acknowledgement.ProtoReflect.Descriptor instead.
+func (*Acknowledgement) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{8}
 }
-func (m *AppendMetadata) XXX_DiscardUnknown() {
-	xxx_messageInfo_AppendMetadata.DiscardUnknown(m)
+
+func (x *Acknowledgement) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
 }
 
-var xxx_messageInfo_AppendMetadata proto.InternalMessageInfo
+type MembershipRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-type DataRequest struct {
-	FileName string `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
-	PkiId    []byte `protobuf:"bytes,2,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
-	// Types that are valid to be assigned to Req:
-	//	*DataRequest_Append
-	Req                  isDataRequest_Req `protobuf_oneof:"req"`
-	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
-	XXX_unrecognized     []byte            `json:"-"`
-	XXX_sizecache        int32             `json:"-"`
+	SelfInformation *Envelope           `protobuf:"bytes,1,opt,name=self_information,json=selfInformation,proto3" json:"self_information,omitempty"`
+	Known           []*Envelope         `protobuf:"bytes,2,rep,name=known,proto3" json:"known,omitempty"`
+	Digests         []*MembershipDigest `protobuf:"bytes,3,rep,name=digests,proto3" json:"digests,omitempty"`
 }
 
-func (m *DataRequest) Reset()         { *m = DataRequest{} }
-func (m *DataRequest) String() string { return proto.CompactTextString(m) }
-func (*DataRequest) ProtoMessage()    {}
-func (*DataRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{18}
-}
-func (m *DataRequest) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *DataRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_DataRequest.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (x *MembershipRequest) Reset() {
+	*x = MembershipRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
-func (m *DataRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_DataRequest.Merge(m, src)
-}
-func (m *DataRequest) XXX_Size() int {
-	return m.Size()
-}
-func (m *DataRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_DataRequest.DiscardUnknown(m)
+
+func (x *MembershipRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var xxx_messageInfo_DataRequest proto.InternalMessageInfo
+func (*MembershipRequest) ProtoMessage() {}
 
-type isDataRequest_Req interface {
-	isDataRequest_Req()
-	MarshalTo([]byte) (int, error)
-	Size() int
+func (x *MembershipRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-type DataRequest_Append struct {
-	Append *AppendRequest `protobuf:"bytes,4,opt,name=append,proto3,oneof"`
+// Deprecated: Use MembershipRequest.ProtoReflect.Descriptor instead.
+func (*MembershipRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{9}
 }
 
-func (*DataRequest_Append) isDataRequest_Req() {}
-
-func (m *DataRequest) GetReq() isDataRequest_Req {
-	if m != nil {
-		return m.Req
+func (x *MembershipRequest) GetSelfInformation() *Envelope {
+	if x != nil {
+		return x.SelfInformation
 	}
 	return nil
 }
 
-func (m *DataRequest) GetAppend() *AppendRequest {
-	if x, ok := m.GetReq().(*DataRequest_Append); ok {
-		return x.Append
+func (x *MembershipRequest) GetKnown() []*Envelope {
+	if x != nil {
+		return x.Known
 	}
 	return nil
 }
 
-// XXX_OneofFuncs is for the internal use of the proto package.
-func (*DataRequest) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
-	return _DataRequest_OneofMarshaler, _DataRequest_OneofUnmarshaler, _DataRequest_OneofSizer, []interface{}{
-		(*DataRequest_Append)(nil),
+func (x *MembershipRequest) GetDigests() []*MembershipDigest {
+	if x != nil {
+		return x.Digests
 	}
+	return nil
 }
 
-func _DataRequest_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
-	m := msg.(*DataRequest)
-	// req
-	switch x := m.Req.(type) {
-	case *DataRequest_Append:
-		_ = b.EncodeVarint(4<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Append); err != nil {
-			return err
-		}
-	case nil:
-	default:
-		return fmt.Errorf("DataRequest.Req has unexpected type %T", x)
-	}
-	return nil
+type MembershipResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Alive  []*Envelope `protobuf:"bytes,1,rep,name=alive,proto3" json:"alive,omitempty"`
+	Dead   []*Envelope `protobuf:"bytes,2,rep,name=dead,proto3" json:"dead,omitempty"`
+	Wanted [][]byte    `protobuf:"bytes,3,rep,name=wanted,proto3" json:"wanted,omitempty"`
 }
 
-func _DataRequest_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
-	m := msg.(*DataRequest)
-	switch tag {
-	case 4: // req.append
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(AppendRequest)
-		err := b.DecodeMessage(msg)
-		m.Req = &DataRequest_Append{msg}
-		return true, err
-	default:
-		return false, nil
+func (x *MembershipResponse) Reset() {
+	*x = MembershipResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
 
-func _DataRequest_OneofSizer(msg proto.Message) (n int) {
-	m := msg.(*DataRequest)
-	// req
-	switch x := m.Req.(type) {
-	case *DataRequest_Append:
-		s := proto.Size(x.Append)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case nil:
-	default:
-		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
-	}
-	return n
+func (x *MembershipResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type AppendRequest struct {
-	Length               int64    `protobuf:"varint,1,opt,name=length,proto3" json:"length,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
-}
+func (*MembershipResponse) ProtoMessage() {}
 
-func (m *AppendRequest) Reset()         { *m = AppendRequest{} }
-func (m *AppendRequest) String() string { return proto.CompactTextString(m) }
-func (*AppendRequest) ProtoMessage()    {}
-func (*AppendRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{19}
-}
-func (m *AppendRequest) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *AppendRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_AppendRequest.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
+func (x *MembershipResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		return b[:n], nil
+		return ms
 	}
-}
-func (m *AppendRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_AppendRequest.Merge(m, src)
-}
-func (m *AppendRequest) XXX_Size() int {
-	return m.Size()
-}
-func (m *AppendRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_AppendRequest.DiscardUnknown(m)
+	return mi.MessageOf(x)
 }
 
-var xxx_messageInfo_AppendRequest proto.InternalMessageInfo
+// Deprecated: Use MembershipResponse.ProtoReflect.Descriptor instead.
+func (*MembershipResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{10}
+}
 
-type LeaveChainMessage struct {
-	ChainMac             []byte   `protobuf:"bytes,1,opt,name=chain_mac,json=chainMac,proto3" json:"chain_mac,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+func (x *MembershipResponse) GetAlive() []*Envelope {
+	if x != nil {
+		return x.Alive
+	}
+	return nil
 }
 
-func (m *LeaveChainMessage) Reset()         { *m = LeaveChainMessage{} }
-func (m *LeaveChainMessage) String() string { return proto.CompactTextString(m) }
-func (*LeaveChainMessage) ProtoMessage()    {}
-func (*LeaveChainMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cff4fef9b2151f97, []int{20}
-}
-func (m *LeaveChainMessage) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
-}
-func (m *LeaveChainMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_LeaveChainMessage.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalTo(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
-	}
-}
-func (m *LeaveChainMessage) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_LeaveChainMessage.Merge(m, src)
-}
-func (m *LeaveChainMessage) XXX_Size() int {
-	return m.Size()
-}
-func (m *LeaveChainMessage) XXX_DiscardUnknown() {
-	xxx_messageInfo_LeaveChainMessage.DiscardUnknown(m)
-}
-
-var xxx_messageInfo_LeaveChainMessage proto.InternalMessageInfo
-
-func init() {
-	proto.RegisterEnum("protos.RKSyncMessage_Tag", RKSyncMessage_Tag_name, RKSyncMessage_Tag_value)
-	proto.RegisterEnum("protos.File_Mode", File_Mode_name, File_Mode_value)
-	proto.RegisterType((*Envelope)(nil), "protos.Envelope")
-	proto.RegisterType((*RKSyncMessage)(nil), "protos.RKSyncMessage")
-	proto.RegisterType((*ConnEstablish)(nil), "protos.ConnEstablish")
-	proto.RegisterType((*AliveMessage)(nil), "protos.AliveMessage")
-	proto.RegisterType((*PeerTime)(nil), "protos.PeerTime")
-	proto.RegisterType((*Member)(nil), "protos.Member")
-	proto.RegisterType((*Acknowledgement)(nil), "protos.Acknowledgement")
-	proto.RegisterType((*MembershipRequest)(nil), "protos.MembershipRequest")
-	proto.RegisterType((*MembershipResponse)(nil), "protos.MembershipResponse")
-	proto.RegisterType((*ChainState)(nil), "protos.ChainState")
-	proto.RegisterType((*ChainStateInfo)(nil), "protos.ChainStateInfo")
-	proto.RegisterType((*Properties)(nil), "protos.Properties")
-	proto.RegisterType((*File)(nil), "protos.File")
-	proto.RegisterType((*ChainStatePullResponse)(nil), "protos.ChainStatePullResponse")
-	proto.RegisterType((*ChainStatePullRequest)(nil), "protos.ChainStatePullRequest")
-	proto.RegisterType((*DataMessage)(nil), "protos.DataMessage")
-	proto.RegisterType((*Payload)(nil), "protos.Payload")
-	proto.RegisterType((*AppendMetadata)(nil), "protos.AppendMetadata")
-	proto.RegisterType((*DataRequest)(nil), "protos.DataRequest")
-	proto.RegisterType((*AppendRequest)(nil), "protos.AppendRequest")
-	proto.RegisterType((*LeaveChainMessage)(nil), "protos.LeaveChainMessage")
-}
-
-func init() {
-	proto.RegisterFile("github.com/rkcloudchain/rksync/protos/rksync.proto", fileDescriptor_cff4fef9b2151f97)
-}
-
-var fileDescriptor_cff4fef9b2151f97 = []byte{
-	// 1249 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xeb, 0x6e, 0xdc, 0x44,
-	0x14, 0x5e, 0x67, 0xef, 0x27, 0xb7, 0xcd, 0xf4, 0xe6, 0xa6, 0xb0, 0x44, 0x16, 0xa5, 0xa1, 0x45,
-	0x9b, 0x68, 0xcb, 0x4d, 0x6a, 0x05, 0x6a, 0xda, 0x40, 0x02, 0xdd, 0x10, 0xb9, 0xe1, 0x47, 0x01,
-	0x69, 0x35, 0xb1, 0x4f, 0x1c, 0x2b, 0xf6, 0x8c, 0xe3, 0x99, 0x2d, 0x04, 0x89, 0x17, 0xe0, 0x09,
-	0x78, 0xa4, 0xfe, 0xec, 0x23, 0xd0, 0xf0, 0x00, 0xbc, 0x02, 0x9a, 0x19, 0xdb, 0xbb, 0xce, 0xee,
-	0x02, 0xbf, 0x3c, 0xe7, 0xf2, 0x1d, 0x9f, 0xf9, 0xe6, 0x9c, 0x33, 0x03, 0xfd, 0x20, 0x94, 0xa7,
-	0xa3, 0xe3, 0x9e, 0xc7, 0xe3, 0xad, 0xf4, 0xcc, 0x8b, 0xf8, 0xc8, 0xf7, 0x4e, 0x69, 0xc8, 0xb6,
-	0xd2, 0x33, 0x71, 0xc1, 0xbc, 0xad, 0x24, 0xe5, 0x92, 0x8b, 0x4c, 0xea, 0x69, 0x89, 0x34, 0x8c,
-	0x72, 0xfd, 0x4e, 0xc0, 0x79, 0x10, 0xa1, 0xf1, 0x39, 0x1e, 0x9d, 0x6c, 0x61, 0x9c, 0xc8, 0x0b,
-	0xe3, 0xb4, 0x7e, 0x3d, 0xe0, 0x01, 0xd7, 0xcb, 0x2d, 0xb5, 0x32, 0x5a, 0x67, 0x07, 0x5a, 0xbb,
-	0xec, 0x15, 0x46, 0x3c, 0x41, 0x62, 0x43, 0x33, 0xa1, 0x17, 0x11, 0xa7, 0xbe, 0x6d, 0x6d, 0x58,
-	0x9b, 0x4b, 0x6e, 0x2e, 0x92, 0x77, 0xa0, 0x2d, 0xc2, 0x80, 0x51, 0x39, 0x4a, 0xd1, 0x5e, 0xd0,
-	0xb6, 0xb1, 0xc2, 0xf9, 0xbb, 0x01, 0xcb, 0xee, 0xb7, 0x2f, 0x2e, 0x98, 0x37, 0x40, 0x21, 0x68,
-	0x80, 0xe4, 0x3a, 0xd4, 0x19, 0x67, 0x1e, 0xea, 0x38, 0x35, 0xd7, 0x08, 0xe4, 0x0e, 0xb4, 0xf5,
-	0x56, 0x86, 0x31, 0xf5, 0xb2, 0x28, 0x2d, 0xad, 0x18, 0x50, 0x8f, 0x3c, 0x80, 0xaa, 0xa4, 0x81,
-	0x5d, 0xdd, 0xb0, 0x36, 0x57, 0xfa, 0xb7, 0x4d, 0x76, 0xa2, 0x57, 0x0a, 0xdb, 0x3b, 0xa2, 0x81,
-	0xab, 0xbc, 0xc8, 0x43, 0x68, 0xd3, 0x28, 0x7c, 0x85, 0xc3, 0x58, 0x04, 0x76, 0x7d, 0xc3, 0xda,
-	0x5c, 0xec, 0x5f, 0xcf, 0x21, 0x4f, 0x94, 0x21, 0x43, 0xec, 0x55, 0xdc, 0x96, 0x76, 0x1c, 0x88,
-	0x80, 0xf4, 0xa0, 0xae, 0xf9, 0xb0, 0x1b, 0x1a, 0x70, 0xb3, 0x67, 0xd8, 0xea, 0xe5, 0x6c, 0xf5,
-	0x76, 0x95, 0x75, 0xaf, 0xe2, 0x1a, 0x37, 0xf2, 0x00, 0x6a, 0x1e, 0x67, 0xcc, 0x6e, 0x6a, 0xf7,
-	0x1b, 0x79, 0xfc, 0xa7, 0x9c, 0xb1, 0x5d, 0x21, 0xe9, 0x71, 0x14, 0x8a, 0xd3, 0xbd, 0x8a, 0xab,
-	0x9d, 0x54, 0xfa, 0xd4, 0x3b, 0xb3, 0x5b, 0xda, 0xf7, 0x56, 0x91, 0x8b, 0x77, 0xc6, 0xf8, 0xcf,
-	0x11, 0xfa, 0x01, 0xc6, 0xc8, 0xe4, 0x5e, 0xc5, 0x55, 0x5e, 0xe4, 0x63, 0x68, 0xc6, 0x18, 0x0f,
-	0x53, 0x3c, 0xb7, 0xdb, 0x1a, 0x50, 0xec, 0x77, 0x80, 0xf1, 0x31, 0xa6, 0xe2, 0x34, 0x4c, 0x5c,
-	0x3c, 0x1f, 0xa1, 0x50, 0x90, 0x46, 0x8c, 0xb1, 0x8b, 0xe7, 0xe4, 0x93, 0x1c, 0x25, 0x6c, 0xd0,
-	0xa8, 0xf5, 0x59, 0x28, 0x91, 0x70, 0x26, 0xb0, 0x80, 0x09, 0x72, 0x1f, 0xea, 0x42, 0x52, 0x89,
-	0xf6, 0xa2, 0x06, 0x91, 0x62, 0x1f, 0x8a, 0xf9, 0x17, 0xca, 0xa2, 0xb6, 0xac, 0x5d, 0xc8, 0x00,
-	0x88, 0x5e, 0x0c, 0x93, 0x51, 0x14, 0xa9, 0xfc, 0x54, 0x0a, 0xf6, 0x92, 0x06, 0xbe, 0x3b, 0x0d,
-	0x3c, 0x1c, 0x45, 0xd1, 0x38, 0xcf, 0x8e, 0xb8, 0xa2, 0x23, 0x87, 0x70, 0xad, 0x14, 0xce, 0xe4,
-	0x66, 0x2f, 0xeb, 0x78, 0xdd, 0x79, 0xf1, 0x8a, 0x1d, 0xac, 0x89, 0xab, 0x4a, 0xf2, 0x19, 0x80,
-	0x89, 0x18, 0xb2, 0x13, 0x6e, 0xaf, 0x64, 0x07, 0x39, 0x15, 0x68, 0x9f, 0x9d, 0xf0, 0xbd, 0x8a,
-	0xdb, 0x16, 0xb9, 0x40, 0xb6, 0xa1, 0xe5, 0x53, 0x49, 0x75, 0xc1, 0xac, 0x6a, 0xd8, 0xb5, 0x1c,
-	0xf6, 0x8c, 0x4a, 0x3a, 0xae, 0x97, 0xa6, 0x72, 0x53, 0xe5, 0x92, 0x23, 0xd4, 0x29, 0x75, 0xa6,
-	0x11, 0xe3, 0x7d, 0x6b, 0x84, 0x3a, 0xa0, 0xc7, 0xb0, 0x18, 0x21, 0x7d, 0x85, 0x43, 0x5d, 0xd4,
-	0xf6, 0x5a, 0xf9, 0x68, 0x9f, 0x2b, 0x93, 0x4e, 0x71, 0xfc, 0x33, 0x88, 0x0a, 0xa5, 0xf3, 0x1e,
-	0x54, 0x8f, 0x68, 0x40, 0xda, 0x50, 0xdf, 0x1d, 0x1c, 0x1e, 0xbd, 0xec, 0x54, 0xc8, 0x32, 0xb4,
-	0x9f, 0xee, 0x3d, 0x39, 0x18, 0x7e, 0x77, 0xf0, 0xfc, 0x65, 0xc7, 0xda, 0x69, 0x43, 0xd3, 0xe3,
-	0x4c, 0x22, 0x93, 0xce, 0x0e, 0x2c, 0x97, 0xca, 0x90, 0xdc, 0x80, 0x46, 0x72, 0x16, 0x0e, 0xc3,
-	0xbc, 0x73, 0xeb, 0xc9, 0x59, 0xb8, 0xef, 0x93, 0x75, 0x68, 0x85, 0x3e, 0x32, 0x19, 0xca, 0x8b,
-	0xbc, 0xe1, 0x72, 0xd9, 0xf9, 0xdd, 0x82, 0xa5, 0xc9, 0x5e, 0x21, 0x3d, 0x80, 0xb8, 0x28, 0x24,
-	0x1d, 0x67, 0xb1, 0xbf, 0x52, 0x2e, 0x31, 0x77, 0xc2, 0x83, 0xf4, 0xa0, 0x2d, 0xc3, 0x18, 0x85,
-	0xa4, 0x71, 0xa2, 0xa3, 0x2f, 0xf6, 0x3b, 0xb9, 0xfb, 0x21, 0x62, 0x7a, 0x14, 0xc6, 0xe8, 0x8e,
-	0x5d, 0x4a, 0xc9, 0x54, 0xaf, 0x24, 0xf3, 0x18, 0x5a, 0x39, 0x84, 0xdc, 0x82, 0x66, 0xc8, 0xbc,
-	0x21, 0x1b, 0xc5, 0xd9, 0xf8, 0x68, 0x84, 0xcc, 0x3b, 0x18, 0xc5, 0xca, 0x20, 0xf0, 0x5c, 0x1b,
-	0x16, 0x8c, 0x41, 0xe0, 0xf9, 0xc1, 0x28, 0x76, 0x1e, 0x41, 0xc3, 0xe4, 0xa7, 0xfe, 0x81, 0xcc,
-	0x4f, 0x78, 0xc8, 0xa4, 0x06, 0xb7, 0xdd, 0x42, 0x9e, 0xe0, 0x68, 0x61, 0x82, 0x23, 0xe7, 0x1e,
-	0xac, 0x5e, 0x69, 0x53, 0x35, 0xbe, 0x30, 0x4d, 0x79, 0x9a, 0x85, 0x30, 0x82, 0xf3, 0x0b, 0xac,
-	0x4d, 0xb5, 0x27, 0x79, 0x04, 0x1d, 0x81, 0xd1, 0x89, 0xae, 0xc7, 0x34, 0xa6, 0x32, 0xe4, 0x2c,
-	0xa3, 0xae, 0xe0, 0x22, 0x9f, 0xaf, 0xee, 0xaa, 0xf2, 0xdc, 0x1f, 0x3b, 0x92, 0x0f, 0xa0, 0xae,
-	0x7e, 0xcc, 0xec, 0x85, 0x8d, 0xea, 0x4c, 0x84, 0x31, 0x3b, 0xc7, 0x40, 0xa6, 0x5b, 0x5c, 0xa1,
-	0xf5, 0x6c, 0xb3, 0xad, 0x79, 0x68, 0x6d, 0x26, 0xef, 0x43, 0xcd, 0x47, 0xea, 0xcf, 0xfd, 0x89,
-	0xb6, 0x3a, 0x0c, 0x60, 0xdc, 0x3f, 0x93, 0x54, 0x5b, 0x93, 0x54, 0x93, 0xdb, 0x60, 0x46, 0x76,
-	0x4e, 0x63, 0xdb, 0x6d, 0x6a, 0x79, 0xdf, 0x27, 0x1f, 0x29, 0xee, 0x4d, 0x4c, 0x7d, 0xbe, 0xb3,
-	0xfe, 0x55, 0x78, 0x38, 0x3f, 0xc1, 0x4a, 0xb9, 0x5f, 0xc9, 0x4d, 0x68, 0x44, 0x48, 0x7d, 0x4c,
-	0xb3, 0x1a, 0xce, 0x24, 0xd2, 0x07, 0x48, 0x52, 0x9e, 0x60, 0x2a, 0x43, 0x14, 0x59, 0xa1, 0x15,
-	0x53, 0xec, 0xb0, 0xb0, 0xb8, 0x13, 0x5e, 0xce, 0x37, 0x00, 0x63, 0x8b, 0xba, 0xd8, 0xb2, 0xba,
-	0xd5, 0x5c, 0x2d, 0xb9, 0xb9, 0x48, 0x1c, 0xa8, 0x9f, 0x84, 0x91, 0x0e, 0xab, 0xc8, 0x59, 0xca,
-	0xc3, 0x7e, 0x15, 0x46, 0xe8, 0x1a, 0x93, 0xf3, 0x1b, 0xd4, 0x94, 0x48, 0x08, 0xd4, 0x12, 0x2a,
-	0x4f, 0xb3, 0xa2, 0xd0, 0x6b, 0x72, 0x17, 0x6a, 0x31, 0xf7, 0xcd, 0x9d, 0xb8, 0xd2, 0x5f, 0x9b,
-	0x84, 0xf7, 0x06, 0xdc, 0x47, 0x57, 0x9b, 0x55, 0x59, 0xc6, 0x28, 0xa9, 0x1a, 0x14, 0x79, 0xe9,
-	0xe7, 0xb2, 0xd3, 0x85, 0x9a, 0xf2, 0x24, 0x00, 0x8d, 0x27, 0x49, 0x82, 0xcc, 0xef, 0x54, 0xd4,
-	0xda, 0xa5, 0xcc, 0xe7, 0x71, 0xc7, 0x72, 0x9e, 0xc1, 0xcd, 0xd9, 0x13, 0x92, 0xdc, 0x87, 0x26,
-	0x46, 0xba, 0x62, 0xe7, 0x96, 0x5c, 0xee, 0xe0, 0x7c, 0x0d, 0x37, 0x66, 0xce, 0xed, 0x72, 0x17,
-	0x5b, 0xff, 0xd9, 0xc5, 0xce, 0xf7, 0xb0, 0x38, 0x31, 0x30, 0xd5, 0x9d, 0xae, 0x58, 0x1a, 0x32,
-	0x1a, 0x63, 0xde, 0x71, 0x4a, 0x71, 0x40, 0x63, 0x24, 0x1f, 0x8e, 0x1f, 0x14, 0xe6, 0xd8, 0x56,
-	0x8b, 0xc8, 0x46, 0x5d, 0xbc, 0x30, 0x9c, 0x1f, 0xa1, 0x99, 0xe9, 0x14, 0xcf, 0x9a, 0x28, 0x53,
-	0x05, 0x7a, 0x4d, 0xb6, 0xa1, 0x41, 0x35, 0x39, 0x59, 0x65, 0x15, 0x33, 0xdf, 0x50, 0x36, 0xc8,
-	0xc8, 0x54, 0xd7, 0x9e, 0xf1, 0xdb, 0x81, 0x31, 0xe5, 0xce, 0x17, 0xb0, 0x52, 0xf6, 0x53, 0x1d,
-	0x2e, 0x24, 0x4d, 0x0d, 0x71, 0x55, 0xd7, 0x08, 0xa6, 0x02, 0x59, 0x20, 0x4f, 0x75, 0xba, 0x55,
-	0x37, 0x93, 0x9c, 0x0b, 0xb3, 0xe7, 0x9c, 0xb2, 0x7f, 0xdd, 0xf3, 0xec, 0x29, 0x43, 0xb6, 0x8a,
-	0x0d, 0xd4, 0xca, 0xcf, 0x09, 0x93, 0xd8, 0xc4, 0x6d, 0x9f, 0xe5, 0x5f, 0x87, 0x6a, 0x8a, 0xe7,
-	0xce, 0x3d, 0x58, 0x2e, 0x79, 0x4c, 0xe4, 0x68, 0x95, 0x72, 0xdc, 0x86, 0xb5, 0xa9, 0x1b, 0xa6,
-	0xfc, 0xe2, 0xb2, 0xca, 0x2f, 0xae, 0xfe, 0xaf, 0xd0, 0x30, 0xcf, 0x2b, 0xf2, 0x29, 0x80, 0xfa,
-	0xbe, 0x90, 0x29, 0xd2, 0x98, 0x4c, 0x55, 0xd1, 0xfa, 0x94, 0xc6, 0xa9, 0x6c, 0x5a, 0xdb, 0x16,
-	0xf9, 0x1c, 0x6a, 0x87, 0x21, 0x0b, 0xc8, 0x9c, 0xa7, 0xd4, 0xfa, 0x1c, 0xbd, 0x53, 0xd9, 0xf9,
-	0xf2, 0xf5, 0xdb, 0x6e, 0xe5, 0xcd, 0xdb, 0xae, 0xf5, 0xfa, 0xb2, 0x6b, 0xbd, 0xb9, 0xec, 0x5a,
-	0x7f, 0x5e, 0x76, 0xad, 0x3f, 0xfe, 0xea, 0x56, 0x7e, 0xb8, 0xfb, 0xbf, 0xde, 0xbf, 0xc7, 0xe6,
-	0xc9, 0xfb, 0xf0, 0x9f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x62, 0x0c, 0x68, 0x35, 0x2f, 0x0b, 0x00,
-	0x00,
-}
-
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// RKSyncClient is the client API for RKSync service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type RKSyncClient interface {
-	// SyncStream is the gRPC stream used for sending and receiving messages
-	SyncStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_SyncStreamClient, error)
-	// Ping is used to probe a remote peer's aliveness
-	Ping(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*types.Empty, error)
+func (x *MembershipResponse) GetDead() []*Envelope {
+	if x != nil {
+		return x.Dead
+	}
+	return nil
 }
 
-type rKSyncClient struct {
-	cc *grpc.ClientConn
+func (x *MembershipResponse) GetWanted() [][]byte {
+	if x != nil {
+		return x.Wanted
+	}
+	return nil
 }
 
-func NewRKSyncClient(cc *grpc.ClientConn) RKSyncClient {
-	return &rKSyncClient{cc}
+type MembershipDigest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PkiId     []byte    `protobuf:"bytes,1,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	Timestamp *PeerTime `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 }
 
-func (c *rKSyncClient) SyncStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_SyncStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_RKSync_serviceDesc.Streams[0], "/protos.RKSync/SyncStream", opts...)
-	if err != nil {
-		return nil, err
+func (x *MembershipDigest) Reset() {
+	*x = MembershipDigest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	x := &rKSyncSyncStreamClient{stream}
-	return x, nil
 }
 
-type RKSync_SyncStreamClient interface {
-	Send(*Envelope) error
-	Recv() (*Envelope, error)
-	grpc.ClientStream
+func (x *MembershipDigest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type rKSyncSyncStreamClient struct {
-	grpc.ClientStream
+func (*MembershipDigest) ProtoMessage() {}
+
+func (x *MembershipDigest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (x *rKSyncSyncStreamClient) Send(m *Envelope) error {
-	return x.ClientStream.SendMsg(m)
+// Deprecated: Use MembershipDigest.ProtoReflect.Descriptor instead.
+func (*MembershipDigest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *rKSyncSyncStreamClient) Recv() (*Envelope, error) {
-	m := new(Envelope)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (x *MembershipDigest) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
 	}
-	return m, nil
+	return nil
 }
 
-func (c *rKSyncClient) Ping(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*types.Empty, error) {
-	out := new(types.Empty)
-	err := c.cc.Invoke(ctx, "/protos.RKSync/Ping", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (x *MembershipDigest) GetTimestamp() *PeerTime {
+	if x != nil {
+		return x.Timestamp
 	}
-	return out, nil
+	return nil
 }
 
-// RKSyncServer is the server API for RKSync service.
-type RKSyncServer interface {
-	// SyncStream is the gRPC stream used for sending and receiving messages
-	SyncStream(RKSync_SyncStreamServer) error
-	// Ping is used to probe a remote peer's aliveness
-	Ping(context.Context, *types.Empty) (*types.Empty, error)
-}
+type ChainState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-func RegisterRKSyncServer(s *grpc.Server, srv RKSyncServer) {
-	s.RegisterService(&_RKSync_serviceDesc, srv)
+	SeqNum   uint64    `protobuf:"varint,1,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
+	ChainId  string    `protobuf:"bytes,2,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	Envelope *Envelope `protobuf:"bytes,3,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	// Latest record of the change log, whose payload is a ChangeRecord signed by the leader
+	Change *Envelope `protobuf:"bytes,4,opt,name=change,proto3" json:"change,omitempty"`
+	// Stamped by the leader every time it publishes the chain state, so that the members know it's alive
+	Heartbeat *PeerTime `protobuf:"bytes,5,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
 }
 
-func _RKSync_SyncStream_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(RKSyncServer).SyncStream(&rKSyncSyncStreamServer{stream})
+func (x *ChainState) Reset() {
+	*x = ChainState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
 }
 
-type RKSync_SyncStreamServer interface {
-	Send(*Envelope) error
-	Recv() (*Envelope, error)
-	grpc.ServerStream
+func (x *ChainState) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type rKSyncSyncStreamServer struct {
-	grpc.ServerStream
+func (*ChainState) ProtoMessage() {}
+
+func (x *ChainState) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (x *rKSyncSyncStreamServer) Send(m *Envelope) error {
-	return x.ServerStream.SendMsg(m)
+// Deprecated: Use ChainState.ProtoReflect.Descriptor instead.
+func (*ChainState) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *rKSyncSyncStreamServer) Recv() (*Envelope, error) {
-	m := new(Envelope)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
+func (x *ChainState) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
 	}
-	return m, nil
+	return 0
 }
 
-func _RKSync_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(types.Empty)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(RKSyncServer).Ping(ctx, in)
+func (x *ChainState) GetChainId() string {
+	if x != nil {
+		return x.ChainId
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/protos.RKSync/Ping",
+	return ""
+}
+
+func (x *ChainState) GetEnvelope() *Envelope {
+	if x != nil {
+		return x.Envelope
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RKSyncServer).Ping(ctx, req.(*types.Empty))
+	return nil
+}
+
+func (x *ChainState) GetChange() *Envelope {
+	if x != nil {
+		return x.Change
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var _RKSync_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "protos.RKSync",
-	HandlerType: (*RKSyncServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Ping",
-			Handler:    _RKSync_Ping_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "SyncStream",
-			Handler:       _RKSync_SyncStream_Handler,
-			ServerStreams: true,
-			ClientStreams: true,
-		},
-	},
-	Metadata: "github.com/rkcloudchain/rksync/protos/rksync.proto",
-}
-
-func (m *Envelope) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *Envelope) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Payload) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Payload)))
-		i += copy(dAtA[i:], m.Payload)
-	}
-	if len(m.Signature) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Signature)))
-		i += copy(dAtA[i:], m.Signature)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *RKSyncMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *RKSyncMessage) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Nonce != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Nonce))
-	}
-	if len(m.ChainMac) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.ChainMac)))
-		i += copy(dAtA[i:], m.ChainMac)
-	}
-	if m.Tag != 0 {
-		dAtA[i] = 0x18
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Tag))
-	}
-	if m.Content != nil {
-		nn1, err := m.Content.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += nn1
+func (x *ChainState) GetHeartbeat() *PeerTime {
+	if x != nil {
+		return x.Heartbeat
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+	return nil
+}
+
+// ChangeRecord is a membership change of a channel, chained to the previous record by its hash
+type ChangeRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index     uint64          `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	PrevHash  []byte          `protobuf:"bytes,2,opt,name=prev_hash,json=prevHash,proto3" json:"prev_hash,omitempty"`
+	Op        ChangeRecord_Op `protobuf:"varint,3,opt,name=op,proto3,enum=protos.ChangeRecord_Op" json:"op,omitempty"`
+	Members   [][]byte        `protobuf:"bytes,4,rep,name=members,proto3" json:"members,omitempty"`
+	Files     []string        `protobuf:"bytes,5,rep,name=files,proto3" json:"files,omitempty"`
+	SeqNum    uint64          `protobuf:"varint,6,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
+	Timestamp int64           `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *ChangeRecord) Reset() {
+	*x = ChangeRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return i, nil
 }
 
-func (m *RKSyncMessage_AliveMsg) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.AliveMsg != nil {
-		dAtA[i] = 0x2a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.AliveMsg.Size()))
-		n2, err := m.AliveMsg.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n2
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_Empty) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.Empty != nil {
-		dAtA[i] = 0x32
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Empty.Size()))
-		n3, err := m.Empty.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n3
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_Conn) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.Conn != nil {
-		dAtA[i] = 0x3a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Conn.Size()))
-		n4, err := m.Conn.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n4
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_Ack) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.Ack != nil {
-		dAtA[i] = 0x42
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Ack.Size()))
-		n5, err := m.Ack.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n5
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_MemReq) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.MemReq != nil {
-		dAtA[i] = 0x4a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.MemReq.Size()))
-		n6, err := m.MemReq.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n6
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_MemRes) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.MemRes != nil {
-		dAtA[i] = 0x52
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.MemRes.Size()))
-		n7, err := m.MemRes.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n7
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_State) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.State != nil {
-		dAtA[i] = 0x5a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.State.Size()))
-		n8, err := m.State.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n8
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_StatePullRequest) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.StatePullRequest != nil {
-		dAtA[i] = 0x62
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.StatePullRequest.Size()))
-		n9, err := m.StatePullRequest.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n9
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_StatePullResponse) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.StatePullResponse != nil {
-		dAtA[i] = 0x6a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.StatePullResponse.Size()))
-		n10, err := m.StatePullResponse.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n10
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_StateInfo) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.StateInfo != nil {
-		dAtA[i] = 0x72
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.StateInfo.Size()))
-		n11, err := m.StateInfo.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n11
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_DataMsg) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.DataMsg != nil {
-		dAtA[i] = 0x7a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.DataMsg.Size()))
-		n12, err := m.DataMsg.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n12
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_DataReq) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.DataReq != nil {
-		dAtA[i] = 0x82
-		i++
-		dAtA[i] = 0x1
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.DataReq.Size()))
-		n13, err := m.DataReq.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n13
-	}
-	return i, nil
-}
-func (m *RKSyncMessage_LeaveChain) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.LeaveChain != nil {
-		dAtA[i] = 0x8a
-		i++
-		dAtA[i] = 0x1
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.LeaveChain.Size()))
-		n14, err := m.LeaveChain.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n14
-	}
-	return i, nil
-}
-func (m *ConnEstablish) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *ConnEstablish) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.PkiId) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.PkiId)))
-		i += copy(dAtA[i:], m.PkiId)
-	}
-	if len(m.Identity) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Identity)))
-		i += copy(dAtA[i:], m.Identity)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *AliveMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *AliveMessage) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Membership != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Membership.Size()))
-		n15, err := m.Membership.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n15
-	}
-	if m.Timestamp != nil {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Timestamp.Size()))
-		n16, err := m.Timestamp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+func (x *ChangeRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeRecord) ProtoMessage() {}
+
+func (x *ChangeRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		i += n16
-	}
-	if len(m.Identity) > 0 {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Identity)))
-		i += copy(dAtA[i:], m.Identity)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+		return ms
 	}
-	return i, nil
+	return mi.MessageOf(x)
 }
 
-func (m *PeerTime) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+// Deprecated: Use ChangeRecord.ProtoReflect.Descriptor instead.
+func (*ChangeRecord) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{13}
 }
 
-func (m *PeerTime) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.IncNum != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.IncNum))
-	}
-	if m.SeqNum != 0 {
-		dAtA[i] = 0x10
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.SeqNum))
+func (x *ChangeRecord) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+	return 0
 }
 
-func (m *Member) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *ChangeRecord) GetPrevHash() []byte {
+	if x != nil {
+		return x.PrevHash
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *Member) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Endpoint) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Endpoint)))
-		i += copy(dAtA[i:], m.Endpoint)
+func (x *ChangeRecord) GetOp() ChangeRecord_Op {
+	if x != nil {
+		return x.Op
 	}
-	if len(m.PkiId) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.PkiId)))
-		i += copy(dAtA[i:], m.PkiId)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+	return ChangeRecord_INITIALIZE
 }
 
-func (m *Acknowledgement) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *ChangeRecord) GetMembers() [][]byte {
+	if x != nil {
+		return x.Members
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *Acknowledgement) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Error) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Error)))
-		i += copy(dAtA[i:], m.Error)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+func (x *ChangeRecord) GetFiles() []string {
+	if x != nil {
+		return x.Files
 	}
-	return i, nil
-}
+	return nil
+}
 
-func (m *MembershipRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *MembershipRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.SelfInformation != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.SelfInformation.Size()))
-		n17, err := m.SelfInformation.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n17
-	}
-	if len(m.Known) > 0 {
-		for _, msg := range m.Known {
-			dAtA[i] = 0x12
-			i++
-			i = encodeVarintRksync(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+func (x *ChangeRecord) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
 	}
-	return i, nil
+	return 0
 }
 
-func (m *MembershipResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *ChangeRecord) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *MembershipResponse) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Alive) > 0 {
-		for _, msg := range m.Alive {
-			dAtA[i] = 0xa
-			i++
-			i = encodeVarintRksync(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
-	}
-	if len(m.Dead) > 0 {
-		for _, msg := range m.Dead {
-			dAtA[i] = 0x12
-			i++
-			i = encodeVarintRksync(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *ChainState) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *ChainState) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.SeqNum != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.SeqNum))
-	}
-	if len(m.ChainId) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.ChainId)))
-		i += copy(dAtA[i:], m.ChainId)
-	}
-	if m.Envelope != nil {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Envelope.Size()))
-		n18, err := m.Envelope.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n18
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *ChainStateInfo) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *ChainStateInfo) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Leader) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Leader)))
-		i += copy(dAtA[i:], m.Leader)
-	}
-	if m.Properties != nil {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Properties.Size()))
-		n19, err := m.Properties.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n19
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+// JournalRecord is an entry of the write-ahead journal of the chain states accepted by a member,
+// written once before the chain state is applied, and again once it was applied
+type JournalRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeqNum uint64 `protobuf:"varint,1,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
+	Epoch  uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	// SHA-256 digest of the payload of the chain state's envelope
+	Digest []byte `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	// PKI-ID of the leader which signed the chain state
+	Signer    []byte `protobuf:"bytes,4,opt,name=signer,proto3" json:"signer,omitempty"`
+	Applied   bool   `protobuf:"varint,5,opt,name=applied,proto3" json:"applied,omitempty"`
+	Timestamp int64  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 }
 
-func (m *Properties) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *JournalRecord) Reset() {
+	*x = JournalRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return dAtA[:n], nil
 }
 
-func (m *Properties) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Members) > 0 {
-		for _, b := range m.Members {
-			dAtA[i] = 0xa
-			i++
-			i = encodeVarintRksync(dAtA, i, uint64(len(b)))
-			i += copy(dAtA[i:], b)
-		}
-	}
-	if len(m.Files) > 0 {
-		for _, msg := range m.Files {
-			dAtA[i] = 0x12
-			i++
-			i = encodeVarintRksync(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
+func (x *JournalRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JournalRecord) ProtoMessage() {}
+
+func (x *JournalRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+	return mi.MessageOf(x)
 }
 
-func (m *File) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+// Deprecated: Use JournalRecord.ProtoReflect.Descriptor instead.
+func (*JournalRecord) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{14}
 }
 
-func (m *File) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Path) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Path)))
-		i += copy(dAtA[i:], m.Path)
-	}
-	if m.Mode != 0 {
-		dAtA[i] = 0x10
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Mode))
-	}
-	if len(m.Metadata) > 0 {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Metadata)))
-		i += copy(dAtA[i:], m.Metadata)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+func (x *JournalRecord) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
 	}
-	return i, nil
+	return 0
 }
 
-func (m *ChainStatePullResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *JournalRecord) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *ChainStatePullResponse) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Element != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Element.Size()))
-		n20, err := m.Element.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n20
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+func (x *JournalRecord) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
 	}
-	return i, nil
+	return nil
 }
 
-func (m *ChainStatePullRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *JournalRecord) GetSigner() []byte {
+	if x != nil {
+		return x.Signer
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *ChainStatePullRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Timestamp != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Timestamp.Size()))
-		n21, err := m.Timestamp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n21
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *DataMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *DataMessage) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.FileName) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.FileName)))
-		i += copy(dAtA[i:], m.FileName)
-	}
-	if m.Payload != nil {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Payload.Size()))
-		n22, err := m.Payload.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n22
+func (x *JournalRecord) GetApplied() bool {
+	if x != nil {
+		return x.Applied
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+	return false
 }
 
-func (m *Payload) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (x *JournalRecord) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Payload) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Data) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.Data)))
-		i += copy(dAtA[i:], m.Data)
-	}
-	if m.Metadata != nil {
-		nn23, err := m.Metadata.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += nn23
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+type ChangeLogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FromIndex uint64 `protobuf:"varint,1,opt,name=from_index,json=fromIndex,proto3" json:"from_index,omitempty"`
 }
 
-func (m *Payload_Append) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.Append != nil {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Append.Size()))
-		n24, err := m.Append.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n24
-	}
-	return i, nil
-}
-func (m *AppendMetadata) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *AppendMetadata) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Start != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Start))
-	}
-	if m.Length != 0 {
-		dAtA[i] = 0x10
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Length))
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
-}
-
-func (m *DataRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *DataRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.FileName) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.FileName)))
-		i += copy(dAtA[i:], m.FileName)
-	}
-	if len(m.PkiId) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.PkiId)))
-		i += copy(dAtA[i:], m.PkiId)
-	}
-	if m.Req != nil {
-		nn25, err := m.Req.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += nn25
+func (x *ChangeLogRequest) Reset() {
+	*x = ChangeLogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
 }
 
-func (m *DataRequest_Append) MarshalTo(dAtA []byte) (int, error) {
-	i := 0
-	if m.Append != nil {
-		dAtA[i] = 0x22
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Append.Size()))
-		n26, err := m.Append.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+func (x *ChangeLogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeLogRequest) ProtoMessage() {}
+
+func (x *ChangeLogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		i += n26
+		return ms
 	}
-	return i, nil
+	return mi.MessageOf(x)
 }
-func (m *AppendRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+
+// Deprecated: Use ChangeLogRequest.ProtoReflect.Descriptor instead.
+func (*ChangeLogRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{15}
 }
 
-func (m *AppendRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Length != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(m.Length))
+func (x *ChangeLogRequest) GetFromIndex() uint64 {
+	if x != nil {
+		return x.FromIndex
 	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	return i, nil
+	return 0
 }
 
-func (m *LeaveChainMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+type ChangeLogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Records []*Envelope `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
 }
 
-func (m *LeaveChainMessage) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.ChainMac) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintRksync(dAtA, i, uint64(len(m.ChainMac)))
-		i += copy(dAtA[i:], m.ChainMac)
-	}
-	if m.XXX_unrecognized != nil {
-		i += copy(dAtA[i:], m.XXX_unrecognized)
+func (x *ChangeLogResponse) Reset() {
+	*x = ChangeLogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return i, nil
 }
 
-func encodeVarintRksync(dAtA []byte, offset int, v uint64) int {
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
-	}
-	dAtA[offset] = uint8(v)
-	return offset + 1
+func (x *ChangeLogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
-func (m *Envelope) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.Payload)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	l = len(m.Signature)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+
+func (*ChangeLogResponse) ProtoMessage() {}
+
+func (x *ChangeLogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return n
+	return mi.MessageOf(x)
 }
 
-func (m *RKSyncMessage) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Nonce != 0 {
-		n += 1 + sovRksync(uint64(m.Nonce))
-	}
-	l = len(m.ChainMac)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Tag != 0 {
-		n += 1 + sovRksync(uint64(m.Tag))
-	}
-	if m.Content != nil {
-		n += m.Content.Size()
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+// Deprecated: Use ChangeLogResponse.ProtoReflect.Descriptor instead.
+func (*ChangeLogResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{16}
 }
 
-func (m *RKSyncMessage_AliveMsg) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.AliveMsg != nil {
-		l = m.AliveMsg.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *ChangeLogResponse) GetRecords() []*Envelope {
+	if x != nil {
+		return x.Records
 	}
-	return n
+	return nil
 }
-func (m *RKSyncMessage_Empty) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Empty != nil {
-		l = m.Empty.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	return n
+
+type ChainStateInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Leader      []byte      `protobuf:"bytes,1,opt,name=leader,proto3" json:"leader,omitempty"`
+	Properties  *Properties `protobuf:"bytes,2,opt,name=properties,proto3" json:"properties,omitempty"`
+	AnchorPeers []string    `protobuf:"bytes,3,rep,name=anchor_peers,json=anchorPeers,proto3" json:"anchor_peers,omitempty"`
+	// Incremented by the leader at every change of the channel, a state of a lower epoch is stale
+	Epoch uint64 `protobuf:"varint,4,opt,name=epoch,proto3" json:"epoch,omitempty"`
 }
-func (m *RKSyncMessage_Conn) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Conn != nil {
-		l = m.Conn.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (x *ChainStateInfo) Reset() {
+	*x = ChainStateInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return n
 }
-func (m *RKSyncMessage_Ack) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Ack != nil {
-		l = m.Ack.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	return n
+
+func (x *ChainStateInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
-func (m *RKSyncMessage_MemReq) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.MemReq != nil {
-		l = m.MemReq.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (*ChainStateInfo) ProtoMessage() {}
+
+func (x *ChainStateInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return n
+	return mi.MessageOf(x)
 }
-func (m *RKSyncMessage_MemRes) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.MemRes != nil {
-		l = m.MemRes.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	return n
+
+// Deprecated: Use ChainStateInfo.ProtoReflect.Descriptor instead.
+func (*ChainStateInfo) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{17}
 }
-func (m *RKSyncMessage_State) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.State != nil {
-		l = m.State.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (x *ChainStateInfo) GetLeader() []byte {
+	if x != nil {
+		return x.Leader
 	}
-	return n
+	return nil
 }
-func (m *RKSyncMessage_StatePullRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.StatePullRequest != nil {
-		l = m.StatePullRequest.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (x *ChainStateInfo) GetProperties() *Properties {
+	if x != nil {
+		return x.Properties
 	}
-	return n
+	return nil
 }
-func (m *RKSyncMessage_StatePullResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.StatePullResponse != nil {
-		l = m.StatePullResponse.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (x *ChainStateInfo) GetAnchorPeers() []string {
+	if x != nil {
+		return x.AnchorPeers
 	}
-	return n
+	return nil
 }
-func (m *RKSyncMessage_StateInfo) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.StateInfo != nil {
-		l = m.StateInfo.Size()
-		n += 1 + l + sovRksync(uint64(l))
+
+func (x *ChainStateInfo) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
 	}
-	return n
+	return 0
 }
-func (m *RKSyncMessage_DataMsg) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.DataMsg != nil {
-		l = m.DataMsg.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	return n
+
+type Properties struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Members [][]byte `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	Files   []*File  `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"`
 }
-func (m *RKSyncMessage_DataReq) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.DataReq != nil {
-		l = m.DataReq.Size()
-		n += 2 + l + sovRksync(uint64(l))
+
+func (x *Properties) Reset() {
+	*x = Properties{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return n
 }
-func (m *RKSyncMessage_LeaveChain) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.LeaveChain != nil {
-		l = m.LeaveChain.Size()
-		n += 2 + l + sovRksync(uint64(l))
-	}
-	return n
+
+func (x *Properties) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
-func (m *ConnEstablish) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.PkiId)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	l = len(m.Identity)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+
+func (*Properties) ProtoMessage() {}
+
+func (x *Properties) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return n
+	return mi.MessageOf(x)
 }
 
-func (m *AliveMessage) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Membership != nil {
-		l = m.Membership.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Timestamp != nil {
-		l = m.Timestamp.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	l = len(m.Identity)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+// Deprecated: Use Properties.ProtoReflect.Descriptor instead.
+func (*Properties) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{18}
 }
 
-func (m *PeerTime) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.IncNum != 0 {
-		n += 1 + sovRksync(uint64(m.IncNum))
-	}
-	if m.SeqNum != 0 {
-		n += 1 + sovRksync(uint64(m.SeqNum))
+func (x *Properties) GetMembers() [][]byte {
+	if x != nil {
+		return x.Members
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return nil
 }
 
-func (m *Member) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.Endpoint)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	l = len(m.PkiId)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
+func (x *Properties) GetFiles() []*File {
+	if x != nil {
+		return x.Files
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return nil
 }
 
-func (m *Acknowledgement) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.Error)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+type File struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path        string           `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Mode        File_Mode        `protobuf:"varint,2,opt,name=mode,proto3,enum=protos.File_Mode" json:"mode,omitempty"`
+	Metadata    []byte           `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Version     uint64           `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	ContentHash []byte           `protobuf:"bytes,5,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	Perm        uint32           `protobuf:"varint,6,opt,name=perm,proto3" json:"perm,omitempty"`
+	Attributes  []*FileAttribute `protobuf:"bytes,7,rep,name=attributes,proto3" json:"attributes,omitempty"`
+	// Hint of the members transferring the files of the channel with the higher priorities first
+	Priority int32 `protobuf:"varint,8,opt,name=priority,proto3" json:"priority,omitempty"`
 }
 
-func (m *MembershipRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.SelfInformation != nil {
-		l = m.SelfInformation.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *File) Reset() {
+	*x = File{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	if len(m.Known) > 0 {
-		for _, e := range m.Known {
-			l = e.Size()
-			n += 1 + l + sovRksync(uint64(l))
-		}
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
 }
 
-func (m *MembershipResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.Alive) > 0 {
-		for _, e := range m.Alive {
-			l = e.Size()
-			n += 1 + l + sovRksync(uint64(l))
-		}
-	}
-	if len(m.Dead) > 0 {
-		for _, e := range m.Dead {
-			l = e.Size()
-			n += 1 + l + sovRksync(uint64(l))
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return mi.MessageOf(x)
 }
 
-func (m *ChainState) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.SeqNum != 0 {
-		n += 1 + sovRksync(uint64(m.SeqNum))
-	}
-	l = len(m.ChainId)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Envelope != nil {
-		l = m.Envelope.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{19}
 }
 
-func (m *ChainStateInfo) Size() (n int) {
-	if m == nil {
-		return 0
+func (x *File) GetPath() string {
+	if x != nil {
+		return x.Path
 	}
-	var l int
-	_ = l
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Properties != nil {
-		l = m.Properties.Size()
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return ""
 }
 
-func (m *Properties) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.Members) > 0 {
-		for _, b := range m.Members {
-			l = len(b)
-			n += 1 + l + sovRksync(uint64(l))
-		}
-	}
-	if len(m.Files) > 0 {
-		for _, e := range m.Files {
-			l = e.Size()
-			n += 1 + l + sovRksync(uint64(l))
-		}
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+func (x *File) GetMode() File_Mode {
+	if x != nil {
+		return x.Mode
 	}
-	return n
+	return File_Append
 }
 
-func (m *File) Size() (n int) {
-	if m == nil {
-		return 0
+func (x *File) GetMetadata() []byte {
+	if x != nil {
+		return x.Metadata
 	}
-	var l int
-	_ = l
-	l = len(m.Path)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Mode != 0 {
-		n += 1 + sovRksync(uint64(m.Mode))
-	}
-	l = len(m.Metadata)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return nil
 }
 
-func (m *ChainStatePullResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Element != nil {
-		l = m.Element.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *File) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return 0
 }
 
-func (m *ChainStatePullRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Timestamp != nil {
-		l = m.Timestamp.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *File) GetContentHash() []byte {
+	if x != nil {
+		return x.ContentHash
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return nil
 }
 
-func (m *DataMessage) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.FileName)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Payload != nil {
-		l = m.Payload.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *File) GetPerm() uint32 {
+	if x != nil {
+		return x.Perm
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+	return 0
 }
 
-func (m *Payload) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.Data)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
-	}
-	if m.Metadata != nil {
-		n += m.Metadata.Size()
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+func (x *File) GetAttributes() []*FileAttribute {
+	if x != nil {
+		return x.Attributes
 	}
-	return n
+	return nil
 }
 
-func (m *Payload_Append) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Append != nil {
-		l = m.Append.Size()
-		n += 1 + l + sovRksync(uint64(l))
+func (x *File) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
 	}
-	return n
+	return 0
 }
-func (m *AppendMetadata) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Start != 0 {
-		n += 1 + sovRksync(uint64(m.Start))
-	}
-	if m.Length != 0 {
-		n += 1 + sovRksync(uint64(m.Length))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
+
+type FileAttribute struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
 }
 
-func (m *DataRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (x *FileAttribute) Reset() {
+	*x = FileAttribute{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	var l int
-	_ = l
-	l = len(m.FileName)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
+}
+
+func (x *FileAttribute) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileAttribute) ProtoMessage() {}
+
+func (x *FileAttribute) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	l = len(m.PkiId)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileAttribute.ProtoReflect.Descriptor instead.
+func (*FileAttribute) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *FileAttribute) GetKey() string {
+	if x != nil {
+		return x.Key
 	}
-	if m.Req != nil {
-		n += m.Req.Size()
+	return ""
+}
+
+func (x *FileAttribute) GetValue() string {
+	if x != nil {
+		return x.Value
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return ""
+}
+
+type ChainStatePullResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Element *Envelope `protobuf:"bytes,1,opt,name=element,proto3" json:"element,omitempty"`
+}
+
+func (x *ChainStatePullResponse) Reset() {
+	*x = ChainStatePullResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return n
 }
 
-func (m *DataRequest_Append) Size() (n int) {
-	if m == nil {
-		return 0
+func (x *ChainStatePullResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainStatePullResponse) ProtoMessage() {}
+
+func (x *ChainStatePullResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	var l int
-	_ = l
-	if m.Append != nil {
-		l = m.Append.Size()
-		n += 1 + l + sovRksync(uint64(l))
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainStatePullResponse.ProtoReflect.Descriptor instead.
+func (*ChainStatePullResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ChainStatePullResponse) GetElement() *Envelope {
+	if x != nil {
+		return x.Element
 	}
-	return n
+	return nil
 }
-func (m *AppendRequest) Size() (n int) {
-	if m == nil {
-		return 0
+
+type ChainStatePullRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timestamp *PeerTime `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Sequence number of the chain state known by the requester,
+	// peers respond only if they know a newer one
+	SeqNum uint64 `protobuf:"varint,2,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
+	// Epoch of the chain state known by the requester, signed by the leader
+	// unlike the sequence number. Zero if the requester predates the epochs.
+	Epoch uint64 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+}
+
+func (x *ChainStatePullRequest) Reset() {
+	*x = ChainStatePullRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	var l int
-	_ = l
-	if m.Length != 0 {
-		n += 1 + sovRksync(uint64(m.Length))
+}
+
+func (x *ChainStatePullRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainStatePullRequest) ProtoMessage() {}
+
+func (x *ChainStatePullRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainStatePullRequest.ProtoReflect.Descriptor instead.
+func (*ChainStatePullRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ChainStatePullRequest) GetTimestamp() *PeerTime {
+	if x != nil {
+		return x.Timestamp
 	}
-	return n
+	return nil
 }
 
-func (m *LeaveChainMessage) Size() (n int) {
-	if m == nil {
-		return 0
+func (x *ChainStatePullRequest) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
 	}
-	var l int
-	_ = l
-	l = len(m.ChainMac)
-	if l > 0 {
-		n += 1 + l + sovRksync(uint64(l))
+	return 0
+}
+
+func (x *ChainStatePullRequest) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return 0
+}
+
+// StateRequest is sent by a peer which just joined a channel to its leader and members,
+// so that it learns the current chain state and files at once
+type StateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Sequence number of the chain state known by the requester
+	SeqNum uint64 `protobuf:"varint,1,opt,name=seq_num,json=seqNum,proto3" json:"seq_num,omitempty"`
+	// Path the page of the manifest starts at, the chain state is only sent along with the first page
+	ManifestFrom string `protobuf:"bytes,2,opt,name=manifest_from,json=manifestFrom,proto3" json:"manifest_from,omitempty"`
+}
+
+func (x *StateRequest) Reset() {
+	*x = StateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return n
 }
 
-func sovRksync(x uint64) (n int) {
-	for {
-		n++
-		x >>= 7
-		if x == 0 {
-			break
+func (x *StateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateRequest) ProtoMessage() {}
+
+func (x *StateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	return n
+	return mi.MessageOf(x)
 }
-func sozRksync(x uint64) (n int) {
-	return sovRksync(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+
+// Deprecated: Use StateRequest.ProtoReflect.Descriptor instead.
+func (*StateRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{23}
 }
-func (m *Envelope) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Envelope: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Envelope: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
-			if m.Payload == nil {
-				m.Payload = []byte{}
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
-			if m.Signature == nil {
-				m.Signature = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *StateRequest) GetSeqNum() uint64 {
+	if x != nil {
+		return x.SeqNum
+	}
+	return 0
+}
+
+func (x *StateRequest) GetManifestFrom() string {
+	if x != nil {
+		return x.ManifestFrom
+	}
+	return ""
+}
+
+type StateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ChainState message signed by the responder
+	Element *Envelope `protobuf:"bytes,1,opt,name=element,proto3" json:"element,omitempty"`
+	// Sizes of the responder's copies of the files, sorted by path
+	Manifest []*FileManifestEntry `protobuf:"bytes,2,rep,name=manifest,proto3" json:"manifest,omitempty"`
+	// Path the next page of the manifest starts at, empty on the last page
+	Next string `protobuf:"bytes,3,opt,name=next,proto3" json:"next,omitempty"`
+}
+
+func (x *StateResponse) Reset() {
+	*x = StateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateResponse) ProtoMessage() {}
+
+func (x *StateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateResponse.ProtoReflect.Descriptor instead.
+func (*StateResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{24}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *StateResponse) GetElement() *Envelope {
+	if x != nil {
+		return x.Element
 	}
 	return nil
 }
-func (m *RKSyncMessage) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: RKSyncMessage: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RKSyncMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+
+func (x *StateResponse) GetManifest() []*FileManifestEntry {
+	if x != nil {
+		return x.Manifest
+	}
+	return nil
+}
+
+func (x *StateResponse) GetNext() string {
+	if x != nil {
+		return x.Next
+	}
+	return ""
+}
+
+type FileManifestEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path   string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Length int64  `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+}
+
+func (x *FileManifestEntry) Reset() {
+	*x = FileManifestEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FileManifestEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileManifestEntry) ProtoMessage() {}
+
+func (x *FileManifestEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Nonce", wireType)
-			}
-			m.Nonce = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Nonce |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ChainMac", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ChainMac = append(m.ChainMac[:0], dAtA[iNdEx:postIndex]...)
-			if m.ChainMac == nil {
-				m.ChainMac = []byte{}
-			}
-			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Tag", wireType)
-			}
-			m.Tag = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Tag |= RKSyncMessage_Tag(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 5:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field AliveMsg", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &AliveMessage{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_AliveMsg{v}
-			iNdEx = postIndex
-		case 6:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Empty", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &types.Empty{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_Empty{v}
-			iNdEx = postIndex
-		case 7:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Conn", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &ConnEstablish{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_Conn{v}
-			iNdEx = postIndex
-		case 8:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Ack", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &Acknowledgement{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_Ack{v}
-			iNdEx = postIndex
-		case 9:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemReq", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &MembershipRequest{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_MemReq{v}
-			iNdEx = postIndex
-		case 10:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemRes", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &MembershipResponse{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_MemRes{v}
-			iNdEx = postIndex
-		case 11:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &ChainState{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_State{v}
-			iNdEx = postIndex
-		case 12:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatePullRequest", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &ChainStatePullRequest{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_StatePullRequest{v}
-			iNdEx = postIndex
-		case 13:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatePullResponse", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &ChainStatePullResponse{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_StatePullResponse{v}
-			iNdEx = postIndex
-		case 14:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StateInfo", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &ChainStateInfo{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_StateInfo{v}
-			iNdEx = postIndex
-		case 15:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DataMsg", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &DataMessage{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_DataMsg{v}
-			iNdEx = postIndex
-		case 16:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DataReq", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &DataRequest{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_DataReq{v}
-			iNdEx = postIndex
-		case 17:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaveChain", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &LeaveChainMessage{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Content = &RKSyncMessage_LeaveChain{v}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileManifestEntry.ProtoReflect.Descriptor instead.
+func (*FileManifestEntry) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *FileManifestEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileManifestEntry) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+type DataMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FileName string   `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Payload  *Payload `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (x *DataMessage) Reset() {
+	*x = DataMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DataMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataMessage) ProtoMessage() {}
+
+func (x *DataMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataMessage.ProtoReflect.Descriptor instead.
+func (*DataMessage) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{26}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *DataMessage) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *DataMessage) GetPayload() *Payload {
+	if x != nil {
+		return x.Payload
 	}
 	return nil
 }
-func (m *ConnEstablish) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ConnEstablish: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ConnEstablish: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PkiId", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.PkiId = append(m.PkiId[:0], dAtA[iNdEx:postIndex]...)
-			if m.PkiId == nil {
-				m.PkiId = []byte{}
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Identity", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Identity = append(m.Identity[:0], dAtA[iNdEx:postIndex]...)
-			if m.Identity == nil {
-				m.Identity = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+type Payload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// Types that are assignable to Metadata:
+	//	*Payload_Append
+	Metadata isPayload_Metadata `protobuf_oneof:"metadata"`
+}
+
+func (x *Payload) Reset() {
+	*x = Payload{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Payload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payload) ProtoMessage() {}
+
+func (x *Payload) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
+func (*Payload) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{27}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *Payload) GetData() []byte {
+	if x != nil {
+		return x.Data
 	}
 	return nil
 }
-func (m *AliveMessage) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
+
+func (m *Payload) GetMetadata() isPayload_Metadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (x *Payload) GetAppend() *AppendMetadata {
+	if x, ok := x.GetMetadata().(*Payload_Append); ok {
+		return x.Append
+	}
+	return nil
+}
+
+type isPayload_Metadata interface {
+	isPayload_Metadata()
+}
+
+type Payload_Append struct {
+	Append *AppendMetadata `protobuf:"bytes,3,opt,name=append,proto3,oneof"`
+}
+
+func (*Payload_Append) isPayload_Metadata() {}
+
+type AppendMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Start     int64  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	Length    int64  `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Truncated bool   `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	Digest    []byte `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
+	ModTime   int64  `protobuf:"varint,5,opt,name=mod_time,json=modTime,proto3" json:"mod_time,omitempty"`
+	FileSize  int64  `protobuf:"varint,6,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+}
+
+func (x *AppendMetadata) Reset() {
+	*x = AppendMetadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AppendMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendMetadata) ProtoMessage() {}
+
+func (x *AppendMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: AliveMessage: wiretype end group for non-group")
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendMetadata.ProtoReflect.Descriptor instead.
+func (*AppendMetadata) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AppendMetadata) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *AppendMetadata) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *AppendMetadata) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *AppendMetadata) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *AppendMetadata) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *AppendMetadata) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+type DataRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FileName string `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	PkiId    []byte `protobuf:"bytes,2,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	// Types that are assignable to Req:
+	//	*DataRequest_Append
+	//	*DataRequest_Rejection
+	Req isDataRequest_Req `protobuf_oneof:"req"`
+}
+
+func (x *DataRequest) Reset() {
+	*x = DataRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataRequest) ProtoMessage() {}
+
+func (x *DataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: AliveMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataRequest.ProtoReflect.Descriptor instead.
+func (*DataRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *DataRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *DataRequest) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
+	}
+	return nil
+}
+
+func (m *DataRequest) GetReq() isDataRequest_Req {
+	if m != nil {
+		return m.Req
+	}
+	return nil
+}
+
+func (x *DataRequest) GetAppend() *AppendRequest {
+	if x, ok := x.GetReq().(*DataRequest_Append); ok {
+		return x.Append
+	}
+	return nil
+}
+
+func (x *DataRequest) GetRejection() *TransferRejection {
+	if x, ok := x.GetReq().(*DataRequest_Rejection); ok {
+		return x.Rejection
+	}
+	return nil
+}
+
+type isDataRequest_Req interface {
+	isDataRequest_Req()
+}
+
+type DataRequest_Append struct {
+	Append *AppendRequest `protobuf:"bytes,4,opt,name=append,proto3,oneof"`
+}
+
+type DataRequest_Rejection struct {
+	Rejection *TransferRejection `protobuf:"bytes,5,opt,name=rejection,proto3,oneof"`
+}
+
+func (*DataRequest_Append) isDataRequest_Req() {}
+
+func (*DataRequest_Rejection) isDataRequest_Req() {}
+
+type AppendRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Length   int64  `protobuf:"varint,1,opt,name=length,proto3" json:"length,omitempty"`
+	TailHash []byte `protobuf:"bytes,2,opt,name=tail_hash,json=tailHash,proto3" json:"tail_hash,omitempty"`
+	Pending  bool   `protobuf:"varint,3,opt,name=pending,proto3" json:"pending,omitempty"`
+	// Max number of bytes of the file sent in response, the rest of the file if 0
+	Limit int64 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *AppendRequest) Reset() {
+	*x = AppendRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AppendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendRequest) ProtoMessage() {}
+
+func (x *AppendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Membership", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Membership == nil {
-				m.Membership = &Member{}
-			}
-			if err := m.Membership.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Timestamp == nil {
-				m.Timestamp = &PeerTime{}
-			}
-			if err := m.Timestamp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Identity", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Identity = append(m.Identity[:0], dAtA[iNdEx:postIndex]...)
-			if m.Identity == nil {
-				m.Identity = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendRequest.ProtoReflect.Descriptor instead.
+func (*AppendRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *AppendRequest) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *AppendRequest) GetTailHash() []byte {
+	if x != nil {
+		return x.TailHash
+	}
+	return nil
+}
+
+func (x *AppendRequest) GetPending() bool {
+	if x != nil {
+		return x.Pending
+	}
+	return false
+}
+
+func (x *AppendRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+// TransferRejection is sent to the leader by a member which
+// doesn't have the room to store the file
+type TransferRejection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Limit     TransferRejection_Limit `protobuf:"varint,1,opt,name=limit,proto3,enum=protos.TransferRejection_Limit" json:"limit,omitempty"`
+	Required  int64                   `protobuf:"varint,2,opt,name=required,proto3" json:"required,omitempty"`
+	Available int64                   `protobuf:"varint,3,opt,name=available,proto3" json:"available,omitempty"`
+}
+
+func (x *TransferRejection) Reset() {
+	*x = TransferRejection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TransferRejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferRejection) ProtoMessage() {}
+
+func (x *TransferRejection) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
+// Deprecated: Use TransferRejection.ProtoReflect.Descriptor instead.
+func (*TransferRejection) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{31}
 }
-func (m *PeerTime) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: PeerTime: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PeerTime: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field IncNum", wireType)
-			}
-			m.IncNum = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.IncNum |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SeqNum", wireType)
-			}
-			m.SeqNum = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.SeqNum |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *TransferRejection) GetLimit() TransferRejection_Limit {
+	if x != nil {
+		return x.Limit
 	}
+	return TransferRejection_Quota
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *TransferRejection) GetRequired() int64 {
+	if x != nil {
+		return x.Required
 	}
-	return nil
+	return 0
 }
-func (m *Member) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Member: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Member: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Endpoint", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Endpoint = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PkiId", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.PkiId = append(m.PkiId[:0], dAtA[iNdEx:postIndex]...)
-			if m.PkiId == nil {
-				m.PkiId = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *TransferRejection) GetAvailable() int64 {
+	if x != nil {
+		return x.Available
 	}
+	return 0
+}
+
+// FileAck is sent by a member of a channel to the leader once its copy of a file is synchronized
+type FileAck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FileName string `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	// Size of the member's copy
+	Size int64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	// SHA-256 digest of the member's copy
+	Digest []byte `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	// Latest version of the file published by the leader the member knows of
+	Version uint64 `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *FileAck) Reset() {
+	*x = FileAck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
-func (m *Acknowledgement) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Acknowledgement: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Acknowledgement: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Error = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *FileAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileAck) ProtoMessage() {}
+
+func (x *FileAck) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileAck.ProtoReflect.Descriptor instead.
+func (*FileAck) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{32}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *FileAck) GetFileName() string {
+	if x != nil {
+		return x.FileName
 	}
-	return nil
+	return ""
 }
-func (m *MembershipRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: MembershipRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MembershipRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SelfInformation", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.SelfInformation == nil {
-				m.SelfInformation = &Envelope{}
-			}
-			if err := m.SelfInformation.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Known", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Known = append(m.Known, &Envelope{})
-			if err := m.Known[len(m.Known)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *FileAck) GetSize() int64 {
+	if x != nil {
+		return x.Size
 	}
+	return 0
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *FileAck) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
 	}
 	return nil
 }
-func (m *MembershipResponse) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: MembershipResponse: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MembershipResponse: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Alive", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Alive = append(m.Alive, &Envelope{})
-			if err := m.Alive[len(m.Alive)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Dead", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Dead = append(m.Dead, &Envelope{})
-			if err := m.Dead[len(m.Dead)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *FileAck) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
 	}
+	return 0
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+// PeerIdentityRequest asks a peer for the identities of the peers whose alive messages can't be verified
+type PeerIdentityRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PkiIds [][]byte `protobuf:"bytes,1,rep,name=pki_ids,json=pkiIds,proto3" json:"pki_ids,omitempty"`
+}
+
+func (x *PeerIdentityRequest) Reset() {
+	*x = PeerIdentityRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
-func (m *ChainState) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ChainState: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ChainState: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SeqNum", wireType)
-			}
-			m.SeqNum = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.SeqNum |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ChainId", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ChainId = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Envelope", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Envelope == nil {
-				m.Envelope = &Envelope{}
-			}
-			if err := m.Envelope.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *PeerIdentityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerIdentityRequest) ProtoMessage() {}
+
+func (x *PeerIdentityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerIdentityRequest.ProtoReflect.Descriptor instead.
+func (*PeerIdentityRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{33}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *PeerIdentityRequest) GetPkiIds() [][]byte {
+	if x != nil {
+		return x.PkiIds
 	}
 	return nil
 }
-func (m *ChainStateInfo) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ChainStateInfo: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ChainStateInfo: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Leader = append(m.Leader[:0], dAtA[iNdEx:postIndex]...)
-			if m.Leader == nil {
-				m.Leader = []byte{}
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Properties", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Properties == nil {
-				m.Properties = &Properties{}
-			}
-			if err := m.Properties.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+// PeerIdentityResponse carries the requested identities known by the responder,
+// each one is bound to its PKI-ID by the identity store of the requester
+type PeerIdentityResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Identities []*PeerIdentity `protobuf:"bytes,1,rep,name=identities,proto3" json:"identities,omitempty"`
+}
+
+func (x *PeerIdentityResponse) Reset() {
+	*x = PeerIdentityResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerIdentityResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerIdentityResponse) ProtoMessage() {}
+
+func (x *PeerIdentityResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerIdentityResponse.ProtoReflect.Descriptor instead.
+func (*PeerIdentityResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{34}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *PeerIdentityResponse) GetIdentities() []*PeerIdentity {
+	if x != nil {
+		return x.Identities
 	}
 	return nil
 }
-func (m *Properties) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Properties: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Properties: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Members", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Members = append(m.Members, make([]byte, postIndex-iNdEx))
-			copy(m.Members[len(m.Members)-1], dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Files = append(m.Files, &File{})
-			if err := m.Files[len(m.Files)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+type PeerIdentity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PkiId    []byte `protobuf:"bytes,1,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	Identity []byte `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
+}
+
+func (x *PeerIdentity) Reset() {
+	*x = PeerIdentity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerIdentity) ProtoMessage() {}
+
+func (x *PeerIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerIdentity.ProtoReflect.Descriptor instead.
+func (*PeerIdentity) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{35}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *PeerIdentity) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
 	}
 	return nil
 }
-func (m *File) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: File: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: File: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Path = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Mode", wireType)
-			}
-			m.Mode = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Mode |= File_Mode(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Metadata = append(m.Metadata[:0], dAtA[iNdEx:postIndex]...)
-			if m.Metadata == nil {
-				m.Metadata = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *PeerIdentity) GetIdentity() []byte {
+	if x != nil {
+		return x.Identity
 	}
+	return nil
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+// ChannelInvitation is sent by the leader of a channel to a peer it invites to become a member,
+// the peer is only added to the channel once it sent back a ChannelAcceptance
+type ChannelInvitation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChainId string `protobuf:"bytes,1,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	// PKI-ID of the invited peer
+	Member []byte `protobuf:"bytes,2,opt,name=member,proto3" json:"member,omitempty"`
+	// Time in nanoseconds after which the invitation can't be accepted
+	Expires int64 `protobuf:"varint,3,opt,name=expires,proto3" json:"expires,omitempty"`
+}
+
+func (x *ChannelInvitation) Reset() {
+	*x = ChannelInvitation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
-func (m *ChainStatePullResponse) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ChainStatePullResponse: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ChainStatePullResponse: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Element", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Element == nil {
-				m.Element = &Envelope{}
-			}
-			if err := m.Element.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *ChannelInvitation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelInvitation) ProtoMessage() {}
+
+func (x *ChannelInvitation) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelInvitation.ProtoReflect.Descriptor instead.
+func (*ChannelInvitation) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ChannelInvitation) GetChainId() string {
+	if x != nil {
+		return x.ChainId
 	}
+	return ""
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *ChannelInvitation) GetMember() []byte {
+	if x != nil {
+		return x.Member
 	}
 	return nil
 }
-func (m *ChainStatePullRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ChainStatePullRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ChainStatePullRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Timestamp == nil {
-				m.Timestamp = &PeerTime{}
-			}
-			if err := m.Timestamp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *ChannelInvitation) GetExpires() int64 {
+	if x != nil {
+		return x.Expires
 	}
+	return 0
+}
+
+// ChannelAcceptance answers a ChannelInvitation, signed by the invited peer
+type ChannelAcceptance struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+	Member   []byte `protobuf:"bytes,1,opt,name=member,proto3" json:"member,omitempty"`
+	Accepted bool   `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+}
+
+func (x *ChannelAcceptance) Reset() {
+	*x = ChannelAcceptance{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
-func (m *DataMessage) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: DataMessage: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DataMessage: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FileName", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.FileName = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Payload == nil {
-				m.Payload = &Payload{}
-			}
-			if err := m.Payload.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *ChannelAcceptance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelAcceptance) ProtoMessage() {}
+
+func (x *ChannelAcceptance) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelAcceptance.ProtoReflect.Descriptor instead.
+func (*ChannelAcceptance) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{37}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *ChannelAcceptance) GetMember() []byte {
+	if x != nil {
+		return x.Member
 	}
 	return nil
 }
-func (m *Payload) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Payload: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Payload: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
-			if m.Data == nil {
-				m.Data = []byte{}
-			}
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Append", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &AppendMetadata{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Metadata = &Payload_Append{v}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *ChannelAcceptance) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
 	}
+	return false
+}
+
+type LeaveChainMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChainMac []byte `protobuf:"bytes,1,opt,name=chain_mac,json=chainMac,proto3" json:"chain_mac,omitempty"`
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *LeaveChainMessage) Reset() {
+	*x = LeaveChainMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
-func (m *AppendMetadata) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: AppendMetadata: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: AppendMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
-			}
-			m.Start = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Start |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Length", wireType)
-			}
-			m.Length = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Length |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+func (x *LeaveChainMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveChainMessage) ProtoMessage() {}
+
+func (x *LeaveChainMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveChainMessage.ProtoReflect.Descriptor instead.
+func (*LeaveChainMessage) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{38}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *LeaveChainMessage) GetChainMac() []byte {
+	if x != nil {
+		return x.ChainMac
 	}
 	return nil
 }
-func (m *DataRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: DataRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DataRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FileName", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.FileName = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PkiId", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.PkiId = append(m.PkiId[:0], dAtA[iNdEx:postIndex]...)
-			if m.PkiId == nil {
-				m.PkiId = []byte{}
-			}
-			iNdEx = postIndex
-		case 4:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Append", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			v := &AppendRequest{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Req = &DataRequest_Append{v}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+// LeadershipDeclaration is gossiped in a channel by the member which takes over its leadership,
+// the declaration of the highest term supersedes the others
+type LeadershipDeclaration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PkiId []byte `protobuf:"bytes,1,opt,name=pki_id,json=pkiId,proto3" json:"pki_id,omitempty"`
+	Term  uint64 `protobuf:"varint,2,opt,name=term,proto3" json:"term,omitempty"`
+	// Epoch of the chain state the candidate holds, the members don't follow a candidate behind them
+	Epoch     uint64    `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Timestamp *PeerTime `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *LeadershipDeclaration) Reset() {
+	*x = LeadershipDeclaration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeadershipDeclaration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeadershipDeclaration) ProtoMessage() {}
+
+func (x *LeadershipDeclaration) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeadershipDeclaration.ProtoReflect.Descriptor instead.
+func (*LeadershipDeclaration) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{39}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *LeadershipDeclaration) GetPkiId() []byte {
+	if x != nil {
+		return x.PkiId
 	}
 	return nil
 }
-func (m *AppendRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: AppendRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: AppendRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Length", wireType)
-			}
-			m.Length = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Length |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
+
+func (x *LeadershipDeclaration) GetTerm() uint64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *LeadershipDeclaration) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
 	}
+	return 0
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *LeadershipDeclaration) GetTimestamp() *PeerTime {
+	if x != nil {
+		return x.Timestamp
 	}
 	return nil
 }
-func (m *LeaveChainMessage) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: LeaveChainMessage: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LeaveChainMessage: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ChainMac", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRksync
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ChainMac = append(m.ChainMac[:0], dAtA[iNdEx:postIndex]...)
-			if m.ChainMac == nil {
-				m.ChainMac = []byte{}
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRksync(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRksync
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+
+// LeadershipVote is sent by a member of a channel to the candidate of a term,
+// a member grants a single vote per term
+type LeadershipVote struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Candidate []byte `protobuf:"bytes,1,opt,name=candidate,proto3" json:"candidate,omitempty"`
+	Voter     []byte `protobuf:"bytes,2,opt,name=voter,proto3" json:"voter,omitempty"`
+	Term      uint64 `protobuf:"varint,3,opt,name=term,proto3" json:"term,omitempty"`
+	Granted   bool   `protobuf:"varint,4,opt,name=granted,proto3" json:"granted,omitempty"`
+}
+
+func (x *LeadershipVote) Reset() {
+	*x = LeadershipVote{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeadershipVote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeadershipVote) ProtoMessage() {}
+
+func (x *LeadershipVote) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeadershipVote.ProtoReflect.Descriptor instead.
+func (*LeadershipVote) Descriptor() ([]byte, []int) {
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP(), []int{40}
+}
 
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
+func (x *LeadershipVote) GetCandidate() []byte {
+	if x != nil {
+		return x.Candidate
 	}
 	return nil
 }
-func skipRksync(dAtA []byte) (n int, err error) {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return 0, ErrIntOverflowRksync
-			}
-			if iNdEx >= l {
-				return 0, io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		wireType := int(wire & 0x7)
-		switch wireType {
-		case 0:
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return 0, ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return 0, io.ErrUnexpectedEOF
-				}
-				iNdEx++
-				if dAtA[iNdEx-1] < 0x80 {
-					break
-				}
-			}
-			return iNdEx, nil
-		case 1:
-			iNdEx += 8
-			return iNdEx, nil
-		case 2:
-			var length int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return 0, ErrIntOverflowRksync
-				}
-				if iNdEx >= l {
-					return 0, io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				length |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if length < 0 {
-				return 0, ErrInvalidLengthRksync
-			}
-			iNdEx += length
-			if iNdEx < 0 {
-				return 0, ErrInvalidLengthRksync
-			}
-			return iNdEx, nil
-		case 3:
-			for {
-				var innerWire uint64
-				var start int = iNdEx
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return 0, ErrIntOverflowRksync
-					}
-					if iNdEx >= l {
-						return 0, io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					innerWire |= (uint64(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				innerWireType := int(innerWire & 0x7)
-				if innerWireType == 4 {
-					break
-				}
-				next, err := skipRksync(dAtA[start:])
-				if err != nil {
-					return 0, err
-				}
-				iNdEx = start + next
-				if iNdEx < 0 {
-					return 0, ErrInvalidLengthRksync
-				}
-			}
-			return iNdEx, nil
-		case 4:
-			return iNdEx, nil
-		case 5:
-			iNdEx += 4
-			return iNdEx, nil
-		default:
-			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
-		}
+
+func (x *LeadershipVote) GetVoter() []byte {
+	if x != nil {
+		return x.Voter
 	}
-	panic("unreachable")
+	return nil
+}
+
+func (x *LeadershipVote) GetTerm() uint64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *LeadershipVote) GetGranted() bool {
+	if x != nil {
+		return x.Granted
+	}
+	return false
+}
+
+var File_github_com_rkcloudchain_rksync_protos_rksync_proto protoreflect.FileDescriptor
+
+var file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDesc = []byte{
+	0x0a, 0x32, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x72, 0x6b, 0x63,
+	0x6c, 0x6f, 0x75, 0x64, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x2f, 0x72, 0x6b, 0x73, 0x79, 0x6e, 0x63,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x72, 0x6b, 0x73, 0x79, 0x6e, 0x63, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x1a, 0x1b, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x65, 0x6d,
+	0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xed, 0x01, 0x0a, 0x08, 0x45, 0x6e,
+	0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x4b,
+	0x0a, 0x13, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x61, 0x6c, 0x67, 0x6f,
+	0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x41, 0x6c,
+	0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x12, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x3f, 0x0a, 0x0f, 0x73,
+	0x65, 0x63, 0x72, 0x65, 0x74, 0x5f, 0x65, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x65,
+	0x63, 0x72, 0x65, 0x74, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x0e, 0x73, 0x65,
+	0x63, 0x72, 0x65, 0x74, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x1b, 0x0a, 0x09,
+	0x68, 0x6f, 0x70, 0x73, 0x5f, 0x6c, 0x65, 0x66, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x08, 0x68, 0x6f, 0x70, 0x73, 0x4c, 0x65, 0x66, 0x74, 0x22, 0x48, 0x0a, 0x0e, 0x53, 0x65, 0x63,
+	0x72, 0x65, 0x74, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x22, 0x42, 0x0a, 0x06, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x12, 0x2d, 0x0a,
+	0x11, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x5f, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x10, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x42, 0x09, 0x0a, 0x07,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0xe3, 0x0c, 0x0a, 0x0d, 0x52, 0x4b, 0x53, 0x79,
+	0x6e, 0x63, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e,
+	0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x5f, 0x6d, 0x61, 0x63, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x08, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x4d, 0x61, 0x63, 0x12, 0x2b, 0x0a, 0x03,
+	0x74, 0x61, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x73, 0x2e, 0x52, 0x4b, 0x53, 0x79, 0x6e, 0x63, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x54, 0x61, 0x67, 0x52, 0x03, 0x74, 0x61, 0x67, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x33, 0x0a, 0x09, 0x61, 0x6c, 0x69, 0x76, 0x65,
+	0x5f, 0x6d, 0x73, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x48, 0x00, 0x52, 0x08, 0x61, 0x6c, 0x69, 0x76, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x2e, 0x0a, 0x05,
+	0x65, 0x6d, 0x70, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d,
+	0x70, 0x74, 0x79, 0x48, 0x00, 0x52, 0x05, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x2b, 0x0a, 0x04,
+	0x63, 0x6f, 0x6e, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x45, 0x73, 0x74, 0x61, 0x62, 0x6c, 0x69, 0x73,
+	0x68, 0x48, 0x00, 0x52, 0x04, 0x63, 0x6f, 0x6e, 0x6e, 0x12, 0x2b, 0x0a, 0x03, 0x61, 0x63, 0x6b,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
+	0x41, 0x63, 0x6b, 0x6e, 0x6f, 0x77, 0x6c, 0x65, 0x64, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x48,
+	0x00, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x12, 0x34, 0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x5f, 0x72, 0x65,
+	0x71, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x48, 0x00, 0x52, 0x06, 0x6d, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x12, 0x35, 0x0a, 0x07,
+	0x6d, 0x65, 0x6d, 0x5f, 0x72, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69,
+	0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x06, 0x6d, 0x65, 0x6d,
+	0x52, 0x65, 0x73, 0x12, 0x2a, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68, 0x61, 0x69,
+	0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12,
+	0x4d, 0x0a, 0x12, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x75, 0x6c, 0x6c, 0x5f, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x50,
+	0x75, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x10, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x50, 0x75, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x50,
+	0x0a, 0x13, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x75, 0x6c, 0x6c, 0x5f, 0x72, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x50,
+	0x75, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x11, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x50, 0x75, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x37, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68,
+	0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x48, 0x00, 0x52, 0x09,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x30, 0x0a, 0x08, 0x64, 0x61, 0x74,
+	0x61, 0x5f, 0x6d, 0x73, 0x67, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x48, 0x00, 0x52, 0x07, 0x64, 0x61, 0x74, 0x61, 0x4d, 0x73, 0x67, 0x12, 0x30, 0x0a, 0x08, 0x64,
+	0x61, 0x74, 0x61, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x48, 0x00, 0x52, 0x07, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x12, 0x3c, 0x0a,
+	0x0b, 0x6c, 0x65, 0x61, 0x76, 0x65, 0x5f, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x18, 0x11, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x65, 0x61, 0x76,
+	0x65, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52,
+	0x0a, 0x6c, 0x65, 0x61, 0x76, 0x65, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x12, 0x40, 0x0a, 0x0e, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x12, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
+	0x0c, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x12, 0x41, 0x0a,
+	0x0e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x65, 0x73, 0x18,
+	0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x48, 0x00, 0x52, 0x0c, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x73,
+	0x12, 0x33, 0x0a, 0x09, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x14, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x08, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x52, 0x65, 0x71, 0x12, 0x34, 0x0a, 0x09, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x72,
+	0x65, 0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48,
+	0x00, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x12, 0x56, 0x0a, 0x16, 0x6c,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x5f, 0x64, 0x65, 0x63, 0x6c, 0x61, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x16, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x44,
+	0x65, 0x63, 0x6c, 0x61, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x15, 0x6c, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x44, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x41, 0x0a, 0x0f, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69,
+	0x70, 0x5f, 0x76, 0x6f, 0x74, 0x65, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70,
+	0x56, 0x6f, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0e, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68,
+	0x69, 0x70, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x2c, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x61,
+	0x63, 0x6b, 0x18, 0x18, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x41, 0x63, 0x6b, 0x48, 0x00, 0x52, 0x07, 0x66, 0x69, 0x6c,
+	0x65, 0x41, 0x63, 0x6b, 0x12, 0x40, 0x0a, 0x0c, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79,
+	0x5f, 0x72, 0x65, 0x71, 0x18, 0x19, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0b, 0x69, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x74, 0x79, 0x52, 0x65, 0x71, 0x12, 0x41, 0x0a, 0x0c, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x79, 0x5f, 0x72, 0x65, 0x73, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0b, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x65, 0x73, 0x12, 0x4a, 0x0a, 0x12, 0x63, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x5f, 0x69, 0x6e, 0x76, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x1b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43,
+	0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x6e, 0x76, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x48, 0x00, 0x52, 0x11, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x6e, 0x76, 0x69, 0x74,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x4a, 0x0a, 0x12, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x5f, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x1c, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x41, 0x63, 0x63, 0x65, 0x70, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x48, 0x00, 0x52, 0x11,
+	0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x41, 0x63, 0x63, 0x65, 0x70, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x22, 0x1f, 0x0a, 0x03, 0x54, 0x61, 0x67, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x4d, 0x50, 0x54,
+	0x59, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x48, 0x41, 0x4e, 0x5f, 0x4f, 0x4e, 0x4c, 0x59,
+	0x10, 0x01, 0x42, 0x09, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0x9f, 0x01,
+	0x0a, 0x0d, 0x43, 0x6f, 0x6e, 0x6e, 0x45, 0x73, 0x74, 0x61, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x12,
+	0x15, 0x0a, 0x06, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x79, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x5f, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x30, 0x0a,
+	0x14, 0x6d, 0x69, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x5f, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x12, 0x6d, 0x69, 0x6e,
+	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22,
+	0xa4, 0x01, 0x0a, 0x0c, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x2e, 0x0a, 0x0a, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4d, 0x65,
+	0x6d, 0x62, 0x65, 0x72, 0x52, 0x0a, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70,
+	0x12, 0x2e, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65, 0x65,
+	0x72, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x12, 0x1a, 0x0a, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07,
+	0x6c, 0x65, 0x61, 0x76, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x6c,
+	0x65, 0x61, 0x76, 0x69, 0x6e, 0x67, 0x22, 0x3c, 0x0a, 0x08, 0x50, 0x65, 0x65, 0x72, 0x54, 0x69,
+	0x6d, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x69, 0x6e, 0x63, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x69, 0x6e, 0x63, 0x4e, 0x75, 0x6d, 0x12, 0x17, 0x0a, 0x07, 0x73,
+	0x65, 0x71, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x73, 0x65,
+	0x71, 0x4e, 0x75, 0x6d, 0x22, 0xe4, 0x01, 0x0a, 0x06, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x12,
+	0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x70,
+	0x6b, 0x69, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x6b, 0x69,
+	0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6f, 0x72,
+	0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6f, 0x72, 0x67, 0x12, 0x32, 0x0a, 0x06,
+	0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x2e, 0x4c, 0x61, 0x62,
+	0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73,
+	0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x27, 0x0a, 0x0f, 0x41,
+	0x63, 0x6b, 0x6e, 0x6f, 0x77, 0x6c, 0x65, 0x64, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x22, 0xac, 0x01, 0x0a, 0x11, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73,
+	0x68, 0x69, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3b, 0x0a, 0x10, 0x73, 0x65,
+	0x6c, 0x66, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e,
+	0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x0f, 0x73, 0x65, 0x6c, 0x66, 0x49, 0x6e, 0x66, 0x6f,
+	0x72, 0x6d, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a, 0x05, 0x6b, 0x6e, 0x6f, 0x77, 0x6e,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
+	0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x05, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x12,
+	0x32, 0x0a, 0x07, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72,
+	0x73, 0x68, 0x69, 0x70, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x52, 0x07, 0x64, 0x69, 0x67, 0x65,
+	0x73, 0x74, 0x73, 0x22, 0x7a, 0x0a, 0x12, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69,
+	0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x61, 0x6c, 0x69,
+	0x76, 0x65, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x05, 0x61, 0x6c, 0x69, 0x76,
+	0x65, 0x12, 0x24, 0x0a, 0x04, 0x64, 0x65, 0x61, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70,
+	0x65, 0x52, 0x04, 0x64, 0x65, 0x61, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x61, 0x6e, 0x74, 0x65,
+	0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x06, 0x77, 0x61, 0x6e, 0x74, 0x65, 0x64, 0x22,
+	0x59, 0x0a, 0x10, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x44, 0x69, 0x67,
+	0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x12, 0x2e, 0x0a, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x52,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0xc8, 0x01, 0x0a, 0x0a, 0x43,
+	0x68, 0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x65, 0x71,
+	0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x73, 0x65, 0x71, 0x4e,
+	0x75, 0x6d, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x49, 0x64, 0x12, 0x2c, 0x0a,
+	0x08, 0x65, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70,
+	0x65, 0x52, 0x08, 0x65, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x28, 0x0a, 0x06, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x06, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65,
+	0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x09, 0x68, 0x65, 0x61, 0x72,
+	0x74, 0x62, 0x65, 0x61, 0x74, 0x22, 0xa9, 0x02, 0x0a, 0x0c, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65,
+	0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x1b, 0x0a, 0x09,
+	0x70, 0x72, 0x65, 0x76, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x08, 0x70, 0x72, 0x65, 0x76, 0x48, 0x61, 0x73, 0x68, 0x12, 0x27, 0x0a, 0x02, 0x6f, 0x70, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x2e, 0x4f, 0x70, 0x52, 0x02,
+	0x6f, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x0c, 0x52, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x66, 0x69, 0x6c,
+	0x65, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x65, 0x71, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x73, 0x65, 0x71, 0x4e, 0x75, 0x6d, 0x12, 0x1c, 0x0a, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x56, 0x0a, 0x02, 0x4f, 0x70, 0x12,
+	0x0e, 0x0a, 0x0a, 0x49, 0x4e, 0x49, 0x54, 0x49, 0x41, 0x4c, 0x49, 0x5a, 0x45, 0x10, 0x00, 0x12,
+	0x0e, 0x0a, 0x0a, 0x41, 0x44, 0x44, 0x5f, 0x4d, 0x45, 0x4d, 0x42, 0x45, 0x52, 0x10, 0x01, 0x12,
+	0x11, 0x0a, 0x0d, 0x52, 0x45, 0x4d, 0x4f, 0x56, 0x45, 0x5f, 0x4d, 0x45, 0x4d, 0x42, 0x45, 0x52,
+	0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x44, 0x44, 0x5f, 0x46, 0x49, 0x4c, 0x45, 0x10, 0x03,
+	0x12, 0x0f, 0x0a, 0x0b, 0x52, 0x45, 0x4d, 0x4f, 0x56, 0x45, 0x5f, 0x46, 0x49, 0x4c, 0x45, 0x10,
+	0x04, 0x22, 0xa6, 0x01, 0x0a, 0x0d, 0x4a, 0x6f, 0x75, 0x72, 0x6e, 0x61, 0x6c, 0x52, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x65, 0x71, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x73, 0x65, 0x71, 0x4e, 0x75, 0x6d, 0x12, 0x14, 0x0a, 0x05,
+	0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f,
+	0x63, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x69,
+	0x67, 0x6e, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x73, 0x69, 0x67, 0x6e,
+	0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x31, 0x0a, 0x10, 0x43, 0x68,
+	0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d,
+	0x0a, 0x0a, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x09, 0x66, 0x72, 0x6f, 0x6d, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x22, 0x3f, 0x0a,
+	0x11, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x2a, 0x0a, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76,
+	0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x22, 0x95,
+	0x01, 0x0a, 0x0e, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x66,
+	0x6f, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x0a, 0x70, 0x72, 0x6f,
+	0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65,
+	0x73, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x12, 0x21, 0x0a,
+	0x0c, 0x61, 0x6e, 0x63, 0x68, 0x6f, 0x72, 0x5f, 0x70, 0x65, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x0b, 0x61, 0x6e, 0x63, 0x68, 0x6f, 0x72, 0x50, 0x65, 0x65, 0x72, 0x73,
+	0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x4a, 0x0a, 0x0a, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72,
+	0x74, 0x69, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x22,
+	0x0a, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x05, 0x66, 0x69, 0x6c,
+	0x65, 0x73, 0x22, 0xa1, 0x02, 0x0a, 0x04, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12,
+	0x25, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x2e, 0x4d, 0x6f, 0x64, 0x65,
+	0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12,
+	0x12, 0x0a, 0x04, 0x70, 0x65, 0x72, 0x6d, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70,
+	0x65, 0x72, 0x6d, 0x12, 0x35, 0x0a, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65,
+	0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x46, 0x69, 0x6c, 0x65, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x52, 0x0a,
+	0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72,
+	0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72,
+	0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x22, 0x1e, 0x0a, 0x04, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x0a,
+	0x0a, 0x06, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x61,
+	0x6e, 0x64, 0x6f, 0x6d, 0x10, 0x01, 0x22, 0x37, 0x0a, 0x0d, 0x46, 0x69, 0x6c, 0x65, 0x41, 0x74,
+	0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22,
+	0x44, 0x0a, 0x16, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x50, 0x75, 0x6c,
+	0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2a, 0x0a, 0x07, 0x65, 0x6c, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x52, 0x07, 0x65, 0x6c,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x76, 0x0a, 0x15, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x50, 0x75, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2e,
+	0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x54,
+	0x69, 0x6d, 0x65, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x17,
+	0x0a, 0x07, 0x73, 0x65, 0x71, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x06, 0x73, 0x65, 0x71, 0x4e, 0x75, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x4c, 0x0a,
+	0x0c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a,
+	0x07, 0x73, 0x65, 0x71, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06,
+	0x73, 0x65, 0x71, 0x4e, 0x75, 0x6d, 0x12, 0x23, 0x0a, 0x0d, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65,
+	0x73, 0x74, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6d,
+	0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x46, 0x72, 0x6f, 0x6d, 0x22, 0x86, 0x01, 0x0a, 0x0d,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2a, 0x0a,
+	0x07, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65,
+	0x52, 0x07, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x35, 0x0a, 0x08, 0x6d, 0x61, 0x6e,
+	0x69, 0x66, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73,
+	0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x65, 0x78, 0x74, 0x22, 0x3f, 0x0a, 0x11, 0x46, 0x69, 0x6c, 0x65, 0x4d, 0x61, 0x6e, 0x69,
+	0x66, 0x65, 0x73, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74,
+	0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a,
+	0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6c,
+	0x65, 0x6e, 0x67, 0x74, 0x68, 0x22, 0x55, 0x0a, 0x0b, 0x44, 0x61, 0x74, 0x61, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x4e, 0x61, 0x6d,
+	0x65, 0x12, 0x29, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x61, 0x79, 0x6c,
+	0x6f, 0x61, 0x64, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x5b, 0x0a, 0x07,
+	0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x30, 0x0a, 0x06, 0x61,
+	0x70, 0x70, 0x65, 0x6e, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x06, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x42, 0x0a, 0x0a,
+	0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x22, 0xac, 0x01, 0x0a, 0x0e, 0x41, 0x70,
+	0x70, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05,
+	0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x72, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x72,
+	0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x74,
+	0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65,
+	0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74,
+	0x12, 0x19, 0x0a, 0x08, 0x6d, 0x6f, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x66,
+	0x69, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x66, 0x69, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x22, 0xb4, 0x01, 0x0a, 0x0b, 0x44, 0x61, 0x74,
+	0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65,
+	0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c,
+	0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x12, 0x2f, 0x0a, 0x06,
+	0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x06, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x12, 0x39, 0x0a,
+	0x09, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x66,
+	0x65, 0x72, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x09, 0x72,
+	0x65, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x05, 0x0a, 0x03, 0x72, 0x65, 0x71, 0x22,
+	0x74, 0x0a, 0x0d, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x1b, 0x0a, 0x09, 0x74, 0x61, 0x69, 0x6c,
+	0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x74, 0x61, 0x69,
+	0x6c, 0x48, 0x61, 0x73, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x12,
+	0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05,
+	0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0xa2, 0x01, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x66,
+	0x65, 0x72, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x35, 0x0a, 0x05, 0x6c,
+	0x69, 0x6d, 0x69, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x52, 0x65, 0x6a, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x52, 0x05, 0x6c, 0x69, 0x6d,
+	0x69, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x12, 0x1c,
+	0x0a, 0x09, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x09, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x22, 0x1c, 0x0a, 0x05,
+	0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x09, 0x0a, 0x05, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x10, 0x00,
+	0x12, 0x08, 0x0a, 0x04, 0x44, 0x69, 0x73, 0x6b, 0x10, 0x01, 0x22, 0x6c, 0x0a, 0x07, 0x46, 0x69,
+	0x6c, 0x65, 0x41, 0x63, 0x6b, 0x12, 0x1b, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x4e, 0x61,
+	0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x2e, 0x0a, 0x13, 0x50, 0x65, 0x65, 0x72,
+	0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x17, 0x0a, 0x07, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c,
+	0x52, 0x06, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x73, 0x22, 0x4c, 0x0a, 0x14, 0x50, 0x65, 0x65, 0x72,
+	0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x34, 0x0a, 0x0a, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65,
+	0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x0a, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0x41, 0x0a, 0x0c, 0x50, 0x65, 0x65, 0x72, 0x49, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x15, 0x0a, 0x06, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x22, 0x60, 0x0a, 0x11, 0x43, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x6e, 0x76, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x19,
+	0x0a, 0x08, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x6d, 0x65, 0x6d, 0x62, 0x65,
+	0x72, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x22, 0x47, 0x0a, 0x11, 0x43,
+	0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x41, 0x63, 0x63, 0x65, 0x70, 0x74, 0x61, 0x6e, 0x63, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x06, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x63, 0x63, 0x65,
+	0x70, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x61, 0x63, 0x63, 0x65,
+	0x70, 0x74, 0x65, 0x64, 0x22, 0x30, 0x0a, 0x11, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x43, 0x68, 0x61,
+	0x69, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x68, 0x61,
+	0x69, 0x6e, 0x5f, 0x6d, 0x61, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x63, 0x68,
+	0x61, 0x69, 0x6e, 0x4d, 0x61, 0x63, 0x22, 0x88, 0x01, 0x0a, 0x15, 0x4c, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x73, 0x68, 0x69, 0x70, 0x44, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x15, 0x0a, 0x06, 0x70, 0x6b, 0x69, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x70, 0x6b, 0x69, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63,
+	0x68, 0x12, 0x2e, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x65,
+	0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x22, 0x72, 0x0a, 0x0e, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x56,
+	0x6f, 0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x67,
+	0x72, 0x61, 0x6e, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x67, 0x72,
+	0x61, 0x6e, 0x74, 0x65, 0x64, 0x2a, 0x58, 0x0a, 0x12, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x0f, 0x0a, 0x0b, 0x55,
+	0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e,
+	0x45, 0x43, 0x44, 0x53, 0x41, 0x5f, 0x53, 0x48, 0x41, 0x33, 0x5f, 0x32, 0x35, 0x36, 0x10, 0x01,
+	0x12, 0x10, 0x0a, 0x0c, 0x52, 0x53, 0x41, 0x5f, 0x53, 0x48, 0x41, 0x33, 0x5f, 0x32, 0x35, 0x36,
+	0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x45, 0x44, 0x32, 0x35, 0x35, 0x31, 0x39, 0x10, 0x03, 0x32,
+	0xb2, 0x01, 0x0a, 0x06, 0x52, 0x4b, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x36, 0x0a, 0x0a, 0x53, 0x79,
+	0x6e, 0x63, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x1a, 0x10, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x22, 0x00, 0x28, 0x01,
+	0x30, 0x01, 0x12, 0x36, 0x0a, 0x0a, 0x44, 0x61, 0x74, 0x61, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x12, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f,
+	0x70, 0x65, 0x1a, 0x10, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x45, 0x6e, 0x76, 0x65,
+	0x6c, 0x6f, 0x70, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x38, 0x0a, 0x04, 0x50, 0x69,
+	0x6e, 0x67, 0x12, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x00, 0x42, 0x27, 0x5a, 0x25, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2f, 0x72, 0x6b, 0x63, 0x6c, 0x6f, 0x75, 0x64, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x2f,
+	0x72, 0x6b, 0x73, 0x79, 0x6e, 0x63, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
-	ErrInvalidLengthRksync = fmt.Errorf("proto: negative length found during unmarshaling")
-	ErrIntOverflowRksync   = fmt.Errorf("proto: integer overflow")
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescOnce sync.Once
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescData = file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDesc
 )
+
+func file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescGZIP() []byte {
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescOnce.Do(func() {
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescData = protoimpl.X.CompressGZIP(file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescData)
+	})
+	return file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDescData
+}
+
+var file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes = make([]protoimpl.MessageInfo, 42)
+var file_github_com_rkcloudchain_rksync_protos_rksync_proto_goTypes = []interface{}{
+	(SignatureAlgorithm)(0),        // 0: protos.SignatureAlgorithm
+	(RKSyncMessage_Tag)(0),         // 1: protos.RKSyncMessage.Tag
+	(ChangeRecord_Op)(0),           // 2: protos.ChangeRecord.Op
+	(File_Mode)(0),                 // 3: protos.File.Mode
+	(TransferRejection_Limit)(0),   // 4: protos.TransferRejection.Limit
+	(*Envelope)(nil),               // 5: protos.Envelope
+	(*SecretEnvelope)(nil),         // 6: protos.SecretEnvelope
+	(*Secret)(nil),                 // 7: protos.Secret
+	(*RKSyncMessage)(nil),          // 8: protos.RKSyncMessage
+	(*ConnEstablish)(nil),          // 9: protos.ConnEstablish
+	(*AliveMessage)(nil),           // 10: protos.AliveMessage
+	(*PeerTime)(nil),               // 11: protos.PeerTime
+	(*Member)(nil),                 // 12: protos.Member
+	(*Acknowledgement)(nil),        // 13: protos.Acknowledgement
+	(*MembershipRequest)(nil),      // 14: protos.MembershipRequest
+	(*MembershipResponse)(nil),     // 15: protos.MembershipResponse
+	(*MembershipDigest)(nil),       // 16: protos.MembershipDigest
+	(*ChainState)(nil),             // 17: protos.ChainState
+	(*ChangeRecord)(nil),           // 18: protos.ChangeRecord
+	(*JournalRecord)(nil),          // 19: protos.JournalRecord
+	(*ChangeLogRequest)(nil),       // 20: protos.ChangeLogRequest
+	(*ChangeLogResponse)(nil),      // 21: protos.ChangeLogResponse
+	(*ChainStateInfo)(nil),         // 22: protos.ChainStateInfo
+	(*Properties)(nil),             // 23: protos.Properties
+	(*File)(nil),                   // 24: protos.File
+	(*FileAttribute)(nil),          // 25: protos.FileAttribute
+	(*ChainStatePullResponse)(nil), // 26: protos.ChainStatePullResponse
+	(*ChainStatePullRequest)(nil),  // 27: protos.ChainStatePullRequest
+	(*StateRequest)(nil),           // 28: protos.StateRequest
+	(*StateResponse)(nil),          // 29: protos.StateResponse
+	(*FileManifestEntry)(nil),      // 30: protos.FileManifestEntry
+	(*DataMessage)(nil),            // 31: protos.DataMessage
+	(*Payload)(nil),                // 32: protos.Payload
+	(*AppendMetadata)(nil),         // 33: protos.AppendMetadata
+	(*DataRequest)(nil),            // 34: protos.DataRequest
+	(*AppendRequest)(nil),          // 35: protos.AppendRequest
+	(*TransferRejection)(nil),      // 36: protos.TransferRejection
+	(*FileAck)(nil),                // 37: protos.FileAck
+	(*PeerIdentityRequest)(nil),    // 38: protos.PeerIdentityRequest
+	(*PeerIdentityResponse)(nil),   // 39: protos.PeerIdentityResponse
+	(*PeerIdentity)(nil),           // 40: protos.PeerIdentity
+	(*ChannelInvitation)(nil),      // 41: protos.ChannelInvitation
+	(*ChannelAcceptance)(nil),      // 42: protos.ChannelAcceptance
+	(*LeaveChainMessage)(nil),      // 43: protos.LeaveChainMessage
+	(*LeadershipDeclaration)(nil),  // 44: protos.LeadershipDeclaration
+	(*LeadershipVote)(nil),         // 45: protos.LeadershipVote
+	nil,                            // 46: protos.Member.LabelsEntry
+	(*emptypb.Empty)(nil),          // 47: google.protobuf.Empty
+}
+var file_github_com_rkcloudchain_rksync_protos_rksync_proto_depIdxs = []int32{
+	0,  // 0: protos.Envelope.signature_algorithm:type_name -> protos.SignatureAlgorithm
+	6,  // 1: protos.Envelope.secret_envelope:type_name -> protos.SecretEnvelope
+	1,  // 2: protos.RKSyncMessage.tag:type_name -> protos.RKSyncMessage.Tag
+	10, // 3: protos.RKSyncMessage.alive_msg:type_name -> protos.AliveMessage
+	47, // 4: protos.RKSyncMessage.empty:type_name -> google.protobuf.Empty
+	9,  // 5: protos.RKSyncMessage.conn:type_name -> protos.ConnEstablish
+	13, // 6: protos.RKSyncMessage.ack:type_name -> protos.Acknowledgement
+	14, // 7: protos.RKSyncMessage.mem_req:type_name -> protos.MembershipRequest
+	15, // 8: protos.RKSyncMessage.mem_res:type_name -> protos.MembershipResponse
+	17, // 9: protos.RKSyncMessage.state:type_name -> protos.ChainState
+	27, // 10: protos.RKSyncMessage.state_pull_request:type_name -> protos.ChainStatePullRequest
+	26, // 11: protos.RKSyncMessage.state_pull_response:type_name -> protos.ChainStatePullResponse
+	22, // 12: protos.RKSyncMessage.state_info:type_name -> protos.ChainStateInfo
+	31, // 13: protos.RKSyncMessage.data_msg:type_name -> protos.DataMessage
+	34, // 14: protos.RKSyncMessage.data_req:type_name -> protos.DataRequest
+	43, // 15: protos.RKSyncMessage.leave_chain:type_name -> protos.LeaveChainMessage
+	20, // 16: protos.RKSyncMessage.change_log_req:type_name -> protos.ChangeLogRequest
+	21, // 17: protos.RKSyncMessage.change_log_res:type_name -> protos.ChangeLogResponse
+	28, // 18: protos.RKSyncMessage.state_req:type_name -> protos.StateRequest
+	29, // 19: protos.RKSyncMessage.state_res:type_name -> protos.StateResponse
+	44, // 20: protos.RKSyncMessage.leadership_declaration:type_name -> protos.LeadershipDeclaration
+	45, // 21: protos.RKSyncMessage.leadership_vote:type_name -> protos.LeadershipVote
+	37, // 22: protos.RKSyncMessage.file_ack:type_name -> protos.FileAck
+	38, // 23: protos.RKSyncMessage.identity_req:type_name -> protos.PeerIdentityRequest
+	39, // 24: protos.RKSyncMessage.identity_res:type_name -> protos.PeerIdentityResponse
+	41, // 25: protos.RKSyncMessage.channel_invitation:type_name -> protos.ChannelInvitation
+	42, // 26: protos.RKSyncMessage.channel_acceptance:type_name -> protos.ChannelAcceptance
+	12, // 27: protos.AliveMessage.membership:type_name -> protos.Member
+	11, // 28: protos.AliveMessage.timestamp:type_name -> protos.PeerTime
+	46, // 29: protos.Member.labels:type_name -> protos.Member.LabelsEntry
+	5,  // 30: protos.MembershipRequest.self_information:type_name -> protos.Envelope
+	5,  // 31: protos.MembershipRequest.known:type_name -> protos.Envelope
+	16, // 32: protos.MembershipRequest.digests:type_name -> protos.MembershipDigest
+	5,  // 33: protos.MembershipResponse.alive:type_name -> protos.Envelope
+	5,  // 34: protos.MembershipResponse.dead:type_name -> protos.Envelope
+	11, // 35: protos.MembershipDigest.timestamp:type_name -> protos.PeerTime
+	5,  // 36: protos.ChainState.envelope:type_name -> protos.Envelope
+	5,  // 37: protos.ChainState.change:type_name -> protos.Envelope
+	11, // 38: protos.ChainState.heartbeat:type_name -> protos.PeerTime
+	2,  // 39: protos.ChangeRecord.op:type_name -> protos.ChangeRecord.Op
+	5,  // 40: protos.ChangeLogResponse.records:type_name -> protos.Envelope
+	23, // 41: protos.ChainStateInfo.properties:type_name -> protos.Properties
+	24, // 42: protos.Properties.files:type_name -> protos.File
+	3,  // 43: protos.File.mode:type_name -> protos.File.Mode
+	25, // 44: protos.File.attributes:type_name -> protos.FileAttribute
+	5,  // 45: protos.ChainStatePullResponse.element:type_name -> protos.Envelope
+	11, // 46: protos.ChainStatePullRequest.timestamp:type_name -> protos.PeerTime
+	5,  // 47: protos.StateResponse.element:type_name -> protos.Envelope
+	30, // 48: protos.StateResponse.manifest:type_name -> protos.FileManifestEntry
+	32, // 49: protos.DataMessage.payload:type_name -> protos.Payload
+	33, // 50: protos.Payload.append:type_name -> protos.AppendMetadata
+	35, // 51: protos.DataRequest.append:type_name -> protos.AppendRequest
+	36, // 52: protos.DataRequest.rejection:type_name -> protos.TransferRejection
+	4,  // 53: protos.TransferRejection.limit:type_name -> protos.TransferRejection.Limit
+	40, // 54: protos.PeerIdentityResponse.identities:type_name -> protos.PeerIdentity
+	11, // 55: protos.LeadershipDeclaration.timestamp:type_name -> protos.PeerTime
+	5,  // 56: protos.RKSync.SyncStream:input_type -> protos.Envelope
+	5,  // 57: protos.RKSync.DataStream:input_type -> protos.Envelope
+	47, // 58: protos.RKSync.Ping:input_type -> google.protobuf.Empty
+	5,  // 59: protos.RKSync.SyncStream:output_type -> protos.Envelope
+	5,  // 60: protos.RKSync.DataStream:output_type -> protos.Envelope
+	47, // 61: protos.RKSync.Ping:output_type -> google.protobuf.Empty
+	59, // [59:62] is the sub-list for method output_type
+	56, // [56:59] is the sub-list for method input_type
+	56, // [56:56] is the sub-list for extension type_name
+	56, // [56:56] is the sub-list for extension extendee
+	0,  // [0:56] is the sub-list for field type_name
+}
+
+func init() { file_github_com_rkcloudchain_rksync_protos_rksync_proto_init() }
+func file_github_com_rkcloudchain_rksync_protos_rksync_proto_init() {
+	if File_github_com_rkcloudchain_rksync_protos_rksync_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Envelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SecretEnvelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Secret); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RKSyncMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConnEstablish); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AliveMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerTime); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Member); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Acknowledgement); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MembershipRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MembershipResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MembershipDigest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChainState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChangeRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*JournalRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChangeLogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChangeLogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChainStateInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Properties); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*File); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FileAttribute); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChainStatePullResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChainStatePullRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FileManifestEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DataMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Payload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AppendMetadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DataRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AppendRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TransferRejection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FileAck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerIdentityRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerIdentityResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerIdentity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelInvitation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelAcceptance); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeaveChainMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeadershipDeclaration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeadershipVote); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[2].OneofWrappers = []interface{}{
+		(*Secret_InternalEndpoint)(nil),
+	}
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[3].OneofWrappers = []interface{}{
+		(*RKSyncMessage_AliveMsg)(nil),
+		(*RKSyncMessage_Empty)(nil),
+		(*RKSyncMessage_Conn)(nil),
+		(*RKSyncMessage_Ack)(nil),
+		(*RKSyncMessage_MemReq)(nil),
+		(*RKSyncMessage_MemRes)(nil),
+		(*RKSyncMessage_State)(nil),
+		(*RKSyncMessage_StatePullRequest)(nil),
+		(*RKSyncMessage_StatePullResponse)(nil),
+		(*RKSyncMessage_StateInfo)(nil),
+		(*RKSyncMessage_DataMsg)(nil),
+		(*RKSyncMessage_DataReq)(nil),
+		(*RKSyncMessage_LeaveChain)(nil),
+		(*RKSyncMessage_ChangeLogReq)(nil),
+		(*RKSyncMessage_ChangeLogRes)(nil),
+		(*RKSyncMessage_StateReq)(nil),
+		(*RKSyncMessage_StateRes)(nil),
+		(*RKSyncMessage_LeadershipDeclaration)(nil),
+		(*RKSyncMessage_LeadershipVote)(nil),
+		(*RKSyncMessage_FileAck)(nil),
+		(*RKSyncMessage_IdentityReq)(nil),
+		(*RKSyncMessage_IdentityRes)(nil),
+		(*RKSyncMessage_ChannelInvitation)(nil),
+		(*RKSyncMessage_ChannelAcceptance)(nil),
+	}
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[27].OneofWrappers = []interface{}{
+		(*Payload_Append)(nil),
+	}
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes[29].OneofWrappers = []interface{}{
+		(*DataRequest_Append)(nil),
+		(*DataRequest_Rejection)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDesc,
+			NumEnums:      5,
+			NumMessages:   42,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_github_com_rkcloudchain_rksync_protos_rksync_proto_goTypes,
+		DependencyIndexes: file_github_com_rkcloudchain_rksync_protos_rksync_proto_depIdxs,
+		EnumInfos:         file_github_com_rkcloudchain_rksync_protos_rksync_proto_enumTypes,
+		MessageInfos:      file_github_com_rkcloudchain_rksync_protos_rksync_proto_msgTypes,
+	}.Build()
+	File_github_com_rkcloudchain_rksync_protos_rksync_proto = out.File
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_rawDesc = nil
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_goTypes = nil
+	file_github_com_rkcloudchain_rksync_protos_rksync_proto_depIdxs = nil
+}
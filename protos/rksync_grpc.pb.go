@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-grpc. DO NOT EDIT.
+// source: github.com/rkcloudchain/rksync/protos/rksync.proto
+
+package protos
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RKSyncClient is the client API for RKSync service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RKSyncClient interface {
+	// SyncStream is the gRPC stream used for sending and receiving messages
+	SyncStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_SyncStreamClient, error)
+	// DataStream is the gRPC stream used for sending and receiving file data,
+	// keeping the SyncStream free for membership and state messages
+	DataStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_DataStreamClient, error)
+	// Ping is used to probe a remote peer's aliveness
+	Ping(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type rKSyncClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRKSyncClient(cc *grpc.ClientConn) RKSyncClient {
+	return &rKSyncClient{cc}
+}
+
+func (c *rKSyncClient) SyncStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_SyncStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RKSync_serviceDesc.Streams[0], "/protos.RKSync/SyncStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rKSyncSyncStreamClient{stream}
+	return x, nil
+}
+
+type RKSync_SyncStreamClient interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ClientStream
+}
+
+type rKSyncSyncStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *rKSyncSyncStreamClient) Send(m *Envelope) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rKSyncSyncStreamClient) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rKSyncClient) DataStream(ctx context.Context, opts ...grpc.CallOption) (RKSync_DataStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RKSync_serviceDesc.Streams[1], "/protos.RKSync/DataStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rKSyncDataStreamClient{stream}
+	return x, nil
+}
+
+type RKSync_DataStreamClient interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ClientStream
+}
+
+type rKSyncDataStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *rKSyncDataStreamClient) Send(m *Envelope) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rKSyncDataStreamClient) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rKSyncClient) Ping(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.RKSync/Ping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RKSyncServer is the server API for RKSync service.
+type RKSyncServer interface {
+	// SyncStream is the gRPC stream used for sending and receiving messages
+	SyncStream(RKSync_SyncStreamServer) error
+	// DataStream is the gRPC stream used for sending and receiving file data,
+	// keeping the SyncStream free for membership and state messages
+	DataStream(RKSync_DataStreamServer) error
+	// Ping is used to probe a remote peer's aliveness
+	Ping(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+}
+
+func RegisterRKSyncServer(s *grpc.Server, srv RKSyncServer) {
+	s.RegisterService(&_RKSync_serviceDesc, srv)
+}
+
+func _RKSync_SyncStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RKSyncServer).SyncStream(&rKSyncSyncStreamServer{stream})
+}
+
+type RKSync_SyncStreamServer interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ServerStream
+}
+
+type rKSyncSyncStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *rKSyncSyncStreamServer) Send(m *Envelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rKSyncSyncStreamServer) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RKSync_DataStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RKSyncServer).DataStream(&rKSyncDataStreamServer{stream})
+}
+
+type RKSync_DataStreamServer interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ServerStream
+}
+
+type rKSyncDataStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *rKSyncDataStreamServer) Send(m *Envelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rKSyncDataStreamServer) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RKSync_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RKSyncServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.RKSync/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RKSyncServer).Ping(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _RKSync_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.RKSync",
+	HandlerType: (*RKSyncServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler:    _RKSync_Ping_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SyncStream",
+			Handler:       _RKSync_SyncStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DataStream",
+			Handler:       _RKSync_DataStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "github.com/rkcloudchain/rksync/protos/rksync.proto",
+}
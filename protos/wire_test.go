@@ -0,0 +1,215 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package protos
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// wireMessages are the messages whose encoding by the gogo-generated marshalers
+// the protos were generated with before is recorded in testdata/wire.golden
+func wireMessages() map[string]proto.Message {
+	return map[string]proto.Message{
+		"envelope": &Envelope{Payload: []byte("payload"), Signature: []byte("signature"), SignatureAlgorithm: SignatureAlgorithm_ED25519,
+			SecretEnvelope: &SecretEnvelope{Payload: []byte("secret"), Signature: []byte("secret signature")}, HopsLeft: 3},
+		"chain_state": &RKSyncMessage{Nonce: 7, ChainMac: []byte{0x12, 0x34}, Tag: RKSyncMessage_CHAN_ONLY, Timestamp: 1571277210000000000,
+			Content: &RKSyncMessage_State{State: &ChainState{SeqNum: 5, ChainId: "testchannel",
+				Envelope:  &Envelope{Payload: []byte{1, 2, 3}, Signature: []byte{4, 5, 6}},
+				Change:    &Envelope{Payload: []byte{7}, Signature: []byte{8}},
+				Heartbeat: &PeerTime{IncNum: 1571277210, SeqNum: 42}}}},
+		"alive": &RKSyncMessage{Nonce: 1, Content: &RKSyncMessage_AliveMsg{AliveMsg: &AliveMessage{
+			Membership: &Member{Endpoint: "peer0:7051", PkiId: []byte{0xab, 0xcd}, Tags: []string{"edge", "ssd"}, Zone: "eu-west", Org: "org1", Labels: map[string]string{"role": "ingest"}},
+			Timestamp:  &PeerTime{IncNum: 1, SeqNum: 2}, Identity: []byte("identity"), Leaving: true}}},
+		"empty": &RKSyncMessage{Nonce: 2, Content: &RKSyncMessage_Empty{Empty: &emptypb.Empty{}}},
+		"data": &RKSyncMessage{Tag: RKSyncMessage_CHAN_ONLY, Content: &RKSyncMessage_DataMsg{DataMsg: &DataMessage{FileName: "config.yaml",
+			Payload: &Payload{Data: []byte("data"), Metadata: &Payload_Append{Append: &AppendMetadata{Start: 1024, Length: 4, Truncated: true, Digest: []byte{9}, ModTime: -1, FileSize: 1028}}}}}},
+		"data_req": &RKSyncMessage{Content: &RKSyncMessage_DataReq{DataReq: &DataRequest{FileName: "config.yaml", PkiId: []byte{1},
+			Req: &DataRequest_Rejection{Rejection: &TransferRejection{Limit: TransferRejection_Disk, Required: 100, Available: 10}}}}},
+		"file_ack":      &RKSyncMessage{Content: &RKSyncMessage_FileAck{FileAck: &FileAck{FileName: "config.yaml", Size: 1028, Digest: []byte{1, 2}, Version: 3}}},
+		"mem_res":       &RKSyncMessage{Content: &RKSyncMessage_MemRes{MemRes: &MembershipResponse{Alive: []*Envelope{{Payload: []byte{1}}}, Dead: []*Envelope{{Signature: []byte{2}}}, Wanted: [][]byte{{3}, {4}}}}},
+		"change_record": &ChangeRecord{Index: 4, PrevHash: []byte{1}, Op: ChangeRecord_REMOVE_FILE, Members: [][]byte{{2}}, Files: []string{"a", "b"}, SeqNum: 9, Timestamp: 10},
+		"chain_state_info": &ChainStateInfo{Leader: []byte{1}, AnchorPeers: []string{"peer0:7051"}, Epoch: 3, Properties: &Properties{Members: [][]byte{{1}, {2}},
+			Files: []*File{{Path: "config.yaml", Mode: File_Random, Metadata: []byte{1}, Version: 2, ContentHash: []byte{3}, Perm: 0644, Attributes: []*FileAttribute{{Key: "k", Value: "v"}}, Priority: -2}}}},
+		"identity": &SerializedIdentity{NodeId: "peer0", IdBytes: []byte("cert"), IntermediateCerts: [][]byte{[]byte("ca")}, Org: "org1"},
+		"connection_stat": &ConnectionStat{PkiId: "abcd", Endpoint: "peer0:7051", QueuedControl: 1, QueuedData: 2, ProtocolVersion: 3, BytesIn: 4, BytesOut: 5,
+			MessagesIn: map[string]uint64{"alive_msg": 6}, MessagesOut: map[string]uint64{"data_msg": 7}, LastActivity: 8, RttNanos: 9,
+			Tls: &TLSSession{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256", ServerName: "peer0", NegotiatedProtocol: "h2", PeerCertificate: "CN=peer0"}},
+		"zero": &RKSyncMessage{},
+	}
+}
+
+func readGolden(t *testing.T) map[string][]byte {
+	f, err := os.Open("testdata/wire.golden")
+	require.NoError(t, err)
+	defer f.Close()
+
+	golden := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		name, encoded := fields[0], ""
+		if len(fields) > 1 {
+			encoded = fields[1]
+		}
+		b, err := hex.DecodeString(encoded)
+		require.NoError(t, err)
+		golden[name] = b
+	}
+	require.NoError(t, scanner.Err())
+	return golden
+}
+
+// TestWireCompatibility checks that the messages are encoded the same as by the gogo-generated
+// marshalers of the previous releases, so that the peers of both releases understand each other
+func TestWireCompatibility(t *testing.T) {
+	golden := readGolden(t)
+	msgs := wireMessages()
+	require.Len(t, golden, len(msgs))
+
+	for name, msg := range msgs {
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(golden[name]), hex.EncodeToString(b), name)
+
+		parsed := msg.ProtoReflect().New().Interface()
+		require.NoError(t, proto.Unmarshal(golden[name], parsed))
+		assert.True(t, proto.Equal(msg, parsed), name)
+	}
+}
+
+// TestFastPath checks that the envelopes and the chain states encoded by hand
+// are encoded the same as by the generated code, and parsed back
+func TestFastPath(t *testing.T) {
+	golden := readGolden(t)
+	msgs := wireMessages()
+
+	envelope := msgs["envelope"].(*Envelope)
+	b, err := envelope.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, golden["envelope"], b)
+	parsed := &Envelope{}
+	require.True(t, parsed.parse(b))
+	assert.True(t, proto.Equal(envelope, parsed))
+
+	for _, msg := range []*RKSyncMessage{msgs["chain_state"].(*RKSyncMessage), chainStateMessage(t)} {
+		b, err := marshalMessage(msg)
+		require.NoError(t, err)
+		expected, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		require.NoError(t, err)
+		assert.Equal(t, expected, b)
+		parsedMsg := &RKSyncMessage{}
+		require.True(t, parseMessage(b, parsedMsg))
+		assert.True(t, proto.Equal(msg, parsedMsg))
+	}
+
+	// The other messages, and the ones carrying unknown fields, are left to the generated code
+	alive := msgs["alive"].(*RKSyncMessage)
+	b, err = marshalMessage(alive)
+	require.NoError(t, err)
+	assert.Equal(t, golden["alive"], b)
+	parsedMsg := &RKSyncMessage{}
+	assert.False(t, parseMessage(b, &RKSyncMessage{}))
+	require.NoError(t, unmarshalMessage(b, parsedMsg))
+	assert.True(t, proto.Equal(alive, parsedMsg))
+
+	unknown := protowire.AppendVarint(protowire.AppendTag(append([]byte{}, golden["envelope"]...), 99, protowire.VarintType), 1)
+	parsed = &Envelope{}
+	require.NoError(t, parsed.Unmarshal(unknown))
+	assert.Equal(t, envelope.Payload, parsed.Payload)
+	b, err = parsed.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, unknown, b)
+}
+
+// The envelopes and the gossip messages they carry are marshaled and unmarshaled
+// for every message sent and received, the file data being the largest of them.
+// The fast path is the encoding by hand the peers use, the generated code is measured for comparison.
+func BenchmarkMarshal(b *testing.B) {
+	for name, msg := range benchmarkMessages(b) {
+		b.Run("generated/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := proto.Marshal(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("fast/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				switch m := msg.(type) {
+				case *RKSyncMessage:
+					_, err = marshalMessage(m)
+				case *Envelope:
+					_, err = m.Marshal()
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for name, msg := range benchmarkMessages(b) {
+		data, err := proto.Marshal(msg)
+		require.NoError(b, err)
+		b.Run("generated/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := proto.Unmarshal(data, msg.ProtoReflect().New().Interface()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("fast/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var err error
+				switch msg.(type) {
+				case *RKSyncMessage:
+					err = unmarshalMessage(data, &RKSyncMessage{})
+				case *Envelope:
+					err = (&Envelope{}).Unmarshal(data)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkMessages(b *testing.B) map[string]proto.Message {
+	signed, err := chainStateMessage(b).NoopSign()
+	require.NoError(b, err)
+	data, err := (&RKSyncMessage{Tag: RKSyncMessage_CHAN_ONLY, Content: &RKSyncMessage_DataMsg{DataMsg: &DataMessage{
+		FileName: "config.yaml",
+		Payload:  &Payload{Data: make([]byte, 64<<10), Metadata: &Payload_Append{Append: &AppendMetadata{Start: 1 << 20, Length: 64 << 10, FileSize: 1<<20 + 64<<10}}},
+	}}}).NoopSign()
+	require.NoError(b, err)
+
+	return map[string]proto.Message{
+		"chain_state":          chainStateMessage(b),
+		"chain_state_envelope": signed.Envelope,
+		"data_envelope":        data.Envelope,
+	}
+}
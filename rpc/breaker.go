@@ -0,0 +1,165 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+)
+
+const defBreakerTimeout = 30 * time.Second
+
+// BreakerConfig determines when the circuit of a failing peer is opened
+type BreakerConfig struct {
+	Threshold int           // Number of send or probe failures within Timeout opening the circuit, 0 disables the breaker
+	Timeout   time.Duration // Time the circuit stays open before a single attempt is let through, defaults to 30s
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	state    circuitState
+	failures []time.Time
+	openedAt time.Time
+}
+
+// circuitBreaker tracks the failures of the peers. Once the circuit of a peer is open,
+// the messages sent to it are discarded until the timeout expires; then a single
+// attempt is let through, closing the circuit if it succeeds.
+type circuitBreaker struct {
+	conf     BreakerConfig
+	lock     sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+func newCircuitBreaker(conf BreakerConfig) *circuitBreaker {
+	if conf.Timeout <= 0 {
+		conf.Timeout = defBreakerTimeout
+	}
+	return &circuitBreaker{
+		conf:     conf,
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+}
+
+func (cb *circuitBreaker) enabled() bool {
+	return cb.conf.Threshold > 0
+}
+
+// isOpen checks whether the peer mustn't be selected, the circuit
+// of a peer due for an attempt is reported as closed
+func (cb *circuitBreaker) isOpen(pkiID common.PKIidType) bool {
+	if !cb.enabled() {
+		return false
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	c, exists := cb.circuits[pkiID.String()]
+	if !exists {
+		return false
+	}
+	return c.state == circuitHalfOpen || (c.state == circuitOpen && cb.now().Before(c.openedAt.Add(cb.conf.Timeout)))
+}
+
+// allow checks whether an attempt to reach the peer may be made,
+// only one attempt is let through once the circuit timeout expired
+func (cb *circuitBreaker) allow(pkiID common.PKIidType) bool {
+	if !cb.enabled() {
+		return true
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	c, exists := cb.circuits[pkiID.String()]
+	if !exists {
+		return true
+	}
+
+	switch c.state {
+	case circuitOpen:
+		if cb.now().Before(c.openedAt.Add(cb.conf.Timeout)) {
+			return false
+		}
+		c.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// failure records a failed attempt and returns true if it opened the circuit
+func (cb *circuitBreaker) failure(pkiID common.PKIidType) bool {
+	if !cb.enabled() || len(pkiID) == 0 {
+		return false
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	c, exists := cb.circuits[pkiID.String()]
+	if !exists {
+		c = &circuit{}
+		cb.circuits[pkiID.String()] = c
+	}
+
+	now := cb.now()
+	switch c.state {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		c.state = circuitOpen
+		c.openedAt = now
+		return true
+	}
+
+	failures := c.failures[:0]
+	for _, t := range c.failures {
+		if now.Sub(t) < cb.conf.Timeout {
+			failures = append(failures, t)
+		}
+	}
+	c.failures = append(failures, now)
+	if len(c.failures) < cb.conf.Threshold {
+		return false
+	}
+
+	c.state = circuitOpen
+	c.openedAt = now
+	c.failures = nil
+	return true
+}
+
+// success records a successful attempt, closing the circuit of the peer
+func (cb *circuitBreaker) success(pkiID common.PKIidType) {
+	if !cb.enabled() {
+		return
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	c, exists := cb.circuits[pkiID.String()]
+	if !exists {
+		return
+	}
+	// the failures of a closed circuit are kept until they expire, so that a flapping peer opens it
+	if c.state == circuitClosed && len(c.failures) > 0 && cb.now().Sub(c.failures[len(c.failures)-1]) < cb.conf.Timeout {
+		return
+	}
+	delete(cb.circuits, pkiID.String())
+}
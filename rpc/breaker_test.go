@@ -0,0 +1,83 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker(BreakerConfig{Threshold: 3, Timeout: time.Minute})
+	cb.now = func() time.Time { return now }
+	p := common.PKIidType("p")
+
+	assert.False(t, cb.failure(p))
+	assert.False(t, cb.failure(p))
+	// successes don't reset the failures of a flapping peer
+	cb.success(p)
+	assert.True(t, cb.allow(p))
+	assert.True(t, cb.failure(p))
+	assert.True(t, cb.isOpen(p))
+	assert.False(t, cb.allow(p))
+
+	// a single attempt is let through once the timeout expired
+	now = now.Add(time.Minute)
+	assert.False(t, cb.isOpen(p))
+	assert.True(t, cb.allow(p))
+	assert.False(t, cb.allow(p))
+	assert.True(t, cb.isOpen(p))
+	assert.True(t, cb.failure(p))
+	assert.False(t, cb.allow(p))
+
+	now = now.Add(time.Minute)
+	assert.True(t, cb.allow(p))
+	cb.success(p)
+	assert.False(t, cb.isOpen(p))
+	assert.True(t, cb.allow(p))
+	assert.Empty(t, cb.circuits)
+
+	// failures older than the timeout are forgotten
+	assert.False(t, cb.failure(p))
+	assert.False(t, cb.failure(p))
+	now = now.Add(time.Minute)
+	assert.False(t, cb.failure(p))
+	assert.False(t, cb.isOpen(p))
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cb := newCircuitBreaker(BreakerConfig{})
+	p := common.PKIidType("p")
+	for i := 0; i < 10; i++ {
+		assert.False(t, cb.failure(p))
+	}
+	assert.True(t, cb.allow(p))
+	assert.False(t, cb.isOpen(p))
+}
+
+func TestProbeOpensCircuit(t *testing.T) {
+	inst, err := CreateRPCServer("localhost:6060", 0)
+	require.NoError(t, err)
+	defer inst.Stop()
+	inst.breaker = newCircuitBreaker(BreakerConfig{Threshold: 2, Timeout: time.Minute})
+
+	dead := &common.NetworkMember{Endpoint: "localhost:6061", PKIID: common.PKIidType("dead")}
+	assert.Error(t, inst.Probe(dead))
+	assert.False(t, inst.CircuitOpen(dead.PKIID))
+	assert.Error(t, inst.Probe(dead))
+	assert.True(t, inst.CircuitOpen(dead.PKIID))
+
+	start := time.Now()
+	err = inst.Probe(dead)
+	assert.Contains(t, err.Error(), "Circuit of localhost:6061 is open")
+	assert.True(t, time.Since(start) < defDialTimeout)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/common"
+)
+
+// errSendBufferOverflow is reported to the senders of the messages dropped by the send buffer
+var errSendBufferOverflow = errors.New("Send buffer overflowed")
+
+// SendBufferConfig determines how the messages sent to a peer are buffered
+type SendBufferConfig struct {
+	Size           int                   // Max number of buffered messages of each priority
+	ControlPolicy  common.OverflowPolicy // Applied to control plane messages when the buffer is full
+	DataPolicy     common.OverflowPolicy // Applied to file data messages when the buffer is full
+	MaxMessageSize int                   // Larger messages are rejected instead of being buffered, 0 disables the check
+	BlockTimeout   time.Duration         // Max time a message waits for room in the buffer under the Block policy, defaults to 10s
+}
+
+func (c SendBufferConfig) withDefaults() SendBufferConfig {
+	if c.Size <= 0 {
+		c.Size = defSendBuffSize
+	}
+	if c.ControlPolicy == 0 {
+		c.ControlPolicy = common.DropOldest
+	}
+	if c.DataPolicy == 0 {
+		c.DataPolicy = common.Block
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = defBlockTimeout
+	}
+	return c
+}
+
+// DropCounts holds the number of messages dropped because
+// the send buffer of a peer, or a subscriber of the received messages, was full
+type DropCounts struct {
+	Control  uint64
+	Data     uint64
+	Received uint64 // Received messages dropped because a subscriber didn't keep up
+}
+
+// ConnectionStats describes a connection established with a remote peer
+type ConnectionStats struct {
+	PKIID           common.PKIidType
+	Endpoint        string
+	QueuedControl   int               // Number of control plane messages waiting to be sent
+	QueuedData      int               // Number of file data messages waiting to be sent
+	ProtocolVersion uint32            // Version of the protocol agreed on with the peer
+	BytesIn         uint64            // Size of the envelopes received through the connection
+	BytesOut        uint64            // Size of the envelopes sent through the connection
+	MessagesIn      map[string]uint64 // Number of messages received by content type, e.g. "alive_msg"
+	MessagesOut     map[string]uint64 // Number of messages sent by content type
+	LastActivity    time.Time         // Last time a message was sent or received
+	RTT             time.Duration     // Average round-trip time measured to the peer, 0 if it wasn't measured yet
+	TLS             *TLSInfo          // TLS session negotiated with the peer, nil if the connection isn't secured by TLS
+}
+
+type sendBuffer struct {
+	msgs         chan *msgSending
+	waiters      chan struct{} // Slots of the messages waiting for room under the Block policy
+	blockTimeout time.Duration
+	policy       common.OverflowPolicy
+	dropped      *uint64
+}
+
+func newSendBuffer(size int, policy common.OverflowPolicy, blockTimeout time.Duration, dropped *uint64) *sendBuffer {
+	return &sendBuffer{
+		msgs:         make(chan *msgSending, size),
+		waiters:      make(chan struct{}, size),
+		blockTimeout: blockTimeout,
+		policy:       policy,
+		dropped:      dropped,
+	}
+}
+
+// put buffers the message according to the given overflow policy,
+// it returns false if the message was dropped
+func (b *sendBuffer) put(m *msgSending, policy common.OverflowPolicy, done <-chan struct{}) bool {
+	switch policy {
+	case common.Block:
+		select {
+		case b.msgs <- m:
+			return true
+		default:
+		}
+
+		// As many messages as the buffer holds may wait for room, each of them
+		// for BlockTimeout at most, so that a stalled peer doesn't pile up the
+		// goroutines of the senders. The others are dropped.
+		select {
+		case b.waiters <- struct{}{}:
+			defer func() { <-b.waiters }()
+		default:
+			b.drop(m)
+			return false
+		}
+
+		timer := time.NewTimer(b.blockTimeout)
+		defer timer.Stop()
+		select {
+		case b.msgs <- m:
+			return true
+		case <-done:
+			return false
+		case <-timer.C:
+			b.drop(m)
+			return false
+		}
+	case common.DropOldest:
+		for {
+			select {
+			case b.msgs <- m:
+				return true
+			default:
+			}
+
+			select {
+			case oldest := <-b.msgs:
+				b.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case b.msgs <- m:
+			return true
+		default:
+			b.drop(m)
+			return false
+		}
+	}
+}
+
+// wait buffers the message once there is room in the buffer, however long it takes.
+// It returns false if the connection was closed meanwhile.
+func (b *sendBuffer) wait(m *msgSending, done <-chan struct{}) bool {
+	select {
+	case b.msgs <- m:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// drop counts the dropped message and reports the overflow to its sender
+func (b *sendBuffer) drop(m *msgSending) {
+	atomic.AddUint64(b.dropped, 1)
+	if m.onErr != nil {
+		go m.onErr(errSendBufferOverflow)
+	}
+}
+
+func (b *sendBuffer) drain() {
+	for {
+		select {
+		case <-b.msgs:
+		default:
+			return
+		}
+	}
+}
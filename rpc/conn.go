@@ -7,15 +7,18 @@ SPDX-License-Identifier: Apache-2.0
 package rpc
 
 import (
+	"bytes"
 	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 type handler func(message *protos.SignedRKSyncMessage)
@@ -23,18 +26,27 @@ type connCreation func(endpoint string, pkiID common.PKIidType) (*connection, er
 
 type connectionStore struct {
 	isClosing    bool
+	selfID       common.PKIidType // Breaks the ties between the connections two peers dial at the same time
 	connCreation connCreation
+	bufConf      SendBufferConfig
+	poolConf     ConnPoolConfig
+	dropped      *DropCounts
 	sync.RWMutex
 	conns            map[string]*connection
 	destinationLocks map[string]*sync.Mutex
+	logger           logging.Logger
 }
 
-func newConnStore(connCreation connCreation) *connectionStore {
+func newConnStore(connCreation connCreation, bufConf SendBufferConfig, poolConf ConnPoolConfig, dropped *DropCounts, logger logging.Logger) *connectionStore {
 	return &connectionStore{
 		connCreation:     connCreation,
+		bufConf:          bufConf,
+		poolConf:         poolConf,
+		dropped:          dropped,
 		isClosing:        false,
 		conns:            make(map[string]*connection),
 		destinationLocks: make(map[string]*sync.Mutex),
+		logger:           logger,
 	}
 }
 
@@ -48,7 +60,7 @@ func (cs *connectionStore) getConnection(peer *common.NetworkMember) (*connectio
 	}
 
 	pkiID := peer.PKIID
-	endpoint := peer.Endpoint
+	endpoint := peer.PreferredEndpoint()
 
 	cs.Lock()
 	destinationLock, hasConnected := cs.destinationLocks[pkiID.String()]
@@ -84,12 +96,16 @@ func (cs *connectionStore) getConnection(peer *common.NetworkMember) (*connectio
 	defer cs.Unlock()
 
 	conn, exists = cs.conns[pkiID.String()]
-	if exists {
+	if exists && !(createdConnection != nil && conn.clientStream == nil && cs.keepsOwnConn(pkiID)) {
 		if createdConnection != nil {
 			createdConnection.close()
 		}
 		return conn, nil
 	}
+	if exists {
+		// The peer dialed us meanwhile, the connection we dialed wins
+		conn.close()
+	}
 
 	if err != nil {
 		return nil, err
@@ -102,12 +118,65 @@ func (cs *connectionStore) getConnection(peer *common.NetworkMember) (*connectio
 	return conn, nil
 }
 
+// waitForConn returns the connection with the given peer, waiting
+// for its gossip stream to be registered if needed
+func (cs *connectionStore) waitForConn(pkiID common.PKIidType, timeout time.Duration) (*connection, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		cs.RLock()
+		conn, exists := cs.conns[pkiID.String()]
+		isClosing := cs.isClosing
+		cs.RUnlock()
+
+		if isClosing {
+			return nil, errors.Errorf("ConnStore is closing")
+		}
+		if exists {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("No connection with %s", pkiID)
+		}
+		time.Sleep(connPollInterval)
+	}
+}
+
+// isCurrent returns whether the connection is open and is the connection of its peer
+func (cs *connectionStore) isCurrent(conn *connection) bool {
+	cs.RLock()
+	defer cs.RUnlock()
+	return !conn.toDie() && cs.conns[conn.info.ID.String()] == conn
+}
+
 func (cs *connectionStore) connNum() int {
 	cs.RLock()
 	defer cs.RUnlock()
 	return len(cs.conns)
 }
 
+func (cs *connectionStore) stats() []ConnectionStats {
+	cs.RLock()
+	defer cs.RUnlock()
+
+	stats := make([]ConnectionStats, 0, len(cs.conns))
+	for _, conn := range cs.conns {
+		stat := ConnectionStats{
+			PKIID:           conn.info.ID,
+			Endpoint:        conn.info.Endpoint,
+			QueuedControl:   len(conn.outBuff.msgs),
+			QueuedData:      len(conn.dataBuff.msgs),
+			ProtocolVersion: conn.info.ProtocolVersion,
+			LastActivity:    conn.idleSince(),
+		}
+		conn.traffic.fill(&stat)
+		if s := conn.getStream(); s != nil {
+			stat.TLS = tlsInfo(s)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
 func (cs *connectionStore) closeConn(peer *common.NetworkMember) {
 	cs.Lock()
 	defer cs.Unlock()
@@ -119,8 +188,8 @@ func (cs *connectionStore) closeConn(peer *common.NetworkMember) {
 }
 
 func (cs *connectionStore) shutdown() {
-	logging.Info("Closing rpc connection store")
-	defer logging.Info("Closed rpc connection store")
+	cs.logger.Info("Closing rpc connection store")
+	defer cs.logger.Info("Closed rpc connection store")
 
 	cs.Lock()
 	cs.isClosing = true
@@ -148,14 +217,24 @@ func (cs *connectionStore) onConnected(serverStream protos.RKSync_SyncStreamServ
 	defer cs.Unlock()
 
 	if c, exists := cs.conns[connInfo.ID.String()]; exists {
+		// When both peers dialed each other at the same time, both must keep the same connection
+		if c.clientStream != nil && time.Since(c.created) < defConnTimeout && cs.keepsOwnConn(connInfo.ID) {
+			return nil
+		}
 		c.close()
 	}
 
 	return cs.registerConn(connInfo, serverStream)
 }
 
+// keepsOwnConn returns whether the connection this peer dials wins over
+// the one the given peer dials, the peer with the lowest PKI-ID wins
+func (cs *connectionStore) keepsOwnConn(pkiID common.PKIidType) bool {
+	return bytes.Compare(cs.selfID, pkiID) < 0
+}
+
 func (cs *connectionStore) registerConn(connInfo *protos.ConnectionInfo, serverStream protos.RKSync_SyncStreamServer) *connection {
-	conn := newConnection(nil, nil, serverStream)
+	conn := newConnection(nil, nil, serverStream, cs.bufConf, cs.dropped, cs.logger)
 	conn.info = connInfo
 	cs.conns[connInfo.ID.String()] = conn
 	return conn
@@ -170,29 +249,57 @@ func (cs *connectionStore) closeByPKIid(pkiID common.PKIidType) {
 	}
 }
 
-func newConnection(c *grpc.ClientConn, cs protos.RKSync_SyncStreamClient, ss protos.RKSync_SyncStreamServer) *connection {
+// closeConnection closes the connection, and forgets it unless another connection
+// with the same peer replaced it meanwhile
+func (cs *connectionStore) closeConnection(conn *connection) {
+	cs.Lock()
+	defer cs.Unlock()
+	conn.close()
+	if cs.conns[conn.info.ID.String()] == conn {
+		delete(cs.conns, conn.info.ID.String())
+	}
+}
+
+func newConnection(c *grpc.ClientConn, cs protos.RKSync_SyncStreamClient, ss protos.RKSync_SyncStreamServer,
+	bufConf SendBufferConfig, dropped *DropCounts, logger logging.Logger) *connection {
 	connection := &connection{
-		outBuff:      make(chan *msgSending, defSendBuffSize),
-		conn:         c,
-		clientStream: cs,
-		serverStream: ss,
-		stopFlat:     int32(0),
-		stopChan:     make(chan struct{}, 1),
+		outBuff:           newSendBuffer(bufConf.Size, bufConf.ControlPolicy, bufConf.BlockTimeout, &dropped.Control),
+		dataBuff:          newSendBuffer(bufConf.Size, bufConf.DataPolicy, bufConf.BlockTimeout, &dropped.Data),
+		done:              make(chan struct{}),
+		conn:              c,
+		clientStream:      cs,
+		serverStream:      ss,
+		stopFlat:          int32(0),
+		stopChan:          make(chan struct{}, 1),
+		dataStreamChanged: make(chan struct{}, 1),
+		traffic:           newTrafficStats(),
+		created:           time.Now(),
+		logger:            logger,
 	}
+	connection.touch()
 	return connection
 }
 
 type connection struct {
-	cancel       context.CancelFunc
-	outBuff      chan *msgSending
-	info         *protos.ConnectionInfo
-	handler      handler
-	conn         *grpc.ClientConn
-	clientStream protos.RKSync_SyncStreamClient
-	serverStream protos.RKSync_SyncStreamServer
-	stopFlat     int32
-	stopChan     chan struct{}
-	stopWG       sync.WaitGroup
+	lastActivity      int64 // accessed atomically, must stay 64-bit aligned
+	created           time.Time
+	cancel            context.CancelFunc
+	outBuff           *sendBuffer
+	dataBuff          *sendBuffer
+	done              chan struct{}
+	info              *protos.ConnectionInfo
+	handler           handler
+	conn              *grpc.ClientConn
+	clientStream      protos.RKSync_SyncStreamClient
+	serverStream      protos.RKSync_SyncStreamServer
+	dataClientStream  protos.RKSync_DataStreamClient // Opened along with the client stream, nil if the remote peer doesn't support it
+	dataStream        stream                         // Carries the file data messages when set
+	dataStreamChanged chan struct{}
+	stopFlat          int32
+	stopChan          chan struct{}
+	stopWG            sync.WaitGroup
+	traffic           *trafficStats
+	logger            logging.Logger
 	sync.RWMutex
 }
 
@@ -206,6 +313,7 @@ func (conn *connection) close() {
 		return
 	}
 
+	close(conn.done)
 	conn.stopChan <- struct{}{}
 	conn.drainOutputBuffer()
 	conn.Lock()
@@ -215,6 +323,9 @@ func (conn *connection) close() {
 		conn.stopWG.Wait()
 		conn.clientStream.CloseSend()
 	}
+	if conn.dataClientStream != nil {
+		conn.dataClientStream.CloseSend()
+	}
 	if conn.conn != nil {
 		conn.conn.Close()
 	}
@@ -229,44 +340,64 @@ func (conn *connection) toDie() bool {
 }
 
 func (conn *connection) send(msg *protos.SignedRKSyncMessage, onErr func(error), shouldBlock bool) {
+	conn.sendMarshaled(msg, nil, onErr, shouldBlock)
+}
+
+// sendMarshaled sends the message, whose envelope was marshaled once for all the peers unless it's nil
+func (conn *connection) sendMarshaled(msg *protos.SignedRKSyncMessage, marshaled *protos.MarshaledEnvelope, onErr func(error), shouldBlock bool) {
 	if conn.toDie() {
-		logging.Debug("Aborting send() to ", conn.info.Endpoint, " because connection is closing")
+		conn.logger.Debug("Aborting send() to ", conn.info.Endpoint, " because connection is closing")
 		return
 	}
 
+	conn.touch()
 	m := &msgSending{
-		envelope: msg.Envelope,
-		onErr:    onErr,
+		envelope:    msg.Envelope,
+		marshaled:   marshaled,
+		contentType: msg.ContentType(),
+		onErr:       onErr,
 	}
 
-	if len(conn.outBuff) == cap(conn.outBuff) {
-		logging.Debug("Buffer to ", conn.info.Endpoint, " overflowed, dropping message", msg.String())
-		if !shouldBlock {
-			return
-		}
+	buff := conn.outBuff
+	if msg.Priority() == common.LowPriority {
+		buff = conn.dataBuff
 	}
 
-	conn.outBuff <- m
+	// The messages which must be delivered wait for room in the buffer until the connection is closed
+	if shouldBlock {
+		buff.wait(m, conn.done)
+		return
+	}
+
+	if len(buff.msgs) == cap(buff.msgs) {
+		conn.logger.Debug("Buffer to ", conn.info.Endpoint, " overflowed, applying overflow policy", buff.policy, "to message", msg.String())
+	}
+	buff.put(m, buff.policy, conn.done)
 }
 
 func (conn *connection) serviceConnection() error {
 	errChan := make(chan error, 1)
 	msgChan := make(chan *protos.SignedRKSyncMessage, defRecvBuffSize)
 
-	go conn.readFromStream(errChan, msgChan)
+	go conn.readFromStream(conn.getStream, errChan, msgChan)
 
 	conn.stopWG.Add(1)
 	go conn.writeToStream()
 
+	if conn.dataClientStream != nil {
+		go conn.serviceDataStream(conn.dataClientStream)
+	}
+
 	for !conn.toDie() {
 		select {
 		case stop := <-conn.stopChan:
-			logging.Debug("Closing reading from stream")
+			conn.logger.Debug("Closing reading from stream")
 			conn.stopChan <- stop
 			return nil
 		case err := <-errChan:
 			return err
 		case msg := <-msgChan:
+			conn.touch()
 			conn.handler(msg)
 		}
 	}
@@ -278,58 +409,176 @@ func (conn *connection) writeToStream() {
 	for !conn.toDie() {
 		stream := conn.getStream()
 		if stream == nil {
-			logging.Error(conn.info.ID, "Stream is nil, aborting!")
+			conn.logger.Error(conn.info.ID, "Stream is nil, aborting!")
 			return
 		}
 
+		// File data is sent through the data stream when there is one
+		dataMsgs := conn.dataBuff.msgs
+		if conn.getDataStream() != nil {
+			dataMsgs = nil
+		}
+
+		// Messages of high priority preempt the pending data messages
 		select {
-		case m := <-conn.outBuff:
-			err := stream.Send(m.envelope)
+		case m := <-conn.outBuff.msgs:
+			if !conn.sendToStream(stream, m) {
+				return
+			}
+			continue
+		default:
+		}
 
-			if err != nil {
-				go m.onErr(err)
+		select {
+		case m := <-conn.outBuff.msgs:
+			if !conn.sendToStream(stream, m) {
+				return
+			}
+		case m := <-dataMsgs:
+			if !conn.sendToStream(stream, m) {
 				return
 			}
+		case <-conn.dataStreamChanged:
 		case s := <-conn.stopChan:
-			logging.Debug("Closing writing to stream")
+			conn.logger.Debug("Closing writing to stream")
 			conn.stopChan <- s
 			return
 		}
 	}
 }
 
-func (conn *connection) drainOutputBuffer() {
-	for len(conn.outBuff) > 0 {
-		<-conn.outBuff
+// serviceDataStream sends the file data messages through the given stream and
+// handles the messages received from it, until the stream or the connection fails
+func (conn *connection) serviceDataStream(ds stream) error {
+	stop := make(chan struct{})
+	if !conn.attachDataStream(ds, stop) {
+		return errors.New("Connection is closing")
+	}
+	defer conn.detachDataStream(ds)
+	defer close(stop)
+
+	errChan := make(chan error, 1)
+	msgChan := make(chan *protos.SignedRKSyncMessage, defRecvBuffSize)
+	go conn.readFromStream(func() stream { return ds }, errChan, msgChan)
+
+	for {
+		select {
+		case <-conn.done:
+			return nil
+		case err := <-errChan:
+			conn.logger.Debugf("Data stream with %s failed: %v", conn.info.Endpoint, err)
+			return err
+		case msg := <-msgChan:
+			conn.touch()
+			conn.handler(msg)
+		}
+	}
+}
+
+func (conn *connection) attachDataStream(ds stream, stop <-chan struct{}) bool {
+	conn.Lock()
+	defer conn.Unlock()
+
+	// close() waits for the writers once it holds the lock
+	if conn.toDie() {
+		return false
+	}
+	conn.dataStream = ds
+	conn.stopWG.Add(1)
+	go conn.writeToDataStream(ds, stop)
+	conn.notifyDataStreamChanged()
+	return true
+}
+
+func (conn *connection) detachDataStream(ds stream) {
+	conn.Lock()
+	defer conn.Unlock()
+
+	if conn.dataStream == ds {
+		conn.dataStream = nil
+		conn.notifyDataStreamChanged()
 	}
 }
 
-func (conn *connection) readFromStream(errChan chan error, msgChan chan *protos.SignedRKSyncMessage) {
+func (conn *connection) notifyDataStreamChanged() {
+	select {
+	case conn.dataStreamChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (conn *connection) getDataStream() stream {
+	conn.RLock()
+	defer conn.RUnlock()
+	return conn.dataStream
+}
+
+func (conn *connection) writeToDataStream(ds stream, stop <-chan struct{}) {
+	defer conn.stopWG.Done()
 	for !conn.toDie() {
-		stream := conn.getStream()
+		select {
+		case m := <-conn.dataBuff.msgs:
+			if !conn.sendToStream(ds, m) {
+				return
+			}
+		case <-stop:
+			return
+		case <-conn.done:
+			return
+		}
+	}
+}
+
+func (conn *connection) sendToStream(s stream, m *msgSending) bool {
+	var err error
+	var size int
+	if m.marshaled != nil {
+		err = s.SendMsg(m.marshaled)
+		size = m.marshaled.Size()
+	} else {
+		err = s.Send(m.envelope)
+		size = proto.Size(m.envelope)
+	}
+	if err != nil {
+		go m.onErr(err)
+		return false
+	}
+	conn.traffic.sent(m.contentType, size)
+	return true
+}
+
+func (conn *connection) drainOutputBuffer() {
+	conn.outBuff.drain()
+	conn.dataBuff.drain()
+}
+
+func (conn *connection) readFromStream(getStream func() stream, errChan chan error, msgChan chan *protos.SignedRKSyncMessage) {
+	for !conn.toDie() {
+		stream := getStream()
 		if stream == nil {
-			logging.Error(conn.info.ID, "Stream is nil, aborting!")
+			conn.logger.Error(conn.info.ID, "Stream is nil, aborting!")
 			errChan <- errors.Errorf("Stream is nil")
 			return
 		}
 
 		envelope, err := stream.Recv()
 		if conn.toDie() {
-			logging.Debug(conn.info.ID, "canceling read because closing")
+			conn.logger.Debug(conn.info.ID, "canceling read because closing")
 			return
 		}
 		if err != nil {
 			errChan <- err
-			logging.Debugf("Got error, aborting: %v", err)
+			conn.logger.Debugf("Got error, aborting: %v", err)
 			return
 		}
 
 		msg, err := envelope.ToRKSyncMessage()
 		if err != nil {
 			errChan <- err
-			logging.Debugf("Go error, aborting: %v", err)
+			conn.logger.Debugf("Go error, aborting: %v", err)
 			return
 		}
+		conn.traffic.received(msg.ContentType(), proto.Size(envelope))
 
 		msgChan <- msg
 	}
@@ -344,7 +593,7 @@ func (conn *connection) getStream() stream {
 	}
 
 	if conn.clientStream != nil && conn.serverStream != nil {
-		logging.Error("Both client and server stream are not nil, something went wrong")
+		conn.logger.Error("Both client and server stream are not nil, something went wrong")
 	}
 
 	if conn.clientStream != nil {
@@ -365,6 +614,8 @@ type stream interface {
 }
 
 type msgSending struct {
-	envelope *protos.Envelope
-	onErr    func(error)
+	envelope    *protos.Envelope
+	marshaled   *protos.MarshaledEnvelope // The envelope marshaled once for several peers, if any
+	contentType string                    // Content type of the message, counted once it's sent
+	onErr       func(error)
 }
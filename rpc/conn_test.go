@@ -0,0 +1,258 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type recordingStream struct {
+	grpc.ClientStream
+	sync.Mutex
+	sent []*protos.Envelope
+}
+
+func (s *recordingStream) Send(envelope *protos.Envelope) error {
+	s.Lock()
+	defer s.Unlock()
+	s.sent = append(s.sent, envelope)
+	return nil
+}
+
+func (s *recordingStream) Recv() (*protos.Envelope, error) {
+	select {}
+}
+
+func (s *recordingStream) CloseSend() error {
+	return nil
+}
+
+func (s *recordingStream) sentMessages() []*protos.Envelope {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*protos.Envelope{}, s.sent...)
+}
+
+func TestMessagePriority(t *testing.T) {
+	dataMsg := &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{}}}
+	dataReq := &protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataReq{DataReq: &protos.DataRequest{}}}
+	aliveMsg := &protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}
+	stateMsg := &protos.RKSyncMessage{Content: &protos.RKSyncMessage_State{State: &protos.ChainState{}}}
+
+	assert.Equal(t, common.LowPriority, dataMsg.Priority())
+	assert.Equal(t, common.LowPriority, dataReq.Priority())
+	assert.Equal(t, common.HighPriority, aliveMsg.Priority())
+	assert.Equal(t, common.HighPriority, stateMsg.Priority())
+}
+
+func TestHighPriorityPreemptsData(t *testing.T) {
+	s := &recordingStream{}
+	conn := newConnection(nil, s, nil, SendBufferConfig{}.withDefaults(), &DropCounts{}, logging.Default())
+	conn.info = &protos.ConnectionInfo{Endpoint: "localhost:0"}
+	onErr := func(error) {}
+
+	for i := 0; i < defSendBuffSize; i++ {
+		msg, err := (&protos.RKSyncMessage{Nonce: uint64(i), Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{}}}).NoopSign()
+		require.NoError(t, err)
+		conn.send(msg, onErr, false)
+	}
+	alive, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}).NoopSign()
+	require.NoError(t, err)
+	conn.send(alive, onErr, false)
+
+	// The alive message isn't dropped though the data buffer is full
+	assert.Len(t, conn.dataBuff.msgs, defSendBuffSize)
+	assert.Len(t, conn.outBuff.msgs, 1)
+
+	conn.stopWG.Add(1)
+	go conn.writeToStream()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.sentMessages()) < defSendBuffSize+1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.close()
+
+	sent := s.sentMessages()
+	require.Len(t, sent, defSendBuffSize+1)
+	assert.Equal(t, alive.Envelope, sent[0])
+	for _, envp := range sent[1:] {
+		m, err := envp.ToRKSyncMessage()
+		require.NoError(t, err)
+		assert.True(t, m.IsDataMsg())
+	}
+}
+
+func TestSendBufferOverflow(t *testing.T) {
+	newMsg := func(i int) *msgSending {
+		return &msgSending{envelope: &protos.Envelope{Payload: []byte{byte(i)}}}
+	}
+	done := make(chan struct{})
+	done2 := make(chan struct{})
+
+	var dropped uint64
+	b := newSendBuffer(2, common.DropOldest, defBlockTimeout, &dropped)
+	for i := 0; i < 5; i++ {
+		assert.True(t, b.put(newMsg(i), b.policy, done))
+	}
+	assert.Equal(t, uint64(3), dropped)
+	assert.Equal(t, []byte{3}, (<-b.msgs).envelope.Payload)
+	assert.Equal(t, []byte{4}, (<-b.msgs).envelope.Payload)
+
+	dropped = 0
+	b = newSendBuffer(2, common.DropNewest, defBlockTimeout, &dropped)
+	for i := 0; i < 5; i++ {
+		b.put(newMsg(i), b.policy, done)
+	}
+	assert.Equal(t, uint64(3), dropped)
+	assert.Equal(t, []byte{0}, (<-b.msgs).envelope.Payload)
+	assert.Equal(t, []byte{1}, (<-b.msgs).envelope.Payload)
+
+	dropped = 0
+	b = newSendBuffer(1, common.Block, defBlockTimeout, &dropped)
+	assert.True(t, b.put(newMsg(0), b.policy, done))
+	putResult := make(chan bool)
+	go func() {
+		putResult <- b.put(newMsg(1), b.policy, done)
+	}()
+	select {
+	case <-putResult:
+		assert.Fail(t, "put should block while the buffer is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	<-b.msgs
+	assert.True(t, <-putResult)
+
+	go func() {
+		putResult <- b.put(newMsg(2), b.policy, done)
+	}()
+	close(done)
+	assert.False(t, <-putResult)
+	assert.Zero(t, dropped)
+
+	// The messages in excess of the waiters are dropped at once, the waiters after BlockTimeout
+	b = newSendBuffer(1, common.Block, 200*time.Millisecond, &dropped)
+	assert.True(t, b.put(newMsg(0), b.policy, nil))
+	go func() {
+		putResult <- b.put(newMsg(1), b.policy, nil)
+	}()
+	assert.Eventually(t, func() bool { return len(b.waiters) == 1 }, time.Second, 10*time.Millisecond)
+	assert.False(t, b.put(newMsg(2), b.policy, nil))
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&dropped))
+	assert.False(t, <-putResult)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&dropped))
+	assert.Zero(t, len(b.waiters))
+	assert.Equal(t, []byte{0}, (<-b.msgs).envelope.Payload)
+
+	// The senders of the dropped messages are told about the overflow
+	errs := make(chan error, 1)
+	b = newSendBuffer(1, common.DropNewest, defBlockTimeout, &dropped)
+	assert.True(t, b.put(newMsg(0), b.policy, nil))
+	assert.False(t, b.put(&msgSending{envelope: &protos.Envelope{}, onErr: func(err error) { errs <- err }}, b.policy, nil))
+	assert.Equal(t, errSendBufferOverflow, <-errs)
+
+	// The messages which must be delivered wait for room however long it takes, as many as they are
+	b = newSendBuffer(1, common.Block, 100*time.Millisecond, &dropped)
+	assert.True(t, b.put(newMsg(0), b.policy, nil))
+	waitResult := make(chan bool)
+	for i := 1; i <= 3; i++ {
+		go func(i int) {
+			waitResult <- b.wait(newMsg(i), done2)
+		}(i)
+	}
+	select {
+	case <-waitResult:
+		assert.Fail(t, "wait should block while the buffer is full")
+	case <-time.After(300 * time.Millisecond):
+	}
+	for i := 0; i < 3; i++ {
+		<-b.msgs
+		assert.True(t, <-waitResult)
+	}
+	go func() {
+		waitResult <- b.wait(newMsg(4), done2)
+	}()
+	close(done2)
+	assert.False(t, <-waitResult)
+}
+
+func TestDataStream(t *testing.T) {
+	s := &recordingStream{}
+	ds := &recordingStream{}
+	conn := newConnection(nil, s, nil, SendBufferConfig{}.withDefaults(), &DropCounts{}, logging.Default())
+	conn.info = &protos.ConnectionInfo{Endpoint: "localhost:0"}
+	conn.handler = func(*protos.SignedRKSyncMessage) {}
+	onErr := func(error) {}
+
+	conn.stopWG.Add(1)
+	go conn.writeToStream()
+	go conn.serviceDataStream(ds)
+
+	dataMsg, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{}}}).NoopSign()
+	require.NoError(t, err)
+	alive, err := (&protos.RKSyncMessage{Content: &protos.RKSyncMessage_AliveMsg{AliveMsg: &protos.AliveMessage{}}}).NoopSign()
+	require.NoError(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for conn.getDataStream() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.send(dataMsg, onErr, false)
+	conn.send(alive, onErr, false)
+
+	for (len(s.sentMessages()) < 1 || len(ds.sentMessages()) < 1) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.close()
+
+	// File data doesn't go through the gossip stream
+	assert.Equal(t, []*protos.Envelope{alive.Envelope}, s.sentMessages())
+	assert.Equal(t, []*protos.Envelope{dataMsg.Envelope}, ds.sentMessages())
+}
+
+func TestCloseReplacedConnection(t *testing.T) {
+	cs := newConnStore(nil, SendBufferConfig{}.withDefaults(), ConnPoolConfig{}, &DropCounts{}, logging.Default())
+	replaced := addIdleConn(cs, "p1", 0)
+	conn := addIdleConn(cs, "p1", 0)
+
+	// the stream of the replaced connection ending must not close the new one
+	cs.closeConnection(replaced)
+	assert.True(t, replaced.toDie())
+	assert.False(t, conn.toDie())
+	assert.Equal(t, 1, cs.connNum())
+
+	cs.closeConnection(conn)
+	assert.True(t, conn.toDie())
+	assert.Equal(t, 0, cs.connNum())
+}
+
+func TestSimultaneousConnections(t *testing.T) {
+	cs := newConnStore(nil, SendBufferConfig{}.withDefaults(), ConnPoolConfig{}, &DropCounts{}, logging.Default())
+	cs.selfID = common.PKIidType("p0")
+	dialed := addIdleConn(cs, "p1", 0)
+
+	// the connection dialed by the peer with the lowest PKI-ID is kept on both sides
+	assert.Nil(t, cs.onConnected(nil, &protos.ConnectionInfo{ID: common.PKIidType("p1")}))
+	assert.True(t, cs.isCurrent(dialed))
+
+	cs.selfID = common.PKIidType("p2")
+	conn := cs.onConnected(nil, &protos.ConnectionInfo{ID: common.PKIidType("p1")})
+	assert.NotNil(t, conn)
+	assert.True(t, dialed.toDie())
+	assert.False(t, cs.isCurrent(dialed))
+	assert.True(t, cs.isCurrent(conn))
+}
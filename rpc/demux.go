@@ -8,18 +8,22 @@ package rpc
 
 import (
 	"bytes"
+	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/common"
-	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 )
 
+// defSubscriberSize is the number of messages buffered for the subscribers registered with AddChannel
+const defSubscriberSize = 10
+
 // ChannelDeMultiplexer is a struct that can receive channel registrations
 type ChannelDeMultiplexer struct {
+	dropped  uint64 // accessed atomically, must stay 64-bit aligned
 	channels []*channel
-	seqNum   uint64
 	lock     sync.RWMutex
 	closed   bool
 }
@@ -30,9 +34,75 @@ func NewChannelDemultiplexer() *ChannelDeMultiplexer {
 }
 
 type channel struct {
-	pred common.MessageAcceptor
-	ch   chan interface{}
-	mac  []byte
+	sync.RWMutex
+	pred    common.MessageAcceptor
+	ch      chan interface{}
+	mac     []byte
+	policy  common.OverflowPolicy
+	done    chan struct{}
+	once    sync.Once
+	closed  bool
+	dropped uint64
+}
+
+func newChannel(predicate common.MessageAcceptor, mac []byte, size int, policy common.OverflowPolicy) *channel {
+	return &channel{
+		pred:   predicate,
+		ch:     make(chan interface{}, size),
+		mac:    mac,
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+}
+
+// put buffers the message according to the overflow policy of the subscriber
+func (c *channel) put(msg interface{}) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.closed {
+		return
+	}
+
+	switch c.policy {
+	case common.Block:
+		select {
+		case c.ch <- msg:
+		case <-c.done:
+		}
+	case common.DropOldest:
+		for {
+			select {
+			case c.ch <- msg:
+				return
+			default:
+			}
+
+			select {
+			case <-c.ch:
+				atomic.AddUint64(&c.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case c.ch <- msg:
+		default:
+			atomic.AddUint64(&c.dropped, 1)
+		}
+	}
+}
+
+// close closes the channel of the subscriber, once the messages being delivered
+// to it are given up
+func (c *channel) close() {
+	c.once.Do(func() {
+		close(c.done)
+
+		c.Lock()
+		defer c.Unlock()
+		c.closed = true
+		close(c.ch)
+	})
 }
 
 func (m *ChannelDeMultiplexer) isClosed() bool {
@@ -43,77 +113,131 @@ func (m *ChannelDeMultiplexer) isClosed() bool {
 // to close as well
 func (m *ChannelDeMultiplexer) Close() {
 	m.lock.Lock()
-	defer m.lock.Unlock()
 	m.closed = true
-	for _, ch := range m.channels {
-		close(ch.ch)
-	}
+	channels := m.channels
 	m.channels = nil
-}
+	m.lock.Unlock()
 
-// AddChannel registers a channel with a certain predicate
-func (m *ChannelDeMultiplexer) AddChannel(predicate common.MessageAcceptor) <-chan interface{} {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	if m.isClosed() {
-		return nil
+	for _, ch := range channels {
+		m.retire(ch)
 	}
+}
 
-	ch := &channel{ch: make(chan interface{}, 10), pred: predicate, mac: []byte{}}
-	m.channels = append(m.channels, ch)
-	return ch.ch
+// AddChannel registers a channel with a certain predicate.
+// Messages are delivered to it with backpressure.
+func (m *ChannelDeMultiplexer) AddChannel(predicate common.MessageAcceptor) <-chan interface{} {
+	ch, _ := m.Subscribe(predicate, []byte{}, defSubscriberSize, common.Block)
+	return ch
 }
 
 // AddChannelWithMAC registers a channel with a certain predicate and a byte slice
+// it can be unregistered by. Messages are delivered to it with backpressure.
 func (m *ChannelDeMultiplexer) AddChannelWithMAC(predicate common.MessageAcceptor, mac []byte) <-chan interface{} {
+	ch, _ := m.Subscribe(predicate, mac, defSubscriberSize, common.Block)
+	return ch
+}
+
+// Subscribe registers a channel buffering size messages with a certain predicate and a byte slice
+// it can be unregistered by. When the channel is full, the Block policy makes DeMultiplex wait for
+// room until the subscription is cancelled, the other policies drop a message. It returns a function
+// cancelling the subscription and closing the channel, which can be called after Close.
+// The channel is nil if the demultiplexer is closed.
+func (m *ChannelDeMultiplexer) Subscribe(predicate common.MessageAcceptor, mac []byte, size int, policy common.OverflowPolicy) (<-chan interface{}, func()) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	if m.isClosed() {
-		return nil
+		return nil, func() {}
 	}
 
-	ch := &channel{ch: make(chan interface{}, 10), pred: predicate, mac: mac}
+	ch := newChannel(predicate, mac, size, policy)
 	m.channels = append(m.channels, ch)
-	return ch.ch
+	return ch.ch, func() {
+		m.remove(func(c *channel) bool { return c == ch })
+	}
 }
 
-// Unregister closes a channel with a certain predicate
+// Unregister closes the channels registered with a certain byte slice
 func (m *ChannelDeMultiplexer) Unregister(mac []byte) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	if m.isClosed() {
-		return
-	}
+	m.remove(func(c *channel) bool { return bytes.Equal(mac, c.mac) })
+}
 
+func (m *ChannelDeMultiplexer) remove(matches func(*channel) bool) {
+	m.lock.Lock()
+	var removed []*channel
 	n := len(m.channels)
 	for i := 0; i < n; i++ {
 		ch := m.channels[i]
-		if bytes.Equal(mac, ch.mac) {
+		if matches(ch) {
+			removed = append(removed, ch)
 			m.channels = append(m.channels[:i], m.channels[i+1:]...)
 			n--
 			i--
 		}
 	}
+	m.lock.Unlock()
+
+	for _, ch := range removed {
+		m.retire(ch)
+	}
 }
 
-// DeMultiplex broadcasts the message to all channels that were returned
-// by AddChannel calls and that hold the respected predicates.
-func (m *ChannelDeMultiplexer) DeMultiplex(msg interface{}) {
+// retire closes the channel of a subscriber no longer registered, keeping count of its dropped messages
+func (m *ChannelDeMultiplexer) retire(ch *channel) {
+	ch.close()
+	atomic.AddUint64(&m.dropped, atomic.LoadUint64(&ch.dropped))
+}
+
+// Dropped returns the number of messages dropped so far because a subscriber was full
+func (m *ChannelDeMultiplexer) Dropped() uint64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	dropped := atomic.LoadUint64(&m.dropped)
+	for _, ch := range m.channels {
+		dropped += atomic.LoadUint64(&ch.dropped)
+	}
+	return dropped
+}
+
+// QueueDepths returns the number of messages buffered by each subscriber, named after the prefix
+// and the hex encoded byte slice the subscriber was registered with, or its index if it has none
+func (m *ChannelDeMultiplexer) QueueDepths(prefix string) []QueueDepth {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
+	depths := make([]QueueDepth, 0, len(m.channels))
+	for i, ch := range m.channels {
+		name := fmt.Sprintf("%s/%d", prefix, i)
+		if len(ch.mac) > 0 {
+			name = fmt.Sprintf("%s/%x", prefix, ch.mac)
+		}
+		depths = append(depths, QueueDepth{Name: name, Length: len(ch.ch), Capacity: cap(ch.ch)})
+	}
+	return depths
+}
+
+// DeMultiplex delivers the message to all channels that were returned
+// by AddChannel or Subscribe calls and that hold the respected predicates.
+// The subscribers are snapshotted, so that a blocking subscriber can be
+// unregistered while DeMultiplex waits for room in its channel.
+func (m *ChannelDeMultiplexer) DeMultiplex(msg interface{}) {
+	m.lock.RLock()
 	if m.isClosed() {
+		m.lock.RUnlock()
 		return
 	}
-
+	channels := make([]*channel, 0, len(m.channels))
 	for _, ch := range m.channels {
 		if ch.pred(msg) {
-			ch.ch <- msg
+			channels = append(channels, ch)
 		}
 	}
+	m.lock.RUnlock()
+
+	for _, ch := range channels {
+		ch.put(msg)
+	}
 }
 
 // ReceivedMessageImpl is an implementation of ReceiveMessage
@@ -133,7 +257,7 @@ func (m *ReceivedMessageImpl) Respond(msg *protos.RKSyncMessage) {
 	sMsg, err := msg.NoopSign()
 	if err != nil {
 		err = errors.WithStack(err)
-		logging.Errorf("Failed creating SignedRKSyncMessage: %+v", err)
+		m.conn.logger.Errorf("Failed creating SignedRKSyncMessage: %+v", err)
 		return
 	}
 	m.conn.send(sMsg, func(e error) {}, true)
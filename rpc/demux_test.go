@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rkcloudchain/rksync/common"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,3 +67,81 @@ func TestUnregister(t *testing.T) {
 	demux.Unregister([]byte{0})
 	assert.Len(t, demux.channels, 2)
 }
+
+func TestChannelDeMultiplexerOverflowPolicies(t *testing.T) {
+	demux := NewChannelDemultiplexer()
+	newest, cancelNewest := demux.Subscribe(func(msg interface{}) bool { return true }, nil, 2, common.DropNewest)
+	defer cancelNewest()
+	oldest, cancelOldest := demux.Subscribe(func(msg interface{}) bool { return true }, nil, 2, common.DropOldest)
+
+	for i := 0; i < 4; i++ {
+		demux.DeMultiplex(i)
+	}
+	assert.Equal(t, 0, <-newest)
+	assert.Equal(t, 1, <-newest)
+	assert.Equal(t, 2, <-oldest)
+	assert.Equal(t, 3, <-oldest)
+	assert.Equal(t, uint64(4), demux.Dropped())
+
+	// The dropped messages of the cancelled subscribers are still counted
+	cancelOldest()
+	cancelOldest()
+	_, open := <-oldest
+	assert.False(t, open)
+	assert.Equal(t, uint64(4), demux.Dropped())
+	assert.Len(t, demux.channels, 1)
+}
+
+func TestChannelDeMultiplexerBackpressure(t *testing.T) {
+	demux := NewChannelDemultiplexer()
+	ch, cancel := demux.Subscribe(func(msg interface{}) bool { return true }, nil, 1, common.Block)
+	demux.DeMultiplex("msg1")
+
+	// DeMultiplex waits for room in the channel of a blocking subscriber
+	delivered := make(chan struct{})
+	go func() {
+		demux.DeMultiplex("msg2")
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		assert.Fail(t, "DeMultiplex should wait for the subscriber")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, "msg1", <-ch)
+	<-delivered
+	assert.Equal(t, "msg2", <-ch)
+
+	// Cancelling the subscription releases a waiting DeMultiplex
+	demux.DeMultiplex("msg3")
+	released := make(chan struct{})
+	go func() {
+		demux.DeMultiplex("msg4")
+		close(released)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "DeMultiplex should be released")
+	}
+	assert.Equal(t, "msg3", <-ch)
+	_, open := <-ch
+	assert.False(t, open)
+	assert.Equal(t, uint64(0), demux.Dropped())
+}
+
+func TestChannelDeMultiplexerUnsubscribeAfterClose(t *testing.T) {
+	demux := NewChannelDemultiplexer()
+	ch, cancel := demux.Subscribe(func(msg interface{}) bool { return true }, []byte{0}, 1, common.Block)
+	demux.Close()
+	_, open := <-ch
+	assert.False(t, open)
+	cancel()
+	demux.Unregister([]byte{0})
+
+	ch, cancel = demux.Subscribe(func(msg interface{}) bool { return true }, nil, 1, common.Block)
+	assert.Nil(t, ch)
+	cancel()
+}
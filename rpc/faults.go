@@ -0,0 +1,170 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// Direction of an intercepted message
+type Direction int
+
+// Directions of the intercepted messages
+const (
+	Outgoing Direction = iota // Sent to the remote peer
+	Incoming                  // Received from the remote peer
+)
+
+func (d Direction) String() string {
+	if d == Incoming {
+		return "incoming"
+	}
+	return "outgoing"
+}
+
+// Fault is what happens to an intercepted message, the zero value delivers it untouched
+type Fault struct {
+	Drop       bool          // The message is discarded
+	Delay      time.Duration // The message is delivered after the delay, the messages which follow may overtake it
+	Duplicates int           // Number of extra copies of the message delivered
+}
+
+// Interceptor is invoked with every message exchanged with the remote peers, before it is sent
+// or handed to the subscribers. It lets tests drop, delay, duplicate or reorder the messages,
+// e.g. to partition the peers. Intercept is called concurrently and must not block.
+type Interceptor interface {
+	Intercept(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) Fault
+}
+
+// interceptorHolder wraps the interceptor stored in an atomic.Value, which can't hold nil
+type interceptorHolder struct {
+	Interceptor
+}
+
+// SetInterceptor installs the interceptor of the messages, nil removes it
+func (s *Server) SetInterceptor(i Interceptor) {
+	s.interceptor.Store(interceptorHolder{i})
+}
+
+func (s *Server) getInterceptor() Interceptor {
+	holder, _ := s.interceptor.Load().(interceptorHolder)
+	return holder.Interceptor
+}
+
+// intercept delivers the message as the interceptor decides, if any
+func (s *Server) intercept(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage, deliver func()) {
+	i := s.getInterceptor()
+	if i == nil {
+		deliver()
+		return
+	}
+
+	fault := i.Intercept(dir, peer, msg)
+	if fault.Drop {
+		s.logger.Debugf("Dropping %s message %v of %s", dir, msg, peer)
+		return
+	}
+	for n := 0; n <= fault.Duplicates; n++ {
+		if fault.Delay <= 0 {
+			deliver()
+			continue
+		}
+		time.AfterFunc(fault.Delay, func() {
+			if !s.isStopping() {
+				deliver()
+			}
+		})
+	}
+}
+
+// interceptIncoming passes the messages received from the peer to the interceptor before handling them
+func (s *Server) interceptIncoming(peer common.PKIidType, h handler) handler {
+	return func(m *protos.SignedRKSyncMessage) {
+		s.intercept(Incoming, peer, m, func() { h(m) })
+	}
+}
+
+// FaultRule describes the faults injected in the messages exchanged with a peer
+type FaultRule struct {
+	Drop      float64       // Probability a message is dropped, 1 partitions the peer
+	Duplicate float64       // Probability a message is delivered twice
+	Delay     time.Duration // Time the messages are held before being delivered
+	Jitter    time.Duration // Random extra delay of up to Jitter, which reorders the messages
+
+	// Filter selects the messages the rule applies to, all of them if nil
+	Filter func(dir Direction, msg *protos.SignedRKSyncMessage) bool
+}
+
+// Faults is an Interceptor applying a rule to the messages of each peer. The random
+// decisions are derived from a seed, so that a failing test can be replayed.
+type Faults struct {
+	lock  sync.Mutex
+	rand  *rand.Rand
+	rules map[string]FaultRule
+}
+
+// NewFaults creates a Faults without any rule, whose random decisions are derived from the seed
+func NewFaults(seed int64) *Faults {
+	return &Faults{
+		rand:  rand.New(rand.NewSource(seed)),
+		rules: make(map[string]FaultRule),
+	}
+}
+
+// Set replaces the rule of the messages exchanged with the peers
+func (f *Faults) Set(rule FaultRule, peers ...common.PKIidType) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, peer := range peers {
+		f.rules[peer.String()] = rule
+	}
+}
+
+// Partition drops all the messages exchanged with the peers
+func (f *Faults) Partition(peers ...common.PKIidType) {
+	f.Set(FaultRule{Drop: 1}, peers...)
+}
+
+// Heal removes the rules of the peers, or of all of them if none is given
+func (f *Faults) Heal(peers ...common.PKIidType) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(peers) == 0 {
+		f.rules = make(map[string]FaultRule)
+		return
+	}
+	for _, peer := range peers {
+		delete(f.rules, peer.String())
+	}
+}
+
+// Intercept applies the rule of the peer to the message
+func (f *Faults) Intercept(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) Fault {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	rule, exists := f.rules[peer.String()]
+	if !exists || (rule.Filter != nil && !rule.Filter(dir, msg)) {
+		return Fault{}
+	}
+	if rule.Drop > 0 && f.rand.Float64() < rule.Drop {
+		return Fault{Drop: true}
+	}
+	fault := Fault{Delay: rule.Delay}
+	if rule.Jitter > 0 {
+		fault.Delay += time.Duration(f.rand.Int63n(int64(rule.Jitter)))
+	}
+	if rule.Duplicate > 0 && f.rand.Float64() < rule.Duplicate {
+		fault.Duplicates = 1
+	}
+	return fault
+}
@@ -0,0 +1,126 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type interceptorFunc func(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) Fault
+
+func (f interceptorFunc) Intercept(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) Fault {
+	return f(dir, peer, msg)
+}
+
+func TestFaults(t *testing.T) {
+	p1, p2 := common.PKIidType("p1"), common.PKIidType("p2")
+	msg := createRKSyncMessage()
+
+	f := NewFaults(1)
+	assert.Equal(t, Fault{}, f.Intercept(Outgoing, p1, msg))
+	f.Partition(p1)
+	assert.Equal(t, Fault{Drop: true}, f.Intercept(Outgoing, p1, msg))
+	assert.Equal(t, Fault{Drop: true}, f.Intercept(Incoming, p1, msg))
+	assert.Equal(t, Fault{}, f.Intercept(Outgoing, p2, msg))
+
+	f.Set(FaultRule{Delay: time.Second, Duplicate: 1, Filter: func(dir Direction, _ *protos.SignedRKSyncMessage) bool {
+		return dir == Incoming
+	}}, p2)
+	assert.Equal(t, Fault{}, f.Intercept(Outgoing, p2, msg))
+	assert.Equal(t, Fault{Delay: time.Second, Duplicates: 1}, f.Intercept(Incoming, p2, msg))
+
+	f.Heal(p1)
+	assert.Equal(t, Fault{}, f.Intercept(Outgoing, p1, msg))
+	f.Heal()
+	assert.Equal(t, Fault{}, f.Intercept(Incoming, p2, msg))
+
+	// the same seed injects the same faults
+	rule := FaultRule{Drop: 0.3, Duplicate: 0.3, Jitter: time.Second}
+	f1, f2 := NewFaults(42), NewFaults(42)
+	f1.Set(rule, p1)
+	f2.Set(rule, p1)
+	dropped := 0
+	for i := 0; i < 100; i++ {
+		fault := f1.Intercept(Outgoing, p1, msg)
+		assert.Equal(t, fault, f2.Intercept(Outgoing, p1, msg))
+		assert.True(t, fault.Delay < time.Second)
+		if fault.Drop {
+			dropped++
+		}
+	}
+	assert.True(t, dropped > 10 && dropped < 50, "%d messages dropped", dropped)
+}
+
+func TestInterceptor(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6065", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6066", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+
+	received := inst2.Accept(func(msg interface{}) bool { return true })
+	member := &common.NetworkMember{Endpoint: "localhost:6066", PKIID: inst2.GetPKIid()}
+	expect := func(nonces ...uint64) {
+		for _, nonce := range nonces {
+			select {
+			case m := <-received:
+				assert.Equal(t, nonce, m.GetRKSyncMessage().Nonce)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Didn't receive message %d", nonce)
+			}
+		}
+		select {
+		case m := <-received:
+			t.Fatalf("Unexpected message %d", m.GetRKSyncMessage().Nonce)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	msg := createRKSyncMessage()
+	inst1.Send(msg, member)
+	expect(msg.Nonce)
+
+	faults := NewFaults(1)
+	inst1.SetInterceptor(faults)
+	faults.Partition(inst2.GetPKIid())
+	inst1.Send(createRKSyncMessage(), member)
+	expect()
+
+	faults.Heal()
+	faults.Set(FaultRule{Duplicate: 1}, inst2.GetPKIid())
+	msg = createRKSyncMessage()
+	inst1.Send(msg, member)
+	expect(msg.Nonce, msg.Nonce)
+
+	// the incoming messages are intercepted too
+	inst1.SetInterceptor(nil)
+	inst2.SetInterceptor(faults)
+	faults.Partition(inst1.GetPKIid())
+	inst1.Send(createRKSyncMessage(), member)
+	expect()
+
+	// a delayed message is overtaken by the next one
+	first, second := createRKSyncMessage(), createRKSyncMessage()
+	inst2.SetInterceptor(interceptorFunc(func(dir Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) Fault {
+		if msg.Nonce == first.Nonce {
+			return Fault{Delay: 500 * time.Millisecond}
+		}
+		return Fault{}
+	}))
+	inst1.Send(first, member)
+	time.Sleep(100 * time.Millisecond)
+	inst1.Send(second, member)
+	expect(second.Nonce, first.Nonce)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+)
+
+// latencyWeight is the weight of a new round-trip time in the moving average
+const latencyWeight = 0.25
+
+// latencyTracker keeps a moving average of the round-trip times
+// measured to the peers by pings and handshakes
+type latencyTracker struct {
+	lock sync.RWMutex
+	rtts map[string]time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{rtts: make(map[string]time.Duration)}
+}
+
+func (lt *latencyTracker) record(pkiID common.PKIidType, rtt time.Duration) {
+	if len(pkiID) == 0 || rtt <= 0 {
+		return
+	}
+
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	avg, exists := lt.rtts[pkiID.String()]
+	if exists {
+		rtt = avg + time.Duration(latencyWeight*float64(rtt-avg))
+	}
+	lt.rtts[pkiID.String()] = rtt
+}
+
+func (lt *latencyTracker) get(pkiID common.PKIidType) time.Duration {
+	lt.lock.RLock()
+	defer lt.lock.RUnlock()
+	return lt.rtts[pkiID.String()]
+}
@@ -0,0 +1,128 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+)
+
+// ConnPoolConfig bounds the connections established with remote peers
+type ConnPoolConfig struct {
+	MaxConnections  int           // Least recently used connections above this number are evicted, 0 disables the limit
+	IdleConnTimeout time.Duration // Connections without any message sent or received during this time are closed, 0 disables it
+	MaxHandshakes   int           // Max number of handshakes with connecting peers performed concurrently, the others are refused, 0 disables the limit
+	HandshakeRate   int           // Max number of connection attempts accepted per second from an IP address, 0 disables the limit
+	// MinProtocolVersion is the oldest version of the protocol the remote peers must speak,
+	// the peers which predate the negotiation are accepted if it's 0
+	MinProtocolVersion uint32
+	// Admit is consulted once a remote peer is authenticated, the connection is refused if it returns an error.
+	// All peers are admitted if it's nil.
+	Admit func(pkiID common.PKIidType, identity common.PeerIdentityType) error
+}
+
+func (conn *connection) touch() {
+	atomic.StoreInt64(&conn.lastActivity, time.Now().UnixNano())
+}
+
+func (conn *connection) idleSince() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&conn.lastActivity))
+}
+
+// evictExcess closes the least recently used connections above MaxConnections,
+// except the one with the given peer, and returns the PKI-IDs of the evicted peers
+func (cs *connectionStore) evictExcess(keep common.PKIidType) []common.PKIidType {
+	max := cs.poolConf.MaxConnections
+	if max <= 0 {
+		return nil
+	}
+
+	cs.RLock()
+	num := len(cs.conns)
+	cs.RUnlock()
+	if num <= max {
+		return nil
+	}
+
+	cs.Lock()
+	var evicted []*connection
+	for len(cs.conns) > max {
+		var lru *connection
+		for id, conn := range cs.conns {
+			if id == keep.String() {
+				continue
+			}
+			if lru == nil || conn.idleSince().Before(lru.idleSince()) {
+				lru = conn
+			}
+		}
+		if lru == nil {
+			break
+		}
+		delete(cs.conns, lru.info.ID.String())
+		evicted = append(evicted, lru)
+	}
+	cs.Unlock()
+
+	var pkiIDs []common.PKIidType
+	for _, conn := range evicted {
+		cs.logger.Debugf("Evicting connection with %s, %d connections at most", conn.info.Endpoint, max)
+		conn.close()
+		pkiIDs = append(pkiIDs, conn.info.ID)
+	}
+	return pkiIDs
+}
+
+// reapIdle closes the connections idle for longer than the timeout
+func (cs *connectionStore) reapIdle(timeout time.Duration) {
+	deadline := time.Now().Add(-timeout)
+
+	cs.Lock()
+	var idle []*connection
+	for id, conn := range cs.conns {
+		if conn.idleSince().Before(deadline) {
+			delete(cs.conns, id)
+			idle = append(idle, conn)
+		}
+	}
+	cs.Unlock()
+
+	for _, conn := range idle {
+		cs.logger.Debugf("Closing connection with %s, idle since %s", conn.info.Endpoint, conn.idleSince())
+		conn.close()
+	}
+}
+
+// evict closes the connections above the max number of connections and
+// reports the evicted peers as presumed dead, keeping the discovery state coherent
+func (s *Server) evict(keep common.PKIidType) {
+	for _, pkiID := range s.connStore.evictExcess(keep) {
+		if s.isStopping() {
+			return
+		}
+		s.deadEndpoints <- pkiID
+	}
+}
+
+func (s *Server) reapIdleConns() {
+	defer s.stopWG.Done()
+
+	timeout := s.connStore.poolConf.IdleConnTimeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.connStore.reapIdle(timeout)
+		case <-s.exitChan:
+			return
+		}
+	}
+}
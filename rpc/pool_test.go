@@ -0,0 +1,62 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+)
+
+func addIdleConn(cs *connectionStore, pkiID string, idle time.Duration) *connection {
+	conn := newConnection(nil, &recordingStream{}, nil, cs.bufConf, cs.dropped, cs.logger)
+	conn.info = &protos.ConnectionInfo{ID: common.PKIidType(pkiID), Endpoint: pkiID}
+	atomic.StoreInt64(&conn.lastActivity, time.Now().Add(-idle).UnixNano())
+	cs.conns[conn.info.ID.String()] = conn
+	return conn
+}
+
+func TestEvictExcess(t *testing.T) {
+	cs := newConnStore(nil, SendBufferConfig{}.withDefaults(), ConnPoolConfig{MaxConnections: 2}, &DropCounts{}, logging.Default())
+	oldest := addIdleConn(cs, "p1", 3*time.Minute)
+	addIdleConn(cs, "p2", time.Minute)
+	assert.Empty(t, cs.evictExcess(nil))
+
+	// the connection just established is kept even if it's the least recently used
+	addIdleConn(cs, "p3", 5*time.Minute)
+	evicted := cs.evictExcess(common.PKIidType("p3"))
+	assert.Equal(t, []common.PKIidType{common.PKIidType("p1")}, evicted)
+	assert.True(t, oldest.toDie())
+	assert.Equal(t, 2, cs.connNum())
+
+	cs = newConnStore(nil, SendBufferConfig{}.withDefaults(), ConnPoolConfig{}, &DropCounts{}, logging.Default())
+	addIdleConn(cs, "p1", time.Minute)
+	addIdleConn(cs, "p2", time.Minute)
+	assert.Empty(t, cs.evictExcess(nil))
+	assert.Equal(t, 2, cs.connNum())
+}
+
+func TestReapIdle(t *testing.T) {
+	cs := newConnStore(nil, SendBufferConfig{}.withDefaults(), ConnPoolConfig{IdleConnTimeout: time.Minute}, &DropCounts{}, logging.Default())
+	idle := addIdleConn(cs, "p1", 2*time.Minute)
+	active := addIdleConn(cs, "p2", 0)
+
+	cs.reapIdle(time.Minute)
+	assert.True(t, idle.toDie())
+	assert.False(t, active.toDie())
+	assert.Equal(t, 1, cs.connNum())
+
+	atomic.StoreInt64(&active.lastActivity, time.Now().Add(-2*time.Minute).UnixNano())
+	active.touch()
+	cs.reapIdle(time.Minute)
+	assert.False(t, active.toDie())
+}
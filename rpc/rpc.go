@@ -17,16 +17,18 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/gogo/protobuf/types"
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/identity"
 	"github.com/rkcloudchain/rksync/lib"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/server"
 	"github.com/rkcloudchain/rksync/util"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 const (
@@ -35,11 +37,14 @@ const (
 	defConnTimeout   = time.Second * time.Duration(2)
 	defRecvBuffSize  = 20
 	defSendBuffSize  = 20
+	defBlockTimeout  = time.Second * time.Duration(10)
+	connPollInterval = time.Millisecond * time.Duration(10)
 )
 
 // NewServer creates a new Server instance that binds itself to the given gRPC server
 func NewServer(s *grpc.Server, idMapper identity.Identity, selfIdentity common.PeerIdentityType,
-	secureDialOpts func() []grpc.DialOption) *Server {
+	secureDialOpts func() []grpc.DialOption, bufConf SendBufferConfig, poolConf ConnPoolConfig, breakerConf BreakerConfig,
+	logger logging.Logger) *Server {
 
 	srv := &Server{
 		pubSub:         lib.NewPubSub(),
@@ -49,26 +54,43 @@ func NewServer(s *grpc.Server, idMapper identity.Identity, selfIdentity common.P
 		secureDialOpts: secureDialOpts,
 		gSrv:           s,
 		msgPublisher:   NewChannelDemultiplexer(),
+		breaker:        newCircuitBreaker(breakerConf),
+		throttle:       newHandshakeThrottle(poolConf.MaxHandshakes, poolConf.HandshakeRate),
+		latencies:      newLatencyTracker(),
+		version:        protos.ProtocolVersion,
 		deadEndpoints:  make(chan common.PKIidType, 100),
 		stopping:       int32(0),
 		exitChan:       make(chan struct{}),
 		subscriptions:  make([]chan protos.ReceivedMessage, 0),
+		logger:         logger,
 	}
-	srv.connStore = newConnStore(srv.createConnection)
+	srv.connStore = newConnStore(srv.createConnection, bufConf.withDefaults(), poolConf, &srv.dropped, logger)
+	srv.connStore.selfID = srv.pkiID
 	protos.RegisterRKSyncServer(s, srv)
+	if poolConf.IdleConnTimeout > 0 {
+		srv.stopWG.Add(1)
+		go srv.reapIdleConns()
+	}
 	return srv
 }
 
 // Server is an object that enables to communicate with other peers
 type Server struct {
+	dropped        DropCounts // accessed atomically, must stay 64-bit aligned
 	secureDialOpts func() []grpc.DialOption
 	pubSub         *lib.PubSub
 	gSrv           *grpc.Server
 	lsnr           net.Listener
 	connStore      *connectionStore
+	breaker        *circuitBreaker
+	throttle       *handshakeThrottle
+	latencies      *latencyTracker
+	interceptor    atomic.Value // interceptorHolder
 	idMapper       identity.Identity
 	peerIdentity   common.PeerIdentityType
 	pkiID          common.PKIidType
+	version        uint32 // Highest version of the protocol spoken
+	logger         logging.Logger
 	lock           sync.Mutex
 	stopping       int32
 	stopWG         sync.WaitGroup
@@ -85,14 +107,15 @@ func (s *Server) createConnection(endpoint string, expectedPKIID common.PKIidTyp
 	var connInfo *protos.ConnectionInfo
 	var dialOpts []grpc.DialOption
 
-	logging.Debug("Entering", endpoint, expectedPKIID)
-	defer logging.Debug("Exiting")
+	s.logger.Debug("Entering", endpoint, expectedPKIID)
+	defer s.logger.Debug("Exiting")
 
 	if s.isStopping() {
 		return nil, errors.New("Stopping")
 	}
 
 	dialOpts = append(dialOpts, s.secureDialOpts()...)
+	dialOpts = append(dialOpts, endpointDialOptions(endpoint)...)
 	dialOpts = append(dialOpts, grpc.WithBlock())
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, defDialTimeout)
@@ -106,7 +129,7 @@ func (s *Server) createConnection(endpoint string, expectedPKIID common.PKIidTyp
 	cl := protos.NewRKSyncClient(cc)
 	ctx, cancel = context.WithTimeout(context.Background(), defConnTimeout)
 	defer cancel()
-	if _, err = cl.Ping(ctx, &types.Empty{}); err != nil {
+	if _, err = cl.Ping(ctx, &emptypb.Empty{}); err != nil {
 		cc.Close()
 		return nil, errors.WithStack(err)
 	}
@@ -116,32 +139,53 @@ func (s *Server) createConnection(endpoint string, expectedPKIID common.PKIidTyp
 		connInfo, err = s.authenticateRemotePeer(stream)
 		if err == nil {
 			if expectedPKIID != nil && !bytes.Equal(connInfo.ID, expectedPKIID) {
-				logging.Warning("Remote endpoint claims to be a different peer, expected", expectedPKIID, "but got", connInfo.ID)
+				s.logger.Warning("Remote endpoint claims to be a different peer, expected", expectedPKIID, "but got", connInfo.ID)
 			}
 
-			conn := newConnection(cc, stream, nil)
+			conn := newConnection(cc, stream, nil, s.connStore.bufConf, s.connStore.dropped, s.logger)
 			conn.info = connInfo
 			conn.cancel = cancel
+			conn.dataClientStream = s.createDataStream(ctx, cl, connInfo)
 
 			h := func(m *protos.SignedRKSyncMessage) {
-				logging.Debug("Got message:", m)
+				s.logger.Debug("Got message:", m)
 				s.msgPublisher.DeMultiplex(&ReceivedMessageImpl{
 					conn:                conn,
 					SignedRKSyncMessage: m,
 					connInfo:            connInfo,
 				})
 			}
-			conn.handler = interceptAcks(h, connInfo.ID, s.pubSub)
+			conn.handler = s.interceptIncoming(connInfo.ID, interceptAcks(h, connInfo.ID, s.pubSub))
 			return conn, nil
 		}
 
-		logging.Warningf("Authentication failed: %+v", err)
+		s.logger.Warningf("Authentication failed: %+v", err)
 	}
 	cc.Close()
 	cancel()
 	return nil, errors.WithStack(err)
 }
 
+// createDataStream opens the stream used for exchanging file data with the remote peer,
+// it returns nil if the remote peer doesn't support it and the data is sent through the gossip stream
+func (s *Server) createDataStream(ctx context.Context, cl protos.RKSyncClient, connInfo *protos.ConnectionInfo) protos.RKSync_DataStreamClient {
+	stream, err := cl.DataStream(ctx)
+	if err == nil {
+		var dataConnInfo *protos.ConnectionInfo
+		dataConnInfo, err = s.authenticateRemotePeer(stream)
+		if err == nil && !bytes.Equal(dataConnInfo.ID, connInfo.ID) {
+			err = errors.New("Data stream was authenticated by a different peer")
+		}
+		if err == nil {
+			return stream
+		}
+		stream.CloseSend()
+	}
+
+	s.logger.Debugf("Sending file data to %s through the gossip stream: %v", connInfo.Endpoint, err)
+	return nil
+}
+
 // GetPKIid returns this instance's PKI id
 func (s *Server) GetPKIid() common.PKIidType {
 	return s.pkiID
@@ -152,11 +196,24 @@ func (s *Server) Send(msg *protos.SignedRKSyncMessage, peers ...*common.NetworkM
 	if s.isStopping() || len(peers) == 0 {
 		return
 	}
-	logging.Debug("Entering, sending", msg, "to ", len(peers), "peers")
+	// The envelope relayed to several peers is marshaled once rather than by the stream of each of them
+	var marshaled *protos.MarshaledEnvelope
+	if len(peers) > 1 && msg.Envelope != nil {
+		var err error
+		if marshaled, err = msg.Envelope.Marshaled(); err != nil {
+			s.logger.Warningf("Dropping message sent to %d peers: %s", len(peers), err)
+			return
+		}
+	}
+	if err := s.checkSize(msg, marshaled); err != nil {
+		s.logger.Warningf("Dropping message sent to %d peers: %s", len(peers), err)
+		return
+	}
+	s.logger.Debug("Entering, sending", msg, "to ", len(peers), "peers")
 
 	for _, peer := range peers {
 		go func(peer *common.NetworkMember, msg *protos.SignedRKSyncMessage) {
-			s.sendToEndpoint(peer, msg, false)
+			s.sendToEndpoint(peer, msg, marshaled, false, nil)
 		}(peer, msg)
 	}
 }
@@ -173,10 +230,13 @@ func (s *Server) SendWithAck(msg *protos.SignedRKSyncMessage, timeout time.Durat
 	if len(msg.Signature) == 0 {
 		msg, err = msg.NoopSign()
 	} else {
-		_, err = msg.Sign(func(msg []byte) ([]byte, error) {
+		_, err = msg.SignWithAlgorithm(s.idMapper.SignatureAlgorithm(), func(msg []byte) ([]byte, error) {
 			return s.idMapper.Sign(msg)
 		})
 	}
+	if err == nil {
+		err = s.checkSize(msg, nil)
+	}
 
 	if s.isStopping() || err != nil {
 		if err == nil {
@@ -189,9 +249,12 @@ func (s *Server) SendWithAck(msg *protos.SignedRKSyncMessage, timeout time.Durat
 		return results
 	}
 
-	logging.Debug("Entering, sending", msg, "to ", len(peers), "peers")
+	s.logger.Debug("Entering, sending", msg, "to ", len(peers), "peers")
 	sndFunc := func(peer *common.NetworkMember, msg *protos.SignedRKSyncMessage) {
-		s.sendToEndpoint(peer, msg, true)
+		// A message which couldn't be sent is reported at once instead of waiting for the acknowledgement to time out
+		s.sendToEndpoint(peer, msg, nil, true, func(err error) {
+			s.pubSub.Publish(topicForAck(msg.Nonce, peer.PKIID), &protos.Acknowledgement{Error: err.Error()})
+		})
 	}
 
 	subscriptions := make(map[string]func() error)
@@ -221,33 +284,128 @@ func (s *Server) SendWithAck(msg *protos.SignedRKSyncMessage, timeout time.Durat
 	return ackOperation.send(msg, minAck, peers...)
 }
 
-func (s *Server) sendToEndpoint(peer *common.NetworkMember, msg *protos.SignedRKSyncMessage, shouldBlock bool) {
+// checkSize returns an error if the message exceeds the max message size
+func (s *Server) checkSize(msg *protos.SignedRKSyncMessage, marshaled *protos.MarshaledEnvelope) error {
+	max := s.connStore.bufConf.MaxMessageSize
+	if max <= 0 || msg.Envelope == nil {
+		return nil
+	}
+	size := 0
+	if marshaled != nil {
+		size = marshaled.Size()
+	} else {
+		size = proto.Size(msg.Envelope)
+	}
+	if size > max {
+		return errors.Errorf("Message of %d bytes exceeds the max message size of %d bytes", size, max)
+	}
+	return nil
+}
+
+// sendToEndpoint sends the message to the peer, onErr is invoked with the error if it
+// couldn't be sent unless it's nil
+func (s *Server) sendToEndpoint(peer *common.NetworkMember, msg *protos.SignedRKSyncMessage, marshaled *protos.MarshaledEnvelope, shouldBlock bool, onErr func(error)) {
 	if s.isStopping() {
 		return
 	}
-	logging.Debug("Entering, Sending to", peer.Endpoint, ", msg", msg)
-	defer logging.Debug("Exiting")
+	s.intercept(Outgoing, peer.PKIID, msg, func() {
+		s.transmit(peer, msg, marshaled, shouldBlock, onErr)
+	})
+}
+
+func (s *Server) transmit(peer *common.NetworkMember, msg *protos.SignedRKSyncMessage, marshaled *protos.MarshaledEnvelope, shouldBlock bool, onErr func(error)) {
+	s.logger.Debug("Entering, Sending to", peer.Endpoint, ", msg", msg)
+	defer s.logger.Debug("Exiting")
+
+	if !s.breaker.allow(peer.PKIID) {
+		s.logger.Debugf("Discarding message sent to %s, its circuit is open", peer.Endpoint)
+		return
+	}
 
 	conn, err := s.connStore.getConnection(peer)
 	if err == nil {
+		s.breaker.success(peer.PKIID)
+		s.evict(conn.info.ID)
+		if !conn.supports(msg) {
+			s.logger.Debugf("Discarding message sent to %s, it speaks version %d of the protocol: %v", peer.Endpoint, conn.info.ProtocolVersion, msg)
+			return
+		}
 		disConnectOnErr := func(err error) {
-			logging.Warningf("%v isn't responsive: %v", peer.Endpoint, err)
+			if onErr != nil {
+				onErr(err)
+			}
+			// The overflow of the send buffer is handled by its policy
+			if err == errSendBufferOverflow {
+				s.logger.Debugf("Dropped message sent to %s: %v", peer.Endpoint, err)
+				return
+			}
+			// A connection closed or replaced meanwhile, e.g. by the connection the peer dialed
+			// at the same time, says nothing about the peer
+			if !s.connStore.isCurrent(conn) {
+				s.logger.Debugf("Failed sending to %s through a replaced connection: %v", peer.Endpoint, err)
+				return
+			}
+			s.logger.Warningf("%v isn't responsive: %v", peer.Endpoint, err)
+			s.recordFailure(peer)
 			s.disconnect(peer.PKIID)
 		}
-		conn.send(msg, disConnectOnErr, shouldBlock)
+		conn.sendMarshaled(msg, marshaled, disConnectOnErr, shouldBlock)
 		return
 	}
-	logging.Warningf("Failed obtaining connection for %v reason: %v", peer.Endpoint, err)
+	s.logger.Warningf("Failed obtaining connection for %v reason: %v", peer.Endpoint, err)
+	if onErr != nil {
+		onErr(err)
+	}
+	s.recordFailure(peer)
 	s.disconnect(peer.PKIID)
 }
 
+// DroppedMessages returns the number of messages dropped so far
+// because the send buffer of a peer, or a subscriber of the received messages, was full
+func (s *Server) DroppedMessages() DropCounts {
+	return DropCounts{
+		Control:  atomic.LoadUint64(&s.dropped.Control),
+		Data:     atomic.LoadUint64(&s.dropped.Data),
+		Received: s.msgPublisher.Dropped(),
+	}
+}
+
+// ConnectionStats returns the connections currently established with remote peers
+func (s *Server) ConnectionStats() []ConnectionStats {
+	stats := s.connStore.stats()
+	for i := range stats {
+		stats[i].RTT = s.latencies.get(stats[i].PKIID)
+	}
+	return stats
+}
+
+// QueueDepths returns the number of messages waiting in the queues of the server:
+// the messages received by each subscriber, and the messages to send to each peer
+func (s *Server) QueueDepths() []QueueDepth {
+	depths := s.msgPublisher.QueueDepths("subscriber")
+
+	s.lock.Lock()
+	for i, ch := range s.subscriptions {
+		depths = append(depths, QueueDepth{Name: fmt.Sprintf("accept/%d", i), Length: len(ch), Capacity: cap(ch)})
+	}
+	s.lock.Unlock()
+
+	size := s.connStore.bufConf.Size
+	for _, stats := range s.connStore.stats() {
+		depths = append(depths,
+			QueueDepth{Name: fmt.Sprintf("send/%s/control", stats.PKIID), Length: stats.QueuedControl, Capacity: size},
+			QueueDepth{Name: fmt.Sprintf("send/%s/data", stats.PKIID), Length: stats.QueuedData, Capacity: size})
+	}
+	return depths
+}
+
 // Accept returns a dedicated read-only channel for messages sent by other nodes that match a certain predicate.
 func (s *Server) Accept(acceptor common.MessageAcceptor) <-chan protos.ReceivedMessage {
 	genericChan := s.msgPublisher.AddChannel(acceptor)
 	specificChan := make(chan protos.ReceivedMessage, 10)
 
 	if s.isStopping() {
-		logging.Warning("Accept() called but server is stopping, returning empty channel")
+		s.logger.Warning("Accept() called but server is stopping, returning empty channel")
 		return specificChan
 	}
 
@@ -257,7 +415,7 @@ func (s *Server) Accept(acceptor common.MessageAcceptor) <-chan protos.ReceivedM
 
 	s.stopWG.Add(1)
 	go func() {
-		defer logging.Debug("Exiting Accept() loop")
+		defer s.logger.Debug("Exiting Accept() loop")
 		defer s.stopWG.Done()
 
 		for {
@@ -284,13 +442,15 @@ func (s *Server) Accept(acceptor common.MessageAcceptor) <-chan protos.ReceivedM
 // (its identity, nil) on success and (nil, error)
 func (s *Server) Handshake(peer *common.NetworkMember) (common.PeerIdentityType, error) {
 	var dialOpts []grpc.DialOption
+	endpoint := peer.PreferredEndpoint()
 	dialOpts = append(dialOpts, s.secureDialOpts()...)
+	dialOpts = append(dialOpts, endpointDialOptions(endpoint)...)
 	dialOpts = append(dialOpts, grpc.WithBlock())
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, defDialTimeout)
 	defer cancel()
 
-	cc, err := grpc.DialContext(ctx, peer.Endpoint, dialOpts...)
+	cc, err := grpc.DialContext(ctx, endpoint, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -299,9 +459,11 @@ func (s *Server) Handshake(peer *common.NetworkMember) (common.PeerIdentityType,
 	cl := protos.NewRKSyncClient(cc)
 	ctx, cancel = context.WithTimeout(context.Background(), defConnTimeout)
 	defer cancel()
-	if _, err = cl.Ping(ctx, &types.Empty{}); err != nil {
+	start := time.Now()
+	if _, err = cl.Ping(ctx, &emptypb.Empty{}); err != nil {
 		return nil, err
 	}
+	rtt := time.Since(start)
 
 	ctx, cancel = context.WithTimeout(context.Background(), handshakeTimeout)
 	defer cancel()
@@ -311,28 +473,62 @@ func (s *Server) Handshake(peer *common.NetworkMember) (common.PeerIdentityType,
 	}
 	connInfo, err := s.authenticateRemotePeer(stream)
 	if err != nil {
-		logging.Warningf("Authentication failed: %v", err)
+		s.logger.Warningf("Authentication failed: %v", err)
 		return nil, err
 	}
 
 	if len(peer.PKIID) > 0 && !bytes.Equal(connInfo.ID, peer.PKIID) {
 		return nil, errors.New("PKI-ID of remote peer doesn't match expected PKI-ID")
 	}
+	s.latencies.record(connInfo.ID, rtt)
 	return connInfo.Identity, nil
 }
 
 // Probe probes a remote node and returns nil if its responsive,
 // and an error if it's not
 func (s *Server) Probe(remotePeer *common.NetworkMember) error {
+	if !s.breaker.allow(remotePeer.PKIID) {
+		return errors.Errorf("Circuit of %s is open", remotePeer.Endpoint)
+	}
+
+	err := s.probe(remotePeer)
+	if err != nil {
+		s.recordFailure(remotePeer)
+	} else {
+		s.breaker.success(remotePeer.PKIID)
+	}
+	return err
+}
+
+// CircuitOpen checks whether the circuit of the peer is open because it failed repeatedly,
+// the peer shouldn't be selected to send messages to
+func (s *Server) CircuitOpen(pkiID common.PKIidType) bool {
+	return s.breaker.isOpen(pkiID)
+}
+
+// Latency returns the average round-trip time measured to the peer
+// when probing it or handshaking with it, 0 if it wasn't measured yet
+func (s *Server) Latency(pkiID common.PKIidType) time.Duration {
+	return s.latencies.get(pkiID)
+}
+
+func (s *Server) recordFailure(peer *common.NetworkMember) {
+	if s.breaker.failure(peer.PKIID) {
+		s.logger.Warningf("Opening the circuit of %s, it failed %d times", peer.Endpoint, s.breaker.conf.Threshold)
+	}
+}
+
+func (s *Server) probe(remotePeer *common.NetworkMember) error {
 	var dialOpts []grpc.DialOption
-	endpoint := remotePeer.Endpoint
+	endpoint := remotePeer.PreferredEndpoint()
 	pkiID := remotePeer.PKIID
 	if s.isStopping() {
 		return errors.New("Stopping")
 	}
 
-	logging.Debug("Entering, endpoint:", endpoint, "PKIID:", pkiID)
+	s.logger.Debug("Entering, endpoint:", endpoint, "PKIID:", pkiID)
 	dialOpts = append(dialOpts, s.secureDialOpts()...)
+	dialOpts = append(dialOpts, endpointDialOptions(endpoint)...)
 	dialOpts = append(dialOpts, grpc.WithBlock())
 
 	ctx := context.Background()
@@ -341,7 +537,7 @@ func (s *Server) Probe(remotePeer *common.NetworkMember) error {
 
 	cc, err := grpc.DialContext(ctx, endpoint, dialOpts...)
 	if err != nil {
-		logging.Debugf("Returning %v", err)
+		s.logger.Debugf("Returning %v", err)
 		return err
 	}
 	defer cc.Close()
@@ -350,14 +546,18 @@ func (s *Server) Probe(remotePeer *common.NetworkMember) error {
 	ctx, cancel = context.WithTimeout(context.Background(), defConnTimeout)
 	defer cancel()
 
-	_, err = cl.Ping(ctx, &types.Empty{})
-	logging.Debugf("Returning %v", err)
+	start := time.Now()
+	_, err = cl.Ping(ctx, &emptypb.Empty{})
+	if err == nil {
+		s.latencies.record(pkiID, time.Since(start))
+	}
+	s.logger.Debugf("Returning %v", err)
 	return err
 }
 
 // CloseConn closes a connection to a certain endpoint
 func (s *Server) CloseConn(peer *common.NetworkMember) {
-	logging.Debug("Closing connection for", peer.Endpoint)
+	s.logger.Debug("Closing connection for", peer.Endpoint)
 	s.connStore.closeConn(peer)
 }
 
@@ -379,13 +579,13 @@ func (s *Server) Stop() {
 	if !atomic.CompareAndSwapInt32(&s.stopping, int32(0), int32(1)) {
 		return
 	}
-	logging.Info("Stopping rpc")
-	defer logging.Info("Stopped rpc")
+	s.logger.Info("Stopping rpc")
+	defer s.logger.Info("Stopped rpc")
 	if s.gSrv != nil {
 		s.gSrv.Stop()
 	}
 	s.connStore.shutdown()
-	logging.Debug("Shut down connection store, connection count:", s.connStore.connNum())
+	s.logger.Debug("Shut down connection store, connection count:", s.connStore.connNum())
 	s.msgPublisher.Close()
 	close(s.exitChan)
 	s.stopWG.Wait()
@@ -397,14 +597,19 @@ func (s *Server) SyncStream(stream protos.RKSync_SyncStreamServer) error {
 	if s.isStopping() {
 		return errors.New("Shutting down")
 	}
-	connInfo, err := s.authenticateRemotePeer(stream)
+	connInfo, err := s.authenticateConnectingPeer(stream)
 	if err != nil {
-		logging.Errorf("Authentication failed: %v", err)
+		s.logger.Errorf("Authentication failed: %v", err)
 		return err
 	}
-	logging.Debug("Servicing", extractRemoteAddress(stream))
+	s.logger.Debug("Servicing", extractRemoteAddress(stream))
 
 	conn := s.connStore.onConnected(stream, connInfo)
+	if conn == nil {
+		s.logger.Debug("Keeping the connection already dialed to", extractRemoteAddress(stream))
+		return errors.New("A connection dialed at the same time is kept")
+	}
+	s.evict(connInfo.ID)
 
 	h := func(m *protos.SignedRKSyncMessage) {
 		s.msgPublisher.DeMultiplex(&ReceivedMessageImpl{
@@ -413,20 +618,40 @@ func (s *Server) SyncStream(stream protos.RKSync_SyncStreamServer) error {
 			connInfo:            connInfo,
 		})
 	}
-	conn.handler = interceptAcks(h, connInfo.ID, s.pubSub)
+	conn.handler = s.interceptIncoming(connInfo.ID, interceptAcks(h, connInfo.ID, s.pubSub))
 
 	defer func() {
-		logging.Debug("Client", extractRemoteAddress(stream), "disconnected")
-		s.connStore.closeByPKIid(connInfo.ID)
-		conn.close()
+		s.logger.Debug("Client", extractRemoteAddress(stream), "disconnected")
+		s.connStore.closeConnection(conn)
 	}()
 
 	return conn.serviceConnection()
 }
 
+// DataStream is the gRPC stream used for sending and receiving file data,
+// it is attached to the connection established by the SyncStream of the same peer
+func (s *Server) DataStream(stream protos.RKSync_DataStreamServer) error {
+	if s.isStopping() {
+		return errors.New("Shutting down")
+	}
+	connInfo, err := s.authenticateConnectingPeer(stream)
+	if err != nil {
+		s.logger.Errorf("Authentication failed: %v", err)
+		return err
+	}
+
+	conn, err := s.connStore.waitForConn(connInfo.ID, defConnTimeout)
+	if err != nil {
+		s.logger.Warningf("Rejecting data stream of %s: %v", extractRemoteAddress(stream), err)
+		return err
+	}
+	s.logger.Debug("Servicing data stream of", extractRemoteAddress(stream))
+	return conn.serviceDataStream(stream)
+}
+
 // Ping is used to probe a remote peer's aliveness
-func (s *Server) Ping(context.Context, *types.Empty) (*types.Empty, error) {
-	return &types.Empty{}, nil
+func (s *Server) Ping(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
 }
 
 func (s *Server) disconnect(pkiID common.PKIidType) {
@@ -437,9 +662,20 @@ func (s *Server) disconnect(pkiID common.PKIidType) {
 	s.connStore.closeByPKIid(pkiID)
 }
 
+// authenticateConnectingPeer authenticates a peer which opened a stream,
+// unless the handshakes are throttled
+func (s *Server) authenticateConnectingPeer(stream stream) (*protos.ConnectionInfo, error) {
+	release, err := s.throttle.acquire(extractRemoteAddress(stream), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.authenticateRemotePeer(stream)
+}
+
 func (s *Server) authenticateRemotePeer(stream stream) (*protos.ConnectionInfo, error) {
 	remoteAddress := extractRemoteAddress(stream)
-	logging.Debugf("Remote address: %s", remoteAddress)
+	s.logger.Debugf("Remote address: %s", remoteAddress)
 
 	var err error
 	var cMsg *protos.SignedRKSyncMessage
@@ -448,55 +684,76 @@ func (s *Server) authenticateRemotePeer(stream stream) (*protos.ConnectionInfo,
 		return s.idMapper.Sign(msg)
 	}
 
-	cMsg, err = s.createConnectionMsg(s.pkiID, s.peerIdentity, signer)
+	cMsg, err = s.createConnectionMsg(s.pkiID, s.idMapper.SelfIdentity(), signer)
 	if err != nil {
 		return nil, err
 	}
 
-	logging.Debug("Sending", cMsg, "to", remoteAddress)
+	s.logger.Debug("Sending", cMsg, "to", remoteAddress)
 	stream.Send(cMsg.Envelope)
 	m, err := readWithTimeout(stream, defConnTimeout, remoteAddress)
 	if err != nil {
-		logging.Warningf("Failed reading message from %s, reason %v", remoteAddress, err)
+		s.logger.Warningf("Failed reading message from %s, reason %v", remoteAddress, err)
 		return nil, err
 	}
 
 	receivedMsg := m.GetConn()
 	if receivedMsg == nil {
-		logging.Warning("Expected connection message from", remoteAddress, "but got", receivedMsg)
+		s.logger.Warning("Expected connection message from", remoteAddress, "but got", receivedMsg)
 		return nil, errors.New("Wrong type")
 	}
 
 	if receivedMsg.PkiId == nil {
-		logging.Warningf("%s didn't send a pkiID", remoteAddress)
+		s.logger.Warningf("%s didn't send a pkiID", remoteAddress)
 		return nil, errors.New("No PKI-ID")
 	}
 
-	logging.Debug("Received", receivedMsg, "from", remoteAddress)
+	s.logger.Debug("Received", receivedMsg, "from", remoteAddress)
 	err = s.idMapper.Put(receivedMsg.PkiId, receivedMsg.Identity)
 	if err != nil {
-		logging.Warningf("Identity store rejected %s: %v", remoteAddress, err)
+		s.logger.Warningf("Identity store rejected %s: %v", remoteAddress, err)
 		return nil, err
 	}
-
-	connInfo := &protos.ConnectionInfo{
-		ID:       receivedMsg.PkiId,
-		Identity: receivedMsg.Identity,
-		Endpoint: remoteAddress,
+	if stored, err := s.idMapper.Get(receivedMsg.PkiId); err == nil && !bytes.Equal(stored, receivedMsg.Identity) {
+		// The peer presents a renewed identity, e.g. a rotated X.509 SVID, it must have signed the connection message
+		err = s.idMapper.Renew(receivedMsg.PkiId, receivedMsg.Identity, m.Envelope.SignatureAlgorithm, m.Envelope.Signature, m.Envelope.Payload)
+		if err != nil {
+			s.logger.Debugf("Kept the stored identity of %s: %v", remoteAddress, err)
+		}
 	}
 
-	verifier := func(peerIdentity []byte, signature, message []byte) error {
+	verifier := func(peerIdentity []byte, algorithm protos.SignatureAlgorithm, signature, message []byte) error {
 		pkiID := s.idMapper.GetPKIidOfCert(common.PeerIdentityType(peerIdentity))
-		return s.idMapper.Verify(pkiID, signature, message)
+		return s.idMapper.Verify(pkiID, algorithm, signature, message)
 	}
 
 	err = m.Verify(receivedMsg.Identity, verifier)
 	if err != nil {
-		logging.Errorf("Failed verifying signature from %s: %v", remoteAddress, err)
+		s.logger.Errorf("Failed verifying signature from %s: %v", remoteAddress, err)
 		return nil, err
 	}
 
-	logging.Debug("Authenticated", remoteAddress)
+	version, err := negotiateProtocolVersion(s.connStore.poolConf.MinProtocolVersion, s.version, receivedMsg)
+	if err != nil {
+		s.logger.Warningf("Refusing connection with %s: %v", remoteAddress, err)
+		return nil, err
+	}
+
+	connInfo := &protos.ConnectionInfo{
+		ID:              receivedMsg.PkiId,
+		Identity:        receivedMsg.Identity,
+		Endpoint:        remoteAddress,
+		ProtocolVersion: version,
+	}
+
+	if admit := s.connStore.poolConf.Admit; admit != nil {
+		if err := admit(connInfo.ID, connInfo.Identity); err != nil {
+			s.logger.Warningf("Refusing connection with %s: %v", remoteAddress, err)
+			return nil, err
+		}
+	}
+
+	s.logger.Debugf("Authenticated %s, signature algorithm: %s, protocol version: %d", remoteAddress, m.Envelope.SignatureAlgorithm, version)
 	return connInfo, nil
 }
 
@@ -505,8 +762,10 @@ func (s *Server) createConnectionMsg(pkiID common.PKIidType, cert common.PeerIde
 		Tag: protos.RKSyncMessage_EMPTY,
 		Content: &protos.RKSyncMessage_Conn{
 			Conn: &protos.ConnEstablish{
-				Identity: cert,
-				PkiId:    pkiID,
+				Identity:           cert,
+				PkiId:              pkiID,
+				ProtocolVersion:    s.version,
+				MinProtocolVersion: s.connStore.poolConf.MinProtocolVersion,
 			},
 		},
 	}
@@ -514,7 +773,7 @@ func (s *Server) createConnectionMsg(pkiID common.PKIidType, cert common.PeerIde
 	sMsg := &protos.SignedRKSyncMessage{
 		RKSyncMessage: m,
 	}
-	_, err := sMsg.Sign(signer)
+	_, err := sMsg.SignWithAlgorithm(s.idMapper.SignatureAlgorithm(), signer)
 	return sMsg, errors.WithStack(err)
 }
 
@@ -522,6 +781,15 @@ func (s *Server) isStopping() bool {
 	return atomic.LoadInt32(&s.stopping) == int32(1)
 }
 
+// endpointDialOptions returns the dial options specific to the endpoint,
+// WebSocket URLs are dialed over WebSocket whatever the transport of the peer
+func endpointDialOptions(endpoint string) []grpc.DialOption {
+	if server.IsWebSocketURL(endpoint) {
+		return []grpc.DialOption{grpc.WithContextDialer(server.DialWebSocket)}
+	}
+	return nil
+}
+
 func extractRemoteAddress(stream stream) string {
 	var remoteAddress string
 	p, ok := peer.FromContext(stream.Context())
@@ -533,31 +801,21 @@ func extractRemoteAddress(stream stream) string {
 	return remoteAddress
 }
 
-func readWithTimeout(stream interface{}, timeout time.Duration, address string) (*protos.SignedRKSyncMessage, error) {
+func readWithTimeout(stream stream, timeout time.Duration, address string) (*protos.SignedRKSyncMessage, error) {
 	incChan := make(chan *protos.SignedRKSyncMessage, 1)
 	errChan := make(chan error, 1)
 	go func() {
-		if srvStr, isServerStr := stream.(protos.RKSync_SyncStreamServer); isServerStr {
-			if m, err := srvStr.Recv(); err == nil {
-				msg, err := m.ToRKSyncMessage()
-				if err != nil {
-					errChan <- err
-					return
-				}
-				incChan <- msg
-			}
-		} else if clStr, isClientStr := stream.(protos.RKSync_SyncStreamClient); isClientStr {
-			if m, err := clStr.Recv(); err == nil {
-				msg, err := m.ToRKSyncMessage()
-				if err != nil {
-					errChan <- err
-					return
-				}
-				incChan <- msg
-			}
-		} else {
-			panic(errors.Errorf("Stream isn't a SyncStreamServer or a SyncStreamClient, but %v, Aborting", reflect.TypeOf(stream)))
+		m, err := stream.Recv()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		msg, err := m.ToRKSyncMessage()
+		if err != nil {
+			errChan <- err
+			return
 		}
+		incChan <- msg
 	}()
 
 	select {
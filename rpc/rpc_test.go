@@ -15,16 +15,17 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/server"
 	"github.com/rkcloudchain/rksync/tests/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 func init() {
@@ -68,6 +69,12 @@ func TestSendWithAck(t *testing.T) {
 	for i := 0; i < msgNum; i++ {
 		<-inc2
 	}
+
+	// The message which couldn't be sent is reported without waiting for the acknowledgement
+	res := inst1.SendWithAck(createRKSyncMessage(), time.Minute, 1, &common.NetworkMember{Endpoint: "localhost:1", PKIID: common.PKIidType("unreachable")})
+	require.Len(t, res, 1)
+	assert.Equal(t, 1, res.NackCount())
+	assert.NotEqual(t, "timed out", res[0].Error())
 }
 
 func TestGetConnectionInfo(t *testing.T) {
@@ -112,6 +119,29 @@ func TestHandshake(t *testing.T) {
 	assert.Equal(t, "peer1.org2", sid.NodeId)
 }
 
+func TestHandshakeOverWebSocket(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6057", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6058", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+
+	lis, err := server.ListenWebSocket("localhost:6059")
+	require.NoError(t, err)
+	go inst2.gSrv.Serve(lis)
+
+	endpoint := "ws://localhost:6059" + server.WebSocketPath
+	id, err := inst1.Handshake(&common.NetworkMember{Endpoint: endpoint, PKIID: inst2.GetPKIid()})
+	require.NoError(t, err)
+	sid := &protos.SerializedIdentity{}
+	require.NoError(t, proto.Unmarshal(id, sid))
+	assert.Equal(t, "peer1.org2", sid.NodeId)
+
+	assert.NoError(t, inst1.Probe(&common.NetworkMember{Endpoint: endpoint, PKIID: inst2.GetPKIid()}))
+}
+
 func TestNonResponsivePing(t *testing.T) {
 	inst1, err := CreateRPCServer("localhost:9053", 0)
 	require.NoError(t, err)
@@ -190,7 +220,9 @@ func TestProbe(t *testing.T) {
 	defer inst2.Stop()
 
 	time.Sleep(1 * time.Second)
+	assert.Zero(t, inst1.Latency(inst2.GetPKIid()))
 	assert.NoError(t, inst1.Probe(&common.NetworkMember{Endpoint: "localhost:10053", PKIID: inst2.GetPKIid()}))
+	assert.True(t, inst1.Latency(inst2.GetPKIid()) > 0)
 
 	_, err = inst1.Handshake(&common.NetworkMember{Endpoint: "localhost:10053", PKIID: inst2.GetPKIid()})
 	assert.NoError(t, err)
@@ -257,6 +289,84 @@ func TestBasic(t *testing.T) {
 	waitForMessage(t, out, 2, "Didn't receive messages")
 }
 
+func TestSendMarshaledOnce(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6062", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6063", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+
+	inst3, err := CreateRPCServer("localhost:6064", 2)
+	require.NoError(t, err)
+	defer inst3.Stop()
+
+	// The relayed envelope is received as it was sent by its origin
+	msg := createRKSyncMessage()
+	msg.Envelope.HopsLeft = 3
+	marshaled, err := msg.Envelope.Marshaled()
+	require.NoError(t, err)
+	assert.Equal(t, proto.Size(msg.Envelope), marshaled.Size())
+
+	out := make(chan *protos.Envelope, 2)
+	reader := func(ch <-chan protos.ReceivedMessage) {
+		m := <-ch
+		out <- m.GetSourceEnvelope()
+	}
+	go reader(inst2.Accept(func(msg interface{}) bool { return true }))
+	go reader(inst3.Accept(func(msg interface{}) bool { return true }))
+
+	inst1.Send(msg,
+		&common.NetworkMember{Endpoint: "localhost:6063", PKIID: inst2.GetPKIid()},
+		&common.NetworkMember{Endpoint: "localhost:6064", PKIID: inst3.GetPKIid()})
+	for i := 0; i < 2; i++ {
+		select {
+		case envp := <-out:
+			assert.Equal(t, msg.Envelope.Payload, envp.Payload)
+			assert.Equal(t, msg.Envelope.Signature, envp.Signature)
+			assert.Equal(t, uint32(3), envp.HopsLeft)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Didn't receive messages")
+		}
+	}
+}
+
+func TestDataStreamAttached(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6055", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6056", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+
+	m2 := inst2.Accept(func(msg interface{}) bool {
+		return msg.(protos.ReceivedMessage).GetRKSyncMessage().IsDataMsg()
+	})
+
+	dataMsg, err := (&protos.RKSyncMessage{
+		Nonce:   uint64(rand.Int()),
+		Content: &protos.RKSyncMessage_DataMsg{DataMsg: &protos.DataMessage{FileName: "rfc2616.txt"}},
+	}).NoopSign()
+	require.NoError(t, err)
+	inst1.Send(dataMsg, &common.NetworkMember{Endpoint: "localhost:6056", PKIID: inst2.GetPKIid()})
+
+	select {
+	case <-time.After(time.Second * 5):
+		t.Fatal("Didn't receive the data message in time")
+	case msg := <-m2:
+		assert.Equal(t, "rfc2616.txt", msg.GetRKSyncMessage().GetDataMsg().FileName)
+	}
+
+	conn1, err := inst1.connStore.waitForConn(inst2.GetPKIid(), time.Second)
+	require.NoError(t, err)
+	conn2, err := inst2.connStore.waitForConn(inst1.GetPKIid(), time.Second)
+	require.NoError(t, err)
+	assert.NotNil(t, conn1.getDataStream())
+	assert.NotNil(t, conn2.getDataStream())
+}
+
 func waitForMessage(t *testing.T, msgChan <-chan uint64, count int, errMsg string) {
 	c := 0
 	waiting := true
@@ -307,7 +417,7 @@ func CreateRPCServer(address string, num int) (*Server, error) {
 		return nil, err
 	}
 
-	idMapper, err := identity.NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	idMapper, err := identity.NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	if err != nil {
 		return nil, err
 	}
@@ -321,7 +431,7 @@ func CreateRPCServer(address string, num int) (*Server, error) {
 
 	rpcSrv := NewServer(srv.Server(), idMapper, selfIdentity, func() []grpc.DialOption {
 		return []grpc.DialOption{grpc.WithInsecure()}
-	})
+	}, SendBufferConfig{}, ConnPoolConfig{}, BreakerConfig{}, logging.Default())
 	go srv.Start()
 
 	return rpcSrv, nil
@@ -0,0 +1,103 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// TLSInfo describes the TLS session negotiated with a remote peer
+type TLSInfo struct {
+	Version            string // e.g. "TLS 1.3"
+	CipherSuite        string // e.g. "TLS_AES_128_GCM_SHA256"
+	ServerName         string // Server name indicated by the client, if any
+	NegotiatedProtocol string // Application protocol negotiated with ALPN, if any
+	PeerCertificate    string // Subject of the certificate presented by the peer, if any
+}
+
+// QueueDepth is the number of messages waiting in a queue
+type QueueDepth struct {
+	Name     string // e.g. "send/<pki-id>/control", "subscriber/0"
+	Length   int
+	Capacity int // 0 if the queue is unbounded
+}
+
+// trafficStats counts the bytes and the messages sent and received through a connection
+type trafficStats struct {
+	sync.Mutex
+	bytesIn     uint64
+	bytesOut    uint64
+	messagesIn  map[string]uint64
+	messagesOut map[string]uint64
+}
+
+func newTrafficStats() *trafficStats {
+	return &trafficStats{
+		messagesIn:  make(map[string]uint64),
+		messagesOut: make(map[string]uint64),
+	}
+}
+
+// received records a message of the given content type received from the peer
+func (t *trafficStats) received(contentType string, size int) {
+	t.Lock()
+	defer t.Unlock()
+	t.bytesIn += uint64(size)
+	t.messagesIn[contentType]++
+}
+
+// sent records a message of the given content type sent to the peer
+func (t *trafficStats) sent(contentType string, size int) {
+	t.Lock()
+	defer t.Unlock()
+	t.bytesOut += uint64(size)
+	t.messagesOut[contentType]++
+}
+
+// fill copies the counters into the connection statistics
+func (t *trafficStats) fill(stats *ConnectionStats) {
+	t.Lock()
+	defer t.Unlock()
+	stats.BytesIn = t.bytesIn
+	stats.BytesOut = t.bytesOut
+	stats.MessagesIn = make(map[string]uint64, len(t.messagesIn))
+	for contentType, n := range t.messagesIn {
+		stats.MessagesIn[contentType] = n
+	}
+	stats.MessagesOut = make(map[string]uint64, len(t.messagesOut))
+	for contentType, n := range t.messagesOut {
+		stats.MessagesOut[contentType] = n
+	}
+}
+
+// tlsInfo returns the TLS session of the stream, nil if it isn't secured by TLS
+func tlsInfo(s stream) *TLSInfo {
+	p, ok := peer.FromContext(s.Context())
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+
+	state := info.State
+	res := &TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) > 0 {
+		res.PeerCertificate = state.PeerCertificates[0].Subject.String()
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConnectionStats(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6070", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6071", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+
+	received := inst2.Accept(func(msg interface{}) bool { return true })
+	member := &common.NetworkMember{Endpoint: "localhost:6071", PKIID: inst2.GetPKIid()}
+	msg := createRKSyncMessage()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		inst1.Send(msg, member)
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Didn't receive the message")
+		}
+	}
+
+	stats := inst1.ConnectionStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, map[string]uint64{"alive_msg": 3}, stats[0].MessagesOut)
+	assert.Equal(t, uint64(3*proto.Size(msg.Envelope)), stats[0].BytesOut)
+	assert.False(t, stats[0].LastActivity.Before(start))
+	assert.Nil(t, stats[0].TLS)
+
+	stats = inst2.ConnectionStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, inst1.GetPKIid(), stats[0].PKIID)
+	assert.Equal(t, map[string]uint64{"alive_msg": 3}, stats[0].MessagesIn)
+	assert.Equal(t, uint64(3*proto.Size(msg.Envelope)), stats[0].BytesIn)
+}
+
+type ctxStream struct {
+	stream
+	ctx context.Context
+}
+
+func (s *ctxStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestTLSInfo(t *testing.T) {
+	assert.Nil(t, tlsInfo(&ctxStream{ctx: context.Background()}))
+
+	state := tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "peer1.org2",
+		NegotiatedProtocol: "h2",
+		PeerCertificates:   []*x509.Certificate{{Subject: pkix.Name{CommonName: "peer1.org2"}}},
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+	assert.Equal(t, &TLSInfo{
+		Version:            "TLS 1.3",
+		CipherSuite:        "TLS_AES_128_GCM_SHA256",
+		ServerName:         "peer1.org2",
+		NegotiatedProtocol: "h2",
+		PeerCertificate:    "CN=peer1.org2",
+	}, tlsInfo(&ctxStream{ctx: ctx}))
+}
@@ -0,0 +1,107 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	throttleWindow = time.Second
+	// throttleSweepInterval is the interval at which the addresses which didn't connect lately are forgotten
+	throttleSweepInterval = 10 * time.Second
+)
+
+type attemptWindow struct {
+	start    time.Time
+	attempts int
+}
+
+// handshakeThrottle bounds the handshakes of the connecting peers, which verify signatures,
+// so that probing the port can't exhaust the CPU: at most maxInflight handshakes are performed
+// concurrently, and at most rate connection attempts are accepted per second from an IP address.
+type handshakeThrottle struct {
+	inflight  chan struct{} // nil if the concurrent handshakes aren't limited
+	rate      int
+	lock      sync.Mutex
+	windows   map[string]*attemptWindow
+	nextSweep time.Time
+}
+
+func newHandshakeThrottle(maxInflight, rate int) *handshakeThrottle {
+	t := &handshakeThrottle{rate: rate, windows: make(map[string]*attemptWindow)}
+	if maxInflight > 0 {
+		t.inflight = make(chan struct{}, maxInflight)
+	}
+	return t
+}
+
+// acquire returns an error if a handshake with the peer connecting from the given address
+// must be refused, otherwise the function to call once the handshake is over
+func (t *handshakeThrottle) acquire(address string, now time.Time) (func(), error) {
+	if err := t.allow(address, now); err != nil {
+		return nil, err
+	}
+	if t.inflight == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case t.inflight <- struct{}{}:
+		return func() { <-t.inflight }, nil
+	default:
+		return nil, errors.Errorf("Too many handshakes in progress, max %d", cap(t.inflight))
+	}
+}
+
+// allow counts a connection attempt from the IP address, it returns an error once
+// more than rate attempts were made within the current window
+func (t *handshakeThrottle) allow(address string, now time.Time) error {
+	if t.rate <= 0 {
+		return nil
+	}
+	ip := address
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		ip = host
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.sweep(now)
+	w, exists := t.windows[ip]
+	if !exists {
+		w = &attemptWindow{}
+		t.windows[ip] = w
+	}
+	if now.Sub(w.start) >= throttleWindow {
+		w.start = now
+		w.attempts = 0
+	}
+	w.attempts++
+	if w.attempts > t.rate {
+		return errors.Errorf("Too many connection attempts from %s, max %d per second", ip, t.rate)
+	}
+	return nil
+}
+
+// sweep forgets the addresses whose window expired, at most every throttleSweepInterval
+func (t *handshakeThrottle) sweep(now time.Time) {
+	if now.Before(t.nextSweep) {
+		return
+	}
+	t.nextSweep = now.Add(throttleSweepInterval)
+	for ip, w := range t.windows {
+		if now.Sub(w.start) >= throttleWindow {
+			delete(t.windows, ip)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakeThrottleInflight(t *testing.T) {
+	throttle := newHandshakeThrottle(2, 0)
+	now := time.Now()
+
+	release1, err := throttle.acquire("10.0.0.1:7051", now)
+	require.NoError(t, err)
+	release2, err := throttle.acquire("10.0.0.2:7051", now)
+	require.NoError(t, err)
+	_, err = throttle.acquire("10.0.0.3:7051", now)
+	assert.Error(t, err)
+
+	release1()
+	release3, err := throttle.acquire("10.0.0.3:7051", now)
+	require.NoError(t, err)
+	release2()
+	release3()
+
+	unlimited := newHandshakeThrottle(0, 0)
+	for i := 0; i < 100; i++ {
+		_, err := unlimited.acquire("10.0.0.1:7051", now)
+		assert.NoError(t, err)
+	}
+}
+
+func TestHandshakeThrottleRate(t *testing.T) {
+	throttle := newHandshakeThrottle(0, 2)
+	now := time.Now()
+
+	// The attempts are counted by IP address, whatever the port
+	for _, address := range []string{"10.0.0.1:7051", "10.0.0.1:7052"} {
+		release, err := throttle.acquire(address, now)
+		require.NoError(t, err)
+		release()
+	}
+	_, err := throttle.acquire("10.0.0.1:7053", now.Add(500*time.Millisecond))
+	assert.Error(t, err)
+	_, err = throttle.acquire("10.0.0.2:7051", now)
+	assert.NoError(t, err)
+
+	_, err = throttle.acquire("10.0.0.1:7051", now.Add(time.Second))
+	assert.NoError(t, err)
+
+	// The addresses are forgotten once their window expired
+	assert.Len(t, throttle.windows, 2)
+	_, err = throttle.acquire("10.0.0.3:7051", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, throttle.windows, 1)
+}
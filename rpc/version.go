@@ -0,0 +1,34 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync/protos"
+)
+
+// negotiateProtocolVersion returns the highest version of the protocol spoken by both peers,
+// given the versions the local peer speaks and the connection message of the remote peer.
+// The peers which predate the negotiation send neither version, they speak the legacy version only.
+func negotiateProtocolVersion(minVersion, maxVersion uint32, remote *protos.ConnEstablish) (uint32, error) {
+	version := maxVersion
+	if remote.ProtocolVersion < version {
+		version = remote.ProtocolVersion
+	}
+	if version < minVersion {
+		return 0, errors.Errorf("remote peer speaks version %d of the protocol, older than %d", remote.ProtocolVersion, minVersion)
+	}
+	if version < remote.MinProtocolVersion {
+		return 0, errors.Errorf("remote peer requires version %d of the protocol, newer than %d", remote.MinProtocolVersion, maxVersion)
+	}
+	return version, nil
+}
+
+// supports returns whether the peer of the connection understands the message
+func (conn *connection) supports(msg *protos.SignedRKSyncMessage) bool {
+	return msg.ProtocolVersion() <= conn.info.ProtocolVersion
+}
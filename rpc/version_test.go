@@ -0,0 +1,93 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		min, max             uint32
+		remoteMin, remoteMax uint32
+		expected             uint32
+		fails                bool
+	}{
+		{name: "same version", min: 0, max: 2, remoteMin: 0, remoteMax: 2, expected: 2},
+		{name: "older remote peer", min: 0, max: 2, remoteMin: 0, remoteMax: 1, expected: 1},
+		{name: "newer remote peer", min: 0, max: 1, remoteMin: 0, remoteMax: 2, expected: 1},
+		{name: "legacy remote peer", min: 0, max: 1, expected: 0},
+		{name: "legacy remote peer refused", min: 1, max: 1, fails: true},
+		{name: "remote peer refusing us", min: 0, max: 1, remoteMin: 2, remoteMax: 3, fails: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := negotiateProtocolVersion(tc.min, tc.max, &protos.ConnEstablish{
+				MinProtocolVersion: tc.remoteMin,
+				ProtocolVersion:    tc.remoteMax,
+			})
+			if tc.fails {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, version)
+		})
+	}
+}
+
+func TestLegacyPeer(t *testing.T) {
+	inst1, err := CreateRPCServer("localhost:6067", 0)
+	require.NoError(t, err)
+	defer inst1.Stop()
+
+	inst2, err := CreateRPCServer("localhost:6068", 1)
+	require.NoError(t, err)
+	defer inst2.Stop()
+	// the second peer predates the negotiation
+	inst2.version = protos.ProtocolVersionLegacy
+
+	received := inst2.Accept(func(msg interface{}) bool { return true })
+	member := &common.NetworkMember{Endpoint: "localhost:6068", PKIID: inst2.GetPKIid()}
+
+	// the messages it doesn't understand aren't sent to it
+	stateReq, err := (&protos.RKSyncMessage{
+		Tag:     protos.RKSyncMessage_CHAN_ONLY,
+		Content: &protos.RKSyncMessage_StateReq{StateReq: &protos.StateRequest{}},
+	}).NoopSign()
+	require.NoError(t, err)
+	inst1.Send(stateReq, member)
+	msg := createRKSyncMessage()
+	inst1.Send(msg, member)
+	select {
+	case m := <-received:
+		assert.Equal(t, msg.Nonce, m.GetRKSyncMessage().Nonce)
+		assert.Equal(t, protos.ProtocolVersionLegacy, m.GetConnectionInfo().ProtocolVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Didn't receive the message")
+	}
+
+	stats := inst1.ConnectionStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, protos.ProtocolVersionLegacy, stats[0].ProtocolVersion)
+
+	// once the upgrade is over, the legacy peers are refused
+	inst3, err := CreateRPCServer("localhost:6069", 2)
+	require.NoError(t, err)
+	defer inst3.Stop()
+	inst3.connStore.poolConf.MinProtocolVersion = protos.ProtocolVersion
+	_, err = inst3.Handshake(member)
+	assert.Error(t, err)
+	_, err = inst3.Handshake(&common.NetworkMember{Endpoint: "localhost:6067", PKIID: inst1.GetPKIid()})
+	assert.NoError(t, err)
+}
@@ -7,27 +7,31 @@ SPDX-License-Identifier: Apache-2.0
 package rksync
 
 import (
+	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/channel"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/creds"
 	"github.com/rkcloudchain/rksync/gossip"
+	"github.com/rkcloudchain/rksync/identity"
 	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/rpc"
 	"github.com/rkcloudchain/rksync/server"
 	"github.com/rkcloudchain/rksync/util"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -41,29 +45,47 @@ func Serve(l net.Listener, cfg *config.Config) (*Server, error) {
 		cfg.HomeDir = config.DefaultHomeDir
 	}
 	if cfg.Gossip == nil {
-		return nil, errors.New("Gossip configuration cannot be nil")
+		return nil, validationErrorf("Gossip configuration cannot be nil")
 	}
 	if cfg.Identity == nil {
-		return nil, errors.New("Identity configuration cannot be nil")
+		return nil, validationErrorf("Identity configuration cannot be nil")
 	}
 	err := validateGossipConfig(cfg.Gossip)
 	if err != nil {
-		return nil, err
+		return nil, newError(CodeValidation, err)
+	}
+	if cfg.Identity.PKCS11 != nil {
+		if err := identity.ValidatePKCS11Config(cfg.Identity.PKCS11); err != nil {
+			return nil, newError(CodeValidation, err)
+		}
 	}
 
-	srv := &Server{cfg: cfg}
+	if cfg.Gossip.ChangeLogDir == "" {
+		cfg.Gossip.ChangeLogDir = filepath.Join(cfg.HomeDir, "changelog")
+	}
+	if cfg.Gossip.JournalDir == "" {
+		cfg.Gossip.JournalDir = filepath.Join(cfg.HomeDir, "journal")
+	}
+	if cfg.Gossip.MembershipFile == "" {
+		cfg.Gossip.MembershipFile = filepath.Join(cfg.HomeDir, "membership.json")
+	}
+	if cfg.Gossip.InvitationsFile == "" {
+		cfg.Gossip.InvitationsFile = filepath.Join(cfg.HomeDir, "invitations.json")
+	}
+
+	srv := &Server{cfg: cfg, logger: logging.ForModule(cfg.Gossip.Logger, "rksync", cfg.Gossip.LogLevels)}
 
 	srv.chainFilePath = filepath.Join(srv.cfg.HomeDir, "channels")
 	if s, err := os.Stat(srv.chainFilePath); err != nil {
 		if os.IsNotExist(err) {
 			if err := os.MkdirAll(srv.chainFilePath, 0755); err != nil {
-				return nil, errors.Errorf("Could not create chain file path: %s", err)
+				return nil, newError(CodeInternal, errors.Errorf("Could not create chain file path: %s", err))
 			}
 		} else {
-			return nil, errors.Errorf("Could not stat chain file path: %s", err)
+			return nil, newError(CodeInternal, errors.Errorf("Could not stat chain file path: %s", err))
 		}
 	} else if !s.IsDir() {
-		return nil, errors.Errorf("RKSync chain file path exists but not a dir: %s", srv.chainFilePath)
+		return nil, validationErrorf("RKSync chain file path exists but not a dir: %s", srv.chainFilePath)
 	}
 
 	if srv.cfg.Server == nil {
@@ -73,36 +95,90 @@ func Serve(l net.Listener, cfg *config.Config) (*Server, error) {
 		}
 	}
 
-	if cfg.Server.SecOpts.UseTLS {
-		srv.clientCreds, err = clientTransportCredentials(srv.cfg)
-		if err != nil {
-			return nil, errors.Errorf("Failed to set TLS client certificate (%s)", err)
-		}
+	srv.creds, err = creds.New(srv.cfg.Server)
+	if err != nil {
+		return nil, validationErrorf("Failed to set TLS credentials (%s)", err)
 	}
 
 	srv.selfIdentity, err = serializeIdentity(cfg.Identity, cfg.HomeDir)
 	if err != nil {
-		return nil, errors.Errorf("Failed serializing self identity: %v", err)
+		return nil, validationErrorf("Failed serializing self identity: %v", err)
 	}
 
-	grpcServer, err := server.NewGRPCServerFromListener(l, srv.cfg.Server)
+	srv.creds.SetMaxMessageSize(cfg.Gossip.MaxMessageSize)
+	grpcServer, err := server.NewGRPCServerWithCredentials(l, srv.creds)
 	if err != nil {
-		logging.Errorf("Failed to create grpc server (%s)", err)
-		return nil, err
+		srv.logger.Errorf("Failed to create grpc server (%s)", err)
+		return nil, classify(err)
 	}
 
-	srv.gossip, err = gossip.NewGossipService(srv.cfg.Gossip, srv.cfg.Identity, grpcServer.Server(), srv.selfIdentity, func() []grpc.DialOption {
-		return srv.secureDialOpts(srv.cfg.Server)
-	})
+	// peers using the QUIC transport keep accepting TCP connections, and dial
+	// over TCP the peers they can't dial over QUIC, so they can be mixed with TCP peers
+	var listeners []net.Listener
+	if cfg.Gossip.Transport == config.TransportQUIC {
+		quicListener, err := server.ListenQUIC(l.Addr().String())
+		if err != nil {
+			return nil, newError(CodeUnavailable, errors.Errorf("Failed to create QUIC listener (%s)", err))
+		}
+		listeners = append(listeners, quicListener)
+		srv.creds.SetDialer(server.NewQUICDialer(cfg.Gossip.QUICHandshakeTimeout, cfg.Gossip.QUICFallbackPeriod).Dial)
+	}
+	if cfg.Gossip.Transport == config.TransportMemory {
+		srv.creds.SetDialer(server.DialMemory)
+	}
+	if cfg.Gossip.WebSocketAddress != "" {
+		wsListener, err := server.ListenWebSocket(cfg.Gossip.WebSocketAddress)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, newError(CodeUnavailable, errors.Errorf("Failed to create WebSocket listener (%s)", err))
+		}
+		listeners = append(listeners, wsListener)
+	}
+
+	srv.gossip, err = gossip.NewGossipService(srv.cfg.Gossip, srv.cfg.Identity, grpcServer.Server(), srv.selfIdentity,
+		gossip.WithSecureDialOpts(srv.creds.DialOptions), gossip.WithMemberAcceptedHandler(srv.memberAccepted))
 	if err != nil {
-		return nil, errors.Errorf("Failed creating RKSync service (%s)", err)
+		closeListeners(listeners)
+		return nil, newError(CodeInternal, errors.Errorf("Failed creating RKSync service (%s)", err))
+	}
+	// the admin service isn't authorized beyond TLS, it's served apart
+	// from the gossip endpoint, on the local host by default
+	if srv.cfg.Gossip.AdminService {
+		srv.admin, err = serveAdmin(cfg.Gossip.AdminAddress, srv.creds)
+		if err != nil {
+			closeListeners(listeners)
+			srv.gossip.Stop()
+			return nil, newError(CodeUnavailable, errors.Errorf("Failed to serve the admin service (%s)", err))
+		}
+		srv.gossip.RegisterAdminServer(srv.admin.Server(), srv)
+		go func() {
+			if err := srv.admin.Start(); err != nil {
+				srv.logger.Errorf("admin server exited with error: %s", err)
+			}
+		}()
+	}
+	if cfg.Gossip.ProfilingAddress != "" {
+		srv.profiling, err = server.ServeProfiling(cfg.Gossip.ProfilingAddress)
+		if err != nil {
+			closeListeners(listeners)
+			srv.stopLocalServers()
+			srv.gossip.Stop()
+			return nil, newError(CodeUnavailable, errors.Errorf("Failed to serve the pprof handlers (%s)", err))
+		}
 	}
 
 	go func() {
 		if err := grpcServer.Start(); err != nil {
-			logging.Errorf("grpc server exited with error: %s", err)
+			srv.logger.Errorf("grpc server exited with error: %s", err)
 		}
 	}()
+	for _, lis := range listeners {
+		go func(lis net.Listener) {
+			if err := grpcServer.Server().Serve(lis); err != nil {
+				srv.logger.Errorf("grpc server exited with error on %s: %s", lis.Addr(), err)
+			}
+		}(lis)
+	}
 
 	go srv.initializeChannel()
 	return srv, nil
@@ -114,35 +190,225 @@ type Server struct {
 	cfg           *config.Config
 	chainFilePath string
 	selfIdentity  common.PeerIdentityType
-	clientCreds   credentials.TransportCredentials
+	creds         *creds.Credentials
+	admin         *server.GRPCServer      // nil unless the admin service is served
+	profiling     *server.ProfilingServer // nil unless the pprof handlers are served
+	logger        logging.Logger
 }
 
 // Stop the rksync service
 func (srv *Server) Stop() {
+	srv.stopLocalServers()
 	if srv.gossip != nil {
 		srv.gossip.Stop()
-		logging.Infof("RKSync %s server exited", srv.cfg.Identity.ID)
+		srv.logger.Infof("RKSync %s server exited", srv.cfg.Identity.ID)
+	}
+}
+
+// Done returns a channel closed once the rksync service starts stopping, after Stop or Drain is called
+func (srv *Server) Done() <-chan struct{} {
+	return srv.gossip.Done()
+}
+
+// Drain waits for the file transfers in progress to finish, or the context
+// to expire, before stopping the rksync service
+func (srv *Server) Drain(ctx context.Context) error {
+	srv.stopLocalServers()
+	if srv.gossip == nil {
+		return nil
+	}
+	err := srv.gossip.Drain(ctx)
+	srv.logger.Infof("RKSync %s server exited", srv.cfg.Identity.ID)
+	return classify(err)
+}
+
+// serveAdmin creates the gRPC server of the admin service, with the TLS settings of the gossip endpoint
+func serveAdmin(address string, c *creds.Credentials) (*server.GRPCServer, error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s, err := server.NewGRPCServerWithCredentials(lis, c)
+	if err != nil {
+		lis.Close()
+	}
+	return s, err
+}
+
+// stopLocalServers stops serving the admin service and the pprof handlers
+func (srv *Server) stopLocalServers() {
+	if srv.admin != nil {
+		srv.admin.Stop()
+	}
+	if srv.profiling != nil {
+		srv.profiling.Close()
 	}
 }
 
+// Events returns a channel receiving the events of the rksync service, such as
+// file synchronizations and membership changes, and a function cancelling the subscription
+func (srv *Server) Events() (<-chan common.Event, func()) {
+	return srv.gossip.Events()
+}
+
+// AcceptChannelMessages subscribes to the messages of the given types received in the channel,
+// e.g. gossip.ChainStateMessage, decoded. All of them are accepted if no type is given.
+// It returns a function cancelling the subscription.
+func (srv *Server) AcceptChannelMessages(chainID string, types ...gossip.ChannelMessageType) (<-chan *gossip.ChannelMessage, func(), error) {
+	ch, cancel, err := srv.gossip.AcceptChannelMessages(chainID, types...)
+	return ch, cancel, classify(err)
+}
+
+// Channels returns the summaries of the channels the rksync service takes part in
+func (srv *Server) Channels() []gossip.ChannelSummary {
+	return srv.gossip.Channels()
+}
+
+// ChannelFiles returns the files of the channel sorted by name, along with the synchronization state of their local copy
+func (srv *Server) ChannelFiles(chainID string) ([]channel.FileState, error) {
+	files, err := srv.gossip.ChannelFiles(chainID)
+	return files, classify(err)
+}
+
+// ChannelSyncStatus returns the completion, lag and last acknowledgement of every file of the channel on every other member,
+// e.g. to check that a distribution is complete. The members acknowledge the files they synchronized to the leader,
+// only the leader of the channel reports it.
+func (srv *Server) ChannelSyncStatus(chainID string) ([]gossip.MemberSyncStatus, error) {
+	status, err := srv.gossip.ChannelSyncStatus(chainID)
+	return status, classify(err)
+}
+
+// FileVersions returns the versions of a file of the channel observed by the rksync service, oldest first.
+// Only the latest versions are kept, along with the pinned ones.
+func (srv *Server) FileVersions(chainID, filename string) ([]channel.FileVersion, error) {
+	versions, err := srv.gossip.FileVersions(chainID, filename)
+	return versions, classify(err)
+}
+
+// PinFileVersion keeps a version of a file of the channel in its history, e.g. for audit purposes
+func (srv *Server) PinFileVersion(chainID, filename string, version uint64) error {
+	return classify(srv.gossip.PinFileVersion(chainID, filename, version))
+}
+
+// UnpinFileVersion lets a pinned version of a file of the channel be discarded from its history
+func (srv *Server) UnpinFileVersion(chainID, filename string, version uint64) error {
+	return classify(srv.gossip.UnpinFileVersion(chainID, filename, version))
+}
+
+// ChannelChangeLog returns the records of the membership and file changes of the channel, oldest first.
+// Every record is signed by the leader and chained to the previous one.
+func (srv *Server) ChannelChangeLog(chainID string) ([]channel.Change, error) {
+	changes, err := srv.gossip.ChangeLog(chainID)
+	return changes, classify(err)
+}
+
+// VerifyChannelChangeLog verifies the change log of the channel, and that replaying it leads to the current members and files
+func (srv *Server) VerifyChannelChangeLog(chainID string) error {
+	return classify(srv.gossip.VerifyChangeLog(chainID))
+}
+
+// SetChannelPropagation overrides the number of peers the chain states of the channel are pushed to,
+// and the number of times they're pushed, e.g. to fan out small control channels wider than heavy data channels.
+// Zero values keep the gossip settings.
+func (srv *Server) SetChannelPropagation(chainID string, propagation config.Propagation) error {
+	if propagation.PeerNum < 0 || propagation.Iterations < 0 {
+		return validationErrorf("Propagation of channel %s can't be negative", chainID)
+	}
+	return classify(srv.gossip.SetChannelPropagation(chainID, propagation))
+}
+
+// ExportChannelSnapshot writes the signed state of the channel and the local copies of its files to w as a tar archive,
+// e.g. to seed a member at a bandwidth-constrained site from a removable drive. Only the leader of the channel exports it.
+func (srv *Server) ExportChannelSnapshot(chainID string, w io.Writer) error {
+	if chainID == "" {
+		return validationErrorf("Channel ID must be provided")
+	}
+	return classify(srv.gossip.ExportChannelSnapshot(chainID, w))
+}
+
+// ImportChannelSnapshot joins the channel of a snapshot written by ExportChannelSnapshot, the peer must be a member
+// of the channel. The local copies of the files are seeded with the snapshot, then synchronized incrementally.
+func (srv *Server) ImportChannelSnapshot(r io.Reader) error {
+	chainState, err := srv.gossip.ImportChannelSnapshot(r)
+	if err != nil {
+		return classify(err)
+	}
+
+	stateInfo, err := chainState.GetChainStateInfo()
+	if err != nil {
+		return classify(err)
+	}
+	// The leader initializes its channels from their config file when it restarts
+	if !bytes.Equal(stateInfo.Leader, srv.gossip.SelfPKIid()) {
+		return nil
+	}
+	return classify(srv.rewriteChainConfigFile(channel.GenerateMAC(stateInfo.Leader, chainState.ChainId), chainState))
+}
+
+// BlockPeer refuses the connections and the messages of the peer with the given hex encoded PKI-ID or node ID,
+// e.g. when its key is suspected to be compromised. Its connections are closed and it's excluded from the membership.
+func (srv *Server) BlockPeer(id string) {
+	srv.gossip.BlockPeer(id)
+}
+
+// UnblockPeer accepts the peer with the given hex encoded PKI-ID or node ID again
+func (srv *Server) UnblockPeer(id string) {
+	srv.gossip.UnblockPeer(id)
+}
+
+// SuspectPeers revalidates the identities of the peers the predicate selects against the CA certificates
+// and the CRLs of the home directory, reloaded e.g. after a CA compromise or a root rotation.
+// The connections of the peers whose identity is no longer valid are closed.
+func (srv *Server) SuspectPeers(isSuspected func(common.PeerIdentityType) bool) {
+	srv.gossip.SuspectPeers(isSuspected)
+}
+
+// ConnectionStats returns the statistics of the connections to the remote peers:
+// the traffic by message type, the last activity, the round-trip time and the TLS session.
+func (srv *Server) ConnectionStats() []rpc.ConnectionStats {
+	return srv.gossip.ConnectionStats()
+}
+
+// RegisterMessageValidator adds a rule the messages received from the peers must satisfy, e.g. a file
+// name policy or a max number of channel members. The messages the validator returns an error for are
+// discarded and reported as dead letters. A rule registered under the same name is replaced.
+func (srv *Server) RegisterMessageValidator(rule string, validator gossip.MessageValidator) {
+	srv.gossip.RegisterMessageValidator(rule, validator)
+}
+
+// RejectedMessages returns the number of messages rejected by each registered rule, by rule name
+func (srv *Server) RejectedMessages() map[string]uint64 {
+	return srv.gossip.RejectedMessages()
+}
+
 // CreateChannel creates a channel
 func (srv *Server) CreateChannel(chainID string, files []*common.FileSyncInfo) error {
-	logging.Debugf("Creating channel, ID: %s", chainID)
+	srv.logger.Debugf("Creating channel, ID: %s", chainID)
 
 	if err := validateChannelID(chainID); err != nil {
-		return errors.Errorf("Bad channel id: %s", err)
+		return validationErrorf("Bad channel id: %s", err)
+	}
+
+	if policy := srv.cfg.Gossip.AccessControl; policy != nil {
+		cert, err := util.GetX509CertificateFromPEM(srv.cfg.Identity.GetCertificate())
+		if err != nil {
+			return classify(err)
+		}
+		if err := policy.CanLead(chainID, config.PeerInfo{NodeID: srv.cfg.Identity.ID, Org: identity.CertificateOrg(cert), Certificate: cert}); err != nil {
+			return newError(CodeUnauthorized, errors.WithMessage(err, "Access denied"))
+		}
 	}
 
 	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
 	chainState, err := srv.gossip.CreateChain(mac, chainID, files)
 	if err != nil {
-		return err
+		return classify(err)
 	}
 
 	err = srv.rewriteChainConfigFile(mac, chainState)
 	if err != nil {
 		srv.gossip.CloseChain(mac, false)
-		return err
+		return classify(err)
 	}
 
 	return nil
@@ -151,143 +417,242 @@ func (srv *Server) CreateChannel(chainID string, files []*common.FileSyncInfo) e
 // CloseChannel closes an channel
 func (srv *Server) CloseChannel(chainID string) error {
 	if chainID == "" {
-		return errors.New("Channel ID must be provided")
+		return validationErrorf("Channel ID must be provided")
 	}
 
 	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
 	err := srv.gossip.CloseChain(mac, true)
 	if err != nil {
-		return err
+		return classify(err)
 	}
 
 	dir := filepath.Join(srv.chainFilePath, mac.String())
-	return os.RemoveAll(dir)
+	return classify(os.RemoveAll(dir))
 }
 
 // AddMemberToChan adds a member to the channel
 func (srv *Server) AddMemberToChan(chainID string, nodeID string, cert *x509.Certificate) error {
+	pkiID, err := srv.admitMember(chainID, nodeID, cert)
+	if err != nil {
+		return err
+	}
+
+	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
+	chainState, err := srv.gossip.AddMemberToChain(mac, pkiID)
+	if err != nil {
+		return classify(err)
+	}
+
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
+}
+
+// InviteMemberToChan invites a peer to become a member of the channel, it's added to the channel
+// once it accepted the invitation within InvitationTTL. The peer must be alive.
+func (srv *Server) InviteMemberToChan(chainID string, nodeID string, cert *x509.Certificate) error {
+	pkiID, err := srv.admitMember(chainID, nodeID, cert)
+	if err != nil {
+		return err
+	}
+
+	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
+	_, err = srv.gossip.InviteMember(mac, pkiID)
+	return classify(err)
+}
+
+// ChannelInvitations returns the invitations to join channels received by the peer and not answered yet
+func (srv *Server) ChannelInvitations() []gossip.Invitation {
+	return srv.gossip.Invitations()
+}
+
+// AnswerChannelInvitation accepts or declines the invitation to join the channel
+func (srv *Server) AnswerChannelInvitation(chainID string, accept bool) error {
+	if chainID == "" {
+		return validationErrorf("Channel ID must be provided")
+	}
+
+	var invitations []gossip.Invitation
+	for _, i := range srv.gossip.Invitations() {
+		if i.ChainID == chainID {
+			invitations = append(invitations, i)
+		}
+	}
+	switch len(invitations) {
+	case 0:
+		return classify(errors.Wrapf(gossip.ErrNoInvitation, "Channel %s", chainID))
+	case 1:
+		return classify(srv.gossip.AnswerInvitation(invitations[0].ChainMac, accept))
+	default:
+		return validationErrorf("Several leaders invited the peer to a channel %s", chainID)
+	}
+}
+
+// admitMember validates the member to add to the channel, and returns its PKI-ID
+func (srv *Server) admitMember(chainID string, nodeID string, cert *x509.Certificate) (common.PKIidType, error) {
 	if chainID == "" {
-		return errors.New("Channel ID must be provided")
+		return nil, validationErrorf("Channel ID must be provided")
 	}
 	if nodeID == "" {
-		return errors.New("Node ID must be provided")
+		return nil, validationErrorf("Node ID must be provided")
 	}
 	if cert == nil {
-		return errors.New("Node certificate must be provided")
+		return nil, validationErrorf("Node certificate must be provided")
 	}
 
 	pkiID, err := srv.gossip.GetPKIidOfCert(nodeID, cert)
 	if err != nil {
-		return err
+		return nil, newError(CodeValidation, err)
 	}
 
-	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
-	chainState, err := srv.gossip.AddMemberToChain(mac, pkiID)
-	if err != nil {
-		return err
+	if policy := srv.cfg.Gossip.AccessControl; policy != nil {
+		peer := config.PeerInfo{NodeID: nodeID, Org: identity.CertificateOrg(cert), Certificate: cert}
+		if err := policy.CanJoin(chainID, peer); err != nil {
+			return nil, newError(CodeUnauthorized, errors.WithMessage(err, "Access denied"))
+		}
 	}
+	return pkiID, nil
+}
 
-	return srv.rewriteChainConfigFile(mac, chainState)
+// memberAccepted persists the state of the channel an invited peer was added to
+func (srv *Server) memberAccepted(chainMac common.ChainMac, chainState *protos.ChainState) {
+	if err := srv.rewriteChainConfigFile(chainMac, chainState); err != nil {
+		srv.logger.Errorf("Failed persisting the state of channel %s: %s", chainState.ChainId, err)
+	}
 }
 
 // RemoveMemberWithChan removes member contained in the channel
 func (srv *Server) RemoveMemberWithChan(chainID string, nodeID string, cert *x509.Certificate) error {
 	if chainID == "" {
-		return errors.New("Channel ID must be provided")
+		return validationErrorf("Channel ID must be provided")
 	}
 	if nodeID == "" {
-		return errors.New("Node ID must be provided")
+		return validationErrorf("Node ID must be provided")
 	}
 	if cert == nil {
-		return errors.New("Node certificate must be provided")
+		return validationErrorf("Node certificate must be provided")
 	}
 
 	pkiID, err := srv.gossip.GetPKIidOfCert(nodeID, cert)
 	if err != nil {
-		return err
+		return newError(CodeValidation, err)
 	}
 
 	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
 	chainState, err := srv.gossip.RemoveMemberWithChain(mac, pkiID)
 	if err != nil {
-		return err
+		return classify(err)
 	}
 
-	return srv.rewriteChainConfigFile(mac, chainState)
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
 }
 
-// AddFileToChan adds a file to the channel
+// AddFileToChan adds files to the channel. The state of the channel is updated, signed
+// and propagated once for all the files, so adding files in bulk is cheaper than one by one.
 func (srv *Server) AddFileToChan(chainID string, files []*common.FileSyncInfo) error {
 	if chainID == "" {
-		return errors.New("Channel ID must be provided")
+		return validationErrorf("Channel ID must be provided")
 	}
 	if len(files) == 0 {
-		return errors.New("files can't be nil or empty")
+		return validationErrorf("files can't be nil or empty")
 	}
 
 	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
 	chainState, err := srv.gossip.AddFileToChain(mac, files)
 	if err != nil {
-		return err
+		return classify(err)
 	}
 
-	return srv.rewriteChainConfigFile(mac, chainState)
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
 }
 
-// RemoveFileWithChan removes file contained in the channel
+// RemoveFileWithChan removes files contained in the channel, updating its state once for all of them
 func (srv *Server) RemoveFileWithChan(chainID string, filenames []string) error {
 	if chainID == "" {
-		return errors.New("Channel ID must be provided")
+		return validationErrorf("Channel ID must be provided")
 	}
 	if len(filenames) == 0 {
-		return errors.New("files can't be nil or empty")
+		return validationErrorf("files can't be nil or empty")
 	}
 
 	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
 	chainState, err := srv.gossip.RemoveFileWithChain(mac, filenames)
 	if err != nil {
-		return err
+		return classify(err)
 	}
 
-	return srv.rewriteChainConfigFile(mac, chainState)
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
+}
+
+// PublishFileVersion publishes the current content of the file as a new version
+func (srv *Server) PublishFileVersion(chainID, filename string) error {
+	if chainID == "" {
+		return validationErrorf("Channel ID must be provided")
+	}
+	if filename == "" {
+		return validationErrorf("filename must be provided")
+	}
+
+	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
+	chainState, err := srv.gossip.PublishFileVersion(mac, filename)
+	if err != nil {
+		return classify(err)
+	}
+
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
+}
+
+// SetChannelAnchorPeers sets the endpoints of the channel's anchor peers.
+// Channel members connect to the anchor peers, which allows them to learn about
+// each other even if they are not in each other's bootstrap peers.
+func (srv *Server) SetChannelAnchorPeers(chainID string, endpoints []string) error {
+	if chainID == "" {
+		return validationErrorf("Channel ID must be provided")
+	}
+
+	mac := channel.GenerateMAC(srv.gossip.SelfPKIid(), chainID)
+	chainState, err := srv.gossip.SetAnchorPeers(mac, endpoints)
+	if err != nil {
+		return classify(err)
+	}
+
+	return classify(srv.rewriteChainConfigFile(mac, chainState))
 }
 
 func (srv *Server) initializeChannel() {
 	dirs, err := util.ListSubdirs(srv.chainFilePath)
 	if err != nil {
-		logging.Error(err.Error())
+		srv.logger.Error(err.Error())
 		return
 	}
 
 	for _, dir := range dirs {
 		path := filepath.Join(srv.chainFilePath, dir, "config.pb")
 		if _, err := os.Stat(path); err != nil {
-			logging.Errorf("Error reading channel %s config file: %s", dir, err)
+			srv.logger.Errorf("Error reading channel %s config file: %s", dir, err)
 			continue
 		}
 
 		csBytes, err := ioutil.ReadFile(path)
 		if err != nil {
-			logging.Errorf("Error reading channel %s config file: %s", dir, err)
+			srv.logger.Errorf("Error reading channel %s config file: %s", dir, err)
 			continue
 		}
 
 		chainState := &protos.ChainState{}
 		err = proto.Unmarshal(csBytes, chainState)
 		if err != nil {
-			logging.Errorf("Error unmarshalling channel %s state message: %s", dir, err)
+			srv.logger.Errorf("Error unmarshalling channel %s state message: %s", dir, err)
 			continue
 		}
 
 		mac, err := hex.DecodeString(dir)
 		if err != nil {
-			logging.Errorf("Error decoding directory string: %s", err)
+			srv.logger.Errorf("Error decoding directory string: %s", err)
 			continue
 		}
 
 		err = srv.gossip.InitializeChain(common.ChainMac(mac), chainState)
 		if err != nil {
-			logging.Errorf("Error initializing channel %s: %s", dir, err)
+			srv.logger.Errorf("Error initializing channel %s: %s", dir, err)
 		}
 	}
 }
@@ -320,23 +685,6 @@ func (srv *Server) rewriteChainConfigFile(chainMac common.ChainMac, chainState *
 	return nil
 }
 
-func (srv *Server) secureDialOpts(cfg *config.ServerConfig) []grpc.DialOption {
-	var dialOpts []grpc.DialOption
-	dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
-		grpc.MaxCallRecvMsgSize(config.MaxRecvMsgSize),
-		grpc.MaxCallSendMsgSize(config.MaxSendMsgSize),
-	))
-
-	dialOpts = append(dialOpts, config.ClientKeepaliveOptions(cfg.KaOpts)...)
-	if cfg.SecOpts.UseTLS {
-		dialOpts = append(dialOpts, grpc.WithTransportCredentials(srv.clientCreds))
-	} else {
-		dialOpts = append(dialOpts, grpc.WithInsecure())
-	}
-
-	return dialOpts
-}
-
 func serializeIdentity(cfg *config.IdentityConfig, homedir string) (common.PeerIdentityType, error) {
 	if cfg.ID == "" {
 		return nil, errors.New("Node id must be provided")
@@ -344,6 +692,19 @@ func serializeIdentity(cfg *config.IdentityConfig, homedir string) (common.PeerI
 	if err := cfg.MakeFilesAbs(homedir); err != nil {
 		return nil, errors.Wrap(err, "Failed to make identity file absolute")
 	}
+	if cfg.Provider != nil {
+		return cfg.Provider.Serialize()
+	}
+	if cfg.SPIFFE != nil {
+		if cfg.SPIFFE.Source == nil {
+			return nil, errors.New("Must specify the source of the X.509 SVIDs")
+		}
+		svid, err := cfg.SPIFFE.Source.GetX509SVID()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed fetching the X.509 SVID")
+		}
+		return identity.SerializeSVID(cfg.ID, cfg.Org, svid)
+	}
 
 	cert, err := util.GetX509CertificateFromPEM(cfg.GetCertificate())
 	if err != nil {
@@ -356,7 +717,7 @@ func serializeIdentity(cfg *config.IdentityConfig, homedir string) (common.PeerI
 		return nil, errors.New("Encoding of identity failed")
 	}
 
-	sID := &protos.SerializedIdentity{NodeId: cfg.ID, IdBytes: pemBytes}
+	sID := &protos.SerializedIdentity{NodeId: cfg.ID, Org: cfg.Org, IdBytes: pemBytes, IntermediateCerts: cfg.GetIntermediateCAs()}
 	idBytes, err := proto.Marshal(sID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not marshal a SerializedIdentity structure for identity %v", sID)
@@ -365,6 +726,12 @@ func serializeIdentity(cfg *config.IdentityConfig, homedir string) (common.PeerI
 	return idBytes, nil
 }
 
+func closeListeners(listeners []net.Listener) {
+	for _, lis := range listeners {
+		lis.Close()
+	}
+}
+
 func validateChannelID(chainID string) error {
 	re, _ := regexp.Compile(channelAllowedChars)
 	if len(chainID) <= 0 {
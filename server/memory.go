@@ -0,0 +1,147 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memoryBufferSize is the number of bytes buffered in each direction of an in-memory connection
+const memoryBufferSize = 256 * 1024
+
+// memoryListeners are the in-memory listeners of the process, by address
+var memoryListeners = struct {
+	sync.Mutex
+	byAddress map[string]*memoryListener
+}{byAddress: make(map[string]*memoryListener)}
+
+var memoryDialSeq uint64
+
+// ListenMemory returns a listener accepting the connections dialed with DialMemory
+// within the process, no socket is opened. The address is any string not already
+// listened to, so tests can run many peers without allocating ports.
+func ListenMemory(address string) (net.Listener, error) {
+	memoryListeners.Lock()
+	defer memoryListeners.Unlock()
+	if _, exists := memoryListeners.byAddress[address]; exists {
+		return nil, errors.Errorf("Failed listening on %s: address already in use", address)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &memoryListener{
+		addr:   memoryAddr(address),
+		conns:  make(chan net.Conn),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	memoryListeners.byAddress[address] = l
+	return l, nil
+}
+
+// DialMemory connects to the in-memory listener of the address
+func DialMemory(ctx context.Context, address string) (net.Conn, error) {
+	memoryListeners.Lock()
+	l, exists := memoryListeners.byAddress[address]
+	memoryListeners.Unlock()
+	if !exists {
+		return nil, errors.Errorf("Failed dialing %s: connection refused", address)
+	}
+
+	// bufconn buffers the data written to the connections,
+	// net.Pipe would block the HTTP/2 handshakes which write on both ends at once
+	pipe := bufconn.Listen(memoryBufferSize)
+	defer pipe.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		server, _ := pipe.Accept()
+		accepted <- server
+	}()
+	client, err := pipe.Dial()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed dialing %s", address)
+	}
+	server := <-accepted
+
+	local := memoryAddr(fmt.Sprintf("memory:%d", atomic.AddUint64(&memoryDialSeq, 1)))
+	select {
+	case l.conns <- &memoryConn{Conn: server, local: l.addr, remote: local}:
+		return &memoryConn{Conn: client, local: local, remote: l.addr}, nil
+	case <-l.ctx.Done():
+	case <-ctx.Done():
+	}
+	client.Close()
+	server.Close()
+	return nil, errors.Errorf("Failed dialing %s: connection refused", address)
+}
+
+type memoryListener struct {
+	addr      memoryAddr
+	conns     chan net.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// Accept waits for and returns the next connection to the listener
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.ctx.Done():
+		return nil, errors.New("Memory listener closed")
+	}
+}
+
+// Close closes the listener and releases its address
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() {
+		l.cancel()
+		memoryListeners.Lock()
+		defer memoryListeners.Unlock()
+		if memoryListeners.byAddress[string(l.addr)] == l {
+			delete(memoryListeners.byAddress, string(l.addr))
+		}
+	})
+	return nil
+}
+
+// Addr returns the listener's network address
+func (l *memoryListener) Addr() net.Addr {
+	return l.addr
+}
+
+// memoryConn is an in-memory connection reporting the addresses of its ends
+type memoryConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c *memoryConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *memoryConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string {
+	return "memory"
+}
+
+func (a memoryAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/creds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestMemoryConn(t *testing.T) {
+	lis, err := ListenMemory("peer0:7051")
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.Equal(t, "peer0:7051", lis.Addr().String())
+
+	_, err = ListenMemory("peer0:7051")
+	assert.Error(t, err)
+	_, err = DialMemory(context.Background(), "peer1:7051")
+	assert.Error(t, err)
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		assert.Equal(t, "peer0:7051", conn.LocalAddr().String())
+		io.Copy(conn, conn)
+	}()
+
+	conn, err := DialMemory(context.Background(), "peer0:7051")
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "peer0:7051", conn.RemoteAddr().String())
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// The address is released once the listener is closed
+	lis.Close()
+	_, err = lis.Accept()
+	assert.Error(t, err)
+	_, err = DialMemory(context.Background(), "peer0:7051")
+	assert.Error(t, err)
+	lis, err = ListenMemory("peer0:7051")
+	require.NoError(t, err)
+	lis.Close()
+
+	// Dialing a listener which doesn't accept the connection is cancelled along with the context
+	lis, err = ListenMemory("peer0:7051")
+	require.NoError(t, err)
+	defer lis.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = DialMemory(ctx, "peer0:7051")
+	assert.Error(t, err)
+}
+
+func TestGRPCOverMemory(t *testing.T) {
+	c, err := creds.New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: false}})
+	require.NoError(t, err)
+	c.SetDialer(DialMemory)
+
+	for i := 0; i < 100; i++ {
+		lis, err := ListenMemory(fmt.Sprintf("peer%d:7051", i))
+		require.NoError(t, err)
+		srv, err := NewGRPCServerWithCredentials(lis, c)
+		require.NoError(t, err)
+		grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+		go srv.Start()
+		defer srv.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 100; i++ {
+		cc, err := grpc.DialContext(ctx, fmt.Sprintf("peer%d:7051", i), append(c.DialOptions(), grpc.WithBlock())...)
+		require.NoError(t, err)
+		resp, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+		cc.Close()
+	}
+}
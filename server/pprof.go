@@ -0,0 +1,57 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pkg/errors"
+)
+
+// ProfilingPath is the HTTP path under which the pprof handlers are served
+const ProfilingPath = "/debug/pprof/"
+
+// ProfilingServer serves the pprof handlers over HTTP
+type ProfilingServer struct {
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+// ServeProfiling announces on the TCP address and serves the pprof handlers on ProfilingPath,
+// e.g. for "go tool pprof http://localhost:6060/debug/pprof/heap".
+// The handlers aren't authenticated, the address should only be reachable by operators.
+func ServeProfiling(address string) (*ProfilingServer, error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed listening on %s", address)
+	}
+
+	// the handlers are registered on a dedicated mux rather than http.DefaultServeMux,
+	// so that they aren't exposed by the other HTTP servers of the process
+	mux := http.NewServeMux()
+	mux.HandleFunc(ProfilingPath, pprof.Index)
+	mux.HandleFunc(ProfilingPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(ProfilingPath+"profile", pprof.Profile)
+	mux.HandleFunc(ProfilingPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(ProfilingPath+"trace", pprof.Trace)
+
+	s := &ProfilingServer{listener: lis, httpServer: &http.Server{Handler: mux}}
+	go s.httpServer.Serve(lis)
+	return s, nil
+}
+
+// Addr returns the network address the handlers are served on
+func (s *ProfilingServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops serving the handlers
+func (s *ProfilingServer) Close() error {
+	return s.httpServer.Close()
+}
@@ -0,0 +1,42 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeProfiling(t *testing.T) {
+	s, err := ServeProfiling("127.0.0.1:0")
+	require.NoError(t, err)
+
+	url := "http://" + s.Addr().String() + ProfilingPath
+	resp, err := http.Get(url + "goroutine?debug=1")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "TestServeProfiling")
+
+	resp, err = http.Get(url + "cmdline")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = ServeProfiling(s.Addr().String())
+	assert.Error(t, err)
+
+	require.NoError(t, s.Close())
+	_, err = http.Get(url)
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go"
+	"github.com/rkcloudchain/rksync/config"
+)
+
+// quicALPN is the application protocol negotiated during the QUIC handshake
+const quicALPN = "rksync"
+
+var quicConfig = &quic.Config{
+	KeepAlivePeriod: 15 * time.Second,
+}
+
+// quicFallbackFailures is the number of QUIC handshakes a peer fails in a row before it is dialed over TCP only
+const quicFallbackFailures = 3
+
+// ListenQUIC announces on the UDP address and returns a listener accepting
+// the QUIC connections of remote peers, gRPC is served on the first stream
+// opened by the dialer.
+// The QUIC handshake only uses an ephemeral certificate: the remote peers
+// are authenticated by the gRPC credentials and the rksync handshake.
+func ListenQUIC(address string) (net.Listener, error) {
+	cert, err := ephemeralCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{quicALPN},
+	}
+	ql, err := quic.ListenAddr(address, tlsConfig, quicConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed listening on %s", address)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &quicListener{
+		ql:     ql,
+		conns:  make(chan net.Conn),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go l.acceptConns()
+	return l, nil
+}
+
+// DialQUIC dials the remote peer over QUIC and returns the stream gRPC is served on
+func DialQUIC(ctx context.Context, address string) (net.Conn, error) {
+	tlsConfig := &tls.Config{
+		// the remote peer is authenticated by the gRPC credentials
+		InsecureSkipVerify: true,
+		NextProtos:         []string{quicALPN},
+	}
+	qc, err := quic.DialAddr(ctx, address, tlsConfig, quicConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed dialing %s", address)
+	}
+
+	s, err := qc.OpenStreamSync(ctx)
+	if err != nil {
+		qc.CloseWithError(0, "")
+		return nil, errors.Wrapf(err, "Failed opening stream to %s", address)
+	}
+	return &quicConn{Stream: s, conn: qc}, nil
+}
+
+// QUICDialer dials the remote peers over QUIC, or over TCP those which can't be dialed over QUIC,
+// e.g. because they only listen on TCP, so that the QUIC peers can be mixed with TCP peers
+type QUICDialer struct {
+	handshakeTimeout time.Duration
+	fallbackPeriod   time.Duration
+	lock             sync.Mutex
+	// failing holds the addresses of the peers whose last QUIC handshakes failed
+	failing map[string]*quicFailures
+}
+
+type quicFailures struct {
+	count   int       // number of QUIC handshakes failed in a row
+	tcpOnly time.Time // until when the peer is dialed over TCP only
+}
+
+// NewQUICDialer creates a new QUICDialer. The peers which don't complete the QUIC handshake within
+// the handshake timeout are dialed over TCP, and over TCP only during the fallback period
+// once they failed quicFallbackFailures handshakes in a row.
+func NewQUICDialer(handshakeTimeout, fallbackPeriod time.Duration) *QUICDialer {
+	return &QUICDialer{
+		handshakeTimeout: handshakeTimeout,
+		fallbackPeriod:   fallbackPeriod,
+		failing:          make(map[string]*quicFailures),
+	}
+}
+
+// Dial dials the remote peer over QUIC, or over TCP if the QUIC handshake fails
+func (d *QUICDialer) Dial(ctx context.Context, address string) (net.Conn, error) {
+	if !d.tcpOnlyPeer(address) {
+		quicCtx, cancel := context.WithTimeout(ctx, d.handshakeTimeout)
+		conn, err := DialQUIC(quicCtx, address)
+		cancel()
+		if err == nil {
+			d.lock.Lock()
+			delete(d.failing, address)
+			d.lock.Unlock()
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		d.lock.Lock()
+		failures, exists := d.failing[address]
+		if !exists {
+			failures = &quicFailures{}
+			d.failing[address] = failures
+		}
+		failures.count++
+		if failures.count >= quicFallbackFailures {
+			failures.tcpOnly = time.Now().Add(d.fallbackPeriod)
+		}
+		d.lock.Unlock()
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// tcpOnlyPeer returns whether the peer at the given address is dialed over TCP only.
+// Once the fallback period is over, QUIC is tried again, a single failure resumes it.
+func (d *QUICDialer) tcpOnlyPeer(address string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	failures, exists := d.failing[address]
+	return exists && time.Now().Before(failures.tcpOnly)
+}
+
+type quicListener struct {
+	ql        *quic.Listener
+	conns     chan net.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+func (l *quicListener) acceptConns() {
+	for {
+		qc, err := l.ql.Accept(l.ctx)
+		if err != nil {
+			l.cancel()
+			return
+		}
+		go l.acceptStream(qc)
+	}
+}
+
+func (l *quicListener) acceptStream(qc quic.Connection) {
+	ctx, cancel := context.WithTimeout(l.ctx, config.DefaultConnectionTimeout)
+	defer cancel()
+
+	s, err := qc.AcceptStream(ctx)
+	if err != nil {
+		qc.CloseWithError(0, "")
+		return
+	}
+
+	select {
+	case l.conns <- &quicConn{Stream: s, conn: qc}:
+	case <-l.ctx.Done():
+		qc.CloseWithError(0, "")
+	}
+}
+
+// Accept waits for and returns the next connection to the listener
+func (l *quicListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.ctx.Done():
+		return nil, errors.New("QUIC listener closed")
+	}
+}
+
+// Close closes the listener
+func (l *quicListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.cancel()
+		err = l.ql.Close()
+	})
+	return err
+}
+
+// Addr returns the listener's network address
+func (l *quicListener) Addr() net.Addr {
+	return l.ql.Addr()
+}
+
+// quicConn is a net.Conn reading from and writing to a QUIC stream,
+// closing it closes the whole QUIC connection
+type quicConn struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+func (c *quicConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *quicConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *quicConn) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+func ephemeralCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "Failed generating QUIC key")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "Failed generating QUIC certificate serial number")
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: quicALPN},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "Failed creating QUIC certificate")
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/creds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestQUICConn(t *testing.T) {
+	lis, err := ListenQUIC("127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialQUIC(ctx, lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	lis.Close()
+	_, err = lis.Accept()
+	assert.Error(t, err)
+}
+
+func TestGRPCOverQUIC(t *testing.T) {
+	lis, err := ListenQUIC("127.0.0.1:0")
+	require.NoError(t, err)
+
+	c, err := creds.New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: false}})
+	require.NoError(t, err)
+	c.SetDialer(DialQUIC)
+
+	srv, err := NewGRPCServerWithCredentials(lis, c)
+	require.NoError(t, err)
+	grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+	go srv.Start()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, lis.Addr().String(), append(c.DialOptions(), grpc.WithBlock())...)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestQUICDialer(t *testing.T) {
+	// A peer using the QUIC transport dials a peer only listening on TCP
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	c, err := creds.New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: false}})
+	require.NoError(t, err)
+	srv, err := NewGRPCServerWithCredentials(lis, c)
+	require.NoError(t, err)
+	grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+	go srv.Start()
+	defer srv.Stop()
+
+	quicCreds, err := creds.New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: false}})
+	require.NoError(t, err)
+	dialer := NewQUICDialer(500*time.Millisecond, time.Minute)
+	quicCreds.SetDialer(dialer.Dial)
+
+	address := lis.Addr().String()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, address, append(quicCreds.DialOptions(), grpc.WithBlock())...)
+	require.NoError(t, err)
+	defer cc.Close()
+	resp, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	// A single failed handshake doesn't downgrade the peer to TCP
+	assert.False(t, dialer.tcpOnlyPeer(address), "The peer should be dialed over QUIC again")
+	for i := 1; i < quicFallbackFailures; i++ {
+		conn, err := dialer.Dial(ctx, address)
+		require.NoError(t, err)
+		conn.Close()
+	}
+	assert.True(t, dialer.tcpOnlyPeer(address), "The peer should be dialed over TCP without trying QUIC again")
+	assert.False(t, NewQUICDialer(500*time.Millisecond, time.Minute).tcpOnlyPeer(address), "The peers dialed over TCP only aren't shared between dialers")
+
+	// The peer is dialed over TCP at once
+	start := time.Now()
+	conn, err := dialer.Dial(ctx, address)
+	require.NoError(t, err)
+	conn.Close()
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	// QUIC is tried again once the fallback period is over
+	dialer.failing[address].tcpOnly = time.Now()
+	assert.False(t, dialer.tcpOnlyPeer(address))
+	conn, err = dialer.Dial(ctx, address)
+	require.NoError(t, err)
+	conn.Close()
+	assert.True(t, dialer.tcpOnlyPeer(address), "A failure after the fallback period resumes it")
+
+	// A peer listening on QUIC is still dialed over QUIC
+	quicLis, err := ListenQUIC("127.0.0.1:0")
+	require.NoError(t, err)
+	defer quicLis.Close()
+	go func() {
+		if conn, err := quicLis.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err = dialer.Dial(ctx, quicLis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.IsType(t, &quicConn{}, conn)
+}
@@ -7,24 +7,19 @@ SPDX-License-Identifier: Apache-2.0
 package server
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 	"net"
 
 	"github.com/pkg/errors"
 	"github.com/rkcloudchain/rksync/config"
-	"github.com/rkcloudchain/rksync/util"
+	"github.com/rkcloudchain/rksync/creds"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 )
 
 // GRPCServer is the wrapper of grpc.Server
 type GRPCServer struct {
-	address   string
-	listener  net.Listener
-	server    *grpc.Server
-	tlsConfig *tls.Config
+	address  string
+	listener net.Listener
+	server   *grpc.Server
 }
 
 // NewGRPCServer creates a new implementation of a GRPCServer given a
@@ -45,79 +40,27 @@ func NewGRPCServer(address string, serverConfig *config.ServerConfig) (*GRPCServ
 // NewGRPCServerFromListener creates a new instance of GRPCServer given
 // on existing net.Listener
 func NewGRPCServerFromListener(listener net.Listener, serverConfig *config.ServerConfig) (*GRPCServer, error) {
-	grpcServer := &GRPCServer{
-		address:  listener.Addr().String(),
-		listener: listener,
-	}
-
-	var serverOpts []grpc.ServerOption
-
-	var secureConfig config.TLSConfig
-	if serverConfig.SecOpts != nil {
-		secureConfig = *serverConfig.SecOpts
-	}
-
-	if secureConfig.UseTLS {
-		if secureConfig.Key != "" && secureConfig.Certificate != "" {
-			certPEM, err := ioutil.ReadFile(secureConfig.Certificate)
-			if err != nil {
-				return nil, err
-			}
-			keyPEM, err := ioutil.ReadFile(secureConfig.Key)
-			if err != nil {
-				return nil, err
-			}
-
-			cert, err := tls.X509KeyPair(certPEM, keyPEM)
-			if err != nil {
-				return nil, err
-			}
-
-			if len(secureConfig.CipherSuites) == 0 {
-				secureConfig.CipherSuites = config.DefaultTLSCipherSuites
-			}
-
-			grpcServer.tlsConfig = &tls.Config{
-				Certificates: []tls.Certificate{cert},
-				CipherSuites: secureConfig.CipherSuites,
-			}
-			grpcServer.tlsConfig.ClientAuth = tls.RequestClientCert
-			if secureConfig.RequireClientCert {
-				grpcServer.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-				if len(secureConfig.ClientRootCAs) > 0 {
-					grpcServer.tlsConfig.ClientCAs = x509.NewCertPool()
-					for _, clientRootCA := range secureConfig.ClientRootCAs {
-						rootCAPEM, err := ioutil.ReadFile(clientRootCA)
-						if err != nil {
-							return nil, err
-						}
-						err = grpcServer.appendClientRootCA(rootCAPEM)
-						if err != nil {
-							return nil, err
-						}
-					}
-				}
-			}
-
-			creds := credentials.NewTLS(grpcServer.tlsConfig)
-			serverOpts = append(serverOpts, grpc.Creds(creds))
-
-		} else {
-			return nil, errors.New("serverConfig.SecOpts must contain both Key and Certificate when UseTLS is true")
-		}
+	c, err := creds.New(serverConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	serverOpts = append(serverOpts, grpc.MaxSendMsgSize(config.MaxSendMsgSize))
-	serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(config.MaxRecvMsgSize))
-	serverOpts = append(serverOpts, config.ServerKeepaliveOptions(serverConfig.KaOpts)...)
+	return NewGRPCServerWithCredentials(listener, c)
+}
 
-	if serverConfig.ConnectionTimeout <= 0 {
-		serverConfig.ConnectionTimeout = config.DefaultConnectionTimeout
+// NewGRPCServerWithCredentials creates a new instance of GRPCServer given
+// on existing net.Listener and the credentials of the peer
+func NewGRPCServerWithCredentials(listener net.Listener, c *creds.Credentials) (*GRPCServer, error) {
+	serverOpts, err := c.ServerOptions()
+	if err != nil {
+		return nil, err
 	}
-	serverOpts = append(serverOpts, grpc.ConnectionTimeout(serverConfig.ConnectionTimeout))
 
-	grpcServer.server = grpc.NewServer(serverOpts...)
-	return grpcServer, nil
+	return &GRPCServer{
+		address:  listener.Addr().String(),
+		listener: listener,
+		server:   grpc.NewServer(serverOpts...),
+	}, nil
 }
 
 // Server returns the grpc.Server for the GRPCServer instance
@@ -125,6 +68,11 @@ func (srv *GRPCServer) Server() *grpc.Server {
 	return srv.server
 }
 
+// Address returns the address the GRPCServer listens on
+func (srv *GRPCServer) Address() string {
+	return srv.address
+}
+
 // Start starts the underlying grpc.Server
 func (srv *GRPCServer) Start() error {
 	return srv.server.Serve(srv.listener)
@@ -134,21 +82,3 @@ func (srv *GRPCServer) Start() error {
 func (srv *GRPCServer) Stop() {
 	srv.server.Stop()
 }
-
-func (srv *GRPCServer) appendClientRootCA(clientRoot []byte) error {
-	errmsg := "Falied to append client root certificate(s): %s"
-	certs, _, err := util.PEMToX509Certs(clientRoot)
-	if err != nil {
-		return errors.Errorf(errmsg, err)
-	}
-
-	if len(certs) < 1 {
-		return errors.Errorf(errmsg, "No client root certificate found")
-	}
-
-	for _, cert := range certs {
-		srv.tlsConfig.ClientCAs.AddCert(cert)
-	}
-
-	return nil
-}
@@ -0,0 +1,157 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketPath is the HTTP path on which peers accept WebSocket connections
+const WebSocketPath = "/rksync"
+
+// IsWebSocketURL checks whether the endpoint of a peer is a WebSocket URL,
+// e.g. "wss://gateway.example.com/rksync"
+func IsWebSocketURL(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://")
+}
+
+// ListenWebSocket announces on the TCP address and returns a listener accepting
+// the WebSocket connections of remote peers on WebSocketPath, gRPC is served
+// on the WebSocket connections.
+// TLS of wss endpoints is expected to be terminated by a reverse proxy,
+// the remote peers are authenticated by the gRPC credentials and the rksync handshake.
+func ListenWebSocket(address string) (net.Listener, error) {
+	tcpListener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed listening on %s", address)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &wsListener{
+		tcpListener: tcpListener,
+		conns:       make(chan net.Conn),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(WebSocketPath, websocket.Server{Handler: l.serveConn})
+	l.httpServer = &http.Server{Handler: mux}
+	go func() {
+		l.httpServer.Serve(tcpListener)
+		l.cancel()
+	}()
+	return l, nil
+}
+
+// DialWebSocket dials the WebSocket URL of the remote peer
+func DialWebSocket(ctx context.Context, endpoint string) (net.Conn, error) {
+	location, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid WebSocket endpoint %s", endpoint)
+	}
+	origin := &url.URL{Scheme: "http", Host: location.Host}
+	if location.Scheme == "wss" {
+		origin.Scheme = "https"
+	}
+
+	wsConfig, err := websocket.NewConfig(endpoint, origin.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid WebSocket endpoint %s", endpoint)
+	}
+	ws, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed dialing %s", endpoint)
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+type wsListener struct {
+	tcpListener net.Listener
+	httpServer  *http.Server
+	conns       chan net.Conn
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+}
+
+// serveConn hands the WebSocket connection to Accept, the connection
+// is closed by the WebSocket server once serveConn returns
+func (l *wsListener) serveConn(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	conn := &wsConn{Conn: ws, done: make(chan struct{})}
+	if addr, err := net.ResolveTCPAddr("tcp", ws.Request().RemoteAddr); err == nil {
+		conn.remoteAddr = addr
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.ctx.Done():
+		return
+	}
+
+	select {
+	case <-conn.done:
+	case <-l.ctx.Done():
+	}
+}
+
+// Accept waits for and returns the next connection to the listener
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.ctx.Done():
+		return nil, errors.New("WebSocket listener closed")
+	}
+}
+
+// Close closes the listener
+func (l *wsListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.cancel()
+		err = l.httpServer.Close()
+	})
+	return err
+}
+
+// Addr returns the listener's network address
+func (l *wsListener) Addr() net.Addr {
+	return l.tcpListener.Addr()
+}
+
+// wsConn is a WebSocket connection accepted by the listener,
+// reporting the TCP address of the remote peer instead of its origin
+type wsConn struct {
+	*websocket.Conn
+	remoteAddr net.Addr
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *wsConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { close(c.done) })
+	return err
+}
@@ -0,0 +1,90 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/creds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestIsWebSocketURL(t *testing.T) {
+	assert.True(t, IsWebSocketURL("ws://localhost:8080/rksync"))
+	assert.True(t, IsWebSocketURL("wss://gateway.example.com/rksync"))
+	assert.False(t, IsWebSocketURL("localhost:4053"))
+	assert.False(t, IsWebSocketURL("https://gateway.example.com"))
+}
+
+func TestWebSocketConn(t *testing.T) {
+	lis, err := ListenWebSocket("127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialWebSocket(ctx, "ws://"+lis.Addr().String()+WebSocketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	_, err = DialWebSocket(ctx, "ws://"+lis.Addr().String()+"/unknown")
+	assert.Error(t, err)
+
+	lis.Close()
+	_, err = lis.Accept()
+	assert.Error(t, err)
+}
+
+func TestGRPCOverWebSocket(t *testing.T) {
+	lis, err := ListenWebSocket("127.0.0.1:0")
+	require.NoError(t, err)
+
+	c, err := creds.New(&config.ServerConfig{SecOpts: &config.TLSConfig{UseTLS: false}})
+	require.NoError(t, err)
+
+	srv, err := NewGRPCServerWithCredentials(lis, c)
+	require.NoError(t, err)
+	grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+	go srv.Start()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialOpts := append(c.DialOptions(), grpc.WithContextDialer(DialWebSocket), grpc.WithBlock())
+	cc, err := grpc.DialContext(ctx, "ws://"+lis.Addr().String()+WebSocketPath, dialOpts...)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+}
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package rksync
 
 import (
+	"context"
 	"io/ioutil"
 	"net"
 	"os"
@@ -16,10 +17,15 @@ import (
 
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/tests/mocks"
 	"github.com/rkcloudchain/rksync/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func TestValidateGossipConfig(t *testing.T) {
@@ -41,6 +47,30 @@ func TestValidateGossipConfig(t *testing.T) {
 	cfg.FileSystem = mocks.NewFSMock("base")
 	err = validateGossipConfig(cfg)
 	assert.NoError(t, err)
+
+	cfg.AliveTimeInterval = time.Minute
+	cfg.AliveExpirationTimeout = 30 * time.Second
+	err = validateGossipConfig(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "AliveExpirationTimeout must be greater than AliveTimeInterval")
+
+	cfg.AliveExpirationTimeout = 10 * time.Minute
+	cfg.ReconnectInterval = time.Hour
+	cfg.MaxReconnectInterval = time.Minute
+	err = validateGossipConfig(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MaxReconnectInterval can't be less than ReconnectInterval")
+
+	cfg.MaxReconnectInterval = 2 * time.Hour
+	assert.NoError(t, validateGossipConfig(cfg))
+
+	cfg.AdminDebug = true
+	err = validateGossipConfig(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "AdminDebug requires AdminService")
+
+	cfg.AdminService = true
+	assert.NoError(t, validateGossipConfig(cfg))
 }
 
 func TestRKSyncServiceStartup(t *testing.T) {
@@ -48,7 +78,7 @@ func TestRKSyncServiceStartup(t *testing.T) {
 	require.NoError(t, err)
 
 	cfg1 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer0"),
+		HomeDir: testHome(t, home, "peer0"),
 		Gossip: &config.GossipConfig{
 			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer0")),
 			BootstrapPeers: []string{"localhost:8053"},
@@ -67,9 +97,9 @@ func TestRKSyncServiceStartup(t *testing.T) {
 	defer srv1.Stop()
 
 	cfg2 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer1"),
+		HomeDir: testHome(t, home, "peer1"),
 		Gossip: &config.GossipConfig{
-			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer1")),
+			FileSystem:     mocks.NewFSMock(t.TempDir()),
 			BootstrapPeers: []string{"localhost:8053"},
 			Endpoint:       "localhost:9053",
 		},
@@ -86,9 +116,9 @@ func TestRKSyncServiceStartup(t *testing.T) {
 	defer srv2.Stop()
 
 	cfg3 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer2"),
+		HomeDir: testHome(t, home, "peer2"),
 		Gossip: &config.GossipConfig{
-			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer2")),
+			FileSystem:     mocks.NewFSMock(t.TempDir()),
 			BootstrapPeers: []string{"localhost:8053"},
 			Endpoint:       "localhost:10053",
 		},
@@ -134,7 +164,7 @@ func TestRKSyncServiceServe(t *testing.T) {
 	require.NoError(t, err)
 
 	cfg1 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer0"),
+		HomeDir: testHome(t, home, "peer0"),
 		Gossip: &config.GossipConfig{
 			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer0")),
 			BootstrapPeers: []string{"localhost:8053"},
@@ -152,10 +182,11 @@ func TestRKSyncServiceServe(t *testing.T) {
 	assert.NoError(t, err)
 	defer srv1.Stop()
 
+	dataDir := t.TempDir()
 	cfg2 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer1"),
+		HomeDir: testHome(t, home, "peer1"),
 		Gossip: &config.GossipConfig{
-			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer1")),
+			FileSystem:     mocks.NewFSMock(dataDir),
 			BootstrapPeers: []string{"localhost:8053"},
 			Endpoint:       "localhost:9053",
 		},
@@ -195,11 +226,11 @@ func TestRKSyncServiceServe(t *testing.T) {
 
 	time.Sleep(5 * time.Second)
 
-	_, err = os.Stat(filepath.Join(home, "testdata", "peer1", "101.png"))
+	_, err = os.Stat(filepath.Join(dataDir, "101.png"))
 	assert.NoError(t, err)
-	_, err = os.Stat(filepath.Join(home, "testdata", "peer1", "config.yaml"))
+	_, err = os.Stat(filepath.Join(dataDir, "config.yaml"))
 	assert.NoError(t, err)
-	_, err = os.Stat(filepath.Join(home, "testdata", "peer1", "rfc2616.txt"))
+	_, err = os.Stat(filepath.Join(dataDir, "rfc2616.txt"))
 	assert.NoError(t, err)
 
 	_, err = os.Stat(filepath.Join(home, "testdata", "peer2", "101.png"))
@@ -210,12 +241,38 @@ func TestRKSyncServiceServe(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
+// testHome copies the identity fixtures of the given peer to a temporary home directory,
+// so that the files the service writes under its home don't end up in the fixtures
+func testHome(t *testing.T, home, peer string) string {
+	dir := t.TempDir()
+	src := filepath.Join(home, "fixtures", "identity", peer)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), data, info.Mode())
+	})
+	require.NoError(t, err)
+	return dir
+}
+
 func TestChannelInitialize(t *testing.T) {
 	home, err := filepath.Abs("tests")
 	require.NoError(t, err)
 
 	cfg1 := &config.Config{
-		HomeDir: filepath.Join(home, "fixtures", "identity", "peer0"),
+		HomeDir: testHome(t, home, "peer0"),
 		Gossip: &config.GossipConfig{
 			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer0")),
 			BootstrapPeers: []string{"localhost:8053"},
@@ -238,3 +295,88 @@ func TestChannelInitialize(t *testing.T) {
 	chainInfo := srv1.gossip.SelfChainInfo("testchannel1")
 	assert.NotNil(t, chainInfo)
 }
+
+func TestAdminServiceAddress(t *testing.T) {
+	home, err := filepath.Abs("tests")
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		HomeDir: testHome(t, home, "peer0"),
+		Gossip: &config.GossipConfig{
+			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer0")),
+			BootstrapPeers: []string{"localhost:8055"},
+			Endpoint:       "localhost:8055",
+			AdminService:   true,
+			AdminAddress:   "localhost:0",
+		},
+		Identity: &config.IdentityConfig{
+			ID: "peer0.org1",
+		},
+	}
+
+	l, err := net.Listen("tcp", "localhost:8055")
+	require.NoError(t, err)
+	srv, err := Serve(l, cfg)
+	require.NoError(t, err)
+	defer srv.Stop()
+	require.NotNil(t, srv.admin)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	call := func(address string) error {
+		conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = protos.NewAdminClient(conn).ListChannels(ctx, &emptypb.Empty{})
+		return err
+	}
+
+	// The admin service isn't reachable through the gossip endpoint
+	assert.Equal(t, codes.Unimplemented, status.Code(call("localhost:8055")))
+	assert.NoError(t, call(srv.admin.Address()))
+}
+
+func TestMixedTransports(t *testing.T) {
+	home, err := filepath.Abs("tests")
+	require.NoError(t, err)
+
+	// Only the peer using QUIC dials the other one, over TCP
+	quicCfg := &config.Config{
+		HomeDir: testHome(t, home, "peer0"),
+		Gossip: &config.GossipConfig{
+			FileSystem:     mocks.NewFSMock(filepath.Join(home, "testdata", "peer0")),
+			BootstrapPeers: []string{"127.0.0.1:8058"},
+			Endpoint:       "127.0.0.1:8057",
+			Transport:      config.TransportQUIC,
+		},
+		Identity: &config.IdentityConfig{
+			ID: "peer0.org1",
+		},
+	}
+	l1, err := net.Listen("tcp", "127.0.0.1:8057")
+	require.NoError(t, err)
+	srv1, err := Serve(l1, quicCfg)
+	require.NoError(t, err)
+	defer srv1.Stop()
+
+	tcpCfg := &config.Config{
+		HomeDir: testHome(t, home, "peer1"),
+		Gossip: &config.GossipConfig{
+			FileSystem:     mocks.NewFSMock(t.TempDir()),
+			BootstrapPeers: []string{"127.0.0.1:8058"},
+			Endpoint:       "127.0.0.1:8058",
+		},
+		Identity: &config.IdentityConfig{
+			ID: "peer1.org2",
+		},
+	}
+	l2, err := net.Listen("tcp", "127.0.0.1:8058")
+	require.NoError(t, err)
+	srv2, err := Serve(l2, tcpCfg)
+	require.NoError(t, err)
+	defer srv2.Stop()
+
+	require.Eventually(t, func() bool {
+		return len(srv1.gossip.Peers()) == 1 && len(srv2.gossip.Peers()) == 1
+	}, 20*time.Second, 100*time.Millisecond)
+}
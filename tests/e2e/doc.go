@@ -0,0 +1,10 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package e2e contains the end-to-end tests, which run rksync peers in
+// docker containers described by docker-compose.yml. The tests are guarded
+// by the e2e build tag, use `make e2e` to run them.
+package e2e
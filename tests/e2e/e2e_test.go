@@ -0,0 +1,84 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelCreation(t *testing.T) {
+	writeRandom(t, "peer0", "creation.dat", 64*1024)
+	control(t, "peer0", "channel", request{Chain: "creation", Files: []string{"creation.dat"}})
+	for _, peer := range []string{"peer1", "peer2", "peer3"} {
+		control(t, "peer0", "member/add", request{Chain: "creation", Node: peer})
+	}
+
+	// peer3 is behind the NAT and has to pull the file itself
+	waitSynced(t, "peer0", "creation.dat", 2*time.Minute, "peer1", "peer2", "peer3")
+}
+
+func TestMembershipChurn(t *testing.T) {
+	writeRandom(t, "peer0", "churn.dat", 64*1024)
+	control(t, "peer0", "channel", request{Chain: "churn", Files: []string{"churn.dat"}})
+	control(t, "peer0", "member/add", request{Chain: "churn", Node: "peer1"})
+	control(t, "peer0", "member/add", request{Chain: "churn", Node: "peer2"})
+	waitSynced(t, "peer0", "churn.dat", 2*time.Minute, "peer1", "peer2")
+
+	control(t, "peer0", "member/remove", request{Chain: "churn", Node: "peer1"})
+	removed := digest("peer1", "churn.dat")
+	writeRandom(t, "peer0", "churn.dat", 64*1024)
+	waitSynced(t, "peer0", "churn.dat", 2*time.Minute, "peer2")
+	assert.Equal(t, removed, digest("peer1", "churn.dat"), "Removed member kept receiving updates")
+
+	// A member restarting catches up with the updates it missed
+	kill(t, "peer2")
+	writeRandom(t, "peer0", "churn.dat", 64*1024)
+	restart(t, "peer2")
+	waitSynced(t, "peer0", "churn.dat", 2*time.Minute, "peer2")
+
+	control(t, "peer0", "member/add", request{Chain: "churn", Node: "peer1"})
+	waitSynced(t, "peer0", "churn.dat", 2*time.Minute, "peer1")
+}
+
+func TestLeaderFailover(t *testing.T) {
+	writeRandom(t, "peer0", "failover.dat", 64*1024)
+	control(t, "peer0", "channel", request{Chain: "failover", Files: []string{"failover.dat"}})
+	control(t, "peer0", "member/add", request{Chain: "failover", Node: "peer1"})
+	control(t, "peer0", "member/add", request{Chain: "failover", Node: "peer2"})
+	waitSynced(t, "peer0", "failover.dat", 2*time.Minute, "peer1", "peer2")
+
+	// Without the leader the members synchronize between themselves
+	kill(t, "peer0")
+	if _, err := execIn("peer2", "rm", "/data/failover.dat"); err != nil {
+		t.Fatal(err)
+	}
+	waitSynced(t, "peer1", "failover.dat", 2*time.Minute, "peer2")
+
+	// The restarted leader reloads the channel from its home directory
+	restart(t, "peer0")
+	writeRandom(t, "peer0", "failover.dat", 64*1024)
+	waitSynced(t, "peer0", "failover.dat", 2*time.Minute, "peer1", "peer2")
+}
+
+func TestLargeFileSync(t *testing.T) {
+	netem(t, "peer2", "delay", "100ms", "20ms", "loss", "1%")
+	defer netem(t, "peer2")
+
+	writeRandom(t, "peer0", "large.dat", 256*1024*1024)
+	control(t, "peer0", "channel", request{Chain: "large", Files: []string{"large.dat"}})
+	for _, peer := range []string{"peer1", "peer2", "peer3"} {
+		control(t, "peer0", "member/add", request{Chain: "large", Node: peer})
+	}
+
+	waitSynced(t, "peer0", "large.dat", 15*time.Minute, "peer1", "peer2", "peer3")
+}
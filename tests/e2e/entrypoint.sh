@@ -0,0 +1,21 @@
+#!/bin/sh
+#
+# Copyright Rockontrol Corp. All Rights Reserved.
+#
+# SPDX-License-Identifier: Apache-2.0
+#
+
+set -e
+
+# Peers behind the NAT router use it as their default gateway
+if [ -n "$GATEWAY" ]; then
+    ip route replace default via "$GATEWAY"
+fi
+
+# The router masquerades the private network behind its public address
+if [ "$1" = "router" ]; then
+    iptables -t nat -A POSTROUTING -s "$PRIVATE_SUBNET" ! -d "$PRIVATE_SUBNET" -j MASQUERADE
+    exec tail -f /dev/null
+fi
+
+exec rkpeer "$@"
@@ -0,0 +1,244 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/cccsp/hash"
+	"github.com/rkcloudchain/cccsp/provider"
+)
+
+const project = "rksync-e2e"
+
+var peers = []string{"peer0", "peer1", "peer2", "peer3"}
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	identities, err := ioutil.TempDir("", "rksync-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(identities)
+
+	if err := generateIdentities(identities, peers); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("RKSYNC_E2E_IDENTITIES", identities)
+
+	if _, err := compose("build", "peer0"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer compose("down", "-v")
+	if _, err := compose("up", "-d"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Let the peers discover each other
+	time.Sleep(10 * time.Second)
+	return m.Run()
+}
+
+// generateIdentities writes a CA and one identity signed by it per peer
+func generateIdentities(dir string, nodes []string) error {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.e2e"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caRaw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caRaw)
+	if err != nil {
+		return err
+	}
+
+	for i, node := range nodes {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: node},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(24 * time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+		if err != nil {
+			return err
+		}
+
+		privRaw, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		ski, err := provider.GetDefault().Hash(elliptic.Marshal(key.Curve, key.X, key.Y), hash.SHA3256)
+		if err != nil {
+			return err
+		}
+
+		files := map[string]*pem.Block{
+			"csp/cacerts/ca.pem":                              {Type: "CERTIFICATE", Bytes: caRaw},
+			"csp/signcerts/cert.pem":                          {Type: "CERTIFICATE", Bytes: raw},
+			"csp/keystore/" + hex.EncodeToString(ski) + "_sk": {Type: "PRIVATE KEY", Bytes: privRaw},
+		}
+		for name, block := range files {
+			path := filepath.Join(dir, node, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// compose runs a docker compose command against the e2e topology
+func compose(args ...string) (string, error) {
+	args = append([]string{"compose", "-p", project, "-f", "docker-compose.yml"}, args...)
+	cmd := exec.Command("docker", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "docker %s: %s", strings.Join(args, " "), stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// execIn runs a command inside the container of a peer
+func execIn(peer string, command ...string) (string, error) {
+	return compose(append([]string{"exec", "-T", peer}, command...)...)
+}
+
+type request struct {
+	Chain string   `json:"chain"`
+	Node  string   `json:"node,omitempty"`
+	Files []string `json:"files,omitempty"`
+}
+
+// control invokes an operation of the peer control API
+func control(t *testing.T, peer, op string, req request) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = execIn(peer, "wget", "-qO-", "--header", "Content-Type: application/json",
+		"--post-data", string(body), "http://127.0.0.1:7000/"+op)
+	if err != nil {
+		t.Fatalf("%s %s %+v: %s", peer, op, req, err)
+	}
+}
+
+// writeRandom appends size random bytes to a file in the data directory of a peer
+func writeRandom(t *testing.T, peer, filename string, size int) {
+	cmd := fmt.Sprintf("head -c %d /dev/urandom >> /data/%s", size, filename)
+	if _, err := execIn(peer, "sh", "-c", cmd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// digest returns the sha256 of a file in the data directory of a peer,
+// or an empty string if the file can't be read
+func digest(peer, filename string) string {
+	out, err := execIn(peer, "sha256sum", "/data/"+filename)
+	if err != nil {
+		return ""
+	}
+	return strings.Fields(out)[0]
+}
+
+// waitSynced waits until the file has the same content on all the peers as on the source peer
+func waitSynced(t *testing.T, source, filename string, timeout time.Duration, targets ...string) {
+	want := digest(source, filename)
+	if want == "" {
+		t.Fatalf("File %s doesn't exist on %s", filename, source)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, peer := range targets {
+		for digest(peer, filename) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("File %s wasn't synchronized to %s within %s", filename, peer, timeout)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// netem applies a netem queueing discipline to the network interface of a peer,
+// no arguments remove it
+func netem(t *testing.T, peer string, args ...string) {
+	command := []string{"tc", "qdisc", "del", "dev", "eth0", "root"}
+	if len(args) > 0 {
+		command = append([]string{"tc", "qdisc", "replace", "dev", "eth0", "root", "netem"}, args...)
+	}
+	if _, err := execIn(peer, command...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func kill(t *testing.T, peer string) {
+	if _, err := compose("kill", peer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func restart(t *testing.T, peer string) {
+	if _, err := compose("start", peer); err != nil {
+		t.Fatal(err)
+	}
+	// Give the peer time to rejoin the network
+	time.Sleep(10 * time.Second)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command peer runs a rksync peer inside the end-to-end test containers.
+// Besides the gossip endpoint it serves a small HTTP control API, which
+// the test harness uses to manage channels on the peer.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/rkcloudchain/rksync"
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/config"
+	"github.com/rkcloudchain/rksync/logging"
+	"github.com/rkcloudchain/rksync/tests/mocks"
+	"github.com/rkcloudchain/rksync/util"
+)
+
+type request struct {
+	Chain string   `json:"chain"`
+	Node  string   `json:"node,omitempty"`
+	Files []string `json:"files,omitempty"`
+	Mode  string   `json:"mode,omitempty"`
+}
+
+type controller struct {
+	srv        *rksync.Server
+	identities string
+}
+
+func main() {
+	id := flag.String("id", "", "Node ID, also the name of the identity directory")
+	identities := flag.String("identities", "/identities", "Directory containing the identities of all peers")
+	dataDir := flag.String("data", "/data", "Directory containing the synchronized files")
+	listen := flag.String("listen", "0.0.0.0:9053", "Gossip listen address")
+	endpoint := flag.String("endpoint", "", "Gossip endpoint advertised to other peers")
+	bootstrap := flag.String("bootstrap", "", "Comma separated list of bootstrap peers")
+	control := flag.String("control", "127.0.0.1:7000", "Control API listen address")
+	flag.Parse()
+
+	if *id == "" || *endpoint == "" {
+		logging.Error("Both -id and -endpoint must be provided")
+		os.Exit(2)
+	}
+
+	cfg := &config.Config{
+		HomeDir: filepath.Join(*identities, *id),
+		Gossip: &config.GossipConfig{
+			FileSystem:     mocks.NewFSMock(*dataDir),
+			BootstrapPeers: strings.Split(*bootstrap, ","),
+			Endpoint:       *endpoint,
+		},
+		Identity: &config.IdentityConfig{
+			ID: *id,
+		},
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logging.Errorf("Failed listening on %s: %s", *listen, err)
+		os.Exit(1)
+	}
+
+	srv, err := rksync.Serve(l, cfg)
+	if err != nil {
+		logging.Errorf("Failed starting rksync: %s", err)
+		os.Exit(1)
+	}
+
+	c := &controller{srv: srv, identities: *identities}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/channel", c.handle(c.createChannel))
+	mux.HandleFunc("/channel/close", c.handle(c.closeChannel))
+	mux.HandleFunc("/member/add", c.handle(c.addMember))
+	mux.HandleFunc("/member/remove", c.handle(c.removeMember))
+	mux.HandleFunc("/file/add", c.handle(c.addFiles))
+	mux.HandleFunc("/file/remove", c.handle(c.removeFiles))
+	mux.HandleFunc("/file/publish", c.handle(c.publishFiles))
+	go func() {
+		if err := http.ListenAndServe(*control, mux); err != nil {
+			logging.Errorf("Control API exited: %s", err)
+		}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	srv.Stop()
+}
+
+func (c *controller) handle(op func(req *request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req := &request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := op(req); err != nil {
+			logging.Errorf("Control request %s failed: %s", r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (c *controller) createChannel(req *request) error {
+	return c.srv.CreateChannel(req.Chain, req.fileInfos())
+}
+
+func (c *controller) closeChannel(req *request) error {
+	return c.srv.CloseChannel(req.Chain)
+}
+
+func (c *controller) addMember(req *request) error {
+	cert, err := c.nodeCertificate(req.Node)
+	if err != nil {
+		return err
+	}
+	return c.srv.AddMemberToChan(req.Chain, req.Node, cert)
+}
+
+func (c *controller) removeMember(req *request) error {
+	cert, err := c.nodeCertificate(req.Node)
+	if err != nil {
+		return err
+	}
+	return c.srv.RemoveMemberWithChan(req.Chain, req.Node, cert)
+}
+
+func (c *controller) addFiles(req *request) error {
+	return c.srv.AddFileToChan(req.Chain, req.fileInfos())
+}
+
+func (c *controller) removeFiles(req *request) error {
+	return c.srv.RemoveFileWithChan(req.Chain, req.Files)
+}
+
+func (c *controller) publishFiles(req *request) error {
+	for _, filename := range req.Files {
+		if err := c.srv.PublishFileVersion(req.Chain, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *controller) nodeCertificate(nodeID string) (*x509.Certificate, error) {
+	if nodeID == "" {
+		return nil, errors.New("Node ID must be provided")
+	}
+
+	pemBytes, err := ioutil.ReadFile(filepath.Join(c.identities, nodeID, "csp", "signcerts", "cert.pem"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading certificate of %s", nodeID)
+	}
+	return util.GetX509CertificateFromPEM(pemBytes)
+}
+
+func (req *request) fileInfos() []*common.FileSyncInfo {
+	mode := req.Mode
+	if mode == "" {
+		mode = "Append"
+	}
+
+	files := make([]*common.FileSyncInfo, len(req.Files))
+	for i, filename := range req.Files {
+		files[i] = &common.FileSyncInfo{Path: filename, Mode: mode}
+	}
+	return files
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/identity"
+	"github.com/rkcloudchain/rksync/logging"
 	"github.com/rkcloudchain/rksync/tests/util"
 )
 
@@ -27,7 +28,7 @@ func TestLoadBccspIdentity(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = identity.NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {})
+	_, err = identity.NewIdentity(cfg, selfIdentity, func(_ common.PKIidType) {}, logging.Default())
 	if err != nil {
 		t.Fatal(err)
 	}
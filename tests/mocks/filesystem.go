@@ -57,3 +57,31 @@ func (m *FileSystemMock) Stat(chainID string, fmeta config.FileMeta) (os.FileInf
 	p := filepath.Join(m.baseDir, fmeta.Name)
 	return os.Stat(p)
 }
+
+// Rename ...
+func (m *FileSystemMock) Rename(chainID string, from, to config.FileMeta) error {
+	return os.Rename(filepath.Join(m.baseDir, from.Name), filepath.Join(m.baseDir, to.Name))
+}
+
+// Remove ...
+func (m *FileSystemMock) Remove(chainID string, fmeta config.FileMeta) error {
+	err := os.Remove(filepath.Join(m.baseDir, fmeta.Name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// SetAttributes applies the permissions and the modification time, the extended attributes are ignored
+func (m *FileSystemMock) SetAttributes(chainID string, fmeta config.FileMeta, attrs config.FileAttributes) error {
+	p := filepath.Join(m.baseDir, fmeta.Name)
+	if attrs.Perm != 0 {
+		if err := os.Chmod(p, attrs.Perm); err != nil {
+			return err
+		}
+	}
+	if !attrs.ModTime.IsZero() {
+		return os.Chtimes(p, attrs.ModTime, attrs.ModTime)
+	}
+	return nil
+}
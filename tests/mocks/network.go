@@ -0,0 +1,126 @@
+/*
+Copyright Rockontrol Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rkcloudchain/rksync/common"
+	"github.com/rkcloudchain/rksync/protos"
+	"github.com/rkcloudchain/rksync/rpc"
+)
+
+// NewNetwork creates a Network whose links all deliver the messages untouched
+func NewNetwork() *Network {
+	return &Network{links: make(map[link]*linkState)}
+}
+
+// Network is a programmable network fabric between the peers of a test, it can be
+// split into partitions and slowed down link by link. Each peer joins it by
+// installing its interceptor on its rpc server:
+//
+//	srv.SetInterceptor(network.Interceptor(srv.GetPKIid()))
+type Network struct {
+	lock  sync.RWMutex
+	links map[link]*linkState
+}
+
+// link is an unordered pair of peers
+type link struct {
+	a, b string
+}
+
+type linkState struct {
+	cut     bool
+	latency time.Duration
+}
+
+func newLink(p1, p2 common.PKIidType) link {
+	a, b := p1.String(), p2.String()
+	if a > b {
+		a, b = b, a
+	}
+	return link{a: a, b: b}
+}
+
+// state returns the state of the link between the peers, creating it if needed
+func (n *Network) state(p1, p2 common.PKIidType) *linkState {
+	l := newLink(p1, p2)
+	s, exists := n.links[l]
+	if !exists {
+		s = &linkState{}
+		n.links[l] = s
+	}
+	return s
+}
+
+// Partition cuts the links between every peer of groupA and every peer of groupB,
+// the peers within a group still reach each other
+func (n *Network) Partition(groupA, groupB []common.PKIidType) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, a := range groupA {
+		for _, b := range groupB {
+			n.state(a, b).cut = true
+		}
+	}
+}
+
+// Heal restores all the links cut by Partition, their latency is kept
+func (n *Network) Heal() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, s := range n.links {
+		s.cut = false
+	}
+}
+
+// SetLatency delays the messages exchanged by the two peers, in both directions, 0 removes the delay.
+// The messages which follow a delayed message may overtake it if the latency is lowered.
+func (n *Network) SetLatency(p1, p2 common.PKIidType, latency time.Duration) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.state(p1, p2).latency = latency
+}
+
+// Connected returns whether the link between the peers isn't cut
+func (n *Network) Connected(p1, p2 common.PKIidType) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	s, exists := n.links[newLink(p1, p2)]
+	return !exists || !s.cut
+}
+
+// Interceptor returns the interceptor to install on the rpc server of the peer
+func (n *Network) Interceptor(self common.PKIidType) rpc.Interceptor {
+	return &networkInterceptor{network: n, self: self}
+}
+
+type networkInterceptor struct {
+	network *Network
+	self    common.PKIidType
+}
+
+// Intercept drops the messages of the links which are cut, in both directions so that
+// the peers not attached to the network are cut too. The latency is applied to the
+// outgoing messages only, not to count it twice when both peers are attached.
+func (i *networkInterceptor) Intercept(dir rpc.Direction, peer common.PKIidType, msg *protos.SignedRKSyncMessage) rpc.Fault {
+	i.network.lock.RLock()
+	defer i.network.lock.RUnlock()
+
+	s, exists := i.network.links[newLink(i.self, peer)]
+	switch {
+	case !exists:
+		return rpc.Fault{}
+	case s.cut:
+		return rpc.Fault{Drop: true}
+	case dir == rpc.Outgoing:
+		return rpc.Fault{Delay: s.latency}
+	}
+	return rpc.Fault{}
+}
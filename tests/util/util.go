@@ -14,11 +14,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/rkcloudchain/rksync/common"
 	"github.com/rkcloudchain/rksync/config"
 	"github.com/rkcloudchain/rksync/protos"
 	"github.com/rkcloudchain/rksync/util"
+	"google.golang.org/protobuf/proto"
 )
 
 // DefaultGossipConfig returns a default gossip configuration
@@ -35,6 +35,23 @@ func DefaultGossipConfig(bootstrap []string, endpoint string) *config.GossipConf
 		PublishCertPeriod:          20 * time.Second,
 		PublishStateInfoInterval:   4 * time.Second,
 		RequestStateInfoInterval:   4 * time.Second,
+		DeadLetterRate:             10,
+		MaxChannelMembers:          1000,
+		MaxChannelFiles:            10000,
+		MaxChainStateSize:          4 * 1024 * 1024,
+		MaxMessageSize:             100 * 1024 * 1024,
+		AliveMsgTTL:                8,
+		ChainStateMsgTTL:           8,
+		DedupCacheSize:             10000,
+		DedupCacheTTL:              time.Minute,
+		MaxMessageAge:              5 * time.Minute,
+		ReputationThreshold:        50,
+		ReputationBanTime:          10 * time.Minute,
+		MessageWorkers:             4,
+		IdentityCacheTTL:           time.Hour,
+		IdentityCacheSize:          10000,
+		HealthCheckInterval:        time.Second,
+		InvitationTTL:              24 * time.Hour,
 	}
 }
 
@@ -51,7 +68,7 @@ func GetIdentity(cfg *config.IdentityConfig) (common.PeerIdentityType, error) {
 		return nil, errors.New("Encoding of identity failed")
 	}
 
-	sid := &protos.SerializedIdentity{NodeId: cfg.ID, IdBytes: idBytes}
+	sid := &protos.SerializedIdentity{NodeId: cfg.ID, Org: cfg.Org, IdBytes: idBytes, IntermediateCerts: cfg.GetIntermediateCAs()}
 	selfIdentity, err := proto.Marshal(sid)
 	if err != nil {
 		return nil, err